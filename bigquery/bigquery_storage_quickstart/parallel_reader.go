@@ -0,0 +1,301 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/apache/arrow/go/v10/arrow"
+	gax "github.com/googleapis/gax-go/v2"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// readClient is the part of the BigQuery Storage read client used by
+// parallelReader. *bqStorage.BigQueryReadClient implements it.
+type readClient interface {
+	CreateReadSession(ctx context.Context, req *storagepb.CreateReadSessionRequest, opts ...gax.CallOption) (*storagepb.ReadSession, error)
+	ReadRows(ctx context.Context, req *storagepb.ReadRowsRequest, opts ...gax.CallOption) (storagepb.BigQueryRead_ReadRowsClient, error)
+}
+
+// readOptions configures the read session created by newParallelReader.
+type readOptions struct {
+	// Parent is the project that owns the read session, as
+	// "projects/PROJECT_ID".
+	Parent string
+	// Table is the table to read, as
+	// "projects/PROJECT_ID/datasets/DATASET_ID/tables/TABLE_ID".
+	Table string
+	// Format is the row format; it defaults to Arrow.
+	Format storagepb.DataFormat
+
+	// SelectedFields limits the columns read. RowRestriction is a SQL
+	// predicate applied on the server, such as `state = "WA"`.
+	SelectedFields []string
+	RowRestriction string
+	// SnapshotTime reads the table as of a point in time; the zero value
+	// reads current data.
+	SnapshotTime time.Time
+
+	// MaxStreams caps the streams in the session; zero lets the service
+	// choose. Concurrency is the number of streams read at once and
+	// defaults to 4.
+	MaxStreams  int32
+	Concurrency int
+	// MaxRetries is the number of consecutive transient errors tolerated
+	// on a stream without progress. Defaults to 3.
+	MaxRetries int
+}
+
+// parallelReader reads every stream of a Storage Read API session, a
+// bounded number at a time.
+//
+// Callbacks are called from one goroutine per stream, so they may run
+// concurrently; rows from a single stream are delivered in order. If a
+// stream fails with a transient error, it is reopened at the offset of the
+// first row not yet delivered, so no row is delivered twice.
+type parallelReader struct {
+	client  readClient
+	session *storagepb.ReadSession
+	opts    readOptions
+}
+
+// newParallelReader creates a read session for opts.Table.
+func newParallelReader(ctx context.Context, client readClient, opts readOptions) (*parallelReader, error) {
+	if opts.Format == storagepb.DataFormat_DATA_FORMAT_UNSPECIFIED {
+		opts.Format = storagepb.DataFormat_ARROW
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 3
+	}
+	req := &storagepb.CreateReadSessionRequest{
+		Parent: opts.Parent,
+		ReadSession: &storagepb.ReadSession{
+			Table:      opts.Table,
+			DataFormat: opts.Format,
+			ReadOptions: &storagepb.ReadSession_TableReadOptions{
+				SelectedFields: opts.SelectedFields,
+				RowRestriction: opts.RowRestriction,
+			},
+		},
+		MaxStreamCount: opts.MaxStreams,
+	}
+	if !opts.SnapshotTime.IsZero() {
+		req.ReadSession.TableModifiers = &storagepb.ReadSession_TableModifiers{
+			SnapshotTime: timestamppb.New(opts.SnapshotTime),
+		}
+	}
+	session, err := client.CreateReadSession(ctx, req, rpcOpts)
+	if err != nil {
+		return nil, fmt.Errorf("CreateReadSession: %w", err)
+	}
+	return &parallelReader{client: client, session: session, opts: opts}, nil
+}
+
+// Session returns the read session. It has no streams if the table or the
+// row restriction yields no rows.
+func (r *parallelReader) Session() *storagepb.ReadSession { return r.session }
+
+// ReadArrow calls fn with each Arrow record batch. Records are released
+// when fn returns; call Retain to keep one.
+func (r *parallelReader) ReadArrow(ctx context.Context, fn func(stream string, rec arrow.Record) error) error {
+	if r.session.GetDataFormat() != storagepb.DataFormat_ARROW {
+		return fmt.Errorf("session format is %v, not ARROW", r.session.GetDataFormat())
+	}
+	dec, err := newArrowDecoder(r.session.GetArrowSchema().GetSerializedSchema())
+	if err != nil {
+		return err
+	}
+	return r.forEachStream(ctx, func(ctx context.Context, stream string, resp *storagepb.ReadRowsResponse) error {
+		recs, err := dec.records(resp)
+		if err != nil {
+			return err
+		}
+		defer releaseAll(recs)
+		for _, rec := range recs {
+			if err := fn(stream, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReadRows calls fn with each row as a map from column name to value.
+// Values are nil for NULL, or one of string, []byte, int64, float64, bool,
+// time.Time (TIMESTAMP), civil.Date, *big.Rat (NUMERIC), []any (REPEATED)
+// and map[string]any (RECORD).
+func (r *parallelReader) ReadRows(ctx context.Context, fn func(stream string, row map[string]any) error) error {
+	dec, err := newRowDecoder(r.session)
+	if err != nil {
+		return err
+	}
+	return r.forEachStream(ctx, func(ctx context.Context, stream string, resp *storagepb.ReadRowsResponse) error {
+		rows, err := dec.rows(resp)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := fn(stream, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readStructs calls fn with each row decoded into a T, which must be a
+// struct type. Columns are matched to fields as bigquery.InferSchema does:
+// by the name in a `bigquery:"name"` tag, or else by field name, ignoring
+// case. Columns without a matching field are ignored.
+func readStructs[T any](ctx context.Context, r *parallelReader, fn func(stream string, row T) error) error {
+	var zero T
+	sd, err := newStructDecoder(zero)
+	if err != nil {
+		return err
+	}
+	return r.ReadRows(ctx, func(stream string, row map[string]any) error {
+		var v T
+		if err := sd.decode(&v, row); err != nil {
+			return err
+		}
+		return fn(stream, v)
+	})
+}
+
+// forEachStream calls handle with every response of every stream, reading
+// up to opts.Concurrency streams at once. The first error cancels the other
+// streams and is returned.
+func (r *parallelReader) forEachStream(ctx context.Context, handle func(ctx context.Context, stream string, resp *storagepb.ReadRowsResponse) error) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(r.opts.Concurrency)
+	for _, s := range r.session.GetStreams() {
+		stream := s.GetName()
+		g.Go(func() error {
+			return r.readStream(ctx, stream, func(resp *storagepb.ReadRowsResponse) error {
+				return handle(ctx, stream, resp)
+			})
+		})
+	}
+	return g.Wait()
+}
+
+// readStream calls handle with each response of stream. Transient errors
+// reopen the stream at the offset after the last handled response; the retry
+// budget resets whenever a response is handled.
+func (r *parallelReader) readStream(ctx context.Context, stream string, handle func(*storagepb.ReadRowsResponse) error) error {
+	bo := gax.Backoff{Initial: 100 * time.Millisecond, Max: 10 * time.Second, Multiplier: 2}
+	var offset int64
+	retries := 0
+	for {
+		err := r.readFrom(ctx, stream, &offset, func(resp *storagepb.ReadRowsResponse) error {
+			if err := handle(resp); err != nil {
+				return &handlerError{err}
+			}
+			retries = 0
+			return nil
+		})
+		var he *handlerError
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, &he):
+			return he.err
+		case ctx.Err() != nil:
+			return ctx.Err()
+		}
+
+		delay, ok := retryDelay(err)
+		if !ok {
+			return fmt.Errorf("reading %s at offset %d: %w", stream, offset, err)
+		}
+		if retries++; retries > r.opts.MaxRetries {
+			return fmt.Errorf("reading %s at offset %d, retries exhausted: %w", stream, offset, err)
+		}
+		if delay == 0 {
+			delay = bo.Pause()
+		}
+		if err := gax.Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// readFrom makes one ReadRows call at *offset, advancing it past each
+// handled response.
+func (r *parallelReader) readFrom(ctx context.Context, stream string, offset *int64, handle func(*storagepb.ReadRowsResponse) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	rows, err := r.client.ReadRows(ctx, &storagepb.ReadRowsRequest{
+		ReadStream: stream,
+		Offset:     *offset,
+	}, rpcOpts)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := rows.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if resp.GetRowCount() == 0 {
+			continue
+		}
+		if err := handle(resp); err != nil {
+			return err
+		}
+		*offset += resp.GetRowCount()
+	}
+}
+
+// handlerError marks an error returned by a callback, which is not retried.
+type handlerError struct{ err error }
+
+func (e *handlerError) Error() string { return e.err.Error() }
+
+// retryDelay reports whether a ReadRows error is transient, and the delay
+// the service asked for, if any.
+func retryDelay(err error) (time.Duration, bool) {
+	s, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.Internal, codes.Aborted:
+		return 0, true
+	case codes.ResourceExhausted:
+		for _, d := range s.Details() {
+			if ri, ok := d.(*errdetails.RetryInfo); ok {
+				return ri.GetRetryDelay().AsDuration(), true
+			}
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,576 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/civil"
+	"github.com/apache/arrow/go/v10/arrow"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The fixtures in testdata hold a ReadSession and its ReadRowsResponse
+// messages, one protojson message per line, for a two-stream session over a
+// table with this schema:
+//
+//	name STRING, number INT64, state STRING, born TIMESTAMP, day DATE,
+//	score FLOAT64, ok BOOL, amount NUMERIC, tags ARRAY<STRING>,
+//	place STRUCT<city STRING, zip INT64>
+//
+// The first stream returns two responses and the second returns one. The
+// rows are the same in both formats.
+type fixture struct {
+	session   *storagepb.ReadSession
+	responses map[string][]*storagepb.ReadRowsResponse
+}
+
+func loadFixture(t *testing.T, name string) *fixture {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fx := &fixture{responses: map[string][]*storagepb.ReadRowsResponse{}}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var line struct {
+			Session  json.RawMessage
+			Stream   string
+			Response json.RawMessage
+		}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if line.Session != nil {
+			fx.session = &storagepb.ReadSession{}
+			if err := protojson.Unmarshal(line.Session, fx.session); err != nil {
+				t.Fatalf("%s: session: %v", name, err)
+			}
+			continue
+		}
+		resp := &storagepb.ReadRowsResponse{}
+		if err := protojson.Unmarshal(line.Response, resp); err != nil {
+			t.Fatalf("%s: response: %v", name, err)
+		}
+		fx.responses[line.Stream] = append(fx.responses[line.Stream], resp)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return fx
+}
+
+var fixtureFormats = []struct {
+	format storagepb.DataFormat
+	file   string
+}{
+	{storagepb.DataFormat_ARROW, "arrow_read_session.jsonl"},
+	{storagepb.DataFormat_AVRO, "avro_read_session.jsonl"},
+}
+
+var (
+	t1 = time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+	t2 = time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)
+	d1 = civil.Date{Year: 2020, Month: 1, Day: 2}
+	d2 = civil.Date{Year: 1969, Month: 7, Day: 20}
+)
+
+// fixtureRows are the rows in the fixtures, by stream, with RATs formatted
+// by canonical.
+var fixtureRows = map[string][]map[string]any{
+	"s0": {
+		{"name": "Ada", "number": int64(12), "state": "WA", "born": t1, "day": d1, "score": 1.5, "ok": true,
+			"amount": "25/2", "tags": []any{"a", "b"}, "place": map[string]any{"city": "Seattle", "zip": int64(98101)}},
+		{"name": "Grace", "number": int64(7), "state": "WA", "born": nil, "day": nil, "score": nil, "ok": false,
+			"amount": nil, "tags": []any{}, "place": nil},
+		{"name": "Linus", "number": int64(3), "state": "WA", "born": t2, "day": d2, "score": -0.25, "ok": true,
+			"amount": nil, "tags": []any{"c"}, "place": map[string]any{"city": "Spokane", "zip": nil}},
+	},
+	"s1": {
+		{"name": "Ken", "number": int64(40), "state": "WA", "born": t1, "day": d1, "score": 3.0, "ok": false,
+			"amount": "-1/1000", "tags": []any{}, "place": nil},
+		{"name": "Barbara", "number": int64(5), "state": "WA", "born": nil, "day": d2, "score": nil, "ok": true,
+			"amount": nil, "tags": []any{"x", "y", "z"}, "place": map[string]any{"city": "Tacoma", "zip": int64(98402)}},
+		{"name": "Edsger", "number": int64(1), "state": "WA", "born": t2, "day": nil, "score": 0.0, "ok": false,
+			"amount": nil, "tags": []any{}, "place": map[string]any{"city": nil, "zip": int64(98001)}},
+	},
+}
+
+// canonical replaces *big.Rat values, which reflect.DeepEqual cannot
+// compare reliably, with their RatString.
+func canonical(v any) any {
+	switch x := v.(type) {
+	case *big.Rat:
+		return x.RatString()
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = canonical(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = canonical(e)
+		}
+		return out
+	}
+	return v
+}
+
+func streamID(name string) string { return filepath.Base(name) }
+
+func TestRowDecoders(t *testing.T) {
+	for _, tc := range fixtureFormats {
+		t.Run(tc.format.String(), func(t *testing.T) {
+			fx := loadFixture(t, tc.file)
+			if got := fx.session.GetDataFormat(); got != tc.format {
+				t.Fatalf("fixture format = %v", got)
+			}
+			dec, err := newRowDecoder(fx.session)
+			if err != nil {
+				t.Fatalf("newRowDecoder: %v", err)
+			}
+			for stream, resps := range fx.responses {
+				var got []any
+				for _, resp := range resps {
+					rows, err := dec.rows(resp)
+					if err != nil {
+						t.Fatalf("rows: %v", err)
+					}
+					if int64(len(rows)) != resp.GetRowCount() {
+						t.Errorf("decoded %d rows from a response of %d", len(rows), resp.GetRowCount())
+					}
+					for _, row := range rows {
+						got = append(got, canonical(row))
+					}
+				}
+				var want []any
+				for _, row := range fixtureRows[streamID(stream)] {
+					want = append(want, row)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("stream %s rows:\n%v\nwant:\n%v", streamID(stream), got, want)
+				}
+			}
+		})
+	}
+}
+
+// fakeReadClient serves a fixture. It fails each stream once with an
+// injected error after the given number of responses.
+type fakeReadClient struct {
+	fx *fixture
+
+	mu        sync.Mutex
+	req       *storagepb.CreateReadSessionRequest
+	reads     []*storagepb.ReadRowsRequest
+	failAfter map[string]int
+	failWith  error
+	active    int
+	maxActive int
+}
+
+func (c *fakeReadClient) CreateReadSession(ctx context.Context, req *storagepb.CreateReadSessionRequest, opts ...gax.CallOption) (*storagepb.ReadSession, error) {
+	c.req = req
+	s := proto.Clone(c.fx.session).(*storagepb.ReadSession)
+	s.DataFormat = req.GetReadSession().GetDataFormat()
+	return s, nil
+}
+
+func (c *fakeReadClient) ReadRows(ctx context.Context, req *storagepb.ReadRowsRequest, opts ...gax.CallOption) (storagepb.BigQueryRead_ReadRowsClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads = append(c.reads, req)
+	resps, ok := c.fx.responses[req.GetReadStream()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no stream %s", req.GetReadStream())
+	}
+	var offset int64
+	for len(resps) > 0 && offset < req.GetOffset() {
+		offset += resps[0].GetRowCount()
+		resps = resps[1:]
+	}
+	if offset != req.GetOffset() {
+		return nil, status.Errorf(codes.OutOfRange, "offset %d is not at a response boundary", req.GetOffset())
+	}
+	s := &fakeRowsStream{client: c, resps: resps, fail: -1}
+	if n, ok := c.failAfter[req.GetReadStream()]; ok {
+		delete(c.failAfter, req.GetReadStream())
+		s.fail = n
+	}
+	c.active++
+	if c.active > c.maxActive {
+		c.maxActive = c.active
+	}
+	return s, nil
+}
+
+type fakeRowsStream struct {
+	grpc.ClientStream
+	client *fakeReadClient
+	resps  []*storagepb.ReadRowsResponse
+	fail   int
+	done   bool
+}
+
+func (s *fakeRowsStream) Recv() (*storagepb.ReadRowsResponse, error) {
+	if s.done {
+		return nil, errors.New("Recv after end of stream")
+	}
+	var err error
+	switch {
+	case s.fail == 0:
+		err = s.client.failWith
+	case len(s.resps) == 0:
+		err = io.EOF
+	default:
+		// Yield so that streams overlap when concurrency allows it.
+		time.Sleep(time.Millisecond)
+		s.fail--
+		resp := s.resps[0]
+		s.resps = s.resps[1:]
+		return resp, nil
+	}
+	s.done = true
+	s.client.mu.Lock()
+	s.client.active--
+	s.client.mu.Unlock()
+	return nil, err
+}
+
+type testPlace struct {
+	City string
+	Zip  *int64
+}
+
+type testName struct {
+	Name   string `bigquery:"name"`
+	Number int
+	State  string
+	Born   time.Time
+	Day    *civil.Date
+	Score  *float64
+	OK     bool `bigquery:"ok"`
+	Amount *big.Rat
+	Tags   []string
+	Place  *testPlace
+	Ignore string `bigquery:"-"`
+}
+
+func ptr[T any](v T) *T { return &v }
+
+var fixtureStructs = []testName{
+	{Name: "Ada", Number: 12, State: "WA", Born: t1, Day: &d1, Score: ptr(1.5), OK: true, Amount: big.NewRat(25, 2),
+		Tags: []string{"a", "b"}, Place: &testPlace{City: "Seattle", Zip: ptr(int64(98101))}},
+	{Name: "Barbara", Number: 5, State: "WA", Day: &d2, OK: true,
+		Tags: []string{"x", "y", "z"}, Place: &testPlace{City: "Tacoma", Zip: ptr(int64(98402))}},
+	{Name: "Edsger", Number: 1, State: "WA", Born: t2, Score: ptr(0.0), Tags: []string{}, Place: &testPlace{Zip: ptr(int64(98001))}},
+	{Name: "Grace", Number: 7, State: "WA", Tags: []string{}},
+	{Name: "Ken", Number: 40, State: "WA", Born: t1, Day: &d1, Score: ptr(3.0), Amount: big.NewRat(-1, 1000), Tags: []string{}},
+	{Name: "Linus", Number: 3, State: "WA", Born: t2, Day: &d2, Score: ptr(-0.25), OK: true,
+		Tags: []string{"c"}, Place: &testPlace{City: "Spokane"}},
+}
+
+func newTestReader(t *testing.T, c *fakeReadClient, format storagepb.DataFormat, concurrency int) *parallelReader {
+	t.Helper()
+	r, err := newParallelReader(context.Background(), c, readOptions{
+		Parent:      "projects/fixture-project",
+		Table:       c.fx.session.GetTable(),
+		Format:      format,
+		Concurrency: concurrency,
+	})
+	if err != nil {
+		t.Fatalf("newParallelReader: %v", err)
+	}
+	return r
+}
+
+// readAllStructs reads r into testName values sorted by name.
+func readAllStructs(ctx context.Context, r *parallelReader) ([]testName, error) {
+	var mu sync.Mutex
+	var rows []testName
+	err := readStructs(ctx, r, func(stream string, row testName) error {
+		mu.Lock()
+		defer mu.Unlock()
+		rows = append(rows, row)
+		return nil
+	})
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return rows, err
+}
+
+func equalNames(t *testing.T, got, want []testName) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		g, w := got[i], want[i]
+		if (g.Amount == nil) != (w.Amount == nil) || (g.Amount != nil && g.Amount.Cmp(w.Amount) != 0) {
+			t.Errorf("%s: Amount = %v, want %v", w.Name, g.Amount, w.Amount)
+		}
+		g.Amount, w.Amount = nil, nil
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("row %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestReadStructs(t *testing.T) {
+	for _, tc := range fixtureFormats {
+		t.Run(tc.format.String(), func(t *testing.T) {
+			c := &fakeReadClient{fx: loadFixture(t, tc.file)}
+			r := newTestReader(t, c, tc.format, 4)
+			rows, err := readAllStructs(context.Background(), r)
+			if err != nil {
+				t.Fatalf("readStructs: %v", err)
+			}
+			equalNames(t, rows, fixtureStructs)
+		})
+	}
+}
+
+func TestReadConcurrency(t *testing.T) {
+	c := &fakeReadClient{fx: loadFixture(t, "arrow_read_session.jsonl")}
+	r := newTestReader(t, c, storagepb.DataFormat_ARROW, 1)
+	var order []string
+	err := r.ReadRows(context.Background(), func(stream string, row map[string]any) error {
+		order = append(order, row["name"].(string))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadRows: %v", err)
+	}
+	if c.maxActive != 1 {
+		t.Errorf("read %d streams at once, want 1", c.maxActive)
+	}
+	if want := []string{"Ada", "Grace", "Linus", "Ken", "Barbara", "Edsger"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("rows read in order %v, want %v", order, want)
+	}
+}
+
+func TestReadArrow(t *testing.T) {
+	c := &fakeReadClient{fx: loadFixture(t, "arrow_read_session.jsonl")}
+	r := newTestReader(t, c, storagepb.DataFormat_ARROW, 2)
+
+	var mu sync.Mutex
+	rows := map[string]int64{}
+	var kept []arrow.Record
+	err := r.ReadArrow(context.Background(), func(stream string, rec arrow.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if rec.NumCols() != 10 || rec.ColumnName(0) != "name" {
+			return fmt.Errorf("record has schema %v", rec.Schema())
+		}
+		rows[streamID(stream)] += rec.NumRows()
+		rec.Retain()
+		kept = append(kept, rec)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadArrow: %v", err)
+	}
+	defer releaseAll(kept)
+	if want := map[string]int64{"s0": 3, "s1": 3}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows per stream = %v, want %v", rows, want)
+	}
+	if len(kept) != 3 {
+		t.Errorf("got %d record batches, want 3", len(kept))
+	}
+
+	avro := &fakeReadClient{fx: loadFixture(t, "avro_read_session.jsonl")}
+	r = newTestReader(t, avro, storagepb.DataFormat_AVRO, 2)
+	if err := r.ReadArrow(context.Background(), func(string, arrow.Record) error { return nil }); err == nil {
+		t.Errorf("ReadArrow on an Avro session succeeded")
+	}
+}
+
+func TestReadResume(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+	}{
+		{"Unavailable", status.Error(codes.Unavailable, "stream reset")},
+		{"RetryInfo", retryInfoError(t, time.Millisecond)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &fakeReadClient{fx: loadFixture(t, "avro_read_session.jsonl")}
+			s0 := c.fx.session.GetStreams()[0].GetName()
+			c.failAfter = map[string]int{s0: 1}
+			c.failWith = tc.err
+			r := newTestReader(t, c, storagepb.DataFormat_AVRO, 2)
+
+			rows, err := readAllStructs(context.Background(), r)
+			if err != nil {
+				t.Fatalf("readStructs: %v", err)
+			}
+			equalNames(t, rows, fixtureStructs)
+
+			var offsets []int64
+			for _, req := range c.reads {
+				if req.GetReadStream() == s0 {
+					offsets = append(offsets, req.GetOffset())
+				}
+			}
+			if want := []int64{0, 2}; !reflect.DeepEqual(offsets, want) {
+				t.Errorf("ReadRows offsets for stream 0 = %v, want %v", offsets, want)
+			}
+		})
+	}
+}
+
+func retryInfoError(t *testing.T, d time.Duration) error {
+	st, err := status.New(codes.ResourceExhausted, "slow down").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(d)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.Err()
+}
+
+func TestReadErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Permanent", func(t *testing.T) {
+		c := &fakeReadClient{fx: loadFixture(t, "arrow_read_session.jsonl")}
+		s0 := c.fx.session.GetStreams()[0].GetName()
+		c.failAfter = map[string]int{s0: 0}
+		c.failWith = status.Error(codes.PermissionDenied, "no")
+		r := newTestReader(t, c, storagepb.DataFormat_ARROW, 2)
+		if _, err := readAllStructs(ctx, r); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("readStructs() = %v, want PermissionDenied", err)
+		}
+	})
+
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		c := &fakeReadClient{fx: loadFixture(t, "arrow_read_session.jsonl")}
+		r := newTestReader(t, c, storagepb.DataFormat_ARROW, 1)
+		r.opts.MaxRetries = 2
+		failing := &alwaysFailingClient{readClient: c, err: status.Error(codes.Unavailable, "down"), calls: map[string]int{}}
+		r.client = failing
+		if _, err := readAllStructs(ctx, r); status.Code(err) != codes.Unavailable {
+			t.Errorf("readStructs() = %v, want Unavailable", err)
+		}
+		s0 := c.fx.session.GetStreams()[0].GetName()
+		if n := failing.calls[s0]; n != 3 {
+			t.Errorf("ReadRows called %d times for the first stream, want 3", n)
+		}
+	})
+
+	t.Run("Callback", func(t *testing.T) {
+		c := &fakeReadClient{fx: loadFixture(t, "avro_read_session.jsonl")}
+		r := newTestReader(t, c, storagepb.DataFormat_AVRO, 2)
+		stop := errors.New("stop")
+		err := r.ReadRows(ctx, func(string, map[string]any) error { return stop })
+		if err != stop {
+			t.Errorf("ReadRows() = %v, want the callback's error", err)
+		}
+	})
+
+	t.Run("Decode", func(t *testing.T) {
+		c := &fakeReadClient{fx: loadFixture(t, "avro_read_session.jsonl")}
+		r := newTestReader(t, c, storagepb.DataFormat_AVRO, 2)
+		type wrong struct{ Name int64 }
+		err := readStructs(ctx, r, func(string, wrong) error { return nil })
+		if err == nil {
+			t.Errorf("readStructs into a mismatched struct succeeded")
+		}
+	})
+}
+
+type alwaysFailingClient struct {
+	readClient
+	err   error
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *alwaysFailingClient) ReadRows(ctx context.Context, req *storagepb.ReadRowsRequest, opts ...gax.CallOption) (storagepb.BigQueryRead_ReadRowsClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[req.GetReadStream()]++
+	return nil, c.err
+}
+
+func TestReadSessionRequest(t *testing.T) {
+	c := &fakeReadClient{fx: loadFixture(t, "avro_read_session.jsonl")}
+	snapshot := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	_, err := newParallelReader(context.Background(), c, readOptions{
+		Parent:         "projects/p",
+		Table:          "projects/p/datasets/d/tables/t",
+		Format:         storagepb.DataFormat_AVRO,
+		SelectedFields: []string{"name", "state"},
+		RowRestriction: `state = "WA"`,
+		SnapshotTime:   snapshot,
+		MaxStreams:     8,
+	})
+	if err != nil {
+		t.Fatalf("newParallelReader: %v", err)
+	}
+	want := &storagepb.CreateReadSessionRequest{
+		Parent: "projects/p",
+		ReadSession: &storagepb.ReadSession{
+			Table:      "projects/p/datasets/d/tables/t",
+			DataFormat: storagepb.DataFormat_AVRO,
+			ReadOptions: &storagepb.ReadSession_TableReadOptions{
+				SelectedFields: []string{"name", "state"},
+				RowRestriction: `state = "WA"`,
+			},
+			TableModifiers: &storagepb.ReadSession_TableModifiers{
+				SnapshotTime: timestamppb.New(snapshot),
+			},
+		},
+		MaxStreamCount: 8,
+	}
+	if !proto.Equal(c.req, want) {
+		t.Errorf("CreateReadSession request:\n%v\nwant:\n%v", c.req, want)
+	}
+
+	if _, err := newParallelReader(context.Background(), c, readOptions{Table: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.req.GetReadSession().GetDataFormat(); got != storagepb.DataFormat_ARROW {
+		t.Errorf("default format = %v, want ARROW", got)
+	}
+	if c.req.GetReadSession().GetTableModifiers() != nil {
+		t.Errorf("request without a snapshot time has table modifiers")
+	}
+}
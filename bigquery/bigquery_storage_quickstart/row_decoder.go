@@ -0,0 +1,345 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/civil"
+	"github.com/apache/arrow/go/v10/arrow"
+	"github.com/apache/arrow/go/v10/arrow/array"
+	"github.com/apache/arrow/go/v10/arrow/ipc"
+	"github.com/apache/arrow/go/v10/arrow/memory"
+	goavro "github.com/linkedin/goavro/v2"
+)
+
+// rowDecoder turns a ReadRows response into rows keyed by column name.
+type rowDecoder interface {
+	rows(resp *storagepb.ReadRowsResponse) ([]map[string]any, error)
+}
+
+// newRowDecoder returns a decoder for the session's data format.
+func newRowDecoder(session *storagepb.ReadSession) (rowDecoder, error) {
+	switch session.GetDataFormat() {
+	case storagepb.DataFormat_ARROW:
+		return newArrowDecoder(session.GetArrowSchema().GetSerializedSchema())
+	case storagepb.DataFormat_AVRO:
+		return newAvroDecoder(session.GetAvroSchema().GetSchema())
+	}
+	return nil, fmt.Errorf("unsupported data format %v", session.GetDataFormat())
+}
+
+// arrowDecoder decodes Arrow record batches. Each response holds an IPC
+// record batch message that is read after the session's schema message.
+type arrowDecoder struct {
+	serialized []byte
+	schema     *arrow.Schema
+	mem        memory.Allocator
+}
+
+func newArrowDecoder(serializedSchema []byte) (*arrowDecoder, error) {
+	mem := memory.NewGoAllocator()
+	r, err := ipc.NewReader(bytes.NewReader(serializedSchema), ipc.WithAllocator(mem))
+	if err != nil {
+		return nil, fmt.Errorf("reading Arrow schema: %w", err)
+	}
+	defer r.Release()
+	return &arrowDecoder{serialized: serializedSchema, schema: r.Schema(), mem: mem}, nil
+}
+
+// records returns the record batches in resp. The caller must release them.
+func (d *arrowDecoder) records(resp *storagepb.ReadRowsResponse) ([]arrow.Record, error) {
+	batch := resp.GetArrowRecordBatch().GetSerializedRecordBatch()
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	buf := bytes.NewBuffer(append(append([]byte{}, d.serialized...), batch...))
+	r, err := ipc.NewReader(buf, ipc.WithAllocator(d.mem), ipc.WithSchema(d.schema))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Release()
+	var recs []arrow.Record
+	for r.Next() {
+		rec := r.Record()
+		rec.Retain()
+		recs = append(recs, rec)
+	}
+	if err := r.Err(); err != nil {
+		releaseAll(recs)
+		return nil, fmt.Errorf("reading Arrow record batch: %w", err)
+	}
+	return recs, nil
+}
+
+func (d *arrowDecoder) rows(resp *storagepb.ReadRowsResponse) ([]map[string]any, error) {
+	recs, err := d.records(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseAll(recs)
+	var rows []map[string]any
+	for _, rec := range recs {
+		for i := 0; i < int(rec.NumRows()); i++ {
+			row := make(map[string]any, rec.NumCols())
+			for c, col := range rec.Columns() {
+				v, err := arrowValue(col, i)
+				if err != nil {
+					return nil, fmt.Errorf("column %s: %w", rec.ColumnName(c), err)
+				}
+				row[rec.ColumnName(c)] = v
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func releaseAll(recs []arrow.Record) {
+	for _, rec := range recs {
+		rec.Release()
+	}
+}
+
+// arrowValue returns element i of arr as one of the Go types listed on
+// parallelReader.ReadRows.
+func arrowValue(arr arrow.Array, i int) (any, error) {
+	if arr.IsNull(i) {
+		return nil, nil
+	}
+	switch a := arr.(type) {
+	case *array.String:
+		return a.Value(i), nil
+	case *array.Binary:
+		return append([]byte{}, a.Value(i)...), nil
+	case *array.Int64:
+		return a.Value(i), nil
+	case *array.Float64:
+		return a.Value(i), nil
+	case *array.Boolean:
+		return a.Value(i), nil
+	case *array.Timestamp:
+		unit := a.DataType().(*arrow.TimestampType).Unit
+		return a.Value(i).ToTime(unit).UTC(), nil
+	case *array.Date32:
+		return civil.DateOf(a.Value(i).ToTime()), nil
+	case *array.Decimal128:
+		scale := a.DataType().(*arrow.Decimal128Type).Scale
+		return decimalRat(a.Value(i).BigInt(), scale), nil
+	case *array.Decimal256:
+		scale := a.DataType().(*arrow.Decimal256Type).Scale
+		return decimalRat(a.Value(i).BigInt(), scale), nil
+	case *array.List:
+		start, end := a.ValueOffsets(i)
+		vals := make([]any, 0, end-start)
+		for j := start; j < end; j++ {
+			v, err := arrowValue(a.ListValues(), int(j))
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, v)
+		}
+		return vals, nil
+	case *array.Struct:
+		st := a.DataType().(*arrow.StructType)
+		m := make(map[string]any, a.NumField())
+		for f := 0; f < a.NumField(); f++ {
+			v, err := arrowValue(a.Field(f), i)
+			if err != nil {
+				return nil, err
+			}
+			m[st.Field(f).Name] = v
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported Arrow type %s", arr.DataType())
+}
+
+func decimalRat(unscaled *big.Int, scale int32) *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	return new(big.Rat).SetFrac(unscaled, denom)
+}
+
+// avroDecoder decodes blocks of Avro binary rows. goavro wraps values of
+// union types, which BigQuery uses for NULLABLE columns, in single-entry
+// maps; the decoder uses the schema to unwrap them.
+type avroDecoder struct {
+	codec *goavro.Codec
+	root  *avroNode
+}
+
+// avroNode is the part of an Avro schema needed to normalize decoded values.
+type avroNode struct {
+	typ      string // "record", "array", or a primitive type
+	logical  string
+	nullable bool
+	fields   map[string]*avroNode
+	items    *avroNode
+}
+
+func newAvroDecoder(schema string) (*avroDecoder, error) {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create codec: %w", err)
+	}
+	root, err := parseAvroNode(json.RawMessage(schema))
+	if err != nil {
+		return nil, fmt.Errorf("parsing Avro schema: %w", err)
+	}
+	return &avroDecoder{codec: codec, root: root}, nil
+}
+
+func parseAvroNode(raw json.RawMessage) (*avroNode, error) {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return &avroNode{typ: name}, nil
+	}
+	var union []json.RawMessage
+	if json.Unmarshal(raw, &union) == nil {
+		// BigQuery only uses unions of null and one other type.
+		var n *avroNode
+		for _, u := range union {
+			var s string
+			if json.Unmarshal(u, &s) == nil && s == "null" {
+				continue
+			}
+			var err error
+			if n, err = parseAvroNode(u); err != nil {
+				return nil, err
+			}
+		}
+		if n == nil || len(union) != 2 {
+			return nil, fmt.Errorf("unsupported union %s", raw)
+		}
+		n.nullable = true
+		return n, nil
+	}
+	var obj struct {
+		Type        json.RawMessage
+		LogicalType string
+		Items       json.RawMessage
+		Fields      []struct {
+			Name string
+			Type json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	n, err := parseAvroNode(obj.Type)
+	if err != nil {
+		return nil, err
+	}
+	n.logical = obj.LogicalType
+	switch n.typ {
+	case "record":
+		n.fields = make(map[string]*avroNode, len(obj.Fields))
+		for _, f := range obj.Fields {
+			if n.fields[f.Name], err = parseAvroNode(f.Type); err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		}
+	case "array":
+		if n.items, err = parseAvroNode(obj.Items); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (d *avroDecoder) rows(resp *storagepb.ReadRowsResponse) ([]map[string]any, error) {
+	undecoded := resp.GetAvroRows().GetSerializedBinaryRows()
+	var rows []map[string]any
+	for len(undecoded) > 0 {
+		datum, rest, err := d.codec.NativeFromBinary(undecoded)
+		if err != nil {
+			return nil, fmt.Errorf("decoding error with %d bytes remaining: %w", len(undecoded), err)
+		}
+		v, err := d.root.normalize(datum)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, v.(map[string]any))
+		undecoded = rest
+	}
+	return rows, nil
+}
+
+// normalize converts a value decoded by goavro to the types arrowValue
+// returns.
+func (n *avroNode) normalize(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if n.nullable {
+		m, ok := v.(map[string]any)
+		if !ok || len(m) != 1 {
+			return nil, fmt.Errorf("unexpected union value %T", v)
+		}
+		for _, inner := range m {
+			v = inner
+		}
+	}
+	switch n.typ {
+	case "record":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("record is %T", v)
+		}
+		out := make(map[string]any, len(m))
+		for name, fv := range m {
+			fn, ok := n.fields[name]
+			if !ok {
+				return nil, fmt.Errorf("unexpected field %s", name)
+			}
+			nv, err := fn.normalize(fv)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			out[name] = nv
+		}
+		return out, nil
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("array is %T", v)
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			var err error
+			if out[i], err = n.items.normalize(item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	switch x := v.(type) {
+	case time.Time:
+		if n.logical == "date" {
+			return civil.DateOf(x.UTC()), nil
+		}
+		return x.UTC(), nil
+	case int32:
+		return int64(x), nil
+	case float32:
+		return float64(x), nil
+	}
+	return v, nil
+}
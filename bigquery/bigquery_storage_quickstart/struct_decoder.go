@@ -0,0 +1,243 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// structDecoder assigns rows to values of a struct type.
+type structDecoder struct {
+	typ    reflect.Type
+	fields map[string]structField // keyed by lower-case column name
+}
+
+type structField struct {
+	index []int
+	// nested decodes RECORD columns into struct fields, or elements of
+	// slices of structs.
+	nested *structDecoder
+}
+
+var (
+	structDecodersMu sync.Mutex
+	structDecoders   = map[reflect.Type]*structDecoder{}
+)
+
+// newStructDecoder returns a decoder for the struct type of v.
+func newStructDecoder(v any) (*structDecoder, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("rows can only be decoded into structs, not %v", t)
+	}
+	structDecodersMu.Lock()
+	defer structDecodersMu.Unlock()
+	return structDecoderFor(t)
+}
+
+// structDecoderFor returns the decoder for t, building it if needed.
+// Decoders are cached before their fields are added so recursive types
+// terminate. structDecodersMu must be held.
+func structDecoderFor(t reflect.Type) (*structDecoder, error) {
+	if d, ok := structDecoders[t]; ok {
+		return d, nil
+	}
+	d := &structDecoder{typ: t, fields: map[string]structField{}}
+	structDecoders[t] = d
+	if err := d.addFields(t, nil); err != nil {
+		delete(structDecoders, t)
+		return nil, err
+	}
+	return d, nil
+}
+
+// addFields maps the exported fields of t, including those of embedded
+// structs, to column names.
+func (d *structDecoder) addFields(t reflect.Type, index []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("bigquery"), ",")
+		if name == "-" {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if err := d.addFields(f.Type, idx); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		sf := structField{index: idx}
+		if et := elemType(f.Type); et.Kind() == reflect.Struct && !isScalarStruct(et) {
+			nested, err := structDecoderFor(et)
+			if err != nil {
+				return err
+			}
+			sf.nested = nested
+		}
+		d.fields[strings.ToLower(name)] = sf
+	}
+	return nil
+}
+
+// elemType strips pointers and slices from t.
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+var (
+	typeOfTime = reflect.TypeOf(time.Time{})
+	typeOfDate = reflect.TypeOf(civil.Date{})
+	typeOfRat  = reflect.TypeOf(big.Rat{})
+)
+
+// isScalarStruct reports whether t is a struct type that holds a single
+// column value.
+func isScalarStruct(t reflect.Type) bool {
+	return t == typeOfTime || t == typeOfDate || t == typeOfRat
+}
+
+// decode assigns row to the struct dst points to.
+func (d *structDecoder) decode(dst any, row map[string]any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Type() != d.typ {
+		return fmt.Errorf("decode needs a *%v, not %T", d.typ, dst)
+	}
+	return d.assignStruct(v.Elem(), row)
+}
+
+func (d *structDecoder) assignStruct(dst reflect.Value, row map[string]any) error {
+	for col, val := range row {
+		sf, ok := d.fields[strings.ToLower(col)]
+		if !ok {
+			continue
+		}
+		if err := assign(dst.FieldByIndex(sf.index), val, sf.nested); err != nil {
+			return fmt.Errorf("column %s: %w", col, err)
+		}
+	}
+	return nil
+}
+
+// assign stores val, a value returned by a rowDecoder, in dst.
+func assign(dst reflect.Value, val any, nested *structDecoder) error {
+	if val == nil {
+		dst.SetZero()
+		return nil
+	}
+	if rv := reflect.ValueOf(val); rv.Type().AssignableTo(dst.Type()) {
+		dst.Set(rv)
+		return nil
+	}
+	if dst.Kind() == reflect.Pointer {
+		p := reflect.New(dst.Type().Elem())
+		if err := assign(p.Elem(), val, nested); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	}
+
+	switch v := val.(type) {
+	case map[string]any:
+		if nested == nil || dst.Type() != nested.typ {
+			return fmt.Errorf("cannot assign RECORD to %v", dst.Type())
+		}
+		return nested.assignStruct(dst, v)
+	case []any:
+		if dst.Kind() != reflect.Slice {
+			return fmt.Errorf("cannot assign REPEATED column to %v", dst.Type())
+		}
+		s := reflect.MakeSlice(dst.Type(), len(v), len(v))
+		for i, item := range v {
+			if err := assign(s.Index(i), item, nested); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+	case string:
+		if dst.Kind() == reflect.String {
+			dst.SetString(v)
+			return nil
+		}
+	case bool:
+		if dst.Kind() == reflect.Bool {
+			dst.SetBool(v)
+			return nil
+		}
+	case int64:
+		switch {
+		case dst.CanInt():
+			if dst.OverflowInt(v) {
+				return fmt.Errorf("%d overflows %v", v, dst.Type())
+			}
+			dst.SetInt(v)
+			return nil
+		case dst.CanUint():
+			if v < 0 || dst.OverflowUint(uint64(v)) {
+				return fmt.Errorf("%d overflows %v", v, dst.Type())
+			}
+			dst.SetUint(uint64(v))
+			return nil
+		case dst.CanFloat():
+			dst.SetFloat(float64(v))
+			return nil
+		}
+	case float64:
+		if dst.CanFloat() {
+			dst.SetFloat(v)
+			return nil
+		}
+	case civil.Date:
+		if dst.Type() == typeOfTime {
+			dst.Set(reflect.ValueOf(v.In(time.UTC)))
+			return nil
+		}
+	case *big.Rat:
+		switch {
+		case dst.Type() == typeOfRat:
+			dst.Set(reflect.ValueOf(v).Elem())
+			return nil
+		case dst.Kind() == reflect.String:
+			dst.SetString(v.FloatString(9))
+			return nil
+		case dst.CanFloat():
+			f, _ := v.Float64()
+			dst.SetFloat(f)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot assign %T to %v", val, dst.Type())
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+type testBase struct {
+	ID int64 `bigquery:"id"`
+}
+
+type testTree struct {
+	testBase
+	Label    string
+	Children []*testTree
+	Raw      any
+	Small    int8
+	Count    uint32
+	Ratio    float32
+	Day      time.Time
+	hidden   string
+}
+
+func TestStructDecoder(t *testing.T) {
+	sd, err := newStructDecoder(testTree{})
+	if err != nil {
+		t.Fatalf("newStructDecoder: %v", err)
+	}
+	row := map[string]any{
+		"ID":    int64(1),
+		"label": "root",
+		"children": []any{
+			map[string]any{"id": int64(2), "label": "leaf", "children": []any{}},
+			nil,
+		},
+		"raw":     []any{"kept", int64(1)},
+		"small":   int64(-8),
+		"count":   int64(7),
+		"ratio":   0.5,
+		"day":     civil.Date{Year: 2026, Month: 2, Day: 3},
+		"hidden":  "ignored",
+		"unknown": "ignored",
+	}
+	var got testTree
+	if err := sd.decode(&got, row); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := testTree{
+		testBase: testBase{ID: 1},
+		Label:    "root",
+		Children: []*testTree{{testBase: testBase{ID: 2}, Label: "leaf", Children: []*testTree{}}, nil},
+		Raw:      []any{"kept", int64(1)},
+		Small:    -8,
+		Count:    7,
+		Ratio:    0.5,
+		Day:      time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decode =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// NULL resets fields, so decoders can reuse values.
+	if err := sd.decode(&got, map[string]any{"label": nil, "children": nil}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Label != "" || got.Children != nil {
+		t.Errorf("NULL columns left Label=%q Children=%v", got.Label, got.Children)
+	}
+
+	for _, bad := range []map[string]any{
+		{"small": int64(math.MaxInt8 + 1)},
+		{"count": int64(-1)},
+		{"label": int64(1)},
+		{"children": "not a list"},
+		{"children": []any{"not a record"}},
+		{"day": "2026-02-03"},
+	} {
+		var v testTree
+		if err := sd.decode(&v, bad); err == nil {
+			t.Errorf("decode(%v) succeeded, want error", bad)
+		}
+	}
+
+	if err := sd.decode(testTree{}, row); err == nil {
+		t.Errorf("decode into a non-pointer succeeded")
+	}
+	if _, err := newStructDecoder(map[string]any{}); err == nil {
+		t.Errorf("newStructDecoder(map) succeeded")
+	}
+}
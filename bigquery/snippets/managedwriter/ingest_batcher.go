@@ -0,0 +1,77 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+const (
+	// defaultBatchRows is the number of rows sent in a single append.
+	defaultBatchRows = 500
+	// defaultBatchBytes keeps appends comfortably below the 10 MB
+	// AppendRows request limit, leaving room for the descriptor and
+	// request metadata.
+	defaultBatchBytes = 8 << 20
+)
+
+// rowBatcher groups serialized rows into appends bounded by a row count and
+// an encoded size.
+type rowBatcher struct {
+	maxRows  int
+	maxBytes int
+
+	rows [][]byte
+	size int
+}
+
+func newRowBatcher(maxRows, maxBytes int) *rowBatcher {
+	if maxRows <= 0 {
+		maxRows = defaultBatchRows
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultBatchBytes
+	}
+	return &rowBatcher{maxRows: maxRows, maxBytes: maxBytes}
+}
+
+// rowSize is the number of bytes row adds to the serialized_rows field of
+// an append request.
+func rowSize(row []byte) int {
+	return protowire.SizeTag(1) + protowire.SizeBytes(len(row))
+}
+
+// add queues row. If row does not fit in the current batch, add returns the
+// full batch and starts a new one with row. A single row larger than the
+// byte limit is still batched on its own; the service rejects it.
+func (b *rowBatcher) add(row []byte) [][]byte {
+	var full [][]byte
+	n := rowSize(row)
+	if len(b.rows) > 0 && (len(b.rows) >= b.maxRows || b.size+n > b.maxBytes) {
+		full = b.take()
+	}
+	b.rows = append(b.rows, row)
+	b.size += n
+	return full
+}
+
+// take returns the queued rows, or nil if there are none, and empties the
+// batch.
+func (b *rowBatcher) take() [][]byte {
+	rows := b.rows
+	b.rows, b.size = nil, 0
+	return rows
+}
+
+// len reports the number of queued rows.
+func (b *rowBatcher) len() int { return len(b.rows) }
@@ -0,0 +1,86 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRowBatcher(t *testing.T) {
+	row := func(n int) []byte { return bytes.Repeat([]byte{'x'}, n) }
+	sizes := func(batch [][]byte) []int {
+		var s []int
+		for _, r := range batch {
+			s = append(s, len(r))
+		}
+		return s
+	}
+
+	t.Run("Rows", func(t *testing.T) {
+		b := newRowBatcher(3, 0)
+		var batches [][]int
+		for i := 1; i <= 7; i++ {
+			if full := b.add(row(i)); full != nil {
+				batches = append(batches, sizes(full))
+			}
+		}
+		batches = append(batches, sizes(b.take()))
+		want := [][]int{{1, 2, 3}, {4, 5, 6}, {7}}
+		if !reflect.DeepEqual(batches, want) {
+			t.Errorf("batches = %v, want %v", batches, want)
+		}
+		if b.take() != nil || b.len() != 0 {
+			t.Errorf("take left %d rows in the batcher", b.len())
+		}
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		// Each 100-byte row takes 102 bytes in the request.
+		b := newRowBatcher(100, 250)
+		var batches [][]int
+		for _, n := range []int{100, 100, 100, 10, 300, 100} {
+			if full := b.add(row(n)); full != nil {
+				batches = append(batches, sizes(full))
+			}
+		}
+		batches = append(batches, sizes(b.take()))
+		want := [][]int{{100, 100}, {100, 10}, {300}, {100}}
+		if !reflect.DeepEqual(batches, want) {
+			t.Errorf("batches = %v, want %v", batches, want)
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		b := newRowBatcher(0, -1)
+		if b.maxRows != defaultBatchRows || b.maxBytes != defaultBatchBytes {
+			t.Errorf("newRowBatcher(0, -1) limits = %d rows, %d bytes; want defaults", b.maxRows, b.maxBytes)
+		}
+	})
+}
+
+func TestRowSize(t *testing.T) {
+	for _, tc := range []struct{ n, want int }{
+		{0, 2},
+		{127, 129},
+		{128, 131},
+		{1 << 20, 1<<20 + 4},
+	} {
+		if got := rowSize(make([]byte, tc.n)); got != tc.want {
+			t.Errorf("rowSize(%d bytes) = %d, want %d", tc.n, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"cloud.google.com/go/civil"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// A rowEncoder serializes rows for a single message descriptor.
+type rowEncoder interface {
+	// descriptor returns the normalized descriptor sent with appends.
+	descriptor() *descriptorpb.DescriptorProto
+	// encode returns the serialized protocol buffer form of row.
+	encode(row any) ([]byte, error)
+}
+
+// unknownColumnError is returned when a row has a column that is not in the
+// schema it is encoded against. The table may have gained the column since
+// the encoder was built.
+type unknownColumnError struct {
+	column string
+}
+
+func (e *unknownColumnError) Error() string {
+	return fmt.Sprintf("column %q is not in the table schema", e.column)
+}
+
+// structDescriptor infers a BigQuery schema from a Go struct, using the same
+// rules and `bigquery` field tags as bigquery.InferSchema, and returns the
+// normalized descriptor for it.
+func structDescriptor(st any) (*descriptorpb.DescriptorProto, error) {
+	schema, err := bigquery.InferSchema(st)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := newSchemaEncoder(schema)
+	if err != nil {
+		return nil, err
+	}
+	return enc.descriptor(), nil
+}
+
+// schemaEncoder encodes rows against a BigQuery table schema. Rows can be
+// Go structs (or pointers to them), map[string]bigquery.Value, or
+// bigquery.ValueSaver implementations.
+//
+// The descriptor it builds uses the string representation for NUMERIC,
+// BIGNUMERIC, DATETIME and TIME columns, which the Storage Write API accepts
+// in place of the packed encodings used by adapt.StorageSchemaToProto2Descriptor.
+type schemaEncoder struct {
+	schema     bigquery.Schema
+	md         protoreflect.MessageDescriptor
+	normalized *descriptorpb.DescriptorProto
+}
+
+func newSchemaEncoder(schema bigquery.Schema) (*schemaEncoder, error) {
+	root, err := schemaMessage("Row", schema)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("ingest_row.proto"),
+		Syntax:      proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{root},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building descriptor: %w", err)
+	}
+	md := fd.Messages().Get(0)
+	normalized, err := adapt.NormalizeDescriptor(md)
+	if err != nil {
+		return nil, fmt.Errorf("NormalizeDescriptor: %w", err)
+	}
+	return &schemaEncoder{schema: schema, md: md, normalized: normalized}, nil
+}
+
+// schemaMessage returns a message for schema, with RECORD columns as nested
+// messages named after their column.
+func schemaMessage(name string, schema bigquery.Schema) (*descriptorpb.DescriptorProto, error) {
+	dp := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	for i, fs := range schema {
+		if !protoreflect.Name(fs.Name).IsValid() {
+			return nil, fmt.Errorf("column %q is not a valid protocol buffer field name", fs.Name)
+		}
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(fs.Name),
+			Number: proto.Int32(int32(i + 1)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		switch {
+		case fs.Repeated:
+			f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		case fs.Required:
+			f.Label = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED.Enum()
+		}
+		switch fs.Type {
+		case bigquery.RecordFieldType:
+			nested, err := schemaMessage("Record_"+fs.Name, fs.Schema)
+			if err != nil {
+				return nil, err
+			}
+			dp.NestedType = append(dp.NestedType, nested)
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			f.TypeName = proto.String(nested.GetName())
+		case bigquery.StringFieldType, bigquery.GeographyFieldType, bigquery.JSONFieldType,
+			bigquery.NumericFieldType, bigquery.BigNumericFieldType,
+			bigquery.DateTimeFieldType, bigquery.TimeFieldType, bigquery.IntervalFieldType:
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+		case bigquery.BytesFieldType:
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum()
+		case bigquery.IntegerFieldType, bigquery.TimestampFieldType:
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()
+		case bigquery.DateFieldType:
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum()
+		case bigquery.FloatFieldType:
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum()
+		case bigquery.BooleanFieldType:
+			f.Type = descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum()
+		default:
+			return nil, fmt.Errorf("column %q: unsupported type %s", fs.Name, fs.Type)
+		}
+		dp.Field = append(dp.Field, f)
+	}
+	return dp, nil
+}
+
+func (e *schemaEncoder) descriptor() *descriptorpb.DescriptorProto { return e.normalized }
+
+func (e *schemaEncoder) encode(row any) ([]byte, error) {
+	var values map[string]bigquery.Value
+	switch r := row.(type) {
+	case map[string]bigquery.Value:
+		values = r
+	case bigquery.ValueSaver:
+		var err error
+		if values, _, err = r.Save(); err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		ss := &bigquery.StructSaver{Schema: e.schema, Struct: row}
+		if values, _, err = ss.Save(); err != nil {
+			return nil, err
+		}
+	}
+	m, err := e.message(e.md, e.schema, values)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
+}
+
+func (e *schemaEncoder) message(md protoreflect.MessageDescriptor, schema bigquery.Schema, values map[string]bigquery.Value) (*dynamicpb.Message, error) {
+	m := dynamicpb.NewMessage(md)
+	fields := md.Fields()
+	for name, v := range values {
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil, &unknownColumnError{column: name}
+		}
+		fs := schema[fd.Number()-1]
+		if err := e.set(m, fd, fs, v); err != nil {
+			return nil, fmt.Errorf("column %q: %w", name, err)
+		}
+	}
+	return m, nil
+}
+
+// set stores v in field fd of m, converting it to the wire representation
+// for the column's type. Nil values leave the field unset.
+func (e *schemaEncoder) set(m *dynamicpb.Message, fd protoreflect.FieldDescriptor, fs *bigquery.FieldSchema, v bigquery.Value) error {
+	v = unwrapNull(v)
+	if v == nil {
+		return nil
+	}
+	if !fd.IsList() {
+		pv, err := e.value(m, fd, fs, v)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, pv)
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("repeated column needs a slice, got %T", v)
+	}
+	list := m.Mutable(fd).List()
+	for i := 0; i < rv.Len(); i++ {
+		pv, err := e.value(m, fd, fs, rv.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+		list.Append(pv)
+	}
+	return nil
+}
+
+func (e *schemaEncoder) value(m *dynamicpb.Message, fd protoreflect.FieldDescriptor, fs *bigquery.FieldSchema, v bigquery.Value) (protoreflect.Value, error) {
+	v = unwrapNull(v)
+	switch fs.Type {
+	case bigquery.RecordFieldType:
+		values, ok := v.(map[string]bigquery.Value)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("RECORD needs map[string]bigquery.Value, got %T", v)
+		}
+		nested, err := e.message(fd.Message(), fs.Schema, values)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfMessage(nested), nil
+
+	case bigquery.TimestampFieldType:
+		if t, ok := v.(time.Time); ok {
+			return protoreflect.ValueOfInt64(t.UnixMicro()), nil
+		}
+	case bigquery.DateFieldType:
+		if d, ok := v.(civil.Date); ok {
+			days := d.In(time.UTC).Unix() / (24 * 60 * 60)
+			return protoreflect.ValueOfInt32(int32(days)), nil
+		}
+	case bigquery.DateTimeFieldType:
+		if dt, ok := v.(civil.DateTime); ok {
+			return protoreflect.ValueOfString(bigquery.CivilDateTimeString(dt)), nil
+		}
+	case bigquery.TimeFieldType:
+		if t, ok := v.(civil.Time); ok {
+			return protoreflect.ValueOfString(bigquery.CivilTimeString(t)), nil
+		}
+	case bigquery.NumericFieldType:
+		if r, ok := v.(*big.Rat); ok {
+			return protoreflect.ValueOfString(bigquery.NumericString(r)), nil
+		}
+	case bigquery.BigNumericFieldType:
+		if r, ok := v.(*big.Rat); ok {
+			return protoreflect.ValueOfString(bigquery.BigNumericString(r)), nil
+		}
+	case bigquery.JSONFieldType:
+		if b, ok := v.([]byte); ok {
+			return protoreflect.ValueOfString(string(b)), nil
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if rv.Kind() == reflect.String {
+			return protoreflect.ValueOfString(rv.String()), nil
+		}
+	case protoreflect.BytesKind:
+		if b, ok := v.([]byte); ok {
+			return protoreflect.ValueOfBytes(b), nil
+		}
+	case protoreflect.BoolKind:
+		if rv.Kind() == reflect.Bool {
+			return protoreflect.ValueOfBool(rv.Bool()), nil
+		}
+	case protoreflect.DoubleKind:
+		switch {
+		case rv.CanFloat():
+			return protoreflect.ValueOfFloat64(rv.Float()), nil
+		case rv.CanInt():
+			return protoreflect.ValueOfFloat64(float64(rv.Int())), nil
+		}
+	case protoreflect.Int64Kind:
+		switch {
+		case rv.CanInt():
+			return protoreflect.ValueOfInt64(rv.Int()), nil
+		case rv.CanUint() && rv.Uint() <= 1<<63-1:
+			return protoreflect.ValueOfInt64(int64(rv.Uint())), nil
+		}
+	case protoreflect.Int32Kind:
+		if rv.CanInt() && int64(int32(rv.Int())) == rv.Int() {
+			return protoreflect.ValueOfInt32(int32(rv.Int())), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot use %T as %s", v, fs.Type)
+}
+
+// unwrapNull returns the value held by one of the bigquery.Null types, or
+// nil if it is not valid. Other values are returned unchanged.
+func unwrapNull(v bigquery.Value) bigquery.Value {
+	switch n := v.(type) {
+	case bigquery.NullString:
+		return nullValue(n.Valid, n.StringVal)
+	case bigquery.NullInt64:
+		return nullValue(n.Valid, n.Int64)
+	case bigquery.NullFloat64:
+		return nullValue(n.Valid, n.Float64)
+	case bigquery.NullBool:
+		return nullValue(n.Valid, n.Bool)
+	case bigquery.NullTimestamp:
+		return nullValue(n.Valid, n.Timestamp)
+	case bigquery.NullDate:
+		return nullValue(n.Valid, n.Date)
+	case bigquery.NullTime:
+		return nullValue(n.Valid, n.Time)
+	case bigquery.NullDateTime:
+		return nullValue(n.Valid, n.DateTime)
+	case bigquery.NullGeography:
+		return nullValue(n.Valid, n.GeographyVal)
+	case bigquery.NullJSON:
+		return nullValue(n.Valid, n.JSONVal)
+	}
+	return v
+}
+
+func nullValue(valid bool, v bigquery.Value) bigquery.Value {
+	if !valid {
+		return nil
+	}
+	return v
+}
+
+// messageEncoder encodes rows that are already protocol buffer messages of a
+// single type, such as generated messages or dynamicpb.Message values.
+type messageEncoder struct {
+	name       protoreflect.FullName
+	normalized *descriptorpb.DescriptorProto
+}
+
+func newMessageEncoder(md protoreflect.MessageDescriptor) (*messageEncoder, error) {
+	normalized, err := adapt.NormalizeDescriptor(md)
+	if err != nil {
+		return nil, fmt.Errorf("NormalizeDescriptor: %w", err)
+	}
+	return &messageEncoder{name: md.FullName(), normalized: normalized}, nil
+}
+
+func (e *messageEncoder) descriptor() *descriptorpb.DescriptorProto { return e.normalized }
+
+func (e *messageEncoder) encode(row any) ([]byte, error) {
+	m, ok := row.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("row is %T, not a proto.Message", row)
+	}
+	if got := m.ProtoReflect().Descriptor().FullName(); got != e.name {
+		return nil, fmt.Errorf("row is a %s message, want %s", got, e.name)
+	}
+	return proto.Marshal(m)
+}
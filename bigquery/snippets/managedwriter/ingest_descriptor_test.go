@@ -0,0 +1,285 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/GoogleCloudPlatform/golang-samples/bigquery/snippets/managedwriter/exampleproto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type testItem struct {
+	SKU      string
+	Quantity int64
+}
+
+type testOrder struct {
+	ID       int64 `bigquery:"id"`
+	Customer bigquery.NullString
+	Placed   time.Time
+	Shipped  civil.Date
+	Pickup   civil.Time
+	Local    civil.DateTime
+	Total    *big.Rat `bigquery:",nullable"`
+	Paid     bool
+	Weight   float64
+	Tags     []string
+	Items    []testItem
+	Address  testAddress
+	Skipped  string `bigquery:"-"`
+}
+
+type testAddress struct {
+	City string
+	Zip  []byte
+}
+
+// messageDescriptor resolves a normalized descriptor so rows encoded with it
+// can be decoded.
+func messageDescriptor(t *testing.T, dp *descriptorpb.DescriptorProto) protoreflect.MessageDescriptor {
+	t.Helper()
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("test.proto"),
+		Syntax:      proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{dp},
+	}, nil)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile: %v", err)
+	}
+	return fd.Messages().Get(0)
+}
+
+func TestStructDescriptor(t *testing.T) {
+	dp, err := structDescriptor(testOrder{})
+	if err != nil {
+		t.Fatalf("structDescriptor: %v", err)
+	}
+	md := messageDescriptor(t, dp)
+	if n := len(dp.GetNestedType()); n != 2 {
+		t.Errorf("normalized descriptor has %d nested types, want Items and Address", n)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		kind  protoreflect.Kind
+		label protoreflect.Cardinality
+	}{
+		{"id", protoreflect.Int64Kind, protoreflect.Required},
+		{"Customer", protoreflect.StringKind, protoreflect.Optional},
+		{"Placed", protoreflect.Int64Kind, protoreflect.Required},
+		{"Shipped", protoreflect.Int32Kind, protoreflect.Required},
+		{"Pickup", protoreflect.StringKind, protoreflect.Required},
+		{"Local", protoreflect.StringKind, protoreflect.Required},
+		{"Total", protoreflect.StringKind, protoreflect.Optional},
+		{"Paid", protoreflect.BoolKind, protoreflect.Required},
+		{"Weight", protoreflect.DoubleKind, protoreflect.Required},
+		{"Tags", protoreflect.StringKind, protoreflect.Repeated},
+		{"Items", protoreflect.MessageKind, protoreflect.Repeated},
+		{"Address", protoreflect.MessageKind, protoreflect.Required},
+	} {
+		fd := md.Fields().ByName(protoreflect.Name(tc.name))
+		if fd == nil {
+			t.Errorf("field %s missing", tc.name)
+			continue
+		}
+		if fd.Kind() != tc.kind || fd.Cardinality() != tc.label {
+			t.Errorf("field %s is %v %v, want %v %v", tc.name, fd.Cardinality(), fd.Kind(), tc.label, tc.kind)
+		}
+	}
+	if md.Fields().ByName("Skipped") != nil {
+		t.Errorf("field Skipped is in the descriptor")
+	}
+	if fd := md.Fields().ByName("Items").Message().Fields().ByName("Quantity"); fd == nil || fd.Kind() != protoreflect.Int64Kind {
+		t.Errorf("Items.Quantity = %v, want int64", fd)
+	}
+}
+
+func TestSchemaDescriptorErrors(t *testing.T) {
+	for _, schema := range []bigquery.Schema{
+		{{Name: "has-dash", Type: bigquery.StringFieldType}},
+		{{Name: "r", Type: bigquery.RangeFieldType}},
+		{{Name: "rec", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "1st", Type: bigquery.StringFieldType},
+		}}},
+	} {
+		if _, err := newSchemaEncoder(schema); err == nil {
+			t.Errorf("newSchemaEncoder(%v) succeeded, want error", schema[0].Name)
+		}
+	}
+}
+
+func TestSchemaEncoder(t *testing.T) {
+	schema, err := bigquery.InferSchema(testOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := newSchemaEncoder(schema)
+	if err != nil {
+		t.Fatalf("newSchemaEncoder: %v", err)
+	}
+	md := messageDescriptor(t, enc.descriptor())
+
+	placed := time.Date(2026, 3, 4, 5, 6, 7, 8000, time.UTC)
+	want := `{
+		"id": "7",
+		"Customer": "ada",
+		"Placed": "` + strconv.FormatInt(placed.UnixMicro(), 10) + `",
+		"Shipped": 20516,
+		"Pickup": "09:30:00",
+		"Local": "2026-03-04 05:06:07",
+		"Total": "12.500000000",
+		"Paid": true,
+		"Weight": 1.5,
+		"Tags": ["a", "b"],
+		"Items": [{"SKU": "x", "Quantity": "2"}, {"SKU": "y", "Quantity": "3"}],
+		"Address": {"City": "Paris", "Zip": "NzUwMDE="}
+	}`
+
+	for _, tc := range []struct {
+		name string
+		row  any
+	}{
+		{"struct", testOrder{
+			ID:       7,
+			Customer: bigquery.NullString{StringVal: "ada", Valid: true},
+			Placed:   placed,
+			Shipped:  civil.Date{Year: 2026, Month: 3, Day: 4},
+			Pickup:   civil.Time{Hour: 9, Minute: 30},
+			Local:    civil.DateTimeOf(placed.Truncate(time.Second)),
+			Total:    big.NewRat(25, 2),
+			Paid:     true,
+			Weight:   1.5,
+			Tags:     []string{"a", "b"},
+			Items:    []testItem{{"x", 2}, {"y", 3}},
+			Address:  testAddress{City: "Paris", Zip: []byte("75001")},
+			Skipped:  "ignored",
+		}},
+		{"map", map[string]bigquery.Value{
+			"id":       7,
+			"Customer": "ada",
+			"Placed":   placed,
+			"Shipped":  civil.Date{Year: 2026, Month: 3, Day: 4},
+			"Pickup":   "09:30:00",
+			"Local":    civil.DateTimeOf(placed.Truncate(time.Second)),
+			"Total":    "12.500000000",
+			"Paid":     true,
+			"Weight":   1.5,
+			"Tags":     []bigquery.Value{"a", "b"},
+			"Items": []bigquery.Value{
+				map[string]bigquery.Value{"SKU": "x", "Quantity": int32(2)},
+				map[string]bigquery.Value{"SKU": "y", "Quantity": uint8(3)},
+			},
+			"Address": map[string]bigquery.Value{"City": "Paris", "Zip": []byte("75001")},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := enc.encode(tc.row)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			got := dynamicpb.NewMessage(md)
+			if err := proto.Unmarshal(b, got); err != nil {
+				t.Fatalf("proto.Unmarshal: %v", err)
+			}
+			wantMsg := dynamicpb.NewMessage(md)
+			if err := protojson.Unmarshal([]byte(want), wantMsg); err != nil {
+				t.Fatalf("protojson.Unmarshal: %v", err)
+			}
+			if !proto.Equal(got, wantMsg) {
+				t.Errorf("encoded row:\n%v\nwant:\n%v", got, wantMsg)
+			}
+		})
+	}
+}
+
+func TestSchemaEncoderErrors(t *testing.T) {
+	enc, err := newSchemaEncoder(bigquery.Schema{
+		{Name: "id", Type: bigquery.IntegerFieldType, Required: true},
+		{Name: "day", Type: bigquery.DateFieldType},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = enc.encode(map[string]bigquery.Value{"id": 1, "region": "eu"})
+	var unknown *unknownColumnError
+	if !errors.As(err, &unknown) || unknown.column != "region" {
+		t.Errorf("encode with unknown column: got %v, want unknownColumnError for region", err)
+	}
+
+	for _, row := range []map[string]bigquery.Value{
+		{"day": civil.Date{Year: 2026, Month: 1, Day: 1}},
+		{"id": "one"},
+		{"id": 1, "day": "2026-01-01"},
+		{"id": 1, "tags": "a"},
+	} {
+		if _, err := enc.encode(row); err == nil {
+			t.Errorf("encode(%v) succeeded, want error", row)
+		}
+	}
+
+	if _, err := enc.encode(map[string]bigquery.Value{"id": 1, "day": bigquery.NullDate{}}); err != nil {
+		t.Errorf("encode with NULL date: %v", err)
+	}
+}
+
+func TestMessageEncoder(t *testing.T) {
+	enc, err := newMessageEncoder((&exampleproto.SampleData{}).ProtoReflect().Descriptor())
+	if err != nil {
+		t.Fatalf("newMessageEncoder: %v", err)
+	}
+	if n := len(enc.descriptor().GetNestedType()); n != 1 {
+		t.Errorf("normalized descriptor has %d nested types, want SampleStruct only", n)
+	}
+
+	row := &exampleproto.SampleData{RowNum: proto.Int64(1), StringCol: proto.String("a")}
+	b, err := enc.encode(row)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got := &exampleproto.SampleData{}
+	if err := proto.Unmarshal(b, got); err != nil || !proto.Equal(got, row) {
+		t.Errorf("encode round trip = %v, %v; want %v", got, err, row)
+	}
+
+	// A dynamic message with the same descriptor is accepted too.
+	dyn := dynamicpb.NewMessage(row.ProtoReflect().Descriptor())
+	if _, err := enc.encode(dyn); err == nil {
+		t.Errorf("encode of a message without the required row_num succeeded")
+	}
+	dyn.Set(dyn.Descriptor().Fields().ByName("row_num"), protoreflect.ValueOfInt64(2))
+	if _, err := enc.encode(dyn); err != nil {
+		t.Errorf("encode of a dynamic message: %v", err)
+	}
+	if _, err := enc.encode(&exampleproto.SampleStruct{}); err == nil {
+		t.Errorf("encode of another message type succeeded")
+	}
+	if _, err := enc.encode(map[string]bigquery.Value{}); err == nil {
+		t.Errorf("encode of a map succeeded")
+	}
+}
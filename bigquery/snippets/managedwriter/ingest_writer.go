@@ -0,0 +1,498 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	gax "github.com/googleapis/gax-go/v2"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ingestOptions configures an ingestWriter. The zero value is usable.
+type ingestOptions struct {
+	// BatchRows and BatchBytes bound the rows sent in one append.
+	BatchRows  int
+	BatchBytes int
+	// MaxInflight is the number of appends sent before waiting for the
+	// oldest to be acknowledged. Defaults to 8.
+	MaxInflight int
+	// MaxAttempts is the number of times an append is sent before its
+	// error is returned. Defaults to 5.
+	MaxAttempts int
+	// SchemaWait is how long an append is retried while the service rejects
+	// it for columns just added to the table, which write streams pick up
+	// some time after the table is updated. Defaults to 10 minutes.
+	SchemaWait time.Duration
+
+	// Message, if set, makes the writer accept rows of this message type
+	// instead of encoding rows against the table schema. Schema changes
+	// are not applied to message rows.
+	Message proto.Message
+
+	// StreamName and Offset resume a committed stream created by an
+	// earlier writer from a checkpointed offset. Rows resent from the
+	// checkpoint that the stream already holds are skipped.
+	StreamName string
+	Offset     int64
+}
+
+// appendStream is the part of a managed stream used by ingestWriter.
+type appendStream interface {
+	// append sends rows at offset, encoded with the stream's descriptor.
+	append(ctx context.Context, rows [][]byte, offset int64) appendResult
+	finalize(ctx context.Context) (int64, error)
+	close() error
+}
+
+// appendResult is the outcome of one append.
+type appendResult interface {
+	// get waits for the append to be acknowledged. It returns the table
+	// schema if the service reports that it has changed.
+	get(ctx context.Context) (*storagepb.TableSchema, error)
+}
+
+// streamOpener opens the write stream using descriptor dp.
+type streamOpener func(ctx context.Context, dp *descriptorpb.DescriptorProto) (appendStream, error)
+
+// schemaFetcher returns the current table schema.
+type schemaFetcher func(ctx context.Context) (bigquery.Schema, error)
+
+// ingestWriter writes rows to a committed write stream with exactly-once
+// semantics.
+//
+// Every append carries an explicit offset, so an append that is retried
+// after a transient error is either applied once or rejected as already
+// written. Appends are pipelined up to MaxInflight; if one fails, the writer
+// waits for the rest and resends the unacknowledged ones in offset order.
+//
+// When the table schema changes, either because an append response carries
+// the new schema or because a row has a column the writer does not know
+// about, the writer sends the rows encoded so far and reopens the stream
+// with a descriptor for the new schema. Offsets continue across reopens.
+// Until the stream picks up the new columns, appends using them are
+// rejected with SCHEMA_MISMATCH_EXTRA_FIELDS; they are retried with backoff
+// for up to SchemaWait.
+//
+// After a method returns an error the writer should be closed; a new writer
+// can resume the stream from the last offset returned by Offset after a
+// successful Flush.
+type ingestWriter struct {
+	open    streamOpener
+	refresh schemaFetcher
+	opts    ingestOptions
+
+	enc     rowEncoder
+	stream  appendStream
+	batch   *rowBatcher
+	offset  int64
+	pending []*pendingAppend
+	// updated is a schema reported by an append response that has not been
+	// applied yet.
+	updated *storagepb.TableSchema
+}
+
+type pendingAppend struct {
+	offset int64
+	rows   [][]byte
+	res    appendResult
+}
+
+// newIngestWriter returns a writer for table. If opts.StreamName is empty it
+// creates a new committed stream.
+func newIngestWriter(ctx context.Context, client *managedwriter.Client, table *bigquery.Table, opts ingestOptions) (*ingestWriter, error) {
+	refresh := func(ctx context.Context) (bigquery.Schema, error) {
+		md, err := table.Metadata(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("table.Metadata: %w", err)
+		}
+		return md.Schema, nil
+	}
+
+	var enc rowEncoder
+	var err error
+	if opts.Message != nil {
+		enc, err = newMessageEncoder(opts.Message.ProtoReflect().Descriptor())
+	} else {
+		var schema bigquery.Schema
+		if schema, err = refresh(ctx); err != nil {
+			return nil, err
+		}
+		enc, err = newSchemaEncoder(schema)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	name := opts.StreamName
+	if name == "" {
+		ws, err := client.CreateWriteStream(ctx, &storagepb.CreateWriteStreamRequest{
+			Parent: managedwriter.TableParentFromParts(table.ProjectID, table.DatasetID, table.TableID),
+			WriteStream: &storagepb.WriteStream{
+				Type: storagepb.WriteStream_COMMITTED,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("CreateWriteStream: %w", err)
+		}
+		name = ws.GetName()
+	}
+	open := func(ctx context.Context, dp *descriptorpb.DescriptorProto) (appendStream, error) {
+		ms, err := client.NewManagedStream(ctx,
+			managedwriter.WithStreamName(name),
+			managedwriter.WithSchemaDescriptor(dp))
+		if err != nil {
+			return nil, fmt.Errorf("NewManagedStream: %w", err)
+		}
+		return &managedAppendStream{ms: ms}, nil
+	}
+	return startIngestWriter(ctx, open, refresh, enc, opts)
+}
+
+// startIngestWriter opens the stream and returns a writer using enc.
+func startIngestWriter(ctx context.Context, open streamOpener, refresh schemaFetcher, enc rowEncoder, opts ingestOptions) (*ingestWriter, error) {
+	if opts.MaxInflight <= 0 {
+		opts.MaxInflight = 8
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.SchemaWait <= 0 {
+		opts.SchemaWait = 10 * time.Minute
+	}
+	stream, err := open(ctx, enc.descriptor())
+	if err != nil {
+		return nil, err
+	}
+	return &ingestWriter{
+		open:    open,
+		refresh: refresh,
+		opts:    opts,
+		enc:     enc,
+		stream:  stream,
+		batch:   newRowBatcher(opts.BatchRows, opts.BatchBytes),
+		offset:  opts.Offset,
+	}, nil
+}
+
+// Offset returns the offset of the next row written. Every row before it has
+// been acknowledged once Flush returns, so it can be checkpointed and passed
+// back as ingestOptions.Offset.
+func (w *ingestWriter) Offset() int64 {
+	return w.offset + int64(w.batch.len())
+}
+
+// Write queues row, sending a batch if it is full.
+func (w *ingestWriter) Write(ctx context.Context, row any) error {
+	b, err := w.enc.encode(row)
+	var unknown *unknownColumnError
+	if errors.As(err, &unknown) {
+		if err := w.updateSchema(ctx); err != nil {
+			return err
+		}
+		b, err = w.enc.encode(row)
+	}
+	if err != nil {
+		return err
+	}
+	if full := w.batch.add(b); full != nil {
+		if err := w.send(ctx, full); err != nil {
+			return err
+		}
+	}
+	return w.applyUpdatedSchema(ctx)
+}
+
+// Flush sends queued rows and waits until every append is acknowledged.
+func (w *ingestWriter) Flush(ctx context.Context) error {
+	if err := w.flush(ctx); err != nil {
+		return err
+	}
+	return w.applyUpdatedSchema(ctx)
+}
+
+func (w *ingestWriter) flush(ctx context.Context) error {
+	if rows := w.batch.take(); rows != nil {
+		if err := w.send(ctx, rows); err != nil {
+			return err
+		}
+	}
+	return w.wait(ctx, len(w.pending))
+}
+
+// Close flushes the writer and finalizes the stream, which prevents further
+// appends. It returns the number of rows in the stream.
+func (w *ingestWriter) Close(ctx context.Context) (int64, error) {
+	defer w.stream.close()
+	if err := w.flush(ctx); err != nil {
+		return 0, err
+	}
+	n, err := w.stream.finalize(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Finalize: %w", err)
+	}
+	return n, nil
+}
+
+// send appends rows at the next offset, first waiting for the oldest append
+// if MaxInflight are outstanding.
+func (w *ingestWriter) send(ctx context.Context, rows [][]byte) error {
+	if len(w.pending) >= w.opts.MaxInflight {
+		if err := w.wait(ctx, 1); err != nil {
+			return err
+		}
+	}
+	p := &pendingAppend{offset: w.offset, rows: rows}
+	p.res = w.stream.append(ctx, rows, p.offset)
+	w.pending = append(w.pending, p)
+	w.offset += int64(len(rows))
+	return nil
+}
+
+// wait waits for the oldest n pending appends to be acknowledged.
+func (w *ingestWriter) wait(ctx context.Context, n int) error {
+	for ; n > 0; n-- {
+		p := w.pending[0]
+		schema, err := p.res.get(ctx)
+		if err != nil && !alreadyWritten(err) {
+			if !retryable(err) {
+				return fmt.Errorf("append at offset %d: %w", p.offset, err)
+			}
+			// recover leaves nothing pending.
+			return w.recover(ctx)
+		}
+		w.noteSchema(schema)
+		w.pending = w.pending[1:]
+	}
+	return nil
+}
+
+// recover resends every pending append, in offset order, after one failed
+// with a retryable error. Appends that reached the service before the
+// failure are reported as already written and skipped.
+func (w *ingestWriter) recover(ctx context.Context) error {
+	// Later appends were sent before the failure was seen; wait for them so
+	// that nothing is in flight while rows are resent.
+	failed := make([]bool, len(w.pending))
+	for i, p := range w.pending {
+		schema, err := p.res.get(ctx)
+		failed[i] = err != nil && !alreadyWritten(err)
+		w.noteSchema(schema)
+	}
+	bo := gax.Backoff{Initial: 100 * time.Millisecond, Max: 10 * time.Second, Multiplier: 2}
+	first := true
+	for i, p := range w.pending {
+		if !failed[i] {
+			continue
+		}
+		if err := w.retry(ctx, p, &bo, first); err != nil {
+			return err
+		}
+		first = false
+	}
+	w.pending = nil
+	return nil
+}
+
+// retry resends p until it is acknowledged, backing off between attempts.
+// The first attempt has already been made. Appends after the one that
+// failed only failed because of it, so they are resent without waiting.
+//
+// Rejections for columns the stream doesn't know yet don't count against
+// MaxAttempts; they are retried until SchemaWait has passed.
+func (w *ingestWriter) retry(ctx context.Context, p *pendingAppend, bo *gax.Backoff, backoff bool) error {
+	start := time.Now()
+	attempts := 1
+	for first := true; ; first = false {
+		if backoff || !first {
+			if err := gax.Sleep(ctx, bo.Pause()); err != nil {
+				return err
+			}
+		}
+		schema, err := w.stream.append(ctx, p.rows, p.offset).get(ctx)
+		if err == nil || alreadyWritten(err) {
+			w.noteSchema(schema)
+			return nil
+		}
+		if schemaPending(err) {
+			if time.Since(start) < w.opts.SchemaWait {
+				continue
+			}
+			return fmt.Errorf("append at offset %d: new columns not picked up after %v: %w", p.offset, w.opts.SchemaWait, err)
+		}
+		attempts++
+		if !retryable(err) || attempts >= w.opts.MaxAttempts {
+			return fmt.Errorf("append at offset %d after %d attempts: %w", p.offset, attempts, err)
+		}
+	}
+}
+
+func (w *ingestWriter) noteSchema(schema *storagepb.TableSchema) {
+	if schema != nil {
+		w.updated = schema
+	}
+}
+
+// applyUpdatedSchema switches to a schema reported by an append response.
+func (w *ingestWriter) applyUpdatedSchema(ctx context.Context) error {
+	for w.updated != nil {
+		ts := w.updated
+		w.updated = nil
+		schema, err := adapt.StorageTableSchemaToBQSchema(ts)
+		if err != nil {
+			return fmt.Errorf("StorageTableSchemaToBQSchema: %w", err)
+		}
+		if err := w.evolve(ctx, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateSchema switches to the current table schema. Outstanding appends
+// are acknowledged first, since their responses may already carry it;
+// otherwise the schema is fetched from the table. The stream may not have
+// picked up the new columns yet: appends using them are then retried, see
+// retry.
+func (w *ingestWriter) updateSchema(ctx context.Context) error {
+	enc := w.enc
+	if err := w.Flush(ctx); err != nil {
+		return err
+	}
+	if w.enc != enc {
+		return nil
+	}
+	schema, err := w.refresh(ctx)
+	if err != nil {
+		return err
+	}
+	return w.evolve(ctx, schema)
+}
+
+// evolve reopens the stream with a descriptor for schema, after sending
+// every row encoded with the current one. It does nothing if the descriptor
+// is unchanged or the writer encodes proto messages.
+func (w *ingestWriter) evolve(ctx context.Context, schema bigquery.Schema) error {
+	if _, ok := w.enc.(*schemaEncoder); !ok {
+		return nil
+	}
+	enc, err := newSchemaEncoder(schema)
+	if err != nil {
+		return err
+	}
+	if proto.Equal(enc.descriptor(), w.enc.descriptor()) {
+		return nil
+	}
+	if err := w.flush(ctx); err != nil {
+		return err
+	}
+	w.stream.close()
+	stream, err := w.open(ctx, enc.descriptor())
+	if err != nil {
+		return err
+	}
+	w.enc, w.stream = enc, stream
+	return nil
+}
+
+// alreadyWritten reports whether err means the rows at the append's offset
+// are already in the stream, as happens when an acknowledged append is
+// retried.
+func alreadyWritten(err error) bool {
+	if se := storageError(err); se != nil {
+		return se.GetCode() == storagepb.StorageError_OFFSET_ALREADY_EXISTS
+	}
+	return status.Code(err) == codes.AlreadyExists
+}
+
+// schemaPending reports whether err means the rows have columns the stream
+// doesn't know about yet, as happens shortly after columns are added.
+func schemaPending(err error) bool {
+	se := storageError(err)
+	return se != nil && se.GetCode() == storagepb.StorageError_SCHEMA_MISMATCH_EXTRA_FIELDS
+}
+
+// retryable reports whether an append that failed with err can be resent.
+func retryable(err error) bool {
+	if se := storageError(err); se != nil {
+		// OFFSET_OUT_OF_RANGE means rows before the offset are missing,
+		// which resending in order repairs. SCHEMA_MISMATCH_EXTRA_FIELDS
+		// goes away once the stream picks up new columns.
+		switch se.GetCode() {
+		case storagepb.StorageError_OFFSET_OUT_OF_RANGE, storagepb.StorageError_SCHEMA_MISMATCH_EXTRA_FIELDS:
+			return true
+		}
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.Aborted, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+func storageError(err error) *storagepb.StorageError {
+	apiErr, ok := apierror.FromError(err)
+	if !ok {
+		return nil
+	}
+	se := &storagepb.StorageError{}
+	if apiErr.Details().ExtractProtoMessage(se) != nil {
+		return nil
+	}
+	return se
+}
+
+// managedAppendStream adapts a managedwriter.ManagedStream to appendStream.
+type managedAppendStream struct {
+	ms *managedwriter.ManagedStream
+}
+
+func (s *managedAppendStream) append(ctx context.Context, rows [][]byte, offset int64) appendResult {
+	res, err := s.ms.AppendRows(ctx, rows, managedwriter.WithOffset(offset))
+	return managedAppendResult{res: res, err: err}
+}
+
+func (s *managedAppendStream) finalize(ctx context.Context) (int64, error) {
+	return s.ms.Finalize(ctx)
+}
+
+func (s *managedAppendStream) close() error { return s.ms.Close() }
+
+type managedAppendResult struct {
+	res *managedwriter.AppendResult
+	err error
+}
+
+func (r managedAppendResult) get(ctx context.Context) (*storagepb.TableSchema, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if _, err := r.res.GetResult(ctx); err != nil {
+		return nil, err
+	}
+	return r.res.UpdatedSchema(ctx)
+}
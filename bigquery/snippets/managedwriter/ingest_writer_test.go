@@ -0,0 +1,365 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package managedwriter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// errLostAck makes fakeTable apply an append but report it as unavailable,
+// as when the response is lost.
+var errLostAck = errors.New("lost ack")
+
+// fakeTable is an in-memory committed write stream. Rows are stored as the
+// field values of the decoded message, so tests can check which descriptor
+// they were written with.
+type fakeTable struct {
+	t       *testing.T
+	rows    []string
+	opens   int
+	appends int
+	// fail holds errors for the next appends. A nil entry lets the append
+	// through.
+	fail []error
+	// updated is reported with the next successful append.
+	updated *storagepb.TableSchema
+}
+
+func (f *fakeTable) open(ctx context.Context, dp *descriptorpb.DescriptorProto) (appendStream, error) {
+	f.opens++
+	return &fakeStream{table: f, dp: dp}, nil
+}
+
+type fakeStream struct {
+	table  *fakeTable
+	dp     *descriptorpb.DescriptorProto
+	closed bool
+}
+
+type fakeResult struct {
+	schema *storagepb.TableSchema
+	err    error
+}
+
+func (r fakeResult) get(context.Context) (*storagepb.TableSchema, error) { return r.schema, r.err }
+
+func offsetError(c codes.Code, sc storagepb.StorageError_StorageErrorCode) error {
+	s, err := status.New(c, sc.String()).WithDetails(&storagepb.StorageError{Code: sc})
+	if err != nil {
+		panic(err)
+	}
+	return s.Err()
+}
+
+func (s *fakeStream) append(ctx context.Context, rows [][]byte, offset int64) appendResult {
+	f := s.table
+	f.appends++
+	if s.closed {
+		return fakeResult{err: errors.New("append to closed stream")}
+	}
+	var injected error
+	if len(f.fail) > 0 {
+		injected, f.fail = f.fail[0], f.fail[1:]
+		if injected != nil && injected != errLostAck {
+			return fakeResult{err: injected}
+		}
+	}
+	switch {
+	case offset < int64(len(f.rows)):
+		return fakeResult{err: offsetError(codes.AlreadyExists, storagepb.StorageError_OFFSET_ALREADY_EXISTS)}
+	case offset > int64(len(f.rows)):
+		return fakeResult{err: offsetError(codes.OutOfRange, storagepb.StorageError_OFFSET_OUT_OF_RANGE)}
+	}
+	md := messageDescriptor(f.t, s.dp)
+	for _, b := range rows {
+		m := dynamicpb.NewMessage(md)
+		if err := proto.Unmarshal(b, m); err != nil {
+			return fakeResult{err: status.Error(codes.InvalidArgument, err.Error())}
+		}
+		f.rows = append(f.rows, rowString(m))
+	}
+	if injected == errLostAck {
+		return fakeResult{err: status.Error(codes.Unavailable, "connection reset")}
+	}
+	r := fakeResult{schema: f.updated}
+	f.updated = nil
+	return r
+}
+
+// rowString formats a row as its field values, in field order.
+func rowString(m *dynamicpb.Message) string {
+	var vals []string
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if m.Has(fd) {
+			vals = append(vals, string(fd.Name())+"="+m.Get(fd).String())
+		}
+	}
+	return strings.Join(vals, " ")
+}
+
+func (s *fakeStream) finalize(context.Context) (int64, error) {
+	return int64(len(s.table.rows)), nil
+}
+
+func (s *fakeStream) close() error {
+	s.closed = true
+	return nil
+}
+
+var testSchema = bigquery.Schema{
+	{Name: "id", Type: bigquery.IntegerFieldType, Required: true},
+	{Name: "name", Type: bigquery.StringFieldType},
+}
+
+func newTestWriter(t *testing.T, f *fakeTable, refresh schemaFetcher, opts ingestOptions) *ingestWriter {
+	t.Helper()
+	enc, err := newSchemaEncoder(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refresh == nil {
+		refresh = func(context.Context) (bigquery.Schema, error) { return testSchema, nil }
+	}
+	w, err := startIngestWriter(context.Background(), f.open, refresh, enc, opts)
+	if err != nil {
+		t.Fatalf("startIngestWriter: %v", err)
+	}
+	return w
+}
+
+func writeIDs(t *testing.T, w *ingestWriter, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := w.Write(context.Background(), map[string]bigquery.Value{"id": i}); err != nil {
+			t.Fatalf("Write(%d): %v", i, err)
+		}
+	}
+}
+
+func idRows(from, to int) []string {
+	var rows []string
+	for i := from; i < to; i++ {
+		rows = append(rows, "id="+strconv.Itoa(i))
+	}
+	return rows
+}
+
+func TestIngestWriter(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeTable{t: t}
+	w := newTestWriter(t, f, nil, ingestOptions{BatchRows: 2})
+
+	writeIDs(t, w, 0, 5)
+	if err := w.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := w.Offset(); got != 5 {
+		t.Errorf("Offset() = %d, want 5", got)
+	}
+	writeIDs(t, w, 5, 6)
+	n, err := w.Close(ctx)
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n != 6 || !reflect.DeepEqual(f.rows, idRows(0, 6)) {
+		t.Errorf("Close() = %d; table has %q, want 6 rows %q", n, f.rows, idRows(0, 6))
+	}
+	if f.appends != 4 {
+		t.Errorf("writer made %d appends, want 4", f.appends)
+	}
+}
+
+func TestIngestWriterRetry(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeTable{t: t}
+	w := newTestWriter(t, f, nil, ingestOptions{BatchRows: 2, MaxInflight: 4})
+
+	// The second append fails outright, so the appends pipelined behind it
+	// fail with OFFSET_OUT_OF_RANGE, and its first resend is applied but
+	// not acknowledged.
+	f.fail = []error{nil, status.Error(codes.Unavailable, "try again"), nil, nil, nil, errLostAck}
+	writeIDs(t, w, 0, 10)
+	if err := w.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !reflect.DeepEqual(f.rows, idRows(0, 10)) {
+		t.Errorf("table has %q, want each row once: %q", f.rows, idRows(0, 10))
+	}
+}
+
+func TestIngestWriterResume(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeTable{t: t, rows: idRows(0, 4)}
+
+	// Resume from a checkpoint at 2 after rows 2 and 3 were written but
+	// not checkpointed; resending them must not duplicate them.
+	w := newTestWriter(t, f, nil, ingestOptions{BatchRows: 2, Offset: 2})
+	writeIDs(t, w, 2, 7)
+	if _, err := w.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !reflect.DeepEqual(f.rows, idRows(0, 7)) {
+		t.Errorf("table has %q, want %q", f.rows, idRows(0, 7))
+	}
+}
+
+func TestIngestWriterErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Permanent", func(t *testing.T) {
+		f := &fakeTable{t: t}
+		w := newTestWriter(t, f, nil, ingestOptions{})
+		f.fail = []error{status.Error(codes.PermissionDenied, "no")}
+		writeIDs(t, w, 0, 1)
+		if err := w.Flush(ctx); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Flush() = %v, want PermissionDenied", err)
+		}
+		if f.appends != 1 {
+			t.Errorf("writer made %d appends, want 1", f.appends)
+		}
+	})
+
+	t.Run("Attempts", func(t *testing.T) {
+		f := &fakeTable{t: t}
+		w := newTestWriter(t, f, nil, ingestOptions{MaxAttempts: 3})
+		unavailable := status.Error(codes.Unavailable, "down")
+		f.fail = []error{unavailable, unavailable, unavailable, unavailable}
+		writeIDs(t, w, 0, 1)
+		if err := w.Flush(ctx); status.Code(err) != codes.Unavailable {
+			t.Errorf("Flush() = %v, want Unavailable", err)
+		}
+		if f.appends != 3 {
+			t.Errorf("writer made %d appends, want 3", f.appends)
+		}
+	})
+
+	t.Run("SchemaWait", func(t *testing.T) {
+		f := &fakeTable{t: t}
+		w := newTestWriter(t, f, nil, ingestOptions{SchemaWait: time.Nanosecond})
+		mismatch := offsetError(codes.InvalidArgument, storagepb.StorageError_SCHEMA_MISMATCH_EXTRA_FIELDS)
+		f.fail = []error{mismatch, mismatch, mismatch}
+		writeIDs(t, w, 0, 1)
+		if err := w.Flush(ctx); !schemaPending(err) {
+			t.Errorf("Flush() = %v, want SCHEMA_MISMATCH_EXTRA_FIELDS", err)
+		}
+		if f.appends != 2 {
+			t.Errorf("writer made %d appends, want 2", f.appends)
+		}
+	})
+}
+
+func TestIngestWriterSchemaChange(t *testing.T) {
+	ctx := context.Background()
+	evolved := append(bigquery.Schema{}, testSchema...)
+	evolved = append(evolved, &bigquery.FieldSchema{Name: "region", Type: bigquery.StringFieldType})
+
+	t.Run("UpdatedSchema", func(t *testing.T) {
+		f := &fakeTable{t: t}
+		w := newTestWriter(t, f, nil, ingestOptions{BatchRows: 1})
+		ts, err := adapt.BQSchemaToStorageTableSchema(evolved)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.updated = ts
+
+		writeIDs(t, w, 0, 2)
+		if err := w.Write(ctx, map[string]bigquery.Value{"id": 2, "region": "eu"}); err != nil {
+			t.Fatalf("Write with new column: %v", err)
+		}
+		if _, err := w.Close(ctx); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		want := append(idRows(0, 2), `id=2 region=eu`)
+		if !reflect.DeepEqual(f.rows, want) {
+			t.Errorf("table has %q, want %q", f.rows, want)
+		}
+		if f.opens != 2 {
+			t.Errorf("stream opened %d times, want 2", f.opens)
+		}
+	})
+
+	t.Run("Propagation", func(t *testing.T) {
+		f := &fakeTable{t: t}
+		refresh := func(context.Context) (bigquery.Schema, error) { return evolved, nil }
+		w := newTestWriter(t, f, refresh, ingestOptions{BatchRows: 10, MaxAttempts: 2})
+
+		// The stream rejects the new column for more attempts than
+		// MaxAttempts before picking it up.
+		mismatch := offsetError(codes.InvalidArgument, storagepb.StorageError_SCHEMA_MISMATCH_EXTRA_FIELDS)
+		f.fail = []error{mismatch, mismatch, mismatch}
+		if err := w.Write(ctx, map[string]bigquery.Value{"id": 0, "region": "eu"}); err != nil {
+			t.Fatalf("Write with new column: %v", err)
+		}
+		if _, err := w.Close(ctx); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		want := []string{`id=0 region=eu`}
+		if !reflect.DeepEqual(f.rows, want) {
+			t.Errorf("table has %q, want %q", f.rows, want)
+		}
+		if f.appends != 4 {
+			t.Errorf("writer made %d appends, want 4", f.appends)
+		}
+	})
+
+	t.Run("UnknownColumn", func(t *testing.T) {
+		f := &fakeTable{t: t}
+		schema := testSchema
+		refresh := func(context.Context) (bigquery.Schema, error) { return schema, nil }
+		w := newTestWriter(t, f, refresh, ingestOptions{BatchRows: 10})
+
+		writeIDs(t, w, 0, 2)
+		row := map[string]bigquery.Value{"id": 2, "region": "eu"}
+		var unknown *unknownColumnError
+		if err := w.Write(ctx, row); !errors.As(err, &unknown) {
+			t.Fatalf("Write before the column is added = %v, want unknownColumnError", err)
+		}
+		if f.opens != 1 {
+			t.Errorf("stream reopened without a schema change")
+		}
+
+		schema = evolved
+		if err := w.Write(ctx, row); err != nil {
+			t.Fatalf("Write after the column is added: %v", err)
+		}
+		if _, err := w.Close(ctx); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		want := append(idRows(0, 2), `id=2 region=eu`)
+		if !reflect.DeepEqual(f.rows, want) {
+			t.Errorf("table has %q, want %q", f.rows, want)
+		}
+		if f.opens != 2 {
+			t.Errorf("stream opened %d times, want 2", f.opens)
+		}
+	})
+}
@@ -18,8 +18,10 @@ import (
 	"context"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
 	"github.com/GoogleCloudPlatform/golang-samples/bigquery/snippets/bqtestutil"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 )
@@ -96,4 +98,66 @@ func TestAppends(t *testing.T) {
 		}
 	})
 
+	t.Run("IngestWriter", func(t *testing.T) {
+		type event struct {
+			ID   int64  `bigquery:"id"`
+			Kind string `bigquery:"kind"`
+		}
+		schema, err := bigquery.InferSchema(event{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		table := client.Dataset(testDatasetID).Table(testTableID + "_ingest")
+		if err := table.Create(ctx, &bigquery.TableMetadata{Schema: schema}); err != nil {
+			t.Fatalf("table.Create: %v", err)
+		}
+
+		mwClient, err := managedwriter.NewClient(ctx, tc.ProjectID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer mwClient.Close()
+		w, err := newIngestWriter(ctx, mwClient, table, ingestOptions{BatchRows: 10, SchemaWait: 15 * time.Minute})
+		if err != nil {
+			t.Fatalf("newIngestWriter: %v", err)
+		}
+		for i := 0; i < 25; i++ {
+			if err := w.Write(ctx, event{ID: int64(i), Kind: "created"}); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+		}
+		if err := w.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+
+		// Add a column mid-stream; rows using it are written once the
+		// writer picks up the new schema. The write stream only accepts
+		// the column once the change has propagated to it, which can take
+		// minutes: until then the writer retries the rejected appends for
+		// up to SchemaWait.
+		md, err := table.Metadata(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		update := bigquery.TableMetadataToUpdate{
+			Schema: append(md.Schema, &bigquery.FieldSchema{Name: "region", Type: bigquery.StringFieldType}),
+		}
+		if _, err := table.Update(ctx, update, md.ETag); err != nil {
+			t.Fatalf("table.Update: %v", err)
+		}
+		for i := 25; i < 30; i++ {
+			row := map[string]bigquery.Value{"id": i, "kind": "moved", "region": "eu"}
+			if err := w.Write(ctx, row); err != nil {
+				t.Fatalf("Write with new column: %v", err)
+			}
+		}
+		n, err := w.Close(ctx)
+		if err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if n != 30 {
+			t.Errorf("stream has %d rows, want 30", n)
+		}
+	})
+
 }
@@ -0,0 +1,555 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"golang.org/x/time/rate"
+)
+
+// Job modes.
+const (
+	// modeCopy copies the cells of every row, except for the statistics,
+	// which it updates with the copied documents.
+	modeCopy = "copy"
+	// modeReindex adds every document of the source table again, which
+	// rebuilds its postings and the statistics in the destination table.
+	modeReindex = "reindex"
+	// modeDiff compares the tables without writing anything.
+	modeDiff = "diff"
+)
+
+// Job states.
+const (
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// jobRowPrefix prefixes the rows of the destination table holding job
+// checkpoints. They are never copied or compared.
+const jobRowPrefix = "#job/"
+
+// maxDiffExamples is the number of differing row keys a diff reports.
+const maxDiffExamples = 20
+
+// A rangeProgress is the progress of a job in a range of rows.
+type rangeProgress struct {
+	// Start and End bound the range [Start, End). An empty End is unbounded.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Last is the key of the last row processed. The job resumes after it.
+	Last string `json:"last,omitempty"`
+	Done bool   `json:"done"`
+	Rows int64  `json:"rows"`
+}
+
+// rowSet returns the rows of the range that haven't been processed.
+func (p *rangeProgress) rowSet() bigtable.RowRange {
+	start := p.Start
+	if p.Last != "" {
+		// The smallest key after Last.
+		start = p.Last + "\x00"
+	}
+	if p.End == "" {
+		return bigtable.InfiniteRange(start)
+	}
+	return bigtable.NewRange(start, p.End)
+}
+
+// A tableDiff counts the differences between the source and destination
+// tables.
+type tableDiff struct {
+	Same         int64    `json:"same"`
+	Different    int64    `json:"different"`
+	OnlyInSource int64    `json:"onlyInSource"`
+	OnlyInDest   int64    `json:"onlyInDest"`
+	Examples     []string `json:"examples,omitempty"`
+}
+
+// A job copies, reindexes or compares a source table into the destination
+// table of a jobRunner.
+type job struct {
+	mu       sync.Mutex
+	ID       string           `json:"id"`
+	Source   string           `json:"source"`
+	Mode     string           `json:"mode"`
+	State    string           `json:"state"`
+	Error    string           `json:"error,omitempty"`
+	Started  time.Time        `json:"started"`
+	Finished time.Time        `json:"finished,omitempty"`
+	Ranges   []*rangeProgress `json:"ranges"`
+	Diff     *tableDiff       `json:"diff,omitempty"`
+
+	// checkpointMu serializes checkpoints, so that the last one saved is
+	// the latest.
+	checkpointMu sync.Mutex
+}
+
+// MarshalJSON returns the JSON encoding of j, with the rows processed so far.
+func (j *job) MarshalJSON() ([]byte, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	type plain job
+	var rows int64
+	for _, r := range j.Ranges {
+		rows += r.Rows
+	}
+	return json.Marshal(struct {
+		*plain
+		Rows int64 `json:"rows"`
+	}{(*plain)(j), rows})
+}
+
+// A jobRunner runs jobs writing to the table dst in the background.
+type jobRunner struct {
+	client *bigtable.Client
+	dst    string
+	// parallelism is the number of ranges processed at once.
+	parallelism int
+	// batchSize is the number of rows written by one ApplyBulk call, and the
+	// number of rows between checkpoints.
+	batchSize int
+	// limiter limits the rows written per second across all ranges.
+	limiter *rate.Limiter
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobRunner(client *bigtable.Client, dst string, parallelism, batchSize int, rowsPerSecond float64) *jobRunner {
+	limit := rate.Limit(rowsPerSecond)
+	if rowsPerSecond <= 0 {
+		limit = rate.Inf
+	}
+	return &jobRunner{
+		client:      client,
+		dst:         dst,
+		parallelism: max(parallelism, 1),
+		batchSize:   max(batchSize, 1),
+		limiter:     rate.NewLimiter(limit, max(batchSize, 1)),
+		jobs:        make(map[string]*job),
+	}
+}
+
+// start starts a job. If id names a job checkpointed in the destination
+// table, it resumes that job; source and mode must then be empty or match.
+// Otherwise id may be empty to pick one.
+func (r *jobRunner) start(ctx context.Context, id, source, mode string) (*job, error) {
+	switch mode {
+	case modeCopy, modeReindex, modeDiff:
+	default:
+		return nil, fmt.Errorf("unknown mode %q", mode)
+	}
+	if source == r.dst {
+		return nil, errors.New("source and destination tables are the same")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if j := r.jobs[id]; j != nil {
+		j.mu.Lock()
+		running := j.State == jobRunning
+		j.mu.Unlock()
+		if running {
+			return nil, fmt.Errorf("job %q is already running", id)
+		}
+	}
+
+	var j *job
+	if id != "" && mode != modeDiff {
+		var err error
+		if j, err = r.loadCheckpoint(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	if j != nil {
+		if (source != "" && source != j.Source) || mode != j.Mode {
+			return nil, fmt.Errorf("job %q copies %q in %s mode", id, j.Source, j.Mode)
+		}
+	} else {
+		if source == "" {
+			return nil, errors.New("no source table specified")
+		}
+		if id == "" {
+			id = fmt.Sprintf("%s-%d", mode, time.Now().UnixNano())
+		}
+		keys, err := r.client.Open(source).SampleRowKeys(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("SampleRowKeys: %w", err)
+		}
+		j = &job{ID: id, Source: source, Mode: mode, Ranges: splitRanges(keys)}
+	}
+	j.State = jobRunning
+	j.Error = ""
+	j.Started = time.Now()
+	j.Finished = time.Time{}
+	r.jobs[j.ID] = j
+
+	go r.run(context.Background(), j)
+	return j, nil
+}
+
+// get returns the job id, or nil.
+func (r *jobRunner) get(id string) *job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.jobs[id]
+}
+
+// list returns the jobs started since the server started.
+func (r *jobRunner) list() []*job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jobs := make([]*job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].ID < jobs[b].ID })
+	return jobs
+}
+
+// splitRanges splits the key space at the sampled keys.
+func splitRanges(keys []string) []*rangeProgress {
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+	var ranges []*rangeProgress
+	start := ""
+	for _, k := range keys {
+		if k == "" || k == start {
+			continue
+		}
+		ranges = append(ranges, &rangeProgress{Start: start, End: k})
+		start = k
+	}
+	return append(ranges, &rangeProgress{Start: start})
+}
+
+// run processes the ranges of j in parallel and records the outcome.
+func (r *jobRunner) run(ctx context.Context, j *job) {
+	if j.Mode == modeDiff {
+		j.mu.Lock()
+		j.Diff = &tableDiff{}
+		j.mu.Unlock()
+	}
+	sem := make(chan struct{}, r.parallelism)
+	errc := make(chan error, len(j.Ranges))
+	var wg sync.WaitGroup
+	for _, p := range j.Ranges {
+		j.mu.Lock()
+		done := p.Done
+		j.mu.Unlock()
+		if done {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := r.runRange(ctx, j, p); err != nil {
+				errc <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errc)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Finished = time.Now()
+	j.State = jobDone
+	if err := <-errc; err != nil {
+		j.State = jobFailed
+		j.Error = err.Error()
+		log.Printf("Job %s failed: %v", j.ID, err)
+	}
+}
+
+// runRange processes the rows of one range.
+func (r *jobRunner) runRange(ctx context.Context, j *job, p *rangeProgress) error {
+	if j.Mode == modeDiff {
+		return r.diffRange(ctx, j, p)
+	}
+	src := r.client.Open(j.Source)
+	dst := r.client.Open(r.dst)
+	j.mu.Lock()
+	rows := p.rowSet()
+	j.mu.Unlock()
+
+	var (
+		keys []string
+		muts []*bigtable.Mutation
+		docs = map[string]string{}
+	)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := r.limiter.WaitN(ctx, len(keys)); err != nil {
+			return err
+		}
+		if j.Mode == modeCopy {
+			// The statistics of dst change by the documents the batch
+			// adds or overwrites.
+			stats, err := copiedStats(ctx, dst, docs)
+			if err != nil {
+				return err
+			}
+			if err := applyBulk(ctx, dst, keys, muts); err != nil {
+				return fmt.Errorf("ApplyBulk: %w", err)
+			}
+			if err := updateStats(ctx, dst, stats.docs, stats.words); err != nil {
+				return err
+			}
+		} else {
+			for _, k := range keys {
+				if content, ok := docs[k]; ok {
+					if err := putDocument(ctx, dst, k, content); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		j.mu.Lock()
+		p.Last = keys[len(keys)-1]
+		p.Rows += int64(len(keys))
+		j.mu.Unlock()
+		keys, muts, docs = keys[:0], muts[:0], map[string]string{}
+		return r.checkpoint(ctx, j)
+	}
+
+	filter := bigtable.ChainFilters(
+		bigtable.FamilyFilter(indexColumnFamily+"|"+contentColumnFamily),
+		bigtable.LatestNFilter(1),
+	)
+	if j.Mode == modeReindex {
+		filter = bigtable.ChainFilters(
+			bigtable.FamilyFilter(contentColumnFamily),
+			bigtable.ColumnFilter("^$"),
+			bigtable.LatestNFilter(1),
+		)
+	}
+	var flushErr error
+	err := src.ReadRows(ctx, rows, func(row bigtable.Row) bool {
+		key := row.Key()
+		// The statistics of the destination are kept up to date as
+		// documents are copied, so the source's aren't copied.
+		if strings.HasPrefix(key, jobRowPrefix) || key == statsRow {
+			return true
+		}
+		if j.Mode == modeCopy {
+			mut := bigtable.NewMutation()
+			for family, items := range row {
+				for _, item := range items {
+					// Get the column name, excluding the column family name and ':' character.
+					mut.Set(family, item.Column[len(family)+1:], item.Timestamp, item.Value)
+				}
+			}
+			muts = append(muts, mut)
+		}
+		if cells := row[contentColumnFamily]; len(cells) > 0 && validDocName(key) == nil {
+			docs[key] = string(cells[0].Value)
+		}
+		keys = append(keys, key)
+		if len(keys) >= r.batchSize {
+			flushErr = flush()
+		}
+		return flushErr == nil
+	}, bigtable.RowFilter(filter))
+	if err == nil {
+		err = flushErr
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return fmt.Errorf("range [%q, %q): %w", p.Start, p.End, err)
+	}
+	j.mu.Lock()
+	p.Done = true
+	j.mu.Unlock()
+	return r.checkpoint(ctx, j)
+}
+
+// checkpoint saves the progress of j in the destination table.
+func (r *jobRunner) checkpoint(ctx context.Context, j *job) error {
+	// Ranges are processed concurrently: without checkpointMu, an older
+	// snapshot could be saved after a newer one.
+	j.checkpointMu.Lock()
+	defer j.checkpointMu.Unlock()
+	j.mu.Lock()
+	b, err := json.Marshal(struct {
+		Source string           `json:"source"`
+		Mode   string           `json:"mode"`
+		Ranges []*rangeProgress `json:"ranges"`
+	}{j.Source, j.Mode, j.Ranges})
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// Replace the previous checkpoint rather than adding a version of the
+	// cell per batch.
+	mut := bigtable.NewMutation()
+	mut.DeleteCellsInColumn(contentColumnFamily, "checkpoint")
+	mut.Set(contentColumnFamily, "checkpoint", bigtable.Now(), b)
+	if err := r.client.Open(r.dst).Apply(ctx, jobRowPrefix+j.ID, mut); err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+	return nil
+}
+
+// copiedStats returns how much the statistics of dst change when docs, a
+// map of document names to contents, are copied over it.
+func copiedStats(ctx context.Context, dst *bigtable.Table, docs map[string]string) (corpusStats, error) {
+	var stats corpusStats
+	if len(docs) == 0 {
+		return stats, nil
+	}
+	names := make(bigtable.RowList, 0, len(docs))
+	for name, content := range docs {
+		names = append(names, name)
+		_, n := postings(content)
+		stats.docs++
+		stats.words += int64(n)
+	}
+	err := dst.ReadRows(ctx, names, func(row bigtable.Row) bool {
+		if cells := row[contentColumnFamily]; len(cells) > 0 {
+			_, n := postings(string(cells[0].Value))
+			stats.docs--
+			stats.words -= int64(n)
+		}
+		return true
+	}, bigtable.RowFilter(bigtable.ChainFilters(
+		bigtable.FamilyFilter(contentColumnFamily),
+		bigtable.ColumnFilter("^$"),
+		bigtable.LatestNFilter(1),
+	)))
+	if err != nil {
+		return corpusStats{}, fmt.Errorf("reading copied documents: %w", err)
+	}
+	return stats, nil
+}
+
+// loadCheckpoint returns the job id saved in the destination table, or nil.
+func (r *jobRunner) loadCheckpoint(ctx context.Context, id string) (*job, error) {
+	row, err := r.client.Open(r.dst).ReadRow(ctx, jobRowPrefix+id, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	cells := row[contentColumnFamily]
+	if len(cells) == 0 {
+		return nil, nil
+	}
+	j := &job{ID: id}
+	saved := struct {
+		Source *string           `json:"source"`
+		Mode   *string           `json:"mode"`
+		Ranges *[]*rangeProgress `json:"ranges"`
+	}{&j.Source, &j.Mode, &j.Ranges}
+	if err := json.Unmarshal(cells[0].Value, &saved); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint: %w", err)
+	}
+	return j, nil
+}
+
+// diffRange compares the rows of one range in both tables.
+func (r *jobRunner) diffRange(ctx context.Context, j *job, p *rangeProgress) error {
+	digests := func(table string) (map[string][sha256.Size]byte, error) {
+		m := make(map[string][sha256.Size]byte)
+		err := r.client.Open(table).ReadRows(ctx, p.rowSet(), func(row bigtable.Row) bool {
+			if !strings.HasPrefix(row.Key(), jobRowPrefix) && row.Key() != statsRow {
+				m[row.Key()] = rowDigest(row)
+			}
+			return true
+		}, bigtable.RowFilter(bigtable.ChainFilters(
+			bigtable.FamilyFilter(indexColumnFamily+"|"+contentColumnFamily),
+			bigtable.LatestNFilter(1),
+		)))
+		return m, err
+	}
+	src, err := digests(j.Source)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", j.Source, err)
+	}
+	dst, err := digests(r.dst)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", r.dst, err)
+	}
+
+	var diffs []string
+	var d tableDiff
+	for k, s := range src {
+		switch got, ok := dst[k]; {
+		case !ok:
+			d.OnlyInSource++
+			diffs = append(diffs, "only in source: "+strconv.Quote(k))
+		case got != s:
+			d.Different++
+			diffs = append(diffs, "different: "+strconv.Quote(k))
+		default:
+			d.Same++
+		}
+	}
+	for k := range dst {
+		if _, ok := src[k]; !ok {
+			d.OnlyInDest++
+			diffs = append(diffs, "only in destination: "+strconv.Quote(k))
+		}
+	}
+	sort.Strings(diffs)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Diff.Same += d.Same
+	j.Diff.Different += d.Different
+	j.Diff.OnlyInSource += d.OnlyInSource
+	j.Diff.OnlyInDest += d.OnlyInDest
+	for _, e := range diffs {
+		if len(j.Diff.Examples) >= maxDiffExamples {
+			break
+		}
+		j.Diff.Examples = append(j.Diff.Examples, e)
+	}
+	sort.Strings(j.Diff.Examples)
+	p.Rows = int64(len(src))
+	p.Done = true
+	return nil
+}
+
+// rowDigest returns a hash of the columns and values of row, ignoring
+// timestamps.
+func rowDigest(row bigtable.Row) [sha256.Size]byte {
+	var cells []string
+	for _, items := range row {
+		for _, item := range items {
+			cells = append(cells, strconv.Quote(item.Column)+"="+strconv.Quote(string(item.Value)))
+		}
+	}
+	sort.Strings(cells)
+	return sha256.Sum256([]byte(strings.Join(cells, "\n")))
+}
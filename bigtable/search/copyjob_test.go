@@ -0,0 +1,258 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitRanges(t *testing.T) {
+	got := splitRanges([]string{"m", "", "c", "m", "x"})
+	want := []*rangeProgress{
+		{Start: "", End: "c"},
+		{Start: "c", End: "m"},
+		{Start: "m", End: "x"},
+		{Start: "x"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("splitRanges mismatch (-want +got):\n%s", diff)
+	}
+	if got := splitRanges(nil); len(got) != 1 || got[0].Start != "" || got[0].End != "" {
+		t.Errorf("splitRanges(nil) = %v, want the whole table", got)
+	}
+}
+
+// newSourceTable creates a second table, with documents, on the server of
+// client.
+func newSourceTable(t *testing.T, client *bigtable.Client, adminClient *bigtable.AdminClient, name string, docs map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := resetTable(ctx, adminClient, name, 0); err != nil {
+		t.Fatalf("resetTable: %v", err)
+	}
+	for doc, content := range docs {
+		if err := putDocument(ctx, client.Open(name), doc, content); err != nil {
+			t.Fatalf("putDocument: %v", err)
+		}
+	}
+}
+
+// waitJob waits for j to finish and returns its state.
+func waitJob(t *testing.T, j *job) string {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		j.mu.Lock()
+		state, errMsg := j.State, j.Error
+		j.mu.Unlock()
+		if state != jobRunning {
+			if errMsg != "" {
+				t.Logf("job %s: %s", j.ID, errMsg)
+			}
+			return state
+		}
+	}
+	t.Fatalf("job %s didn't finish", j.ID)
+	return ""
+}
+
+func manyDocs(n int) map[string]string {
+	docs := make(map[string]string)
+	for i := 0; i < n; i++ {
+		docs[fmt.Sprintf("doc%03d", i)] = fmt.Sprintf("Document number %d talks about tablets and rows.", i)
+	}
+	return docs
+}
+
+func TestCopyJob(t *testing.T) {
+	ctx := context.Background()
+	client, adminClient, table := newTestTable(t)
+	newSourceTable(t, client, adminClient, "source", manyDocs(30))
+	// Statistics are derived from the documents, and neither copied nor
+	// compared.
+	if err := updateStats(ctx, client.Open("source"), 100, 0); err != nil {
+		t.Fatalf("updateStats: %v", err)
+	}
+
+	runner := newJobRunner(client, testTable, 3, 7, 0)
+	j, err := runner.start(ctx, "", "source", modeCopy)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if state := waitJob(t, j); state != jobDone {
+		t.Fatalf("copy job state = %q, want %q", state, jobDone)
+	}
+
+	q, _ := parseQuery("tablets")
+	results, err := search(ctx, table, q)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 20 {
+		t.Errorf("search after copy got %d results, want 20", len(results))
+	}
+
+	// A dry run finds no differences, except for the checkpoint and the
+	// statistics, which it ignores.
+	d, err := runner.start(ctx, "", "source", modeDiff)
+	if err != nil {
+		t.Fatalf("start diff: %v", err)
+	}
+	if state := waitJob(t, d); state != jobDone {
+		t.Fatalf("diff job state = %q", state)
+	}
+	if d.Diff.Different != 0 || d.Diff.OnlyInSource != 0 || d.Diff.OnlyInDest != 0 || d.Diff.Same == 0 {
+		t.Errorf("diff after copy = %+v, want only identical rows", d.Diff)
+	}
+
+	// The checkpoint records every range as done.
+	saved, err := runner.loadCheckpoint(ctx, j.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("loadCheckpoint = %v, %v", saved, err)
+	}
+	var rows int64
+	for _, p := range saved.Ranges {
+		if !p.Done {
+			t.Errorf("checkpointed range %+v isn't done", p)
+		}
+		rows += p.Rows
+	}
+	if rows == 0 {
+		t.Errorf("checkpoint recorded no rows")
+	}
+	row, err := table.ReadRow(ctx, jobRowPrefix+j.ID)
+	if err != nil {
+		t.Fatalf("ReadRow: %v", err)
+	}
+	if n := len(row[contentColumnFamily]); n != 1 {
+		t.Errorf("checkpoint row has %d cells, want 1", n)
+	}
+
+	stats, err := readStats(ctx, table)
+	if err != nil {
+		t.Fatalf("readStats: %v", err)
+	}
+	if stats.docs != 30 {
+		t.Errorf("after copying, stats.docs = %d, want 30", stats.docs)
+	}
+}
+
+func TestReindexResumeAndDiff(t *testing.T) {
+	ctx := context.Background()
+	client, adminClient, table := newTestTable(t)
+	newSourceTable(t, client, adminClient, "source", map[string]string{
+		"a": "Alpha tablets.",
+		"b": "Bravo rows.",
+		"c": "Charlie cells.",
+	})
+	if err := putDocument(ctx, table, "z", "Zulu only here."); err != nil {
+		t.Fatalf("putDocument: %v", err)
+	}
+	runner := newJobRunner(client, testTable, 2, 1, 0)
+
+	d, err := runner.start(ctx, "", "source", modeDiff)
+	if err != nil {
+		t.Fatalf("start diff: %v", err)
+	}
+	waitJob(t, d)
+	if d.Diff.OnlyInSource == 0 || d.Diff.OnlyInDest == 0 {
+		t.Errorf("diff before reindex = %+v, want rows only in each table", d.Diff)
+	}
+	if _, ok, _ := readContent(ctx, table, "a"); ok {
+		t.Fatalf("the dry run wrote to the destination table")
+	}
+
+	// Simulate a job that stopped after document a.
+	j := &job{ID: "resumed", Source: "source", Mode: modeReindex, Ranges: []*rangeProgress{{Last: "a", Rows: 1}}}
+	if err := runner.checkpoint(ctx, j); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	j, err = runner.start(ctx, "resumed", "", modeReindex)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if state := waitJob(t, j); state != jobDone {
+		t.Fatalf("reindex state = %q", state)
+	}
+	if _, ok, _ := readContent(ctx, table, "a"); ok {
+		t.Errorf("the resumed job reindexed a, which was before the checkpoint")
+	}
+	for _, doc := range []string{"b", "c", "z"} {
+		if _, ok, _ := readContent(ctx, table, doc); !ok {
+			t.Errorf("document %s is missing after reindexing", doc)
+		}
+	}
+	stats, err := readStats(ctx, table)
+	if err != nil {
+		t.Fatalf("readStats: %v", err)
+	}
+	if stats.docs != 3 {
+		t.Errorf("after reindexing, stats.docs = %d, want 3", stats.docs)
+	}
+
+	if _, err := runner.start(ctx, "resumed", "other", modeReindex); err == nil {
+		t.Errorf("resuming with another source got nil error, want error")
+	}
+	if _, err := runner.start(ctx, "", testTable, modeCopy); err == nil {
+		t.Errorf("copying a table into itself got nil error, want error")
+	}
+}
+
+func TestCopyHandlers(t *testing.T) {
+	client, adminClient, _ := newTestTable(t)
+	newSourceTable(t, client, adminClient, "source", manyDocs(3))
+	runner := newJobRunner(client, testTable, 2, 10, 0)
+
+	r := httptest.NewRequest("POST", "/copy", strings.NewReader("name=source&mode=copy"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handleCopy(rr, r, runner)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("POST /copy got %d: %s", rr.Code, rr.Body)
+	}
+	jobs := runner.list()
+	if len(jobs) != 1 {
+		t.Fatalf("got %d jobs, want 1", len(jobs))
+	}
+	waitJob(t, jobs[0])
+
+	rr = httptest.NewRecorder()
+	handleCopyStatus(rr, httptest.NewRequest("GET", "/copy/status?id="+jobs[0].ID, nil), runner)
+	var status struct {
+		State string
+		Rows  int64
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("status isn't JSON: %v\n%s", err, rr.Body)
+	}
+	if status.State != jobDone || status.Rows == 0 {
+		t.Errorf("status = %+v, want done with rows", status)
+	}
+
+	rr = httptest.NewRecorder()
+	handleCopyStatus(rr, httptest.NewRequest("GET", "/copy/status?id=nope", nil), runner)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status of unknown job got %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
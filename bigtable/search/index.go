@@ -0,0 +1,340 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"cloud.google.com/go/bigtable"
+)
+
+// The index has one row per word. Each cell in the row's index column family
+// is a posting: the column is the name of a document containing the word,
+// and the value encodes the length of the document, the number of times it
+// contains the word and the positions of the word. That's everything BM25
+// scoring and phrase matching need, without reading the documents.
+//
+// The statistics row holds the number of documents and the total number of
+// words in them, for the BM25 inverse document frequency and average
+// document length.
+const (
+	statsRow         = "#stats"
+	statsDocsColumn  = "docs"
+	statsWordsColumn = "words"
+)
+
+// A token is a word of a document, and where it is.
+type token struct {
+	word string
+	// pos is the index of the token among the tokens of the document.
+	pos int
+	// start and end are the byte offsets of the token in the document.
+	start, end int
+}
+
+// tokens splits s into lowercase words of letters.
+// This is very simple, it's not a good tokenization function.
+func tokens(s string) []token {
+	var toks []token
+	start := -1
+	for i, r := range s {
+		if unicode.IsLetter(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			toks = append(toks, token{strings.ToLower(s[start:i]), len(toks), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		toks = append(toks, token{strings.ToLower(s[start:]), len(toks), start, len(s)})
+	}
+	return toks
+}
+
+// tokenize splits a string into unique words.
+func tokenize(s string) []string {
+	seen := make(map[string]bool)
+	var words []string
+	for _, t := range tokens(s) {
+		if !seen[t.word] {
+			seen[t.word] = true
+			words = append(words, t.word)
+		}
+	}
+	return words
+}
+
+// A posting records the occurrences of a word in a document.
+type posting struct {
+	// docLen is the number of words in the document.
+	docLen int
+	// positions are the positions of the word in the document, ascending.
+	positions []int
+}
+
+// tf returns the term frequency of the word in the document.
+func (p posting) tf() int { return len(p.positions) }
+
+// postings returns the posting of each word of content.
+func postings(content string) (map[string]*posting, int) {
+	toks := tokens(content)
+	m := make(map[string]*posting)
+	for _, t := range toks {
+		p := m[t.word]
+		if p == nil {
+			p = &posting{docLen: len(toks)}
+			m[t.word] = p
+		}
+		p.positions = append(p.positions, t.pos)
+	}
+	return m, len(toks)
+}
+
+// encode returns the cell value of p: the document length, the term
+// frequency and the position deltas, as uvarints.
+func (p posting) encode() []byte {
+	b := binary.AppendUvarint(nil, uint64(p.docLen))
+	b = binary.AppendUvarint(b, uint64(len(p.positions)))
+	prev := 0
+	for _, pos := range p.positions {
+		b = binary.AppendUvarint(b, uint64(pos-prev))
+		prev = pos
+	}
+	return b
+}
+
+var errBadPosting = errors.New("malformed posting")
+
+// decodePosting parses a cell value written by posting.encode.
+func decodePosting(b []byte) (posting, error) {
+	next := func() (int, error) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > uint64(maxInt) {
+			return 0, errBadPosting
+		}
+		b = b[n:]
+		return int(v), nil
+	}
+	var p posting
+	var err error
+	if p.docLen, err = next(); err != nil {
+		return posting{}, err
+	}
+	tf, err := next()
+	if err != nil || tf > len(b) {
+		return posting{}, errBadPosting
+	}
+	p.positions = make([]int, tf)
+	pos := 0
+	for i := range p.positions {
+		d, err := next()
+		if err != nil {
+			return posting{}, err
+		}
+		pos += d
+		p.positions[i] = pos
+	}
+	if len(b) > 0 {
+		return posting{}, errBadPosting
+	}
+	return p, nil
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// validDocName reports whether name can be used as a document name.
+func validDocName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("empty document name")
+	case strings.HasPrefix(name, "#"):
+		return errors.New("document names can't start with #")
+	case !utf8.ValidString(name):
+		return errors.New("document name must be UTF-8")
+	}
+	return nil
+}
+
+// readContent returns the content of the document name, and whether it
+// exists.
+func readContent(ctx context.Context, table *bigtable.Table, name string) (string, bool, error) {
+	row, err := table.ReadRow(ctx, name, bigtable.RowFilter(bigtable.ChainFilters(
+		bigtable.FamilyFilter(contentColumnFamily),
+		bigtable.ColumnFilter("^$"),
+		bigtable.LatestNFilter(1),
+	)))
+	if err != nil {
+		return "", false, err
+	}
+	cells := row[contentColumnFamily]
+	if len(cells) == 0 {
+		return "", false, nil
+	}
+	return string(cells[0].Value), true, nil
+}
+
+// putDocument stores content as the document name and indexes it. If the
+// document exists, the postings of words it no longer contains are deleted
+// and the others are overwritten, so searches never return stale matches.
+//
+// The writes aren't atomic: a concurrent search may see the old content with
+// new postings for a moment, and a failed write can leave the index partly
+// updated. Writing the same document again repairs it.
+func putDocument(ctx context.Context, table *bigtable.Table, name, content string) error {
+	if err := validDocName(name); err != nil {
+		return err
+	}
+	old, existed, err := readContent(ctx, table, name)
+	if err != nil {
+		return fmt.Errorf("reading %q: %w", name, err)
+	}
+	oldPostings, oldLen := postings(old)
+	newPostings, newLen := postings(content)
+
+	// Write the content first, so postings never point to a missing document.
+	ts := bigtable.Now()
+	mut := bigtable.NewMutation()
+	mut.Set(contentColumnFamily, "", ts, []byte(content))
+	if err := table.Apply(ctx, name, mut); err != nil {
+		return fmt.Errorf("writing %q: %w", name, err)
+	}
+
+	var rows []string
+	var muts []*bigtable.Mutation
+	for word, p := range newPostings {
+		mut := bigtable.NewMutation()
+		mut.Set(indexColumnFamily, name, ts, p.encode())
+		rows = append(rows, word)
+		muts = append(muts, mut)
+	}
+	for word := range oldPostings {
+		if newPostings[word] != nil {
+			continue
+		}
+		mut := bigtable.NewMutation()
+		mut.DeleteCellsInColumn(indexColumnFamily, name)
+		rows = append(rows, word)
+		muts = append(muts, mut)
+	}
+	if err := applyBulk(ctx, table, rows, muts); err != nil {
+		return fmt.Errorf("indexing %q: %w", name, err)
+	}
+
+	docs := int64(1)
+	if existed {
+		docs = 0
+	}
+	return updateStats(ctx, table, docs, int64(newLen-oldLen))
+}
+
+// deleteDocument deletes the document name and its postings. It returns
+// false if the document doesn't exist.
+func deleteDocument(ctx context.Context, table *bigtable.Table, name string) (bool, error) {
+	content, existed, err := readContent(ctx, table, name)
+	if err != nil {
+		return false, fmt.Errorf("reading %q: %w", name, err)
+	}
+	if !existed {
+		return false, nil
+	}
+	old, oldLen := postings(content)
+
+	// Delete the postings first, so they never point to a missing document.
+	var rows []string
+	var muts []*bigtable.Mutation
+	for word := range old {
+		mut := bigtable.NewMutation()
+		mut.DeleteCellsInColumn(indexColumnFamily, name)
+		rows = append(rows, word)
+		muts = append(muts, mut)
+	}
+	if err := applyBulk(ctx, table, rows, muts); err != nil {
+		return false, fmt.Errorf("unindexing %q: %w", name, err)
+	}
+	mut := bigtable.NewMutation()
+	mut.DeleteCellsInFamily(contentColumnFamily)
+	if err := table.Apply(ctx, name, mut); err != nil {
+		return false, fmt.Errorf("deleting %q: %w", name, err)
+	}
+	return true, updateStats(ctx, table, -1, -int64(oldLen))
+}
+
+// applyBulk applies muts to rows, and returns the first error.
+func applyBulk(ctx context.Context, table *bigtable.Table, rows []string, muts []*bigtable.Mutation) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	errs, err := table.ApplyBulk(ctx, rows, muts)
+	if err != nil {
+		return err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateStats adds to the number of documents and words.
+func updateStats(ctx context.Context, table *bigtable.Table, docs, words int64) error {
+	if docs == 0 && words == 0 {
+		return nil
+	}
+	rmw := bigtable.NewReadModifyWrite()
+	rmw.Increment(contentColumnFamily, statsDocsColumn, docs)
+	rmw.Increment(contentColumnFamily, statsWordsColumn, words)
+	if _, err := table.ApplyReadModifyWrite(ctx, statsRow, rmw); err != nil {
+		return fmt.Errorf("updating stats: %w", err)
+	}
+	return nil
+}
+
+// corpusStats are the statistics of all documents.
+type corpusStats struct {
+	docs, words int64
+}
+
+// readStats returns the number of documents and words.
+func readStats(ctx context.Context, table *bigtable.Table) (corpusStats, error) {
+	row, err := table.ReadRow(ctx, statsRow, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	if err != nil {
+		return corpusStats{}, fmt.Errorf("reading stats: %w", err)
+	}
+	var s corpusStats
+	for _, cell := range row[contentColumnFamily] {
+		if len(cell.Value) != 8 {
+			continue
+		}
+		v := int64(binary.BigEndian.Uint64(cell.Value))
+		switch cell.Column {
+		case contentColumnFamily + ":" + statsDocsColumn:
+			s.docs = v
+		case contentColumnFamily + ":" + statsWordsColumn:
+			s.words = v
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,356 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/bigtable"
+)
+
+// BM25 parameters. See https://en.wikipedia.org/wiki/Okapi_BM25.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+const (
+	// maxResults is the number of results returned by a search.
+	maxResults = 20
+	// snippetWords is the number of words in a snippet.
+	snippetWords = 30
+)
+
+// A clause matches documents containing a word, or a phrase of consecutive
+// words.
+type clause []string
+
+// A query matches documents matching at least one clause of every group.
+// Words are ANDed, OR combines the words around it into a group and quotes
+// make a phrase:
+//
+//	bigtable "column family" OR row
+//
+// matches documents containing bigtable, and the phrase "column family" or
+// the word row.
+type query struct {
+	groups [][]clause
+}
+
+// parseQuery parses s into a query.
+func parseQuery(s string) (query, error) {
+	var q query
+	or := false
+	add := func(text string) {
+		if text == "OR" {
+			or = len(q.groups) > 0
+			return
+		}
+		words := make(clause, 0, 1)
+		for _, t := range tokens(text) {
+			words = append(words, t.word)
+		}
+		if len(words) == 0 {
+			return
+		}
+		if or {
+			last := len(q.groups) - 1
+			q.groups[last] = append(q.groups[last], words)
+		} else {
+			q.groups = append(q.groups, []clause{words})
+		}
+		or = false
+	}
+
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			break
+		}
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return query{}, errors.New("unterminated quote")
+			}
+			add(s[1 : end+1])
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t\r\n\"")
+		if end < 0 {
+			end = len(s)
+		}
+		add(s[:end])
+		s = s[end:]
+	}
+	if len(q.groups) == 0 {
+		return query{}, errors.New("empty query")
+	}
+	return q, nil
+}
+
+// words returns the unique words of q.
+func (q query) words() []string {
+	seen := make(map[string]bool)
+	var words []string
+	for _, g := range q.groups {
+		for _, c := range g {
+			for _, w := range c {
+				if !seen[w] {
+					seen[w] = true
+					words = append(words, w)
+				}
+			}
+		}
+	}
+	return words
+}
+
+// index maps words to documents to postings.
+type index map[string]map[string]posting
+
+// matches reports whether the document doc matches c.
+func (idx index) matches(doc string, c clause) bool {
+	first, ok := idx[c[0]][doc]
+	if !ok {
+		return false
+	}
+	if len(c) == 1 {
+		return true
+	}
+	rest := make([][]int, len(c)-1)
+	for i, w := range c[1:] {
+		p, ok := idx[w][doc]
+		if !ok {
+			return false
+		}
+		rest[i] = p.positions
+	}
+	for _, start := range first.positions {
+		found := true
+		for i, positions := range rest {
+			want := start + i + 1
+			j := sort.SearchInts(positions, want)
+			if j == len(positions) || positions[j] != want {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// A result is a document matching a query.
+type result struct {
+	Title   string
+	Score   float64
+	Snippet template.HTML
+}
+
+// search returns the best maxResults documents matching q, best first.
+func search(ctx context.Context, table *bigtable.Table, q query) ([]result, error) {
+	words := q.words()
+	idx := make(index)
+	err := table.ReadRows(ctx, bigtable.RowList(words), func(row bigtable.Row) bool {
+		docs := make(map[string]posting)
+		for _, cell := range row[indexColumnFamily] {
+			doc := strings.TrimPrefix(cell.Column, indexColumnFamily+":")
+			p, err := decodePosting(cell.Value)
+			if err != nil {
+				// Written by an older version of this sample. Add the
+				// document again to index it.
+				log.Printf("Skipping posting of %q in %q: %v", row.Key(), doc, err)
+				continue
+			}
+			docs[doc] = p
+		}
+		idx[row.Key()] = docs
+		return true
+	}, bigtable.RowFilter(bigtable.ChainFilters(
+		bigtable.FamilyFilter(indexColumnFamily),
+		bigtable.LatestNFilter(1),
+	)))
+	if err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+	stats, err := readStats(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every match contains a word of the first group.
+	candidates := make(map[string]bool)
+	for _, c := range q.groups[0] {
+		for doc := range idx[c[0]] {
+			candidates[doc] = true
+		}
+	}
+	var results []result
+	for doc := range candidates {
+		if !matchesAll(idx, doc, q) {
+			continue
+		}
+		results = append(results, result{Title: doc, Score: score(idx, doc, words, stats)})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Title < results[j].Title
+	})
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+
+	if err := addSnippets(ctx, table, results, words); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// matchesAll reports whether doc matches a clause of every group of q.
+func matchesAll(idx index, doc string, q query) bool {
+	for _, g := range q.groups {
+		ok := false
+		for _, c := range g {
+			if idx.matches(doc, c) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// score returns the BM25 score of doc for words.
+func score(idx index, doc string, words []string, stats corpusStats) float64 {
+	n := float64(stats.docs)
+	avgLen := 1.0
+	if stats.docs > 0 && stats.words > 0 {
+		avgLen = float64(stats.words) / n
+	}
+	var s float64
+	for _, w := range words {
+		p, ok := idx[w][doc]
+		if !ok {
+			continue
+		}
+		df := float64(len(idx[w]))
+		idf := math.Log(1 + (math.Max(n, df)-df+0.5)/(df+0.5))
+		tf := float64(p.tf())
+		s += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(p.docLen)/avgLen))
+	}
+	return s
+}
+
+// addSnippets reads the content of the documents of results and sets their
+// snippets.
+func addSnippets(ctx context.Context, table *bigtable.Table, results []result, words []string) error {
+	if len(results) == 0 {
+		return nil
+	}
+	rows := make([]string, len(results))
+	for i, r := range results {
+		rows[i] = r.Title
+	}
+	content := make(map[string]string)
+	err := table.ReadRows(ctx, bigtable.RowList(rows), func(row bigtable.Row) bool {
+		if cells := row[contentColumnFamily]; len(cells) > 0 {
+			content[row.Key()] = string(cells[0].Value)
+		}
+		return true
+	}, bigtable.RowFilter(bigtable.ChainFilters(
+		bigtable.FamilyFilter(contentColumnFamily),
+		bigtable.ColumnFilter("^$"),
+		bigtable.LatestNFilter(1),
+	)))
+	if err != nil {
+		return fmt.Errorf("reading results: %w", err)
+	}
+	hit := make(map[string]bool)
+	for _, w := range words {
+		hit[w] = true
+	}
+	for i := range results {
+		results[i].Snippet = snippet(content[results[i].Title], hit)
+	}
+	return nil
+}
+
+// snippet returns the snippetWords words of text with the most hits, with
+// the hits in bold.
+func snippet(text string, hit map[string]bool) template.HTML {
+	toks := tokens(text)
+	if len(toks) == 0 {
+		return ""
+	}
+
+	// Slide a window over the tokens, counting the hits in it.
+	best, bestHits, hits := 0, -1, 0
+	for i, t := range toks {
+		if hit[t.word] {
+			hits++
+		}
+		if i >= snippetWords && hit[toks[i-snippetWords].word] {
+			hits--
+		}
+		if start := max(i-snippetWords+1, 0); hits > bestHits {
+			best, bestHits = start, hits
+		}
+	}
+	// Start a few words before the first hit rather than end on the last.
+	for i := best; i < min(best+snippetWords, len(toks)); i++ {
+		if hit[toks[i].word] {
+			best = min(max(best, i-5), max(len(toks)-snippetWords, 0))
+			break
+		}
+	}
+	window := toks[best:min(best+snippetWords, len(toks))]
+
+	var b strings.Builder
+	if best > 0 {
+		b.WriteString("&hellip;")
+	}
+	prev := window[0].start
+	for _, t := range window {
+		b.WriteString(html.EscapeString(text[prev:t.start]))
+		if hit[t.word] {
+			fmt.Fprintf(&b, "<b>%s</b>", html.EscapeString(text[t.start:t.end]))
+		} else {
+			b.WriteString(html.EscapeString(text[t.start:t.end]))
+		}
+		prev = t.end
+	}
+	if window[len(window)-1].pos < len(toks)-1 {
+		b.WriteString("&hellip;")
+	} else {
+		b.WriteString(html.EscapeString(text[prev:]))
+	}
+	return template.HTML(b.String())
+}
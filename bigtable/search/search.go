@@ -18,16 +18,23 @@
 //   - Initialize and clear the table.
 //   - Add a document.  This adds the content of a user-supplied document to the
 //     Bigtable, and adds references to the document to an index in the Bigtable.
-//     The document is indexed under each unique word in the document.
-//   - Search the index.  This returns documents containing each word in a user
-//     query, with snippets and links to view the whole document.
-//   - Copy table.  This copies the documents and index from another table and
-//     adds them to the current one.
+//     The document is indexed under each unique word in the document, with the
+//     positions of the word. Adding a document again replaces it, and its
+//     index entries, and documents can be deleted.
+//   - Search the index.  This returns documents matching a user query, ranked
+//     by BM25 relevance, with highlighted snippets and links to view the whole
+//     document. Queries can use OR and "quoted phrases".
+//   - Copy table.  This starts a background job that copies the documents and
+//     index from another table and adds them to the current one, or reindexes
+//     the documents of another table, or compares the tables without writing.
+//     Jobs split the table into row ranges, copy them in parallel with a rate
+//     limit, and checkpoint their progress so they can be resumed.
 package main
 
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
@@ -35,10 +42,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
-	"sync"
 	"time"
-	"unicode"
 
 	"cloud.google.com/go/bigtable"
 )
@@ -56,8 +60,10 @@ Added {{.Title}}
 	searchTemplate = template.Must(template.New("").Parse(`<html><body>
 Results for <b>{{.Query}}</b>:<br><br>
 {{range .Results}}
-<a href="/content?name={{.Title}}">{{.Title}}</a><br>
+<a href="/content?name={{.Title}}">{{.Title}}</a> ({{printf "%.2f" .Score}})<br>
 <i>{{.Snippet}}</i><br><br>
+{{else}}
+No documents found.
 {{end}}
 </body></html>`))
 )
@@ -76,7 +82,7 @@ const (
 				<div><input type="submit" value="Init"></div>
 			</form>
 
-			Search for documents:
+			Search for documents, e.g. <code>bigtable "column family" OR row</code>:
 			<form action="/search" method="post">
 				<div><input type="text" name="q" size=80></div>
 				<div><input type="submit" value="Search"></div>
@@ -91,12 +97,29 @@ const (
 				<div><input type="submit" value="Submit"></div>
 			</form>
 
-			Copy data from another table:
+			Delete a document:
+			<form action="/delete" method="post">
+				Document name:
+				<div><input type="text" name="name" size=80></div>
+				<div><input type="submit" value="Delete"></div>
+			</form>
+
+			Copy data from another table in the background:
 			<form action="/copy" method="post">
 				Source table name:
 				<div><input type="text" name="name" size=80></div>
-				<div><input type="submit" value="Copy"></div>
+				Job ID, to resume a job:
+				<div><input type="text" name="id" size=80></div>
+				<div>
+					<select name="mode">
+						<option value="copy">Copy rows</option>
+						<option value="reindex">Reindex documents</option>
+						<option value="diff">Dry run: compare tables</option>
+					</select>
+					<input type="submit" value="Start">
+				</div>
 			</form>
+			<a href="/copy/status">Jobs</a>
 		</body>
 	</html>
 	`
@@ -108,6 +131,10 @@ func main() {
 		instance  = flag.String("instance", "", "The name of the Cloud Bigtable instance.")
 		tableName = flag.String("table", "docindex", "The name of the table containing the documents and index.")
 		port      = flag.Int("port", 8080, "TCP port for server.")
+
+		copyParallelism   = flag.Int("copy_parallelism", 4, "Number of row ranges a copy job processes at once.")
+		copyBatchSize     = flag.Int("copy_batch_size", 100, "Number of rows a copy job writes per batch and between checkpoints.")
+		copyRowsPerSecond = flag.Float64("copy_rows_per_second", 1000, "Maximum rows per second written by copy jobs, or 0 for no limit.")
 	)
 	flag.Parse()
 
@@ -125,13 +152,16 @@ func main() {
 
 	// Open the table.
 	table := client.Open(*tableName)
+	runner := newJobRunner(client, *tableName, *copyParallelism, *copyBatchSize, *copyRowsPerSecond)
 
 	// Set up HTML handlers, and start the web server.
 	http.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) { handleSearch(w, r, table) })
 	http.HandleFunc("/content", func(w http.ResponseWriter, r *http.Request) { handleContent(w, r, table) })
 	http.HandleFunc("/add", func(w http.ResponseWriter, r *http.Request) { handleAddDoc(w, r, table) })
+	http.HandleFunc("/delete", func(w http.ResponseWriter, r *http.Request) { handleDeleteDoc(w, r, table) })
 	http.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) { handleReset(w, r, *tableName, adminClient) })
-	http.HandleFunc("/copy", func(w http.ResponseWriter, r *http.Request) { handleCopy(w, r, *tableName, client, adminClient) })
+	http.HandleFunc("/copy", func(w http.ResponseWriter, r *http.Request) { handleCopy(w, r, runner) })
+	http.HandleFunc("/copy/status", func(w http.ResponseWriter, r *http.Request) { handleCopyStatus(w, r, runner) })
 	http.HandleFunc("/", handleMain)
 	if err := http.ListenAndServe(":"+strconv.Itoa(*port), nil); err != nil {
 		log.Fatal(err)
@@ -143,22 +173,6 @@ func handleMain(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, mainPage)
 }
 
-// tokenize splits a string into tokens.
-// This is very simple, it's not a good tokenization function.
-func tokenize(s string) []string {
-	wordMap := make(map[string]bool)
-	f := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
-	for _, word := range f {
-		word = strings.ToLower(word)
-		wordMap[word] = true
-	}
-	words := make([]string, 0, len(wordMap))
-	for word := range wordMap {
-		words = append(words, word)
-	}
-	return words
-}
-
 // handleContent fetches the content of a document from the Bigtable and returns it.
 func handleContent(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -169,18 +183,17 @@ func handleContent(w http.ResponseWriter, r *http.Request, table *bigtable.Table
 		return
 	}
 
-	row, err := table.ReadRow(ctx, name)
+	content, found, err := readContent(ctx, table, name)
 	if err != nil {
 		http.Error(w, "Error reading content: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	content := row[contentColumnFamily]
-	if len(content) == 0 {
+	if !found || validDocName(name) != nil {
 		http.Error(w, "Document not found.", http.StatusNotFound)
 		return
 	}
 	var buf bytes.Buffer
-	if err := contentTemplate.ExecuteTemplate(&buf, "", struct{ Title, Content string }{name, string(content[0].Value)}); err != nil {
+	if err := contentTemplate.ExecuteTemplate(&buf, "", struct{ Title, Content string }{name, content}); err != nil {
 		http.Error(w, "Error executing HTML template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -192,82 +205,21 @@ func handleSearch(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	query := r.FormValue("q")
-	// Split the query into words.
-	words := tokenize(query)
-	if len(words) == 0 {
-		http.Error(w, "Empty query.", http.StatusBadRequest)
-		return
-	}
-
-	// readRows reads from many rows concurrently.
-	readRows := func(rows []string) ([]bigtable.Row, error) {
-		results := make([]bigtable.Row, len(rows))
-		errors := make([]error, len(rows))
-		var wg sync.WaitGroup
-		for i, row := range rows {
-			wg.Add(1)
-			go func(i int, row string) {
-				defer wg.Done()
-				results[i], errors[i] = table.ReadRow(ctx, row, bigtable.RowFilter(bigtable.LatestNFilter(1)))
-			}(i, row)
-		}
-		wg.Wait()
-		for _, err := range errors {
-			if err != nil {
-				return nil, err
-			}
-		}
-		return results, nil
-	}
-
-	// For each query word, get the list of documents containing it.
-	results, err := readRows(words)
+	q, err := parseQuery(query)
 	if err != nil {
-		http.Error(w, "Error reading index: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	// Count how many of the query words each result contained.
-	hits := make(map[string]int)
-	for _, r := range results {
-		for _, r := range r[indexColumnFamily] {
-			hits[r.Column]++
-		}
-	}
-
-	// Build a slice of all the documents that matched every query word.
-	var matches []string
-	for doc, count := range hits {
-		if count == len(words) {
-			matches = append(matches, doc[len(indexColumnFamily+":"):])
-		}
-	}
-
-	// Fetch the content of those documents from the Bigtable.
-	content, err := readRows(matches)
+	results, err := search(ctx, table, q)
 	if err != nil {
-		http.Error(w, "Error reading results: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Error searching: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	type result struct{ Title, Snippet string }
 	data := struct {
 		Query   string
 		Results []result
-	}{query, nil}
-
-	// Output links and snippets.
-	for i, doc := range matches {
-		var text string
-		c := content[i][contentColumnFamily]
-		if len(c) > 0 {
-			text = string(c[0].Value)
-		}
-		if len(text) > 100 {
-			text = text[:100] + "..."
-		}
-		data.Results = append(data.Results, result{doc, text})
-	}
+	}{query, results}
+
 	var buf bytes.Buffer
 	if err := searchTemplate.ExecuteTemplate(&buf, "", data); err != nil {
 		http.Error(w, "Error executing HTML template: "+err.Error(), http.StatusInternalServerError)
@@ -287,8 +239,8 @@ func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 	defer cancel()
 
 	name := r.FormValue("name")
-	if len(name) == 0 {
-		http.Error(w, "Empty document name!", http.StatusBadRequest)
+	if err := validDocName(name); err != nil {
+		http.Error(w, "Invalid document name: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -298,52 +250,8 @@ func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 		return
 	}
 
-	var (
-		writeErr error          // Set if any write fails.
-		mu       sync.Mutex     // Protects writeErr
-		wg       sync.WaitGroup // Used to wait for all writes to finish.
-	)
-
-	// writeOneColumn writes one column in one row, updates err if there is an error,
-	// and signals wg that one operation has finished.
-	writeOneColumn := func(row, family, column, value string, ts bigtable.Timestamp) {
-		mut := bigtable.NewMutation()
-		mut.Set(family, column, ts, []byte(value))
-		err := table.Apply(ctx, row, mut)
-		if err != nil {
-			mu.Lock()
-			writeErr = err
-			mu.Unlock()
-		}
-	}
-
-	// Start a write to store the document content.
-	wg.Add(1)
-	go func() {
-		writeOneColumn(name, contentColumnFamily, "", content, bigtable.Now())
-		wg.Done()
-	}()
-
-	// Start writes to store the document name in the index for each word in the document.
-	words := tokenize(content)
-	for _, word := range words {
-		var (
-			row    = word
-			family = indexColumnFamily
-			column = name
-			value  = ""
-			ts     = bigtable.Now()
-		)
-		wg.Add(1)
-		go func() {
-			// TODO: should use a semaphore to limit the number of concurrent writes.
-			writeOneColumn(row, family, column, value, ts)
-			wg.Done()
-		}()
-	}
-	wg.Wait()
-	if writeErr != nil {
-		http.Error(w, "Error writing to Bigtable: "+writeErr.Error(), http.StatusInternalServerError)
+	if err := putDocument(ctx, table, name, content); err != nil {
+		http.Error(w, "Error writing to Bigtable: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	var buf bytes.Buffer
@@ -354,6 +262,32 @@ func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 	io.Copy(w, &buf)
 }
 
+// handleDeleteDoc deletes a document and removes it from the index.
+func handleDeleteDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
+	if r.Method != "POST" {
+		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	name := r.FormValue("name")
+	if err := validDocName(name); err != nil {
+		http.Error(w, "Invalid document name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	found, err := deleteDocument(ctx, table, name)
+	if err != nil {
+		http.Error(w, "Error deleting from Bigtable: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Document not found.", http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, "<html><body>Deleted.</body></html>")
+}
+
 // handleReset deletes the table if it exists, creates it again, and creates its column families.
 func handleReset(w http.ResponseWriter, r *http.Request, table string, adminClient *bigtable.AdminClient) {
 	if r.Method != "POST" {
@@ -363,97 +297,78 @@ func handleReset(w http.ResponseWriter, r *http.Request, table string, adminClie
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
+	if err := resetTable(ctx, adminClient, table, 20*time.Second); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("<html><body>Done.</body></html>"))
+	return
+}
+
+// resetTable deletes the table if it exists, creates it again, waits, and
+// creates its column families.
+func resetTable(ctx context.Context, adminClient *bigtable.AdminClient, table string, wait time.Duration) error {
 	adminClient.DeleteTable(ctx, table)
 	if err := adminClient.CreateTable(ctx, table); err != nil {
-		http.Error(w, "Error creating Bigtable: "+err.Error(), http.StatusInternalServerError)
-		return
+		return fmt.Errorf("Error creating Bigtable: %w", err)
 	}
-	time.Sleep(20 * time.Second)
+	time.Sleep(wait)
 	// Create two column families, and set the GC policy for each one to keep one version.
 	for _, family := range []string{indexColumnFamily, contentColumnFamily} {
 		if err := adminClient.CreateColumnFamily(ctx, table, family); err != nil {
-			http.Error(w, "Error creating column family: "+err.Error(), http.StatusInternalServerError)
-			return
+			return fmt.Errorf("Error creating column family: %w", err)
 		}
 		if err := adminClient.SetGCPolicy(ctx, table, family, bigtable.MaxVersionsPolicy(1)); err != nil {
-			http.Error(w, "Error setting GC policy: "+err.Error(), http.StatusInternalServerError)
-			return
+			return fmt.Errorf("Error setting GC policy: %w", err)
 		}
 	}
-	w.Write([]byte("<html><body>Done.</body></html>"))
-	return
+	return nil
 }
 
-// copyTable copies data from one table to another.
-func copyTable(src, dst string, client *bigtable.Client, adminClient *bigtable.AdminClient) error {
-	if src == "" || src == dst {
-		return nil
+// handleCopy starts a job copying, reindexing or comparing another table
+// into this one.
+func handleCopy(w http.ResponseWriter, r *http.Request, runner *jobRunner) {
+	if r.Method != "POST" {
+		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
+		return
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
-	// Open the source and destination tables.
-	srcTable := client.Open(src)
-	dstTable := client.Open(dst)
-
-	var (
-		writeErr error          // Set if any write fails.
-		mu       sync.Mutex     // Protects writeErr
-		wg       sync.WaitGroup // Used to wait for all writes to finish.
-	)
-	copyRowToTable := func(row bigtable.Row) bool {
-		mu.Lock()
-		failed := writeErr != nil
-		mu.Unlock()
-		if failed {
-			return false
-		}
-		mut := bigtable.NewMutation()
-		for family, items := range row {
-			for _, item := range items {
-				// Get the column name, excluding the column family name and ':' character.
-				columnWithoutFamily := item.Column[len(family)+1:]
-				mut.Set(family, columnWithoutFamily, bigtable.Now(), item.Value)
-			}
-		}
-		wg.Add(1)
-		go func() {
-			// TODO: should use a semaphore to limit the number of concurrent writes.
-			if err := dstTable.Apply(ctx, row.Key(), mut); err != nil {
-				mu.Lock()
-				writeErr = err
-				mu.Unlock()
-			}
-			wg.Done()
-		}()
-		return true
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = modeCopy
 	}
-
-	// Create a filter that only accepts the column families we're interested in.
-	filter := bigtable.FamilyFilter(indexColumnFamily + "|" + contentColumnFamily)
-	// Read every row from srcTable, and call copyRowToTable to copy it to our table.
-	err := srcTable.ReadRows(ctx, bigtable.InfiniteRange(""), copyRowToTable, bigtable.RowFilter(filter))
-	wg.Wait()
+	src, id := r.FormValue("name"), r.FormValue("id")
+	if src == "" && id == "" {
+		http.Error(w, "No source table or job to resume specified.", http.StatusBadRequest)
+		return
+	}
+	j, err := runner.start(ctx, id, src, mode)
 	if err != nil {
-		return err
+		http.Error(w, "Failed to start job: "+err.Error(), http.StatusBadRequest)
+		return
 	}
-	return writeErr
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "<html><body>Started job %[1]s. <a href=\"/copy/status?id=%[1]s\">Progress</a></body></html>", template.URLQueryEscaper(j.ID))
 }
 
-// handleCopy copies data from one table to another.
-func handleCopy(w http.ResponseWriter, r *http.Request, dst string, client *bigtable.Client, adminClient *bigtable.AdminClient) {
-	if r.Method != "POST" {
-		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
-		return
-	}
-	src := r.FormValue("name")
-	if src == "" {
-		http.Error(w, "No source table specified.", http.StatusBadRequest)
-		return
+// handleCopyStatus returns the progress of a job, or of all jobs, as JSON.
+func handleCopyStatus(w http.ResponseWriter, r *http.Request, runner *jobRunner) {
+	var v any = runner.list()
+	if id := r.FormValue("id"); id != "" {
+		j := runner.get(id)
+		if j == nil {
+			http.Error(w, "Job not found.", http.StatusNotFound)
+			return
+		}
+		v = j
 	}
-	if err := copyTable(src, dst, client, adminClient); err != nil {
-		http.Error(w, "Failed to rebuild index: "+err.Error(), http.StatusInternalServerError)
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "Error encoding job: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	fmt.Fprint(w, "Copied table.\n")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
 }
@@ -0,0 +1,269 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const testTable = "docindex"
+
+// newTestTable returns a table with the search column families on an
+// in-memory Bigtable server.
+func newTestTable(t *testing.T) (*bigtable.Client, *bigtable.AdminClient, *bigtable.Table) {
+	t.Helper()
+	ctx := context.Background()
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	adminClient, err := bigtable.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewAdminClient: %v", err)
+	}
+	client, err := bigtable.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := resetTable(ctx, adminClient, testTable, 0); err != nil {
+		t.Fatalf("resetTable: %v", err)
+	}
+	return client, adminClient, client.Open(testTable)
+}
+
+var testDocs = map[string]string{
+	"families": "A column family groups columns. Each column family has a garbage collection policy.",
+	"rows":     "Rows are sorted by row key. Design the row key so reads scan few rows.",
+	"mixed":    "Bigtable stores rows. The family of a column is chosen when writing.",
+	"gc":       "Garbage collection removes old cells. Policies combine max age and max versions.",
+}
+
+func putTestDocs(t *testing.T, table *bigtable.Table) {
+	t.Helper()
+	for name, content := range testDocs {
+		if err := putDocument(context.Background(), table, name, content); err != nil {
+			t.Fatalf("putDocument(%q): %v", name, err)
+		}
+	}
+}
+
+func titles(results []result) []string {
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Title)
+	}
+	return got
+}
+
+func TestParseQuery(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want [][]clause
+	}{
+		{"Column", [][]clause{{{"column"}}}},
+		{"row key", [][]clause{{{"row"}}, {{"key"}}}},
+		{"row OR column family", [][]clause{{{"row"}, {"column"}}, {{"family"}}}},
+		{`"column family" OR rows gc`, [][]clause{{{"column", "family"}, {"rows"}}, {{"gc"}}}},
+		{"OR row OR", [][]clause{{{"row"}}}},
+		{"max-age", [][]clause{{{"max", "age"}}}},
+	} {
+		q, err := parseQuery(tc.in)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %v", tc.in, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, q.groups); diff != "" {
+			t.Errorf("parseQuery(%q) mismatch (-want +got):\n%s", tc.in, diff)
+		}
+	}
+	for _, in := range []string{"", "  OR ", `"unterminated`, "123 !!"} {
+		if q, err := parseQuery(in); err == nil {
+			t.Errorf("parseQuery(%q) = %v, want error", in, q)
+		}
+	}
+}
+
+func TestPostingEncoding(t *testing.T) {
+	p := posting{docLen: 300, positions: []int{0, 7, 200, 299}}
+	got, err := decodePosting(p.encode())
+	if err != nil {
+		t.Fatalf("decodePosting: %v", err)
+	}
+	if diff := cmp.Diff(p, got, cmp.AllowUnexported(posting{})); diff != "" {
+		t.Errorf("decodePosting mismatch (-want +got):\n%s", diff)
+	}
+	for _, b := range [][]byte{nil, {}, {5, 3, 1}, {5, 1, 1, 1}} {
+		if _, err := decodePosting(b); err == nil {
+			t.Errorf("decodePosting(%v) got nil error, want error", b)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("filler ", 50) + "the <row> key matters " + strings.Repeat("more ", 50)
+	got := string(snippet(text, map[string]bool{"row": true, "key": true}))
+	if !strings.Contains(got, "<b>row</b>&gt; <b>key</b>") {
+		t.Errorf("snippet doesn't highlight the hits: %s", got)
+	}
+	if !strings.HasPrefix(got, "&hellip;") || !strings.HasSuffix(got, "&hellip;") {
+		t.Errorf("snippet of a longer text isn't elided: %s", got)
+	}
+	plain := html.UnescapeString(strings.NewReplacer("<b>", "", "</b>", "").Replace(got))
+	if n := len(tokens(plain)); n != snippetWords {
+		t.Errorf("snippet has %d words, want %d", n, snippetWords)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	_, _, table := newTestTable(t)
+	putTestDocs(t, table)
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		// "families" mentions column family twice, so it ranks first.
+		{"column family", []string{"families", "mixed"}},
+		{`"column family"`, []string{"families"}},
+		{"garbage", []string{"gc", "families"}},
+		// Both match one rare word; the shorter document ranks first.
+		{"bigtable OR policy", []string{"mixed", "families"}},
+		{`"row key" OR "max age"`, []string{"rows", "gc"}},
+		{"rows scan", []string{"rows"}},
+		{"spanner", nil},
+	} {
+		q, err := parseQuery(tc.query)
+		if err != nil {
+			t.Fatalf("parseQuery(%q): %v", tc.query, err)
+		}
+		results, err := search(ctx, table, q)
+		if err != nil {
+			t.Fatalf("search(%q): %v", tc.query, err)
+		}
+		if got := titles(results); !cmp.Equal(got, tc.want) {
+			t.Errorf("search(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+		for _, r := range results {
+			if r.Score <= 0 || !strings.Contains(string(r.Snippet), "<b>") {
+				t.Errorf("search(%q): result %+v has no score or highlights", tc.query, r)
+			}
+		}
+	}
+}
+
+func TestReplaceAndDelete(t *testing.T) {
+	ctx := context.Background()
+	_, _, table := newTestTable(t)
+	putTestDocs(t, table)
+
+	searchTitles := func(query string) []string {
+		t.Helper()
+		q, err := parseQuery(query)
+		if err != nil {
+			t.Fatalf("parseQuery(%q): %v", query, err)
+		}
+		results, err := search(ctx, table, q)
+		if err != nil {
+			t.Fatalf("search(%q): %v", query, err)
+		}
+		return titles(results)
+	}
+
+	if err := putDocument(ctx, table, "rows", "Tablets split when they grow."); err != nil {
+		t.Fatalf("putDocument: %v", err)
+	}
+	if got := searchTitles("key"); got != nil {
+		t.Errorf("after replacing rows, search(key) = %v, want nothing", got)
+	}
+	if got := searchTitles("tablets"); !cmp.Equal(got, []string{"rows"}) {
+		t.Errorf("after replacing rows, search(tablets) = %v, want [rows]", got)
+	}
+
+	found, err := deleteDocument(ctx, table, "mixed")
+	if err != nil || !found {
+		t.Fatalf("deleteDocument = %v, %v, want true, nil", found, err)
+	}
+	if got := searchTitles("family"); !cmp.Equal(got, []string{"families"}) {
+		t.Errorf("after deleting mixed, search(family) = %v, want [families]", got)
+	}
+	if found, err := deleteDocument(ctx, table, "mixed"); err != nil || found {
+		t.Errorf("deleting again = %v, %v, want false, nil", found, err)
+	}
+
+	stats, err := readStats(ctx, table)
+	if err != nil {
+		t.Fatalf("readStats: %v", err)
+	}
+	wantWords := 0
+	for _, content := range []string{testDocs["families"], testDocs["gc"], "Tablets split when they grow."} {
+		wantWords += len(tokens(content))
+	}
+	if stats != (corpusStats{docs: 3, words: int64(wantWords)}) {
+		t.Errorf("readStats = %+v, want 3 docs and %d words", stats, wantWords)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	_, _, table := newTestTable(t)
+
+	post := func(h func(http.ResponseWriter, *http.Request, *bigtable.Table), form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h(rr, r, table)
+		return rr
+	}
+
+	if rr := post(handleAddDoc, url.Values{"name": {"#stats"}, "content": {"x"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("adding a reserved name got %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if rr := post(handleAddDoc, url.Values{"name": {"doc"}, "content": {"Hello <world>"}}); rr.Code != http.StatusOK {
+		t.Fatalf("add got %d: %s", rr.Code, rr.Body)
+	}
+	rr := post(handleSearch, url.Values{"q": {"world"}})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Hello &lt;<b>world</b>&gt;") {
+		t.Errorf("search got %d:\n%s", rr.Code, rr.Body)
+	}
+	if rr := post(handleSearch, url.Values{"q": {`"world`}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid search got %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if rr := post(handleDeleteDoc, url.Values{"name": {"doc"}}); rr.Code != http.StatusOK {
+		t.Errorf("delete got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := post(handleDeleteDoc, url.Values{"name": {"doc"}}); rr.Code != http.StatusNotFound {
+		t.Errorf("second delete got %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/idtoken"
+)
+
+var errUnauthenticated = errors.New("no identity in request")
+
+// identifier finds the email address of the user making a request.
+type identifier struct {
+	// iapAudience is the audience of the JWTs Identity-Aware Proxy adds to
+	// requests, e.g. /projects/PROJECT_NUMBER/global/backendServices/SERVICE_ID.
+	iapAudience string
+	// oidcAudience is the audience of OIDC ID tokens sent as bearer tokens,
+	// e.g. the URL of the Cloud Run service.
+	oidcAudience string
+	// devEmail, if set, is the identity of requests without any token. Only
+	// use it for local development.
+	devEmail string
+	// validate validates an ID token. It defaults to idtoken.Validate.
+	validate func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+}
+
+// fromIAP reports whether the identity came from Identity-Aware Proxy.
+func (id *identifier) fromIAP(r *http.Request) bool {
+	return id.iapAudience != "" && r.Header.Get("X-Goog-IAP-JWT-Assertion") != ""
+}
+
+// email returns the verified email address of the user making r. The
+// IAP headers are only trusted when their signed JWT is valid:
+// X-Goog-Authenticated-User-Email alone can be forged by a client that
+// bypasses IAP.
+func (id *identifier) email(r *http.Request) (string, error) {
+	validate := id.validate
+	if validate == nil {
+		validate = idtoken.Validate
+	}
+	token, audience := "", ""
+	switch {
+	case id.fromIAP(r):
+		token, audience = r.Header.Get("X-Goog-IAP-JWT-Assertion"), id.iapAudience
+	case id.oidcAudience != "" && strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "):
+		token, audience = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), id.oidcAudience
+	case id.devEmail != "":
+		return id.devEmail, nil
+	default:
+		return "", errUnauthenticated
+	}
+
+	payload, err := validate(r.Context(), token, audience)
+	if err != nil {
+		return "", fmt.Errorf("idtoken.Validate: %w", err)
+	}
+	email, _ := payload.Claims["email"].(string)
+	if email == "" {
+		return "", errors.New("token has no email claim")
+	}
+	// IAP tokens only carry verified addresses, and don't have the claim.
+	if verified, ok := payload.Claims["email_verified"].(bool); ok && !verified {
+		return "", fmt.Errorf("email %s isn't verified", email)
+	}
+	return email, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/idtoken"
+)
+
+// fakeValidate accepts tokens of the form "<audience>|<email>", and marks
+// "unverified@example.com" as unverified.
+func fakeValidate(_ context.Context, token, audience string) (*idtoken.Payload, error) {
+	for i := 0; i < len(token); i++ {
+		if token[i] != '|' {
+			continue
+		}
+		if token[:i] != audience {
+			return nil, errors.New("wrong audience")
+		}
+		claims := map[string]interface{}{"email": token[i+1:]}
+		if token[i+1:] == "unverified@example.com" {
+			claims["email_verified"] = false
+		}
+		return &idtoken.Payload{Audience: audience, Claims: claims}, nil
+	}
+	return nil, errors.New("malformed token")
+}
+
+func TestEmail(t *testing.T) {
+	id := &identifier{
+		iapAudience:  "/projects/1/global/backendServices/2",
+		oidcAudience: "https://usercounter.example.com",
+		validate:     fakeValidate,
+	}
+
+	tests := []struct {
+		name    string
+		id      *identifier
+		headers map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "iap",
+			id:      id,
+			headers: map[string]string{"X-Goog-IAP-JWT-Assertion": "/projects/1/global/backendServices/2|iap@example.com"},
+			want:    "iap@example.com",
+		},
+		{
+			name:    "iap wrong audience",
+			id:      id,
+			headers: map[string]string{"X-Goog-IAP-JWT-Assertion": "other|iap@example.com"},
+			wantErr: true,
+		},
+		{
+			name: "iap over bearer",
+			id:   id,
+			headers: map[string]string{
+				"X-Goog-IAP-JWT-Assertion": "/projects/1/global/backendServices/2|iap@example.com",
+				"Authorization":            "Bearer https://usercounter.example.com|oidc@example.com",
+			},
+			want: "iap@example.com",
+		},
+		{
+			name:    "bearer",
+			id:      id,
+			headers: map[string]string{"Authorization": "Bearer https://usercounter.example.com|oidc@example.com"},
+			want:    "oidc@example.com",
+		},
+		{
+			name:    "bearer unverified",
+			id:      id,
+			headers: map[string]string{"Authorization": "Bearer https://usercounter.example.com|unverified@example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "bearer no email",
+			id:      id,
+			headers: map[string]string{"Authorization": "Bearer https://usercounter.example.com|"},
+			wantErr: true,
+		},
+		{
+			name:    "forged iap email header",
+			id:      id,
+			headers: map[string]string{"X-Goog-Authenticated-User-Email": "accounts.google.com:evil@example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "iap disabled",
+			id:      &identifier{oidcAudience: id.oidcAudience, validate: fakeValidate},
+			headers: map[string]string{"X-Goog-IAP-JWT-Assertion": "|iap@example.com"},
+			wantErr: true,
+		},
+		{
+			name: "dev",
+			id:   &identifier{devEmail: "dev@example.com", validate: fakeValidate},
+			want: "dev@example.com",
+		},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+		got, err := tc.id.email(r)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: email = %q, want error", tc.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: email = %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+}
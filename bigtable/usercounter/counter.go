@@ -0,0 +1,218 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+)
+
+// The table has three kinds of rows:
+//
+//	user#<email>               family total, all-time visits
+//	hour#<YYYYMMDDHH>#<email>  family hourly, visits in that hour (UTC)
+//	day#<YYYYMMDD>#<email>     family daily, visits on that day (UTC)
+//
+// Bucket rows start with the bucket, so the visits of all users in a time
+// window are one contiguous row range, read by a single scan for the top-N
+// report. Garbage collection drops old buckets.
+const (
+	tableName    = "user-visit-counter"
+	totalFamily  = "total"
+	visitsColumn = "visits"
+)
+
+// A granularity is a size of time buckets.
+type granularity struct {
+	// name prefixes the row keys, and names the granularity in URLs.
+	name   string
+	family string
+	// layout formats the start of a bucket. Formatted buckets sort by time.
+	layout string
+	step   time.Duration
+	// gc drops cells older than the buckets worth keeping. MaxVersionsPolicy
+	// drops the older versions every increment leaves behind.
+	gc bigtable.GCPolicy
+}
+
+var (
+	hourly = granularity{
+		name:   "hour",
+		family: "hourly",
+		layout: "2006010215",
+		step:   time.Hour,
+		gc:     bigtable.UnionPolicy(bigtable.MaxVersionsPolicy(1), bigtable.MaxAgePolicy(7*24*time.Hour)),
+	}
+	daily = granularity{
+		name:   "day",
+		family: "daily",
+		layout: "20060102",
+		step:   24 * time.Hour,
+		gc:     bigtable.UnionPolicy(bigtable.MaxVersionsPolicy(1), bigtable.MaxAgePolicy(90*24*time.Hour)),
+	}
+	granularities = []granularity{hourly, daily}
+)
+
+// bucketPrefix returns the row key prefix of the bucket containing t.
+func (g granularity) bucketPrefix(t time.Time) string {
+	return g.name + "#" + t.UTC().Truncate(g.step).Format(g.layout) + "#"
+}
+
+// setupTable creates the table and column families, and sets the GC policies.
+func setupTable(ctx context.Context, adminClient *bigtable.AdminClient) error {
+	tables, err := adminClient.Tables(ctx)
+	if err != nil {
+		return fmt.Errorf("Tables: %w", err)
+	}
+	if !sliceContains(tables, tableName) {
+		if err := adminClient.CreateTable(ctx, tableName); err != nil {
+			return fmt.Errorf("CreateTable(%s): %w", tableName, err)
+		}
+	}
+	tblInfo, err := adminClient.TableInfo(ctx, tableName)
+	if err != nil {
+		return fmt.Errorf("TableInfo(%s): %w", tableName, err)
+	}
+	policies := map[string]bigtable.GCPolicy{totalFamily: bigtable.MaxVersionsPolicy(1)}
+	for _, g := range granularities {
+		policies[g.family] = g.gc
+	}
+	for family, policy := range policies {
+		if !sliceContains(tblInfo.Families, family) {
+			if err := adminClient.CreateColumnFamily(ctx, tableName, family); err != nil {
+				return fmt.Errorf("CreateColumnFamily(%s): %w", family, err)
+			}
+		}
+		if err := adminClient.SetGCPolicy(ctx, tableName, family, policy); err != nil {
+			return fmt.Errorf("SetGCPolicy(%s): %w", policy, err)
+		}
+	}
+	return nil
+}
+
+// visits are the visit counts of a user.
+type visits struct {
+	Total, ThisHour, Today int64
+}
+
+// counter counts visits in a table.
+type counter struct {
+	table *bigtable.Table
+	// now returns the current time. It defaults to time.Now.
+	now func() time.Time
+}
+
+func (c *counter) time() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// increment adds one to the visits column of row, and returns the new count.
+func (c *counter) increment(ctx context.Context, row, family string) (int64, error) {
+	rmw := bigtable.NewReadModifyWrite()
+	rmw.Increment(family, visitsColumn, 1)
+	r, err := c.table.ApplyReadModifyWrite(ctx, row, rmw)
+	if err != nil {
+		return 0, fmt.Errorf("ApplyReadModifyWrite(%q): %w", row, err)
+	}
+	return decodeCount(r[family][0].Value), nil
+}
+
+// recordVisit counts a visit of email, and returns the updated counts.
+func (c *counter) recordVisit(ctx context.Context, email string) (visits, error) {
+	now := c.time()
+	var v visits
+	var err error
+	if v.Total, err = c.increment(ctx, "user#"+email, totalFamily); err != nil {
+		return visits{}, err
+	}
+	if v.ThisHour, err = c.increment(ctx, hourly.bucketPrefix(now)+email, hourly.family); err != nil {
+		return visits{}, err
+	}
+	if v.Today, err = c.increment(ctx, daily.bucketPrefix(now)+email, daily.family); err != nil {
+		return visits{}, err
+	}
+	return v, nil
+}
+
+// A userCount is the number of visits of a user.
+type userCount struct {
+	Email  string
+	Visits int64
+}
+
+// topUsers returns the n users with the most visits in the last buckets
+// buckets of g, including the current one, most visits first.
+func (c *counter) topUsers(ctx context.Context, g granularity, buckets, n int) ([]userCount, error) {
+	now := c.time()
+	start := g.bucketPrefix(now.Add(-time.Duration(buckets-1) * g.step))
+	end := g.bucketPrefix(now.Add(g.step))
+
+	sums := make(map[string]int64)
+	err := c.table.ReadRows(ctx, bigtable.NewRange(start, end), func(row bigtable.Row) bool {
+		parts := strings.SplitN(row.Key(), "#", 3)
+		if cells := row[g.family]; len(parts) == 3 && len(cells) > 0 {
+			sums[parts[2]] += decodeCount(cells[0].Value)
+		}
+		return true
+	}, bigtable.RowFilter(bigtable.ChainFilters(
+		bigtable.FamilyFilter(g.family),
+		bigtable.LatestNFilter(1),
+	)))
+	if err != nil {
+		return nil, fmt.Errorf("ReadRows: %w", err)
+	}
+
+	top := make([]userCount, 0, len(sums))
+	for email, v := range sums {
+		top = append(top, userCount{email, v})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Visits != top[j].Visits {
+			return top[i].Visits > top[j].Visits
+		}
+		return top[i].Email < top[j].Email
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top, nil
+}
+
+// decodeCount decodes a counter cell, a big-endian 64-bit integer.
+func decodeCount(b []byte) int64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// sliceContains reports whether the provided string is present in the given slice of strings.
+func sliceContains(list []string, target string) bool {
+	for _, s := range list {
+		if s == target {
+			return true
+		}
+	}
+	return false
+}
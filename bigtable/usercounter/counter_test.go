@@ -0,0 +1,202 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestCounter returns a counter on an in-memory Bigtable server, and a
+// pointer to the time it uses.
+func newTestCounter(t *testing.T) (*counter, *time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	adminClient, err := bigtable.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewAdminClient: %v", err)
+	}
+	if err := setupTable(ctx, adminClient); err != nil {
+		t.Fatalf("setupTable: %v", err)
+	}
+	// Setting up an existing table is a no-op.
+	if err := setupTable(ctx, adminClient); err != nil {
+		t.Fatalf("setupTable again: %v", err)
+	}
+	info, err := adminClient.TableInfo(ctx, tableName)
+	if err != nil {
+		t.Fatalf("TableInfo: %v", err)
+	}
+	sort.Strings(info.Families)
+	if want := []string{"daily", "hourly", "total"}; !cmp.Equal(info.Families, want) {
+		t.Fatalf("families = %v, want %v", info.Families, want)
+	}
+
+	client, err := bigtable.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	now := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	return &counter{table: client.Open(tableName), now: func() time.Time { return now }}, &now
+}
+
+func TestRecordVisit(t *testing.T) {
+	ctx := context.Background()
+	c, now := newTestCounter(t)
+
+	visit := func(email string) visits {
+		t.Helper()
+		v, err := c.recordVisit(ctx, email)
+		if err != nil {
+			t.Fatalf("recordVisit(%q): %v", email, err)
+		}
+		return v
+	}
+
+	visit("a@example.com")
+	if got, want := visit("a@example.com"), (visits{Total: 2, ThisHour: 2, Today: 2}); got != want {
+		t.Errorf("second visit = %+v, want %+v", got, want)
+	}
+	*now = now.Add(time.Hour)
+	if got, want := visit("a@example.com"), (visits{Total: 3, ThisHour: 1, Today: 3}); got != want {
+		t.Errorf("visit next hour = %+v, want %+v", got, want)
+	}
+	*now = now.Add(24 * time.Hour)
+	if got, want := visit("a@example.com"), (visits{Total: 4, ThisHour: 1, Today: 1}); got != want {
+		t.Errorf("visit next day = %+v, want %+v", got, want)
+	}
+	if got, want := visit("b@example.com"), (visits{Total: 1, ThisHour: 1, Today: 1}); got != want {
+		t.Errorf("other user's visit = %+v, want %+v", got, want)
+	}
+}
+
+func TestBucketPrefix(t *testing.T) {
+	// Buckets are in UTC whatever the time zone of the time.
+	ts := time.Date(2026, 1, 2, 1, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	if got, want := hourly.bucketPrefix(ts), "hour#2026010123#"; got != want {
+		t.Errorf("hourly.bucketPrefix = %q, want %q", got, want)
+	}
+	if got, want := daily.bucketPrefix(ts), "day#20260101#"; got != want {
+		t.Errorf("daily.bucketPrefix = %q, want %q", got, want)
+	}
+}
+
+func TestTopUsers(t *testing.T) {
+	ctx := context.Background()
+	c, now := newTestCounter(t)
+	start := *now
+
+	visits := []struct {
+		ago   time.Duration
+		email string
+		n     int
+	}{
+		{0, "a@example.com", 1},
+		{0, "b@example.com", 2},
+		{time.Hour, "a@example.com", 3},
+		{3 * time.Hour, "c@example.com", 5},
+		{48 * time.Hour, "d@example.com", 10},
+	}
+	for _, v := range visits {
+		*now = start.Add(-v.ago)
+		for i := 0; i < v.n; i++ {
+			if _, err := c.recordVisit(ctx, v.email); err != nil {
+				t.Fatalf("recordVisit: %v", err)
+			}
+		}
+	}
+	*now = start
+
+	tests := []struct {
+		g       granularity
+		buckets int
+		n       int
+		want    []userCount
+	}{
+		{hourly, 1, 10, []userCount{{"b@example.com", 2}, {"a@example.com", 1}}},
+		{hourly, 2, 10, []userCount{{"a@example.com", 4}, {"b@example.com", 2}}},
+		{hourly, 4, 2, []userCount{{"c@example.com", 5}, {"a@example.com", 4}}},
+		{daily, 1, 10, []userCount{{"c@example.com", 5}, {"a@example.com", 4}, {"b@example.com", 2}}},
+		{daily, 3, 1, []userCount{{"d@example.com", 10}}},
+	}
+	for _, tc := range tests {
+		got, err := c.topUsers(ctx, tc.g, tc.buckets, tc.n)
+		if err != nil {
+			t.Fatalf("topUsers(%s, %d, %d): %v", tc.g.name, tc.buckets, tc.n, err)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("topUsers(%s, %d, %d) mismatch (-want +got):\n%s", tc.g.name, tc.buckets, tc.n, diff)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	c, _ := newTestCounter(t)
+	a := &app{counter: c, id: &identifier{devEmail: "dev@example.com"}}
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	get := func(path string, wantCode int) string {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		if resp.StatusCode != wantCode {
+			t.Fatalf("GET %s = %d, want %d", path, resp.StatusCode, wantCode)
+		}
+		return string(body)
+	}
+
+	get("/", http.StatusOK)
+	if body := get("/", http.StatusOK); !strings.Contains(body, "visited 2 times") {
+		t.Errorf("GET / = %q, want 2 visits", body)
+	}
+	if body := get("/top?window=day&last=1", http.StatusOK); !strings.Contains(body, "dev@example.com: 2") {
+		t.Errorf("GET /top = %q, want dev@example.com with 2 visits", body)
+	}
+	get("/top?window=week", http.StatusBadRequest)
+	get("/top?n=0", http.StatusBadRequest)
+	get("/missing", http.StatusNotFound)
+}
@@ -15,7 +15,8 @@
 /*
 User counter is a program that tracks how often a user has visited the index page.
 
-This program demonstrates usage of the Cloud Bigtable API for App Engine flexible environment and Go.
+This program demonstrates usage of the Cloud Bigtable API on Cloud Run and Go.
+Users are identified by Identity-Aware Proxy or by OIDC ID tokens.
 Instructions for running this program are in the README.md.
 */
 package main
@@ -23,109 +24,111 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 
 	"cloud.google.com/go/bigtable"
-	"google.golang.org/appengine"
-	aelog "google.golang.org/appengine/log"
-	"google.golang.org/appengine/user"
 )
 
-// User-provided constants.
-const (
-	project  = "PROJECT_ID"
-	instance = "INSTANCE"
-)
-
-var (
-	tableName  = "user-visit-counter"
-	familyName = "emails"
-
-	// Client is initialized by main.
-	client *bigtable.Client
-)
+type app struct {
+	counter *counter
+	id      *identifier
+}
 
 func main() {
 	ctx := context.Background()
 
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	instance := os.Getenv("BIGTABLE_INSTANCE")
+	if project == "" || instance == "" {
+		log.Fatal("GOOGLE_CLOUD_PROJECT and BIGTABLE_INSTANCE must be set")
+	}
+
 	// Set up admin client, tables, and column families.
 	// NewAdminClient uses Application Default Credentials to authenticate.
 	adminClient, err := bigtable.NewAdminClient(ctx, project, instance)
 	if err != nil {
 		log.Fatalf("Unable to create a table admin client. %v", err)
 	}
-	tables, err := adminClient.Tables(ctx)
-	if err != nil {
-		log.Fatalf("Unable to fetch table list. %v", err)
-	}
-	if !sliceContains(tables, tableName) {
-		if err := adminClient.CreateTable(ctx, tableName); err != nil {
-			log.Fatalf("Unable to create table: %v. %v", tableName, err)
-		}
-	}
-	tblInfo, err := adminClient.TableInfo(ctx, tableName)
-	if err != nil {
-		log.Fatalf("Unable to read info for table: %v. %v", tableName, err)
-	}
-	if !sliceContains(tblInfo.Families, familyName) {
-		if err := adminClient.CreateColumnFamily(ctx, tableName, familyName); err != nil {
-			log.Fatalf("Unable to create column family: %v. %v", familyName, err)
-		}
+	if err := setupTable(ctx, adminClient); err != nil {
+		log.Fatalf("Unable to set up table: %v", err)
 	}
 	adminClient.Close()
 
 	// Set up Bigtable data operations client.
 	// NewClient uses Application Default Credentials to authenticate.
-	client, err = bigtable.NewClient(ctx, project, instance)
+	client, err := bigtable.NewClient(ctx, project, instance)
 	if err != nil {
 		log.Fatalf("Unable to create data operations client. %v", err)
 	}
+	defer client.Close()
+
+	a := &app{
+		counter: &counter{table: client.Open(tableName)},
+		id: &identifier{
+			iapAudience:  os.Getenv("IAP_AUDIENCE"),
+			oidcAudience: os.Getenv("OIDC_AUDIENCE"),
+			devEmail:     os.Getenv("DEV_USER_EMAIL"),
+		},
+	}
+	if a.id.iapAudience == "" && a.id.oidcAudience == "" && a.id.devEmail == "" {
+		log.Fatal("One of IAP_AUDIENCE, OIDC_AUDIENCE or DEV_USER_EMAIL must be set")
+	}
 
-	http.Handle("/", appHandler(mainHandler))
-	appengine.Main() // Never returns.
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Printf("Listening on port %s", port)
+	log.Fatal(http.ListenAndServe(":"+port, a.handler()))
+}
+
+func (a *app) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", appHandler(a.mainHandler))
+	mux.Handle("/top", appHandler(a.topHandler))
+	return mux
+}
+
+// user returns the email address of the user making r, or an appError
+// asking them to sign in.
+func (a *app) user(r *http.Request) (string, *appError) {
+	email, err := a.id.email(r)
+	if err != nil {
+		return "", &appError{err, "Sign in to continue", http.StatusUnauthorized}
+	}
+	return email, nil
 }
 
 // mainHandler tracks how many times each user has visited this page.
-func mainHandler(w http.ResponseWriter, r *http.Request) *appError {
+func (a *app) mainHandler(w http.ResponseWriter, r *http.Request) *appError {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return nil
 	}
-
-	ctx := appengine.NewContext(r)
-	u := user.Current(ctx)
-	if u == nil {
-		login, err := user.LoginURL(ctx, r.URL.String())
-		if err != nil {
-			return &appError{err, "Error finding login URL", http.StatusInternalServerError}
-		}
-		http.Redirect(w, r, login, http.StatusFound)
-		return nil
-	}
-	logoutURL, err := user.LogoutURL(ctx, "/")
-	if err != nil {
-		return &appError{err, "Error finding logout URL", http.StatusInternalServerError}
+	email, aerr := a.user(r)
+	if aerr != nil {
+		return aerr
 	}
 
-	// Increment visit count for user.
-	tbl := client.Open(tableName)
-	rmw := bigtable.NewReadModifyWrite()
-	rmw.Increment(familyName, u.Email, 1)
-	row, err := tbl.ApplyReadModifyWrite(ctx, u.Email, rmw)
+	v, err := a.counter.recordVisit(r.Context(), email)
 	if err != nil {
-		return &appError{err, "Error applying ReadModifyWrite to row: " + u.Email, http.StatusInternalServerError}
+		return &appError{err, "Error counting visit for " + email, http.StatusInternalServerError}
 	}
 	data := struct {
 		Username, Logout string
-		Visits           uint64
+		Visits           visits
 	}{
-		Username: u.Email,
-		// Retrieve the most recently edited column.
-		Visits: binary.BigEndian.Uint64(row[familyName][0].Value),
-		Logout: logoutURL,
+		Username: email,
+		Visits:   v,
+	}
+	// IAP clears its session cookie when given this parameter.
+	if a.id.fromIAP(r) {
+		data.Logout = "/?gcp-iap-mode=CLEAR_LOGIN_COOKIE"
 	}
 
 	// Display hello page.
@@ -137,6 +140,59 @@ func mainHandler(w http.ResponseWriter, r *http.Request) *appError {
 	return nil
 }
 
+// topHandler shows the users with the most visits in a recent window, e.g.
+// /top?window=day&last=7&n=10 for the top 10 users of the last 7 days.
+func (a *app) topHandler(w http.ResponseWriter, r *http.Request) *appError {
+	if _, aerr := a.user(r); aerr != nil {
+		return aerr
+	}
+	g := hourly
+	switch window := r.FormValue("window"); window {
+	case "", hourly.name:
+	case daily.name:
+		g = daily
+	default:
+		return &appError{errors.New("bad window " + window), "window must be hour or day", http.StatusBadRequest}
+	}
+	last, err := intParam(r, "last", 24, 1, 24*7)
+	if err != nil {
+		return &appError{err, err.Error(), http.StatusBadRequest}
+	}
+	n, err := intParam(r, "n", 10, 1, 100)
+	if err != nil {
+		return &appError{err, err.Error(), http.StatusBadRequest}
+	}
+
+	top, err := a.counter.topUsers(r.Context(), g, last, n)
+	if err != nil {
+		return &appError{err, "Error reading top users", http.StatusInternalServerError}
+	}
+	data := struct {
+		Window string
+		Last   int
+		Top    []userCount
+	}{g.name, last, top}
+	var buf bytes.Buffer
+	if err := topTmpl.Execute(&buf, data); err != nil {
+		return &appError{err, "Error writing template", http.StatusInternalServerError}
+	}
+	buf.WriteTo(w)
+	return nil
+}
+
+// intParam parses the form value name, which must be between lo and hi.
+func intParam(r *http.Request, name string, def, lo, hi int) (int, error) {
+	s := r.FormValue(name)
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, errors.New(name + " must be a number from " + strconv.Itoa(lo) + " to " + strconv.Itoa(hi))
+	}
+	return v, nil
+}
+
 var tmpl = template.Must(template.New("").Parse(`
 <html><body>
 
@@ -147,20 +203,25 @@ var tmpl = template.Must(template.New("").Parse(`
 </p>
 
 <p>
-You have visited {{.Visits}}
+You have visited {{.Visits.Total}} times, {{.Visits.ThisHour}} this hour and {{.Visits.Today}} today.
 </p>
 
+<p><a href="/top?window=hour&last=24">Top users of the last 24 hours</a></p>
+
 </body></html>`))
 
-// sliceContains reports whether the provided string is present in the given slice of strings.
-func sliceContains(list []string, target string) bool {
-	for _, s := range list {
-		if s == target {
-			return true
-		}
-	}
-	return false
-}
+var topTmpl = template.Must(template.New("").Parse(`
+<html><body>
+
+<h1>Top users of the last {{.Last}} {{.Window}}(s)</h1>
+
+<ol>
+{{range .Top}}<li>{{.Email}}: {{.Visits}}</li>
+{{else}}No visits yet.
+{{end}}
+</ol>
+
+</body></html>`))
 
 // More info about this method of error handling can be found at: http://blog.golang.org/error-handling-and-go
 type appHandler func(http.ResponseWriter, *http.Request) *appError
@@ -173,8 +234,7 @@ type appError struct {
 
 func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if e := fn(w, r); e != nil {
-		ctx := appengine.NewContext(r)
-		aelog.Errorf(ctx, "%v", e.Error)
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, e.Error)
 		http.Error(w, e.Message, e.Code)
 	}
 }
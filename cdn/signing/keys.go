@@ -0,0 +1,314 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signing creates and verifies signed URLs, signed URL prefixes and
+// signed cookies for Cloud CDN (HMAC-SHA1) and Media CDN (Ed25519).
+//
+// Keys are grouped in a Keyset: new values are signed with the primary key,
+// and values signed with any key in the set are accepted. To rotate keys, add
+// the new key to the CDN and the Keyset, make it primary, and remove the old
+// key once everything it signed has expired.
+//
+// Middleware enforces signatures like the CDN edge does, so origins can be
+// tested locally.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Algorithm is a signature algorithm.
+type Algorithm int
+
+const (
+	// HMACSHA1 is used by Cloud CDN. Keys are 16 random bytes.
+	HMACSHA1 Algorithm = iota + 1
+	// Ed25519 is used by Media CDN. Signing needs the private key, verifying
+	// only the public key.
+	Ed25519
+)
+
+// String returns the name used for a in keyset files.
+func (a Algorithm) String() string {
+	switch a {
+	case HMACSHA1:
+		return "hmac-sha1"
+	case Ed25519:
+		return "ed25519"
+	}
+	return fmt.Sprintf("Algorithm(%d)", int(a))
+}
+
+// ParseAlgorithm returns the Algorithm named s, as returned by String.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch strings.ToLower(s) {
+	case "hmac-sha1":
+		return HMACSHA1, nil
+	case "ed25519":
+		return Ed25519, nil
+	}
+	return 0, fmt.Errorf("unknown algorithm %q", s)
+}
+
+// encoding returns the base64 encoding of signatures and URL prefixes. Cloud
+// CDN pads, Media CDN doesn't.
+func (a Algorithm) encoding() *base64.Encoding {
+	if a == Ed25519 {
+		return base64.RawURLEncoding
+	}
+	return base64.URLEncoding
+}
+
+// CookieName returns the name of the signed cookie the CDN using a reads.
+func (a Algorithm) CookieName() string {
+	if a == Ed25519 {
+		return "Edge-Cache-Cookie"
+	}
+	return "Cloud-CDN-Cookie"
+}
+
+// A Key is a named signing key. The name must match a key added to the
+// backend service, bucket or keyset of the CDN.
+type Key struct {
+	Name      string
+	Algorithm Algorithm
+
+	// Secret is the raw (not base64url-encoded) HMAC key, or the Ed25519
+	// private key or seed. Ed25519 keys that are only used for verification
+	// can leave it empty.
+	Secret []byte
+
+	// PublicKey is the Ed25519 public key. It is derived from Secret if empty.
+	PublicKey ed25519.PublicKey
+}
+
+// validate checks k and fills in PublicKey.
+func (k *Key) validate() error {
+	if k.Name == "" {
+		return fmt.Errorf("key name must not be empty")
+	}
+	if strings.ContainsAny(k.Name, "&:=?") {
+		return fmt.Errorf("key %q: name must not contain '&', ':', '=' or '?'", k.Name)
+	}
+	switch k.Algorithm {
+	case HMACSHA1:
+		if len(k.Secret) == 0 {
+			return fmt.Errorf("key %q: empty HMAC secret", k.Name)
+		}
+	case Ed25519:
+		priv, pub, err := Ed25519Key(k.Secret, k.PublicKey)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", k.Name, err)
+		}
+		k.Secret, k.PublicKey = priv, pub
+	default:
+		return fmt.Errorf("key %q: unknown algorithm %v", k.Name, k.Algorithm)
+	}
+	return nil
+}
+
+// Ed25519Key returns the private and public keys for an Ed25519 secret and
+// public key as accepted by Key. The secret is a seed, a private key, or
+// empty for keys that only verify; the public key is derived from the
+// secret if empty. The Media CDN tokens in mediacdn/token use it too.
+func Ed25519Key(secret []byte, pub ed25519.PublicKey) (ed25519.PrivateKey, ed25519.PublicKey, error) {
+	var priv ed25519.PrivateKey
+	switch len(secret) {
+	case 0:
+	case ed25519.SeedSize:
+		priv = ed25519.NewKeyFromSeed(secret)
+	case ed25519.PrivateKeySize:
+		priv = ed25519.PrivateKey(secret)
+	default:
+		return nil, nil, fmt.Errorf("Ed25519 secret must be %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(secret))
+	}
+	if len(pub) == 0 && priv != nil {
+		pub = priv.Public().(ed25519.PublicKey)
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, nil, fmt.Errorf("Ed25519 keys need a secret or a %d byte public key", ed25519.PublicKeySize)
+	}
+	return priv, pub, nil
+}
+
+// sign returns the encoded signature of msg.
+func (k Key) sign(msg string) (string, error) {
+	var sig []byte
+	switch k.Algorithm {
+	case HMACSHA1:
+		mac := hmac.New(sha1.New, k.Secret)
+		mac.Write([]byte(msg))
+		sig = mac.Sum(nil)
+	case Ed25519:
+		if len(k.Secret) == 0 {
+			return "", fmt.Errorf("key %q can only verify", k.Name)
+		}
+		sig = ed25519.Sign(ed25519.PrivateKey(k.Secret), []byte(msg))
+	default:
+		return "", fmt.Errorf("key %q: unknown algorithm %v", k.Name, k.Algorithm)
+	}
+	return k.Algorithm.encoding().EncodeToString(sig), nil
+}
+
+// verify reports whether sig is a valid signature of msg.
+func (k Key) verify(msg string, sig []byte) bool {
+	switch k.Algorithm {
+	case HMACSHA1:
+		mac := hmac.New(sha1.New, k.Secret)
+		mac.Write([]byte(msg))
+		return hmac.Equal(mac.Sum(nil), sig)
+	case Ed25519:
+		return ed25519.Verify(k.PublicKey, []byte(msg), sig)
+	}
+	return false
+}
+
+// A Keyset holds named keys, one of which is the primary signing key.
+type Keyset struct {
+	primary string
+	keys    map[string]Key
+}
+
+// NewKeyset returns a Keyset of keys that signs with the key named primary.
+func NewKeyset(primary string, keys ...Key) (*Keyset, error) {
+	ks := &Keyset{primary: primary, keys: make(map[string]Key, len(keys))}
+	for _, k := range keys {
+		if err := k.validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := ks.keys[k.Name]; ok {
+			return nil, fmt.Errorf("duplicate key %q", k.Name)
+		}
+		ks.keys[k.Name] = k
+	}
+	p, ok := ks.keys[primary]
+	if !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyset", primary)
+	}
+	if len(p.Secret) == 0 {
+		return nil, fmt.Errorf("primary key %q has no secret to sign with", primary)
+	}
+	return ks, nil
+}
+
+// Primary returns the signing key.
+func (ks *Keyset) Primary() Key {
+	return ks.keys[ks.primary]
+}
+
+// Key returns the key with the given name.
+func (ks *Keyset) Key(name string) (Key, bool) {
+	k, ok := ks.keys[name]
+	return k, ok
+}
+
+// Names returns the sorted names of all keys.
+func (ks *Keyset) Names() []string {
+	names := make([]string, 0, len(ks.keys))
+	for n := range ks.keys {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeKey decodes a base64url-encoded key, with or without padding.
+func decodeKey(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64url decode: %w", err)
+	}
+	return b, nil
+}
+
+// ReadKeyFile reads a key file holding a single base64url-encoded secret, as
+// created for Cloud CDN with `head -c 16 /dev/urandom | base64 | tr +/ -_`.
+func ReadKeyFile(path, name string, alg Algorithm) (Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to read key file: %w", err)
+	}
+	secret, err := decodeKey(string(b))
+	if err != nil {
+		return Key{}, err
+	}
+	k := Key{Name: name, Algorithm: alg, Secret: secret}
+	if err := k.validate(); err != nil {
+		return Key{}, err
+	}
+	return k, nil
+}
+
+// keysetFile is the JSON format read by ReadKeysetFile.
+type keysetFile struct {
+	Primary string `json:"primary"`
+	Keys    []struct {
+		Name      string `json:"name"`
+		Algorithm string `json:"algorithm"`
+		Secret    string `json:"secret,omitempty"`
+		PublicKey string `json:"public_key,omitempty"`
+	} `json:"keys"`
+}
+
+// ReadKeysetFile reads a JSON keyset file like:
+//
+//	{
+//	  "primary": "key-2",
+//	  "keys": [
+//	    {"name": "key-1", "algorithm": "hmac-sha1", "secret": "nZtRohdNF9m3cKM24IcK4w=="},
+//	    {"name": "key-2", "algorithm": "hmac-sha1", "secret": "..."},
+//	    {"name": "edge", "algorithm": "ed25519", "public_key": "..."}
+//	  ]
+//	}
+//
+// Secrets and public keys are base64url-encoded.
+func ReadKeysetFile(path string) (*Keyset, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyset file: %w", err)
+	}
+	var f keysetFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse keyset file: %w", err)
+	}
+	keys := make([]Key, 0, len(f.Keys))
+	for _, fk := range f.Keys {
+		alg, err := ParseAlgorithm(fk.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", fk.Name, err)
+		}
+		k := Key{Name: fk.Name, Algorithm: alg}
+		if fk.Secret != "" {
+			if k.Secret, err = decodeKey(fk.Secret); err != nil {
+				return nil, fmt.Errorf("key %q secret: %w", fk.Name, err)
+			}
+		}
+		if fk.PublicKey != "" {
+			if k.PublicKey, err = decodeKey(fk.PublicKey); err != nil {
+				return nil, fmt.Errorf("key %q public key: %w", fk.Name, err)
+			}
+		}
+		keys = append(keys, k)
+	}
+	return NewKeyset(f.Primary, keys...)
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("nZtRohdNF9m3cKM24IcK4w==\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := ReadKeyFile(path, "my-key", HMACSHA1)
+	if err != nil {
+		t.Fatalf("ReadKeyFile: %v", err)
+	}
+	if !bytes.Equal(k.Secret, cloudCDNKey.Secret) {
+		t.Errorf("ReadKeyFile got secret %v, want %v", k.Secret, cloudCDNKey.Secret)
+	}
+}
+
+func TestReadKeysetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyset.json")
+	data := `{
+		"primary": "key-2",
+		"keys": [
+			{"name": "key-1", "algorithm": "hmac-sha1", "secret": "nZtRohdNF9m3cKM24IcK4w=="},
+			{"name": "key-2", "algorithm": "HMAC-SHA1", "secret": "AAAAAAAAAAAAAAAAAAAAAA"},
+			{"name": "edge", "algorithm": "ed25519", "public_key": "O2onvM62pC1io6jQKm8Nc2UyFXcd4kOmOsBIoYtZ2ik"}
+		]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ks, err := ReadKeysetFile(path)
+	if err != nil {
+		t.Fatalf("ReadKeysetFile: %v", err)
+	}
+	if got := ks.Primary().Name; got != "key-2" {
+		t.Errorf("Primary got %q, want key-2", got)
+	}
+	if got, want := len(ks.Names()), 3; got != want {
+		t.Errorf("Names got %d keys, want %d", got, want)
+	}
+	if k, ok := ks.Key("edge"); !ok || len(k.PublicKey) != 32 {
+		t.Errorf("Key(edge) got %+v, %v", k, ok)
+	}
+}
+
+func TestNewKeysetInvalid(t *testing.T) {
+	hmacKey := Key{Name: "a", Algorithm: HMACSHA1, Secret: []byte("secret")}
+	cases := []struct {
+		name    string
+		primary string
+		keys    []Key
+	}{
+		{"missing primary", "b", []Key{hmacKey}},
+		{"duplicate", "a", []Key{hmacKey, hmacKey}},
+		{"no secret", "a", []Key{{Name: "a", Algorithm: HMACSHA1}}},
+		{"bad name", "a:b", []Key{{Name: "a:b", Algorithm: HMACSHA1, Secret: []byte("s")}}},
+		{"bad ed25519 size", "a", []Key{{Name: "a", Algorithm: Ed25519, Secret: []byte("short")}}},
+		{"unknown algorithm", "a", []Key{{Name: "a", Secret: []byte("s")}}},
+	}
+	for _, c := range cases {
+		if _, err := NewKeyset(c.primary, c.keys...); err == nil {
+			t.Errorf("%s: NewKeyset got nil error, want error", c.name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the Claims of a request that passed Middleware.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
+
+// Middleware returns a handler that emulates a CDN edge enforcing signed
+// requests in front of next. It is meant for local development and
+// integration tests of origins and players, not as a replacement for the CDN.
+//
+// A request is let through if its URL carries a valid signature (see
+// VerifyURL) or it has a valid Cloud-CDN-Cookie or Edge-Cache-Cookie (see
+// VerifyCookie). Other requests get 403 Forbidden. The request URL is
+// rebuilt from the Host header and X-Forwarded-Proto (or the TLS state), so
+// signatures must be created for the URL clients see.
+func Middleware(v *Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		url := requestURL(r)
+
+		c, err := v.VerifyURL(url)
+		if err == ErrMissingSignature {
+			c, err = verifyCookies(v, r, url)
+		}
+		if err != nil {
+			log.Printf("signing.Middleware: rejecting %s: %v", url, err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, c)))
+	})
+}
+
+// verifyCookies checks the signed cookies of r, returning ErrMissingSignature
+// if there are none.
+func verifyCookies(v *Verifier, r *http.Request, url string) (*Claims, error) {
+	err := ErrMissingSignature
+	for _, name := range []string{HMACSHA1.CookieName(), Ed25519.CookieName()} {
+		cookie, cerr := r.Cookie(name)
+		if cerr != nil {
+			continue
+		}
+		var c *Claims
+		if c, err = v.VerifyCookie(cookie.Value, url); err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// requestURL returns the absolute URL of r as seen by the client.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
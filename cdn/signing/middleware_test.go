@@ -0,0 +1,97 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	ks, err := NewKeyset(mediaCDNKey.Name, mediaCDNKey)
+	if err != nil {
+		t.Fatalf("NewKeyset: %v", err)
+	}
+	origin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			t.Errorf("no claims in the request context")
+			return
+		}
+		fmt.Fprintf(w, "ok %s", c.KeyName)
+	})
+	srv := httptest.NewServer(Middleware(&Verifier{Keys: ks}, origin))
+	defer srv.Close()
+
+	expires := time.Now().Add(time.Hour)
+	get := func(url string, cookie *http.Cookie) int {
+		t.Helper()
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusOK && !strings.HasPrefix(string(body), "ok my-key") {
+			t.Errorf("GET %s got body %q", url, body)
+		}
+		return resp.StatusCode
+	}
+
+	signedURL, err := ks.SignURL(srv.URL+"/video/manifest.m3u8", expires)
+	if err != nil {
+		t.Fatalf("SignURL: %v", err)
+	}
+	prefixURL, err := ks.SignURLWithPrefix(srv.URL+"/video/seg-1.ts", srv.URL+"/video/", expires)
+	if err != nil {
+		t.Fatalf("SignURLWithPrefix: %v", err)
+	}
+	cookieValue, err := ks.SignCookie(srv.URL+"/video/", expires)
+	if err != nil {
+		t.Fatalf("SignCookie: %v", err)
+	}
+	cookie := &http.Cookie{Name: Ed25519.CookieName(), Value: cookieValue}
+
+	cases := []struct {
+		name   string
+		url    string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"signed URL", signedURL, nil, http.StatusOK},
+		{"signed prefix", prefixURL, nil, http.StatusOK},
+		{"signed cookie", srv.URL + "/video/seg-2.ts", cookie, http.StatusOK},
+		{"unsigned", srv.URL + "/video/seg-2.ts", nil, http.StatusForbidden},
+		{"cookie out of scope", srv.URL + "/private/a.ts", cookie, http.StatusForbidden},
+		{"tampered URL", strings.Replace(signedURL, "manifest", "other", 1), cookie, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if got := get(c.url, c.cookie); got != c.want {
+			t.Errorf("%s: GET got status %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SignURL signs url with key. The result is url with the Expires, KeyName and
+// Signature query parameters appended.
+//
+// url should not already have any of those parameters.
+func SignURL(url string, key Key, expires time.Time) (string, error) {
+	if err := key.validate(); err != nil {
+		return "", err
+	}
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	toSign := fmt.Sprintf("%s%sExpires=%d&KeyName=%s", url, sep, expires.Unix(), key.Name)
+	sig, err := key.sign(toSign)
+	if err != nil {
+		return "", err
+	}
+	return toSign + "&Signature=" + sig, nil
+}
+
+// SignPrefix signs urlPrefix with key and returns the URLPrefix, Expires,
+// KeyName and Signature query parameters. Appending them to any URL starting
+// with urlPrefix grants access to it, see SignURLWithPrefix.
+//
+// Cloud CDN doesn't allow query parameters in urlPrefix.
+func SignPrefix(urlPrefix string, key Key, expires time.Time) (string, error) {
+	if err := key.validate(); err != nil {
+		return "", err
+	}
+	if key.Algorithm == HMACSHA1 && strings.Contains(urlPrefix, "?") {
+		return "", fmt.Errorf("urlPrefix must not include query params: %s", urlPrefix)
+	}
+	return signPrefix(urlPrefix, key, expires, "&")
+}
+
+// SignURLWithPrefix appends the parameters returned by SignPrefix for
+// urlPrefix to url, which must start with urlPrefix.
+func SignURLWithPrefix(url, urlPrefix string, key Key, expires time.Time) (string, error) {
+	if !strings.HasPrefix(url, urlPrefix) {
+		return "", fmt.Errorf("url %q does not start with prefix %q", url, urlPrefix)
+	}
+	params, err := SignPrefix(urlPrefix, key, expires)
+	if err != nil {
+		return "", err
+	}
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	return url + sep + params, nil
+}
+
+// SignCookie signs urlPrefix with key and returns the value of a signed
+// cookie, to be set with the name key.Algorithm.CookieName().
+func SignCookie(urlPrefix string, key Key, expires time.Time) (string, error) {
+	if err := key.validate(); err != nil {
+		return "", err
+	}
+	return signPrefix(urlPrefix, key, expires, ":")
+}
+
+// signPrefix signs urlPrefix, separating fields with sep.
+func signPrefix(urlPrefix string, key Key, expires time.Time, sep string) (string, error) {
+	toSign := fmt.Sprintf("URLPrefix=%s%sExpires=%d%sKeyName=%s",
+		key.Algorithm.encoding().EncodeToString([]byte(urlPrefix)), sep,
+		expires.Unix(), sep,
+		key.Name)
+	sig, err := key.sign(toSign)
+	if err != nil {
+		return "", err
+	}
+	return toSign + sep + "Signature=" + sig, nil
+}
+
+// SignURL signs url with the primary key. See SignURL.
+func (ks *Keyset) SignURL(url string, expires time.Time) (string, error) {
+	return SignURL(url, ks.Primary(), expires)
+}
+
+// SignPrefix signs urlPrefix with the primary key. See SignPrefix.
+func (ks *Keyset) SignPrefix(urlPrefix string, expires time.Time) (string, error) {
+	return SignPrefix(urlPrefix, ks.Primary(), expires)
+}
+
+// SignURLWithPrefix signs url for urlPrefix with the primary key. See
+// SignURLWithPrefix.
+func (ks *Keyset) SignURLWithPrefix(url, urlPrefix string, expires time.Time) (string, error) {
+	return SignURLWithPrefix(url, urlPrefix, ks.Primary(), expires)
+}
+
+// SignCookie signs urlPrefix with the primary key. See SignCookie.
+func (ks *Keyset) SignCookie(urlPrefix string, expires time.Time) (string, error) {
+	return SignCookie(urlPrefix, ks.Primary(), expires)
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"testing"
+	"time"
+)
+
+// cloudCDNKey is the key used by the cdn/signedurls and cdn/signedcookies
+// tests. base64url: nZtRohdNF9m3cKM24IcK4w==
+var cloudCDNKey = Key{
+	Name:      "my-key",
+	Algorithm: HMACSHA1,
+	Secret: []byte{0x9d, 0x9b, 0x51, 0xa2, 0x17, 0x4d, 0x17, 0xd9,
+		0xb7, 0x70, 0xa3, 0x36, 0xe0, 0x87, 0x0a, 0xe3},
+}
+
+// mediaCDNKey is the key used by the mediacdn tests.
+var mediaCDNKey = Key{
+	Name:      "my-key",
+	Algorithm: Ed25519,
+	Secret: []byte{34, 31, 185, 24, 168, 225, 242, 115, 112, 155, 38,
+		157, 183, 65, 104, 243, 85, 182, 188, 26, 176, 101, 247, 177,
+		243, 93, 114, 156, 94, 191, 219, 75, 183, 211, 110, 78, 223,
+		133, 62, 172, 159, 217, 158, 126, 34, 6, 254, 108, 57, 194,
+		141, 93, 219, 91, 8, 162, 88, 62, 52, 75, 42, 103, 202, 238,
+	},
+}
+
+// The expected values below are the ones the per-product samples produce.
+
+func TestSignURL(t *testing.T) {
+	cases := []struct {
+		testName   string
+		key        Key
+		url        string
+		expiration time.Time
+		out        string
+	}{
+		{
+			testName:   "Cloud CDN",
+			key:        cloudCDNKey,
+			url:        "https://www.example.com/some/path?some=query&another=param",
+			expiration: time.Unix(1549751461, 0),
+			out:        "https://www.example.com/some/path?some=query&another=param&Expires=1549751461&KeyName=my-key&Signature=sTqqGX5hUJmlRJ84koAIhWW_c3M=",
+		},
+		{
+			testName:   "Media CDN",
+			key:        mediaCDNKey,
+			url:        "http://35.186.234.33/index.html",
+			expiration: time.Unix(1558131350, 0),
+			out:        "http://35.186.234.33/index.html?Expires=1558131350&KeyName=my-key&Signature=bwCkNAIuVneG0cRPwwPDk1vGmMfqR_TbFfLguwdsfF8Pdlk8INOKICYVOTHY5jHlGgwSF2jkRkm8bWZGwu-SAw",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.testName, func(t *testing.T) {
+			got, err := SignURL(c.url, c.key, c.expiration)
+			if err != nil {
+				t.Fatalf("SignURL: %v", err)
+			}
+			if got != c.out {
+				t.Errorf("SignURL got %s, want %s", got, c.out)
+			}
+		})
+	}
+}
+
+func TestSignPrefix(t *testing.T) {
+	got, err := SignPrefix("https://media.example.com/segments/", cloudCDNKey, time.Unix(1558131350, 0))
+	if err != nil {
+		t.Fatalf("SignPrefix: %v", err)
+	}
+	want := "URLPrefix=aHR0cHM6Ly9tZWRpYS5leGFtcGxlLmNvbS9zZWdtZW50cy8=&Expires=1558131350&KeyName=my-key&Signature=HWE5tBTZgnYVoZzVLG7BtRnOsgk="
+	if got != want {
+		t.Errorf("SignPrefix got %s, want %s", got, want)
+	}
+
+	if _, err := SignPrefix("https://example.com/?a=b", cloudCDNKey, time.Unix(1558131350, 0)); err == nil {
+		t.Errorf("SignPrefix with query params for Cloud CDN got nil error, want error")
+	}
+}
+
+func TestSignURLWithPrefix(t *testing.T) {
+	got, err := SignURLWithPrefix("https://www.google.com/", "https://www.google.com/", mediaCDNKey, time.Unix(1549751401, 0))
+	if err != nil {
+		t.Fatalf("SignURLWithPrefix: %v", err)
+	}
+	want := "https://www.google.com/?URLPrefix=aHR0cHM6Ly93d3cuZ29vZ2xlLmNvbS8&Expires=1549751401&KeyName=my-key&Signature=f82Yhq9HrFXuAKNKlKpt7qk3e1BKo2OCtIy6JF0HA2j_l1IUF69ZFBXposUSky_fgvVvTpxi9IOJCONTKiMNDw"
+	if got != want {
+		t.Errorf("SignURLWithPrefix got %s, want %s", got, want)
+	}
+
+	if _, err := SignURLWithPrefix("https://other.com/", "https://www.google.com/", mediaCDNKey, time.Unix(1549751401, 0)); err == nil {
+		t.Errorf("SignURLWithPrefix for a URL outside the prefix got nil error, want error")
+	}
+}
+
+func TestSignCookie(t *testing.T) {
+	cases := []struct {
+		testName   string
+		key        Key
+		urlPrefix  string
+		expiration time.Time
+		out        string
+	}{
+		{
+			testName:   "Cloud CDN",
+			key:        cloudCDNKey,
+			urlPrefix:  "https://media.example.com/segments/",
+			expiration: time.Unix(1558131350, 0),
+			out:        "URLPrefix=aHR0cHM6Ly9tZWRpYS5leGFtcGxlLmNvbS9zZWdtZW50cy8=:Expires=1558131350:KeyName=my-key:Signature=_qwhz38bxCKdiDqENLIx4ujrw-U=",
+		},
+		{
+			testName:   "Media CDN",
+			key:        mediaCDNKey,
+			urlPrefix:  "https://www.google.com/",
+			expiration: time.Unix(1549751401, 0),
+			out:        "URLPrefix=aHR0cHM6Ly93d3cuZ29vZ2xlLmNvbS8:Expires=1549751401:KeyName=my-key:Signature=O67Laog-pcQ2_RNOuVrgGiN5NS-16I0SOItQRnW0yDkbawgVgX9KfFCgdoqXpY0P3f8ZdMEM2tEVsU6-Saq9BA",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.testName, func(t *testing.T) {
+			got, err := SignCookie(c.urlPrefix, c.key, c.expiration)
+			if err != nil {
+				t.Fatalf("SignCookie: %v", err)
+			}
+			if got != c.out {
+				t.Errorf("SignCookie got %s, want %s", got, c.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by Verifier. They are wrapped with details, so compare them
+// with errors.Is.
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrMalformed        = errors.New("malformed signed value")
+	ErrUnknownKey       = errors.New("unknown key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("signature expired")
+	ErrOutOfScope       = errors.New("URL not covered by signed prefix")
+)
+
+// A Verifier checks signed URLs and cookies against a Keyset.
+type Verifier struct {
+	Keys *Keyset
+
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// A Claims describes a verified signed value.
+type Claims struct {
+	KeyName string
+	Expires time.Time
+	// URLPrefix is the signed prefix, or empty for a signed URL.
+	URLPrefix string
+}
+
+// VerifyURL checks a URL signed with SignURL or SignURLWithPrefix. The
+// signature must be valid for a key in the Keyset and not expired, and a
+// signed prefix must match the start of the URL.
+func (v *Verifier) VerifyURL(url string) (*Claims, error) {
+	i := strings.LastIndex(url, "Signature=")
+	if i <= 0 || (url[i-1] != '&' && url[i-1] != '?') {
+		return nil, ErrMissingSignature
+	}
+	sig := url[i+len("Signature="):]
+	if strings.ContainsAny(sig, "&#") {
+		return nil, fmt.Errorf("%w: Signature must be the last query parameter", ErrMalformed)
+	}
+	signed := url[:i-1]
+
+	if j := strings.LastIndex(signed, "URLPrefix="); j > 0 && (signed[j-1] == '&' || signed[j-1] == '?') {
+		c, err := v.verifyFields(signed[j:], "&", sig)
+		if err != nil {
+			return nil, err
+		}
+		if base := signed[:j-1]; !strings.HasPrefix(base, c.URLPrefix) {
+			return nil, fmt.Errorf("%w: %q does not start with %q", ErrOutOfScope, base, c.URLPrefix)
+		}
+		return c, nil
+	}
+
+	j := strings.LastIndex(signed, "Expires=")
+	if j <= 0 || (signed[j-1] != '&' && signed[j-1] != '?') {
+		return nil, fmt.Errorf("%w: missing Expires", ErrMalformed)
+	}
+	exp, keyName, err := parseExpiresAndKey(signed[j:], "&")
+	if err != nil {
+		return nil, err
+	}
+	return v.check(signed, sig, keyName, exp, "")
+}
+
+// VerifyCookie checks the value of a cookie signed with SignCookie and that
+// url is covered by its signed prefix.
+func (v *Verifier) VerifyCookie(value, url string) (*Claims, error) {
+	i := strings.LastIndex(value, ":Signature=")
+	if i < 0 {
+		return nil, ErrMissingSignature
+	}
+	c, err := v.verifyFields(value[:i], ":", value[i+len(":Signature="):])
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(url, c.URLPrefix) {
+		return nil, fmt.Errorf("%w: %q does not start with %q", ErrOutOfScope, url, c.URLPrefix)
+	}
+	return c, nil
+}
+
+// verifyFields checks "URLPrefix=P<sep>Expires=E<sep>KeyName=K" against sig.
+func (v *Verifier) verifyFields(signed, sep, sig string) (*Claims, error) {
+	encPrefix, rest, ok := strings.Cut(strings.TrimPrefix(signed, "URLPrefix="), sep)
+	if !ok || !strings.HasPrefix(signed, "URLPrefix=") {
+		return nil, fmt.Errorf("%w: want URLPrefix, Expires and KeyName", ErrMalformed)
+	}
+	prefix, err := decodeBase64(encPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: URLPrefix: %v", ErrMalformed, err)
+	}
+	exp, keyName, err := parseExpiresAndKey(rest, sep)
+	if err != nil {
+		return nil, err
+	}
+	return v.check(signed, sig, keyName, exp, string(prefix))
+}
+
+// parseExpiresAndKey parses exactly "Expires=E<sep>KeyName=K".
+func parseExpiresAndKey(s, sep string) (time.Time, string, error) {
+	fields := strings.Split(s, sep)
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], "Expires=") || !strings.HasPrefix(fields[1], "KeyName=") {
+		return time.Time{}, "", fmt.Errorf("%w: want Expires and KeyName, got %q", ErrMalformed, s)
+	}
+	sec, err := strconv.ParseInt(strings.TrimPrefix(fields[0], "Expires="), 10, 64)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: Expires: %v", ErrMalformed, err)
+	}
+	return time.Unix(sec, 0), strings.TrimPrefix(fields[1], "KeyName="), nil
+}
+
+// check verifies the signature of signed, then the expiration time.
+func (v *Verifier) check(signed, encSig, keyName string, expires time.Time, prefix string) (*Claims, error) {
+	key, ok := v.Keys.Key(keyName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyName)
+	}
+	sig, err := decodeBase64(encSig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: Signature: %v", ErrMalformed, err)
+	}
+	if !key.verify(signed, sig) {
+		return nil, ErrInvalidSignature
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	if !now().Before(expires) {
+		return nil, fmt.Errorf("%w at %v", ErrExpired, expires.UTC())
+	}
+	return &Claims{KeyName: keyName, Expires: expires, URLPrefix: prefix}, nil
+}
+
+// decodeBase64 decodes base64url with or without padding, since Cloud CDN
+// pads and Media CDN doesn't.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
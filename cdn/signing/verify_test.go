@@ -0,0 +1,178 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	signTime   = time.Unix(1700000000, 0)
+	expireTime = signTime.Add(time.Hour)
+)
+
+func testVerifier(t *testing.T, primary string, keys ...Key) *Verifier {
+	t.Helper()
+	ks, err := NewKeyset(primary, keys...)
+	if err != nil {
+		t.Fatalf("NewKeyset: %v", err)
+	}
+	return &Verifier{Keys: ks, Now: func() time.Time { return signTime }}
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	for _, key := range []Key{cloudCDNKey, mediaCDNKey} {
+		t.Run(key.Algorithm.String(), func(t *testing.T) {
+			v := testVerifier(t, key.Name, key)
+
+			url, err := SignURL("https://example.com/video/1.m3u8?quality=hd", key, expireTime)
+			if err != nil {
+				t.Fatalf("SignURL: %v", err)
+			}
+			c, err := v.VerifyURL(url)
+			if err != nil {
+				t.Fatalf("VerifyURL(%s): %v", url, err)
+			}
+			if c.KeyName != key.Name || !c.Expires.Equal(expireTime) || c.URLPrefix != "" {
+				t.Errorf("VerifyURL got claims %+v", c)
+			}
+
+			url, err = SignURLWithPrefix("https://example.com/video/seg-1.ts", "https://example.com/video/", key, expireTime)
+			if err != nil {
+				t.Fatalf("SignURLWithPrefix: %v", err)
+			}
+			c, err = v.VerifyURL(url)
+			if err != nil {
+				t.Fatalf("VerifyURL(%s): %v", url, err)
+			}
+			if c.URLPrefix != "https://example.com/video/" {
+				t.Errorf("VerifyURL got prefix %q", c.URLPrefix)
+			}
+
+			cookie, err := SignCookie("https://example.com/video/", key, expireTime)
+			if err != nil {
+				t.Fatalf("SignCookie: %v", err)
+			}
+			if _, err := v.VerifyCookie(cookie, "https://example.com/video/seg-2.ts"); err != nil {
+				t.Errorf("VerifyCookie(%s): %v", cookie, err)
+			}
+		})
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	for _, key := range []Key{cloudCDNKey, mediaCDNKey} {
+		t.Run(key.Algorithm.String(), func(t *testing.T) {
+			v := testVerifier(t, key.Name, key)
+			url, err := SignURL("https://example.com/a.mp4", key, expireTime)
+			if err != nil {
+				t.Fatalf("SignURL: %v", err)
+			}
+			prefixed, err := SignURLWithPrefix("https://example.com/video/1.ts", "https://example.com/video/", key, expireTime)
+			if err != nil {
+				t.Fatalf("SignURLWithPrefix: %v", err)
+			}
+			// Move the prefix-signed parameters to a URL outside the prefix.
+			params := prefixed[strings.Index(prefixed, "?"):]
+
+			cases := []struct {
+				name string
+				url  string
+				want error
+			}{
+				{"unsigned", "https://example.com/a.mp4", ErrMissingSignature},
+				{"tampered path", strings.Replace(url, "a.mp4", "b.mp4", 1), ErrInvalidSignature},
+				{"tampered expiry", strings.Replace(url, "Expires=1700003600", "Expires=1800003600", 1), ErrInvalidSignature},
+				{"unknown key", strings.Replace(url, "KeyName=my-key", "KeyName=other", 1), ErrUnknownKey},
+				{"garbage signature", url + "!!", ErrMalformed},
+				{"extra param", url + "&x=y", ErrMalformed},
+				{"out of scope", "https://example.com/private/1.ts" + params, ErrOutOfScope},
+			}
+			for _, c := range cases {
+				if _, err := v.VerifyURL(c.url); !errors.Is(err, c.want) {
+					t.Errorf("%s: VerifyURL(%s) got %v, want %v", c.name, c.url, err, c.want)
+				}
+			}
+
+			late := &Verifier{Keys: v.Keys, Now: func() time.Time { return expireTime }}
+			if _, err := late.VerifyURL(url); !errors.Is(err, ErrExpired) {
+				t.Errorf("VerifyURL after expiry got %v, want %v", err, ErrExpired)
+			}
+
+			cookie, err := SignCookie("https://example.com/video/", key, expireTime)
+			if err != nil {
+				t.Fatalf("SignCookie: %v", err)
+			}
+			if _, err := v.VerifyCookie(cookie, "https://example.com/private/1.ts"); !errors.Is(err, ErrOutOfScope) {
+				t.Errorf("VerifyCookie out of scope got %v, want %v", err, ErrOutOfScope)
+			}
+			if _, err := late.VerifyCookie(cookie, "https://example.com/video/1.ts"); !errors.Is(err, ErrExpired) {
+				t.Errorf("VerifyCookie after expiry got %v, want %v", err, ErrExpired)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := Key{Name: "key-1", Algorithm: HMACSHA1, Secret: []byte("0123456789abcdef")}
+	newKey := Key{Name: "key-2", Algorithm: HMACSHA1, Secret: []byte("fedcba9876543210")}
+
+	before := testVerifier(t, "key-1", oldKey)
+	signedBefore, err := before.Keys.SignURL("https://example.com/a", expireTime)
+	if err != nil {
+		t.Fatalf("SignURL: %v", err)
+	}
+
+	during := testVerifier(t, "key-2", oldKey, newKey)
+	signedDuring, err := during.Keys.SignURL("https://example.com/a", expireTime)
+	if err != nil {
+		t.Fatalf("SignURL: %v", err)
+	}
+	if !strings.Contains(signedDuring, "KeyName=key-2") {
+		t.Errorf("SignURL got %s, want it signed with the primary key-2", signedDuring)
+	}
+	for _, url := range []string{signedBefore, signedDuring} {
+		if _, err := during.VerifyURL(url); err != nil {
+			t.Errorf("VerifyURL(%s) during rotation: %v", url, err)
+		}
+	}
+
+	after := testVerifier(t, "key-2", newKey)
+	if _, err := after.VerifyURL(signedBefore); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("VerifyURL with a retired key got %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestVerifyWithPublicKeyOnly(t *testing.T) {
+	pub := ed25519.PrivateKey(mediaCDNKey.Secret).Public().(ed25519.PublicKey)
+	v := testVerifier(t, "signer", Key{Name: "my-key", Algorithm: Ed25519, PublicKey: pub},
+		Key{Name: "signer", Algorithm: Ed25519, Secret: make([]byte, ed25519.SeedSize)})
+
+	url, err := SignURL("https://example.com/a", mediaCDNKey, expireTime)
+	if err != nil {
+		t.Fatalf("SignURL: %v", err)
+	}
+	if _, err := v.VerifyURL(url); err != nil {
+		t.Errorf("VerifyURL: %v", err)
+	}
+	verifyOnly, _ := v.Keys.Key("my-key")
+	if _, err := SignURL("https://example.com/a", verifyOnly, expireTime); err == nil {
+		t.Errorf("SignURL with a public key got nil error, want error")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/sqlutil"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/voting"
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDialect runs the voting app on Cloud SQL for MySQL. The connect_*.go
+// files show the same connections on their own.
+type mysqlDialect struct{}
+
+func (mysqlDialect) DriverName() string { return "mysql" }
+
+func (mysqlDialect) DSN(c voting.Config) (string, error) {
+	if c.Mode == voting.Unix {
+		return fmt.Sprintf("%s:%s@unix(%s)/%s?parseTime=true", c.User, c.Password, c.UnixSocket, c.Name), nil
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", c.User, c.Password, c.Host, c.Port, c.Name)
+	if c.RootCert == "" {
+		return dsn, nil
+	}
+	if c.Cert == "" || c.Key == "" {
+		return "", errors.New("DB_CERT and DB_KEY must be set with DB_ROOT_CERT")
+	}
+	pool := x509.NewCertPool()
+	pem, err := os.ReadFile(c.RootCert)
+	if err != nil {
+		return "", err
+	}
+	if ok := pool.AppendCertsFromPEM(pem); !ok {
+		return "", errors.New("unable to append root cert to pool")
+	}
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return "", err
+	}
+	err = mysql.RegisterTLSConfig("cloudsql", &tls.Config{
+		RootCAs:               pool,
+		Certificates:          []tls.Certificate{cert},
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifyPeerCertFunc(pool),
+	})
+	if err != nil {
+		return "", err
+	}
+	return dsn + "&tls=cloudsql", nil
+}
+
+func (mysqlDialect) Connector(c voting.Config, dial voting.DialFunc) (driver.Connector, error) {
+	mysql.RegisterDialContext("cloudsqlconn", func(ctx context.Context, addr string) (net.Conn, error) {
+		return dial(ctx)
+	})
+	password := c.Password
+	if c.IAMAuthN {
+		// The connector logs in with an IAM token; the driver needs any
+		// non-empty password.
+		password = "empty"
+	}
+	cfg, err := mysql.ParseDSN(fmt.Sprintf("%s:%s@cloudsqlconn(localhost:3306)/%s?parseTime=true", c.User, password, c.Name))
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewConnector(cfg)
+}
+
+func (mysqlDialect) Migrator() sqlutil.Migrator {
+	return sqlutil.Migrator{Lock: sqlutil.MySQLLock("votes_migrations")}
+}
+
+func (mysqlDialect) Migrations() []sqlutil.Migration {
+	return []sqlutil.Migration{
+		{
+			Version:     1,
+			Description: "create votes",
+			Statements: []string{`CREATE TABLE IF NOT EXISTS votes (
+				id SERIAL NOT NULL,
+				created_at datetime NOT NULL,
+				candidate VARCHAR(6) NOT NULL,
+				PRIMARY KEY (id)
+			)`},
+		},
+		{
+			Version:     2,
+			Description: "add voter",
+			Statements: []string{
+				"ALTER TABLE votes ADD COLUMN voter VARCHAR(64) NOT NULL DEFAULT ''",
+				"CREATE INDEX votes_voter ON votes (voter, created_at)",
+				"CREATE INDEX votes_created_at ON votes (created_at)",
+			},
+		},
+	}
+}
+
+func (mysqlDialect) Placeholder(n int) string { return "?" }
+
+func (mysqlDialect) RecentVotes(n int) string {
+	return fmt.Sprintf("SELECT candidate, created_at FROM votes ORDER BY created_at DESC LIMIT %d", n)
+}
+
+func (mysqlDialect) InsertVote(ctx context.Context, db *sql.DB, candidate, voter string, createdAt time.Time) error {
+	insertVote := "INSERT INTO votes(candidate, created_at, voter) VALUES(?, ?, ?)"
+	_, err := db.ExecContext(ctx, insertVote, candidate, createdAt, voter)
+	return err
+}
@@ -171,7 +171,7 @@ func TestGetVotes(t *testing.T) {
 			defer cleanup()
 
 			// initialize database connection based on environment
-			db = mustConnect()
+			app = mustConnect()
 
 			testGetVotes(t)
 		})
@@ -199,7 +199,7 @@ func TestCastVote(t *testing.T) {
 			defer cleanup()
 
 			// initialize database connection based on environment
-			db = mustConnect()
+			app = mustConnect()
 
 			testCastVote(t)
 		})
@@ -78,6 +78,11 @@ func connectWithConnectorIAMAuthN() (*sql.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("sql.Open: %w", err)
 	}
+
+	// [START_EXCLUDE]
+	configureConnectionPool(dbPool)
+	// [END_EXCLUDE]
+
 	return dbPool, nil
 }
 
@@ -0,0 +1,109 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/sqlutil"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/voting"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresDialect runs the voting app on Cloud SQL for PostgreSQL. The
+// connect_*.go files show the same connections on their own.
+type postgresDialect struct{}
+
+func (postgresDialect) DriverName() string { return "pgx" }
+
+func (postgresDialect) DSN(c voting.Config) (string, error) {
+	if c.Mode == voting.Unix {
+		return fmt.Sprintf("user=%s password=%s database=%s host=%s", c.User, c.Password, c.Name, c.UnixSocket), nil
+	}
+	dsn := fmt.Sprintf("host=%s user=%s password=%s port=%s database=%s", c.Host, c.User, c.Password, c.Port, c.Name)
+	if c.RootCert == "" {
+		return dsn, nil
+	}
+	if c.Cert == "" || c.Key == "" {
+		return "", errors.New("DB_CERT and DB_KEY must be set with DB_ROOT_CERT")
+	}
+	return dsn + fmt.Sprintf(" sslmode=require sslrootcert=%s sslcert=%s sslkey=%s", c.RootCert, c.Cert, c.Key), nil
+}
+
+func (postgresDialect) Connector(c voting.Config, dial voting.DialFunc) (driver.Connector, error) {
+	dsn := fmt.Sprintf("user=%s database=%s", c.User, c.Name)
+	if !c.IAMAuthN {
+		dsn += fmt.Sprintf(" password=%s", c.Password)
+	}
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	config.DialFunc = func(ctx context.Context, network, instance string) (net.Conn, error) {
+		return dial(ctx)
+	}
+	return stdlib.GetConnector(*config), nil
+}
+
+// migrationLockKey is the advisory lock taken while migrating. Any key
+// not used by other apps of the database works.
+const migrationLockKey = 20260101
+
+func (postgresDialect) Migrator() sqlutil.Migrator {
+	return sqlutil.Migrator{Lock: sqlutil.PostgresLock(migrationLockKey)}
+}
+
+func (postgresDialect) Migrations() []sqlutil.Migration {
+	return []sqlutil.Migration{
+		{
+			Version:     1,
+			Description: "create votes",
+			Statements: []string{`CREATE TABLE IF NOT EXISTS votes (
+				id SERIAL NOT NULL,
+				created_at timestamp NOT NULL,
+				candidate VARCHAR(6) NOT NULL,
+				PRIMARY KEY (id)
+			)`},
+		},
+		{
+			Version:     2,
+			Description: "add voter",
+			Statements: []string{
+				"ALTER TABLE votes ADD COLUMN voter VARCHAR(64) NOT NULL DEFAULT ''",
+				"CREATE INDEX votes_voter ON votes (voter, created_at)",
+				"CREATE INDEX votes_created_at ON votes (created_at)",
+			},
+		},
+	}
+}
+
+func (postgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+func (postgresDialect) RecentVotes(n int) string {
+	return fmt.Sprintf("SELECT candidate, created_at FROM votes ORDER BY created_at DESC LIMIT %d", n)
+}
+
+func (postgresDialect) InsertVote(ctx context.Context, db *sql.DB, candidate, voter string, createdAt time.Time) error {
+	insertVote := "INSERT INTO votes(candidate, created_at, voter) VALUES($1, $2, $3)"
+	_, err := db.ExecContext(ctx, insertVote, candidate, createdAt, voter)
+	return err
+}
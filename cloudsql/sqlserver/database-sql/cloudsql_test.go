@@ -157,7 +157,7 @@ func TestGetVotes(t *testing.T) {
 			defer cleanup()
 
 			// initialize database connection based on environment
-			db = mustConnect()
+			app = mustConnect()
 
 			testGetVotes(t)
 		})
@@ -184,7 +184,7 @@ func TestCastVote(t *testing.T) {
 			defer cleanup()
 
 			// initialize database connection based on environment
-			db = mustConnect()
+			app = mustConnect()
 
 			testCastVote(t)
 		})
@@ -12,6 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// [START cloud_sql_sqlserver_databasesql_connection]
 // [START cloud_sql_sqlserver_databasesql_connect_connector]
 package cloudsql
 
@@ -85,7 +86,13 @@ func connectWithConnector() (*sql.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("sql.Open: %w", err)
 	}
+
+	// [START_EXCLUDE]
+	configureConnectionPool(dbPool)
+	// [END_EXCLUDE]
+
 	return dbPool, nil
 }
 
 // [END cloud_sql_sqlserver_databasesql_connect_connector]
+// [END cloud_sql_sqlserver_databasesql_connection]
@@ -0,0 +1,116 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/sqlutil"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/voting"
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+// sqlserverDialect runs the voting app on Cloud SQL for SQL Server, which
+// doesn't support Unix sockets or IAM database authentication. The
+// connect_*.go files show the same connections on their own.
+type sqlserverDialect struct{}
+
+func (sqlserverDialect) DriverName() string { return "sqlserver" }
+
+func (sqlserverDialect) DSN(c voting.Config) (string, error) {
+	if c.Mode != voting.TCP {
+		return "", fmt.Errorf("SQL Server doesn't support connecting with %v", c.Mode)
+	}
+	dsn := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%s;database=%s;", c.Host, c.User, c.Password, c.Port, c.Name)
+	if c.RootCert == "" {
+		return dsn, nil
+	}
+	// The host name in the certificate is project_id:instance_name.
+	parts := strings.Split(c.InstanceConnectionName, ":")
+	if len(parts) != 3 {
+		return "", errors.New("INSTANCE_CONNECTION_NAME must be set to project:region:instance with DB_ROOT_CERT")
+	}
+	return dsn + fmt.Sprintf("encrypt=true;hostnameincertificate=%s:%s;certificate=%s;", parts[0], parts[2], c.RootCert), nil
+}
+
+func (sqlserverDialect) Connector(c voting.Config, dial voting.DialFunc) (driver.Connector, error) {
+	if c.IAMAuthN {
+		return nil, errors.New("SQL Server doesn't support IAM database authentication")
+	}
+	connector, err := mssql.NewConnector(fmt.Sprintf("user id=%s;password=%s;database=%s;", c.User, c.Password, c.Name))
+	if err != nil {
+		return nil, fmt.Errorf("mssql.NewConnector: %w", err)
+	}
+	connector.Dialer = connectorDialer(dial)
+	return connector, nil
+}
+
+// connectorDialer is an mssql.Dialer dialing with the Cloud SQL Go
+// connector.
+type connectorDialer voting.DialFunc
+
+func (d connectorDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d(ctx)
+}
+
+func (sqlserverDialect) Migrator() sqlutil.Migrator {
+	return sqlutil.Migrator{
+		CreateTable: "IF OBJECT_ID('schema_migrations', 'U') IS NULL CREATE TABLE schema_migrations (version INT NOT NULL PRIMARY KEY)",
+		Lock:        sqlutil.SQLServerLock("votes_migrations"),
+	}
+}
+
+func (sqlserverDialect) Migrations() []sqlutil.Migration {
+	return []sqlutil.Migration{
+		{
+			Version:     1,
+			Description: "create votes",
+			Statements: []string{`IF OBJECT_ID('votes', 'U') IS NULL CREATE TABLE votes (
+				id int IDENTITY(1,1) PRIMARY KEY,
+				created_at DATETIME NOT NULL,
+				candidate CHAR(6) NOT NULL
+			)`},
+		},
+		{
+			Version:     2,
+			Description: "add voter",
+			Statements: []string{
+				"ALTER TABLE votes ADD voter VARCHAR(64) NOT NULL DEFAULT ''",
+				"CREATE INDEX votes_voter ON votes (voter, created_at)",
+				"CREATE INDEX votes_created_at ON votes (created_at)",
+			},
+		},
+	}
+}
+
+func (sqlserverDialect) Placeholder(n int) string { return fmt.Sprintf("@p%d", n) }
+
+func (sqlserverDialect) RecentVotes(n int) string {
+	return fmt.Sprintf("SELECT TOP %d RTRIM(candidate), created_at FROM votes ORDER BY created_at DESC", n)
+}
+
+func (sqlserverDialect) InsertVote(ctx context.Context, db *sql.DB, candidate, voter string, createdAt time.Time) error {
+	insertVote := "INSERT INTO votes (candidate, created_at, voter) VALUES (@TEAM, @CREATED_AT, @VOTER)"
+	_, err := db.ExecContext(ctx, insertVote,
+		sql.Named("TEAM", candidate), sql.Named("CREATED_AT", createdAt), sql.Named("VOTER", voter))
+	return err
+}
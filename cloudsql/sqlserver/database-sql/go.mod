@@ -5,6 +5,7 @@ go 1.25.0
 require (
 	cloud.google.com/go/cloudsqlconn v1.14.1
 	github.com/GoogleCloudPlatform/functions-framework-go v1.8.1
+	github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql v0.1.0
 	github.com/denisenkom/go-mssqldb v0.12.3
 )
 
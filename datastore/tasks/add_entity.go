@@ -17,7 +17,6 @@ package main
 // [START datastore_add_entity]
 import (
 	"context"
-	"log"
 	"time"
 
 	"cloud.google.com/go/datastore"
@@ -25,26 +24,28 @@ import (
 
 // Task is the model used to store tasks in the datastore.
 type Task struct {
-	Desc    string    `datastore:"description"`
-	Created time.Time `datastore:"created"`
-	Done    bool      `datastore:"done"`
-	id      int64     // The integer ID used in the datastore.
+	Desc     string    `datastore:"description"`
+	Created  time.Time `datastore:"created"`
+	Done     bool      `datastore:"done"`
+	Due      time.Time `datastore:"due"`      // Zero if the task has no due date.
+	Priority int       `datastore:"priority"` // Higher is more urgent.
+	Tags     []string  `datastore:"tags"`
+	id       int64     // The integer ID used in the datastore.
 }
 
-// AddTask adds a task with the given description to the datastore,
-// returning the key of the newly created entity.
-func AddTask(projectID string, desc string) (*datastore.Key, error) {
-	ctx := context.Background()
-	client, err := datastore.NewClient(ctx, projectID)
-	if err != nil {
-		log.Fatalf("Could not create datastore client: %v", err)
-	}
-	defer client.Close()
-	task := &Task{
-		Desc:    desc,
-		Created: time.Now(),
+// AddTask adds task to the datastore, returning the key of the newly
+// created entity. Created is set to the current time if it is zero.
+func AddTask(ctx context.Context, client *datastore.Client, task *Task) (*datastore.Key, error) {
+	if task.Created.IsZero() {
+		task.Created = time.Now()
 	}
 	key := datastore.IncompleteKey("Task", nil)
-	return client.Put(ctx, key, task)
-
+	key, err := client.Put(ctx, key, task)
+	if err != nil {
+		return nil, err
+	}
+	task.id = key.ID
+	return key, nil
 }
+
+// [END datastore_add_entity]
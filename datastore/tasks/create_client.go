@@ -17,16 +17,14 @@ package main
 // [START datastore_build_service]
 import (
 	"context"
-	"log"
 
 	"cloud.google.com/go/datastore"
 )
 
-func createClient(projectID string) (*datastore.Client, error) {
-	ctx := context.Background()
+func createClient(ctx context.Context, projectID string) (*datastore.Client, error) {
 	client, err := datastore.NewClient(ctx, projectID)
 	if err != nil {
-		log.Fatalf("Could not create datastore client: %v", err)
+		return nil, err
 	}
 	// Note: call the following from main() to ensure the client
 	// properly frees all resources.
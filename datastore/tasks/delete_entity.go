@@ -17,18 +17,12 @@ package main
 // [START datastore_delete_entity]
 import (
 	"context"
-	"log"
 
 	"cloud.google.com/go/datastore"
 )
 
 // DeleteTask deletes the task with the given ID.
-func DeleteTask(projectID string, taskID int64) error {
-	ctx := context.Background()
-	client, err := datastore.NewClient(ctx, projectID)
-	if err != nil {
-		log.Fatalf("Could not create datastore client: %v", err)
-	}
+func DeleteTask(ctx context.Context, client *datastore.Client, taskID int64) error {
 	return client.Delete(ctx, datastore.IDKey("Task", taskID, nil))
 }
 
@@ -17,33 +17,105 @@ package main
 // [START datastore_retrieve_entities]
 import (
 	"context"
-	"log"
+	"fmt"
+	"time"
 
 	"cloud.google.com/go/datastore"
+	"google.golang.org/api/iterator"
 )
 
-// ListTasks returns all the tasks in ascending order of creation time.
-func ListTasks(projectID string) ([]*Task, error) {
-	ctx := context.Background()
-	client, err := datastore.NewClient(ctx, projectID)
-	if err != nil {
-		log.Fatalf("Could not create datastore client: %v", err)
+// ListOptions selects and orders the tasks returned by ListTasks.
+type ListOptions struct {
+	// Done, if not nil, selects tasks that are done or not done.
+	Done *bool
+	// Tag, if set, selects tasks with the tag.
+	Tag string
+	// Priority, if not zero, selects tasks with the priority.
+	Priority int
+	// DueBefore, if not zero, selects tasks due before the time. Tasks
+	// without a due date are never selected, and the selected tasks are
+	// listed by due date before Order applies.
+	DueBefore time.Time
+	// Order is "created" (the default), "due" or "priority". Tasks are
+	// listed by ascending creation time and due date, and by descending
+	// priority.
+	Order string
+	// Limit is the maximum number of tasks to return. Zero means no limit.
+	Limit int
+	// Cursor continues a previous listing from where it stopped.
+	Cursor string
+}
+
+// ListTasks returns the tasks selected by opts. If opts.Limit tasks are
+// returned, ListTasks also returns a cursor to list the following tasks.
+// Except for listing all tasks by creation time, the queries need the
+// composite indexes in index.yaml.
+func ListTasks(ctx context.Context, client *datastore.Client, opts ListOptions) ([]*Task, string, error) {
+	// Create a query to fetch Task entities.
+	query := datastore.NewQuery("Task")
+	if opts.Done != nil {
+		query = query.FilterField("done", "=", *opts.Done)
+	}
+	if opts.Tag != "" {
+		query = query.FilterField("tags", "=", opts.Tag)
+	}
+	if opts.Priority != 0 {
+		query = query.FilterField("priority", "=", opts.Priority)
+	}
+	if !opts.DueBefore.IsZero() {
+		// Tasks without a due date have a zero due time. An inequality
+		// filter must come with a sort order on the same property first.
+		query = query.FilterField("due", ">", time.Time{}).
+			FilterField("due", "<", opts.DueBefore).
+			Order("due")
+	}
+	switch opts.Order {
+	case "", "created":
+		query = query.Order("created")
+	case "due":
+		if opts.DueBefore.IsZero() {
+			query = query.Order("due")
+		}
+		query = query.Order("created")
+	case "priority":
+		query = query.Order("-priority").Order("created")
+	default:
+		return nil, "", fmt.Errorf("unknown order %q", opts.Order)
+	}
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+	if opts.Cursor != "" {
+		cursor, err := datastore.DecodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid cursor: %w", err)
+		}
+		query = query.Start(cursor)
 	}
 
 	var tasks []*Task
-	// Create a query to fetch all Task entities, ordered by "created".
-	query := datastore.NewQuery("Task").Order("created")
-	keys, err := client.GetAll(ctx, query, &tasks)
-	if err != nil {
-		return nil, err
+	it := client.Run(ctx, query)
+	for {
+		task := new(Task)
+		key, err := it.Next(task)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		// Set the id field on each Task from the corresponding key.
+		task.id = key.ID
+		tasks = append(tasks, task)
 	}
-
-	// Set the id field on each Task from the corresponding key.
-	for i, key := range keys {
-		tasks[i].id = key.ID
+	if opts.Limit == 0 || len(tasks) < opts.Limit {
+		return tasks, "", nil
 	}
-
-	return tasks, nil
+	cursor, err := it.Cursor()
+	if err != nil {
+		return nil, "", err
+	}
+	return tasks, cursor.String(), nil
 }
 
 // [END datastore_retrieve_entities]
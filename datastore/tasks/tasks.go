@@ -18,6 +18,9 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -25,6 +28,9 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/datastore"
 )
 
 func main() {
@@ -32,7 +38,8 @@ func main() {
 	if projID == "" {
 		log.Fatal(`You need to set the environment variable "DATASTORE_PROJECT_ID"`)
 	}
-	client, err := createClient(projID)
+	ctx := context.Background()
+	client, err := createClient(ctx, projID)
 	if err != nil {
 		log.Fatalf("Could not create datastore client: %v", err)
 	}
@@ -41,95 +48,315 @@ func main() {
 	// Print welcome message.
 	fmt.Println("Cloud Datastore Task List")
 	fmt.Println()
-	usage()
+	usage(os.Stdout)
 
 	// Read commands from stdin.
+	c := &cli{client: client, out: os.Stdout}
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Print("> ")
 
 	for scanner.Scan() {
-		cmd, args, n := parseCmd(scanner.Text())
-		switch cmd {
-		case "new":
-			if args == "" {
-				log.Printf("Missing description in %q command", cmd)
-				usage()
-				break
-			}
-			key, err := AddTask(projID, args)
-			if err != nil {
-				log.Printf("Failed to create task: %v", err)
-				break
+		if err := c.exec(ctx, scanner.Text()); err != nil {
+			log.Print(err)
+			var uerr usageError
+			if errors.As(err, &uerr) {
+				usage(os.Stdout)
 			}
-			fmt.Printf("Created new task with ID %d\n", key.ID)
+		}
+		fmt.Print("> ")
+	}
 
-		case "done":
-			if n == 0 {
-				log.Printf("Missing numerical task ID in %q command", cmd)
-				usage()
-				break
-			}
-			if err := MarkDone(projID, n); err != nil {
-				log.Printf("Failed to mark task done: %v", err)
-				break
-			}
-			fmt.Printf("Task %d marked done\n", n)
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed reading stdin: %v", err)
+	}
+}
 
-		case "list":
-			tasks, err := ListTasks(projID)
-			if err != nil {
-				log.Printf("Failed to fetch task list: %v", err)
-				break
-			}
-			PrintTasks(os.Stdout, tasks)
+// usageError is returned for commands with missing or invalid arguments.
+type usageError string
 
-		case "delete":
-			if n == 0 {
-				log.Printf("Missing numerical task ID in %q command", cmd)
-				usage()
-				break
-			}
-			if err := DeleteTask(projID, n); err != nil {
-				log.Printf("Failed to delete task: %v", err)
-				break
-			}
-			fmt.Printf("Task %d deleted\n", n)
+func (e usageError) Error() string { return string(e) }
+
+// cli runs the commands of the task list.
+type cli struct {
+	client *datastore.Client
+	out    io.Writer
+	// next continues the last list command, if it stopped at its limit.
+	next *ListOptions
+}
 
-		default:
-			log.Printf("Unknown command %q", cmd)
-			usage()
+// exec runs the command on line.
+func (c *cli) exec(ctx context.Context, line string) error {
+	cmd, args, n := parseCmd(line)
+	switch cmd {
+	case "":
+		return nil
+
+	case "new":
+		task, err := parseNew(strings.Fields(args))
+		if err != nil {
+			return err
+		}
+		key, err := AddTask(ctx, c.client, task)
+		if err != nil {
+			return fmt.Errorf("failed to create task: %w", err)
 		}
+		fmt.Fprintf(c.out, "Created new task with ID %d\n", key.ID)
 
-		fmt.Print("> ")
+	case "done":
+		ids, err := parseIDs(cmd, args)
+		if err != nil {
+			return err
+		}
+		if err := MarkDone(ctx, c.client, ids...); err != nil {
+			return fmt.Errorf("failed to mark tasks done: %w", missingTasks(ids, err))
+		}
+		for _, id := range ids {
+			fmt.Fprintf(c.out, "Task %d marked done\n", id)
+		}
+
+	case "list":
+		opts, err := parseList(strings.Fields(args))
+		if err != nil {
+			return err
+		}
+		return c.list(ctx, opts)
+
+	case "more":
+		if c.next == nil {
+			return errors.New("no more tasks to list")
+		}
+		return c.list(ctx, *c.next)
+
+	case "delete":
+		if n == 0 {
+			return usageError(fmt.Sprintf("missing numerical task ID in %q command", cmd))
+		}
+		if err := DeleteTask(ctx, c.client, n); err != nil {
+			return fmt.Errorf("failed to delete task: %w", err)
+		}
+		fmt.Fprintf(c.out, "Task %d deleted\n", n)
+
+	case "export":
+		if args == "" {
+			return usageError(fmt.Sprintf("missing file name in %q command", cmd))
+		}
+		format, err := formatOf(args)
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(args)
+		if err != nil {
+			return err
+		}
+		count, err := ExportTasks(ctx, c.client, f, format)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export tasks: %w", err)
+		}
+		fmt.Fprintf(c.out, "Exported %d tasks to %s\n", count, args)
+
+	case "import":
+		if args == "" {
+			return usageError(fmt.Sprintf("missing file name in %q command", cmd))
+		}
+		format, err := formatOf(args)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(args)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		count, err := ImportTasks(ctx, c.client, f, format)
+		if err != nil {
+			return fmt.Errorf("failed to import tasks after %d: %w", count, err)
+		}
+		fmt.Fprintf(c.out, "Imported %d tasks from %s\n", count, args)
+
+	default:
+		return usageError(fmt.Sprintf("unknown command %q", cmd))
 	}
+	return nil
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Failed reading stdin: %v", err)
+// list prints the tasks selected by opts and remembers where it stopped.
+func (c *cli) list(ctx context.Context, opts ListOptions) error {
+	tasks, cursor, err := ListTasks(ctx, c.client, opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch task list: %w", err)
 	}
+	PrintTasks(c.out, tasks)
+	c.next = nil
+	if cursor != "" {
+		opts.Cursor = cursor
+		c.next = &opts
+		fmt.Fprintln(c.out, "Type \"more\" for more tasks.")
+	}
+	return nil
+}
+
+// missingTasks reports which tasks don't exist if err is because of them.
+func missingTasks(ids []int64, err error) error {
+	var merr datastore.MultiError
+	if !errors.As(err, &merr) {
+		return err
+	}
+	var missing []string
+	for i, e := range merr {
+		if errors.Is(e, datastore.ErrNoSuchEntity) {
+			missing = append(missing, strconv.FormatInt(ids[i], 10))
+		}
+	}
+	if len(missing) == 0 {
+		return err
+	}
+	return fmt.Errorf("no such task: %s", strings.Join(missing, ", "))
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// parseDate parses a date like 2024-12-31, at midnight local time, or an
+// RFC 3339 time.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q; use YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+func newFlagSet(cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseNew parses the arguments of the new command: flags followed by the
+// description.
+func parseNew(args []string) (*Task, error) {
+	var task Task
+	var due string
+	var tags stringList
+	fs := newFlagSet("new")
+	fs.StringVar(&due, "due", "", "")
+	fs.IntVar(&task.Priority, "priority", 0, "")
+	fs.Var(&tags, "tag", "")
+	if err := fs.Parse(args); err != nil {
+		return nil, usageError(fmt.Sprintf("invalid \"new\" command: %v", err))
+	}
+	task.Desc = strings.Join(fs.Args(), " ")
+	if task.Desc == "" {
+		return nil, usageError(`missing description in "new" command`)
+	}
+	if due != "" {
+		var err error
+		if task.Due, err = parseDate(due); err != nil {
+			return nil, err
+		}
+	}
+	task.Tags = tags
+	return &task, nil
+}
+
+// parseList parses the flags of the list command.
+func parseList(args []string) (ListOptions, error) {
+	var opts ListOptions
+	var done, pending bool
+	var dueBefore string
+	fs := newFlagSet("list")
+	fs.BoolVar(&done, "done", false, "")
+	fs.BoolVar(&pending, "pending", false, "")
+	fs.StringVar(&opts.Tag, "tag", "", "")
+	fs.IntVar(&opts.Priority, "priority", 0, "")
+	fs.StringVar(&dueBefore, "due-before", "", "")
+	fs.StringVar(&opts.Order, "order", "created", "")
+	fs.IntVar(&opts.Limit, "limit", 0, "")
+	if err := fs.Parse(args); err != nil {
+		return opts, usageError(fmt.Sprintf("invalid \"list\" command: %v", err))
+	}
+	if fs.NArg() > 0 {
+		return opts, usageError(fmt.Sprintf("unexpected arguments in \"list\" command: %q", fs.Args()))
+	}
+	switch {
+	case done && pending:
+		return opts, usageError("-done and -pending can't be used together")
+	case done || pending:
+		opts.Done = &done
+	}
+	if dueBefore != "" {
+		var err error
+		if opts.DueBefore, err = parseDate(dueBefore); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// parseIDs parses one or more task IDs.
+func parseIDs(cmd, args string) ([]int64, error) {
+	f := strings.Fields(args)
+	if len(f) == 0 {
+		return nil, usageError(fmt.Sprintf("missing numerical task ID in %q command", cmd))
+	}
+	ids := make([]int64, len(f))
+	for i, s := range f {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id == 0 {
+			return nil, usageError(fmt.Sprintf("invalid task ID %q in %q command", s, cmd))
+		}
+		ids[i] = id
+	}
+	return ids, nil
 }
 
 // PrintTasks prints the tasks to the given writer.
 func PrintTasks(w io.Writer, tasks []*Task) {
 	// Use a tab writer to help make results pretty.
 	tw := tabwriter.NewWriter(w, 8, 8, 1, ' ', 0) // Min cell size of 8.
-	fmt.Fprintf(tw, "ID\tDescription\tStatus\n")
+	fmt.Fprintf(tw, "ID\tDescription\tPriority\tDue\tTags\tStatus\n")
 	for _, t := range tasks {
+		due := "-"
+		if !t.Due.IsZero() {
+			due = t.Due.Format("2006-01-02")
+		}
+		status := fmt.Sprintf("created %v", t.Created)
 		if t.Done {
-			fmt.Fprintf(tw, "%d\t%s\tdone\n", t.id, t.Desc)
-		} else {
-			fmt.Fprintf(tw, "%d\t%s\tcreated %v\n", t.id, t.Desc, t.Created)
+			status = "done"
 		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n", t.id, t.Desc, t.Priority, due, strings.Join(t.Tags, ","), status)
 	}
 	tw.Flush()
 }
 
-func usage() {
-	fmt.Print(`Usage:
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
 
-  new <description>  Adds a task with a description <description>
-  done <task-id>     Marks a task as done
-  list               Lists all tasks by creation time
+  new [-due <date>] [-priority <n>] [-tag <tag>]... <description>
+                     Adds a task with a description <description>
+  done <task-id>...  Marks tasks as done, all or none of them
+  list [-done|-pending] [-tag <tag>] [-priority <n>] [-due-before <date>]
+       [-order created|due|priority] [-limit <n>]
+                     Lists tasks, by creation time unless ordered otherwise
+  more               Continues the last list that stopped at its limit
   delete <task-id>   Deletes a task
+  export <file>      Writes all tasks to a .json or .csv file
+  import <file>      Adds or replaces tasks from a .json or .csv file
+
+Dates are YYYY-MM-DD.
 `)
 }
 
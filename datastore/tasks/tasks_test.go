@@ -17,9 +17,13 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,20 +51,21 @@ func makeDesc() string {
 }
 
 func TestAddMarkDelete(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	testutil.SystemTest(t)
+	ctx := context.Background()
 
 	desc := makeDesc()
 
-	k, err := AddTask(tc.ProjectID, desc)
+	k, err := AddTask(ctx, client, &Task{Desc: desc})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if err := MarkDone(tc.ProjectID, k.ID); err != nil {
+	if err := MarkDone(ctx, client, k.ID); err != nil {
 		t.Fatal(err)
 	}
 
-	if err := DeleteTask(tc.ProjectID, k.ID); err != nil {
+	if err := DeleteTask(ctx, client, k.ID); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -68,16 +73,17 @@ func TestAddMarkDelete(t *testing.T) {
 func TestList(t *testing.T) {
 	t.Skip("Flaky. Eventual consistency. Re-enable once the datastore emulator works with gRPC.")
 
-	tc := testutil.SystemTest(t)
+	testutil.SystemTest(t)
+	ctx := context.Background()
 
 	desc := makeDesc()
 
-	k, err := AddTask(tc.ProjectID, desc)
+	k, err := AddTask(ctx, client, &Task{Desc: desc})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	foundTask, err := listAndGetTask(tc.ProjectID, desc)
+	foundTask, err := listAndGetTask(ctx, client, desc)
 	if err != nil {
 		t.Error(err)
 	}
@@ -85,11 +91,11 @@ func TestList(t *testing.T) {
 		t.Errorf("k.ID: got %d, want %d", got, want)
 	}
 
-	if err := MarkDone(tc.ProjectID, foundTask.id); err != nil {
+	if err := MarkDone(ctx, client, foundTask.id); err != nil {
 		t.Fatal(err)
 	}
 
-	foundTask, err = listAndGetTask(tc.ProjectID, desc)
+	foundTask, err = listAndGetTask(ctx, client, desc)
 	if err != nil {
 		t.Error(err)
 	}
@@ -97,7 +103,7 @@ func TestList(t *testing.T) {
 		t.Error("foundTask.Done: got false, want true")
 	}
 
-	if err := DeleteTask(tc.ProjectID, foundTask.id); err != nil {
+	if err := DeleteTask(ctx, client, foundTask.id); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -114,9 +120,9 @@ func TestCreateClientWithDatabase(t *testing.T) {
 	}
 }
 
-func listAndGetTask(projectID string, desc string) (*Task, error) {
+func listAndGetTask(ctx context.Context, client *datastore.Client, desc string) (*Task, error) {
 
-	tasks, err := ListTasks(projectID)
+	tasks, _, err := ListTasks(ctx, client, ListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -133,3 +139,365 @@ func listAndGetTask(projectID string, desc string) (*Task, error) {
 
 	return foundTask, nil
 }
+
+func TestParseNew(t *testing.T) {
+	task, err := parseNew(strings.Fields("-due 2024-12-31 -priority 2 -tag home -tag errand Buy  milk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Task{
+		Desc:     "Buy milk",
+		Due:      time.Date(2024, 12, 31, 0, 0, 0, 0, time.Local),
+		Priority: 2,
+		Tags:     []string{"home", "errand"},
+	}
+	if !reflect.DeepEqual(task, want) {
+		t.Errorf("parseNew: got %+v, want %+v", task, want)
+	}
+
+	for _, args := range []string{"", "-priority 2", "-due tomorrow Buy milk", "-color red Buy milk"} {
+		if _, err := parseNew(strings.Fields(args)); err == nil {
+			t.Errorf("parseNew(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	opts, err := parseList(strings.Fields("-pending -tag home -due-before 2024-12-31 -order priority -limit 10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Done == nil || *opts.Done {
+		t.Errorf("Done: got %v, want false", opts.Done)
+	}
+	if opts.Tag != "home" || opts.Order != "priority" || opts.Limit != 10 {
+		t.Errorf("parseList: got %+v", opts)
+	}
+	if want := time.Date(2024, 12, 31, 0, 0, 0, 0, time.Local); !opts.DueBefore.Equal(want) {
+		t.Errorf("DueBefore: got %v, want %v", opts.DueBefore, want)
+	}
+
+	if opts, err := parseList(nil); err != nil || opts.Done != nil || opts.Order != "created" {
+		t.Errorf("parseList(nil) = %+v, %v; want all tasks by creation time", opts, err)
+	}
+	for _, args := range []string{"-done -pending", "extra", "-limit many"} {
+		if _, err := parseList(strings.Fields(args)); err == nil {
+			t.Errorf("parseList(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestParseIDs(t *testing.T) {
+	ids, err := parseIDs("done", "3 1 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{3, 1, 2}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("parseIDs: got %v, want %v", ids, want)
+	}
+	for _, args := range []string{"", "1 x", "0"} {
+		_, err := parseIDs("done", args)
+		var uerr usageError
+		if !errors.As(err, &uerr) {
+			t.Errorf("parseIDs(%q): got %v, want a usage error", args, err)
+		}
+	}
+}
+
+func TestMissingTasks(t *testing.T) {
+	err := missingTasks([]int64{1, 2, 3}, datastore.MultiError{nil, datastore.ErrNoSuchEntity, datastore.ErrNoSuchEntity})
+	if got, want := err.Error(), "no such task: 2, 3"; got != want {
+		t.Errorf("missingTasks: got %q, want %q", got, want)
+	}
+	other := errors.New("unavailable")
+	if got := missingTasks([]int64{1}, other); got != other {
+		t.Errorf("missingTasks: got %v, want %v", got, other)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for name, want := range map[string]string{"tasks.json": "json", "out/Tasks.CSV": "csv", "tasks.txt": ""} {
+		got, err := formatOf(name)
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("formatOf(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	in := "description,tags,priority,due,done,id\n" +
+		"Buy milk,home;errand,2,2024-12-31T00:00:00Z,true,\n" +
+		"\"Call Bob, again\",,,,,42\n"
+	records, err := readCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []record{
+		{Description: "Buy milk", Tags: []string{"home", "errand"}, Priority: 2, Due: "2024-12-31T00:00:00Z", Done: true},
+		{Description: "Call Bob, again", ID: 42},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("readCSV: got %+v, want %+v", records, want)
+	}
+
+	for _, in := range []string{"name\nBuy milk\n", "description,priority\nBuy milk,high\n"} {
+		if _, err := readCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("readCSV(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestPrintTasks(t *testing.T) {
+	var buf bytes.Buffer
+	PrintTasks(&buf, []*Task{
+		{id: 1, Desc: "Buy milk", Done: true, Priority: 2, Tags: []string{"home", "errand"}, Due: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)},
+	})
+	out := buf.String()
+	for _, want := range []string{"Priority", "Buy milk", "2024-12-31", "home,errand", "done"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PrintTasks output doesn't contain %q:\n%s", want, out)
+		}
+	}
+}
+
+// emulatorClient returns a client of an empty Datastore emulator. Start the
+// emulator with --consistency=1.0 so that queries see every write.
+func emulatorClient(t *testing.T) *datastore.Client {
+	t.Helper()
+	if os.Getenv("DATASTORE_EMULATOR_HOST") == "" {
+		t.Skip("Skipping emulator test. Set DATASTORE_EMULATOR_HOST.")
+	}
+	ctx := context.Background()
+	c, err := datastore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("datastore.NewClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	keys, err := c.GetAll(ctx, datastore.NewQuery("Task").KeysOnly(), nil)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if err := c.DeleteMulti(ctx, keys); err != nil {
+		t.Fatalf("DeleteMulti: %v", err)
+	}
+	return c
+}
+
+func descs(tasks []*Task) string {
+	var d []string
+	for _, t := range tasks {
+		d = append(d, t.Desc)
+	}
+	return strings.Join(d, ",")
+}
+
+func TestListTasksEmulator(t *testing.T) {
+	c := emulatorClient(t)
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, task := range []*Task{
+		{Desc: "a", Priority: 1, Tags: []string{"home"}, Due: base.AddDate(0, 0, 3)},
+		{Desc: "b", Priority: 3, Tags: []string{"work"}},
+		{Desc: "c", Priority: 2, Tags: []string{"home", "work"}, Due: base.AddDate(0, 0, 1), Done: true},
+		{Desc: "d", Priority: 3, Due: base.AddDate(0, 0, 2)},
+	} {
+		task.Created = base.Add(time.Duration(i) * time.Hour)
+		if _, err := AddTask(ctx, c, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending := false
+	tests := []struct {
+		opts ListOptions
+		want string
+	}{
+		{ListOptions{}, "a,b,c,d"},
+		{ListOptions{Order: "priority"}, "b,d,c,a"},
+		{ListOptions{Order: "due"}, "b,c,d,a"},
+		{ListOptions{Tag: "home"}, "a,c"},
+		{ListOptions{Done: &pending}, "a,b,d"},
+		{ListOptions{Priority: 3}, "b,d"},
+		{ListOptions{DueBefore: base.AddDate(0, 0, 3)}, "c,d"},
+		{ListOptions{DueBefore: base.AddDate(0, 0, 3), Order: "priority"}, "c,d"},
+	}
+	for _, tc := range tests {
+		tasks, cursor, err := ListTasks(ctx, c, tc.opts)
+		if err != nil {
+			t.Fatalf("ListTasks(%+v): %v", tc.opts, err)
+		}
+		if got := descs(tasks); got != tc.want {
+			t.Errorf("ListTasks(%+v): got %s, want %s", tc.opts, got, tc.want)
+		}
+		if cursor != "" {
+			t.Errorf("ListTasks(%+v): got cursor without a limit", tc.opts)
+		}
+	}
+
+	var pages []string
+	opts := ListOptions{Order: "priority", Limit: 3}
+	for {
+		tasks, cursor, err := ListTasks(ctx, c, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, descs(tasks))
+		if cursor == "" {
+			break
+		}
+		opts.Cursor = cursor
+	}
+	if got, want := strings.Join(pages, "|"), "b,d,c|a"; got != want {
+		t.Errorf("pages: got %s, want %s", got, want)
+	}
+
+	if _, _, err := ListTasks(ctx, c, ListOptions{Order: "name"}); err == nil {
+		t.Error("ListTasks with an unknown order succeeded")
+	}
+}
+
+func TestMarkDoneEmulator(t *testing.T) {
+	c := emulatorClient(t)
+	ctx := context.Background()
+	k1, err := AddTask(ctx, c, &Task{Desc: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := AddTask(ctx, c, &Task{Desc: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing task fails the whole transaction.
+	if err := MarkDone(ctx, c, k1.ID, k2.ID+1000); err == nil {
+		t.Fatal("MarkDone with a missing task succeeded")
+	}
+	var task Task
+	if err := c.Get(ctx, k1, &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Done {
+		t.Error("task marked done by a failed MarkDone")
+	}
+
+	if err := MarkDone(ctx, c, k1.ID, k2.ID); err != nil {
+		t.Fatal(err)
+	}
+	tasks := make([]Task, 2)
+	if err := c.GetMulti(ctx, []*datastore.Key{k1, k2}, tasks); err != nil {
+		t.Fatal(err)
+	}
+	if !tasks[0].Done || !tasks[1].Done {
+		t.Errorf("tasks not marked done: %+v", tasks)
+	}
+}
+
+func TestExportImportEmulator(t *testing.T) {
+	for _, format := range []string{"json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			c := emulatorClient(t)
+			ctx := context.Background()
+			want := []*Task{
+				{Desc: "Buy milk", Priority: 2, Tags: []string{"home"}, Due: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)},
+				{Desc: "Call Bob, again", Done: true},
+			}
+			for i, task := range want {
+				task.Created = time.Date(2024, 1, 1, i, 0, 0, 0, time.UTC)
+				if _, err := AddTask(ctx, c, task); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			if n, err := ExportTasks(ctx, c, &buf, format); err != nil || n != 2 {
+				t.Fatalf("ExportTasks: %d, %v", n, err)
+			}
+			// Importing into an empty database recreates the tasks with
+			// their IDs.
+			emulatorClient(t)
+			if n, err := ImportTasks(ctx, c, &buf, format); err != nil || n != 2 {
+				t.Fatalf("ImportTasks: %d, %v", n, err)
+			}
+			got, _, err := ListTasks(ctx, c, ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d tasks, want %d", len(got), len(want))
+			}
+			for i := range want {
+				g, w := got[i], want[i]
+				if g.id != w.id || g.Desc != w.Desc || g.Done != w.Done || g.Priority != w.Priority ||
+					!g.Due.Equal(w.Due) || !g.Created.Equal(w.Created) || strings.Join(g.Tags, ",") != strings.Join(w.Tags, ",") {
+					t.Errorf("task %d: got %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestCLIEmulator(t *testing.T) {
+	c := emulatorClient(t)
+	ctx := context.Background()
+	var out bytes.Buffer
+	cl := &cli{client: c, out: &out}
+	exec := func(line string) string {
+		t.Helper()
+		out.Reset()
+		if err := cl.exec(ctx, line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		return out.String()
+	}
+
+	var ids []string
+	for _, line := range []string{
+		"new -priority 1 -tag home Water plants",
+		"new -priority 3 -due 2030-01-02 File taxes",
+		"new -tag home Buy milk",
+	} {
+		var id int64
+		if _, err := fmt.Sscanf(exec(line), "Created new task with ID %d", &id); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		ids = append(ids, fmt.Sprint(id))
+	}
+
+	if got := exec("list -tag home -limit 1"); !strings.Contains(got, "Water plants") || !strings.Contains(got, "more") {
+		t.Errorf("list -tag home -limit 1:\n%s", got)
+	}
+	if got := exec("more"); !strings.Contains(got, "Buy milk") || strings.Contains(got, "Water plants") {
+		t.Errorf("more:\n%s", got)
+	}
+	// The second page was full, so the end of the list is only found by
+	// asking for more.
+	if got := exec("more"); strings.Contains(got, "Buy milk") || strings.Contains(got, "more") {
+		t.Errorf("more at the end:\n%s", got)
+	}
+	if err := cl.exec(ctx, "more"); err == nil {
+		t.Error("more after the last page succeeded")
+	}
+
+	exec("done " + ids[0] + " " + ids[2])
+	if got := exec("list -pending"); !strings.Contains(got, "File taxes") || strings.Contains(got, "Buy milk") {
+		t.Errorf("list -pending:\n%s", got)
+	}
+
+	file := filepath.Join(t.TempDir(), "tasks.csv")
+	if got, want := exec("export "+file), "Exported 3 tasks"; !strings.HasPrefix(got, want) {
+		t.Errorf("export: got %q, want prefix %q", got, want)
+	}
+	exec("delete " + ids[1])
+	if got := exec("list"); strings.Contains(got, "File taxes") {
+		t.Errorf("list after delete:\n%s", got)
+	}
+	exec("import " + file)
+	if got := exec("list -order priority"); !strings.Contains(got, "File taxes") || !strings.Contains(got, "2030-01-02") {
+		t.Errorf("list after import:\n%s", got)
+	}
+
+	var uerr usageError
+	if err := cl.exec(ctx, "frobnicate"); !errors.As(err, &uerr) {
+		t.Errorf("unknown command: got %v, want a usage error", err)
+	}
+}
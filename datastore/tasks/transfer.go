@@ -0,0 +1,235 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// record is the exported form of a task.
+type record struct {
+	ID          int64     `json:"id,omitempty"`
+	Description string    `json:"description"`
+	Created     time.Time `json:"created"`
+	Done        bool      `json:"done"`
+	Due         string    `json:"due,omitempty"` // RFC 3339.
+	Priority    int       `json:"priority,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+}
+
+var csvHeader = []string{"id", "description", "created", "done", "due", "priority", "tags"}
+
+// putBatchSize is the maximum number of entities in a PutMulti call.
+const putBatchSize = 500
+
+func toRecord(t *Task) record {
+	r := record{
+		ID:          t.id,
+		Description: t.Desc,
+		Created:     t.Created,
+		Done:        t.Done,
+		Priority:    t.Priority,
+		Tags:        t.Tags,
+	}
+	if !t.Due.IsZero() {
+		r.Due = t.Due.Format(time.RFC3339)
+	}
+	return r
+}
+
+func fromRecord(r record) (*Task, error) {
+	t := &Task{
+		Desc:     r.Description,
+		Created:  r.Created,
+		Done:     r.Done,
+		Priority: r.Priority,
+		Tags:     r.Tags,
+		id:       r.ID,
+	}
+	if r.Due != "" {
+		due, err := time.Parse(time.RFC3339, r.Due)
+		if err != nil {
+			return nil, fmt.Errorf("task %q: invalid due date: %w", r.Description, err)
+		}
+		t.Due = due
+	}
+	return t, nil
+}
+
+// formatOf returns the format of a file, "json" or "csv", from its name.
+func formatOf(name string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".json":
+		return "json", nil
+	case ".csv":
+		return "csv", nil
+	default:
+		return "", fmt.Errorf("unsupported file type %q; use .json or .csv", ext)
+	}
+}
+
+// ExportTasks writes all tasks to w in format "json" or "csv", returning
+// the number of tasks written.
+func ExportTasks(ctx context.Context, client *datastore.Client, w io.Writer, format string) (int, error) {
+	tasks, _, err := ListTasks(ctx, client, ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	switch format {
+	case "json":
+		records := make([]record, len(tasks))
+		for i, t := range tasks {
+			records[i] = toRecord(t)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(records); err != nil {
+			return 0, err
+		}
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		for _, t := range tasks {
+			r := toRecord(t)
+			cw.Write([]string{
+				strconv.FormatInt(r.ID, 10),
+				r.Description,
+				r.Created.Format(time.RFC3339Nano),
+				strconv.FormatBool(r.Done),
+				r.Due,
+				strconv.Itoa(r.Priority),
+				strings.Join(r.Tags, ";"),
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unknown format %q", format)
+	}
+	return len(tasks), nil
+}
+
+// ImportTasks reads tasks in format "json" or "csv" from r and stores them,
+// returning the number of tasks stored. Tasks with an ID replace the task
+// with that ID; tasks without one are added.
+func ImportTasks(ctx context.Context, client *datastore.Client, r io.Reader, format string) (int, error) {
+	var records []record
+	switch format {
+	case "json":
+		if err := json.NewDecoder(r).Decode(&records); err != nil {
+			return 0, fmt.Errorf("invalid JSON: %w", err)
+		}
+	case "csv":
+		var err error
+		if records, err = readCSV(r); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unknown format %q", format)
+	}
+
+	keys := make([]*datastore.Key, len(records))
+	tasks := make([]*Task, len(records))
+	for i, rec := range records {
+		t, err := fromRecord(rec)
+		if err != nil {
+			return 0, err
+		}
+		if t.Created.IsZero() {
+			t.Created = time.Now()
+		}
+		tasks[i] = t
+		if t.id != 0 {
+			keys[i] = datastore.IDKey("Task", t.id, nil)
+		} else {
+			keys[i] = datastore.IncompleteKey("Task", nil)
+		}
+	}
+	for start := 0; start < len(tasks); start += putBatchSize {
+		end := min(start+putBatchSize, len(tasks))
+		if _, err := client.PutMulti(ctx, keys[start:end], tasks[start:end]); err != nil {
+			return start, err
+		}
+	}
+	return len(tasks), nil
+}
+
+func readCSV(r io.Reader) ([]record, error) {
+	cr := csv.NewReader(r)
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[name] = i
+	}
+	if _, ok := col["description"]; !ok {
+		return nil, fmt.Errorf("CSV header has no description column")
+	}
+	get := func(row []string, name string) string {
+		if i, ok := col[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+
+	var records []record
+	for n, row := range rows[1:] {
+		line := n + 2
+		rec := record{Description: get(row, "description"), Due: get(row, "due")}
+		var err error
+		if s := get(row, "id"); s != "" {
+			if rec.ID, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid id %q", line, s)
+			}
+		}
+		if s := get(row, "created"); s != "" {
+			if rec.Created, err = time.Parse(time.RFC3339Nano, s); err != nil {
+				return nil, fmt.Errorf("line %d: invalid created time %q", line, s)
+			}
+		}
+		if s := get(row, "done"); s != "" {
+			if rec.Done, err = strconv.ParseBool(s); err != nil {
+				return nil, fmt.Errorf("line %d: invalid done value %q", line, s)
+			}
+		}
+		if s := get(row, "priority"); s != "" {
+			if rec.Priority, err = strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("line %d: invalid priority %q", line, s)
+			}
+		}
+		if s := get(row, "tags"); s != "" {
+			rec.Tags = strings.Split(s, ";")
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
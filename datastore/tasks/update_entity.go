@@ -17,30 +17,29 @@ package main
 // [START datastore_update_entity]
 import (
 	"context"
-	"log"
 
 	"cloud.google.com/go/datastore"
 )
 
-// MarkDone marks the task done with the given ID.
-func MarkDone(projectID string, taskID int64) error {
-	ctx := context.Background()
-	client, err := datastore.NewClient(ctx, projectID)
-	if err != nil {
-		log.Fatalf("Could not create datastore client: %v", err)
+// MarkDone marks the tasks with the given IDs done. Either all of them are
+// marked done or, if any of them doesn't exist, none are.
+func MarkDone(ctx context.Context, client *datastore.Client, taskIDs ...int64) error {
+	// Create keys using the given integer IDs.
+	keys := make([]*datastore.Key, len(taskIDs))
+	for i, id := range taskIDs {
+		keys[i] = datastore.IDKey("Task", id, nil)
 	}
-	defer client.Close()
-	// Create a key using the given integer ID.
-	key := datastore.IDKey("Task", taskID, nil)
 
 	// In a transaction load each task, set done to true and store.
-	_, err = client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
-		var task Task
-		if err := tx.Get(key, &task); err != nil {
+	_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		tasks := make([]Task, len(keys))
+		if err := tx.GetMulti(keys, tasks); err != nil {
 			return err
 		}
-		task.Done = true
-		_, err := tx.Put(key, &task)
+		for i := range tasks {
+			tasks[i].Done = true
+		}
+		_, err := tx.PutMulti(keys, tasks)
 		return err
 	})
 	return err
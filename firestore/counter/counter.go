@@ -0,0 +1,537 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package counter is a distributed counter on Firestore that adapts its
+// number of shards to the write rate.
+//
+// A counter is a document and a "shards" subcollection, with the same
+// layout as the sharded counter solution in the parent directory: each shard
+// document has a Count field, and the value of the counter is the sum of
+// Count over all shards. The counter document records the number of shards
+// writers spread their increments over, and a cached rollup of the value.
+//
+// When an increment fails because of contention on its shard, the counter
+// doubles the number of shards, up to Options.MaxShards. After a quiet
+// period without contention it halves them, down to Options.MinShards, by
+// folding the removed shards into shard 0 in a transaction.
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	firestorepb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	shardsCollection = "shards"
+	countField       = "Count"
+)
+
+// Options configure a Counter. Zero values select the defaults.
+type Options struct {
+	// InitialShards is the number of shards of a new counter. Default 1.
+	InitialShards int
+	// MinShards and MaxShards bound the number of shards. Defaults 1 and 64.
+	MinShards, MaxShards int
+	// GrowInterval is the minimum time between two resizes caused by
+	// contention, so that a burst of contention errors doubles the shards
+	// once. Default 1s.
+	GrowInterval time.Duration
+	// ShrinkAfter is how long increments must go without contention before
+	// the counter halves its shards. Default 10 minutes. Negative disables
+	// shrinking.
+	ShrinkAfter time.Duration
+	// MaxAttempts is how many shards an increment tries before returning
+	// a contention error. Default 5.
+	MaxAttempts int
+	// RefreshInterval is how often the counter rereads the number of shards
+	// other processes may have changed. Default 30s.
+	RefreshInterval time.Duration
+}
+
+func (o *Options) setDefaults() {
+	if o.InitialShards <= 0 {
+		o.InitialShards = 1
+	}
+	if o.MinShards <= 0 {
+		o.MinShards = 1
+	}
+	if o.MaxShards <= 0 {
+		o.MaxShards = 64
+	}
+	if o.MaxShards < o.MinShards {
+		o.MaxShards = o.MinShards
+	}
+	o.InitialShards = clamp(o.InitialShards, o.MinShards, o.MaxShards)
+	if o.GrowInterval == 0 {
+		o.GrowInterval = time.Second
+	}
+	if o.ShrinkAfter == 0 {
+		o.ShrinkAfter = 10 * time.Minute
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = 30 * time.Second
+	}
+}
+
+// state is the counter document.
+type state struct {
+	NumShards  int
+	Rollup     int64
+	RollupTime time.Time
+}
+
+// Counter is a distributed counter. Its methods are safe for concurrent use.
+type Counter struct {
+	client *firestore.Client
+	ref    *firestore.DocumentRef
+	opts   Options
+
+	// now returns the current time. It defaults to time.Now.
+	now func() time.Time
+
+	mu             sync.Mutex
+	rand           *rand.Rand
+	numShards      int
+	lastRefresh    time.Time
+	lastResize     time.Time
+	lastContention time.Time
+}
+
+// New returns the counter stored in the document ref, creating it with
+// opts.InitialShards shards if it doesn't exist.
+func New(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, opts Options) (*Counter, error) {
+	opts.setDefaults()
+	c := &Counter{
+		client: client,
+		ref:    ref,
+		opts:   opts,
+		now:    time.Now,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	var n int
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		s, err := getState(tx, ref)
+		if err != nil {
+			return err
+		}
+		if s.NumShards > 0 {
+			n = s.NumShards
+			return nil
+		}
+		n = opts.InitialShards
+		return tx.Set(ref, map[string]interface{}{"NumShards": n}, firestore.MergeAll)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RunTransaction: %w", err)
+	}
+	c.numShards = n
+	c.lastRefresh = c.now()
+	c.lastResize = c.lastRefresh
+	return c, nil
+}
+
+// getState reads the counter document. A missing document is a zero state.
+func getState(tx *firestore.Transaction, ref *firestore.DocumentRef) (state, error) {
+	var s state
+	doc, err := tx.Get(ref)
+	if status.Code(err) == codes.NotFound {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("Get: %w", err)
+	}
+	if err := doc.DataTo(&s); err != nil {
+		return s, fmt.Errorf("DataTo: %w", err)
+	}
+	return s, nil
+}
+
+// NumShards returns the number of shards increments currently spread over.
+func (c *Counter) NumShards() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.numShards
+}
+
+func (c *Counter) shard(i int) *firestore.DocumentRef {
+	return c.ref.Collection(shardsCollection).Doc(strconv.Itoa(i))
+}
+
+// isContention reports whether err means a write lost to concurrent writes
+// of the same document, and was not applied.
+func isContention(err error) bool {
+	switch status.Code(err) {
+	case codes.Aborted, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// Increment adds delta to the counter. When the write contends with others,
+// it retries on another shard and grows the number of shards. It returns nil
+// once the increment is written, even if shrinking the shards afterwards
+// fails; that error is logged.
+func (c *Counter) Increment(ctx context.Context, delta int64) error {
+	if err := c.maybeRefresh(ctx); err != nil {
+		return err
+	}
+	var err error
+	for attempt := 0; attempt < c.opts.MaxAttempts; attempt++ {
+		c.mu.Lock()
+		i := c.rand.Intn(c.numShards)
+		c.mu.Unlock()
+
+		// Shards are created by their first increment, so growing the
+		// counter doesn't need to write the new shards.
+		_, err = c.shard(i).Set(ctx, map[string]interface{}{
+			countField: firestore.Increment(delta),
+		}, firestore.MergeAll)
+		if err == nil {
+			// The increment is applied: a failed shrink only leaves
+			// more shards than needed, and is retried after another
+			// ShrinkAfter, so it isn't an error of the increment.
+			if serr := c.maybeShrink(ctx); serr != nil {
+				log.Printf("counter %s: shrinking shards: %v", c.ref.Path, serr)
+			}
+			return nil
+		}
+		if !isContention(err) {
+			return fmt.Errorf("Set: %w", err)
+		}
+		if gerr := c.grow(ctx); gerr != nil {
+			return gerr
+		}
+	}
+	return fmt.Errorf("increment failed after %d attempts: %w", c.opts.MaxAttempts, err)
+}
+
+// Decrement subtracts delta from the counter.
+func (c *Counter) Decrement(ctx context.Context, delta int64) error {
+	return c.Increment(ctx, -delta)
+}
+
+// maybeRefresh rereads the number of shards if it's older than
+// RefreshInterval.
+func (c *Counter) maybeRefresh(ctx context.Context) error {
+	c.mu.Lock()
+	due := c.now().Sub(c.lastRefresh) >= c.opts.RefreshInterval
+	c.mu.Unlock()
+	if !due {
+		return nil
+	}
+	_, err := c.refresh(ctx)
+	return err
+}
+
+func (c *Counter) refresh(ctx context.Context) (state, error) {
+	var s state
+	doc, err := c.ref.Get(ctx)
+	if err != nil {
+		return s, fmt.Errorf("Get: %w", err)
+	}
+	if err := doc.DataTo(&s); err != nil {
+		return s, fmt.Errorf("DataTo: %w", err)
+	}
+	c.mu.Lock()
+	if s.NumShards > 0 {
+		c.numShards = s.NumShards
+	}
+	c.lastRefresh = c.now()
+	c.mu.Unlock()
+	return s, nil
+}
+
+// grow doubles the number of shards after contention, unless it was
+// resized less than GrowInterval ago.
+func (c *Counter) grow(ctx context.Context) error {
+	c.mu.Lock()
+	now := c.now()
+	c.lastContention = now
+	n := c.numShards
+	due := now.Sub(c.lastResize) >= c.opts.GrowInterval && n < c.opts.MaxShards
+	if due {
+		// Claim the resize, so concurrent increments don't all try.
+		c.lastResize = now
+	}
+	c.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return c.resize(ctx, n, clamp(2*n, c.opts.MinShards, c.opts.MaxShards))
+}
+
+// maybeShrink halves the number of shards if there was no contention for
+// ShrinkAfter.
+func (c *Counter) maybeShrink(ctx context.Context) error {
+	if c.opts.ShrinkAfter < 0 {
+		return nil
+	}
+	c.mu.Lock()
+	now := c.now()
+	n := c.numShards
+	due := n > c.opts.MinShards &&
+		now.Sub(c.lastContention) >= c.opts.ShrinkAfter &&
+		now.Sub(c.lastResize) >= c.opts.ShrinkAfter
+	if due {
+		c.lastResize = now
+	}
+	c.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return c.resize(ctx, n, clamp(n/2, c.opts.MinShards, c.opts.MaxShards))
+}
+
+// Resize sets the number of shards to n, within MinShards and MaxShards.
+func (c *Counter) Resize(ctx context.Context, n int) error {
+	c.mu.Lock()
+	from := c.numShards
+	c.lastResize = c.now()
+	c.mu.Unlock()
+	return c.resize(ctx, from, clamp(n, c.opts.MinShards, c.opts.MaxShards))
+}
+
+// resize changes the number of shards from from to to. It does nothing if
+// another process already changed the number of shards.
+//
+// Shrinking adds the shards being removed to shard 0 and deletes them. A
+// writer that hasn't yet seen the new number of shards can recreate a
+// removed shard; that's fine, because reads sum every shard document, and
+// the next shrink folds it again.
+func (c *Counter) resize(ctx context.Context, from, to int) error {
+	var got int
+	err := c.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		s, err := getState(tx, c.ref)
+		if err != nil {
+			return err
+		}
+		got = s.NumShards
+		if s.NumShards != from || from == to {
+			return nil
+		}
+		if to < from {
+			// Read every shard: extra ones may have been recreated.
+			docs, err := tx.Documents(c.ref.Collection(shardsCollection)).GetAll()
+			if err != nil {
+				return fmt.Errorf("GetAll: %w", err)
+			}
+			var folded int64
+			var remove []*firestore.DocumentRef
+			for _, doc := range docs {
+				i, err := strconv.Atoi(doc.Ref.ID)
+				if err != nil || i < to {
+					continue
+				}
+				v, err := shardCount(doc)
+				if err != nil {
+					return err
+				}
+				folded += v
+				remove = append(remove, doc.Ref)
+			}
+			for _, ref := range remove {
+				if err := tx.Delete(ref); err != nil {
+					return err
+				}
+			}
+			if folded != 0 {
+				if err := tx.Set(c.shard(0), map[string]interface{}{
+					countField: firestore.Increment(folded),
+				}, firestore.MergeAll); err != nil {
+					return err
+				}
+			}
+		}
+		got = to
+		return tx.Update(c.ref, []firestore.Update{{Path: "NumShards", Value: to}})
+	})
+	if err != nil {
+		return fmt.Errorf("resize from %d to %d shards: %w", from, to, err)
+	}
+	c.mu.Lock()
+	if got > 0 {
+		c.numShards = got
+	}
+	c.lastRefresh = c.now()
+	c.mu.Unlock()
+	return nil
+}
+
+func shardCount(doc *firestore.DocumentSnapshot) (int64, error) {
+	v, err := doc.DataAt(countField)
+	if err != nil {
+		return 0, fmt.Errorf("DataAt(%s): %w", doc.Ref.ID, err)
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("shard %s: invalid dataType %T, want int64", doc.Ref.ID, v)
+	}
+	return n, nil
+}
+
+// Sum returns the value of the counter, computed by a sum() aggregation
+// query over the shards. It costs one read per 1000 shards.
+func (c *Counter) Sum(ctx context.Context) (int64, error) {
+	res, err := c.ref.Collection(shardsCollection).NewAggregationQuery().
+		WithSum(countField, "total").Get(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Get: %w", err)
+	}
+	v, ok := res["total"].(*firestorepb.Value)
+	if !ok {
+		return 0, errors.New("firestore: couldn't get alias for SUM from results")
+	}
+	switch v := v.GetValueType().(type) {
+	case *firestorepb.Value_IntegerValue:
+		return v.IntegerValue, nil
+	case *firestorepb.Value_DoubleValue:
+		// The sum of integers overflowed, or a shard isn't an integer.
+		return 0, fmt.Errorf("sum isn't an integer: %v", v.DoubleValue)
+	case *firestorepb.Value_NullValue:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("invalid sum %v", v)
+	}
+}
+
+// Scan returns the value of the counter by reading every shard document.
+// It costs one read per shard, and is what Sum replaces.
+func (c *Counter) Scan(ctx context.Context) (int64, error) {
+	var total int64
+	shards := c.ref.Collection(shardsCollection).Documents(ctx)
+	defer shards.Stop()
+	for {
+		doc, err := shards.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Next: %w", err)
+		}
+		v, err := shardCount(doc)
+		if err != nil {
+			return 0, err
+		}
+		total += v
+	}
+	return total, nil
+}
+
+// Value returns the cached rollup of the counter if it is at most
+// maxStaleness old, reading a single document. Otherwise, it computes the
+// value with Sum and updates the rollup.
+func (c *Counter) Value(ctx context.Context, maxStaleness time.Duration) (int64, error) {
+	s, err := c.refresh(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !s.RollupTime.IsZero() && c.now().Sub(s.RollupTime) <= maxStaleness {
+		return s.Rollup, nil
+	}
+	return c.Rollup(ctx)
+}
+
+// Rollup computes the value of the counter with Sum, and caches it in the
+// counter document for Value.
+func (c *Counter) Rollup(ctx context.Context) (int64, error) {
+	total, err := c.Sum(ctx)
+	if err != nil {
+		return 0, err
+	}
+	_, err = c.ref.Update(ctx, []firestore.Update{
+		{Path: "Rollup", Value: total},
+		{Path: "RollupTime", Value: c.now()},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Update: %w", err)
+	}
+	return total, nil
+}
+
+// A Batch applies increments to several counters atomically.
+type Batch struct {
+	client *firestore.Client
+	deltas map[*Counter]int64
+	order  []*Counter
+}
+
+// NewBatch returns an empty batch.
+func NewBatch(client *firestore.Client) *Batch {
+	return &Batch{client: client, deltas: make(map[*Counter]int64)}
+}
+
+// Add adds delta to the increment of c in the batch. Increments of the same
+// counter are combined into a single write.
+func (b *Batch) Add(c *Counter, delta int64) {
+	if _, ok := b.deltas[c]; !ok {
+		b.order = append(b.order, c)
+	}
+	b.deltas[c] += delta
+}
+
+// Commit applies the increments in a single transaction, which Firestore
+// retries on contention. It writes at most 500 counters.
+func (b *Batch) Commit(ctx context.Context) error {
+	if len(b.order) > 500 {
+		return fmt.Errorf("batch has %d counters, want at most 500", len(b.order))
+	}
+	err := b.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		for _, c := range b.order {
+			delta := b.deltas[c]
+			if delta == 0 {
+				continue
+			}
+			c.mu.Lock()
+			i := c.rand.Intn(c.numShards)
+			c.mu.Unlock()
+			if err := tx.Set(c.shard(i), map[string]interface{}{
+				countField: firestore.Increment(delta),
+			}, firestore.MergeAll); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("RunTransaction: %w", err)
+	}
+	b.deltas = make(map[*Counter]int64)
+	b.order = nil
+	return nil
+}
+
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
@@ -0,0 +1,295 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package counter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsContention(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{status.Error(codes.Aborted, "too much contention"), true},
+		{status.Error(codes.ResourceExhausted, "write rate"), true},
+		{fmt.Errorf("Set: %w", status.Error(codes.Aborted, "")), true},
+		// The write may have been applied, so it isn't retried.
+		{status.Error(codes.DeadlineExceeded, ""), false},
+		{status.Error(codes.NotFound, ""), false},
+		{errors.New("other"), false},
+	}
+	for _, tc := range tests {
+		if got := isContention(tc.err); got != tc.want {
+			t.Errorf("isContention(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	o := Options{InitialShards: 100, MinShards: 2, MaxShards: 8}
+	o.setDefaults()
+	if o.InitialShards != 8 || o.MinShards != 2 || o.MaxShards != 8 {
+		t.Errorf("shards = %d in [%d, %d], want 8 in [2, 8]", o.InitialShards, o.MinShards, o.MaxShards)
+	}
+	o = Options{MinShards: 4, MaxShards: 2}
+	o.setDefaults()
+	if o.InitialShards != 4 || o.MaxShards != 4 {
+		t.Errorf("shards = %d, max %d, want 4, max 4", o.InitialShards, o.MaxShards)
+	}
+}
+
+// newTestClient returns a client of the Firestore emulator, and a unique
+// document for a counter.
+func newTestClient(t *testing.T) (*firestore.Client, *firestore.DocumentRef) {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("Skipping emulator test. Set FIRESTORE_EMULATOR_HOST.")
+	}
+	client, err := firestore.NewClient(context.Background(), "counter-test")
+	if err != nil {
+		t.Fatalf("firestore.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, client.Collection("counters").Doc(fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano()))
+}
+
+func checkValue(t *testing.T, c *Counter, want int64) {
+	t.Helper()
+	ctx := context.Background()
+	sum, err := c.Sum(ctx)
+	if err != nil {
+		t.Fatalf("Sum: %v", err)
+	}
+	scan, err := c.Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if sum != want || scan != want {
+		t.Errorf("Sum, Scan = %d, %d, want %d", sum, scan, want)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	ctx := context.Background()
+	client, ref := newTestClient(t)
+
+	c, err := New(ctx, client, ref, Options{InitialShards: 4})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	checkValue(t, c, 0)
+	for i := 0; i < 10; i++ {
+		if err := c.Increment(ctx, 3); err != nil {
+			t.Fatalf("Increment: %v", err)
+		}
+	}
+	if err := c.Decrement(ctx, 5); err != nil {
+		t.Fatalf("Decrement: %v", err)
+	}
+	checkValue(t, c, 25)
+
+	// Another process opening the counter keeps its shards.
+	c2, err := New(ctx, client, ref, Options{InitialShards: 1})
+	if err != nil {
+		t.Fatalf("New again: %v", err)
+	}
+	if got := c2.NumShards(); got != 4 {
+		t.Errorf("NumShards = %d, want 4", got)
+	}
+}
+
+func TestResize(t *testing.T) {
+	ctx := context.Background()
+	client, ref := newTestClient(t)
+
+	c, err := New(ctx, client, ref, Options{InitialShards: 2, MaxShards: 16, ShrinkAfter: -1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := c.Resize(ctx, 16); err != nil {
+		t.Fatalf("Resize(16): %v", err)
+	}
+	for i := 0; i < 16; i++ {
+		if _, err := c.shard(i).Set(ctx, map[string]interface{}{countField: int64(i)}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	checkValue(t, c, 120)
+
+	// A process that hasn't seen the resize doesn't undo it.
+	stale, err := New(ctx, client, ref, Options{MaxShards: 16})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := c.Resize(ctx, 3); err != nil {
+		t.Fatalf("Resize(3): %v", err)
+	}
+	if err := stale.Resize(ctx, 1); err != nil {
+		t.Fatalf("stale Resize(1): %v", err)
+	}
+	if got := c.NumShards(); got != 3 {
+		t.Errorf("NumShards = %d, want 3", got)
+	}
+	if got := stale.NumShards(); got != 3 {
+		t.Errorf("stale NumShards = %d, want 3", got)
+	}
+	docs, err := ref.Collection(shardsCollection).DocumentRefs(ctx).GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(docs) != 3 {
+		t.Errorf("%d shard documents, want 3", len(docs))
+	}
+	checkValue(t, c, 120)
+
+	// A write to a removed shard still counts, and is folded again.
+	if _, err := c.shard(10).Set(ctx, map[string]interface{}{countField: int64(5)}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	checkValue(t, c, 125)
+	if err := c.Resize(ctx, 1); err != nil {
+		t.Fatalf("Resize(1): %v", err)
+	}
+	checkValue(t, c, 125)
+}
+
+func TestConcurrentResize(t *testing.T) {
+	ctx := context.Background()
+	client, ref := newTestClient(t)
+
+	c, err := New(ctx, client, ref, Options{InitialShards: 8, ShrinkAfter: -1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if err := c.Increment(ctx, 1); err != nil {
+					t.Errorf("Increment: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for _, n := range []int{2, 16, 1, 4} {
+		if err := c.Resize(ctx, n); err != nil {
+			t.Fatalf("Resize(%d): %v", n, err)
+		}
+	}
+	wg.Wait()
+	checkValue(t, c, 100)
+}
+
+func TestShrinkAfterQuietPeriod(t *testing.T) {
+	ctx := context.Background()
+	client, ref := newTestClient(t)
+
+	c, err := New(ctx, client, ref, Options{InitialShards: 8, ShrinkAfter: time.Minute})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	if err := c.Increment(ctx, 1); err != nil {
+		t.Fatalf("Increment: %v", err)
+	}
+	if got := c.NumShards(); got != 8 {
+		t.Errorf("NumShards = %d, want 8", got)
+	}
+	now = now.Add(time.Minute)
+	if err := c.Increment(ctx, 1); err != nil {
+		t.Fatalf("Increment: %v", err)
+	}
+	if got := c.NumShards(); got != 4 {
+		t.Errorf("NumShards after a quiet minute = %d, want 4", got)
+	}
+	checkValue(t, c, 2)
+}
+
+func TestValue(t *testing.T) {
+	ctx := context.Background()
+	client, ref := newTestClient(t)
+
+	c, err := New(ctx, client, ref, Options{InitialShards: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	value := func(maxStaleness time.Duration) int64 {
+		t.Helper()
+		v, err := c.Value(ctx, maxStaleness)
+		if err != nil {
+			t.Fatalf("Value: %v", err)
+		}
+		return v
+	}
+
+	if err := c.Increment(ctx, 7); err != nil {
+		t.Fatalf("Increment: %v", err)
+	}
+	if got := value(time.Minute); got != 7 {
+		t.Errorf("Value = %d, want 7", got)
+	}
+	if err := c.Increment(ctx, 1); err != nil {
+		t.Fatalf("Increment: %v", err)
+	}
+	if got := value(time.Minute); got != 7 {
+		t.Errorf("cached Value = %d, want 7", got)
+	}
+	now = now.Add(2 * time.Minute)
+	if got := value(time.Minute); got != 8 {
+		t.Errorf("stale Value = %d, want 8", got)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	client, ref := newTestClient(t)
+
+	a, err := New(ctx, client, ref, Options{InitialShards: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	b, err := New(ctx, client, client.Collection("counters").Doc(ref.ID+"-b"), Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	batch := NewBatch(client)
+	batch.Add(a, 2)
+	batch.Add(b, 10)
+	batch.Add(a, 3)
+	batch.Add(b, -10)
+	if err := batch.Commit(ctx); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	checkValue(t, a, 5)
+	checkValue(t, b, 0)
+}
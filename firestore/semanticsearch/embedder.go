@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semanticsearch
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// A Task is what an embedding is for. Embedding models such as
+// gemini-embedding-001 take it as their task type, and embed documents and
+// queries differently.
+type Task string
+
+const (
+	RetrievalDocument Task = "RETRIEVAL_DOCUMENT"
+	RetrievalQuery    Task = "RETRIEVAL_QUERY"
+)
+
+// An Embedder computes embeddings of texts.
+//
+// To use Vertex AI, wrap the Models.EmbedContent method of a
+// google.golang.org/genai client, as in genai/embeddings, with one part
+// per text and the task as the TaskType of the config.
+type Embedder interface {
+	// Embed returns one embedding per text, each of Dimension values.
+	Embed(ctx context.Context, texts []string, task Task) ([][]float32, error)
+	// Dimension is the length of the embeddings.
+	Dimension() int
+}
+
+// HashEmbedder is a deterministic local Embedder for tests and
+// development. It hashes the words and word pairs of a text into Dim
+// buckets, and normalizes the result: texts sharing words are close, but
+// synonyms aren't.
+type HashEmbedder struct {
+	Dim int
+}
+
+// Dimension implements Embedder.
+func (e HashEmbedder) Dimension() int { return e.Dim }
+
+// Embed implements Embedder. It ignores the task.
+func (e HashEmbedder) Embed(_ context.Context, texts []string, _ Task) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = e.embed(text)
+	}
+	return out, nil
+}
+
+func (e HashEmbedder) embed(text string) []float32 {
+	v := make([]float32, e.Dim)
+	add := func(feature string, weight float32) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// The top bit picks a sign, so unrelated features cancel out
+		// instead of piling up.
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		v[sum%uint64(e.Dim)] += weight
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, w := range words {
+		add(w, 1)
+		if i > 0 {
+			add(words[i-1]+" "+w, 0.5)
+		}
+	}
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= scale
+	}
+	return v
+}
@@ -0,0 +1,337 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package semanticsearch is a semantic search index on Firestore vector
+// fields.
+//
+// Ingest embeds documents with an Embedder, and stores each one with its
+// text, metadata and embedding in a collection. Search embeds a query, and
+// runs a FindNearest query over the collection, optionally filtered on
+// metadata, limited by a distance threshold and returning only some fields,
+// like the vector_search_* samples in the parent directory.
+//
+// Searches need a vector index on the embedding field, and a composite
+// vector index for each combination of filtered metadata fields; see
+// IndexFields. The Firestore emulator doesn't need indexes.
+package semanticsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/admin/adminpb"
+	"google.golang.org/api/iterator"
+)
+
+// Fields of the stored documents.
+const (
+	TextField      = "text"
+	MetadataField  = "metadata"
+	EmbeddingField = "embedding"
+	UpdatedField   = "updated"
+
+	// distanceField holds the distance of search results. It is never
+	// stored.
+	distanceField = "vector_distance"
+)
+
+// Firestore limits.
+const (
+	maxDimension = 2048
+	maxLimit     = 1000
+	maxBatch     = 250
+)
+
+// A Document is a text to index.
+type Document struct {
+	// ID is the Firestore document ID. Ingest generates one if it's empty.
+	ID   string
+	Text string
+	// Metadata is stored alongside the embedding, and can be filtered on.
+	Metadata map[string]interface{}
+}
+
+// Options configure an Index. Zero values select the defaults.
+type Options struct {
+	// Measure is the distance measure of searches. Default cosine.
+	Measure firestore.DistanceMeasure
+	// BatchSize is how many texts Ingest embeds per Embed call. Default 100.
+	BatchSize int
+}
+
+// Index is a semantic search index stored in a collection.
+type Index struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+	embedder   Embedder
+	opts       Options
+
+	// now returns the current time. It defaults to time.Now.
+	now func() time.Time
+}
+
+// New returns an index stored in collection, using embedder for documents
+// and queries.
+func New(client *firestore.Client, collection string, embedder Embedder, opts Options) (*Index, error) {
+	if d := embedder.Dimension(); d <= 0 || d > maxDimension {
+		return nil, fmt.Errorf("embedding dimension %d, want 1 to %d", d, maxDimension)
+	}
+	if opts.Measure == 0 {
+		opts.Measure = firestore.DistanceMeasureCosine
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > maxBatch {
+		opts.BatchSize = 100
+	}
+	return &Index{
+		client:     client,
+		collection: client.Collection(collection),
+		embedder:   embedder,
+		opts:       opts,
+		now:        time.Now,
+	}, nil
+}
+
+// IndexFields returns the fields of the composite index that searches
+// filtering on the given metadata fields need, for a CreateIndexRequest of
+// the Firestore admin API. Without filter fields, it is the plain vector
+// index.
+func (ix *Index) IndexFields(filterFields ...string) []*adminpb.Index_IndexField {
+	var fields []*adminpb.Index_IndexField
+	for _, f := range filterFields {
+		fields = append(fields, &adminpb.Index_IndexField{
+			FieldPath: MetadataField + "." + f,
+			ValueMode: &adminpb.Index_IndexField_Order_{
+				Order: adminpb.Index_IndexField_ASCENDING,
+			},
+		})
+	}
+	return append(fields, &adminpb.Index_IndexField{
+		FieldPath: EmbeddingField,
+		ValueMode: &adminpb.Index_IndexField_VectorConfig_{
+			VectorConfig: &adminpb.Index_IndexField_VectorConfig{
+				Dimension: int32(ix.embedder.Dimension()),
+				Type: &adminpb.Index_IndexField_VectorConfig_Flat{
+					Flat: &adminpb.Index_IndexField_VectorConfig_FlatIndex{},
+				},
+			},
+		},
+	})
+}
+
+// Ingest embeds and stores docs, replacing documents with the same IDs. It
+// returns the IDs of the documents, in order.
+func (ix *Index) Ingest(ctx context.Context, docs []Document) ([]string, error) {
+	ids := make([]string, len(docs))
+	for start := 0; start < len(docs); start += ix.opts.BatchSize {
+		end := start + ix.opts.BatchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		batch := docs[start:end]
+		texts := make([]string, len(batch))
+		for i, d := range batch {
+			texts[i] = d.Text
+		}
+		vecs, err := ix.embed(ctx, texts, RetrievalDocument)
+		if err != nil {
+			return nil, err
+		}
+
+		bw := ix.client.BulkWriter(ctx)
+		jobs := make([]*firestore.BulkWriterJob, len(batch))
+		for i, d := range batch {
+			ref := ix.collection.NewDoc()
+			if d.ID != "" {
+				ref = ix.collection.Doc(d.ID)
+			}
+			ids[start+i] = ref.ID
+			jobs[i], err = bw.Set(ref, map[string]interface{}{
+				TextField:      d.Text,
+				MetadataField:  d.Metadata,
+				EmbeddingField: firestore.Vector32(vecs[i]),
+				UpdatedField:   ix.now(),
+			})
+			if err != nil {
+				bw.End()
+				return nil, fmt.Errorf("BulkWriter.Set(%s): %w", ref.ID, err)
+			}
+		}
+		bw.End()
+		for i, job := range jobs {
+			if _, err := job.Results(); err != nil {
+				return nil, fmt.Errorf("write %s: %w", ids[start+i], err)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// embed embeds texts and checks the embedder's results.
+func (ix *Index) embed(ctx context.Context, texts []string, task Task) ([][]float32, error) {
+	vecs, err := ix.embedder.Embed(ctx, texts, task)
+	if err != nil {
+		return nil, fmt.Errorf("Embed: %w", err)
+	}
+	if len(vecs) != len(texts) {
+		return nil, fmt.Errorf("Embed returned %d embeddings for %d texts", len(vecs), len(texts))
+	}
+	for _, v := range vecs {
+		if len(v) != ix.embedder.Dimension() {
+			return nil, fmt.Errorf("Embed returned an embedding of dimension %d, want %d", len(v), ix.embedder.Dimension())
+		}
+	}
+	return vecs, nil
+}
+
+// Delete deletes the documents with the given IDs.
+func (ix *Index) Delete(ctx context.Context, ids ...string) error {
+	bw := ix.client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, len(ids))
+	for i, id := range ids {
+		var err error
+		if jobs[i], err = bw.Delete(ix.collection.Doc(id)); err != nil {
+			bw.End()
+			return fmt.Errorf("BulkWriter.Delete(%s): %w", id, err)
+		}
+	}
+	bw.End()
+	for i, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return fmt.Errorf("delete %s: %w", ids[i], err)
+		}
+	}
+	return nil
+}
+
+// A Filter restricts a search to documents whose metadata field Field
+// compares to Value with Op, one of the operators of firestore.Query.Where.
+type Filter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// A Query is a semantic search.
+type Query struct {
+	Text string
+	// Limit is the maximum number of results, at most 1000. Default 10.
+	Limit int
+	// Filters must all match.
+	Filters []Filter
+	// DistanceThreshold, if set, drops results further than it from the
+	// query. With the dot product measure, where larger is closer, it drops
+	// results with a smaller dot product instead.
+	DistanceThreshold *float64
+	// Fields, if set, are the only metadata fields returned. Use an empty,
+	// non-nil slice to return no metadata.
+	Fields []string
+	// OmitText doesn't return the text of the results.
+	OmitText bool
+}
+
+// A Result is a document matching a query.
+type Result struct {
+	ID       string
+	Text     string
+	Metadata map[string]interface{}
+	Distance float64
+}
+
+var validOps = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"in": true, "not-in": true, "array-contains": true, "array-contains-any": true,
+}
+
+func (q *Query) validate() error {
+	if q.Text == "" {
+		return errors.New("empty query text")
+	}
+	if q.Limit < 0 || q.Limit > maxLimit {
+		return fmt.Errorf("limit %d, want 1 to %d", q.Limit, maxLimit)
+	}
+	for _, f := range q.Filters {
+		if f.Field == "" {
+			return errors.New("filter without a field")
+		}
+		if !validOps[f.Op] {
+			return fmt.Errorf("invalid filter operator %q", f.Op)
+		}
+	}
+	return nil
+}
+
+// Search returns the documents nearest to q, nearest first.
+func (ix *Index) Search(ctx context.Context, q Query) ([]Result, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = 10
+	}
+	vecs, err := ix.embed(ctx, []string{q.Text}, RetrievalQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	query := ix.collection.Query
+	for _, f := range q.Filters {
+		query = query.Where(MetadataField+"."+f.Field, f.Op, f.Value)
+	}
+	// Never return the embeddings: they're large, and not useful to
+	// callers.
+	fields := []string{distanceField}
+	if !q.OmitText {
+		fields = append(fields, TextField)
+	}
+	if q.Fields == nil {
+		fields = append(fields, MetadataField)
+	}
+	for _, f := range q.Fields {
+		fields = append(fields, MetadataField+"."+f)
+	}
+	query = query.Select(fields...)
+
+	docs := query.FindNearest(EmbeddingField, vecs[0], limit, ix.opts.Measure, &firestore.FindNearestOptions{
+		DistanceThreshold:   q.DistanceThreshold,
+		DistanceResultField: distanceField,
+	}).Documents(ctx)
+	defer docs.Stop()
+
+	var results []Result
+	for {
+		doc, err := docs.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Next: %w", err)
+		}
+		data := doc.Data()
+		r := Result{ID: doc.Ref.ID}
+		r.Text, _ = data[TextField].(string)
+		r.Metadata, _ = data[MetadataField].(map[string]interface{})
+		switch d := data[distanceField].(type) {
+		case float64:
+			r.Distance = d
+		case int64:
+			r.Distance = float64(d)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semanticsearch
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func dot(a, b []float32) float64 {
+	var s float64
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
+
+func TestHashEmbedder(t *testing.T) {
+	ctx := context.Background()
+	e := HashEmbedder{Dim: 64}
+	texts := []string{
+		"Dark roast coffee beans from Kenya",
+		"dark roast, coffee beans from kenya!",
+		"Light roast coffee beans",
+		"Green tea leaves",
+		"",
+	}
+	vecs, err := e.Embed(ctx, texts, RetrievalDocument)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	again, _ := e.Embed(ctx, texts, RetrievalQuery)
+	if diff := cmp.Diff(vecs, again); diff != "" {
+		t.Errorf("Embed isn't deterministic (-first +second):\n%s", diff)
+	}
+	for i, v := range vecs[:4] {
+		if len(v) != 64 {
+			t.Fatalf("len(embedding %d) = %d, want 64", i, len(v))
+		}
+		if n := math.Sqrt(dot(v, v)); math.Abs(n-1) > 1e-5 {
+			t.Errorf("norm of embedding %d = %v, want 1", i, n)
+		}
+	}
+	if sim := dot(vecs[0], vecs[1]); math.Abs(sim-1) > 1e-5 {
+		t.Errorf("case and punctuation changed the embedding: similarity %v", sim)
+	}
+	if near, far := dot(vecs[0], vecs[2]), dot(vecs[0], vecs[3]); near <= far {
+		t.Errorf("similarity to light roast %v <= to tea %v", near, far)
+	}
+	if n := dot(vecs[4], vecs[4]); n != 0 {
+		t.Errorf("empty text embedding has norm %v, want 0", n)
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       Query
+		wantErr bool
+	}{
+		{"ok", Query{Text: "x", Limit: 5, Filters: []Filter{{"color", "==", "red"}}}, false},
+		{"no text", Query{}, true},
+		{"limit too large", Query{Text: "x", Limit: 1001}, true},
+		{"negative limit", Query{Text: "x", Limit: -1}, true},
+		{"bad op", Query{Text: "x", Filters: []Filter{{"color", "=", "red"}}}, true},
+		{"no field", Query{Text: "x", Filters: []Filter{{"", "==", "red"}}}, true},
+	}
+	for _, tc := range tests {
+		if err := tc.q.validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: validate = %v, want error %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+// badEmbedder returns embeddings of the wrong dimension.
+type badEmbedder struct{}
+
+func (badEmbedder) Dimension() int { return 4 }
+
+func (badEmbedder) Embed(_ context.Context, texts []string, _ Task) ([][]float32, error) {
+	return make([][]float32, len(texts)), nil
+}
+
+func TestEmbedChecks(t *testing.T) {
+	ix := &Index{embedder: badEmbedder{}}
+	if _, err := ix.embed(context.Background(), []string{"a"}, RetrievalQuery); err == nil {
+		t.Error("embed with wrong dimension succeeded, want error")
+	}
+	if _, err := New(nil, "c", HashEmbedder{Dim: 4096}, Options{}); err == nil {
+		t.Error("New with dimension 4096 succeeded, want error")
+	}
+}
+
+func TestIndexFields(t *testing.T) {
+	ix := &Index{embedder: HashEmbedder{Dim: 8}}
+	fields := ix.IndexFields("color", "year")
+	var paths []string
+	for _, f := range fields {
+		paths = append(paths, f.GetFieldPath())
+	}
+	if want := []string{"metadata.color", "metadata.year", "embedding"}; !cmp.Equal(paths, want) {
+		t.Errorf("IndexFields paths = %v, want %v", paths, want)
+	}
+	if d := fields[2].GetVectorConfig().GetDimension(); d != 8 {
+		t.Errorf("vector dimension = %d, want 8", d)
+	}
+}
+
+var testDocs = []Document{
+	{ID: "kahawa", Text: "Kahawa dark roast coffee beans from Kenya", Metadata: map[string]interface{}{"color": "red", "year": 2024}},
+	{ID: "owl", Text: "Owl medium roast coffee beans with chocolate notes", Metadata: map[string]interface{}{"color": "brown", "year": 2023}},
+	{ID: "sleepy", Text: "Sleepy decaf coffee beans for the evening", Metadata: map[string]interface{}{"color": "red", "year": 2022}},
+	{ID: "sencha", Text: "Sencha green tea leaves from Japan", Metadata: map[string]interface{}{"color": "green", "year": 2024}},
+}
+
+// newTestIndex returns an index of testDocs in a new collection of the
+// Firestore emulator.
+func newTestIndex(t *testing.T) *Index {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("Skipping emulator test. Set FIRESTORE_EMULATOR_HOST.")
+	}
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "semanticsearch-test")
+	if err != nil {
+		t.Fatalf("firestore.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	ix, err := New(client, fmt.Sprintf("docs-%d", time.Now().UnixNano()), HashEmbedder{Dim: 128}, Options{BatchSize: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ids, err := ix.Ingest(ctx, testDocs)
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if want := []string{"kahawa", "owl", "sleepy", "sencha"}; !cmp.Equal(ids, want) {
+		t.Fatalf("Ingest IDs = %v, want %v", ids, want)
+	}
+	return ix
+}
+
+func resultIDs(rs []Result) []string {
+	var ids []string
+	for _, r := range rs {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	ix := newTestIndex(t)
+
+	rs, err := ix.Search(ctx, Query{Text: "dark roast coffee from Kenya", Limit: 2})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(rs) != 2 || rs[0].ID != "kahawa" {
+		t.Fatalf("Search = %v, want kahawa first of 2", resultIDs(rs))
+	}
+	if rs[0].Text != testDocs[0].Text || rs[0].Metadata["color"] != "red" {
+		t.Errorf("first result = %+v, want text and metadata of kahawa", rs[0])
+	}
+	if rs[0].Distance > rs[1].Distance {
+		t.Errorf("distances %v > %v, want nearest first", rs[0].Distance, rs[1].Distance)
+	}
+
+	// Filters.
+	rs, err = ix.Search(ctx, Query{
+		Text:    "dark roast coffee from Kenya",
+		Filters: []Filter{{"color", "==", "red"}, {"year", "<", 2024}},
+	})
+	if err != nil {
+		t.Fatalf("filtered Search: %v", err)
+	}
+	if got := resultIDs(rs); !cmp.Equal(got, []string{"sleepy"}) {
+		t.Errorf("filtered Search = %v, want [sleepy]", got)
+	}
+
+	// Distance threshold: an unrelated query matches nothing nearby.
+	threshold := 0.5
+	rs, err = ix.Search(ctx, Query{Text: "green tea from Japan", DistanceThreshold: &threshold})
+	if err != nil {
+		t.Fatalf("Search with threshold: %v", err)
+	}
+	if got := resultIDs(rs); !cmp.Equal(got, []string{"sencha"}) {
+		t.Errorf("Search with threshold = %v, want [sencha]", got)
+	}
+	for _, r := range rs {
+		if r.Distance > threshold {
+			t.Errorf("result %s at distance %v > %v", r.ID, r.Distance, threshold)
+		}
+	}
+
+	// Field masking.
+	rs, err = ix.Search(ctx, Query{Text: "coffee", Fields: []string{"year"}, OmitText: true, Limit: 1})
+	if err != nil {
+		t.Fatalf("masked Search: %v", err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("masked Search returned %d results, want 1", len(rs))
+	}
+	if rs[0].Text != "" || rs[0].Metadata["color"] != nil || rs[0].Metadata["year"] == nil {
+		t.Errorf("masked result = %+v, want only metadata.year", rs[0])
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	ix := newTestIndex(t)
+
+	if err := ix.Delete(ctx, "sencha"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	rs, err := ix.Search(ctx, Query{Text: "green tea from Japan"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	for _, r := range rs {
+		if r.ID == "sencha" {
+			t.Errorf("Search returned deleted document")
+		}
+	}
+	if len(rs) != 3 {
+		t.Errorf("Search returned %d results, want 3", len(rs))
+	}
+}
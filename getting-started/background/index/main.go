@@ -14,13 +14,22 @@
 
 // [START getting_started_background_app_main]
 
-// Command index is an HTTP app that displays all previous translations
-// (stored in Firestore) and has a form to request new translations. On form
-// submission, the request is sent to Pub/Sub to be processed in the background.
+// Command index is an HTTP app that displays all previous translations and
+// translation jobs (stored in Firestore) and has a form to request new
+// translations. On form submission, a job is created and the request is sent
+// to Pub/Sub to be processed in the background. The page polls the job list so
+// job states update live.
+//
+// When PUBSUB_EMULATOR_HOST is set, the app also runs the background worker
+// itself, so the whole flow can run locally against the Pub/Sub and Firestore
+// emulators. The local worker uses background.PseudoTranslator unless
+// USE_TRANSLATION_API is set to "true".
 package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -28,16 +37,27 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/translate"
 	"github.com/GoogleCloudPlatform/golang-samples/getting-started/background"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // topicName is the Pub/Sub topic to publish requests to. The Cloud Function to
 // process translation requests should be subscribed to this topic.
 const topicName = "translate"
 
+// localSubscriptionName is the subscription the local worker receives
+// requests from when running against the Pub/Sub emulator.
+const localSubscriptionName = "translate-local"
+
+// maxJobsListed is the number of recent jobs shown on the index page.
+const maxJobsListed = 20
+
 // An app holds the clients and parsed templates that can be reused between
 // requests.
 type app struct {
@@ -58,7 +78,16 @@ func main() {
 		log.Fatalf("newApp: %v", err)
 	}
 
+	if os.Getenv("PUBSUB_EMULATOR_HOST") != "" {
+		go func() {
+			if err := a.runLocalWorker(context.Background()); err != nil {
+				log.Fatalf("runLocalWorker: %v", err)
+			}
+		}()
+	}
+
 	http.HandleFunc("/", a.index)
+	http.HandleFunc("/jobs", a.listJobs)
 	http.HandleFunc("/request-translation", a.requestTranslation)
 
 	port := os.Getenv("PORT")
@@ -107,9 +136,22 @@ func newApp(projectID, templateDir string) (*app, error) {
 
 // [START getting_started_background_app_list]
 
-// index lists the current translations.
+// indexData is the data rendered by the index template.
+type indexData struct {
+	Jobs         []*background.Job
+	Translations []background.Translation
+}
+
+// index lists the recent jobs and the current translations.
 func (a *app) index(w http.ResponseWriter, r *http.Request) {
-	docs, err := a.firestoreClient.Collection("translations").Documents(r.Context()).GetAll()
+	jobs, err := background.ListJobs(r.Context(), a.firestoreClient, maxJobsListed)
+	if err != nil {
+		log.Printf("ListJobs: %v", err)
+		http.Error(w, fmt.Sprintf("Error getting jobs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	docs, err := a.firestoreClient.Collection(background.TranslationsCollection).Documents(r.Context()).GetAll()
 	if err != nil {
 		log.Printf("GetAll: %v", err)
 		http.Error(w, fmt.Sprintf("Error getting translations: %v", err), http.StatusInternalServerError)
@@ -127,37 +169,64 @@ func (a *app) index(w http.ResponseWriter, r *http.Request) {
 		translations = append(translations, t)
 	}
 
-	if err := a.tmpl.Execute(w, translations); err != nil {
+	data := indexData{Jobs: jobs, Translations: translations}
+	if err := a.tmpl.Execute(w, data); err != nil {
 		log.Printf("tmpl.Execute: %v", err)
 		http.Error(w, "Error writing response", http.StatusInternalServerError)
 		return
 	}
 }
 
+// listJobs writes the recent jobs as JSON. The index page polls it to show
+// live job status.
+func (a *app) listJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := background.ListJobs(r.Context(), a.firestoreClient, maxJobsListed)
+	if err != nil {
+		log.Printf("ListJobs: %v", err)
+		http.Error(w, "Error getting jobs", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(jobs); err != nil {
+		log.Printf("json.Encode: %v", err)
+	}
+}
+
 // [END getting_started_background_app_list]
 
 // [START getting_started_background_app_request]
 
-// requestTranslation parses the request, validates it, and sends it to Pub/Sub.
+// acceptableLanguages are the target languages offered by the form.
+var acceptableLanguages = map[string]bool{
+	"de": true,
+	"en": true,
+	"es": true,
+	"fr": true,
+	"ja": true,
+	"sw": true,
+}
+
+// requestTranslation parses the request, validates it, creates a job, and sends
+// it to Pub/Sub. The "v" form value may contain several texts, one per line,
+// which are translated as a batch. The optional "key" form value is an
+// idempotency key: resubmitting the same key returns the existing job instead
+// of creating a second one, and reusing it for other texts is a conflict.
 func (a *app) requestTranslation(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		log.Printf("ParseForm: %v", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
-	v := r.PostFormValue("v")
-	if v == "" {
+	texts := splitTexts(r.PostFormValue("v"))
+	if len(texts) == 0 {
 		log.Printf("Empty value")
 		http.Error(w, "Empty value", http.StatusBadRequest)
 		return
 	}
-	acceptableLanguages := map[string]bool{
-		"de": true,
-		"en": true,
-		"es": true,
-		"fr": true,
-		"ja": true,
-		"sw": true,
+	if len(texts) > background.MaxBatchSize {
+		log.Printf("Too many texts: %d", len(texts))
+		http.Error(w, fmt.Sprintf("At most %d texts can be translated at once", background.MaxBatchSize), http.StatusBadRequest)
+		return
 	}
 	lang := r.PostFormValue("lang")
 	if !acceptableLanguages[lang] {
@@ -165,14 +234,49 @@ func (a *app) requestTranslation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Unsupported language: %v", lang), http.StatusBadRequest)
 		return
 	}
+	key := r.PostFormValue("key")
+	if key == "" {
+		var err error
+		if key, err = newIdempotencyKey(); err != nil {
+			log.Printf("newIdempotencyKey: %v", err)
+			http.Error(w, "Error requesting translation", http.StatusInternalServerError)
+			return
+		}
+	}
 
-	log.Printf("Translation requested: %q -> %s", v, lang)
+	log.Printf("Translation requested: %q -> %s", texts, lang)
 
-	t := background.Translation{
-		Original: v,
+	req := &background.TranslationRequest{
+		JobID:    background.JobID(key),
+		Texts:    texts,
 		Language: lang,
 	}
-	msg, err := json.Marshal(t)
+	job := background.NewJob(req)
+	if err := background.CreateJob(r.Context(), a.firestoreClient, job); err != nil {
+		if status.Code(err) != codes.AlreadyExists {
+			log.Printf("CreateJob: %v", err)
+			http.Error(w, "Error requesting translation", http.StatusInternalServerError)
+			return
+		}
+		// The key was already submitted. Return the stored job rather than
+		// publishing it again, unless the key was reused for other texts.
+		existing, err := background.GetJob(r.Context(), a.firestoreClient, job.ID)
+		if err != nil {
+			log.Printf("GetJob: %v", err)
+			http.Error(w, "Error requesting translation", http.StatusInternalServerError)
+			return
+		}
+		if !existing.SameRequest(req) {
+			log.Printf("Job %s already exists with different texts", job.ID)
+			http.Error(w, "The idempotency key was already used for a different request", http.StatusConflict)
+			return
+		}
+		log.Printf("Job %s already exists", job.ID)
+		writeJob(w, existing)
+		return
+	}
+
+	msg, err := json.Marshal(req)
 	if err != nil {
 		log.Printf("json.Marshal: %v", err)
 		http.Error(w, "Error requesting translation", http.StatusInternalServerError)
@@ -185,6 +289,96 @@ func (a *app) requestTranslation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Error requesting translation", http.StatusInternalServerError)
 		return
 	}
+
+	writeJob(w, job)
+}
+
+// writeJob writes job as the JSON response.
+func writeJob(w http.ResponseWriter, job *background.Job) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Printf("json.Encode: %v", err)
+	}
+}
+
+// splitTexts splits v into one text per non-empty line.
+func splitTexts(v string) []string {
+	var texts []string
+	for _, line := range strings.Split(v, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			texts = append(texts, line)
+		}
+	}
+	return texts
+}
+
+// newIdempotencyKey returns a random key for requests that don't provide one.
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // [END getting_started_background_app_request]
+
+// runLocalWorker subscribes to the translation topic and processes requests
+// in-process, standing in for the Cloud Function. It is meant to be used with
+// the Pub/Sub emulator.
+func (a *app) runLocalWorker(ctx context.Context) error {
+	var translator background.Translator = background.PseudoTranslator{}
+	if os.Getenv("USE_TRANSLATION_API") == "true" {
+		client, err := translate.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("translate.NewClient: %w", err)
+		}
+		translator = &background.CloudTranslator{Client: client}
+	}
+	worker := &background.Worker{
+		Firestore:  a.firestoreClient,
+		Translator: translator,
+	}
+
+	sub, err := ensureSubscription(ctx, a.pubsubClient, a.pubsubTopic, localSubscriptionName)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Running local worker on subscription %v", sub.ID())
+	return sub.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		if err := worker.Process(ctx, m.Data); err != nil {
+			log.Printf("Process: %v", err)
+			m.Nack()
+			return
+		}
+		m.Ack()
+	})
+}
+
+// ensureSubscription creates topic and a subscription to it named subID if
+// they don't exist yet.
+func ensureSubscription(ctx context.Context, client *pubsub.Client, topic *pubsub.Topic, subID string) (*pubsub.Subscription, error) {
+	ok, err := topic.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("topic.Exists: %w", err)
+	}
+	if !ok {
+		if topic, err = client.CreateTopic(ctx, topic.ID()); err != nil {
+			return nil, fmt.Errorf("CreateTopic: %w", err)
+		}
+	}
+
+	sub := client.Subscription(subID)
+	ok, err = sub.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("sub.Exists: %w", err)
+	}
+	if !ok {
+		sub, err = client.CreateSubscription(ctx, subID, pubsub.SubscriptionConfig{Topic: topic})
+		if err != nil {
+			return nil, fmt.Errorf("CreateSubscription: %w", err)
+		}
+	}
+	return sub, nil
+}
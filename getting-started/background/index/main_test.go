@@ -17,7 +17,9 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -41,3 +43,33 @@ func TestIndex(t *testing.T) {
 		t.Errorf("wrong status code, got %v, want %v", resp.StatusCode, http.StatusOK)
 	}
 }
+
+func TestSplitTexts(t *testing.T) {
+	got := splitTexts("Me\n\n  You \r\nThem\n")
+	want := []string{"Me", "You", "Them"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitTexts got %q, want %q", got, want)
+	}
+	if got := splitTexts(" \n "); len(got) != 0 {
+		t.Errorf("splitTexts of blank lines got %q, want none", got)
+	}
+}
+
+func TestRequestTranslationValidation(t *testing.T) {
+	// Invalid requests are rejected before any client is used.
+	a := &app{}
+	tests := []url.Values{
+		{"v": {""}, "lang": {"fr"}},
+		{"v": {"Me"}, "lang": {"xx"}},
+		{"v": {strings.Repeat("Me\n", 200)}, "lang": {"fr"}},
+	}
+	for _, form := range tests {
+		r := httptest.NewRequest("POST", "/request-translation", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		a.requestTranslation(w, r)
+		if got := w.Result().StatusCode; got != http.StatusBadRequest {
+			t.Errorf("requestTranslation(%v) got status %v, want %v", form, got, http.StatusBadRequest)
+		}
+	}
+}
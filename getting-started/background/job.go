@@ -0,0 +1,350 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package background
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// JobsCollection is the Firestore collection that stores Jobs.
+	JobsCollection = "jobs"
+	// TranslationsCollection is the Firestore collection that stores one
+	// Translation per (original, language) pair.
+	TranslationsCollection = "translations"
+
+	// MaxBatchSize is the maximum number of texts in a single request. It
+	// matches the Translation API limit on text segments per call.
+	MaxBatchSize = 128
+
+	// defaultLease is how long a running job is owned by the worker that
+	// started it. A redelivered message for a job that has been running for
+	// longer than this is assumed to come from a crashed worker, and the job
+	// is restarted.
+	defaultLease = 5 * time.Minute
+)
+
+// A JobState is the processing state of a Job.
+type JobState string
+
+// Job states. A Job starts out queued, becomes running when a worker picks it
+// up, and ends up done or failed. Failed jobs are retried when their message is
+// redelivered.
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+// A Job tracks a batch of texts to translate to a single language.
+type Job struct {
+	// ID is the document name of the job. It is derived from the idempotency
+	// key of the request, so all deliveries of a request map to the same Job.
+	ID           string        `json:"id" firestore:"-"`
+	State        JobState      `json:"state"`
+	Texts        []string      `json:"texts"`
+	Language     string        `json:"language"`
+	Translations []Translation `json:"translations,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Attempts     int           `json:"attempts"`
+	Created      time.Time     `json:"created"`
+	Updated      time.Time     `json:"updated"`
+}
+
+// A TranslationRequest is the Pub/Sub message payload that asks for a batch of
+// texts to be translated.
+type TranslationRequest struct {
+	// JobID identifies the Job tracking this request. See JobID.
+	JobID    string   `json:"job_id,omitempty"`
+	Texts    []string `json:"texts,omitempty"`
+	Language string   `json:"language"`
+
+	// Original is the single text to translate in messages that predate
+	// batching. It is only read if Texts is empty.
+	Original string `json:"original,omitempty"`
+}
+
+// JobID returns the Job ID for the given idempotency key. Publishers should
+// use a fresh key for each user request and reuse it for retries.
+func JobID(idempotencyKey string) string {
+	return hashDocName("job/" + idempotencyKey)
+}
+
+// ParseRequest decodes a TranslationRequest from a Pub/Sub message payload.
+// Single-text payloads in the Translation format are accepted too. Requests
+// without a JobID get one derived from their content, so duplicate deliveries
+// still collapse into a single Job.
+func ParseRequest(data []byte) (*TranslationRequest, error) {
+	req := &TranslationRequest{}
+	if err := json.Unmarshal(data, req); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if len(req.Texts) == 0 && req.Original != "" {
+		req.Texts = []string{req.Original}
+	}
+	req.Original = ""
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if req.JobID == "" {
+		req.JobID = JobID(req.Language + "/" + strings.Join(req.Texts, "\x00"))
+	}
+	return req, nil
+}
+
+func (req *TranslationRequest) validate() error {
+	if req.Language == "" {
+		return errors.New("missing language")
+	}
+	if len(req.Texts) == 0 {
+		return errors.New("no texts to translate")
+	}
+	if len(req.Texts) > MaxBatchSize {
+		return fmt.Errorf("got %d texts, the maximum is %d", len(req.Texts), MaxBatchSize)
+	}
+	if strings.Contains(req.JobID, "/") {
+		return fmt.Errorf("invalid job ID %q", req.JobID)
+	}
+	return nil
+}
+
+// NewJob returns a queued Job for req.
+func NewJob(req *TranslationRequest) *Job {
+	now := time.Now().UTC()
+	return &Job{
+		ID:       req.JobID,
+		State:    JobQueued,
+		Texts:    req.Texts,
+		Language: req.Language,
+		Created:  now,
+		Updated:  now,
+	}
+}
+
+// CreateJob stores job as a new document. It returns an error with code
+// AlreadyExists if a job with the same ID was already created.
+func CreateJob(ctx context.Context, client *firestore.Client, job *Job) error {
+	_, err := client.Collection(JobsCollection).Doc(job.ID).Create(ctx, job)
+	return err
+}
+
+// GetJob returns the job with the given ID.
+func GetJob(ctx context.Context, client *firestore.Client, id string) (*Job, error) {
+	doc, err := client.Collection(JobsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w", err)
+	}
+	job := &Job{}
+	if err := doc.DataTo(job); err != nil {
+		return nil, fmt.Errorf("DataTo: %w", err)
+	}
+	job.ID = doc.Ref.ID
+	return job, nil
+}
+
+// SameRequest reports whether job was created for the same texts and
+// language as req.
+func (job *Job) SameRequest(req *TranslationRequest) bool {
+	return job.Language == req.Language && slices.Equal(job.Texts, req.Texts)
+}
+
+// ListJobs returns the most recently created jobs, newest first.
+func ListJobs(ctx context.Context, client *firestore.Client, limit int) ([]*Job, error) {
+	docs, err := client.Collection(JobsCollection).
+		OrderBy("Created", firestore.Desc).
+		Limit(limit).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("GetAll: %w", err)
+	}
+	jobs := make([]*Job, 0, len(docs))
+	for _, d := range docs {
+		j := &Job{}
+		if err := d.DataTo(j); err != nil {
+			return nil, fmt.Errorf("DataTo: %w", err)
+		}
+		j.ID = d.Ref.ID
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// A Worker processes translation requests.
+type Worker struct {
+	Firestore  *firestore.Client
+	Translator Translator
+
+	// Lease is how long a running job is owned by the worker processing it.
+	// Zero means 5 minutes.
+	Lease time.Duration
+}
+
+// Process handles a single delivery of a TranslationRequest message.
+//
+// The Job is moved to running in a transaction, so concurrent and duplicate
+// deliveries don't translate the same batch twice. Once the texts are
+// translated, the results and the done state are written in a second
+// transaction, but only if this delivery still owns the job. If translation
+// fails, the job is marked failed and the error is returned so the message is
+// retried.
+func (w *Worker) Process(ctx context.Context, data []byte) error {
+	req, err := ParseRequest(data)
+	if err != nil {
+		// Retrying a malformed message won't help, so don't return an error.
+		log.Printf("Dropping invalid message: %v", err)
+		return nil
+	}
+
+	job, err := w.start(ctx, req)
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	if job == nil {
+		// Done, or being processed by another delivery.
+		return nil
+	}
+
+	// Translate the texts stored in the job, not the ones in the message:
+	// the job is the record of what was requested for its ID.
+	translations, err := w.Translator.TranslateStrings(ctx, job.Texts, job.Language)
+	if err == nil && len(translations) != len(job.Texts) {
+		err = fmt.Errorf("got %d translations, want %d", len(translations), len(job.Texts))
+	}
+	if err != nil {
+		if ferr := w.fail(ctx, job.ID, job.Attempts, err); ferr != nil {
+			return fmt.Errorf("fail: %v (translate: %w)", ferr, err)
+		}
+		return fmt.Errorf("TranslateStrings: %w", err)
+	}
+
+	if err := w.finish(ctx, job.ID, job.Attempts, translations); err != nil {
+		return fmt.Errorf("finish: %w", err)
+	}
+	return nil
+}
+
+// start claims the job for req and returns it, with Attempts set to the
+// attempt this delivery owns, or nil if there is nothing to do. A job that
+// doesn't exist yet is created from req.
+func (w *Worker) start(ctx context.Context, req *TranslationRequest) (*Job, error) {
+	ref := w.Firestore.Collection(JobsCollection).Doc(req.JobID)
+	lease := w.Lease
+	if lease == 0 {
+		lease = defaultLease
+	}
+
+	var started *Job
+	err := w.Firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		started = nil
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("Get: %w", err)
+		}
+		job := NewJob(req)
+		if doc.Exists() {
+			if err := doc.DataTo(job); err != nil {
+				return fmt.Errorf("DataTo: %w", err)
+			}
+		}
+
+		now := time.Now().UTC()
+		switch job.State {
+		case JobDone:
+			return nil
+		case JobRunning:
+			if now.Sub(job.Updated) < lease {
+				return nil
+			}
+		}
+
+		job.State = JobRunning
+		job.Error = ""
+		job.Attempts++
+		job.Updated = now
+		started = job
+		return tx.Set(ref, job)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RunTransaction: %w", err)
+	}
+	return started, nil
+}
+
+// finish stores translations and marks the job done if attempt still owns it.
+func (w *Worker) finish(ctx context.Context, jobID string, attempt int, translations []Translation) error {
+	ref := w.Firestore.Collection(JobsCollection).Doc(jobID)
+	return w.Firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		job, ok, err := getOwnedJob(tx, ref, attempt)
+		if err != nil || !ok {
+			return err
+		}
+		for _, t := range translations {
+			tref := w.Firestore.Collection(TranslationsCollection).Doc(translationDocName(t.Original, t.Language))
+			if err := tx.Set(tref, t); err != nil {
+				return fmt.Errorf("Set: %w", err)
+			}
+		}
+		job.State = JobDone
+		job.Translations = translations
+		job.Updated = time.Now().UTC()
+		return tx.Set(ref, job)
+	})
+}
+
+// fail marks the job failed if attempt still owns it.
+func (w *Worker) fail(ctx context.Context, jobID string, attempt int, cause error) error {
+	ref := w.Firestore.Collection(JobsCollection).Doc(jobID)
+	return w.Firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		job, ok, err := getOwnedJob(tx, ref, attempt)
+		if err != nil || !ok {
+			return err
+		}
+		job.State = JobFailed
+		job.Error = cause.Error()
+		job.Updated = time.Now().UTC()
+		return tx.Set(ref, job)
+	})
+}
+
+// getOwnedJob reads the job at ref and reports whether it is still running
+// the given attempt. A later delivery may have taken over after the lease
+// expired, in which case this one must not write its results.
+func getOwnedJob(tx *firestore.Transaction, ref *firestore.DocumentRef, attempt int) (*Job, bool, error) {
+	doc, err := tx.Get(ref)
+	if err != nil {
+		return nil, false, fmt.Errorf("Get: %w", err)
+	}
+	job := &Job{}
+	if err := doc.DataTo(job); err != nil {
+		return nil, false, fmt.Errorf("DataTo: %w", err)
+	}
+	if job.State != JobRunning || job.Attempts != attempt {
+		return nil, false, nil
+	}
+	return job, true, nil
+}
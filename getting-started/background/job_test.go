@@ -0,0 +1,264 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package background
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+)
+
+func TestParseRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantTexts []string
+		wantErr   bool
+	}{
+		{
+			name:      "batch",
+			data:      `{"job_id":"abc","texts":["Me","You"],"language":"fr"}`,
+			wantTexts: []string{"Me", "You"},
+		},
+		{
+			name:      "legacy single text",
+			data:      `{"original":"Me","language":"fr"}`,
+			wantTexts: []string{"Me"},
+		},
+		{
+			name:    "no language",
+			data:    `{"texts":["Me"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "no texts",
+			data:    `{"language":"fr"}`,
+			wantErr: true,
+		},
+		{
+			name:    "bad job ID",
+			data:    `{"job_id":"a/b","texts":["Me"],"language":"fr"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			data:    `{`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := ParseRequest([]byte(tc.data))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseRequest(%s) got nil error, want error", tc.data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRequest(%s): %v", tc.data, err)
+			}
+			if strings.Join(req.Texts, "|") != strings.Join(tc.wantTexts, "|") {
+				t.Errorf("ParseRequest(%s) got texts %q, want %q", tc.data, req.Texts, tc.wantTexts)
+			}
+			if req.JobID == "" {
+				t.Errorf("ParseRequest(%s) got empty JobID", tc.data)
+			}
+		})
+	}
+}
+
+func TestParseRequestDefaultJobID(t *testing.T) {
+	data := []byte(`{"original":"Me","language":"fr"}`)
+	a, err := ParseRequest(data)
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	b, err := ParseRequest(data)
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	if a.JobID != b.JobID {
+		t.Errorf("duplicate messages got job IDs %q and %q, want equal", a.JobID, b.JobID)
+	}
+	if strings.Contains(a.JobID, "/") {
+		t.Errorf("JobID %q contains '/'", a.JobID)
+	}
+
+	c, err := ParseRequest([]byte(`{"original":"Me","language":"de"}`))
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	if a.JobID == c.JobID {
+		t.Errorf("different messages got the same job ID %q", a.JobID)
+	}
+}
+
+func TestSameRequest(t *testing.T) {
+	req := &TranslationRequest{Texts: []string{"Me", "You"}, Language: "fr"}
+	job := NewJob(req)
+	tests := []struct {
+		req  *TranslationRequest
+		want bool
+	}{
+		{req, true},
+		{&TranslationRequest{Texts: []string{"Me", "You"}, Language: "de"}, false},
+		{&TranslationRequest{Texts: []string{"You", "Me"}, Language: "fr"}, false},
+		{&TranslationRequest{Texts: []string{"Me"}, Language: "fr"}, false},
+	}
+	for _, tc := range tests {
+		if got := job.SameRequest(tc.req); got != tc.want {
+			t.Errorf("SameRequest(%q, %s) = %v, want %v", tc.req.Texts, tc.req.Language, got, tc.want)
+		}
+	}
+}
+
+func TestPseudoTranslator(t *testing.T) {
+	got, err := PseudoTranslator{SourceLanguage: "en"}.TranslateStrings(context.Background(), []string{"Me", "You"}, "fr")
+	if err != nil {
+		t.Fatalf("TranslateStrings: %v", err)
+	}
+	want := []Translation{
+		{Original: "Me", Translated: "[fr] Me", OriginalLanguage: "en", Language: "fr"},
+		{Original: "You", Translated: "[fr] You", OriginalLanguage: "en", Language: "fr"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("TranslateStrings got %d translations, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TranslateStrings[%d] got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := (PseudoTranslator{}).TranslateStrings(context.Background(), []string{"Me"}, "not a language!"); err == nil {
+		t.Errorf("TranslateStrings with invalid language got nil error, want error")
+	}
+}
+
+// countingTranslator counts calls and optionally fails.
+type countingTranslator struct {
+	calls int32
+	err   error
+}
+
+func (c *countingTranslator) TranslateStrings(ctx context.Context, texts []string, lang string) ([]Translation, error) {
+	atomic.AddInt32(&c.calls, 1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return PseudoTranslator{}.TranslateStrings(ctx, texts, lang)
+}
+
+// TestWorkerEmulator runs Worker.Process against the Firestore emulator.
+func TestWorkerEmulator(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("Skipping emulator test. Set FIRESTORE_EMULATOR_HOST.")
+	}
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "background-test")
+	if err != nil {
+		t.Fatalf("firestore.NewClient: %v", err)
+	}
+	defer client.Close()
+	if err := deleteAll(ctx, client, ""); err != nil {
+		t.Fatalf("deleteAll: %v", err)
+	}
+
+	getJob := func(id string) *Job {
+		t.Helper()
+		doc, err := client.Collection(JobsCollection).Doc(id).Get(ctx)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", id, err)
+		}
+		j := &Job{}
+		if err := doc.DataTo(j); err != nil {
+			t.Fatalf("DataTo: %v", err)
+		}
+		return j
+	}
+
+	// A failing translation marks the job failed and returns an error.
+	req := &TranslationRequest{JobID: JobID("emulator-test"), Texts: []string{"Me", "You"}, Language: "fr"}
+	if err := CreateJob(ctx, client, NewJob(req)); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	msg, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	failing := &countingTranslator{err: errors.New("quota exceeded")}
+	w := &Worker{Firestore: client, Translator: failing}
+	if err := w.Process(ctx, msg); err == nil {
+		t.Errorf("Process with failing translator got nil error, want error")
+	}
+	if j := getJob(req.JobID); j.State != JobFailed || j.Error == "" {
+		t.Errorf("after failure got state %q and error %q, want %q and an error", j.State, j.Error, JobFailed)
+	}
+
+	// Redelivery retries the failed job.
+	ok := &countingTranslator{}
+	w.Translator = ok
+	if err := w.Process(ctx, msg); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	j := getJob(req.JobID)
+	if j.State != JobDone || j.Attempts != 2 || len(j.Translations) != 2 {
+		t.Errorf("after retry got state %q, %d attempts, %d translations, want %q, 2, 2", j.State, j.Attempts, len(j.Translations), JobDone)
+	}
+
+	// Duplicate deliveries of a done job do nothing.
+	if err := w.Process(ctx, msg); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got := atomic.LoadInt32(&ok.calls); got != 1 {
+		t.Errorf("duplicate delivery called the translator %d times in total, want 1", got)
+	}
+
+	// A message with other texts for an existing job translates the texts
+	// stored in the job.
+	stored := &TranslationRequest{JobID: JobID("emulator-test-stored"), Texts: []string{"Stored"}, Language: "fr"}
+	if err := CreateJob(ctx, client, NewJob(stored)); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	msg, err = json.Marshal(&TranslationRequest{JobID: stored.JobID, Texts: []string{"Other"}, Language: "de"})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if err := w.Process(ctx, msg); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	j = getJob(stored.JobID)
+	if len(j.Translations) != 1 || j.Translations[0].Original != "Stored" || j.Translations[0].Language != "fr" {
+		t.Errorf("got translations %+v, want one of %q to fr", j.Translations, "Stored")
+	}
+	if !j.SameRequest(stored) {
+		t.Errorf("job texts changed to %q/%s, want %q/%s", j.Texts, j.Language, stored.Texts, stored.Language)
+	}
+
+	translations, err := getAll(ctx, client, "")
+	if err != nil {
+		t.Fatalf("getAll: %v", err)
+	}
+	if len(translations) != 3 {
+		t.Errorf("got %d translations, want 3", len(translations))
+	}
+}
@@ -23,7 +23,6 @@ import (
 	"context"
 	"crypto/sha512"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -31,8 +30,6 @@ import (
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/translate"
 	"golang.org/x/text/language"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // A Translation contains the original and translated text.
@@ -47,6 +44,7 @@ type Translation struct {
 var (
 	translateClient *translate.Client
 	firestoreClient *firestore.Client
+	worker          *Worker
 )
 
 // PubSubMessage is the payload of a Pub/Sub event.
@@ -59,8 +57,8 @@ type PubSubMessage struct {
 
 // [START getting_started_background_translate_init]
 
-// initializeClients creates translateClient and firestoreClient if they haven't
-// been created yet.
+// initializeClients creates translateClient, firestoreClient, and worker if
+// they haven't been created yet.
 func initializeClients() error {
 	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
 	if projectID == "" {
@@ -85,86 +83,121 @@ func initializeClients() error {
 			return fmt.Errorf("firestore.NewClient: %w", err)
 		}
 	}
+	if worker == nil {
+		worker = &Worker{
+			Firestore:  firestoreClient,
+			Translator: &CloudTranslator{Client: translateClient},
+		}
+	}
 	return nil
 }
 
 // [END getting_started_background_translate_init]
 
+// A Translator translates a batch of strings to a single target language.
+// The Cloud Function uses the Translation API. PseudoTranslator can be used
+// instead to run the whole flow locally against the emulators.
+type Translator interface {
+	// TranslateStrings translates every text to lang. The result has one
+	// Translation per text, in the same order.
+	TranslateStrings(ctx context.Context, texts []string, lang string) ([]Translation, error)
+}
+
 // [START getting_started_background_translate_string]
 
-// translateString translates text to lang, returning:
-// * the translated text,
-// * the automatically detected source language, and
-// * an error.
-func translateString(ctx context.Context, text string, lang string) (translated string, originalLang string, err error) {
+// CloudTranslator is a Translator backed by the Cloud Translation API.
+type CloudTranslator struct {
+	Client *translate.Client
+}
+
+// TranslateStrings translates texts to lang in a single API call. The source
+// language of each text is detected automatically.
+func (c *CloudTranslator) TranslateStrings(ctx context.Context, texts []string, lang string) ([]Translation, error) {
 	l, err := language.Parse(lang)
 	if err != nil {
-		return "", "", fmt.Errorf("language.Parse: %w", err)
+		return nil, fmt.Errorf("language.Parse: %w", err)
 	}
 
-	outs, err := translateClient.Translate(ctx, []string{text}, l, nil)
+	outs, err := c.Client.Translate(ctx, texts, l, nil)
 	if err != nil {
-		return "", "", fmt.Errorf("Translate: %w", err)
+		return nil, fmt.Errorf("Translate: %w", err)
 	}
 
-	if len(outs) < 1 {
-		return "", "", fmt.Errorf("Translate got %d translations, need at least 1", len(outs))
+	if len(outs) != len(texts) {
+		return nil, fmt.Errorf("Translate got %d translations, want %d", len(outs), len(texts))
 	}
 
-	return outs[0].Text, outs[0].Source.String(), nil
+	translations := make([]Translation, len(texts))
+	for i, out := range outs {
+		translations[i] = Translation{
+			Original:         texts[i],
+			Translated:       out.Text,
+			OriginalLanguage: out.Source.String(),
+			Language:         lang,
+		}
+	}
+	return translations, nil
 }
 
 // [END getting_started_background_translate_string]
 
+// PseudoTranslator is a Translator for local development and tests. It
+// doesn't call any API: every text is "translated" by prefixing it with the
+// target language, e.g. "[fr] Me".
+type PseudoTranslator struct {
+	// SourceLanguage is reported as the detected language. It defaults to
+	// "und" (undetermined).
+	SourceLanguage string
+}
+
+// TranslateStrings returns pseudo-translations of texts.
+func (p PseudoTranslator) TranslateStrings(ctx context.Context, texts []string, lang string) ([]Translation, error) {
+	if _, err := language.Parse(lang); err != nil {
+		return nil, fmt.Errorf("language.Parse: %w", err)
+	}
+	src := p.SourceLanguage
+	if src == "" {
+		src = language.Und.String()
+	}
+	translations := make([]Translation, len(texts))
+	for i, text := range texts {
+		translations[i] = Translation{
+			Original:         text,
+			Translated:       fmt.Sprintf("[%s] %s", lang, text),
+			OriginalLanguage: src,
+			Language:         lang,
+		}
+	}
+	return translations, nil
+}
+
 // [START getting_started_background_translate]
 
-// Translate translates the given message and stores the result in Firestore.
+// Translate translates the texts in the given message and stores the result
+// in Firestore. Duplicate deliveries of the same message are only processed
+// once. See Worker.Process.
 func Translate(ctx context.Context, m PubSubMessage) error {
-	initializeClients()
-
-	t := Translation{}
-	if err := json.Unmarshal(m.Data, &t); err != nil {
-		return fmt.Errorf("json.Unmarshal: %w", err)
+	if err := initializeClients(); err != nil {
+		return fmt.Errorf("initializeClients: %w", err)
 	}
+	return worker.Process(ctx, m.Data)
+}
+
+// translationDocName returns the name of the document that stores the
+// translation of original to lang. Using a deterministic name prevents
+// duplicate translations.
+func translationDocName(original, lang string) string {
+	return hashDocName(fmt.Sprintf("%s/%s", lang, original))
+}
 
-	// Use a unique document name to prevent duplicate translations.
-	key := fmt.Sprintf("%s/%s", t.Language, t.Original)
+// hashDocName returns a Firestore-safe document name derived from key.
+func hashDocName(key string) string {
 	sum := sha512.Sum512([]byte(key))
 	// Base64 encode the sum to make a nice string. The [:] converts the byte
 	// array to a byte slice.
 	docName := base64.StdEncoding.EncodeToString(sum[:])
 	// The document name cannot contain "/".
-	docName = strings.Replace(docName, "/", "-", -1)
-	ref := firestoreClient.Collection("translations").Doc(docName)
-
-	// Run in a transation to prevent concurrent duplicate translations.
-	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
-		doc, err := tx.Get(ref)
-		if err != nil && status.Code(err) != codes.NotFound {
-			return fmt.Errorf("Get: %w", err)
-		}
-		// Do nothing if the document already exists.
-		if doc.Exists() {
-			return nil
-		}
-
-		translated, originalLang, err := translateString(ctx, t.Original, t.Language)
-		if err != nil {
-			return fmt.Errorf("translateString: %w", err)
-		}
-		t.Translated = translated
-		t.OriginalLanguage = originalLang
-
-		if err := tx.Set(ref, t); err != nil {
-			return fmt.Errorf("Set: %w", err)
-		}
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("RunTransaction: %w", err)
-	}
-	return nil
+	return strings.Replace(docName, "/", "-", -1)
 }
 
 // [END getting_started_background_translate]
@@ -115,14 +115,16 @@ func TestTranslate(t *testing.T) {
 }
 
 func deleteAll(ctx context.Context, client *firestore.Client, projectID string) error {
-	docs, err := client.Collection("translations").Documents(ctx).GetAll()
-	if err != nil {
-		return err
-	}
-	for _, doc := range docs {
-		if _, err := doc.Ref.Delete(ctx); err != nil {
+	for _, c := range []string{TranslationsCollection, JobsCollection} {
+		docs, err := client.Collection(c).Documents(ctx).GetAll()
+		if err != nil {
 			return err
 		}
+		for _, doc := range docs {
+			if _, err := doc.Ref.Delete(ctx); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
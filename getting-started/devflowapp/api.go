@@ -0,0 +1,179 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// JSON API for conversations and messages. Like the HTML handlers, the API
+// trusts the user named in the request; a real app would authenticate it.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/golang-samples/getting-started/devflowapp/services"
+)
+
+// Registers the JSON API handlers on mux
+func registerAPIHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/conversations", handleAPIListConversations)
+	mux.HandleFunc("GET /api/conversations/{friend}", handleAPIGetConversation)
+	mux.HandleFunc("POST /api/conversations/{friend}/read", handleAPIMarkRead)
+	mux.HandleFunc("POST /api/messages", handleAPISendMessage)
+	mux.HandleFunc("DELETE /api/messages/{id}", handleAPIDeleteMessage)
+}
+
+// Body of a POST /api/messages request
+type sendMessageRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+// Writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writeJSON, Error: %v\n", err)
+	}
+}
+
+// Writes an error as a JSON response with the given status code
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// Gets and validates the "user" query parameter
+func apiUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user := r.URL.Query().Get("user")
+	if err := services.ValidateUser(user); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return "", false
+	}
+	return user, true
+}
+
+// Lists the conversations of the user
+func handleAPIListConversations(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiUser(w, r)
+	if !ok {
+		return
+	}
+	conversations, err := services.GetMessageService().ListConversations(user)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, conversations)
+}
+
+// Gets a page of the conversation between the user and a friend. The page
+// is selected with the optional "limit" and "before" query parameters.
+func handleAPIGetConversation(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiUser(w, r)
+	if !ok {
+		return
+	}
+	page := services.Page{}
+	for name, dst := range map[string]*int{
+		"limit":  &page.Limit,
+		"before": &page.Before,
+	} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest,
+				errors.New("Invalid value for '"+name+"'"))
+			return
+		}
+		*dst = n
+	}
+	messagePage, err := services.GetMessageService().GetConversation(user,
+		r.PathValue("friend"), page)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, messagePage)
+}
+
+// Marks the messages a friend sent to the user as read
+func handleAPIMarkRead(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiUser(w, r)
+	if !ok {
+		return
+	}
+	count, err := services.GetMessageService().MarkRead(user,
+		r.PathValue("friend"))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"marked": count})
+}
+
+// Sends a message. The text is stored as is, without FormatMessage.
+func handleAPISendMessage(w http.ResponseWriter, r *http.Request) {
+	req := sendMessageRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, errors.New("Invalid JSON body"))
+		return
+	}
+	for _, user := range []string{req.From, req.To} {
+		if err := services.ValidateUser(user); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if req.Text == "" {
+		writeJSONError(w, http.StatusBadRequest,
+			errors.New("Message text must not be empty"))
+		return
+	}
+	err := services.GetMessageService().SendMessage(req.From, req.To, req.Text)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"status": "sent"})
+}
+
+// Deletes a message sent or received by the user
+func handleAPIDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errors.New("Invalid message id"))
+		return
+	}
+	err = services.GetMessageService().DeleteMessage(user, id)
+	if errors.Is(err, services.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
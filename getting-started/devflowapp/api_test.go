@@ -0,0 +1,116 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Unit tests for the JSON API, using the mock MessageService
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/golang-samples/getting-started/devflowapp/services"
+)
+
+// Sends a request to the API and decodes the JSON response into v, if not nil
+func doAPI(t *testing.T, method, target, body string, v interface{}) int {
+	t.Helper()
+	os.Setenv("MESSAGE_SERVICE", "mock")
+	mux := http.NewServeMux()
+	registerAPIHandlers(mux)
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	resp := w.Result()
+	if v != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: Error decoding response: %v\n", method, target, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPIConversationFlow(t *testing.T) {
+	for i := 1; i <= 3; i++ {
+		body := fmt.Sprintf(`{"from":"APIAnn","to":"APIBob","text":"hi %d"}`, i)
+		if code := doAPI(t, "POST", "/api/messages", body, nil); code != http.StatusCreated {
+			t.Fatalf("TestAPIConversationFlow: Expected: %d, got %d\n",
+				http.StatusCreated, code)
+		}
+	}
+
+	var conversations []services.Conversation
+	doAPI(t, "GET", "/api/conversations?user=APIBob", "", &conversations)
+	if len(conversations) != 1 || conversations[0].Friend != "APIAnn" ||
+		conversations[0].Unread != 3 {
+		t.Errorf("TestAPIConversationFlow: Expected 1 conversation with 3 "+
+			"unread, got %+v\n", conversations)
+	}
+
+	var page services.MessagePage
+	doAPI(t, "GET", "/api/conversations/APIAnn?user=APIBob&limit=2", "", &page)
+	if len(page.Messages) != 2 || page.Messages[0].Text != "hi 3" ||
+		page.NextBefore == 0 {
+		t.Fatalf("TestAPIConversationFlow: Unexpected first page %+v\n", page)
+	}
+	target := fmt.Sprintf("/api/conversations/APIAnn?user=APIBob&limit=2&before=%d",
+		page.NextBefore)
+	page = services.MessagePage{}
+	doAPI(t, "GET", target, "", &page)
+	if len(page.Messages) != 1 || page.Messages[0].Text != "hi 1" ||
+		page.NextBefore != 0 {
+		t.Errorf("TestAPIConversationFlow: Unexpected last page %+v\n", page)
+	}
+
+	var marked map[string]int
+	doAPI(t, "POST", "/api/conversations/APIAnn/read?user=APIBob", "", &marked)
+	if marked["marked"] != 3 {
+		t.Errorf("TestAPIConversationFlow: Expected 3 marked, got %v\n", marked)
+	}
+
+	id := page.Messages[0].Id
+	target = fmt.Sprintf("/api/messages/%d?user=APIStranger", id)
+	if code := doAPI(t, "DELETE", target, "", nil); code != http.StatusNotFound {
+		t.Errorf("TestAPIConversationFlow: Expected: %d, got %d\n",
+			http.StatusNotFound, code)
+	}
+	target = fmt.Sprintf("/api/messages/%d?user=APIAnn", id)
+	if code := doAPI(t, "DELETE", target, "", nil); code != http.StatusNoContent {
+		t.Errorf("TestAPIConversationFlow: Expected: %d, got %d\n",
+			http.StatusNoContent, code)
+	}
+}
+
+func TestAPIBadRequests(t *testing.T) {
+	tests := []struct{ method, target, body string }{
+		{"GET", "/api/conversations", ""},
+		{"GET", "/api/conversations/APIAnn?user=APIBob&limit=x", ""},
+		{"POST", "/api/messages", "not json"},
+		{"POST", "/api/messages", `{"from":"APIAnn","to":"","text":"hi"}`},
+		{"POST", "/api/messages", `{"from":"APIAnn","to":"APIBob"}`},
+		{"DELETE", "/api/messages/x?user=APIAnn", ""},
+	}
+	for _, test := range tests {
+		code := doAPI(t, test.method, test.target, test.body, nil)
+		if code != http.StatusBadRequest {
+			t.Errorf("TestAPIBadRequests: %s %s: Expected: %d, got %d\n",
+				test.method, test.target, http.StatusBadRequest, code)
+		}
+	}
+}
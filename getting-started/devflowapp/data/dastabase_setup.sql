@@ -1,6 +1,6 @@
 CREATE DATABASE messagesdb;
 CREATE USER proxyuser IDENTIFIED BY '***';
-GRANT INSERT, SELECT, UPDATE ON messagesdb.* to 'proxyuser'@'%';
+GRANT INSERT, SELECT, UPDATE, DELETE, CREATE, ALTER, INDEX ON messagesdb.* to 'proxyuser'@'%';
 
 USE messagesdb;
 
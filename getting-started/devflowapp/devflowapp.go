@@ -43,7 +43,13 @@ func handleCheckMessages(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintf(w, "<p>%v</p>", err)
 			return
 		}
-		fmt.Fprintf(w, "<p>You have %d message(s)</p>", len(messages))
+		unread, err := services.CheckUnread(messageService, user)
+		if err != nil {
+			fmt.Fprintf(w, "<p>%v</p>", err)
+			return
+		}
+		fmt.Fprintf(w, "<p>You have %d message(s), %d unread</p>",
+			len(messages), unread)
 	}
 }
 
@@ -55,7 +61,9 @@ func handleDefault(w http.ResponseWriter, r *http.Request) {
 		"<ol>"+
 		"<li><a href=\"%s\">Send a message</a></li>"+
 		"<li><a href=\"%s\">Get messages</a></li>"+
-		"</ol>", pathSend, pathCheck)
+		"</ol>"+
+		"<p>A JSON API is also available under <code>/api/</code>.</p>",
+		pathSend, pathCheck)
 }
 
 // Handle a HTTP request to send a message to a user. The identify of the
@@ -97,6 +105,7 @@ func main() {
 	http.HandleFunc("/", handleDefault)
 	http.HandleFunc("/messages", handleCheckMessages)
 	http.HandleFunc("/send", handleSend)
+	registerAPIHandlers(http.DefaultServeMux)
 
 	port := os.Getenv("PORT")
 	if port == "" {
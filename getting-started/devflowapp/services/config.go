@@ -22,7 +22,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/sqlutil"
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -30,19 +32,34 @@ var messageService MessageService
 
 func getDBConnection() (db *sql.DB, err error) {
 	conStr, ok := os.LookupEnv("MYSQL_CONNECTION")
-	if ok {
-		return sql.Open("mysql", conStr)
-	} else {
+	if !ok {
 		dbUser, ok := os.LookupEnv("DB_USER")
-		if ok {
-			dbPass, _ := os.LookupEnv("DB_PASSWORD")
-			conStr = fmt.Sprintf("%s:%s@tcp(localhost:3306)/messagesdb", dbUser,
-				dbPass)
-			return sql.Open("mysql", conStr)
-		} else {
+		if !ok {
 			return db, errors.New("No database connection information provided")
 		}
+		dbPass, _ := os.LookupEnv("DB_PASSWORD")
+		conStr = fmt.Sprintf("%s:%s@tcp(localhost:3306)/messagesdb", dbUser,
+			dbPass)
 	}
+	// parseTime is needed to scan DATETIME columns into time.Time.
+	if !strings.Contains(conStr, "parseTime=") {
+		if strings.Contains(conStr, "?") {
+			conStr += "&parseTime=true"
+		} else {
+			conStr += "?parseTime=true"
+		}
+	}
+	db, err = sql.Open("mysql", conStr)
+	if err != nil {
+		return nil, err
+	}
+	// Use the same pool configuration as the Cloud SQL samples.
+	poolConfig, err := sqlutil.PoolConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.Apply(db)
+	return db, nil
 }
 
 // Gets an the MessageService if already instantiated, or creates a new one
@@ -64,5 +81,8 @@ func newMessageService() MessageService {
 	if err != nil {
 		log.Fatal("service.NewMessageService: error, ", err)
 	}
+	if err := migrateDB(dbConn); err != nil {
+		log.Fatal("service.NewMessageService: migration error, ", err)
+	}
 	return SQLMessagingService{dbConn}
 }
@@ -0,0 +1,66 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Versioned schema migrations for the messages database
+
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/sqlutil"
+)
+
+// The schema of the messages database. Migrations are applied at startup by
+// migrateDB. Never edit a migration that has been released: add a new one.
+var migrations = []sqlutil.Migration{
+	{
+		Version:     1,
+		Description: "create messages",
+		// IF NOT EXISTS keeps databases created with data/dastabase_setup.sql
+		// before migrations were introduced working.
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS messages (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				user_from VARCHAR(50) NOT NULL,
+				user_to VARCHAR(50) NOT NULL,
+				text TEXT
+			)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "add read state, timestamps and conversation index",
+		Statements: []string{
+			`ALTER TABLE messages
+				ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				ADD COLUMN read_at DATETIME NULL`,
+			`CREATE INDEX messages_to_from ON messages (user_to, user_from, id)`,
+			`CREATE INDEX messages_from_to ON messages (user_from, user_to, id)`,
+		},
+	},
+}
+
+// Applies any pending migrations to the messages database. The lock keeps
+// replicas starting together from applying a migration twice.
+func migrateDB(db *sql.DB) error {
+	m := sqlutil.Migrator{Lock: sqlutil.MySQLLock("messagesdb_migrations")}
+	applied, err := m.Migrate(context.Background(), db, migrations)
+	for _, version := range applied {
+		log.Printf("migrateDB, applied migration %d\n", version)
+	}
+	return err
+}
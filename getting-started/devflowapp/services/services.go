@@ -21,9 +21,22 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 )
 
-// Interface for sending messages
+// ErrNotFound is returned when a message doesn't exist or isn't visible to the
+// requesting user.
+var ErrNotFound = errors.New("Message not found")
+
+// DefaultPageSize is the number of messages returned when a Page doesn't set a
+// Limit.
+const DefaultPageSize = 20
+
+// MaxPageSize is the largest Page Limit accepted.
+const MaxPageSize = 100
+
+// Interface for sending and reading messages
 type MessageService interface {
 
 	// Gets the messages that have been sent to a user
@@ -31,38 +44,122 @@ type MessageService interface {
 
 	// Send a message to a user
 	SendMessage(userFrom, userTo, formattedMessage string) error
+
+	// Lists the conversations of a user, most recently active first
+	ListConversations(user string) ([]Conversation, error)
+
+	// Gets a page of the messages exchanged between user and friend, newest
+	// first
+	GetConversation(user, friend string, page Page) (MessagePage, error)
+
+	// Marks all messages sent from friend to user as read, returning the
+	// number of messages that changed
+	MarkRead(user, friend string) (int, error)
+
+	// Counts the unread messages sent to a user
+	CountUnread(user string) (int, error)
+
+	// Deletes a message sent or received by user
+	DeleteMessage(user string, id int) error
 }
 
 // Encapsulates a message from a User to her or his Friend with message Text
 type Message struct {
-	User, Friend, Text string
-	Id                 int
+	User   string    `json:"from"`
+	Friend string    `json:"to"`
+	Text   string    `json:"text"`
+	Id     int       `json:"id"`
+	Read   bool      `json:"read"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// A Conversation summarizes the messages exchanged between a user and a
+// friend
+type Conversation struct {
+	Friend      string  `json:"friend"`
+	LastMessage Message `json:"last_message"`
+	Unread      int     `json:"unread"`
+}
+
+// A Page selects messages with Id lower than Before (all messages if Before
+// is 0), at most Limit of them
+type Page struct {
+	Limit  int
+	Before int
+}
+
+// normalize applies the default and maximum Limit
+func (p Page) normalize() Page {
+	if p.Limit <= 0 {
+		p.Limit = DefaultPageSize
+	}
+	if p.Limit > MaxPageSize {
+		p.Limit = MaxPageSize
+	}
+	return p
+}
+
+// A MessagePage is a page of messages. NextBefore is the Page.Before value to
+// get the next page, or 0 if this is the last page.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextBefore int       `json:"next_before,omitempty"`
+}
+
+// newMessagePage builds a MessagePage from up to page.Limit+1 messages. The
+// extra message, if present, only signals that there is a next page.
+func newMessagePage(messages []Message, page Page) MessagePage {
+	if len(messages) <= page.Limit {
+		return MessagePage{Messages: messages}
+	}
+	messages = messages[:page.Limit]
+	return MessagePage{
+		Messages:   messages,
+		NextBefore: messages[len(messages)-1].Id,
+	}
 }
 
 // An implemementation of MessageService using a SQL database
 type SQLMessagingService struct{ DBConn *sql.DB }
 
+// messageColumns are the columns scanned by scanMessages, in order.
+const messageColumns = "id, user_from, user_to, text, read_at IS NOT NULL, created_at"
+
+// scanMessages reads messages selected with messageColumns
+func scanMessages(rows *sql.Rows) ([]Message, error) {
+	defer rows.Close()
+	messages := []Message{}
+	for rows.Next() {
+		var (
+			message Message
+			text    sql.NullString
+		)
+		if err := rows.Scan(&message.Id, &message.User, &message.Friend, &text,
+			&message.Read, &message.SentAt); err != nil {
+			return nil, err
+		}
+		message.Text = text.String
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
 // Gets messages from a SQL database
 func (service SQLMessagingService) GetMessages(userTo string) ([]Message,
 	error) {
 	log.Printf("SQLMessagingService.GetMessages, userTo: %s\n", userTo)
-	messages := []Message{}
 	rows, err := service.DBConn.Query(
-		"SELECT user_from, text, id FROM messages WHERE user_to = ?",
+		"SELECT "+messageColumns+" FROM messages WHERE user_to = ? ORDER BY id",
 		userTo)
 	if err != nil {
 		log.Printf("SQLMessagingService.GetMessages, Error in query: %v\n", err)
 		return nil, errors.New("Due to an error, we could not get your messages.")
 	}
-	defer rows.Close()
-	for rows.Next() {
-		message := Message{}
-		if err := rows.Scan(&message.User, &message.Text, &message.Id); err != nil {
-			log.Printf("SQLMessagingService.GetMessages, Error in scan: %v\n", err)
-			return nil, errors.New("Due to an error, we could not get all your " +
-				"messages.")
-		}
-		messages = append(messages, message)
+	messages, err := scanMessages(rows)
+	if err != nil {
+		log.Printf("SQLMessagingService.GetMessages, Error in scan: %v\n", err)
+		return nil, errors.New("Due to an error, we could not get all your " +
+			"messages.")
 	}
 	return messages, nil
 }
@@ -86,6 +183,160 @@ func (service SQLMessagingService) SendMessage(userFrom, userTo,
 	return nil
 }
 
+// Lists conversations from the SQL database. The latest message of each
+// conversation is found by grouping messages by the other participant.
+func (service SQLMessagingService) ListConversations(user string) (
+	[]Conversation, error) {
+	log.Printf("SQLMessagingService.ListConversations, user: %s\n", user)
+	rows, err := service.DBConn.Query(
+		"SELECT "+messageColumns+" FROM messages WHERE id IN ("+
+			"SELECT MAX(id) FROM messages WHERE user_from = ? OR user_to = ? "+
+			"GROUP BY CASE WHEN user_from = ? THEN user_to ELSE user_from END"+
+			") ORDER BY id DESC",
+		user, user, user)
+	if err != nil {
+		log.Printf("SQLMessagingService.ListConversations, Error in query: %v\n",
+			err)
+		return nil, errors.New("Due to an error, we could not get your " +
+			"conversations.")
+	}
+	latest, err := scanMessages(rows)
+	if err != nil {
+		log.Printf("SQLMessagingService.ListConversations, Error in scan: %v\n",
+			err)
+		return nil, errors.New("Due to an error, we could not get your " +
+			"conversations.")
+	}
+
+	unread := map[string]int{}
+	rows, err = service.DBConn.Query(
+		"SELECT user_from, COUNT(*) FROM messages "+
+			"WHERE user_to = ? AND read_at IS NULL GROUP BY user_from",
+		user)
+	if err != nil {
+		log.Printf("SQLMessagingService.ListConversations, Error in query: %v\n",
+			err)
+		return nil, errors.New("Due to an error, we could not get your " +
+			"conversations.")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			friend string
+			count  int
+		)
+		if err := rows.Scan(&friend, &count); err != nil {
+			log.Printf("SQLMessagingService.ListConversations, Error in scan: %v\n",
+				err)
+			return nil, errors.New("Due to an error, we could not get your " +
+				"conversations.")
+		}
+		unread[friend] = count
+	}
+	return buildConversations(user, latest, unread), nil
+}
+
+// Gets a page of a conversation from the SQL database. One more message than
+// requested is read to find out whether there is a next page.
+func (service SQLMessagingService) GetConversation(user, friend string,
+	page Page) (MessagePage, error) {
+	log.Printf("SQLMessagingService.GetConversation, user: %s, friend: %s\n",
+		user, friend)
+	page = page.normalize()
+	query := "SELECT " + messageColumns + " FROM messages " +
+		"WHERE ((user_from = ? AND user_to = ?) OR (user_from = ? AND user_to = ?))"
+	args := []interface{}{user, friend, friend, user}
+	if page.Before > 0 {
+		query += " AND id < ?"
+		args = append(args, page.Before)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, page.Limit+1)
+	rows, err := service.DBConn.Query(query, args...)
+	if err != nil {
+		log.Printf("SQLMessagingService.GetConversation, Error in query: %v\n",
+			err)
+		return MessagePage{}, errors.New("Due to an error, we could not get " +
+			"your messages.")
+	}
+	messages, err := scanMessages(rows)
+	if err != nil {
+		log.Printf("SQLMessagingService.GetConversation, Error in scan: %v\n",
+			err)
+		return MessagePage{}, errors.New("Due to an error, we could not get " +
+			"your messages.")
+	}
+	return newMessagePage(messages, page), nil
+}
+
+// Marks messages as read in the SQL database
+func (service SQLMessagingService) MarkRead(user, friend string) (int, error) {
+	log.Printf("SQLMessagingService.MarkRead, user: %s, friend: %s\n", user,
+		friend)
+	result, err := service.DBConn.Exec(
+		"UPDATE messages SET read_at = CURRENT_TIMESTAMP "+
+			"WHERE user_to = ? AND user_from = ? AND read_at IS NULL",
+		user, friend)
+	if err != nil {
+		log.Printf("SQLMessagingService.MarkRead, Error: %v\n", err)
+		return 0, errors.New("Due to an error, we could not mark your " +
+			"messages as read")
+	}
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
+
+// Counts unread messages in the SQL database
+func (service SQLMessagingService) CountUnread(user string) (int, error) {
+	log.Printf("SQLMessagingService.CountUnread, user: %s\n", user)
+	var count int
+	err := service.DBConn.QueryRow(
+		"SELECT COUNT(*) FROM messages WHERE user_to = ? AND read_at IS NULL",
+		user).Scan(&count)
+	if err != nil {
+		log.Printf("SQLMessagingService.CountUnread, Error: %v\n", err)
+		return 0, errors.New("Due to an error, we could not count your " +
+			"messages")
+	}
+	return count, nil
+}
+
+// Deletes a message from the SQL database
+func (service SQLMessagingService) DeleteMessage(user string, id int) error {
+	log.Printf("SQLMessagingService.DeleteMessage, user: %s, id: %d\n", user,
+		id)
+	result, err := service.DBConn.Exec(
+		"DELETE FROM messages WHERE id = ? AND (user_from = ? OR user_to = ?)",
+		id, user, user)
+	if err != nil {
+		log.Printf("SQLMessagingService.DeleteMessage, Error: %v\n", err)
+		return errors.New("Due to an error, we could not delete your message")
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// buildConversations combines the latest message exchanged with each friend,
+// newest first, with the unread counts per friend
+func buildConversations(user string, latest []Message,
+	unread map[string]int) []Conversation {
+	conversations := []Conversation{}
+	for _, message := range latest {
+		friend := message.User
+		if friend == user {
+			friend = message.Friend
+		}
+		conversations = append(conversations, Conversation{
+			Friend:      friend,
+			LastMessage: message,
+			Unread:      unread[friend],
+		})
+	}
+	return conversations
+}
+
 // Formats a user message
 func FormatMessage(user, friend, message string) string {
 	return fmt.Sprintf("Hi %s! %s! From %s!", friend, message, user)
@@ -98,9 +349,27 @@ func CheckMessages(messageService MessageService,
 	return messageService.GetMessages(userTo)
 }
 
+// Counts the unread messages of a user, with the given MessageService
+func CheckUnread(messageService MessageService, userTo string) (int, error) {
+	log.Printf("CheckUnread, user: %s\n", userTo)
+	return messageService.CountUnread(userTo)
+}
+
 // Formats and sends a user message
 func SendUserMessage(messageService MessageService, message Message) error {
 	text := FormatMessage(message.User, message.Friend, message.Text)
 	error := messageService.SendMessage(message.Friend, message.Friend, text)
 	return error
 }
+
+// Validates a user name as stored in the messages table
+func ValidateUser(user string) error {
+	user = strings.TrimSpace(user)
+	if user == "" {
+		return errors.New("User name must not be empty")
+	}
+	if len(user) > 50 {
+		return errors.New("User name must be at most 50 characters")
+	}
+	return nil
+}
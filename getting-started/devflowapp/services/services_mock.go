@@ -18,17 +18,26 @@ package services
 
 import (
 	"log"
+	"sort"
+	"sync"
+	"time"
 )
 
-// Mock object that saves the messages in app memory
+// Mock object that saves the messages in app memory, keyed by recipient
 type MockMessageService map[string][]Message
 
+// Guards all MockMessageService maps, since the mock is shared between
+// concurrent HTTP requests
+var mockMu sync.Mutex
+
 // Gets messages from app memory
 func (service MockMessageService) GetMessages(userTo string) ([]Message, error) {
+	mockMu.Lock()
+	defer mockMu.Unlock()
 	log.Printf("MockMicroservice.GetMessages, len: %d\n", len(service))
 	messages, ok := service[userTo]
 	if ok {
-		return messages, nil
+		return append([]Message{}, messages...), nil
 	}
 	return []Message{}, nil
 }
@@ -36,19 +45,146 @@ func (service MockMessageService) GetMessages(userTo string) ([]Message, error)
 // Saves messages to app memory
 func (service MockMessageService) SendMessage(userFrom, userTo,
 	text string) error {
+	mockMu.Lock()
+	defer mockMu.Unlock()
 	log.Printf("MockMicroservice.SendMessage, Message: %s\n", text)
 	message := Message{
 		User:   userFrom,
 		Friend: userTo,
 		Text:   text,
-		Id:     len(service),
+		Id:     service.nextID(),
+		SentAt: time.Now().UTC(),
 	}
-	messages, ok := service[userTo]
-	if ok {
+	service[userTo] = append(service[userTo], message)
+	return nil
+}
+
+// Lists conversations from app memory
+func (service MockMessageService) ListConversations(user string) (
+	[]Conversation, error) {
+	mockMu.Lock()
+	defer mockMu.Unlock()
+	log.Printf("MockMicroservice.ListConversations, user: %s\n", user)
+	latestByFriend := map[string]Message{}
+	unread := map[string]int{}
+	for _, message := range service.all() {
+		var friend string
+		switch user {
+		case message.Friend:
+			friend = message.User
+			if !message.Read {
+				unread[friend]++
+			}
+		case message.User:
+			friend = message.Friend
+		default:
+			continue
+		}
+		if message.Id > latestByFriend[friend].Id {
+			latestByFriend[friend] = message
+		}
+	}
+	latest := []Message{}
+	for _, message := range latestByFriend {
+		latest = append(latest, message)
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].Id > latest[j].Id })
+	return buildConversations(user, latest, unread), nil
+}
+
+// Gets a page of a conversation from app memory
+func (service MockMessageService) GetConversation(user, friend string,
+	page Page) (MessagePage, error) {
+	mockMu.Lock()
+	defer mockMu.Unlock()
+	log.Printf("MockMicroservice.GetConversation, user: %s, friend: %s\n", user,
+		friend)
+	page = page.normalize()
+	messages := []Message{}
+	for _, message := range service.all() {
+		between := (message.User == user && message.Friend == friend) ||
+			(message.User == friend && message.Friend == user)
+		if !between || (page.Before > 0 && message.Id >= page.Before) {
+			continue
+		}
 		messages = append(messages, message)
-	} else {
-		messages = []Message{message}
 	}
-	service[userTo] = messages
-	return nil
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Id > messages[j].Id
+	})
+	if len(messages) > page.Limit+1 {
+		messages = messages[:page.Limit+1]
+	}
+	return newMessagePage(messages, page), nil
+}
+
+// Marks messages as read in app memory
+func (service MockMessageService) MarkRead(user, friend string) (int, error) {
+	mockMu.Lock()
+	defer mockMu.Unlock()
+	log.Printf("MockMicroservice.MarkRead, user: %s, friend: %s\n", user, friend)
+	count := 0
+	messages := service[user]
+	for i := range messages {
+		if messages[i].User == friend && !messages[i].Read {
+			messages[i].Read = true
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Counts unread messages in app memory
+func (service MockMessageService) CountUnread(user string) (int, error) {
+	mockMu.Lock()
+	defer mockMu.Unlock()
+	log.Printf("MockMicroservice.CountUnread, user: %s\n", user)
+	count := 0
+	for _, message := range service[user] {
+		if !message.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Deletes a message from app memory
+func (service MockMessageService) DeleteMessage(user string, id int) error {
+	mockMu.Lock()
+	defer mockMu.Unlock()
+	log.Printf("MockMicroservice.DeleteMessage, user: %s, id: %d\n", user, id)
+	for userTo, messages := range service {
+		for i, message := range messages {
+			if message.Id != id {
+				continue
+			}
+			if message.User != user && message.Friend != user {
+				return ErrNotFound
+			}
+			service[userTo] = append(messages[:i:i], messages[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Returns all messages, in no particular order. Callers must hold mockMu.
+func (service MockMessageService) all() []Message {
+	all := []Message{}
+	for _, messages := range service {
+		all = append(all, messages...)
+	}
+	return all
+}
+
+// Returns an id greater than any stored id. Ids are never reused, except
+// after the message with the greatest id is deleted. Callers must hold mockMu.
+func (service MockMessageService) nextID() int {
+	max := 0
+	for _, message := range service.all() {
+		if message.Id > max {
+			max = message.Id
+		}
+	}
+	return max + 1
 }
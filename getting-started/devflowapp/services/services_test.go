@@ -51,3 +51,101 @@ func TestSendUserMessage(t *testing.T) {
 		t.Errorf("TestSendUserMessage: Expected: %d, got %d\n", expected, result)
 	}
 }
+
+func TestConversations(t *testing.T) {
+	messageService := MockMessageService{}
+	for _, m := range []struct{ from, to, text string }{
+		{"Ann", "Bob", "1"},
+		{"Bob", "Ann", "2"},
+		{"Cid", "Ann", "3"},
+		{"Bob", "Ann", "4"},
+		{"Bob", "Cid", "not Ann's"},
+	} {
+		if err := messageService.SendMessage(m.from, m.to, m.text); err != nil {
+			t.Fatalf("TestConversations: Got an error: %v\n", err)
+		}
+	}
+
+	conversations, err := messageService.ListConversations("Ann")
+	if err != nil {
+		t.Fatalf("TestConversations: Got an error: %v\n", err)
+	}
+	if len(conversations) != 2 {
+		t.Fatalf("TestConversations: Expected 2 conversations, got %+v\n",
+			conversations)
+	}
+	if c := conversations[0]; c.Friend != "Bob" || c.LastMessage.Text != "4" ||
+		c.Unread != 2 {
+		t.Errorf("TestConversations: Expected Bob, 4, 2 unread, got %+v\n", c)
+	}
+	if c := conversations[1]; c.Friend != "Cid" || c.Unread != 1 {
+		t.Errorf("TestConversations: Expected Cid, 1 unread, got %+v\n", c)
+	}
+
+	count, err := messageService.MarkRead("Ann", "Bob")
+	if err != nil || count != 2 {
+		t.Errorf("TestConversations: MarkRead expected 2, got %d, %v\n", count,
+			err)
+	}
+	unread, err := CheckUnread(messageService, "Ann")
+	if err != nil || unread != 1 {
+		t.Errorf("TestConversations: CheckUnread expected 1, got %d, %v\n",
+			unread, err)
+	}
+}
+
+func TestGetConversationPagination(t *testing.T) {
+	messageService := MockMessageService{}
+	for i := 0; i < 5; i++ {
+		if err := messageService.SendMessage("Ann", "Bob", "hi"); err != nil {
+			t.Fatalf("TestGetConversationPagination: Got an error: %v\n", err)
+		}
+	}
+	var ids []int
+	page := Page{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatalf("TestGetConversationPagination: Too many pages\n")
+		}
+		messagePage, err := messageService.GetConversation("Bob", "Ann", page)
+		if err != nil {
+			t.Fatalf("TestGetConversationPagination: Got an error: %v\n", err)
+		}
+		for _, message := range messagePage.Messages {
+			ids = append(ids, message.Id)
+		}
+		if messagePage.NextBefore == 0 {
+			break
+		}
+		page.Before = messagePage.NextBefore
+	}
+	expected := "[5 4 3 2 1]"
+	if result := fmt.Sprint(ids); result != expected {
+		t.Errorf("TestGetConversationPagination: Expected: %s, got %s\n",
+			expected, result)
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	messageService := MockMessageService{}
+	if err := messageService.SendMessage("Ann", "Bob", "oops"); err != nil {
+		t.Fatalf("TestDeleteMessage: Got an error: %v\n", err)
+	}
+	messages, _ := messageService.GetMessages("Bob")
+	id := messages[0].Id
+	if err := messageService.DeleteMessage("Cid", id); err != ErrNotFound {
+		t.Errorf("TestDeleteMessage: Expected ErrNotFound for a stranger, "+
+			"got %v\n", err)
+	}
+	if err := messageService.DeleteMessage("Ann", id); err != nil {
+		t.Errorf("TestDeleteMessage: Got an error: %v\n", err)
+	}
+	if err := messageService.DeleteMessage("Ann", id); err != ErrNotFound {
+		t.Errorf("TestDeleteMessage: Expected ErrNotFound after delete, "+
+			"got %v\n", err)
+	}
+	if messages, _ := messageService.GetMessages("Bob"); len(messages) != 0 {
+		t.Errorf("TestDeleteMessage: Expected no messages, got %d\n",
+			len(messages))
+	}
+}
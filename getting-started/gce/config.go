@@ -0,0 +1,122 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+// config is the server configuration.
+type config struct {
+	Port            string
+	Version         string
+	DrainDelay      time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// setting is a configuration value, looked up in order in the instance
+// metadata attribute, the project metadata attribute and the environment
+// variable, and defaulting to def.
+type setting struct {
+	attr, env, def string
+}
+
+var (
+	portSetting            = setting{"port", "PORT", "8080"}
+	versionSetting         = setting{"app-version", "APP_VERSION", ""}
+	drainDelaySetting      = setting{"drain-delay", "DRAIN_DELAY", "10s"}
+	shutdownTimeoutSetting = setting{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "15s"}
+)
+
+// metadataSource looks up metadata attributes. It's satisfied by
+// *metadata.Client.
+type metadataSource interface {
+	InstanceAttributeValueWithContext(ctx context.Context, attr string) (string, error)
+	ProjectAttributeValueWithContext(ctx context.Context, attr string) (string, error)
+}
+
+// newMetadataSource returns the metadata server client, or nil when not
+// running on Google Cloud, so local runs don't wait for the metadata server
+// to time out.
+func newMetadataSource(ctx context.Context) metadataSource {
+	c := metadata.NewClient(nil)
+	if !c.OnGCEWithContext(ctx) {
+		return nil
+	}
+	return c
+}
+
+// loadConfig reads the configuration from md, which may be nil, with
+// fallbacks to environment variables and defaults.
+func loadConfig(ctx context.Context, md metadataSource) (config, error) {
+	get := func(s setting) (string, error) {
+		if md != nil {
+			for _, lookup := range []func(context.Context, string) (string, error){
+				md.InstanceAttributeValueWithContext,
+				md.ProjectAttributeValueWithContext,
+			} {
+				v, err := lookup(ctx, s.attr)
+				var notDefined metadata.NotDefinedError
+				switch {
+				case err == nil && v != "":
+					return v, nil
+				case err != nil && !errors.As(err, &notDefined):
+					return "", fmt.Errorf("metadata attribute %q: %w", s.attr, err)
+				}
+			}
+		}
+		if v := os.Getenv(s.env); v != "" {
+			return v, nil
+		}
+		return s.def, nil
+	}
+	duration := func(s setting) (time.Duration, error) {
+		v, err := get(s)
+		if err != nil {
+			return 0, err
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("%s: invalid duration %q", s.attr, v)
+		}
+		return d, nil
+	}
+
+	var cfg config
+	var err error
+	if cfg.Port, err = get(portSetting); err != nil {
+		return config{}, err
+	}
+	if n, err := strconv.Atoi(cfg.Port); err != nil || n <= 0 || n > 65535 {
+		return config{}, fmt.Errorf("port: invalid port %q", cfg.Port)
+	}
+	if cfg.Version, err = get(versionSetting); err != nil {
+		return config{}, err
+	}
+	if cfg.DrainDelay, err = duration(drainDelaySetting); err != nil {
+		return config{}, err
+	}
+	if cfg.ShutdownTimeout, err = duration(shutdownTimeoutSetting); err != nil {
+		return config{}, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	compute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/proto"
+)
+
+// deployer creates and deletes the resources of a Plan.
+type deployer struct {
+	w       io.Writer
+	project string
+	zone    string
+
+	firewalls    *compute.FirewallsClient
+	healthChecks *compute.HealthChecksClient
+	templates    *compute.InstanceTemplatesClient
+	groups       *compute.InstanceGroupManagersClient
+}
+
+func newDeployer(ctx context.Context, w io.Writer, project, zone string) (*deployer, error) {
+	d := &deployer{w: w, project: project, zone: zone}
+	var err error
+	if d.firewalls, err = compute.NewFirewallsRESTClient(ctx); err != nil {
+		return nil, fmt.Errorf("NewFirewallsRESTClient: %w", err)
+	}
+	if d.healthChecks, err = compute.NewHealthChecksRESTClient(ctx); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("NewHealthChecksRESTClient: %w", err)
+	}
+	if d.templates, err = compute.NewInstanceTemplatesRESTClient(ctx); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("NewInstanceTemplatesRESTClient: %w", err)
+	}
+	if d.groups, err = compute.NewInstanceGroupManagersRESTClient(ctx); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("NewInstanceGroupManagersRESTClient: %w", err)
+	}
+	return d, nil
+}
+
+// Close closes the clients.
+func (d *deployer) Close() {
+	if d.firewalls != nil {
+		d.firewalls.Close()
+	}
+	if d.healthChecks != nil {
+		d.healthChecks.Close()
+	}
+	if d.templates != nil {
+		d.templates.Close()
+	}
+	if d.groups != nil {
+		d.groups.Close()
+	}
+}
+
+// isNotFound reports whether err is a 404 from the API.
+func isNotFound(err error) bool {
+	var apiErr *apierror.APIError
+	return errors.As(err, &apiErr) && apiErr.HTTPCode() == http.StatusNotFound
+}
+
+// ensure creates a resource with insert unless get finds it.
+func (d *deployer) ensure(ctx context.Context, kind, name string, get func() error, insert func() (*compute.Operation, error)) error {
+	err := get()
+	if err == nil {
+		fmt.Fprintf(d.w, "%s %s exists\n", kind, name)
+		return nil
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("getting %s %s: %w", kind, name, err)
+	}
+	op, err := insert()
+	if err != nil {
+		return fmt.Errorf("creating %s %s: %w", kind, name, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("creating %s %s: %w", kind, name, err)
+	}
+	fmt.Fprintf(d.w, "%s %s created\n", kind, name)
+	return nil
+}
+
+// apply creates the resources of p that don't exist yet. If the group
+// exists, it is switched to the new instance template, which starts a
+// rolling update.
+func (d *deployer) apply(ctx context.Context, p *Plan) error {
+	if err := d.ensure(ctx, "firewall rule", p.Firewall.GetName(),
+		func() error {
+			_, err := d.firewalls.Get(ctx, &computepb.GetFirewallRequest{Project: d.project, Firewall: p.Firewall.GetName()})
+			return err
+		},
+		func() (*compute.Operation, error) {
+			return d.firewalls.Insert(ctx, &computepb.InsertFirewallRequest{Project: d.project, FirewallResource: p.Firewall})
+		}); err != nil {
+		return err
+	}
+	if err := d.ensure(ctx, "health check", p.HealthCheck.GetName(),
+		func() error {
+			_, err := d.healthChecks.Get(ctx, &computepb.GetHealthCheckRequest{Project: d.project, HealthCheck: p.HealthCheck.GetName()})
+			return err
+		},
+		func() (*compute.Operation, error) {
+			return d.healthChecks.Insert(ctx, &computepb.InsertHealthCheckRequest{Project: d.project, HealthCheckResource: p.HealthCheck})
+		}); err != nil {
+		return err
+	}
+	if err := d.ensure(ctx, "instance template", p.InstanceTemplate.GetName(),
+		func() error {
+			_, err := d.templates.Get(ctx, &computepb.GetInstanceTemplateRequest{Project: d.project, InstanceTemplate: p.InstanceTemplate.GetName()})
+			return err
+		},
+		func() (*compute.Operation, error) {
+			return d.templates.Insert(ctx, &computepb.InsertInstanceTemplateRequest{Project: d.project, InstanceTemplateResource: p.InstanceTemplate})
+		}); err != nil {
+		return err
+	}
+
+	name := p.Group.GetName()
+	current, err := d.groups.Get(ctx, &computepb.GetInstanceGroupManagerRequest{Project: d.project, Zone: d.zone, InstanceGroupManager: name})
+	if isNotFound(err) {
+		op, err := d.groups.Insert(ctx, &computepb.InsertInstanceGroupManagerRequest{Project: d.project, Zone: d.zone, InstanceGroupManagerResource: p.Group})
+		if err != nil {
+			return fmt.Errorf("creating instance group %s: %w", name, err)
+		}
+		if err := op.Wait(ctx); err != nil {
+			return fmt.Errorf("creating instance group %s: %w", name, err)
+		}
+		fmt.Fprintf(d.w, "instance group %s created\n", name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting instance group %s: %w", name, err)
+	}
+
+	op, err := d.groups.Patch(ctx, &computepb.PatchInstanceGroupManagerRequest{
+		Project:              d.project,
+		Zone:                 d.zone,
+		InstanceGroupManager: name,
+		InstanceGroupManagerResource: &computepb.InstanceGroupManager{
+			Versions:            p.Group.Versions,
+			UpdatePolicy:        p.Group.UpdatePolicy,
+			AutoHealingPolicies: p.Group.AutoHealingPolicies,
+			NamedPorts:          p.Group.NamedPorts,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("updating instance group %s: %w", name, err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("updating instance group %s: %w", name, err)
+	}
+	fmt.Fprintf(d.w, "instance group %s rolling out %s\n", name, p.InstanceTemplate.GetName())
+
+	if current.GetTargetSize() != p.Group.GetTargetSize() {
+		op, err := d.groups.Resize(ctx, &computepb.ResizeInstanceGroupManagerRequest{
+			Project:              d.project,
+			Zone:                 d.zone,
+			InstanceGroupManager: name,
+			Size:                 p.Group.GetTargetSize(),
+		})
+		if err != nil {
+			return fmt.Errorf("resizing instance group %s: %w", name, err)
+		}
+		if err := op.Wait(ctx); err != nil {
+			return fmt.Errorf("resizing instance group %s: %w", name, err)
+		}
+		fmt.Fprintf(d.w, "instance group %s resized to %d\n", name, p.Group.GetTargetSize())
+	}
+	return nil
+}
+
+// waitStable polls the group until all instances run the target version and
+// are healthy.
+func (d *deployer) waitStable(ctx context.Context, name string, interval time.Duration) error {
+	for {
+		g, err := d.groups.Get(ctx, &computepb.GetInstanceGroupManagerRequest{Project: d.project, Zone: d.zone, InstanceGroupManager: name})
+		if err != nil {
+			return fmt.Errorf("getting instance group %s: %w", name, err)
+		}
+		st := g.GetStatus()
+		if st.GetIsStable() && st.GetVersionTarget().GetIsReached() {
+			fmt.Fprintf(d.w, "instance group %s is stable\n", name)
+			return nil
+		}
+		fmt.Fprintf(d.w, "waiting for instance group %s: %+v\n", name, g.GetCurrentActions())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// teardown deletes the resources of c, including the instance templates of
+// all versions. Missing resources are skipped.
+func (d *deployer) teardown(ctx context.Context, c Config) error {
+	del := func(kind, name string, call func() (*compute.Operation, error)) error {
+		op, err := call()
+		if isNotFound(err) {
+			fmt.Fprintf(d.w, "%s %s not found\n", kind, name)
+			return nil
+		}
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("deleting %s %s: %w", kind, name, err)
+		}
+		fmt.Fprintf(d.w, "%s %s deleted\n", kind, name)
+		return nil
+	}
+
+	if err := del("instance group", c.groupName(), func() (*compute.Operation, error) {
+		return d.groups.Delete(ctx, &computepb.DeleteInstanceGroupManagerRequest{Project: d.project, Zone: d.zone, InstanceGroupManager: c.groupName()})
+	}); err != nil {
+		return err
+	}
+
+	it := d.templates.List(ctx, &computepb.ListInstanceTemplatesRequest{
+		Project: d.project,
+		Filter:  proto.String(fmt.Sprintf("name eq %s-v.*", c.Name)),
+	})
+	for {
+		t, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("listing instance templates: %w", err)
+		}
+		if err := del("instance template", t.GetName(), func() (*compute.Operation, error) {
+			return d.templates.Delete(ctx, &computepb.DeleteInstanceTemplateRequest{Project: d.project, InstanceTemplate: t.GetName()})
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := del("health check", c.healthCheckName(), func() (*compute.Operation, error) {
+		return d.healthChecks.Delete(ctx, &computepb.DeleteHealthCheckRequest{Project: d.project, HealthCheck: c.healthCheckName()})
+	}); err != nil {
+		return err
+	}
+	return del("firewall rule", c.firewallName(), func() (*compute.Operation, error) {
+		return d.firewalls.Delete(ctx, &computepb.DeleteFirewallRequest{Project: d.project, Firewall: c.firewallName()})
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command deployer deploys the gce app to a managed instance group.
+//
+// Build the app tarball with Cloud Build first:
+//
+//	gcloud builds submit --substitutions=_DEPLOY_DIR=gs://my-bucket,_DEPLOY_FILENAME=app.tar.gz
+//
+// Then create the firewall rule, health check, instance template and group,
+// or roll out a new version to an existing group:
+//
+//	go run ./deployer -project my-project -app-location gs://my-bucket/app.tar.gz up
+//
+// Use -dry-run to print the planned resources without creating them, and
+// "down" to delete everything.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func main() {
+	cfg := Config{}
+	flag.StringVar(&cfg.Project, "project", os.Getenv("GOOGLE_CLOUD_PROJECT"), "Google Cloud project ID")
+	flag.StringVar(&cfg.Zone, "zone", "us-central1-f", "zone of the instance group")
+	flag.StringVar(&cfg.Name, "name", "my-app", "prefix of the resource names")
+	flag.StringVar(&cfg.AppLocation, "app-location", defaultAppLocation(), "Cloud Storage URL of the app tarball, defaults to $DEPLOY_DIR/$DEPLOY_FILENAME")
+	flag.StringVar(&cfg.Version, "version", time.Now().UTC().Format("20060102-150405"), "version of the app, used in the instance template name")
+	flag.StringVar(&cfg.MachineType, "machine-type", "e2-small", "machine type of the instances")
+	flag.StringVar(&cfg.Image, "image", "projects/debian-cloud/global/images/family/debian-12", "boot disk image of the instances")
+	size := flag.Int("size", 2, "number of instances")
+	port := flag.Int("port", 80, "port the app listens on")
+	dryRun := flag.Bool("dry-run", false, "print the planned resources without changing anything")
+	wait := flag.Bool("wait", false, "after up, wait until the group is stable")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] up|down\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	cfg.Size = int32(*size)
+	cfg.Port = int32(*port)
+
+	cmd := flag.Arg(0)
+	if flag.NArg() != 1 || (cmd != "up" && cmd != "down") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(context.Background(), os.Stdout, cmd, cfg, *dryRun, *wait); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func defaultAppLocation() string {
+	dir, file := os.Getenv("DEPLOY_DIR"), os.Getenv("DEPLOY_FILENAME")
+	if dir == "" || file == "" {
+		return ""
+	}
+	return strings.TrimSuffix(dir, "/") + "/" + file
+}
+
+func run(ctx context.Context, w io.Writer, cmd string, cfg Config, dryRun, wait bool) error {
+	if cmd == "down" {
+		if cfg.Project == "" {
+			return fmt.Errorf("missing project")
+		}
+		if dryRun {
+			printTeardown(w, cfg)
+			return nil
+		}
+		d, err := newDeployer(ctx, w, cfg.Project, cfg.Zone)
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+		return d.teardown(ctx, cfg)
+	}
+
+	p, err := cfg.Plan()
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return printPlan(w, p)
+	}
+	d, err := newDeployer(ctx, w, cfg.Project, cfg.Zone)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.apply(ctx, p); err != nil {
+		return err
+	}
+	if wait {
+		return d.waitStable(ctx, p.Group.GetName(), 15*time.Second)
+	}
+	return nil
+}
+
+// printPlan prints the resources of p as JSON.
+func printPlan(w io.Writer, p *Plan) error {
+	for _, r := range []struct {
+		kind string
+		msg  proto.Message
+	}{
+		{"firewall rule", p.Firewall},
+		{"health check", p.HealthCheck},
+		{"instance template", p.InstanceTemplate},
+		{"instance group", p.Group},
+	} {
+		b, err := protojson.MarshalOptions{Multiline: true}.Marshal(r.msg)
+		if err != nil {
+			return fmt.Errorf("protojson.Marshal: %w", err)
+		}
+		fmt.Fprintf(w, "# %s\n%s\n", r.kind, b)
+	}
+	return nil
+}
+
+// printTeardown prints the resources teardown deletes.
+func printTeardown(w io.Writer, c Config) {
+	fmt.Fprintf(w, "would delete instance group %s in %s\n", c.groupName(), c.Zone)
+	fmt.Fprintf(w, "would delete instance templates %s-v*\n", c.Name)
+	fmt.Fprintf(w, "would delete health check %s\n", c.healthCheckName())
+	fmt.Fprintf(w, "would delete firewall rule %s\n", c.firewallName())
+}
@@ -0,0 +1,184 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// startupScript installs and starts the app on each instance.
+//
+//go:embed startup-script.sh
+var startupScript string
+
+// healthCheckRanges are the source ranges of Google Cloud health checkers.
+var healthCheckRanges = []string{"130.211.0.0/22", "35.191.0.0/16"}
+
+// Config describes a deployment.
+type Config struct {
+	Project string
+	Zone    string
+	// Name prefixes the names of all resources.
+	Name string
+	// AppLocation is the Cloud Storage URL of the app tarball built by
+	// cloudbuild.yaml, e.g. gs://my-bucket/app.tar.gz.
+	AppLocation string
+	// Version names the instance template of this deployment. Deploying a
+	// new version triggers a rolling update of the group.
+	Version     string
+	MachineType string
+	Image       string
+	Size        int32
+	Port        int32
+}
+
+// Plan holds the resources of a deployment.
+type Plan struct {
+	Firewall         *computepb.Firewall
+	HealthCheck      *computepb.HealthCheck
+	InstanceTemplate *computepb.InstanceTemplate
+	Group            *computepb.InstanceGroupManager
+}
+
+var resourceName = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+func (c Config) validate() error {
+	switch {
+	case c.Project == "":
+		return fmt.Errorf("missing project")
+	case c.Zone == "":
+		return fmt.Errorf("missing zone")
+	case !resourceName.MatchString(c.Name):
+		return fmt.Errorf("invalid name %q: use lowercase letters, digits and dashes", c.Name)
+	case !resourceName.MatchString("v" + c.Version):
+		return fmt.Errorf("invalid version %q: use lowercase letters, digits and dashes", c.Version)
+	case len(c.templateName()) > 63:
+		return fmt.Errorf("name and version are too long for instance template name %q", c.templateName())
+	case c.AppLocation == "":
+		return fmt.Errorf("missing app location")
+	case c.Size < 1:
+		return fmt.Errorf("size must be positive, got %d", c.Size)
+	case c.Port < 1 || c.Port > 65535:
+		return fmt.Errorf("invalid port %d", c.Port)
+	}
+	return nil
+}
+
+func (c Config) firewallName() string    { return c.Name + "-allow-http" }
+func (c Config) healthCheckName() string { return c.Name + "-health" }
+func (c Config) groupName() string       { return c.Name + "-group" }
+
+// templateName is the instance template name of c.Version. The "-v" lets
+// teardown find the templates of all versions.
+func (c Config) templateName() string {
+	return c.Name + "-v" + strings.TrimPrefix(c.Version, "v")
+}
+
+// Plan returns the resources of the deployment described by c.
+func (c Config) Plan() (*Plan, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(int(c.Port))
+	tag := c.Name + "-http-server"
+
+	return &Plan{
+		// The rule lets both clients and health checkers reach the app.
+		Firewall: &computepb.Firewall{
+			Name:         proto.String(c.firewallName()),
+			Network:      proto.String("global/networks/default"),
+			Direction:    proto.String(computepb.Firewall_INGRESS.String()),
+			Allowed:      []*computepb.Allowed{{IPProtocol: proto.String("tcp"), Ports: []string{port}}},
+			SourceRanges: append([]string{"0.0.0.0/0"}, healthCheckRanges...),
+			TargetTags:   []string{tag},
+			Description:  proto.String(fmt.Sprintf("Allow port %s access to %s", port, c.Name)),
+		},
+		// Autohealing recreates instances failing the liveness endpoint.
+		HealthCheck: &computepb.HealthCheck{
+			Name: proto.String(c.healthCheckName()),
+			Type: proto.String(computepb.HealthCheck_HTTP.String()),
+			HttpHealthCheck: &computepb.HTTPHealthCheck{
+				Port:        proto.Int32(c.Port),
+				RequestPath: proto.String("/healthz"),
+			},
+			CheckIntervalSec:   proto.Int32(10),
+			TimeoutSec:         proto.Int32(5),
+			HealthyThreshold:   proto.Int32(2),
+			UnhealthyThreshold: proto.Int32(3),
+		},
+		InstanceTemplate: &computepb.InstanceTemplate{
+			Name: proto.String(c.templateName()),
+			Properties: &computepb.InstanceProperties{
+				MachineType: proto.String(c.MachineType),
+				Disks: []*computepb.AttachedDisk{{
+					InitializeParams: &computepb.AttachedDiskInitializeParams{
+						SourceImage: proto.String(c.Image),
+					},
+					AutoDelete: proto.Bool(true),
+					Boot:       proto.Bool(true),
+				}},
+				NetworkInterfaces: []*computepb.NetworkInterface{{
+					Name: proto.String("global/networks/default"),
+					AccessConfigs: []*computepb.AccessConfig{{
+						Name: proto.String("External NAT"),
+						Type: proto.String(computepb.AccessConfig_ONE_TO_ONE_NAT.String()),
+					}},
+				}},
+				Metadata: &computepb.Metadata{Items: []*computepb.Items{
+					{Key: proto.String("startup-script"), Value: proto.String(startupScript)},
+					{Key: proto.String("app-location"), Value: proto.String(c.AppLocation)},
+					{Key: proto.String("app-version"), Value: proto.String(c.Version)},
+					{Key: proto.String("port"), Value: proto.String(port)},
+				}},
+				ServiceAccounts: []*computepb.ServiceAccount{{
+					Email:  proto.String("default"),
+					Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+				}},
+				Tags: &computepb.Tags{Items: []string{tag}},
+			},
+		},
+		Group: &computepb.InstanceGroupManager{
+			Name:             proto.String(c.groupName()),
+			BaseInstanceName: proto.String(c.Name),
+			TargetSize:       proto.Int32(c.Size),
+			Versions: []*computepb.InstanceGroupManagerVersion{{
+				Name:             proto.String(c.Version),
+				InstanceTemplate: proto.String(fmt.Sprintf("projects/%s/global/instanceTemplates/%s", c.Project, c.templateName())),
+			}},
+			NamedPorts: []*computepb.NamedPort{{Name: proto.String("http"), Port: proto.Int32(c.Port)}},
+			AutoHealingPolicies: []*computepb.InstanceGroupManagerAutoHealingPolicy{{
+				HealthCheck: proto.String(fmt.Sprintf("projects/%s/global/healthChecks/%s", c.Project, c.healthCheckName())),
+				// Leave time for the startup script to install the app.
+				InitialDelaySec: proto.Int32(300),
+			}},
+			// A new version replaces instances one at a time, creating the
+			// new instance before the old one stops, so capacity never drops.
+			UpdatePolicy: &computepb.InstanceGroupManagerUpdatePolicy{
+				Type:              proto.String("PROACTIVE"),
+				MinimalAction:     proto.String("REPLACE"),
+				MaxSurge:          &computepb.FixedOrPercent{Fixed: proto.Int32(1)},
+				MaxUnavailable:    &computepb.FixedOrPercent{Fixed: proto.Int32(0)},
+				ReplacementMethod: proto.String(computepb.InstanceGroupManagerUpdatePolicy_SUBSTITUTE.String()),
+			},
+		},
+	}, nil
+}
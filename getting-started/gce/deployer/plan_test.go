@@ -0,0 +1,111 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+var testConfig = Config{
+	Project:     "my-project",
+	Zone:        "us-central1-f",
+	Name:        "my-app",
+	AppLocation: "gs://my-bucket/app.tar.gz",
+	Version:     "20261018-120000",
+	MachineType: "e2-small",
+	Image:       "projects/debian-cloud/global/images/family/debian-12",
+	Size:        2,
+	Port:        80,
+}
+
+func TestPlan(t *testing.T) {
+	p, err := testConfig.Plan()
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if got, want := p.InstanceTemplate.GetName(), "my-app-v20261018-120000"; got != want {
+		t.Errorf("template name = %q, want %q", got, want)
+	}
+	if got, want := p.Group.GetVersions()[0].GetInstanceTemplate(), "projects/my-project/global/instanceTemplates/my-app-v20261018-120000"; got != want {
+		t.Errorf("group template = %q, want %q", got, want)
+	}
+	if got, want := p.Group.GetAutoHealingPolicies()[0].GetHealthCheck(), "projects/my-project/global/healthChecks/my-app-health"; got != want {
+		t.Errorf("autohealing health check = %q, want %q", got, want)
+	}
+	if got := p.HealthCheck.GetHttpHealthCheck().GetRequestPath(); got != "/healthz" {
+		t.Errorf("health check path = %q, want /healthz", got)
+	}
+	if got := p.Group.GetUpdatePolicy().GetMaxUnavailable().GetFixed(); got != 0 {
+		t.Errorf("max unavailable = %d, want 0", got)
+	}
+
+	metadata := map[string]string{}
+	for _, item := range p.InstanceTemplate.GetProperties().GetMetadata().GetItems() {
+		metadata[item.GetKey()] = item.GetValue()
+	}
+	if metadata["app-location"] != testConfig.AppLocation || metadata["app-version"] != testConfig.Version || metadata["port"] != "80" {
+		t.Errorf("template metadata = %v, want app location, version and port", metadata)
+	}
+	if !strings.Contains(metadata["startup-script"], "app-location") {
+		t.Errorf("template startup script doesn't fetch the app:\n%s", metadata["startup-script"])
+	}
+
+	tag := p.InstanceTemplate.GetProperties().GetTags().GetItems()[0]
+	if got := p.Firewall.GetTargetTags(); len(got) != 1 || got[0] != tag {
+		t.Errorf("firewall target tags = %v, want [%s]", got, tag)
+	}
+}
+
+func TestPlanInvalid(t *testing.T) {
+	for name, mutate := range map[string]func(*Config){
+		"no project":   func(c *Config) { c.Project = "" },
+		"bad name":     func(c *Config) { c.Name = "My_App" },
+		"bad version":  func(c *Config) { c.Version = "1.2" },
+		"long version": func(c *Config) { c.Version = strings.Repeat("1", 60) },
+		"no app":       func(c *Config) { c.AppLocation = "" },
+		"no instances": func(c *Config) { c.Size = 0 },
+		"bad port":     func(c *Config) { c.Port = 70000 },
+	} {
+		c := testConfig
+		mutate(&c)
+		if _, err := c.Plan(); err == nil {
+			t.Errorf("%s: Plan got nil error, want error", name)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if err := run(ctx, &buf, "up", testConfig, true, false); err != nil {
+		t.Fatalf("run up: %v", err)
+	}
+	for _, want := range []string{"# firewall rule", `"my-app-allow-http"`, "# instance group", `"/healthz"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("dry run output is missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := run(ctx, &buf, "down", testConfig, true, false); err != nil {
+		t.Fatalf("run down: %v", err)
+	}
+	if !strings.Contains(buf.String(), "would delete instance group my-app-group") {
+		t.Errorf("dry run teardown output = %q", buf.String())
+	}
+}
@@ -13,28 +13,110 @@
 // limitations under the License.
 
 // Command gce starts a web server.
+//
+// The server is meant to run in a managed instance group (MIG). It serves
+// liveness (/healthz) and readiness (/readyz) endpoints for autohealing and
+// load balancing, and drains gracefully on SIGTERM, which is what instances
+// receive when a rolling update replaces them.
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 func main() {
-	http.HandleFunc("/", index)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := loadConfig(ctx, newMetadataSource(ctx))
+	if err != nil {
+		log.Fatalf("loadConfig: %v", err)
+	}
+	lis, err := net.Listen("tcp", ":"+cfg.Port)
+	if err != nil {
+		log.Fatal(err)
 	}
-	log.Printf("Listening on port %s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	log.Printf("Listening on port %s, version %q", cfg.Port, cfg.Version)
+	if err := newServer(cfg).serve(ctx, lis); err != nil {
 		log.Fatal(err)
 	}
+	log.Print("Shut down")
 }
 
-func index(w http.ResponseWriter, r *http.Request) {
+// server is the web server and its readiness state.
+type server struct {
+	cfg   config
+	ready atomic.Bool
+	http  *http.Server
+}
+
+func newServer(cfg config) *server {
+	s := &server{cfg: cfg}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.index)
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	s.http = &http.Server{Handler: mux}
+	return s
+}
+
+// serve serves requests on lis until ctx is done, then drains: /readyz
+// starts failing so load balancers stop sending new requests, and after
+// cfg.DrainDelay in-flight requests get up to cfg.ShutdownTimeout to finish.
+func (s *server) serve(ctx context.Context, lis net.Listener) error {
+	errc := make(chan error, 1)
+	go func() { errc <- s.http.Serve(lis) }()
+	s.ready.Store(true)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Draining for %v", s.cfg.DrainDelay)
+	s.ready.Store(false)
+	time.Sleep(s.cfg.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("Shutdown: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *server) index(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Hello, World!")
+	if s.cfg.Version != "" {
+		fmt.Fprintf(w, " (version %s)", s.cfg.Version)
+	}
+}
+
+// healthz reports whether the process is alive. The MIG autohealing health
+// check uses it, so it keeps succeeding while the server drains.
+func (s *server) healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz reports whether the server accepts new requests.
+func (s *server) readyz(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
 }
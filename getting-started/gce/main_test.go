@@ -0,0 +1,187 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+// fakeMetadata serves instance and project attributes from maps.
+type fakeMetadata struct {
+	instance, project map[string]string
+	err               error
+}
+
+func (f fakeMetadata) lookup(m map[string]string, attr string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	if v, ok := m[attr]; ok {
+		return v, nil
+	}
+	return "", metadata.NotDefinedError("instance/attributes/" + attr)
+}
+
+func (f fakeMetadata) InstanceAttributeValueWithContext(ctx context.Context, attr string) (string, error) {
+	return f.lookup(f.instance, attr)
+}
+
+func (f fakeMetadata) ProjectAttributeValueWithContext(ctx context.Context, attr string) (string, error) {
+	return f.lookup(f.project, attr)
+}
+
+func TestLoadConfig(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("PORT", "")
+	t.Setenv("APP_VERSION", "")
+
+	cfg, err := loadConfig(ctx, nil)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	want := config{Port: "8080", DrainDelay: 10 * time.Second, ShutdownTimeout: 15 * time.Second}
+	if cfg != want {
+		t.Errorf("loadConfig without metadata got %+v, want %+v", cfg, want)
+	}
+
+	t.Setenv("PORT", "80")
+	t.Setenv("APP_VERSION", "from-env")
+	md := fakeMetadata{
+		instance: map[string]string{"app-version": "v2"},
+		project:  map[string]string{"app-version": "v1", "drain-delay": "1s"},
+	}
+	cfg, err = loadConfig(ctx, md)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	want = config{Port: "80", Version: "v2", DrainDelay: time.Second, ShutdownTimeout: 15 * time.Second}
+	if cfg != want {
+		t.Errorf("loadConfig got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	ctx := context.Background()
+	for name, md := range map[string]fakeMetadata{
+		"bad port":     {instance: map[string]string{"port": "http"}},
+		"bad duration": {instance: map[string]string{"shutdown-timeout": "15"}},
+		"unavailable":  {err: errors.New("connection refused")},
+	} {
+		if cfg, err := loadConfig(ctx, md); err == nil {
+			t.Errorf("%s: loadConfig got %+v, want error", name, cfg)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	s := newServer(config{Version: "v3"})
+	s.ready.Store(true)
+	for _, tc := range []struct {
+		path, want string
+		code       int
+	}{
+		{"/", "Hello, World! (version v3)", http.StatusOK},
+		{"/healthz", "ok\n", http.StatusOK},
+		{"/readyz", "ready\n", http.StatusOK},
+	} {
+		rr := httptest.NewRecorder()
+		s.http.Handler.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+		if rr.Code != tc.code || rr.Body.String() != tc.want {
+			t.Errorf("GET %s got %d %q, want %d %q", tc.path, rr.Code, rr.Body, tc.code, tc.want)
+		}
+	}
+
+	s.ready.Store(false)
+	rr := httptest.NewRecorder()
+	s.http.Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz while draining got %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + lis.Addr().String()
+	s := newServer(config{DrainDelay: 200 * time.Millisecond, ShutdownTimeout: 5 * time.Second})
+
+	// A slow request started before the shutdown must complete.
+	release := make(chan struct{})
+	s.http.Handler.(*http.ServeMux).HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.serve(ctx, lis) }()
+	waitFor(t, func() bool { return s.ready.Load() })
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		slow <- string(b)
+	}()
+
+	cancel()
+	waitFor(t, func() bool { return !s.ready.Load() })
+	// During the drain delay the server is alive but not ready.
+	for path, want := range map[string]int{"/healthz": http.StatusOK, "/readyz": http.StatusServiceUnavailable} {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatalf("GET %s while draining: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s while draining got %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+	close(release)
+
+	if got := <-slow; !strings.Contains(got, "done") {
+		t.Errorf("in-flight request got %q, want it to complete", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("serve: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("timed out waiting for condition")
+}
@@ -1,23 +0,0 @@
-#! /bin/sh
-
-# Copyright 2019 Google LLC
-# 
-# Licensed under the Apache License, Version 2.0 (the "License");
-# you may not use this file except in compliance with the License.
-# You may obtain a copy of the License at
-#
-#     https://www.apache.org/licenses/LICENSE-2.0
-#
-# Unless required by applicable law or agreed to in writing, software
-# distributed under the License is distributed on an "AS IS" BASIS,
-# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-# See the License for the specific language governing permissions and
-# limitations under the License.
-
-set -ex
-
-ZONE=us-central1-f
-
-gcloud compute instances delete my-app-instance --zone=$ZONE --delete-disks=all
-
-gcloud compute firewall-rules delete default-allow-http-80
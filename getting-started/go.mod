@@ -2,6 +2,9 @@ module github.com/GoogleCloudPlatform/golang-samples/getting-started
 
 go 1.25.0
 
-require github.com/go-sql-driver/mysql v1.8.1
+require (
+	github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql v0.1.0
+	github.com/go-sql-driver/mysql v1.8.1
+)
 
 require filippo.io/edwards25519 v1.1.1 // indirect
@@ -9,6 +9,7 @@ require (
 	cloud.google.com/go/redis v1.17.3
 	cloud.google.com/go/storage v1.50.0
 	cloud.google.com/go/vision v1.2.0
+	github.com/GoogleCloudPlatform/golang-samples/cdn v0.1.0
 	github.com/bmatcuk/doublestar/v2 v2.0.4
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/golang/protobuf v1.5.4
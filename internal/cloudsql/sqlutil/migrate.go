@@ -0,0 +1,222 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A Migration is a versioned change to a database schema.
+type Migration struct {
+	// Version orders migrations. Versions must be positive and unique.
+	Version int
+	// Description is a short summary used in errors and logs.
+	Description string
+	// Statements are executed in order.
+	Statements []string
+}
+
+// A Migrator applies Migrations and records the applied versions in a table.
+// The zero value is ready to use.
+type Migrator struct {
+	// Table is the name of the table recording applied versions. It defaults
+	// to "schema_migrations".
+	Table string
+	// CreateTable is the statement creating Table if it doesn't exist. It
+	// defaults to a CREATE TABLE IF NOT EXISTS statement, which works with
+	// MySQL, PostgreSQL and SQLite. SQL Server needs its own statement.
+	CreateTable string
+	// Lock, if set, takes a lock shared by all instances of the app, so that
+	// instances starting together don't apply the same migration twice. It
+	// is called with the connection the migrations run on and returns a
+	// function releasing the lock. See MySQLLock, PostgresLock and
+	// SQLServerLock.
+	Lock func(ctx context.Context, conn *sql.Conn) (unlock func() error, err error)
+}
+
+// MySQLLock returns a Migrator.Lock taking the MySQL named lock name. It
+// waits up to a minute for other instances to release it.
+func MySQLLock(name string) func(context.Context, *sql.Conn) (func() error, error) {
+	return func(ctx context.Context, conn *sql.Conn) (func() error, error) {
+		var ok sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", name).Scan(&ok); err != nil {
+			return nil, fmt.Errorf("GET_LOCK: %w", err)
+		}
+		if ok.Int64 != 1 {
+			return nil, fmt.Errorf("GET_LOCK: timed out waiting for lock %q", name)
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+			return err
+		}, nil
+	}
+}
+
+// PostgresLock returns a Migrator.Lock taking the PostgreSQL session-level
+// advisory lock key. It waits until the lock is available or ctx is done.
+func PostgresLock(key int64) func(context.Context, *sql.Conn) (func() error, error) {
+	return func(ctx context.Context, conn *sql.Conn) (func() error, error) {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			return nil, fmt.Errorf("pg_advisory_lock: %w", err)
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+			return err
+		}, nil
+	}
+}
+
+// SQLServerLock returns a Migrator.Lock taking the SQL Server application
+// lock resource for the session. It waits up to a minute for other instances
+// to release it.
+func SQLServerLock(resource string) func(context.Context, *sql.Conn) (func() error, error) {
+	return func(ctx context.Context, conn *sql.Conn) (func() error, error) {
+		var status int
+		err := conn.QueryRowContext(ctx, `DECLARE @status int;
+			EXEC @status = sp_getapplock @Resource = @resource, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 60000;
+			SELECT @status`, sql.Named("resource", resource)).Scan(&status)
+		if err != nil {
+			return nil, fmt.Errorf("sp_getapplock: %w", err)
+		}
+		if status < 0 {
+			return nil, fmt.Errorf("sp_getapplock: unable to lock %q, status %d", resource, status)
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(),
+				"EXEC sp_releaseapplock @Resource = @resource, @LockOwner = 'Session'", sql.Named("resource", resource))
+			return err
+		}, nil
+	}
+}
+
+// Migrate applies migrations to db using a zero Migrator.
+func Migrate(ctx context.Context, db *sql.DB, migrations []Migration) (applied []int, err error) {
+	return Migrator{}.Migrate(ctx, db, migrations)
+}
+
+// Migrate applies the migrations that haven't been applied yet, in version
+// order, and returns the versions it applied. Each migration runs in its own
+// transaction together with the update of the versions table. Note that MySQL
+// commits DDL statements implicitly, so a migration that fails halfway there
+// has to be repaired by hand.
+//
+// Without a Lock, concurrent Migrate calls can both see a migration as
+// pending. The transaction of the second one then fails on the duplicate
+// version, after having run the statements if the database doesn't
+// support transactional DDL.
+func (m Migrator) Migrate(ctx context.Context, db *sql.DB, migrations []Migration) (applied []int, err error) {
+	table := m.Table
+	if table == "" {
+		table = "schema_migrations"
+	}
+	create := m.CreateTable
+	if create == "" {
+		create = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INT NOT NULL PRIMARY KEY)", table)
+	}
+
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, mig := range sorted {
+		if mig.Version <= 0 {
+			return nil, fmt.Errorf("migration %q: version must be positive, got %d", mig.Description, mig.Version)
+		}
+		if i > 0 && sorted[i-1].Version == mig.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", mig.Version)
+		}
+	}
+
+	// The lock, if any, belongs to a connection, so everything runs on one.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("DB.Conn: %w", err)
+	}
+	defer conn.Close()
+	if m.Lock != nil {
+		unlock, err := m.Lock(ctx, conn)
+		if err != nil {
+			return nil, fmt.Errorf("locking migrations: %w", err)
+		}
+		defer func() {
+			if uerr := unlock(); uerr != nil {
+				err = errors.Join(err, fmt.Errorf("unlocking migrations: %w", uerr))
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", table, err)
+	}
+	// Read the applied versions once locked, as another instance may just
+	// have applied some.
+	done, err := appliedVersions(ctx, conn, table)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mig := range sorted {
+		if done[mig.Version] {
+			continue
+		}
+		if err := apply(ctx, conn, table, mig); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", mig.Version, mig.Description, err)
+		}
+		applied = append(applied, mig.Version)
+	}
+	return applied, nil
+}
+
+// appliedVersions returns the versions recorded in table.
+func appliedVersions(ctx context.Context, conn *sql.Conn, table string) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", table))
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query: %w", err)
+	}
+	defer rows.Close()
+	done := make(map[int]bool)
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("Rows.Scan: %w", err)
+		}
+		done[v] = true
+	}
+	return done, rows.Err()
+}
+
+// apply runs mig and records its version in a single transaction.
+func apply(ctx context.Context, conn *sql.Conn, table string, mig Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("DB.Begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range mig.Statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("Tx.Exec: %w", err)
+		}
+	}
+	// The version is an int, so formatting it into the statement is safe and
+	// avoids dialect-specific placeholders.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version) VALUES (%d)", table, mig.Version)); err != nil {
+		return fmt.Errorf("recording version: %w", err)
+	}
+	return tx.Commit()
+}
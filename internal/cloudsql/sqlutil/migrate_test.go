@@ -0,0 +1,129 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	migrations := []Migration{
+		{
+			Version:     2,
+			Description: "add color",
+			Statements:  []string{"ALTER TABLE things ADD COLUMN color TEXT"},
+		},
+		{
+			Version:     1,
+			Description: "create things",
+			Statements:  []string{"CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT)"},
+		},
+	}
+	applied, err := Migrate(ctx, db, migrations)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if fmt.Sprint(applied) != "[1 2]" {
+		t.Errorf("Migrate applied %v, want [1 2]", applied)
+	}
+	if _, err := db.Exec("INSERT INTO things (name, color) VALUES ('sky', 'blue')"); err != nil {
+		t.Errorf("migrated schema is missing columns: %v", err)
+	}
+
+	// Running again is a no-op.
+	applied, err = Migrate(ctx, db, migrations)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second Migrate applied %v, want nothing", applied)
+	}
+
+	// New migrations are applied on top.
+	migrations = append(migrations, Migration{
+		Version:     3,
+		Description: "index names",
+		Statements:  []string{"CREATE INDEX things_name ON things (name)"},
+	})
+	applied, err = Migrate(ctx, db, migrations)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if fmt.Sprint(applied) != "[3]" {
+		t.Errorf("Migrate applied %v, want [3]", applied)
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	migrations := []Migration{
+		{Version: 1, Description: "ok", Statements: []string{"CREATE TABLE a (id INTEGER)"}},
+		{Version: 2, Description: "broken", Statements: []string{
+			"CREATE TABLE b (id INTEGER)",
+			"NOT SQL",
+		}},
+	}
+	applied, err := Migrator{Table: "versions"}.Migrate(ctx, db, migrations)
+	if err == nil {
+		t.Fatalf("Migrate with a broken migration got nil error, want error")
+	}
+	if fmt.Sprint(applied) != "[1]" {
+		t.Errorf("Migrate applied %v, want [1]", applied)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM versions").Scan(&n); err != nil {
+		t.Fatalf("QueryRow: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d recorded versions, want 1", n)
+	}
+	if _, err := db.Exec("SELECT * FROM b"); err == nil {
+		t.Errorf("table from the failed migration exists, want it rolled back")
+	}
+}
+
+func TestMigrateInvalid(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	for _, migrations := range [][]Migration{
+		{{Version: 0}},
+		{{Version: 1}, {Version: 1}},
+	} {
+		if _, err := Migrate(ctx, db, migrations); err == nil {
+			t.Errorf("Migrate(%+v) got nil error, want error", migrations)
+		}
+	}
+}
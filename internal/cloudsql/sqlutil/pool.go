@@ -0,0 +1,104 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlutil contains database/sql helpers shared by the Cloud SQL
+// samples in cloudsql/*/database-sql and getting-started/devflowapp:
+// connection pool configuration and versioned schema migrations.
+package sqlutil
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// PoolConfig holds database/sql connection pool properties.
+// For more information, see https://golang.org/pkg/database/sql
+type PoolConfig struct {
+	// MaxIdleConns is the maximum number of connections in the idle
+	// connection pool.
+	MaxIdleConns int
+	// MaxOpenConns is the maximum number of open connections to the database.
+	MaxOpenConns int
+	// ConnMaxLifetime is the maximum time a connection can remain open.
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is the maximum time a connection can remain idle. Zero
+	// means connections are not closed for being idle.
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultPoolConfig is the pool configuration used by the Cloud SQL samples.
+var DefaultPoolConfig = PoolConfig{
+	MaxIdleConns:    5,
+	MaxOpenConns:    7,
+	ConnMaxLifetime: 1800 * time.Second,
+}
+
+// PoolConfigFromEnv returns DefaultPoolConfig with any of the following
+// environment variables applied:
+//
+//	DB_MAX_IDLE_CONNS       e.g. '5'
+//	DB_MAX_OPEN_CONNS       e.g. '7'
+//	DB_CONN_MAX_LIFETIME    e.g. '30m'
+//	DB_CONN_MAX_IDLE_TIME   e.g. '5m'
+func PoolConfigFromEnv() (PoolConfig, error) {
+	c := DefaultPoolConfig
+	ints := []struct {
+		env string
+		dst *int
+	}{
+		{"DB_MAX_IDLE_CONNS", &c.MaxIdleConns},
+		{"DB_MAX_OPEN_CONNS", &c.MaxOpenConns},
+	}
+	for _, v := range ints {
+		s, ok := os.LookupEnv(v.env)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return PoolConfig{}, fmt.Errorf("%s: invalid connection count %q", v.env, s)
+		}
+		*v.dst = n
+	}
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME", &c.ConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", &c.ConnMaxIdleTime},
+	}
+	for _, v := range durations {
+		s, ok := os.LookupEnv(v.env)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return PoolConfig{}, fmt.Errorf("%s: invalid duration %q", v.env, s)
+		}
+		*v.dst = d
+	}
+	return c, nil
+}
+
+// Apply sets the pool properties on db.
+func (c PoolConfig) Apply(db *sql.DB) {
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
@@ -0,0 +1,58 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolConfigFromEnv(t *testing.T) {
+	c, err := PoolConfigFromEnv()
+	if err != nil {
+		t.Fatalf("PoolConfigFromEnv: %v", err)
+	}
+	if c != DefaultPoolConfig {
+		t.Errorf("PoolConfigFromEnv without env got %+v, want %+v", c, DefaultPoolConfig)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "20")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "90s")
+	c, err = PoolConfigFromEnv()
+	if err != nil {
+		t.Fatalf("PoolConfigFromEnv: %v", err)
+	}
+	want := DefaultPoolConfig
+	want.MaxOpenConns = 20
+	want.ConnMaxIdleTime = 90 * time.Second
+	if c != want {
+		t.Errorf("PoolConfigFromEnv got %+v, want %+v", c, want)
+	}
+}
+
+func TestPoolConfigFromEnvInvalid(t *testing.T) {
+	for env, v := range map[string]string{
+		"DB_MAX_IDLE_CONNS":    "many",
+		"DB_MAX_OPEN_CONNS":    "-1",
+		"DB_CONN_MAX_LIFETIME": "30",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, v)
+			if _, err := PoolConfigFromEnv(); err == nil {
+				t.Errorf("PoolConfigFromEnv with %s=%q got nil error, want error", env, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package voting
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/sqlutil"
+)
+
+// Mode is how to connect to a Cloud SQL instance.
+type Mode int
+
+const (
+	// TCP connects to Host and Port, e.g. of the Cloud SQL Auth Proxy.
+	TCP Mode = iota
+	// Unix connects to the Unix socket UnixSocket, e.g. of Cloud Run.
+	Unix
+	// Connector connects to InstanceConnectionName with the Cloud SQL
+	// Go connector.
+	Connector
+)
+
+func (m Mode) String() string {
+	switch m {
+	case TCP:
+		return "TCP"
+	case Unix:
+		return "Unix socket"
+	case Connector:
+		return "connector"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Config describes how to connect to a Cloud SQL instance.
+type Config struct {
+	Mode Mode
+	// IAMAuthN logs in with IAM database authentication as User, without
+	// a password. It requires Connector.
+	IAMAuthN bool
+
+	User     string
+	Password string
+	// Name is the name of the database.
+	Name string
+
+	// Host and Port are the address of TCP connections.
+	Host string
+	Port string
+	// UnixSocket is the path of the Unix socket, or of its directory for
+	// PostgreSQL.
+	UnixSocket string
+	// InstanceConnectionName is the instance of Connector, as
+	// project:region:instance.
+	InstanceConnectionName string
+	// PrivateIP makes Connector connect to the private IP of the instance.
+	PrivateIP bool
+
+	// RootCert, Cert and Key are the paths of the certificates of TCP
+	// connections encrypted with TLS. TLS is used if RootCert is set.
+	RootCert string
+	Cert     string
+	Key      string
+}
+
+// ConfigFromEnv returns the configuration in environment variables. Setting
+// one of INSTANCE_HOST, INSTANCE_UNIX_SOCKET, or INSTANCE_CONNECTION_NAME
+// selects TCP, Unix or Connector respectively; INSTANCE_HOST wins if
+// several are set. Connector uses IAM database authentication if
+// DB_IAM_USER is set instead of DB_USER and DB_PASS.
+//
+//	DB_USER                   e.g. 'my-db-user'
+//	DB_PASS                   e.g. 'my-db-password'
+//	DB_IAM_USER               e.g. 'sa-name@project-id.iam'
+//	DB_NAME                   e.g. 'my-database'
+//	INSTANCE_HOST, DB_PORT    e.g. '127.0.0.1' and '3306'
+//	INSTANCE_UNIX_SOCKET      e.g. '/cloudsql/project:region:instance'
+//	INSTANCE_CONNECTION_NAME  e.g. 'project:region:instance'
+//	PRIVATE_IP                any value connects to the private IP
+//	DB_ROOT_CERT              e.g. '/path/to/my/server-ca.pem'
+//	DB_CERT                   e.g. '/path/to/my/client-cert.pem'
+//	DB_KEY                    e.g. '/path/to/my/client-key.pem'
+//
+// Note: Saving credentials in environment variables is convenient, but not
+// secure - consider a more secure solution such as
+// Cloud Secret Manager (https://cloud.google.com/secret-manager) to help
+// keep secrets safe.
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		User:                   os.Getenv("DB_USER"),
+		Password:               os.Getenv("DB_PASS"),
+		Name:                   os.Getenv("DB_NAME"),
+		Host:                   os.Getenv("INSTANCE_HOST"),
+		Port:                   os.Getenv("DB_PORT"),
+		UnixSocket:             os.Getenv("INSTANCE_UNIX_SOCKET"),
+		InstanceConnectionName: os.Getenv("INSTANCE_CONNECTION_NAME"),
+		PrivateIP:              os.Getenv("PRIVATE_IP") != "",
+		RootCert:               os.Getenv("DB_ROOT_CERT"),
+		Cert:                   os.Getenv("DB_CERT"),
+		Key:                    os.Getenv("DB_KEY"),
+	}
+	switch {
+	case c.Host != "":
+		c.Mode = TCP
+	case c.UnixSocket != "":
+		c.Mode = Unix
+	case c.InstanceConnectionName != "":
+		c.Mode = Connector
+	default:
+		return Config{}, errors.New("missing database connection type: define one of INSTANCE_HOST, INSTANCE_UNIX_SOCKET, or INSTANCE_CONNECTION_NAME")
+	}
+	if iamUser := os.Getenv("DB_IAM_USER"); c.Mode == Connector && iamUser != "" {
+		c.IAMAuthN = true
+		c.User, c.Password = iamUser, ""
+	}
+	return c, c.validate()
+}
+
+// validate reports missing settings by the environment variable they are
+// read from.
+func (c Config) validate() error {
+	type setting struct{ env, value string }
+	required := []setting{{"DB_NAME", c.Name}}
+	switch {
+	case c.IAMAuthN:
+		required = append(required, setting{"DB_IAM_USER", c.User})
+	case c.Mode == Connector && c.User == "":
+		return errors.New("connecting with connector: one of DB_USER or DB_IAM_USER must be defined")
+	default:
+		required = append(required, setting{"DB_USER", c.User}, setting{"DB_PASS", c.Password})
+	}
+	if c.Mode == TCP {
+		required = append(required, setting{"DB_PORT", c.Port})
+	}
+	for _, s := range required {
+		if s.value == "" {
+			return fmt.Errorf("connecting with %v: %s environment variable not set", c.Mode, s.env)
+		}
+	}
+	return nil
+}
+
+// DialFunc dials a connection to the Cloud SQL instance.
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+// Connect returns a connection pool for c, opened with the driver of d. The
+// pool is configured with sqlutil.PoolConfigFromEnv.
+func Connect(ctx context.Context, d Dialect, c Config) (*sql.DB, error) {
+	if c.IAMAuthN && c.Mode != Connector {
+		return nil, fmt.Errorf("IAM database authentication requires the connector, not %v", c.Mode)
+	}
+	pool, err := sqlutil.PoolConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var db *sql.DB
+	switch c.Mode {
+	case TCP, Unix:
+		dsn, err := d.DSN(c)
+		if err != nil {
+			return nil, err
+		}
+		if db, err = sql.Open(d.DriverName(), dsn); err != nil {
+			return nil, fmt.Errorf("sql.Open: %w", err)
+		}
+	case Connector:
+		// WithLazyRefresh() Option is used to perform refresh
+		// when needed, rather than on a scheduled interval.
+		// This is recommended for serverless environments to
+		// avoid background refreshes from throttling CPU.
+		opts := []cloudsqlconn.Option{cloudsqlconn.WithLazyRefresh()}
+		if c.IAMAuthN {
+			opts = append(opts, cloudsqlconn.WithIAMAuthN())
+		}
+		var dialOpts []cloudsqlconn.DialOption
+		if c.PrivateIP {
+			dialOpts = append(dialOpts, cloudsqlconn.WithPrivateIP())
+		}
+		dialer, err := cloudsqlconn.NewDialer(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("cloudsqlconn.NewDialer: %w", err)
+		}
+		connector, err := d.Connector(c, func(ctx context.Context) (net.Conn, error) {
+			return dialer.Dial(ctx, c.InstanceConnectionName, dialOpts...)
+		})
+		if err != nil {
+			dialer.Close()
+			return nil, err
+		}
+		db = sql.OpenDB(connector)
+	default:
+		return nil, fmt.Errorf("unknown connection mode %v", c.Mode)
+	}
+	pool.Apply(db)
+	return db, nil
+}
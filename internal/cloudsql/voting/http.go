@@ -0,0 +1,234 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package voting
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var indexTmpl = template.Must(template.New("index").Parse(indexHTML))
+
+// DefaultTrustedHops is the number of addresses a Google Cloud external
+// Application Load Balancer appends to X-Forwarded-For: the address of the
+// client and its own.
+const DefaultTrustedHops = 2
+
+// Handler serves the voting app: GET shows the totals and POST saves a vote
+// for the form value "team".
+type Handler struct {
+	Store *Store
+	// TrustedHops is the number of addresses at the end of X-Forwarded-For
+	// appended by the proxies in front of the app, the first of which is
+	// the address of the client. Zero ignores X-Forwarded-For. See
+	// TrustedHopsFromEnv.
+	TrustedHops int
+}
+
+// TrustedHopsFromEnv returns the number of trusted proxy hops set in the
+// TRUSTED_PROXY_HOPS environment variable, or DefaultTrustedHops.
+func TrustedHopsFromEnv() (int, error) {
+	s, ok := os.LookupEnv("TRUSTED_PROXY_HOPS")
+	if !ok {
+		return DefaultTrustedHops, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("TRUSTED_PROXY_HOPS: invalid number of hops %q", s)
+	}
+	return n, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.renderIndex(w, r)
+	case http.MethodPost:
+		h.saveVote(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// renderIndex renders the HTML application with the voting form, current
+// totals, and recent votes.
+func (h *Handler) renderIndex(w http.ResponseWriter, r *http.Request) {
+	t, err := h.Store.CurrentTotals(r.Context())
+	if err != nil {
+		log.Printf("renderIndex: failed to read current totals: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := indexTmpl.Execute(w, t); err != nil {
+		log.Printf("renderIndex: failed to render template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// saveVote saves a vote passed as http.Request form data.
+func (h *Handler) saveVote(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		log.Printf("saveVote: failed to parse form: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	team := r.FormValue("team")
+	if team == "" {
+		log.Printf("saveVote: \"team\" property missing from form submission")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err := h.Store.SaveVote(r.Context(), h.voter(r), team)
+	switch {
+	case errors.Is(err, ErrInvalidCandidate):
+		log.Printf("saveVote: \"team\" property should be \"TABS\" or \"SPACES\", was %q", team)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrRateLimited):
+		w.Header().Set("Retry-After", strconv.Itoa(int(h.Store.rateLimit.Window.Seconds())))
+		http.Error(w, "Too many votes, try again later.", http.StatusTooManyRequests)
+		return
+	case err != nil:
+		log.Printf("saveVote: unable to save vote: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "Vote successfully cast for %s!", team)
+}
+
+// voter identifies the voter of r by IP address. Behind proxies, it is the
+// address the first trusted proxy appended to X-Forwarded-For, counting
+// h.TrustedHops addresses from the right. The earlier addresses come from
+// the client and can't be trusted. IDs are truncated to the 64 characters of
+// the voter column.
+func (h *Handler) voter(r *http.Request) string {
+	id := r.RemoteAddr
+	var fwd []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(v, ",") {
+			fwd = append(fwd, strings.TrimSpace(addr))
+		}
+	}
+	if h.TrustedHops > 0 && len(fwd) > 0 {
+		// Fewer addresses than hops were all appended by trusted proxies.
+		id = fwd[max(len(fwd)-h.TrustedHops, 0)]
+	} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		id = host
+	}
+	if len(id) > 64 {
+		id = id[:64]
+	}
+	return id
+}
+
+var indexHTML = `
+<html lang="en">
+<head>
+    <title>Tabs VS Spaces</title>
+    <link rel="icon" type="image/png" href="data:image/png;base64,iVBORw0KGgo=">
+    <link rel="stylesheet"
+          href="https://cdnjs.cloudflare.com/ajax/libs/materialize/1.0.0/css/materialize.min.css">
+    <link href="https://fonts.googleapis.com/icon?family=Material+Icons" rel="stylesheet">
+    <script src="https://cdnjs.cloudflare.com/ajax/libs/materialize/1.0.0/js/materialize.min.js"></script>
+</head>
+<body>
+<nav class="red lighten-1">
+    <div class="nav-wrapper">
+        <a href="#" class="brand-logo center">Tabs VS Spaces</a>
+    </div>
+</nav>
+<div class="section">
+    <div class="center">
+        <h4>
+            {{ if eq .TabsCount .SpacesCount }}
+                TABS and SPACES are evenly matched!
+            {{ else if gt .TabsCount .SpacesCount }}
+                TABS are winning by {{ .VoteMargin }}
+            {{ else if gt .SpacesCount .TabsCount }}
+                SPACES are winning by {{ .VoteMargin }}
+            {{ end }}
+        </h4>
+    </div>
+    <div class="row center">
+        <div class="col s6 m5 offset-m1">
+            {{ if gt .TabsCount .SpacesCount }}
+			<div class="card-panel green lighten-3">
+			{{ else }}
+			<div class="card-panel">
+			{{ end }}
+                <i class="material-icons large">keyboard_tab</i>
+                <h3>{{ .TabsCount }} votes</h3>
+                <button id="voteTabs" class="btn green">Vote for TABS</button>
+            </div>
+        </div>
+        <div class="col s6 m5">
+            {{ if lt .TabsCount .SpacesCount }}
+			<div class="card-panel blue lighten-3">
+			{{ else }}
+			<div class="card-panel">
+			{{ end }}
+                <i class="material-icons large">space_bar</i>
+                <h3>{{ .SpacesCount }} votes</h3>
+                <button id="voteSpaces" class="btn blue">Vote for SPACES</button>
+            </div>
+        </div>
+    </div>
+    <h4 class="header center">Recent Votes</h4>
+    <ul class="container collection center">
+        {{ range .RecentVotes }}
+            <li class="collection-item avatar">
+                {{ if eq .Candidate "TABS" }}
+                    <i class="material-icons circle green">keyboard_tab</i>
+                {{ else if eq .Candidate "SPACES" }}
+                    <i class="material-icons circle blue">space_bar</i>
+                {{ end }}
+                <span class="title">
+                    A vote for <b>{{.Candidate}}</b> was cast at {{.VoteTime.Format "2006-01-02T15:04:05Z07:00" }}
+                </span>
+            </li>
+        {{ end }}
+    </ul>
+</div>
+<script>
+    function vote(team) {
+        var xhr = new XMLHttpRequest();
+        xhr.onreadystatechange = function () {
+            if (this.readyState == 4) {
+                window.location.reload();
+            }
+        };
+        xhr.open("POST", "/Votes", true);
+        xhr.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+        xhr.send("team=" + team);
+    }
+
+    document.getElementById("voteTabs").addEventListener("click", function () {
+        vote("TABS");
+    });
+    document.getElementById("voteSpaces").addEventListener("click", function () {
+        vote("SPACES");
+    });
+</script>
+</body>
+</html>
+`
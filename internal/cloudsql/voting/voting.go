@@ -0,0 +1,202 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package voting is the "Tabs vs Spaces" app of the Cloud SQL database/sql
+// samples in cloudsql/*/database-sql. The samples differ only in their
+// Dialect, which holds the driver and SQL specific parts.
+package voting
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/sqlutil"
+)
+
+// Candidates are the values a vote can be cast for.
+var Candidates = []string{"TABS", "SPACES"}
+
+var (
+	// ErrInvalidCandidate is returned for votes that aren't for one of the
+	// Candidates.
+	ErrInvalidCandidate = errors.New(`candidate should be "TABS" or "SPACES"`)
+	// ErrRateLimited is returned for votes of voters who voted too often.
+	ErrRateLimited = errors.New("too many votes")
+)
+
+// A Dialect adapts the app to a database and its driver.
+type Dialect interface {
+	// DriverName is the name of the database/sql driver.
+	DriverName() string
+	// DSN returns the data source name of the TCP or Unix socket
+	// connection described by c.
+	DSN(c Config) (string, error)
+	// Connector returns a connector to the database described by c, whose
+	// connections are dialed with dial, the Cloud SQL Go connector.
+	Connector(c Config, dial DialFunc) (driver.Connector, error)
+	// Migrator returns the migrator of the schema.
+	Migrator() sqlutil.Migrator
+	// Migrations returns the migrations creating the votes table:
+	//
+	//	votes (
+	//		id         auto-incremented primary key,
+	//		created_at timestamp,
+	//		candidate  string of up to 6 characters,
+	//		voter      string of up to 64 characters
+	//	)
+	Migrations() []sqlutil.Migration
+	// Placeholder returns the placeholder of the nth (from 1) statement
+	// argument.
+	Placeholder(n int) string
+	// InsertVote saves a vote of voter for candidate cast at createdAt.
+	InsertVote(ctx context.Context, db *sql.DB, candidate, voter string, createdAt time.Time) error
+	// RecentVotes returns a query of the candidate and created_at columns
+	// of the n latest votes, latest first.
+	RecentVotes(n int) string
+}
+
+// RateLimit limits the number of votes of each voter.
+type RateLimit struct {
+	// Votes is the number of votes a voter may cast in Window. Zero means
+	// no limit.
+	Votes  int
+	Window time.Duration
+}
+
+// DefaultRateLimit lets a voter vote 5 times a minute.
+var DefaultRateLimit = RateLimit{Votes: 5, Window: time.Minute}
+
+// A Store saves and counts votes.
+type Store struct {
+	db        *sql.DB
+	dialect   Dialect
+	rateLimit RateLimit
+	now       func() time.Time
+}
+
+// NewStore returns a store of the votes in db.
+func NewStore(db *sql.DB, d Dialect, limit RateLimit) *Store {
+	return &Store{db: db, dialect: d, rateLimit: limit, now: time.Now}
+}
+
+// Migrate creates or updates the votes table.
+func (s *Store) Migrate(ctx context.Context) error {
+	_, err := s.dialect.Migrator().Migrate(ctx, s.db, s.dialect.Migrations())
+	return err
+}
+
+// Vote contains a single row from the votes table in the database.
+type Vote struct {
+	Candidate string
+	VoteTime  time.Time
+}
+
+// SaveVote saves a vote of voter for candidate. It returns
+// ErrRateLimited if voter voted too often recently.
+//
+// The limit is checked before the vote is saved, so concurrent votes of a
+// voter can exceed it slightly.
+func (s *Store) SaveVote(ctx context.Context, voter, candidate string) error {
+	if !validCandidate(candidate) {
+		return ErrInvalidCandidate
+	}
+	now := s.now().UTC()
+	p := s.dialect.Placeholder
+	if s.rateLimit.Votes > 0 {
+		var n int
+		query := fmt.Sprintf("SELECT COUNT(*) FROM votes WHERE voter = %s AND created_at > %s", p(1), p(2))
+		if err := s.db.QueryRowContext(ctx, query, voter, now.Add(-s.rateLimit.Window)).Scan(&n); err != nil {
+			return fmt.Errorf("DB.QueryRow: %w", err)
+		}
+		if n >= s.rateLimit.Votes {
+			return ErrRateLimited
+		}
+	}
+	if err := s.dialect.InsertVote(ctx, s.db, candidate, voter, now); err != nil {
+		return fmt.Errorf("DB.Exec: %w", err)
+	}
+	return nil
+}
+
+func validCandidate(c string) bool {
+	for _, cand := range Candidates {
+		if c == cand {
+			return true
+		}
+	}
+	return false
+}
+
+// recentVotes returns the last five votes cast.
+func (s *Store) recentVotes(ctx context.Context) ([]Vote, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.RecentVotes(5))
+	if err != nil {
+		return nil, fmt.Errorf("DB.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var votes []Vote
+	for rows.Next() {
+		var v Vote
+		if err := rows.Scan(&v.Candidate, &v.VoteTime); err != nil {
+			return nil, fmt.Errorf("Rows.Scan: %w", err)
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+// Totals is the voting data shown by the app.
+type Totals struct {
+	TabsCount   int
+	SpacesCount int
+	VoteMargin  string
+	RecentVotes []Vote
+}
+
+// CurrentTotals retrieves all voting data from the database.
+func (s *Store) CurrentTotals(ctx context.Context) (Totals, error) {
+	var t Totals
+	query := fmt.Sprintf("SELECT COUNT(*) FROM votes WHERE candidate = %s", s.dialect.Placeholder(1))
+	if err := s.db.QueryRowContext(ctx, query, "TABS").Scan(&t.TabsCount); err != nil {
+		return Totals{}, fmt.Errorf("DB.QueryRow: %w", err)
+	}
+	if err := s.db.QueryRowContext(ctx, query, "SPACES").Scan(&t.SpacesCount); err != nil {
+		return Totals{}, fmt.Errorf("DB.QueryRow: %w", err)
+	}
+	recent, err := s.recentVotes(ctx)
+	if err != nil {
+		return Totals{}, fmt.Errorf("recentVotes: %w", err)
+	}
+	t.RecentVotes = recent
+	t.VoteMargin = formatMargin(t.TabsCount, t.SpacesCount)
+	return t, nil
+}
+
+// formatMargin calculates the difference between votes and returns a human
+// friendly margin (e.g., 2 votes)
+func formatMargin(a, b int) string {
+	diff := int(math.Abs(float64(a - b)))
+	margin := fmt.Sprintf("%d votes", diff)
+	// remove pluralization when diff is just one
+	if diff == 1 {
+		margin = "1 vote"
+	}
+	return margin
+}
@@ -0,0 +1,365 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package voting
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/internal/cloudsql/sqlutil"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDialect runs the app on the SQLite database in the file path.
+type sqliteDialect struct{ path string }
+
+func (sqliteDialect) DriverName() string { return "sqlite" }
+
+func (d sqliteDialect) DSN(c Config) (string, error) { return d.path, nil }
+
+func (sqliteDialect) Connector(c Config, dial DialFunc) (driver.Connector, error) {
+	return nil, errors.New("SQLite doesn't support the connector")
+}
+
+func (sqliteDialect) Migrator() sqlutil.Migrator { return sqlutil.Migrator{} }
+
+func (sqliteDialect) Migrations() []sqlutil.Migration {
+	return []sqlutil.Migration{
+		{
+			Version:     1,
+			Description: "create votes",
+			Statements: []string{`CREATE TABLE IF NOT EXISTS votes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				candidate VARCHAR(6) NOT NULL
+			)`},
+		},
+		{
+			Version:     2,
+			Description: "add voter",
+			Statements: []string{
+				"ALTER TABLE votes ADD COLUMN voter VARCHAR(64) NOT NULL DEFAULT ''",
+				"CREATE INDEX votes_voter ON votes (voter, created_at)",
+				"CREATE INDEX votes_created_at ON votes (created_at)",
+			},
+		},
+	}
+}
+
+func (sqliteDialect) Placeholder(n int) string { return "?" }
+
+func (sqliteDialect) RecentVotes(n int) string {
+	return "SELECT candidate, created_at FROM votes ORDER BY created_at DESC LIMIT 5"
+}
+
+func (sqliteDialect) InsertVote(ctx context.Context, db *sql.DB, candidate, voter string, createdAt time.Time) error {
+	_, err := db.ExecContext(ctx, "INSERT INTO votes (candidate, created_at, voter) VALUES (?, ?, ?)", candidate, createdAt, voter)
+	return err
+}
+
+func newTestStore(t *testing.T, limit RateLimit) *Store {
+	t.Helper()
+	d := sqliteDialect{path: filepath.Join(t.TempDir(), "votes.db")}
+	db, err := Connect(context.Background(), d, Config{Mode: TCP})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := NewStore(db, d, limit)
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return s
+}
+
+// clock is a fake time.Now.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, RateLimit{})
+	clk := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s.now = clk.now
+
+	for _, c := range []string{"TABS", "SPACES", "TABS"} {
+		clk.t = clk.t.Add(time.Second)
+		if err := s.SaveVote(ctx, "voter", c); err != nil {
+			t.Fatalf("SaveVote(%s): %v", c, err)
+		}
+	}
+	if err := s.SaveVote(ctx, "voter", "EMACS"); !errors.Is(err, ErrInvalidCandidate) {
+		t.Errorf("SaveVote(EMACS): got %v, want ErrInvalidCandidate", err)
+	}
+
+	got, err := s.CurrentTotals(ctx)
+	if err != nil {
+		t.Fatalf("CurrentTotals: %v", err)
+	}
+	if got.TabsCount != 2 || got.SpacesCount != 1 || got.VoteMargin != "1 vote" {
+		t.Errorf("CurrentTotals: got %+v, want 2 tabs, 1 space, margin 1 vote", got)
+	}
+	if len(got.RecentVotes) != 3 {
+		t.Fatalf("RecentVotes: got %d votes, want 3", len(got.RecentVotes))
+	}
+	if first := got.RecentVotes[0]; first.Candidate != "TABS" || !first.VoteTime.Equal(clk.t) {
+		t.Errorf("latest vote: got %+v, want TABS at %v", first, clk.t)
+	}
+
+	// Migrating again is a no-op.
+	if err := s.Migrate(ctx); err != nil {
+		t.Errorf("second Migrate: %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, RateLimit{Votes: 2, Window: time.Minute})
+	clk := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s.now = clk.now
+
+	vote := func(voter string) error {
+		clk.t = clk.t.Add(10 * time.Second)
+		return s.SaveVote(ctx, voter, "TABS")
+	}
+	for i := 0; i < 2; i++ {
+		if err := vote("alice"); err != nil {
+			t.Fatalf("vote %d: %v", i, err)
+		}
+	}
+	if err := vote("alice"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("third vote within a minute: got %v, want ErrRateLimited", err)
+	}
+	if err := vote("bob"); err != nil {
+		t.Errorf("another voter: %v", err)
+	}
+	// The first vote leaves the window after a minute.
+	clk.t = clk.t.Add(20 * time.Second)
+	if err := vote("alice"); err != nil {
+		t.Errorf("vote after the window: %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := &Handler{Store: newTestStore(t, RateLimit{Votes: 1, Window: time.Hour}), TrustedHops: DefaultTrustedHops}
+
+	post := func(body, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		body, forwardedFor string
+		wantStatus         int
+		wantBody           string
+	}{
+		// The load balancer 203.0.113.1 appends the client address and
+		// its own.
+		{"team=SPACES", "198.51.100.1, 203.0.113.1", http.StatusOK, "Vote successfully cast for SPACES"},
+		// Clients can prepend any address.
+		{"team=TABS", "192.0.2.99, 198.51.100.1, 203.0.113.1", http.StatusTooManyRequests, "Too many votes"},
+		// Other clients behind the same load balancer vote on their own.
+		{"team=TABS", "198.51.100.2, 203.0.113.1", http.StatusOK, "Vote successfully cast for TABS"},
+		{"team=TABS", "", http.StatusOK, "Vote successfully cast for TABS"},
+		{"team=VIM", "", http.StatusBadRequest, ""},
+		{"", "", http.StatusBadRequest, ""},
+	}
+	for _, tc := range tests {
+		rr := post(tc.body, tc.forwardedFor)
+		if rr.Code != tc.wantStatus {
+			t.Errorf("POST %q from %q: got status %d, want %d", tc.body, tc.forwardedFor, rr.Code, tc.wantStatus)
+		}
+		if !strings.Contains(rr.Body.String(), tc.wantBody) {
+			t.Errorf("POST %q from %q: got %q, want %q", tc.body, tc.forwardedFor, rr.Body, tc.wantBody)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET: got status %d", rr.Code)
+	}
+	for _, want := range []string{"Tabs VS Spaces", "TABS are winning by 1 vote", "A vote for <b>SPACES</b>"} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("GET: body doesn't contain %q", want)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("DELETE", "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: got status %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	vars := []string{
+		"INSTANCE_HOST", "INSTANCE_UNIX_SOCKET", "INSTANCE_CONNECTION_NAME", "DB_PORT",
+		"DB_USER", "DB_PASS", "DB_IAM_USER", "DB_NAME", "PRIVATE_IP", "DB_ROOT_CERT", "DB_CERT", "DB_KEY",
+	}
+	tests := []struct {
+		desc    string
+		env     map[string]string
+		want    Config
+		wantErr string
+	}{
+		{
+			desc: "tcp",
+			env:  map[string]string{"INSTANCE_HOST": "127.0.0.1", "DB_PORT": "3306", "DB_USER": "u", "DB_PASS": "p", "DB_NAME": "d"},
+			want: Config{Mode: TCP, User: "u", Password: "p", Name: "d", Host: "127.0.0.1", Port: "3306"},
+		},
+		{
+			desc:    "tcp without port",
+			env:     map[string]string{"INSTANCE_HOST": "127.0.0.1", "DB_USER": "u", "DB_PASS": "p", "DB_NAME": "d"},
+			wantErr: "DB_PORT",
+		},
+		{
+			desc: "unix",
+			env:  map[string]string{"INSTANCE_UNIX_SOCKET": "/cloudsql/p:r:i", "DB_USER": "u", "DB_PASS": "p", "DB_NAME": "d"},
+			want: Config{Mode: Unix, User: "u", Password: "p", Name: "d", UnixSocket: "/cloudsql/p:r:i"},
+		},
+		{
+			desc: "connector with IAM",
+			env:  map[string]string{"INSTANCE_CONNECTION_NAME": "p:r:i", "DB_IAM_USER": "sa@p.iam", "DB_NAME": "d", "PRIVATE_IP": "1"},
+			want: Config{Mode: Connector, IAMAuthN: true, User: "sa@p.iam", Name: "d", InstanceConnectionName: "p:r:i", PrivateIP: true},
+		},
+		{
+			desc:    "unix without password",
+			env:     map[string]string{"INSTANCE_UNIX_SOCKET": "/cloudsql/p:r:i", "DB_USER": "u", "DB_NAME": "d"},
+			wantErr: "DB_PASS",
+		},
+		{
+			desc:    "connector without user",
+			env:     map[string]string{"INSTANCE_CONNECTION_NAME": "p:r:i", "DB_NAME": "d"},
+			wantErr: "DB_IAM_USER",
+		},
+		{
+			desc:    "nothing",
+			env:     map[string]string{},
+			wantErr: "missing database connection type",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, v := range vars {
+				t.Setenv(v, tc.env[v])
+			}
+			got, err := ConfigFromEnv()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("ConfigFromEnv: got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConfigFromEnv: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ConfigFromEnv:\ngot  %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConnect(t *testing.T) {
+	ctx := context.Background()
+	if _, err := Connect(ctx, sqliteDialect{}, Config{Mode: TCP, IAMAuthN: true}); err == nil {
+		t.Error("Connect with IAM over TCP succeeded, want error")
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "3")
+	db, err := Connect(ctx, sqliteDialect{path: filepath.Join(t.TempDir(), "votes.db")}, Config{Mode: TCP})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer db.Close()
+	if got := db.Stats().MaxOpenConnections; got != 3 {
+		t.Errorf("MaxOpenConnections = %d, want 3", got)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+	if _, err := Connect(ctx, sqliteDialect{}, Config{Mode: TCP}); err == nil {
+		t.Error("Connect with an invalid pool configuration succeeded, want error")
+	}
+}
+
+func TestVoter(t *testing.T) {
+	tests := []struct {
+		hops         int
+		forwardedFor []string
+		want         string
+	}{
+		{2, nil, "192.0.2.7"},
+		{0, []string{"198.51.100.1, 203.0.113.1"}, "192.0.2.7"},
+		{2, []string{"198.51.100.1, 203.0.113.1"}, "198.51.100.1"},
+		{2, []string{"spoofed, 198.51.100.1, 203.0.113.1"}, "198.51.100.1"},
+		{2, []string{"spoofed", "198.51.100.1, 203.0.113.1"}, "198.51.100.1"},
+		{1, []string{"spoofed, 198.51.100.1"}, "198.51.100.1"},
+		{3, []string{"198.51.100.1, 203.0.113.1"}, "198.51.100.1"},
+		{1, []string{"10.0.0.1, " + strings.Repeat("x", 100)}, strings.Repeat("x", 64)},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.7:1234"
+		for _, v := range tc.forwardedFor {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		h := &Handler{TrustedHops: tc.hops}
+		if got := h.voter(req); got != tc.want {
+			t.Errorf("voter with %d hops and X-Forwarded-For %q: got %q, want %q", tc.hops, tc.forwardedFor, got, tc.want)
+		}
+	}
+}
+
+func TestTrustedHopsFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		env     string
+		set     bool
+		want    int
+		wantErr bool
+	}{
+		{want: DefaultTrustedHops},
+		{env: "1", set: true, want: 1},
+		{env: "0", set: true, want: 0},
+		{env: "-1", set: true, wantErr: true},
+		{env: "two", set: true, wantErr: true},
+	} {
+		if tc.set {
+			t.Setenv("TRUSTED_PROXY_HOPS", tc.env)
+		} else {
+			os.Unsetenv("TRUSTED_PROXY_HOPS")
+		}
+		got, err := TrustedHopsFromEnv()
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("TrustedHopsFromEnv with %q = %d, %v, want %d, error %v", tc.env, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
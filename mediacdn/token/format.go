@@ -0,0 +1,93 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package token
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Param is the query parameter and path component name carrying a token.
+const Param = "edge-cache-token"
+
+// AddToQuery returns rawURL with token in the edge-cache-token query
+// parameter, e.g. https://media.example.com/videos/main.m3u8?edge-cache-token=....
+func AddToQuery(rawURL, token string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %w", err)
+	}
+	q := u.Query()
+	q.Set(Param, token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// AddToPath returns rawURL with token as its first path component, e.g.
+// https://media.example.com/edge-cache-token=.../videos/main.m3u8. Relative
+// URLs in a manifest fetched this way, such as segment URLs, resolve under
+// the same component, so they carry the token without being rewritten. The
+// token is path-escaped, so slashes in path globs don't split it.
+func AddToPath(rawURL, token string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %w", err)
+	}
+	if strings.Contains(u.EscapedPath(), "/"+Param+"=") {
+		return "", fmt.Errorf("%q already has a token path component", rawURL)
+	}
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u.RawPath = "/" + Param + "=" + url.PathEscape(token) + path
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return "", fmt.Errorf("url.PathUnescape: %w", err)
+	}
+	return u.String(), nil
+}
+
+// Extract returns the token carried by u, in the query or in a path
+// component, and a copy of u without it. It returns an empty token if u has
+// none.
+func Extract(u *url.URL) (token string, stripped *url.URL) {
+	c := *u
+	if q := c.Query(); q.Has(Param) {
+		token = q.Get(Param)
+		q.Del(Param)
+		c.RawQuery = q.Encode()
+		return token, &c
+	}
+	prefix := Param + "="
+	segments := strings.Split(c.EscapedPath(), "/")
+	for i, s := range segments {
+		if !strings.HasPrefix(s, prefix) {
+			continue
+		}
+		tok, err := url.PathUnescape(strings.TrimPrefix(s, prefix))
+		if err != nil {
+			return "", &c
+		}
+		rest := strings.Join(append(segments[:i:i], segments[i+1:]...), "/")
+		path, err := url.PathUnescape(rest)
+		if err != nil {
+			return "", &c
+		}
+		c.Path, c.RawPath = path, rest
+		return tok, &c
+	}
+	return "", &c
+}
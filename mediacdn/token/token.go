@@ -0,0 +1,265 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package token creates and verifies Media CDN dual-token authentication
+// tokens (Edge-Cache-Token).
+//
+// Unlike the signed URLs and cookies in the parent directory, which only carry
+// an expiration time and a key name, tokens can also be scoped to a start
+// time, client IP ranges, request header values, a session ID, opaque data,
+// and a URL prefix, full path or path globs. They are signed with Ed25519 or
+// HMAC-SHA256, and can be carried in a query parameter or a path component.
+//
+// See https://cloud.google.com/media-cdn/docs/use-dual-token-authentication.
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/cdn/signing"
+)
+
+// Algorithm is a token signature algorithm.
+type Algorithm int
+
+const (
+	// Ed25519 tokens end with a Signature field.
+	Ed25519 Algorithm = iota + 1
+	// HMACSHA256 tokens end with an hmac field.
+	HMACSHA256
+)
+
+// String returns the name of a.
+func (a Algorithm) String() string {
+	switch a {
+	case Ed25519:
+		return "ed25519"
+	case HMACSHA256:
+		return "hmac-sha256"
+	}
+	return fmt.Sprintf("Algorithm(%d)", int(a))
+}
+
+// A Key signs or verifies tokens. Ed25519 keys are the same as the Media CDN
+// keys of cdn/signing; see FromSigningKey.
+type Key struct {
+	Algorithm Algorithm
+
+	// Secret is the HMAC key, or the Ed25519 seed or private key. Ed25519
+	// keys that only verify can leave it empty and set PublicKey.
+	Secret []byte
+
+	// PublicKey is the Ed25519 public key. It is derived from Secret if empty.
+	PublicKey ed25519.PublicKey
+}
+
+// FromSigningKey returns the token key for an Ed25519 key of cdn/signing,
+// e.g. one read with signing.ReadKeyFile. Dual tokens don't carry key
+// names, so the name of k is not used.
+func FromSigningKey(k signing.Key) (Key, error) {
+	if k.Algorithm != signing.Ed25519 {
+		return Key{}, fmt.Errorf("key %q: dual tokens can't be signed with %v keys", k.Name, k.Algorithm)
+	}
+	return Key{Algorithm: Ed25519, Secret: k.Secret, PublicKey: k.PublicKey}.normalize()
+}
+
+// normalize validates k and fills in the Ed25519 keys.
+func (k Key) normalize() (Key, error) {
+	switch k.Algorithm {
+	case HMACSHA256:
+		if len(k.Secret) == 0 {
+			return Key{}, errors.New("empty HMAC key")
+		}
+	case Ed25519:
+		priv, pub, err := signing.Ed25519Key(k.Secret, k.PublicKey)
+		if err != nil {
+			return Key{}, err
+		}
+		k.Secret, k.PublicKey = priv, pub
+	default:
+		return Key{}, fmt.Errorf("unknown algorithm %v", k.Algorithm)
+	}
+	return k, nil
+}
+
+// A Header binds a token to the value of a request header.
+type Header struct {
+	Name, Value string
+}
+
+// A Token holds the claims of a dual-token. Exactly one of URLPrefix,
+// FullPath and PathGlobs must be set, and Expires is required.
+type Token struct {
+	// URLPrefix grants access to URLs starting with it, e.g.
+	// "https://media.example.com/videos/".
+	URLPrefix string
+	// FullPath grants access to exactly this path, e.g.
+	// "/videos/main.m3u8". The token only records that it is a full path
+	// token: the path is part of the signature, not of the token.
+	FullPath string
+	// PathGlobs grant access to paths matching any of the globs, e.g.
+	// "/videos/*". In a glob, * matches any sequence of characters.
+	PathGlobs []string
+
+	// Starts is the time before which the token is not valid yet. Optional.
+	Starts time.Time
+	// Expires is the time from which the token is no longer valid.
+	Expires time.Time
+
+	// SessionID identifies the viewer session, e.g. for logging. Optional.
+	SessionID string
+	// Data is opaque data passed along with the token. Optional.
+	Data string
+	// Headers bind the token to request header values. Only the names are
+	// carried in the token; the values are part of the signature.
+	Headers []Header
+	// IPRanges restricts the client IP to these CIDR ranges, e.g.
+	// "203.0.113.0/24". Optional.
+	IPRanges []string
+}
+
+// reserved are characters that can't appear in free-form fields because they
+// separate fields or values.
+const reserved = "~,="
+
+// validate checks that t can be signed.
+func (t *Token) validate() error {
+	scopes := 0
+	if t.URLPrefix != "" {
+		scopes++
+	}
+	if t.FullPath != "" {
+		scopes++
+	}
+	if len(t.PathGlobs) > 0 {
+		scopes++
+	}
+	if scopes != 1 {
+		return errors.New("exactly one of URLPrefix, FullPath and PathGlobs must be set")
+	}
+	if len(t.PathGlobs) > 5 {
+		return fmt.Errorf("at most 5 path globs are allowed, got %d", len(t.PathGlobs))
+	}
+	for _, g := range t.PathGlobs {
+		if !strings.HasPrefix(g, "/") || strings.ContainsAny(g, reserved) {
+			return fmt.Errorf("invalid path glob %q", g)
+		}
+	}
+	if t.Expires.IsZero() {
+		return errors.New("Expires must be set")
+	}
+	if !t.Starts.IsZero() && !t.Starts.Before(t.Expires) {
+		return errors.New("Starts must be before Expires")
+	}
+	for name, v := range map[string]string{"SessionID": t.SessionID, "Data": t.Data} {
+		if strings.ContainsAny(v, reserved) {
+			return fmt.Errorf("%s must not contain any of %q", name, reserved)
+		}
+	}
+	for _, h := range t.Headers {
+		if h.Name == "" || strings.ContainsAny(h.Name, reserved) || strings.ContainsAny(h.Value, "~,") {
+			return fmt.Errorf("invalid header %q", h.Name)
+		}
+	}
+	for _, r := range t.IPRanges {
+		if _, err := netip.ParsePrefix(r); err != nil {
+			return fmt.Errorf("invalid IP range: %w", err)
+		}
+	}
+	return nil
+}
+
+// fields returns the fields of the token and the fields that are signed. They
+// differ for FullPath and Headers, whose values are only signed.
+func (t *Token) fields() (fields, signed []string) {
+	add := func(field, signedField string) {
+		fields = append(fields, field)
+		signed = append(signed, signedField)
+	}
+	switch {
+	case t.URLPrefix != "":
+		f := "URLPrefix=" + base64.RawURLEncoding.EncodeToString([]byte(t.URLPrefix))
+		add(f, f)
+	case t.FullPath != "":
+		add("FullPath", "FullPath="+t.FullPath)
+	default:
+		f := "PathGlobs=" + strings.Join(t.PathGlobs, ",")
+		add(f, f)
+	}
+	if !t.Starts.IsZero() {
+		f := "Starts=" + strconv.FormatInt(t.Starts.Unix(), 10)
+		add(f, f)
+	}
+	f := "Expires=" + strconv.FormatInt(t.Expires.Unix(), 10)
+	add(f, f)
+	if t.SessionID != "" {
+		f := "SessionID=" + t.SessionID
+		add(f, f)
+	}
+	if t.Data != "" {
+		f := "Data=" + t.Data
+		add(f, f)
+	}
+	if len(t.Headers) > 0 {
+		names := make([]string, len(t.Headers))
+		pairs := make([]string, len(t.Headers))
+		for i, h := range t.Headers {
+			names[i] = h.Name
+			pairs[i] = h.Name + "=" + h.Value
+		}
+		add("Headers="+strings.Join(names, ","), "Headers="+strings.Join(pairs, ","))
+	}
+	if len(t.IPRanges) > 0 {
+		f := "IPRanges=" + base64.RawURLEncoding.EncodeToString([]byte(strings.Join(t.IPRanges, ",")))
+		add(f, f)
+	}
+	return fields, signed
+}
+
+// Sign returns the token string for t signed with key.
+func (t *Token) Sign(key Key) (string, error) {
+	key, err := key.normalize()
+	if err != nil {
+		return "", err
+	}
+	if err := t.validate(); err != nil {
+		return "", err
+	}
+	fields, signed := t.fields()
+	toSign := []byte(strings.Join(signed, "~"))
+
+	switch key.Algorithm {
+	case Ed25519:
+		if len(key.Secret) == 0 {
+			return "", errors.New("Ed25519 key has no private key to sign with")
+		}
+		sig := ed25519.Sign(ed25519.PrivateKey(key.Secret), toSign)
+		fields = append(fields, "Signature="+hex.EncodeToString(sig))
+	case HMACSHA256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(toSign)
+		fields = append(fields, "hmac="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return strings.Join(fields, "~"), nil
+}
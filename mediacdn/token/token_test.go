@@ -0,0 +1,175 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/cdn/signing"
+)
+
+var (
+	// Same key as privateTestKey in the parent directory's tests.
+	ed25519TestKey = Key{Algorithm: Ed25519, Secret: []byte{
+		0x9d, 0x61, 0xb1, 0x9d, 0xef, 0xfd, 0x5a, 0x60, 0xba, 0x84, 0x4a, 0xf4, 0x92, 0xec, 0x2c, 0xc4,
+		0x44, 0x49, 0xc5, 0x69, 0x7b, 0x32, 0x69, 0x19, 0x70, 0x3b, 0xac, 0x03, 0x1c, 0xae, 0x7f, 0x60,
+	}}
+	hmacTestKey = Key{Algorithm: HMACSHA256, Secret: []byte("test-secret-please-rotate")}
+
+	testExpires = time.Unix(1900000000, 0)
+)
+
+func TestSignFields(t *testing.T) {
+	tok := Token{
+		PathGlobs: []string{"/videos/*", "/thumbs/*.jpg"},
+		Starts:    time.Unix(1800000000, 0),
+		Expires:   testExpires,
+		SessionID: "session-1",
+		Data:      "tier:gold",
+		Headers:   []Header{{"User-Agent", "player/1.0"}},
+		IPRanges:  []string{"203.0.113.0/24", "2001:db8::/32"},
+	}
+	got, err := tok.Sign(hmacTestKey)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	want := "PathGlobs=/videos/*,/thumbs/*.jpg~Starts=1800000000~Expires=1900000000~SessionID=session-1~Data=tier:gold~Headers=User-Agent~IPRanges=MjAzLjAuMTEzLjAvMjQsMjAwMTpkYjg6Oi8zMg"
+	unsigned, sig, _ := strings.Cut(got, "~hmac=")
+	if unsigned != want {
+		t.Errorf("Sign got fields\n%s\nwant\n%s", unsigned, want)
+	}
+
+	// Header values are signed, but not part of the token.
+	signed := strings.Replace(want, "Headers=User-Agent", "Headers=User-Agent=player/1.0", 1)
+	mac := hmac.New(sha256.New, hmacTestKey.Secret)
+	mac.Write([]byte(signed))
+	if wantSig := hex.EncodeToString(mac.Sum(nil)); sig != wantSig {
+		t.Errorf("Sign got signature %s, want %s", sig, wantSig)
+	}
+}
+
+func TestSignScopes(t *testing.T) {
+	for _, tc := range []struct {
+		tok  Token
+		want string
+	}{
+		{
+			tok:  Token{URLPrefix: "https://media.example.com/videos/", Expires: testExpires},
+			want: "URLPrefix=aHR0cHM6Ly9tZWRpYS5leGFtcGxlLmNvbS92aWRlb3Mv~Expires=1900000000~Signature=",
+		},
+		{
+			tok:  Token{FullPath: "/videos/main.m3u8", Expires: testExpires},
+			want: "FullPath~Expires=1900000000~Signature=",
+		},
+	} {
+		got, err := tc.tok.Sign(ed25519TestKey)
+		if err != nil {
+			t.Errorf("Sign(%+v): %v", tc.tok, err)
+			continue
+		}
+		if !strings.HasPrefix(got, tc.want) {
+			t.Errorf("Sign(%+v) = %q, want prefix %q", tc.tok, got, tc.want)
+		}
+	}
+}
+
+func TestSignInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		tok Token
+		key Key
+	}{
+		"no scope":       {Token{Expires: testExpires}, hmacTestKey},
+		"two scopes":     {Token{FullPath: "/a", PathGlobs: []string{"/b"}, Expires: testExpires}, hmacTestKey},
+		"no expiry":      {Token{FullPath: "/a"}, hmacTestKey},
+		"starts late":    {Token{FullPath: "/a", Starts: testExpires, Expires: testExpires}, hmacTestKey},
+		"relative glob":  {Token{PathGlobs: []string{"videos/*"}, Expires: testExpires}, hmacTestKey},
+		"too many globs": {Token{PathGlobs: []string{"/1", "/2", "/3", "/4", "/5", "/6"}, Expires: testExpires}, hmacTestKey},
+		"tilde in data":  {Token{FullPath: "/a", Data: "a~b", Expires: testExpires}, hmacTestKey},
+		"bad IP range":   {Token{FullPath: "/a", IPRanges: []string{"203.0.113.0"}, Expires: testExpires}, hmacTestKey},
+		"bad key":        {Token{FullPath: "/a", Expires: testExpires}, Key{Algorithm: Ed25519, Secret: []byte("short")}},
+		"public key":     {Token{FullPath: "/a", Expires: testExpires}, Key{Algorithm: Ed25519, PublicKey: make([]byte, 32)}},
+	} {
+		if got, err := tc.tok.Sign(tc.key); err == nil {
+			t.Errorf("%s: Sign got %q, want error", name, got)
+		}
+	}
+}
+
+func TestFromSigningKey(t *testing.T) {
+	sk := signing.Key{Name: "media-key", Algorithm: signing.Ed25519, Secret: ed25519TestKey.Secret}
+	key, err := FromSigningKey(sk)
+	if err != nil {
+		t.Fatalf("FromSigningKey: %v", err)
+	}
+	tok := Token{FullPath: "/a", Expires: testExpires}
+	got, err := tok.Sign(key)
+	if err != nil {
+		t.Fatalf("Sign with signing key: %v", err)
+	}
+	want, err := tok.Sign(ed25519TestKey)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if got != want {
+		t.Errorf("Sign with signing key = %q, want %q", got, want)
+	}
+
+	if _, err := FromSigningKey(signing.Key{Name: "cdn-key", Algorithm: signing.HMACSHA1, Secret: []byte("secret")}); err == nil {
+		t.Errorf("FromSigningKey of an HMAC-SHA1 key got nil error, want error")
+	}
+}
+
+func TestFormats(t *testing.T) {
+	const tok = "PathGlobs=/videos/*~Expires=1900000000~hmac=00"
+	for _, tc := range []struct {
+		add  func(string, string) (string, error)
+		want string
+	}{
+		{AddToQuery, "https://media.example.com/videos/main.m3u8?edge-cache-token=PathGlobs%3D%2Fvideos%2F%2A~Expires%3D1900000000~hmac%3D00"},
+		{AddToPath, "https://media.example.com/edge-cache-token=PathGlobs=%2Fvideos%2F%2A~Expires=1900000000~hmac=00/videos/main.m3u8"},
+	} {
+		got, err := tc.add("https://media.example.com/videos/main.m3u8", tok)
+		if err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("got %s, want %s", got, tc.want)
+		}
+		u, err := url.Parse(got)
+		if err != nil {
+			t.Fatalf("url.Parse: %v", err)
+		}
+		extracted, stripped := Extract(u)
+		if extracted != tok {
+			t.Errorf("Extract(%s) got token %q, want %q", got, extracted, tok)
+		}
+		if s := stripped.String(); s != "https://media.example.com/videos/main.m3u8" {
+			t.Errorf("Extract(%s) got URL %s, want the original URL", got, s)
+		}
+	}
+
+	// Relative URLs in a manifest keep the path component.
+	u, _ := url.Parse("https://media.example.com/edge-cache-token=" + url.PathEscape(tok) + "/videos/main.m3u8")
+	seg := u.ResolveReference(&url.URL{Path: "seg-1.ts"})
+	if got, _ := Extract(seg); got != tok {
+		t.Errorf("Extract(%s) got %q, want %q", seg, got, tok)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by Verifier. Errors are wrapped with details, use
+// errors.Is to check for them.
+var (
+	ErrMissingToken     = errors.New("missing token")
+	ErrMalformed        = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrNotYetValid      = errors.New("token not yet valid")
+	ErrExpired          = errors.New("token expired")
+	ErrOutOfScope       = errors.New("URL not covered by token")
+	ErrIPNotAllowed     = errors.New("client IP not allowed by token")
+)
+
+// A Request holds what a token is checked against.
+type Request struct {
+	// URL is the requested URL, without the token.
+	URL *url.URL
+	// ClientIP is the address of the client. It is only checked if the token
+	// has IPRanges.
+	ClientIP netip.Addr
+	// Header holds the request headers bound by the token.
+	Header http.Header
+}
+
+// A Verifier checks tokens the way Media CDN does.
+type Verifier struct {
+	// Keys are tried in order until one verifies the signature.
+	Keys []Key
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// VerifyHTTP extracts the token from r, in the query or a path component,
+// and verifies it against r, using r.RemoteAddr as the client IP.
+func (v *Verifier) VerifyHTTP(r *http.Request) (*Token, error) {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	tok, stripped := Extract(&u)
+	if tok == "" {
+		return nil, ErrMissingToken
+	}
+	req := Request{URL: stripped, Header: r.Header}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		req.ClientIP = ip.Unmap()
+	}
+	return v.Verify(tok, req)
+}
+
+// Verify parses token, checks its signature and checks that it grants access
+// to req. It returns the claims of the token, with Header values and
+// FullPath taken from req.
+func (v *Verifier) Verify(token string, req Request) (*Token, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	t, signed, sig, err := parse(token, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.verifySignature(signed, sig); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	if !t.Starts.IsZero() && now.Before(t.Starts) {
+		return nil, fmt.Errorf("%w: starts at %v", ErrNotYetValid, t.Starts)
+	}
+	if !now.Before(t.Expires) {
+		return nil, fmt.Errorf("%w at %v", ErrExpired, t.Expires)
+	}
+	if !inScope(t, req.URL) {
+		return nil, fmt.Errorf("%w: %s", ErrOutOfScope, req.URL)
+	}
+	if len(t.IPRanges) > 0 && !ipAllowed(t.IPRanges, req.ClientIP) {
+		return nil, fmt.Errorf("%w: %v", ErrIPNotAllowed, req.ClientIP)
+	}
+	return t, nil
+}
+
+// sigField is the signature field of a parsed token.
+type sigField struct {
+	alg   Algorithm
+	value []byte
+}
+
+// parse splits token into its claims, the string that was signed, and the
+// signature. The values that are only signed, the full path and header values,
+// are taken from req.
+func parse(token string, req Request) (t *Token, signed string, sig sigField, err error) {
+	malformed := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrMalformed, fmt.Sprintf(format, args...))
+	}
+	t = &Token{}
+	var signedFields []string
+	fields := strings.Split(token, "~")
+	for i, f := range fields {
+		name, value, _ := strings.Cut(f, "=")
+		last := i == len(fields)-1
+		if (name == "Signature" || name == "hmac") != last {
+			return nil, "", sig, malformed("token must end with one signature field")
+		}
+		switch name {
+		case "URLPrefix":
+			b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err != nil {
+				return nil, "", sig, malformed("URLPrefix: %v", err)
+			}
+			t.URLPrefix = string(b)
+		case "FullPath":
+			if req.URL == nil {
+				return nil, "", sig, malformed("FullPath token needs a request URL")
+			}
+			t.FullPath = req.URL.Path
+			f = "FullPath=" + t.FullPath
+		case "PathGlobs":
+			t.PathGlobs = strings.Split(value, ",")
+		case "Starts", "Expires":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, "", sig, malformed("%s: %v", name, err)
+			}
+			if name == "Starts" {
+				t.Starts = time.Unix(n, 0)
+			} else {
+				t.Expires = time.Unix(n, 0)
+			}
+		case "SessionID":
+			t.SessionID = value
+		case "Data":
+			t.Data = value
+		case "Headers":
+			var pairs []string
+			for _, name := range strings.Split(value, ",") {
+				h := Header{Name: name, Value: strings.Join(req.Header.Values(name), ",")}
+				t.Headers = append(t.Headers, h)
+				pairs = append(pairs, h.Name+"="+h.Value)
+			}
+			f = "Headers=" + strings.Join(pairs, ",")
+		case "IPRanges":
+			b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err != nil {
+				return nil, "", sig, malformed("IPRanges: %v", err)
+			}
+			t.IPRanges = strings.Split(string(b), ",")
+		case "Signature", "hmac":
+			b, err := hex.DecodeString(value)
+			if err != nil {
+				return nil, "", sig, malformed("%s: %v", name, err)
+			}
+			sig = sigField{alg: HMACSHA256, value: b}
+			if name == "Signature" {
+				sig.alg = Ed25519
+			}
+			continue
+		default:
+			return nil, "", sig, malformed("unknown field %q", name)
+		}
+		signedFields = append(signedFields, f)
+	}
+	if t.Expires.IsZero() {
+		return nil, "", sig, malformed("no Expires field")
+	}
+	return t, strings.Join(signedFields, "~"), sig, nil
+}
+
+// verifySignature checks sig over signed with the keys of v.
+func (v *Verifier) verifySignature(signed string, sig sigField) error {
+	for _, k := range v.Keys {
+		if k.Algorithm != sig.alg {
+			continue
+		}
+		k, err := k.normalize()
+		if err != nil {
+			return err
+		}
+		switch k.Algorithm {
+		case Ed25519:
+			if ed25519.Verify(k.PublicKey, []byte(signed), sig.value) {
+				return nil
+			}
+		case HMACSHA256:
+			mac := hmac.New(sha256.New, k.Secret)
+			mac.Write([]byte(signed))
+			if hmac.Equal(mac.Sum(nil), sig.value) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// inScope reports whether the URL prefix, full path or path globs of t cover u.
+func inScope(t *Token, u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	switch {
+	case t.URLPrefix != "":
+		return strings.HasPrefix(u.String(), t.URLPrefix)
+	case t.FullPath != "":
+		// The path is part of the signature, which was already checked.
+		return true
+	}
+	for _, g := range t.PathGlobs {
+		if matchGlob(g, u.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether path matches glob, where * matches any sequence of
+// characters, including slashes.
+func matchGlob(glob, path string) bool {
+	parts := strings.Split(glob, "*")
+	if len(parts) == 1 {
+		return glob == path
+	}
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, p)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(p):]
+	}
+	return strings.HasSuffix(path, last)
+}
+
+// ipAllowed reports whether ip is in any of ranges.
+func ipAllowed(ranges []string, ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	for _, r := range ranges {
+		p, err := netip.ParsePrefix(r)
+		if err == nil && p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
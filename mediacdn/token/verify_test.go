@@ -0,0 +1,175 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package token
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testVerifier(now time.Time) *Verifier {
+	return &Verifier{
+		Keys: []Key{hmacTestKey, ed25519TestKey},
+		Now:  func() time.Time { return now },
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tok := Token{
+		PathGlobs: []string{"/videos/*"},
+		Starts:    time.Unix(1800000000, 0),
+		Expires:   testExpires,
+		SessionID: "session-1",
+		Headers:   []Header{{"User-Agent", "player/1.0"}},
+		IPRanges:  []string{"203.0.113.0/24"},
+	}
+	v := testVerifier(time.Unix(1850000000, 0))
+	for _, key := range []Key{hmacTestKey, ed25519TestKey} {
+		s, err := tok.Sign(key)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		for _, add := range []func(string, string) (string, error){AddToQuery, AddToPath} {
+			u, err := add("https://media.example.com/videos/hd/main.m3u8", s)
+			if err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			r := httptest.NewRequest("GET", u, nil)
+			r.RemoteAddr = "203.0.113.7:4321"
+			r.Header.Set("User-Agent", "player/1.0")
+			got, err := v.VerifyHTTP(r)
+			if err != nil {
+				t.Errorf("%v: VerifyHTTP(%s): %v", key.Algorithm, u, err)
+				continue
+			}
+			if got.SessionID != "session-1" || !got.Expires.Equal(testExpires) || got.Headers[0] != tok.Headers[0] {
+				t.Errorf("%v: VerifyHTTP got %+v, want %+v", key.Algorithm, got, tok)
+			}
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	sign := func(tok Token) string {
+		t.Helper()
+		if tok.Expires.IsZero() {
+			tok.Expires = testExpires
+		}
+		s, err := tok.Sign(ed25519TestKey)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return s
+	}
+	now := time.Unix(1850000000, 0)
+	for name, tc := range map[string]struct {
+		token  string
+		target string
+		ip     string
+		ua     string
+		now    time.Time
+		want   error
+	}{
+		"valid": {
+			token: sign(Token{FullPath: "/a.ts"}),
+		},
+		"missing": {
+			want: ErrMissingToken,
+		},
+		"garbage": {
+			token: "hello", want: ErrMalformed,
+		},
+		"no signature": {
+			token: "FullPath~Expires=1900000000", want: ErrMalformed,
+		},
+		"other path": {
+			token: sign(Token{FullPath: "/b.ts"}), want: ErrInvalidSignature,
+		},
+		"tampered": {
+			token: sign(Token{FullPath: "/a.ts"})[:len("FullPath~Expires=")] + "1999999999" + sign(Token{FullPath: "/a.ts"})[len("FullPath~Expires=1900000000"):],
+			want:  ErrInvalidSignature,
+		},
+		"expired": {
+			token: sign(Token{FullPath: "/a.ts"}), now: testExpires, want: ErrExpired,
+		},
+		"not started": {
+			token: sign(Token{FullPath: "/a.ts", Starts: now.Add(time.Minute)}), want: ErrNotYetValid,
+		},
+		"glob": {
+			token: sign(Token{PathGlobs: []string{"/b/*", "/*.ts"}}),
+		},
+		"glob miss": {
+			token: sign(Token{PathGlobs: []string{"/b/*", "/*.m3u8"}}), want: ErrOutOfScope,
+		},
+		"prefix": {
+			token: sign(Token{URLPrefix: "https://media.example.com/a"}),
+		},
+		"prefix miss": {
+			token: sign(Token{URLPrefix: "https://media.example.com/b"}), want: ErrOutOfScope,
+		},
+		"ip": {
+			token: sign(Token{FullPath: "/a.ts", IPRanges: []string{"2001:db8::/32", "198.51.100.0/24"}}), ip: "198.51.100.9",
+		},
+		"ip miss": {
+			token: sign(Token{FullPath: "/a.ts", IPRanges: []string{"198.51.100.0/24"}}), want: ErrIPNotAllowed,
+		},
+		"header": {
+			token: sign(Token{FullPath: "/a.ts", Headers: []Header{{"User-Agent", "player"}}}), ua: "player",
+		},
+		"header mismatch": {
+			token: sign(Token{FullPath: "/a.ts", Headers: []Header{{"User-Agent", "player"}}}), ua: "curl", want: ErrInvalidSignature,
+		},
+	} {
+		target := "https://media.example.com/a.ts"
+		if tc.token != "" {
+			target, _ = AddToQuery(target, tc.token)
+		}
+		r := httptest.NewRequest("GET", target, nil)
+		r.RemoteAddr = "203.0.113.7:4321"
+		if tc.ip != "" {
+			r.RemoteAddr = tc.ip + ":4321"
+		}
+		r.Header.Set("User-Agent", tc.ua)
+		if tc.now.IsZero() {
+			tc.now = now
+		}
+		_, err := testVerifier(tc.now).VerifyHTTP(r)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: VerifyHTTP got error %v, want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		glob, path string
+		want       bool
+	}{
+		{"/a.ts", "/a.ts", true},
+		{"/a.ts", "/b.ts", false},
+		{"/videos/*", "/videos/hd/1.ts", true},
+		{"/videos/*", "/video/1.ts", false},
+		{"/*/*.ts", "/videos/hd/1.ts", true},
+		{"/*.ts", "/a.m3u8", false},
+		{"/a*a", "/a", false},
+		{"*", "/anything", true},
+	} {
+		if got := matchGlob(tc.glob, tc.path); got != tc.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tc.glob, tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redisclient wraps a redigo connection pool with retries, circuit
+// breaking, pipelining, MULTI/EXEC transactions and routing of reads to
+// replicas. Every call is traced and recorded in client-side latency and
+// error metrics.
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options configures a Client.
+type Options struct {
+	// Primary is the pool of the primary server. It is required.
+	Primary *redis.Pool
+	// Replicas are pools of read replicas. Read-only commands are spread
+	// across them and fall back to the primary when none is available.
+	Replicas []*redis.Pool
+
+	Retry   RetryPolicy
+	Breaker BreakerPolicy
+
+	// TracerProvider and MeterProvider default to the global providers.
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+}
+
+// Cmd is a single command of a pipeline or transaction.
+type Cmd struct {
+	Name string
+	Args []interface{}
+}
+
+// Client runs Redis commands. It is safe for concurrent use.
+type Client struct {
+	primary  *target
+	replicas []*target
+	next     atomic.Uint32
+	retry    RetryPolicy
+
+	tracer           trace.Tracer
+	rttHist          metric.Float64Histogram
+	clientBlockHist  metric.Float64Histogram
+	appBlockHist     metric.Float64Histogram
+	retryCounter     metric.Int64Counter
+	connErrorCounter metric.Int64Counter
+
+	// now and sleep are replaced in tests.
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+// target is a server the client sends commands to.
+type target struct {
+	name    string
+	pool    *redis.Pool
+	breaker *breaker
+}
+
+// New returns a client for the servers in opts.
+func New(opts Options) (*Client, error) {
+	if opts.Primary == nil {
+		return nil, errors.New("redisclient: Options.Primary is required")
+	}
+	tp := opts.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	mp := opts.MeterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	c := &Client{
+		retry:  opts.Retry.withDefaults(),
+		tracer: tp.Tracer("redigo.client"),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+	now := func() time.Time { return c.now() }
+	c.primary = &target{name: "primary", pool: opts.Primary, breaker: newBreaker(opts.Breaker, now)}
+	for i, p := range opts.Replicas {
+		c.replicas = append(c.replicas, &target{name: fmt.Sprintf("replica-%d", i), pool: p, breaker: newBreaker(opts.Breaker, now)})
+	}
+
+	meter := mp.Meter("redigo.metrics")
+	var err error
+	if c.rttHist, err = meter.Float64Histogram("redis_client_rtt", metric.WithUnit("ms")); err != nil {
+		return nil, fmt.Errorf("redis_client_rtt histogram: %w", err)
+	}
+	if c.clientBlockHist, err = meter.Float64Histogram("redis_client_blocking_latency", metric.WithUnit("ms")); err != nil {
+		return nil, fmt.Errorf("redis_client_blocking_latency histogram: %w", err)
+	}
+	if c.appBlockHist, err = meter.Float64Histogram("redis_application_blocking_latency", metric.WithUnit("ms")); err != nil {
+		return nil, fmt.Errorf("redis_application_blocking_latency histogram: %w", err)
+	}
+	if c.retryCounter, err = meter.Int64Counter("redis_retry_count"); err != nil {
+		return nil, fmt.Errorf("redis_retry_count counter: %w", err)
+	}
+	if c.connErrorCounter, err = meter.Int64Counter("redis_connectivity_error_count"); err != nil {
+		return nil, fmt.Errorf("redis_connectivity_error_count counter: %w", err)
+	}
+	// Export the counters from startup, so that dashboards and alerts see
+	// zero rather than no data until the first error.
+	initAttrs := metric.WithAttributes(attribute.String("operation", "startup"))
+	c.retryCounter.Add(context.Background(), 0, initAttrs)
+	c.connErrorCounter.Add(context.Background(), 0, initAttrs)
+	return c, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sinceMs calculates elapsed time in fractional milliseconds to avoid truncating sub-millisecond durations.
+func (c *Client) sinceMs(start time.Time) float64 {
+	return float64(c.now().Sub(start).Microseconds()) / 1000.0
+}
+
+// Do runs a single command. operation names the call in spans and metrics.
+// Read-only commands are sent to a replica when there is one. A connection
+// that breaks after the command was sent leaves it unknown whether it ran,
+// so only read-only and idempotent commands are then retried.
+func (c *Client) Do(ctx context.Context, operation, command string, args ...interface{}) (interface{}, error) {
+	var reply interface{}
+	err := c.call(ctx, operation, strings.ToUpper(command), isReadOnly(command), isResendable(command), func(conn redis.Conn, timeout time.Duration) error {
+		var err error
+		if timeout > 0 {
+			reply, err = redis.DoWithTimeout(conn, timeout, command, args...)
+		} else {
+			reply, err = conn.Do(command, args...)
+		}
+		return err
+	})
+	return reply, err
+}
+
+// Pipeline sends cmds in a single round trip and returns their replies in
+// order. An error reply to one command is returned as a redis.Error in its
+// place in the slice; the returned error is only set when the pipeline as a
+// whole failed. Pipelines of read-only commands are sent to a replica when
+// there is one. Like Do, a pipeline is only resent after a broken
+// connection if all of its commands are read-only or idempotent.
+func (c *Client) Pipeline(ctx context.Context, operation string, cmds []Cmd) ([]interface{}, error) {
+	readOnly, resend := len(cmds) > 0, true
+	for _, cmd := range cmds {
+		readOnly = readOnly && isReadOnly(cmd.Name)
+		resend = resend && isResendable(cmd.Name)
+	}
+	var replies []interface{}
+	err := c.call(ctx, operation, "PIPELINE", readOnly, resend, func(conn redis.Conn, timeout time.Duration) error {
+		replies = make([]interface{}, len(cmds))
+		for _, cmd := range cmds {
+			if err := conn.Send(cmd.Name, cmd.Args...); err != nil {
+				return err
+			}
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		for i := range cmds {
+			var err error
+			if timeout > 0 {
+				replies[i], err = redis.ReceiveWithTimeout(conn, timeout)
+			} else {
+				replies[i], err = conn.Receive()
+			}
+			var reply redis.Error
+			if errors.As(err, &reply) {
+				replies[i] = reply
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return replies, err
+}
+
+// ErrTxAborted is returned by Multi when the server discarded the
+// transaction.
+var ErrTxAborted = errors.New("redisclient: transaction aborted")
+
+// Multi runs cmds atomically in a MULTI/EXEC transaction on the primary
+// and returns their replies. A connection that breaks after EXEC was sent
+// leaves it unknown whether the transaction ran, so such calls are not
+// retried.
+func (c *Client) Multi(ctx context.Context, operation string, cmds []Cmd) ([]interface{}, error) {
+	var replies []interface{}
+	err := c.call(ctx, operation, "MULTI", false, false, func(conn redis.Conn, timeout time.Duration) error {
+		if err := conn.Send("MULTI"); err != nil {
+			return err
+		}
+		for _, cmd := range cmds {
+			if err := conn.Send(cmd.Name, cmd.Args...); err != nil {
+				return err
+			}
+		}
+		var reply interface{}
+		var err error
+		if timeout > 0 {
+			reply, err = redis.DoWithTimeout(conn, timeout, "EXEC")
+		} else {
+			reply, err = conn.Do("EXEC")
+		}
+		if err != nil {
+			return err
+		}
+		if reply == nil {
+			return ErrTxAborted
+		}
+		replies, err = redis.Values(reply, nil)
+		return err
+	})
+	return replies, err
+}
+
+// TimeApp starts timing application work on a reply, such as decoding it,
+// and returns a func that records the elapsed time in the
+// redis_application_blocking_latency histogram.
+func (c *Client) TimeApp(ctx context.Context, operation string) func() {
+	start := c.now()
+	return func() {
+		c.appBlockHist.Record(ctx, c.sinceMs(start), metric.WithAttributes(attribute.String("operation", operation)))
+	}
+}
+
+// errAfterSend marks errors of calls that may have reached the server.
+type errAfterSend struct{ err error }
+
+func (e errAfterSend) Error() string { return e.err.Error() }
+func (e errAfterSend) Unwrap() error { return e.err }
+
+// call runs fn with a connection, retrying per the client's retry policy.
+// Unless resend is set, connection errors from fn are not retried.
+func (c *Client) call(ctx context.Context, operation, command string, readOnly, resend bool, fn func(conn redis.Conn, timeout time.Duration) error) error {
+	ctx, span := c.tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(attribute.String("redis.command", command))
+	defer span.End()
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		t := c.pick(readOnly)
+		span.SetAttributes(attribute.Int("redis.attempts", attempt))
+		if t == nil {
+			lastErr = ErrCircuitOpen
+		} else {
+			span.SetAttributes(attribute.String("redis.target", t.name))
+			lastErr = c.attempt(ctx, t, operation, fn)
+		}
+		if lastErr == nil {
+			span.SetStatus(codes.Ok, "")
+			return nil
+		}
+		span.RecordError(lastErr)
+
+		var sent errAfterSend
+		afterSend := errors.As(lastErr, &sent)
+		if afterSend {
+			lastErr = sent.err
+		}
+		retryable := c.retry.Retryable(lastErr) && (resend || !afterSend || !isConnError(lastErr))
+		if !retryable {
+			span.SetStatus(codes.Error, lastErr.Error())
+			return fmt.Errorf("%s: %w", operation, lastErr)
+		}
+		if attempt >= c.retry.MaxAttempts {
+			break
+		}
+		c.retryCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("operation", operation)))
+		span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1), attribute.String("error", lastErr.Error())))
+		if err := c.sleep(ctx, c.retry.backoff(attempt)); err != nil {
+			lastErr = err
+			break
+		}
+	}
+	span.SetStatus(codes.Error, lastErr.Error())
+	return fmt.Errorf("max retries reached for %s: %w", operation, lastErr)
+}
+
+// attempt makes a single attempt of a call on t.
+func (c *Client) attempt(ctx context.Context, t *target, operation string, fn func(conn redis.Conn, timeout time.Duration) error) (err error) {
+	metricOpts := metric.WithAttributes(attribute.String("operation", operation), attribute.String("target", t.name))
+	defer func() {
+		failed := isConnError(err)
+		t.breaker.record(!failed)
+		if failed {
+			c.connErrorCounter.Add(ctx, 1, metricOpts)
+		}
+	}()
+
+	poolStart := c.now()
+	// Use GetContext to respect context deadlines and cancellation
+	conn, err := t.pool.GetContext(ctx)
+	c.clientBlockHist.Record(ctx, c.sinceMs(poolStart), metricOpts)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Check if the connection is dead
+	if err := conn.Err(); err != nil {
+		return err
+	}
+
+	// Redigo has no native DoContext; pass timeouts using the *WithTimeout
+	// helpers when the context has a deadline.
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		if timeout = time.Until(deadline); timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+	reqStart := c.now()
+	err = fn(conn, timeout)
+	c.rttHist.Record(ctx, c.sinceMs(reqStart), metricOpts)
+	if err != nil {
+		return errAfterSend{err}
+	}
+	return nil
+}
+
+// pick returns the target for the next attempt of a call, or nil if every
+// candidate's circuit is open. Reads go round-robin to the replicas and
+// fall back to the primary.
+func (c *Client) pick(readOnly bool) *target {
+	if readOnly && len(c.replicas) > 0 {
+		start := int(c.next.Add(1))
+		for i := range c.replicas {
+			t := c.replicas[(start+i)%len(c.replicas)]
+			if t.breaker.allow() {
+				return t
+			}
+		}
+	}
+	if c.primary.breaker.allow() {
+		return c.primary
+	}
+	return nil
+}
+
+// readOnlyCommands are commands that are safe to send to a replica.
+var readOnlyCommands = map[string]bool{
+	"GET": true, "MGET": true, "STRLEN": true, "GETRANGE": true, "EXISTS": true, "TTL": true, "PTTL": true, "TYPE": true,
+	"HGET": true, "HMGET": true, "HGETALL": true, "HKEYS": true, "HVALS": true, "HLEN": true, "HEXISTS": true,
+	"LRANGE": true, "LLEN": true, "LINDEX": true,
+	"SMEMBERS": true, "SISMEMBER": true, "SCARD": true,
+	"ZRANGE": true, "ZRANGEBYSCORE": true, "ZREVRANGE": true, "ZSCORE": true, "ZCARD": true, "ZRANK": true, "ZCOUNT": true,
+	"SCAN": true, "HSCAN": true, "SSCAN": true, "ZSCAN": true,
+}
+
+func isReadOnly(command string) bool {
+	return readOnlyCommands[strings.ToUpper(command)]
+}
+
+// idempotentCommands are writes that leave the same data when they run
+// twice, so they can be resent when it is unknown whether they ran. INCR,
+// APPEND, LPUSH and the like are not.
+var idempotentCommands = map[string]bool{
+	"SET": true, "MSET": true, "DEL": true, "UNLINK": true,
+	"EXPIRE": true, "PEXPIRE": true, "EXPIREAT": true, "PEXPIREAT": true, "PERSIST": true,
+	"HSET": true, "HMSET": true, "HDEL": true,
+	"SADD": true, "SREM": true, "ZREM": true,
+	"PING": true,
+}
+
+// isResendable reports whether command can be resent after a connection
+// broke while it was running.
+func isResendable(command string) bool {
+	command = strings.ToUpper(command)
+	return readOnlyCommands[command] || idempotentCommands[command]
+}
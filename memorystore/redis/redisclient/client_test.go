@@ -0,0 +1,581 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testClient is a client recording its spans, metrics and backoffs.
+type testClient struct {
+	*Client
+	reader *sdkmetric.ManualReader
+	spans  *tracetest.SpanRecorder
+	sleeps []time.Duration
+	clock  time.Time
+}
+
+func newTestClient(t *testing.T, opts Options) *testClient {
+	t.Helper()
+	tc := &testClient{
+		reader: sdkmetric.NewManualReader(),
+		spans:  tracetest.NewSpanRecorder(),
+		clock:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	opts.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(tc.reader))
+	opts.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tc.spans))
+	c, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c.now = func() time.Time { return tc.clock }
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		tc.sleeps = append(tc.sleeps, d)
+		return ctx.Err()
+	}
+	tc.Client = c
+	return tc
+}
+
+func (tc *testClient) collect(t *testing.T) metricdata.ResourceMetrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := tc.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	return rm
+}
+
+// counter returns the total of the counter name over the data points
+// having all of attrs.
+func counter(rm metricdata.ResourceMetrics, name string, attrs ...attribute.KeyValue) int64 {
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == name {
+				for _, dp := range sum.DataPoints {
+					if hasAll(dp.Attributes, attrs) {
+						total += dp.Value
+					}
+				}
+			}
+		}
+	}
+	return total
+}
+
+// histogramCount returns the number of recordings of the histogram name
+// over the data points having all of attrs.
+func histogramCount(rm metricdata.ResourceMetrics, name string, attrs ...attribute.KeyValue) uint64 {
+	var total uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == name {
+				for _, dp := range h.DataPoints {
+					if hasAll(dp.Attributes, attrs) {
+						total += dp.Count
+					}
+				}
+			}
+		}
+	}
+	return total
+}
+
+func hasAll(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if v, ok := set.Value(a.Key); !ok || v != a.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func spanAttr(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, a := range s.Attributes() {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name         string
+		seed         map[string]string
+		faults       []string
+		op           string
+		args         []interface{}
+		want         interface{}
+		wantErr      string
+		wantCommands int
+		wantRetries  int64
+		wantConnErrs int64
+	}{
+		{
+			name:         "Success_SET",
+			op:           "set_user",
+			args:         []interface{}{"SET", "user:123", "active"},
+			want:         "OK",
+			wantCommands: 1,
+		},
+		{
+			name:         "Success_GET",
+			seed:         map[string]string{"user:123": "active"},
+			op:           "get_user",
+			args:         []interface{}{"GET", "user:123"},
+			want:         "active",
+			wantCommands: 1,
+		},
+		{
+			name:         "RetryOnDroppedConnection_ThenSuccess",
+			seed:         map[string]string{"user:123": "active"},
+			faults:       []string{"drop"},
+			op:           "get_user",
+			args:         []interface{}{"GET", "user:123"},
+			want:         "active",
+			wantCommands: 2,
+			wantRetries:  1,
+			wantConnErrs: 1,
+		},
+		{
+			name:         "RetryOnLoading_ThenSuccess",
+			faults:       []string{"LOADING Redis is loading the dataset in memory"},
+			op:           "set_user",
+			args:         []interface{}{"SET", "user:123", "active"},
+			want:         "OK",
+			wantCommands: 2,
+			wantRetries:  1,
+		},
+		{
+			name:         "PermanentFailure_AfterMaxRetries",
+			faults:       []string{"drop", "drop", "drop"},
+			op:           "set_user",
+			args:         []interface{}{"SET", "user:123", "active"},
+			wantErr:      "max retries reached for set_user",
+			wantCommands: 3,
+			wantRetries:  2,
+			wantConnErrs: 3,
+		},
+		{
+			name:         "ErrorReply_NotRetried",
+			faults:       []string{"WRONGTYPE Operation against a key holding the wrong kind of value"},
+			op:           "get_user",
+			args:         []interface{}{"GET", "user:123"},
+			wantErr:      "get_user: WRONGTYPE",
+			wantCommands: 1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newFakeServer(t)
+			for k, v := range tc.seed {
+				s.set(k, v)
+			}
+			s.fail(tc.faults...)
+			c := newTestClient(t, Options{Primary: s.pool(t)})
+
+			reply, err := c.Do(context.Background(), tc.op, tc.args[0].(string), tc.args[1:]...)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("Do got error %v, want it to contain %q", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Do: %v", err)
+			} else if got, _ := redis.String(reply, nil); got != tc.want {
+				t.Errorf("Do got %v, want %v", got, tc.want)
+			}
+
+			if got := len(s.log()); got != tc.wantCommands {
+				t.Errorf("server got %d commands, want %d", got, tc.wantCommands)
+			}
+			if got := len(c.sleeps); int64(got) != tc.wantRetries {
+				t.Errorf("client slept %d times, want %d", got, tc.wantRetries)
+			}
+			rm := c.collect(t)
+			op := attribute.String("operation", tc.op)
+			if got := counter(rm, "redis_retry_count", op); got != tc.wantRetries {
+				t.Errorf("redis_retry_count = %d, want %d", got, tc.wantRetries)
+			}
+			if got := counter(rm, "redis_connectivity_error_count", op, attribute.String("target", "primary")); got != tc.wantConnErrs {
+				t.Errorf("redis_connectivity_error_count = %d, want %d", got, tc.wantConnErrs)
+			}
+			if got := histogramCount(rm, "redis_client_rtt", op); got != uint64(tc.wantCommands) {
+				t.Errorf("redis_client_rtt count = %d, want %d", got, tc.wantCommands)
+			}
+			if got := histogramCount(rm, "redis_client_blocking_latency", op); got != uint64(tc.wantCommands) {
+				t.Errorf("redis_client_blocking_latency count = %d, want %d", got, tc.wantCommands)
+			}
+		})
+	}
+}
+
+func TestDoNotResent(t *testing.T) {
+	s := newFakeServer(t)
+	s.set("visits", "1")
+	c := newTestClient(t, Options{Primary: s.pool(t)})
+	ctx := context.Background()
+
+	// The connection breaks after INCR ran, so resending it would count
+	// the visit twice.
+	s.fail("lost")
+	if _, err := c.Do(ctx, "visit", "INCR", "visits"); err == nil || strings.Contains(err.Error(), "max retries") {
+		t.Errorf("Do got %v, want a connection error without retries", err)
+	}
+	s.fail("lost")
+	if _, err := c.Pipeline(ctx, "visits", []Cmd{{Name: "INCR", Args: []interface{}{"visits"}}, {Name: "GET", Args: []interface{}{"visits"}}}); err == nil {
+		t.Errorf("Pipeline got nil error, want a connection error")
+	}
+	if len(c.sleeps) != 0 {
+		t.Errorf("client retried %d times, want none", len(c.sleeps))
+	}
+	if got, want := strings.Join(s.log(), " "), "INCR INCR"; got != want {
+		t.Errorf("server got %q, want %q", got, want)
+	}
+	reply, err := c.Do(ctx, "get", "GET", "visits")
+	if n, _ := redis.Int(reply, err); n != 3 {
+		t.Errorf("visits = %v, %v, want 3", reply, err)
+	}
+
+	// SET can be resent.
+	s.fail("lost")
+	if _, err := c.Do(ctx, "set", "SET", "visits", "0"); err != nil {
+		t.Errorf("Do: %v", err)
+	}
+	if len(c.sleeps) != 1 {
+		t.Errorf("client retried %d times, want 1", len(c.sleeps))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s := newFakeServer(t)
+	s.fail("drop", "drop", "drop", "drop")
+	c := newTestClient(t, Options{
+		Primary: s.pool(t),
+		Retry:   RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, Multiplier: 3, MaxBackoff: time.Second},
+	})
+	if _, err := c.Do(context.Background(), "get", "GET", "k"); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second}
+	if len(c.sleeps) != len(want) {
+		t.Fatalf("sleeps = %v, want %v", c.sleeps, want)
+	}
+	for i := range want {
+		if c.sleeps[i] != want[i] {
+			t.Errorf("sleeps = %v, want %v", c.sleeps, want)
+			break
+		}
+	}
+}
+
+func TestRetryableClassifier(t *testing.T) {
+	s := newFakeServer(t)
+	s.fail("ERR custom")
+	c := newTestClient(t, Options{
+		Primary: s.pool(t),
+		Retry: RetryPolicy{Retryable: func(err error) bool {
+			return IsRetryable(err) || strings.HasPrefix(err.Error(), "ERR custom")
+		}},
+	})
+	if _, err := c.Do(context.Background(), "ping", "PING"); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if got := len(s.log()); got != 2 {
+		t.Errorf("server got %d commands, want 2", got)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset by peer"), true},
+		{redis.Error("LOADING Redis is loading the dataset in memory"), true},
+		{redis.Error("READONLY You can't write against a read only replica."), true},
+		{redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{redis.Error("ERR unknown command"), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{ErrCircuitOpen, false},
+	}
+	for _, tc := range tests {
+		if got := IsRetryable(tc.err); got != tc.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	s := newFakeServer(t)
+	s.fail("drop")
+	c := newTestClient(t, Options{Primary: s.pool(t)})
+	ctx := context.Background()
+	if _, err := c.Do(ctx, "set_user", "SET", "user:123", "active"); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	s.fail("WRONGTYPE Operation against a key holding the wrong kind of value")
+	if _, err := c.Do(ctx, "get_user", "GET", "user:123"); err == nil {
+		t.Fatalf("Do got no error, want WRONGTYPE")
+	}
+
+	spans := c.spans.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	set, get := spans[0], spans[1]
+	if set.Name() != "set_user" || set.Status().Code != codes.Ok {
+		t.Errorf("span %q has status %v, want set_user with Ok", set.Name(), set.Status())
+	}
+	if got := spanAttr(set, "redis.attempts").AsInt64(); got != 2 {
+		t.Errorf("set_user redis.attempts = %d, want 2", got)
+	}
+	if got := spanAttr(set, "redis.command").AsString(); got != "SET" {
+		t.Errorf("set_user redis.command = %q, want SET", got)
+	}
+	var retries int
+	for _, e := range set.Events() {
+		if e.Name == "retry" {
+			retries++
+		}
+	}
+	if retries != 1 {
+		t.Errorf("set_user has %d retry events, want 1", retries)
+	}
+	if get.Status().Code != codes.Error {
+		t.Errorf("get_user status = %v, want Error", get.Status())
+	}
+}
+
+func TestTimeApp(t *testing.T) {
+	s := newFakeServer(t)
+	c := newTestClient(t, Options{Primary: s.pool(t)})
+	done := c.TimeApp(context.Background(), "render")
+	c.clock = c.clock.Add(3 * time.Millisecond)
+	done()
+
+	rm := c.collect(t)
+	if got := histogramCount(rm, "redis_application_blocking_latency", attribute.String("operation", "render")); got != 1 {
+		t.Errorf("redis_application_blocking_latency count = %d, want 1", got)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "redis_application_blocking_latency" {
+				if got := m.Data.(metricdata.Histogram[float64]).DataPoints[0].Sum; got != 3 {
+					t.Errorf("redis_application_blocking_latency sum = %v, want 3", got)
+				}
+			}
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	// A listener that is closed right away gives an address that refuses
+	// connections.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	down := ln.Addr().String()
+	ln.Close()
+	s := newFakeServer(t)
+
+	addr, dials := down, 0
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) {
+		dials++
+		return redis.Dial("tcp", addr)
+	}}
+	defer pool.Close()
+	c := newTestClient(t, Options{
+		Primary: pool,
+		Retry:   RetryPolicy{MaxAttempts: 1},
+		Breaker: BreakerPolicy{FailureThreshold: 2, OpenTimeout: 10 * time.Second},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do(ctx, "ping", "PING"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Do #%d got %v, want a connection error", i+1, err)
+		}
+	}
+	if got := c.primary.breaker.current(); got != open {
+		t.Fatalf("breaker is %v after 2 failures, want open", got)
+	}
+	if _, err := c.Do(ctx, "ping", "PING"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Do with an open circuit got %v, want ErrCircuitOpen", err)
+	}
+	if dials != 2 {
+		t.Errorf("dialed %d times, want 2", dials)
+	}
+
+	// After the timeout a failed probe reopens the circuit.
+	c.clock = c.clock.Add(10 * time.Second)
+	if _, err := c.Do(ctx, "ping", "PING"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe got %v, want a connection error", err)
+	}
+	if got := c.primary.breaker.current(); got != open {
+		t.Fatalf("breaker is %v after a failed probe, want open", got)
+	}
+
+	// A successful probe closes it.
+	c.clock = c.clock.Add(10 * time.Second)
+	addr = s.ln.Addr().String()
+	if _, err := c.Do(ctx, "ping", "PING"); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if got := c.primary.breaker.current(); got != closed {
+		t.Errorf("breaker is %v after a successful probe, want closed", got)
+	}
+}
+
+func TestBreakerIgnoresErrorReplies(t *testing.T) {
+	s := newFakeServer(t)
+	s.fail("ERR one", "ERR two", "ERR three")
+	c := newTestClient(t, Options{Primary: s.pool(t), Breaker: BreakerPolicy{FailureThreshold: 1}})
+	for i := 0; i < 3; i++ {
+		c.Do(context.Background(), "ping", "PING")
+	}
+	if got := c.primary.breaker.current(); got != closed {
+		t.Errorf("breaker is %v after error replies, want closed", got)
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	primary, r0, r1 := newFakeServer(t), newFakeServer(t), newFakeServer(t)
+	c := newTestClient(t, Options{
+		Primary:  primary.pool(t),
+		Replicas: []*redis.Pool{r0.pool(t), r1.pool(t)},
+		Breaker:  BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute},
+	})
+	ctx := context.Background()
+
+	if _, err := c.Do(ctx, "set", "SET", "k", "v"); err != nil {
+		t.Fatalf("SET: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := c.Do(ctx, "get", "get", "k"); err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+	}
+	if got := len(primary.log()); got != 1 {
+		t.Errorf("primary got %d commands, want only the SET", got)
+	}
+	if len(r0.log()) != 2 || len(r1.log()) != 2 {
+		t.Errorf("replicas got %d and %d GETs, want 2 each", len(r0.log()), len(r1.log()))
+	}
+
+	// Failing replicas trip their breakers; the retries go to the other
+	// replica and then to the primary, which also serves later reads.
+	r0.fail("drop")
+	r1.fail("drop")
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do(ctx, "get", "GET", "k"); err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+	}
+	if got := len(primary.log()); got != 3 {
+		t.Errorf("primary got %d commands, want the SET and 2 fallback GETs", got)
+	}
+	rm := c.collect(t)
+	for _, target := range []string{"replica-0", "replica-1"} {
+		if got := counter(rm, "redis_connectivity_error_count", attribute.String("target", target)); got != 1 {
+			t.Errorf("redis_connectivity_error_count{target=%s} = %d, want 1", target, got)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	primary, replica := newFakeServer(t), newFakeServer(t)
+	primary.set("a", "1")
+	replica.set("a", "1")
+	c := newTestClient(t, Options{Primary: primary.pool(t), Replicas: []*redis.Pool{replica.pool(t)}})
+	ctx := context.Background()
+
+	replies, err := c.Pipeline(ctx, "bump", []Cmd{
+		{Name: "INCR", Args: []interface{}{"a"}},
+		{Name: "NOPE"},
+		{Name: "GET", Args: []interface{}{"a"}},
+	})
+	if err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+	if n, _ := redis.Int(replies[0], nil); n != 2 {
+		t.Errorf("INCR got %v, want 2", replies[0])
+	}
+	if _, ok := replies[1].(redis.Error); !ok {
+		t.Errorf("NOPE got %v, want a redis.Error", replies[1])
+	}
+	if v, _ := redis.String(replies[2], nil); v != "2" {
+		t.Errorf("GET got %v, want 2", replies[2])
+	}
+	if got := len(primary.log()); got != 3 {
+		t.Errorf("primary got %d commands, want 3", got)
+	}
+
+	if _, err := c.Pipeline(ctx, "read", []Cmd{{Name: "GET", Args: []interface{}{"a"}}, {Name: "GET", Args: []interface{}{"b"}}}); err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+	if got := len(replica.log()); got != 2 {
+		t.Errorf("replica got %d commands, want the read-only pipeline", got)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	s := newFakeServer(t)
+	c := newTestClient(t, Options{Primary: s.pool(t)})
+	ctx := context.Background()
+
+	replies, err := c.Multi(ctx, "swap", []Cmd{
+		{Name: "SET", Args: []interface{}{"a", "1"}},
+		{Name: "INCR", Args: []interface{}{"a"}},
+	})
+	if err != nil {
+		t.Fatalf("Multi: %v", err)
+	}
+	if len(replies) != 2 {
+		t.Fatalf("Multi got %d replies, want 2", len(replies))
+	}
+	if n, _ := redis.Int(replies[1], nil); n != 2 {
+		t.Errorf("INCR got %v, want 2", replies[1])
+	}
+	if got, want := strings.Join(s.log(), " "), "MULTI SET INCR EXEC"; got != want {
+		t.Errorf("server got %q, want %q", got, want)
+	}
+
+	// A connection dropped at EXEC may have run the transaction, so it
+	// isn't retried.
+	s.fail("", "", "drop")
+	if _, err := c.Multi(ctx, "incr", []Cmd{{Name: "INCR", Args: []interface{}{"a"}}}); err == nil || strings.Contains(err.Error(), "max retries") {
+		t.Errorf("Multi got %v, want a connection error without retries", err)
+	}
+	if len(c.sleeps) != 0 {
+		t.Errorf("Multi retried %d times, want none", len(c.sleeps))
+	}
+}
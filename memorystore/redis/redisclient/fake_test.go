@@ -0,0 +1,255 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// fakeServer is an in-process server speaking enough of the Redis protocol
+// for the tests: strings, INCR, DEL, PING and MULTI/EXEC. Faults can be
+// injected for the next commands it receives.
+type fakeServer struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	data     map[string]string
+	faults   []string // "drop" closes the connection, "lost" runs the command first, anything else is an error reply
+	commands []string
+	dials    int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	s := &fakeServer{ln: ln, data: map[string]string{}}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// pool returns a pool dialing s that doesn't keep idle connections, so
+// every attempt dials.
+func (s *fakeServer) pool(t *testing.T) *redis.Pool {
+	p := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", s.ln.Addr().String(), redis.DialReadTimeout(time.Second))
+		},
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// fail injects faults for the next commands.
+func (s *fakeServer) fail(faults ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+func (s *fakeServer) set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+}
+
+// log returns the commands s received.
+func (s *fakeServer) log() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *fakeServer) dialCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.dials++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var queued [][]string
+	inTx := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+
+		s.mu.Lock()
+		s.commands = append(s.commands, name)
+		var fault string
+		if len(s.faults) > 0 {
+			fault, s.faults = s.faults[0], s.faults[1:]
+		}
+		s.mu.Unlock()
+
+		var reply interface{}
+		switch {
+		case fault == "drop":
+			return
+		case fault == "lost":
+			s.exec(args)
+			return
+		case fault != "":
+			reply = redis.Error(fault)
+		case name == "MULTI":
+			inTx, queued = true, nil
+			reply = "OK"
+		case name == "EXEC":
+			replies := make([]interface{}, len(queued))
+			for i, cmd := range queued {
+				replies[i] = s.exec(cmd)
+			}
+			inTx, queued = false, nil
+			reply = replies
+		case inTx:
+			queued = append(queued, args)
+			reply = "QUEUED"
+		default:
+			reply = s.exec(args)
+		}
+		writeReply(w, reply)
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// exec runs a command and returns its reply: a string for a status, a
+// []byte for a bulk string, an int64, nil or a redis.Error.
+func (s *fakeServer) exec(args []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch name := strings.ToUpper(args[0]); {
+	case name == "PING":
+		return "PONG"
+	case name == "GET" && len(args) == 2:
+		v, ok := s.data[args[1]]
+		if !ok {
+			return nil
+		}
+		return []byte(v)
+	case name == "SET" && len(args) == 3:
+		s.data[args[1]] = args[2]
+		return "OK"
+	case name == "INCR" && len(args) == 2:
+		n, err := strconv.ParseInt(s.data[args[1]], 10, 64)
+		if err != nil && s.data[args[1]] != "" {
+			return redis.Error("ERR value is not an integer or out of range")
+		}
+		n++
+		s.data[args[1]] = strconv.FormatInt(n, 10)
+		return n
+	case name == "DEL":
+		var n int64
+		for _, k := range args[1:] {
+			if _, ok := s.data[k]; ok {
+				delete(s.data, k)
+				n++
+			}
+		}
+		return n
+	}
+	return redis.Error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad array length %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimPrefix(line, "$"))
+		if err != nil {
+			return nil, fmt.Errorf("bad bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	return strings.TrimSuffix(line, "\r\n"), err
+}
+
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case string:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case redis.Error:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, r := range v {
+			writeReply(w, r)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrCircuitOpen is returned when a circuit breaker rejects a call.
+var ErrCircuitOpen = errors.New("redisclient: circuit breaker open")
+
+// RetryPolicy controls how failed calls are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first. It
+	// defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. It defaults to
+	// 200ms and is multiplied by Multiplier, default 2, for each further
+	// retry, up to MaxBackoff, default 2s.
+	InitialBackoff time.Duration
+	Multiplier     float64
+	MaxBackoff     time.Duration
+	// Jitter randomizes each wait by up to this fraction of it, e.g. 0.2
+	// for ±20%.
+	Jitter float64
+	// Retryable reports whether a failed call may be retried. It defaults
+	// to IsRetryable.
+	Retryable func(error) bool
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 200 * time.Millisecond
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 2 * time.Second
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+// backoff returns the wait before retry n, from 1.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(n-1))
+	d = math.Min(d, float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// retryableReplies are prefixes of error replies of a server that is
+// temporarily unable to serve a command, e.g. while it loads its data set
+// or right after a failover.
+var retryableReplies = []string{"LOADING", "BUSY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN", "READONLY"}
+
+// IsRetryable reports whether err is worth retrying: connectivity errors
+// and error replies of a server that is temporarily unavailable. Other
+// error replies, such as WRONGTYPE, fail the same way when retried.
+// Canceled and expired contexts are never retried. Whatever it reports, a
+// connection that broke after a command was sent is only retried for
+// read-only and idempotent commands.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var reply redis.Error
+	if errors.As(err, &reply) {
+		for _, prefix := range retryableReplies {
+			if strings.HasPrefix(string(reply), prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// isConnError reports whether err means the server couldn't be reached or
+// the connection broke, as opposed to an error reply, a canceled call or a
+// rejection by the breaker. Only these count against the circuit breaker.
+func isConnError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var reply redis.Error
+	return !errors.As(err, &reply)
+}
+
+// BreakerPolicy configures the circuit breakers of a client. Each server
+// has one.
+type BreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failed attempts that
+	// opens the circuit. Zero disables the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long an open circuit rejects calls before it lets
+	// a probe through. It defaults to 30s.
+	OpenTimeout time.Duration
+}
+
+type breakerState int
+
+const (
+	closed breakerState = iota
+	open
+	halfOpen
+)
+
+func (s breakerState) String() string {
+	return [...]string{"closed", "open", "half-open"}[s]
+}
+
+// breaker is a circuit breaker. When closed it allows all calls. After
+// FailureThreshold failures in a row it opens and rejects calls. After
+// OpenTimeout it is half-open and allows a single probe, whose outcome
+// closes or reopens it.
+type breaker struct {
+	policy BreakerPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(p BreakerPolicy, now func() time.Time) *breaker {
+	if p.OpenTimeout <= 0 {
+		p.OpenTimeout = 30 * time.Second
+	}
+	return &breaker{policy: p, now: now}
+}
+
+// allow reports whether a call may proceed. Calls that proceed must be
+// followed by record.
+func (b *breaker) allow() bool {
+	if b.policy.FailureThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case open:
+		if b.now().Sub(b.openedAt) < b.policy.OpenTimeout {
+			return false
+		}
+		b.state = halfOpen
+		b.probing = true
+		return true
+	case halfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record records the outcome of an allowed call.
+func (b *breaker) record(ok bool) {
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if ok {
+		b.state = closed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == halfOpen || b.failures >= b.policy.FailureThreshold {
+		b.state = open
+		b.openedAt = b.now()
+	}
+}
+
+// current returns the state of the breaker.
+func (b *breaker) current() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,285 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consumer runs Pub/Sub subscribers with handler middleware, error
+// classification, a poison-message policy and graceful drain.
+//
+// A handler returns nil to ack a message and an error to nack it for
+// redelivery. Errors wrapped with Permanent, and messages that reach the
+// maximum number of delivery attempts, are published to a dead-letter topic
+// and acked.
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+// Attributes added to dead-lettered messages.
+const (
+	AttrSubscription    = "dead_letter_subscription"
+	AttrMessageID       = "dead_letter_message_id"
+	AttrDeliveryAttempt = "dead_letter_delivery_attempt"
+	AttrError           = "dead_letter_error"
+)
+
+// Config configures a Consumer.
+type Config struct {
+	// Subscriber receives the messages. It is required.
+	Subscriber *pubsub.Subscriber
+	// Handler processes each message. It is required.
+	Handler Handler
+	// Middleware wraps Handler; see Chain.
+	Middleware []Middleware
+	// Classify decides what to do with a message from its handler's error.
+	// It defaults to Classify.
+	Classify func(error) Decision
+
+	// DeadLetter receives poison messages. Without it they are logged and
+	// acked, which drops them.
+	DeadLetter *pubsub.Publisher
+	// MaxDeliveryAttempts is the number of deliveries after which a
+	// message that keeps failing is dead-lettered. It defaults to 5; a
+	// negative value redelivers it forever. Attempts are taken from the
+	// message when the subscription has a dead-letter policy, and counted
+	// by this process otherwise. Such counts are per process: deliveries to
+	// other consumers of the subscription aren't counted, and the count of
+	// a message that isn't redelivered here for attemptsTTL is forgotten.
+	MaxDeliveryAttempts int
+
+	// DrainTimeout bounds how long Run waits for in-flight messages once
+	// its context is done. Handlers' contexts are canceled when it
+	// expires. It defaults to 30s.
+	DrainTimeout time.Duration
+
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Stats counts the outcomes of handled messages.
+type Stats struct {
+	Acked, Nacked, DeadLettered, Dropped int64
+}
+
+// Consumer receives messages from a subscription and handles them.
+type Consumer struct {
+	sub          *pubsub.Subscriber
+	handler      Handler
+	classify     func(error) Decision
+	deadLetter   *pubsub.Publisher
+	maxAttempts  int
+	drainTimeout time.Duration
+	logger       *slog.Logger
+
+	// attempts counts deliveries of messages without a delivery attempt
+	// from the service, by message ID, until they are acked or
+	// dead-lettered, or expire after attemptsTTL.
+	mu        sync.Mutex
+	attempts  map[string]*deliveries
+	lastSweep time.Time
+	// now returns the current time. It defaults to time.Now.
+	now func() time.Time
+
+	acked, nacked, deadLettered, dropped atomic.Int64
+}
+
+// New returns a Consumer for cfg.
+func New(cfg Config) (*Consumer, error) {
+	if cfg.Subscriber == nil {
+		return nil, errors.New("consumer: Config.Subscriber is required")
+	}
+	if cfg.Handler == nil {
+		return nil, errors.New("consumer: Config.Handler is required")
+	}
+	c := &Consumer{
+		sub:          cfg.Subscriber,
+		handler:      Chain(cfg.Handler, cfg.Middleware...),
+		classify:     cfg.Classify,
+		deadLetter:   cfg.DeadLetter,
+		maxAttempts:  cfg.MaxDeliveryAttempts,
+		drainTimeout: cfg.DrainTimeout,
+		logger:       cfg.Logger,
+		attempts:     make(map[string]*deliveries),
+		now:          time.Now,
+	}
+	if c.classify == nil {
+		c.classify = Classify
+	}
+	if c.maxAttempts == 0 {
+		c.maxAttempts = 5
+	}
+	if c.drainTimeout <= 0 {
+		c.drainTimeout = 30 * time.Second
+	}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+	return c, nil
+}
+
+// Stats returns the outcomes of the messages handled so far.
+func (c *Consumer) Stats() Stats {
+	return Stats{
+		Acked:        c.acked.Load(),
+		Nacked:       c.nacked.Load(),
+		DeadLettered: c.deadLettered.Load(),
+		Dropped:      c.dropped.Load(),
+	}
+}
+
+// Run receives and handles messages until ctx is done or receiving fails.
+// Once ctx is done it stops pulling and waits for in-flight messages to be
+// handled, for up to the drain timeout, before returning. Handlers keep a
+// live context while draining.
+func (c *Consumer) Run(ctx context.Context) error {
+	// Handlers get contexts that outlive ctx by up to the drain timeout.
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+	var drainExpired atomic.Bool
+	stopDrain := context.AfterFunc(ctx, func() {
+		time.AfterFunc(c.drainTimeout, func() {
+			drainExpired.Store(true)
+			cancelHandlers()
+		})
+	})
+	defer stopDrain()
+
+	err := c.sub.Receive(ctx, func(rctx context.Context, msg *pubsub.Message) {
+		mctx, cancel := context.WithCancel(context.WithoutCancel(rctx))
+		defer cancel()
+		stop := context.AfterFunc(handlerCtx, cancel)
+		defer stop()
+		c.handle(mctx, msg)
+	})
+	if drainExpired.Load() {
+		c.logger.Warn("drain timeout expired; canceled in-flight handlers", slog.String("subscription", c.sub.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("Receive: %w", err)
+	}
+	return nil
+}
+
+// handle runs the handler on msg and acts on its decision.
+func (c *Consumer) handle(ctx context.Context, msg *pubsub.Message) {
+	attempt := c.attempt(msg)
+	err := c.handler(ctx, msg)
+	decision := c.classify(err)
+	if decision == Nack && c.maxAttempts > 0 && attempt >= c.maxAttempts {
+		decision = DeadLetter
+	}
+
+	switch decision {
+	case Ack:
+		c.forget(msg)
+		msg.Ack()
+		c.acked.Add(1)
+	case Nack:
+		msg.Nack()
+		c.nacked.Add(1)
+	case DeadLetter:
+		c.poison(ctx, msg, attempt, err)
+	}
+}
+
+// poison dead-letters msg, which failed with err on the given attempt.
+func (c *Consumer) poison(ctx context.Context, msg *pubsub.Message, attempt int, err error) {
+	log := c.logger.With(slog.String("message_id", msg.ID), slog.Int("delivery_attempt", attempt), slog.Any("error", err))
+	if c.deadLetter == nil {
+		log.Error("dropping poison message: no dead-letter topic")
+		c.forget(msg)
+		msg.Ack()
+		c.dropped.Add(1)
+		return
+	}
+
+	attrs := make(map[string]string, len(msg.Attributes)+4)
+	for k, v := range msg.Attributes {
+		attrs[k] = v
+	}
+	attrs[AttrSubscription] = c.sub.String()
+	attrs[AttrMessageID] = msg.ID
+	attrs[AttrDeliveryAttempt] = strconv.Itoa(attempt)
+	if err != nil {
+		attrs[AttrError] = err.Error()
+	}
+	res := c.deadLetter.Publish(ctx, &pubsub.Message{Data: msg.Data, Attributes: attrs})
+	if _, perr := res.Get(ctx); perr != nil {
+		// Leave the message to be redelivered and dead-lettered again.
+		log.Error("dead-lettering message failed", slog.Any("publish_error", perr))
+		msg.Nack()
+		c.nacked.Add(1)
+		return
+	}
+	log.Warn("dead-lettered message")
+	c.forget(msg)
+	msg.Ack()
+	c.deadLettered.Add(1)
+}
+
+// attemptsTTL is how long the consumer counts the deliveries of a message
+// that isn't redelivered. A nacked message is redelivered well within it,
+// after at most the maximum backoff of a retry policy, 10 minutes; one that
+// isn't was acked or expired elsewhere, e.g. by another consumer.
+const attemptsTTL = 30 * time.Minute
+
+// deliveries counts the deliveries of a message.
+type deliveries struct {
+	n    int
+	last time.Time
+}
+
+// attempt returns the delivery attempt of msg.
+func (c *Consumer) attempt(msg *pubsub.Message) int {
+	if msg.DeliveryAttempt != nil {
+		return *msg.DeliveryAttempt
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.Sub(c.lastSweep) >= attemptsTTL {
+		for id, d := range c.attempts {
+			if now.Sub(d.last) >= attemptsTTL {
+				delete(c.attempts, id)
+			}
+		}
+		c.lastSweep = now
+	}
+	d := c.attempts[msg.ID]
+	if d == nil {
+		d = &deliveries{}
+		c.attempts[msg.ID] = d
+	}
+	d.n++
+	d.last = now
+	return d.n
+}
+
+// forget stops counting the deliveries of msg.
+func (c *Consumer) forget(msg *pubsub.Message) {
+	if msg.DeliveryAttempt != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.attempts, msg.ID)
+}
@@ -0,0 +1,463 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	pb "cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const project = "projects/p"
+
+// fixture is a pstest server with a topic, a subscription to it and a
+// dead-letter topic.
+type fixture struct {
+	srv    *pstest.Server
+	clock  *clock
+	client *pubsub.Client
+	topic  string
+	sub    string
+	dlq    string
+}
+
+func newFixture(t *testing.T, sub *pb.Subscription) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	clk := &clock{now: time.Now()}
+	srv.SetTimeNowFunc(clk.Now)
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	client, err := pubsub.NewClient(ctx, "p", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("pubsub.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	f := &fixture{srv: srv, clock: clk, client: client, topic: project + "/topics/orders", sub: project + "/subscriptions/orders", dlq: project + "/topics/orders-dlq"}
+	for _, name := range []string{f.topic, f.dlq} {
+		if _, err := client.TopicAdminClient.CreateTopic(ctx, &pb.Topic{Name: name}); err != nil {
+			t.Fatalf("CreateTopic: %v", err)
+		}
+	}
+	if sub == nil {
+		sub = &pb.Subscription{}
+	}
+	sub.Name, sub.Topic, sub.AckDeadlineSeconds = f.sub, f.topic, 10
+	if _, err := client.SubscriptionAdminClient.CreateSubscription(ctx, sub); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	return f
+}
+
+// clock is the fake time of a pstest server.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (f *fixture) publish(t *testing.T, data ...string) {
+	t.Helper()
+	for _, d := range data {
+		f.srv.Publish(f.topic, []byte(d), map[string]string{"source": "test"})
+	}
+}
+
+// deadLettered returns the messages published to the dead-letter topic.
+func (f *fixture) deadLettered() []*pstest.Message {
+	var msgs []*pstest.Message
+	for _, m := range f.srv.Messages() {
+		if m.Topic == f.dlq {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+func (f *fixture) config(h Handler) Config {
+	sub := f.client.Subscriber(f.sub)
+	sub.ReceiveSettings.NumGoroutines = 1
+	return Config{
+		Subscriber: sub,
+		Handler:    h,
+		DeadLetter: f.client.Publisher(f.dlq),
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// run runs a consumer for cfg until done returns true, then drains it.
+//
+// The client may extend a message's ack deadline after the consumer has
+// nacked it, which holds the message until the deadline. run moves the
+// server's clock past ack deadlines so that nacked messages are redelivered
+// promptly.
+func (f *fixture) run(t *testing.T, cfg Config, done func(*Consumer) bool) *Consumer {
+	t.Helper()
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- c.Run(ctx) }()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !done(c) {
+		if time.Now().After(deadline) {
+			cancel()
+			<-errc
+			t.Fatalf("timed out; stats: %+v", c.Stats())
+		}
+		time.Sleep(50 * time.Millisecond)
+		f.clock.Advance(11 * time.Second)
+	}
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return c
+}
+
+type order struct {
+	ID    string `json:"id"`
+	Total int    `json:"total"`
+}
+
+func TestTypedHandlerAcks(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, `{"id":"a","total":1}`, `{"id":"b","total":2}`, `{"id":"c","total":3}`)
+
+	var mu sync.Mutex
+	var total int
+	cfg := f.config(Typed(JSON[order], func(_ context.Context, o order, _ *pubsub.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		total += o.Total
+		return nil
+	}))
+	c := f.run(t, cfg, func(c *Consumer) bool { return c.Stats().Acked == 3 })
+
+	if total != 6 {
+		t.Errorf("total = %d, want 6", total)
+	}
+	if got := c.Stats(); got != (Stats{Acked: 3}) {
+		t.Errorf("Stats() = %+v, want 3 acked", got)
+	}
+	for _, m := range f.srv.Messages() {
+		if m.Acks != 1 {
+			t.Errorf("message %s acked %d times, want 1", m.Data, m.Acks)
+		}
+	}
+}
+
+func TestDecodeErrorIsDeadLettered(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, `not json`)
+
+	cfg := f.config(Typed(JSON[order], func(context.Context, order, *pubsub.Message) error {
+		t.Error("handler called for a message that can't be decoded")
+		return nil
+	}))
+	c := f.run(t, cfg, func(c *Consumer) bool { return c.Stats().DeadLettered == 1 })
+
+	dl := f.deadLettered()
+	if len(dl) != 1 {
+		t.Fatalf("got %d dead-lettered messages, want 1", len(dl))
+	}
+	if got := string(dl[0].Data); got != "not json" {
+		t.Errorf("dead-lettered data = %q, want the original", got)
+	}
+	if got := dl[0].Attributes["source"]; got != "test" {
+		t.Errorf("dead-lettered source attribute = %q, want the original", got)
+	}
+	if got := dl[0].Attributes[AttrDeliveryAttempt]; got != "1" {
+		t.Errorf("dead-lettered %s = %q, want 1", AttrDeliveryAttempt, got)
+	}
+	if got := dl[0].Attributes[AttrError]; !strings.Contains(got, "decoding message") {
+		t.Errorf("dead-lettered %s = %q, want the decoding error", AttrError, got)
+	}
+	if got := dl[0].Attributes[AttrSubscription]; got != f.sub {
+		t.Errorf("dead-lettered %s = %q, want %q", AttrSubscription, got, f.sub)
+	}
+	if got := c.Stats(); got.Nacked != 0 {
+		t.Errorf("Stats() = %+v, want no nacks", got)
+	}
+}
+
+func TestRetryThenSuccess(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, "flaky")
+
+	var calls atomic.Int32
+	cfg := f.config(func(context.Context, *pubsub.Message) error {
+		if calls.Add(1) < 3 {
+			return errors.New("backend unavailable")
+		}
+		return nil
+	})
+	c := f.run(t, cfg, func(c *Consumer) bool { return c.Stats().Acked == 1 })
+
+	if got := c.Stats(); got != (Stats{Acked: 1, Nacked: 2}) {
+		t.Errorf("Stats() = %+v, want 2 nacks and an ack", got)
+	}
+	if len(f.deadLettered()) != 0 {
+		t.Errorf("got dead-lettered messages, want none")
+	}
+	if n := len(c.attempts); n != 0 {
+		t.Errorf("consumer still counts attempts of %d messages, want none", n)
+	}
+}
+
+func TestMaxDeliveryAttempts(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, "poison")
+
+	cfg := f.config(func(context.Context, *pubsub.Message) error {
+		return errors.New("always fails")
+	})
+	cfg.MaxDeliveryAttempts = 3
+	c := f.run(t, cfg, func(c *Consumer) bool { return c.Stats().DeadLettered == 1 })
+
+	if got := c.Stats(); got != (Stats{Nacked: 2, DeadLettered: 1}) {
+		t.Errorf("Stats() = %+v, want 2 nacks and a dead letter", got)
+	}
+	dl := f.deadLettered()
+	if len(dl) != 1 || dl[0].Attributes[AttrDeliveryAttempt] != "3" || dl[0].Attributes[AttrError] != "always fails" {
+		t.Fatalf("dead-lettered %+v, want one message after 3 attempts", dl)
+	}
+}
+
+func TestAttemptsExpire(t *testing.T) {
+	now := time.Now()
+	c := &Consumer{attempts: make(map[string]*deliveries), now: func() time.Time { return now }}
+	a, b := &pubsub.Message{ID: "a"}, &pubsub.Message{ID: "b"}
+
+	c.attempt(a)
+	c.attempt(b)
+	now = now.Add(attemptsTTL / 2)
+	if got := c.attempt(a); got != 2 {
+		t.Errorf("second delivery of a is attempt %d, want 2", got)
+	}
+
+	// b hasn't been redelivered for attemptsTTL: it was handled elsewhere.
+	now = now.Add(attemptsTTL / 2)
+	if got := c.attempt(a); got != 3 {
+		t.Errorf("third delivery of a is attempt %d, want 3", got)
+	}
+	if _, ok := c.attempts[b.ID]; ok {
+		t.Errorf("consumer still counts attempts of b after %v", attemptsTTL)
+	}
+}
+
+func TestMaxDeliveryAttemptsFromService(t *testing.T) {
+	// With a dead-letter policy on the subscription the service reports
+	// delivery attempts; the consumer's own limit is lower.
+	f := newFixture(t, &pb.Subscription{DeadLetterPolicy: &pb.DeadLetterPolicy{
+		DeadLetterTopic:     project + "/topics/orders-dlq",
+		MaxDeliveryAttempts: 10,
+	}})
+	f.publish(t, "poison")
+
+	var attempts []int
+	cfg := f.config(func(_ context.Context, msg *pubsub.Message) error {
+		attempts = append(attempts, *msg.DeliveryAttempt)
+		return errors.New("always fails")
+	})
+	cfg.MaxDeliveryAttempts = 2
+	c := f.run(t, cfg, func(c *Consumer) bool { return c.Stats().DeadLettered == 1 })
+
+	if fmt.Sprint(attempts) != "[1 2]" {
+		t.Errorf("handler saw delivery attempts %v, want [1 2]", attempts)
+	}
+	if len(c.attempts) != 0 {
+		t.Errorf("consumer counted attempts itself, want it to use the service's")
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, "panics", "fine")
+
+	cfg := f.config(func(_ context.Context, msg *pubsub.Message) error {
+		if string(msg.Data) == "panics" {
+			panic("boom")
+		}
+		return nil
+	})
+	cfg.Middleware = []Middleware{Recover()}
+	cfg.MaxDeliveryAttempts = 2
+	c := f.run(t, cfg, func(c *Consumer) bool {
+		s := c.Stats()
+		return s.Acked == 1 && s.DeadLettered == 1
+	})
+
+	dl := f.deadLettered()
+	if len(dl) != 1 || !strings.Contains(dl[0].Attributes[AttrError], "handler panicked: boom") {
+		t.Fatalf("dead-lettered %+v, want the panicking message", dl)
+	}
+	if got := c.Stats().Nacked; got != 1 {
+		t.Errorf("Nacked = %d, want 1", got)
+	}
+}
+
+func TestCustomClassify(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, "duplicate")
+
+	errDuplicate := errors.New("already processed")
+	cfg := f.config(func(context.Context, *pubsub.Message) error { return errDuplicate })
+	cfg.Classify = func(err error) Decision {
+		if errors.Is(err, errDuplicate) {
+			return Ack
+		}
+		return Classify(err)
+	}
+	c := f.run(t, cfg, func(c *Consumer) bool { return c.Stats().Acked == 1 })
+	if got := c.Stats(); got != (Stats{Acked: 1}) {
+		t.Errorf("Stats() = %+v, want 1 acked", got)
+	}
+}
+
+func TestNoDeadLetterTopicDrops(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, "poison")
+
+	var logs bytes.Buffer
+	cfg := f.config(func(context.Context, *pubsub.Message) error { return Permanent(errors.New("bad")) })
+	cfg.DeadLetter = nil
+	cfg.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	c := f.run(t, cfg, func(c *Consumer) bool { return c.Stats().Dropped == 1 })
+
+	if got := c.Stats(); got != (Stats{Dropped: 1}) {
+		t.Errorf("Stats() = %+v, want 1 dropped", got)
+	}
+	if !strings.Contains(logs.String(), "dropping poison message") {
+		t.Errorf("logs = %q, want a dropped message", logs.String())
+	}
+}
+
+func TestGracefulDrain(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, "slow")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handlerErr atomic.Value
+	c, err := New(f.config(func(ctx context.Context, _ *pubsub.Message) error {
+		close(started)
+		<-release
+		handlerErr.Store(fmt.Sprint(ctx.Err()))
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- c.Run(ctx) }()
+
+	<-started
+	cancel()
+	select {
+	case err := <-errc:
+		t.Fatalf("Run returned %v with a message in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := handlerErr.Load(); got != "<nil>" {
+		t.Errorf("handler context error while draining = %v, want nil", got)
+	}
+	if got := c.Stats().Acked; got != 1 {
+		t.Errorf("Acked = %d, want the drained message acked", got)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	f := newFixture(t, nil)
+	f.publish(t, "stuck")
+
+	started := make(chan struct{})
+	cfg := f.config(func(ctx context.Context, _ *pubsub.Message) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	cfg.DrainTimeout = 50 * time.Millisecond
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- c.Run(ctx) }()
+
+	<-started
+	cancel()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after the drain timeout")
+	}
+	if got := c.Stats().Nacked; got != 1 {
+		t.Errorf("Nacked = %d, want the canceled message nacked", got)
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	if _, err := New(Config{Handler: func(context.Context, *pubsub.Message) error { return nil }}); err == nil {
+		t.Error("New without a Subscriber succeeded, want an error")
+	}
+	f := newFixture(t, nil)
+	if _, err := New(Config{Subscriber: f.client.Subscriber(f.sub)}); err == nil {
+		t.Error("New without a Handler succeeded, want an error")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+// Handler processes a message. The error it returns decides what happens
+// to the message; see Classify.
+type Handler func(ctx context.Context, msg *pubsub.Message) error
+
+// Middleware wraps a Handler with extra behavior.
+type Middleware func(Handler) Handler
+
+// Chain wraps h in mw. The first middleware is the outermost one, so it
+// sees a message first and its result last.
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Decoder decodes the data of a message into a T.
+type Decoder[T any] func(data []byte) (T, error)
+
+// JSON decodes JSON data into a T.
+func JSON[T any](data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// Typed returns a Handler that decodes each message with decode and passes
+// the value to fn. Messages that can't be decoded fail permanently.
+func Typed[T any](decode Decoder[T], fn func(ctx context.Context, v T, msg *pubsub.Message) error) Handler {
+	return func(ctx context.Context, msg *pubsub.Message) error {
+		v, err := decode(msg.Data)
+		if err != nil {
+			return Permanent(fmt.Errorf("decoding message %s: %w", msg.ID, err))
+		}
+		return fn(ctx, v, msg)
+	}
+}
+
+// permanentError marks an error that redelivery can't fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that redelivering the message can't
+// fix, such as a malformed payload. Such messages are dead-lettered right
+// away instead of being redelivered.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Decision is what the consumer does with a handled message.
+type Decision int
+
+const (
+	// Ack acknowledges the message.
+	Ack Decision = iota
+	// Nack asks for the message to be redelivered.
+	Nack
+	// DeadLetter publishes the message to the dead-letter topic and acks
+	// it.
+	DeadLetter
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Ack:
+		return "ack"
+	case Nack:
+		return "nack"
+	case DeadLetter:
+		return "dead_letter"
+	}
+	return fmt.Sprintf("Decision(%d)", int(d))
+}
+
+// Classify is the default classification of handler errors: success is
+// acked, permanent errors are dead-lettered and all other errors are
+// nacked for redelivery.
+func Classify(err error) Decision {
+	switch {
+	case err == nil:
+		return Ack
+	case IsPermanent(err):
+		return DeadLetter
+	}
+	return Nack
+}
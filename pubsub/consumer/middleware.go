@@ -0,0 +1,148 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PanicError is returned by handlers wrapped with Recover that panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+// Recover turns handler panics into a *PanicError. Such errors are nacked
+// like any other, so a message that always panics ends up dead-lettered
+// once it reaches the maximum number of delivery attempts.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *pubsub.Message) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Value: v, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Logging logs the outcome of each message: successes at debug level and
+// failures at warning level.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *pubsub.Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			attrs := []any{
+				slog.String("message_id", msg.ID),
+				slog.Int("delivery_attempt", deliveryAttempt(msg)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				logger.WarnContext(ctx, "handling message failed", append(attrs, slog.Any("error", err), slog.Bool("permanent", IsPermanent(err)))...)
+				return err
+			}
+			logger.DebugContext(ctx, "handled message", attrs...)
+			return nil
+		}
+	}
+}
+
+// Tracing records a span for each message.
+func Tracing(tp trace.TracerProvider) Middleware {
+	tracer := tp.Tracer("github.com/GoogleCloudPlatform/golang-samples/pubsub/consumer")
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *pubsub.Message) error {
+			ctx, span := tracer.Start(ctx, "consumer process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("messaging.system", "gcp_pubsub"),
+					attribute.String("messaging.message.id", msg.ID),
+					attribute.Int("messaging.gcp_pubsub.message.delivery_attempt", deliveryAttempt(msg)),
+				))
+			defer span.End()
+			if msg.OrderingKey != "" {
+				span.SetAttributes(attribute.String("messaging.gcp_pubsub.message.ordering_key", msg.OrderingKey))
+			}
+
+			err := next(ctx, msg)
+			if err != nil {
+				span.RecordError(err)
+				span.SetAttributes(attribute.Bool("consumer.error.permanent", IsPermanent(err)))
+				span.SetStatus(codes.Error, err.Error())
+				return err
+			}
+			span.SetStatus(codes.Ok, "")
+			return nil
+		}
+	}
+}
+
+// Metrics counts handled messages by result (ok, error or permanent) in
+// pubsub_consumer_handled_count and records handler latency in
+// pubsub_consumer_handler_latency.
+func Metrics(mp metric.MeterProvider) (Middleware, error) {
+	meter := mp.Meter("github.com/GoogleCloudPlatform/golang-samples/pubsub/consumer")
+	handled, err := meter.Int64Counter("pubsub_consumer_handled_count")
+	if err != nil {
+		return nil, fmt.Errorf("pubsub_consumer_handled_count counter: %w", err)
+	}
+	latency, err := meter.Float64Histogram("pubsub_consumer_handler_latency", metric.WithUnit("ms"))
+	if err != nil {
+		return nil, fmt.Errorf("pubsub_consumer_handler_latency histogram: %w", err)
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *pubsub.Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			result := "ok"
+			switch {
+			case IsPermanent(err):
+				result = "permanent"
+			case err != nil:
+				result = "error"
+			}
+			attrs := metric.WithAttributes(attribute.String("result", result))
+			latency.Record(ctx, float64(time.Since(start).Microseconds())/1000.0, attrs)
+			handled.Add(ctx, 1, attrs)
+			return err
+		}
+	}, nil
+}
+
+// deliveryAttempt returns the delivery attempt reported by the service, or
+// 0 when the subscription has no dead-letter policy.
+func deliveryAttempt(msg *pubsub.Message) int {
+	if msg.DeliveryAttempt == nil {
+		return 0
+	}
+	return *msg.DeliveryAttempt
+}
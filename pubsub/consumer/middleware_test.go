@@ -0,0 +1,187 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func fail(err error) Handler {
+	return func(context.Context, *pubsub.Message) error { return err }
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, msg *pubsub.Message) error {
+				order = append(order, name+" in")
+				err := next(ctx, msg)
+				order = append(order, name+" out")
+				return err
+			}
+		}
+	}
+	h := Chain(func(context.Context, *pubsub.Message) error {
+		order = append(order, "handler")
+		return nil
+	}, mw("a"), mw("b"))
+	h(context.Background(), &pubsub.Message{})
+	if got, want := strings.Join(order, ", "), "a in, b in, handler, b out, a out"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Decision
+	}{
+		{nil, Ack},
+		{errors.New("transient"), Nack},
+		{Permanent(errors.New("bad payload")), DeadLetter},
+		{&PanicError{Value: "boom"}, Nack},
+	}
+	for _, tc := range tests {
+		if got := Classify(tc.err); got != tc.want {
+			t.Errorf("Classify(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) != nil")
+	}
+	inner := errors.New("inner")
+	if err := Permanent(inner); !errors.Is(err, inner) || err.Error() != "inner" {
+		t.Errorf("Permanent(inner) = %v, want it to wrap inner", err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Recover()(func(context.Context, *pubsub.Message) error { panic("boom") })
+	err := h(context.Background(), &pubsub.Message{})
+	var pe *PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" || len(pe.Stack) == 0 {
+		t.Fatalf("got %v, want a *PanicError with a stack", err)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	attempt := 2
+	msg := &pubsub.Message{ID: "m1", DeliveryAttempt: &attempt}
+
+	Logging(logger)(fail(nil))(context.Background(), msg)
+	Logging(logger)(fail(Permanent(errors.New("bad payload"))))(context.Background(), msg)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2:\n%s", len(lines), buf.String())
+	}
+	for _, want := range []string{"level=DEBUG", `msg="handled message"`, "message_id=m1", "delivery_attempt=2"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("success log %q doesn't contain %q", lines[0], want)
+		}
+	}
+	for _, want := range []string{"level=WARN", `error="bad payload"`, "permanent=true"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("failure log %q doesn't contain %q", lines[1], want)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	mw := Tracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	mw(fail(nil))(context.Background(), &pubsub.Message{ID: "ok", OrderingKey: "k"})
+	mw(fail(Permanent(errors.New("bad"))))(context.Background(), &pubsub.Message{ID: "bad"})
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	attrs := func(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := map[attribute.Key]attribute.Value{}
+		for _, a := range s.Attributes() {
+			m[a.Key] = a.Value
+		}
+		return m
+	}
+	ok, bad := attrs(spans[0]), attrs(spans[1])
+	if spans[0].Status().Code != codes.Ok || ok["messaging.message.id"].AsString() != "ok" || ok["messaging.gcp_pubsub.message.ordering_key"].AsString() != "k" {
+		t.Errorf("success span: status %v, attributes %v", spans[0].Status(), ok)
+	}
+	if spans[1].Status().Code != codes.Error || !bad["consumer.error.permanent"].AsBool() {
+		t.Errorf("failure span: status %v, attributes %v", spans[1].Status(), bad)
+	}
+	if len(spans[1].Events()) != 1 {
+		t.Errorf("failure span has %d events, want the recorded error", len(spans[1].Events()))
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mw, err := Metrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	if err != nil {
+		t.Fatalf("Metrics: %v", err)
+	}
+	ctx := context.Background()
+	mw(fail(nil))(ctx, &pubsub.Message{})
+	mw(fail(nil))(ctx, &pubsub.Message{})
+	mw(fail(errors.New("transient")))(ctx, &pubsub.Message{})
+	mw(fail(Permanent(errors.New("bad"))))(ctx, &pubsub.Message{})
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	counts := map[string]int64{}
+	var latencies uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					result, _ := dp.Attributes.Value("result")
+					counts[result.AsString()] += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					latencies += dp.Count
+				}
+			}
+		}
+	}
+	if counts["ok"] != 2 || counts["error"] != 1 || counts["permanent"] != 1 {
+		t.Errorf("pubsub_consumer_handled_count by result = %v, want ok:2 error:1 permanent:1", counts)
+	}
+	if latencies != 4 {
+		t.Errorf("pubsub_consumer_handler_latency count = %d, want 4", latencies)
+	}
+}
@@ -0,0 +1,483 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ordered publishes Pub/Sub messages with ordering keys and
+// recovers from failed keys without manual ResumePublish calls.
+//
+// When a publish with an ordering key fails, the Pub/Sub client pauses the
+// key and fails every later message for it until ResumePublish is called.
+// A Publisher collects the failed messages, buffers (or rejects) new ones
+// for the key, and after a backoff resumes the key and republishes them in
+// their original order.
+package ordered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Policy is what a Publisher does with new messages for a paused key.
+type Policy int
+
+const (
+	// Buffer queues new messages and publishes them after the key resumes.
+	Buffer Policy = iota
+	// Reject fails new messages with ErrKeyPaused.
+	Reject
+)
+
+var (
+	// ErrKeyPaused is returned for messages rejected because their
+	// ordering key is paused.
+	ErrKeyPaused = errors.New("ordered: ordering key is paused")
+	// ErrBufferFull is returned for messages that don't fit in the buffer
+	// of a paused ordering key.
+	ErrBufferFull = errors.New("ordered: ordering key buffer is full")
+	// ErrStopped is returned for messages still queued when the Publisher
+	// is stopped.
+	ErrStopped = errors.New("ordered: publisher stopped")
+)
+
+// Options configures a Publisher.
+type Options struct {
+	// Policy applies to new messages for a paused key. It defaults to
+	// Buffer.
+	Policy Policy
+	// MaxBuffered is the number of messages a paused key holds, including
+	// the failed ones. It defaults to 1000.
+	MaxBuffered int
+
+	// InitialBackoff is the wait before resuming a key after its first
+	// failure. It defaults to 1s and is multiplied by Multiplier, default
+	// 2, after each further failure, up to MaxBackoff, default 1m.
+	InitialBackoff time.Duration
+	Multiplier     float64
+	MaxBackoff     time.Duration
+
+	// MaxAttempts is the number of times a message is published before it
+	// fails. It defaults to 5; a negative value retries forever.
+	MaxAttempts int
+	// Retryable reports whether a failed publish may be retried. It
+	// defaults to retrying everything but InvalidArgument errors, which
+	// mean the message itself is bad. The client has already retried
+	// transient errors by the time a Publisher sees them.
+	Retryable func(error) bool
+
+	// MeterProvider defaults to the global meter provider.
+	MeterProvider metric.MeterProvider
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxBuffered <= 0 {
+		o.MaxBuffered = 1000
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = time.Second
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = 2
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+	if o.MaxAttempts == 0 {
+		o.MaxAttempts = 5
+	}
+	if o.Retryable == nil {
+		o.Retryable = func(err error) bool { return status.Code(err) != codes.InvalidArgument }
+	}
+	if o.MeterProvider == nil {
+		o.MeterProvider = otel.GetMeterProvider()
+	}
+	return o
+}
+
+// KeyStats describes the state of an ordering key.
+type KeyStats struct {
+	// Queued is the number of messages waiting for the key to resume.
+	Queued int
+	// InFlight is the number of messages being published.
+	InFlight int
+	// Paused reports whether the key is paused after a failure.
+	Paused bool
+	// Failures is the number of failures since the key last published
+	// successfully.
+	Failures int
+	// LastError is the error that paused the key.
+	LastError error
+}
+
+// Publisher publishes messages with ordering keys. It is safe for
+// concurrent use.
+type Publisher struct {
+	pub  *pubsub.Publisher
+	opts Options
+
+	resumes  metric.Int64Counter
+	rejected metric.Int64Counter
+	reg      metric.Registration
+
+	// after runs f after d; it is replaced in tests.
+	after func(d time.Duration, f func()) (stop func() bool)
+
+	mu      sync.Mutex
+	idle    *sync.Cond // signaled when a key becomes idle
+	keys    map[string]*key
+	stopped bool
+}
+
+// key is the state of an ordering key with messages in flight or queued.
+// Keys without either are removed.
+type key struct {
+	name     string
+	nextSeq  uint64
+	inFlight int
+	// queue holds the messages to publish when the key resumes, by
+	// sequence number.
+	queue    []*pending
+	paused   bool
+	failures int
+	lastErr  error
+	// abort is set when a message failed for good; the messages after it
+	// fail too instead of being republished out of order.
+	abort error
+	stop  func() bool // cancels a scheduled resume
+}
+
+type pending struct {
+	seq      uint64
+	msg      *pubsub.Message
+	attempts int
+	res      *Result
+}
+
+// Result is the result of publishing a message.
+type Result struct {
+	done chan struct{}
+	id   string
+	err  error
+}
+
+func newResult() *Result {
+	return &Result{done: make(chan struct{})}
+}
+
+func (r *Result) resolve(id string, err error) {
+	r.id, r.err = id, err
+	close(r.done)
+}
+
+// Ready returns a channel that is closed when the result is available.
+func (r *Result) Ready() <-chan struct{} {
+	return r.done
+}
+
+// Get waits for the message to be published, possibly after its key was
+// resumed, and returns its server-assigned ID.
+func (r *Result) Get(ctx context.Context) (string, error) {
+	select {
+	case <-r.done:
+		return r.id, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// New returns a Publisher that publishes with pub, enabling message
+// ordering on it. The caller must not publish with pub directly.
+func New(pub *pubsub.Publisher, opts Options) (*Publisher, error) {
+	opts = opts.withDefaults()
+	pub.EnableMessageOrdering = true
+	p := &Publisher{
+		pub:  pub,
+		opts: opts,
+		keys: make(map[string]*key),
+		after: func(d time.Duration, f func()) func() bool {
+			return time.AfterFunc(d, f).Stop
+		},
+	}
+	p.idle = sync.NewCond(&p.mu)
+
+	meter := opts.MeterProvider.Meter("github.com/GoogleCloudPlatform/golang-samples/pubsub/ordered")
+	var err error
+	if p.resumes, err = meter.Int64Counter("pubsub_ordered_key_resume_count"); err != nil {
+		return nil, fmt.Errorf("pubsub_ordered_key_resume_count counter: %w", err)
+	}
+	if p.rejected, err = meter.Int64Counter("pubsub_ordered_key_rejected_count"); err != nil {
+		return nil, fmt.Errorf("pubsub_ordered_key_rejected_count counter: %w", err)
+	}
+	queued, err := meter.Int64ObservableGauge("pubsub_ordered_key_queued")
+	if err != nil {
+		return nil, fmt.Errorf("pubsub_ordered_key_queued gauge: %w", err)
+	}
+	inFlight, err := meter.Int64ObservableGauge("pubsub_ordered_key_in_flight")
+	if err != nil {
+		return nil, fmt.Errorf("pubsub_ordered_key_in_flight gauge: %w", err)
+	}
+	paused, err := meter.Int64ObservableGauge("pubsub_ordered_key_paused")
+	if err != nil {
+		return nil, fmt.Errorf("pubsub_ordered_key_paused gauge: %w", err)
+	}
+	p.reg, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for name, s := range p.Stats() {
+			attrs := metric.WithAttributes(attribute.String("ordering_key", name))
+			o.ObserveInt64(queued, int64(s.Queued), attrs)
+			o.ObserveInt64(inFlight, int64(s.InFlight), attrs)
+			var v int64
+			if s.Paused {
+				v = 1
+			}
+			o.ObserveInt64(paused, v, attrs)
+		}
+		return nil
+	}, queued, inFlight, paused)
+	if err != nil {
+		return nil, fmt.Errorf("registering queue metrics: %w", err)
+	}
+	return p, nil
+}
+
+// Stats returns the state of the ordering keys that have messages in
+// flight or queued, or are paused.
+func (p *Publisher) Stats() map[string]KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]KeyStats, len(p.keys))
+	for name, k := range p.keys {
+		stats[name] = KeyStats{
+			Queued:    len(k.queue),
+			InFlight:  k.inFlight,
+			Paused:    k.paused,
+			Failures:  k.failures,
+			LastError: k.lastErr,
+		}
+	}
+	return stats
+}
+
+// Publish publishes msg. Messages without an ordering key are passed
+// through to the underlying publisher.
+func (p *Publisher) Publish(ctx context.Context, msg *pubsub.Message) *Result {
+	res := newResult()
+	if msg.OrderingKey == "" {
+		r := p.pub.Publish(ctx, msg)
+		go func() {
+			res.resolve(r.Get(context.Background()))
+		}()
+		return res
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		res.resolve("", ErrStopped)
+		return res
+	}
+	k := p.keys[msg.OrderingKey]
+	if k == nil {
+		k = &key{name: msg.OrderingKey}
+		p.keys[k.name] = k
+	}
+	m := &pending{seq: k.nextSeq, msg: msg, res: res}
+	k.nextSeq++
+
+	switch {
+	case !k.paused:
+		p.send(ctx, k, m)
+	case p.opts.Policy == Reject:
+		p.reject(ctx, k, m, ErrKeyPaused, "paused")
+	case len(k.queue) >= p.opts.MaxBuffered:
+		p.reject(ctx, k, m, ErrBufferFull, "buffer_full")
+	default:
+		k.queue = append(k.queue, m)
+	}
+	return res
+}
+
+func (p *Publisher) reject(ctx context.Context, k *key, m *pending, err error, reason string) {
+	p.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("ordering_key", k.name), attribute.String("reason", reason)))
+	m.res.resolve("", fmt.Errorf("%w: %q: %v", err, k.name, k.lastErr))
+}
+
+// send publishes m. It must be called with p.mu held, so that messages of
+// a key reach the client in order.
+func (p *Publisher) send(ctx context.Context, k *key, m *pending) {
+	k.inFlight++
+	m.attempts++
+	r := p.pub.Publish(ctx, m.msg)
+	go func() {
+		id, err := r.Get(context.Background())
+		p.done(k, m, id, err)
+	}()
+}
+
+// done records the result of publishing m.
+func (p *Publisher) done(k *key, m *pending, id string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.inFlight--
+
+	var paused pubsub.ErrPublishingPaused
+	switch {
+	case err != nil && p.stopped:
+		m.res.resolve("", fmt.Errorf("publishing with ordering key %q: %w", k.name, err))
+	case err == nil:
+		m.res.resolve(id, nil)
+		if !k.paused {
+			k.failures = 0
+			k.lastErr = nil
+		}
+	case errors.As(err, &paused):
+		// Failed because an earlier message failed; it wasn't attempted.
+		m.attempts--
+		k.paused = true
+		p.requeue(k, m)
+	default:
+		k.paused = true
+		k.failures++
+		k.lastErr = err
+		if k.abort == nil && (!p.opts.Retryable(err) || (p.opts.MaxAttempts > 0 && m.attempts >= p.opts.MaxAttempts)) {
+			k.abort = err
+			m.res.resolve("", fmt.Errorf("publishing with ordering key %q: %w", k.name, err))
+		} else {
+			p.requeue(k, m)
+		}
+	}
+
+	switch {
+	case k.inFlight > 0 || !k.paused || k.stop != nil || p.stopped:
+	case k.abort != nil:
+		// Nothing will be republished; fail the queue right away.
+		p.resumeLocked(k)
+		return
+	default:
+		k.stop = p.after(p.backoff(k.failures), func() { p.resume(k) })
+	}
+	p.release(k)
+}
+
+// requeue adds m to the queue of k in sequence order.
+func (p *Publisher) requeue(k *key, m *pending) {
+	i := sort.Search(len(k.queue), func(i int) bool { return k.queue[i].seq > m.seq })
+	k.queue = append(k.queue, nil)
+	copy(k.queue[i+1:], k.queue[i:])
+	k.queue[i] = m
+}
+
+// backoff returns the wait before resuming a key after n failures.
+func (p *Publisher) backoff(n int) time.Duration {
+	d := p.opts.InitialBackoff
+	for i := 1; i < n && d < p.opts.MaxBackoff; i++ {
+		d = time.Duration(float64(d) * p.opts.Multiplier)
+	}
+	if d > p.opts.MaxBackoff {
+		d = p.opts.MaxBackoff
+	}
+	return d
+}
+
+// resume resumes a paused key after its backoff.
+func (p *Publisher) resume(k *key) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.stop = nil
+	if !p.stopped {
+		p.resumeLocked(k)
+	}
+}
+
+// resumeLocked resumes a paused key and republishes its queue in order.
+func (p *Publisher) resumeLocked(k *key) {
+	queue := k.queue
+	k.queue = nil
+	k.paused = false
+	p.pub.ResumePublish(k.name)
+	p.resumes.Add(context.Background(), 1, metric.WithAttributes(attribute.String("ordering_key", k.name)))
+
+	if err := k.abort; err != nil {
+		// A message failed for good; fail the messages queued behind it
+		// rather than publish them out of order.
+		k.abort = nil
+		k.failures = 0
+		for _, m := range queue {
+			m.res.resolve("", fmt.Errorf("publishing with ordering key %q: an earlier message failed: %w", k.name, err))
+		}
+	} else {
+		for _, m := range queue {
+			p.send(context.Background(), k, m)
+		}
+	}
+	p.release(k)
+}
+
+// release forgets k if it is idle.
+func (p *Publisher) release(k *key) {
+	if k.inFlight == 0 && len(k.queue) == 0 && !k.paused {
+		delete(p.keys, k.name)
+		p.idle.Broadcast()
+	}
+}
+
+// Flush waits until every message published so far has been published or
+// has failed, including messages waiting for their key to resume.
+func (p *Publisher) Flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.idle.Broadcast()
+	})
+	defer stop()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.keys) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.idle.Wait()
+	}
+	return nil
+}
+
+// Stop fails the messages waiting for their key to resume with ErrStopped
+// and stops the underlying publisher, which sends the messages in flight.
+func (p *Publisher) Stop() {
+	p.mu.Lock()
+	p.stopped = true
+	for _, k := range p.keys {
+		if k.stop != nil {
+			k.stop()
+			k.stop = nil
+		}
+		for _, m := range k.queue {
+			m.res.resolve("", ErrStopped)
+		}
+		k.queue = nil
+		k.paused = false
+		p.release(k)
+	}
+	p.mu.Unlock()
+	p.pub.Stop()
+	p.reg.Unregister()
+}
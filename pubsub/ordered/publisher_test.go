@@ -0,0 +1,450 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ordered
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	pb "cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const topic = "projects/p/topics/ordered"
+
+// faults is a pstest reactor that fails publish requests with scripted
+// errors per ordering key. A failing request waits for release, so that
+// tests can queue messages behind it first.
+type faults struct {
+	mu      sync.Mutex
+	errs    map[string][]error
+	release chan struct{}
+}
+
+func (f *faults) fail(key string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs[key] = append(f.errs[key], errs...)
+}
+
+func (f *faults) React(req interface{}) (bool, interface{}, error) {
+	r := req.(*pb.PublishRequest)
+	key := r.Messages[0].OrderingKey
+	f.mu.Lock()
+	errs := f.errs[key]
+	if len(errs) == 0 {
+		f.mu.Unlock()
+		return false, nil, nil
+	}
+	f.errs[key] = errs[1:]
+	f.mu.Unlock()
+	<-f.release
+	return true, nil, errs[0]
+}
+
+// timers runs scheduled resumes when a test fires them.
+type timers struct {
+	mu      sync.Mutex
+	delays  []time.Duration
+	pending []func()
+}
+
+func (ts *timers) after(d time.Duration, f func()) func() bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.delays = append(ts.delays, d)
+	ts.pending = append(ts.pending, f)
+	i := len(ts.pending) - 1
+	return func() bool {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		stopped := ts.pending[i] != nil
+		ts.pending[i] = nil
+		return stopped
+	}
+}
+
+// fire waits for the next resume to be scheduled and runs it.
+func (ts *timers) fire(t *testing.T) {
+	t.Helper()
+	f := ts.next(t)
+	f()
+}
+
+func (ts *timers) next(t *testing.T) func() {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		ts.mu.Lock()
+		for i, f := range ts.pending {
+			if f != nil {
+				ts.pending[i] = nil
+				ts.mu.Unlock()
+				return f
+			}
+		}
+		ts.mu.Unlock()
+	}
+	t.Fatal("no resume was scheduled")
+	return nil
+}
+
+type fixture struct {
+	srv    *pstest.Server
+	faults *faults
+	timers *timers
+	reader *sdkmetric.ManualReader
+	p      *Publisher
+}
+
+func newFixture(t *testing.T, opts Options) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &fixture{
+		faults: &faults{errs: map[string][]error{}, release: make(chan struct{})},
+		timers: &timers{},
+		reader: sdkmetric.NewManualReader(),
+	}
+	f.srv = pstest.NewServer(pstest.ServerReactorOption{FuncName: "Publish", Reactor: f.faults})
+	t.Cleanup(func() { f.srv.Close() })
+	conn, err := grpc.NewClient(f.srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	client, err := pubsub.NewClient(ctx, "p", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("pubsub.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.TopicAdminClient.CreateTopic(ctx, &pb.Topic{Name: topic}); err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+
+	pub := client.Publisher(topic)
+	// Publish each message in its own request, so a failure affects one
+	// message and the ones queued behind it.
+	pub.PublishSettings.CountThreshold = 1
+	opts.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(f.reader))
+	if f.p, err = New(pub, opts); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	f.p.after = f.timers.after
+	t.Cleanup(f.p.Stop)
+	return f
+}
+
+func (f *fixture) publish(key string, data ...string) []*Result {
+	var rs []*Result
+	for _, d := range data {
+		rs = append(rs, f.p.Publish(context.Background(), &pubsub.Message{Data: []byte(d), OrderingKey: key}))
+	}
+	return rs
+}
+
+// published returns the data of the messages the server stored for key.
+func (f *fixture) published(key string) []string {
+	var data []string
+	for _, m := range f.srv.Messages() {
+		if m.OrderingKey == key {
+			data = append(data, string(m.Data))
+		}
+	}
+	return data
+}
+
+func (f *fixture) flush(t *testing.T) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := f.p.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v; stats: %v", err, f.p.Stats())
+	}
+}
+
+func get(t *testing.T, r *Result) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.Get(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Result.Get timed out")
+	}
+	return err
+}
+
+var errPrecondition = status.Error(codes.FailedPrecondition, "injected")
+
+func TestResumeAfterFailure(t *testing.T) {
+	f := newFixture(t, Options{})
+	f.faults.fail("k", errPrecondition)
+	results := f.publish("k", "m1", "m2", "m3")
+	close(f.faults.release)
+
+	resume := f.timers.next(t)
+	got := f.p.Stats()["k"]
+	if !got.Paused || got.Queued != 3 || got.InFlight != 0 || got.Failures != 1 || status.Code(got.LastError) != codes.FailedPrecondition {
+		t.Errorf("Stats()[k] = %+v, want paused with 3 queued after 1 failure", got)
+	}
+
+	// New messages wait behind the failed ones; other keys are unaffected.
+	results = append(results, f.publish("k", "m4")...)
+	if got := f.p.Stats()["k"].Queued; got != 4 {
+		t.Errorf("Queued = %d after publishing to a paused key, want 4", got)
+	}
+	if err := get(t, f.publish("other", "o1")[0]); err != nil {
+		t.Errorf("publishing to another key: %v", err)
+	}
+
+	resume()
+	f.flush(t)
+	for i, r := range results {
+		if err := get(t, r); err != nil {
+			t.Errorf("message %d: %v", i+1, err)
+		}
+	}
+	if diff := cmp.Diff([]string{"m1", "m2", "m3", "m4"}, f.published("k")); diff != "" {
+		t.Errorf("published messages mismatch (-want +got):\n%s", diff)
+	}
+	if len(f.p.Stats()) != 0 {
+		t.Errorf("Stats() = %v after a flush, want no keys", f.p.Stats())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	f := newFixture(t, Options{InitialBackoff: time.Second, Multiplier: 2, MaxBackoff: 3 * time.Second})
+	f.faults.fail("k", errPrecondition, errPrecondition, errPrecondition)
+	close(f.faults.release)
+	r := f.publish("k", "m1")[0]
+	for i := 0; i < 3; i++ {
+		f.timers.fire(t)
+	}
+	if err := get(t, r); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	if diff := cmp.Diff(want, f.timers.delays); diff != "" {
+		t.Errorf("backoffs mismatch (-want +got):\n%s", diff)
+	}
+
+	// Success resets the backoff.
+	f.faults.fail("k", errPrecondition)
+	r = f.publish("k", "m2")[0]
+	f.timers.fire(t)
+	if err := get(t, r); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := f.timers.delays[3]; got != time.Second {
+		t.Errorf("backoff after a success = %v, want 1s", got)
+	}
+}
+
+func TestRejectPolicy(t *testing.T) {
+	f := newFixture(t, Options{Policy: Reject})
+	f.faults.fail("k", errPrecondition)
+	close(f.faults.release)
+	r1 := f.publish("k", "m1")[0]
+	resume := f.timers.next(t)
+
+	err := get(t, f.publish("k", "m2")[0])
+	if !errors.Is(err, ErrKeyPaused) || !strings.Contains(err.Error(), "injected") {
+		t.Errorf("publishing to a paused key got %v, want ErrKeyPaused with the cause", err)
+	}
+	resume()
+	if err := get(t, r1); err != nil {
+		t.Errorf("failed message after resume: %v", err)
+	}
+	if diff := cmp.Diff([]string{"m1"}, f.published("k")); diff != "" {
+		t.Errorf("published messages mismatch (-want +got):\n%s", diff)
+	}
+	if got := counter(t, f.reader, "pubsub_ordered_key_rejected_count", attribute.String("reason", "paused")); got != 1 {
+		t.Errorf("pubsub_ordered_key_rejected_count{reason=paused} = %d, want 1", got)
+	}
+}
+
+func TestBufferFull(t *testing.T) {
+	f := newFixture(t, Options{MaxBuffered: 2})
+	f.faults.fail("k", errPrecondition)
+	f.publish("k", "m1", "m2")
+	close(f.faults.release)
+	resume := f.timers.next(t)
+
+	if err := get(t, f.publish("k", "m3")[0]); !errors.Is(err, ErrBufferFull) {
+		t.Errorf("publishing to a full key got %v, want ErrBufferFull", err)
+	}
+	resume()
+	f.flush(t)
+	if diff := cmp.Diff([]string{"m1", "m2"}, f.published("k")); diff != "" {
+		t.Errorf("published messages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPermanentFailure(t *testing.T) {
+	f := newFixture(t, Options{})
+	f.faults.fail("k", status.Error(codes.InvalidArgument, "too large"))
+	results := f.publish("k", "m1", "m2")
+	close(f.faults.release)
+
+	if err := get(t, results[0]); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("m1 got %v, want InvalidArgument", err)
+	}
+	if err := get(t, results[1]); err == nil || !strings.Contains(err.Error(), "an earlier message failed") {
+		t.Errorf("m2 got %v, want it failed behind m1", err)
+	}
+	f.flush(t)
+	if len(f.timers.delays) != 0 {
+		t.Errorf("scheduled resumes %v, want none", f.timers.delays)
+	}
+
+	// The key is usable again.
+	if err := get(t, f.publish("k", "m3")[0]); err != nil {
+		t.Fatalf("m3: %v", err)
+	}
+	if diff := cmp.Diff([]string{"m3"}, f.published("k")); diff != "" {
+		t.Errorf("published messages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMaxAttempts(t *testing.T) {
+	f := newFixture(t, Options{MaxAttempts: 2})
+	f.faults.fail("k", errPrecondition, errPrecondition)
+	results := f.publish("k", "m1", "m2")
+	close(f.faults.release)
+	f.timers.fire(t)
+
+	if err := get(t, results[0]); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("m1 got %v, want FailedPrecondition after 2 attempts", err)
+	}
+	if err := get(t, results[1]); err == nil || !strings.Contains(err.Error(), "an earlier message failed") {
+		t.Errorf("m2 got %v, want it failed behind m1", err)
+	}
+	if got := f.published("k"); len(got) != 0 {
+		t.Errorf("published %v, want nothing", got)
+	}
+}
+
+func TestQueueMetrics(t *testing.T) {
+	f := newFixture(t, Options{})
+	f.faults.fail("k", errPrecondition)
+	f.publish("k", "m1", "m2", "m3")
+	close(f.faults.release)
+	resume := f.timers.next(t)
+
+	key := attribute.String("ordering_key", "k")
+	for name, want := range map[string]int64{
+		"pubsub_ordered_key_queued":    3,
+		"pubsub_ordered_key_in_flight": 0,
+		"pubsub_ordered_key_paused":    1,
+	} {
+		if got := gauge(t, f.reader, name, key); got != want {
+			t.Errorf("%s{ordering_key=k} = %d, want %d", name, got, want)
+		}
+	}
+
+	resume()
+	f.flush(t)
+	if got := counter(t, f.reader, "pubsub_ordered_key_resume_count", key); got != 1 {
+		t.Errorf("pubsub_ordered_key_resume_count{ordering_key=k} = %d, want 1", got)
+	}
+	if got := gauge(t, f.reader, "pubsub_ordered_key_queued", key); got != -1 {
+		t.Errorf("pubsub_ordered_key_queued{ordering_key=k} = %d after a flush, want no data point", got)
+	}
+}
+
+func TestStop(t *testing.T) {
+	f := newFixture(t, Options{})
+	f.faults.fail("k", errPrecondition)
+	results := f.publish("k", "m1", "m2")
+	close(f.faults.release)
+	f.timers.next(t)
+
+	f.p.Stop()
+	for i, r := range results {
+		if err := get(t, r); !errors.Is(err, ErrStopped) {
+			t.Errorf("message %d got %v, want ErrStopped", i+1, err)
+		}
+	}
+	f.flush(t)
+	if err := get(t, f.publish("k", "m3")[0]); !errors.Is(err, ErrStopped) {
+		t.Errorf("publishing after Stop got %v, want ErrStopped", err)
+	}
+}
+
+func TestWithoutOrderingKey(t *testing.T) {
+	f := newFixture(t, Options{})
+	r := f.p.Publish(context.Background(), &pubsub.Message{Data: []byte("plain")})
+	if err := get(t, r); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(f.p.Stats()) != 0 {
+		t.Errorf("Stats() = %v, want no keys", f.p.Stats())
+	}
+}
+
+func collect(t *testing.T, reader *sdkmetric.ManualReader) metricdata.ResourceMetrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	return rm
+}
+
+func counter(t *testing.T, reader *sdkmetric.ManualReader, name string, attr attribute.KeyValue) int64 {
+	t.Helper()
+	var total int64
+	for _, sm := range collect(t, reader).ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == name {
+				for _, dp := range sum.DataPoints {
+					if v, ok := dp.Attributes.Value(attr.Key); ok && v == attr.Value {
+						total += dp.Value
+					}
+				}
+			}
+		}
+	}
+	return total
+}
+
+// gauge returns the value of the gauge name for attr, or -1 if it has no
+// data point.
+func gauge(t *testing.T, reader *sdkmetric.ManualReader, name string, attr attribute.KeyValue) int64 {
+	t.Helper()
+	for _, sm := range collect(t, reader).ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if g, ok := m.Data.(metricdata.Gauge[int64]); ok && m.Name == name {
+				for _, dp := range g.DataPoints {
+					if v, ok := dp.Attributes.Value(attr.Key); ok && v == attr.Value {
+						return dp.Value
+					}
+				}
+			}
+		}
+	}
+	return -1
+}
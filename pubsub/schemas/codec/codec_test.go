@@ -0,0 +1,432 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	statepb "github.com/GoogleCloudPlatform/golang-samples/internal/pubsub/schemas"
+)
+
+const (
+	avroSchema  = "projects/p/schemas/us-states-avro"
+	protoSchema = "projects/p/schemas/us-states-proto"
+)
+
+// state is a record of the us-states Avro schemas.
+type state struct {
+	Name       string `json:"name"`
+	PostAbbr   string `json:"post_abbr"`
+	Population int64  `json:"population,omitempty"`
+}
+
+// newRegistry returns a registry with two revisions of each us-states
+// schema, loaded from the sample's schema files: r1 with a name and a
+// postal abbreviation, and r2 that adds a population.
+func newRegistry(t *testing.T) *MemoryRegistry {
+	t.Helper()
+	reg := &MemoryRegistry{}
+	for _, f := range []struct {
+		name, rev, path string
+		typ             pubsubpb.Schema_Type
+	}{
+		{avroSchema, "r1", "../resources/us-states.avsc", pubsubpb.Schema_AVRO},
+		{avroSchema, "r2", "../resources/us-states-plus.avsc", pubsubpb.Schema_AVRO},
+		{protoSchema, "r1", "../resources/us-states.proto", pubsubpb.Schema_PROTOCOL_BUFFER},
+		{protoSchema, "r2", "../resources/us-states-plus.proto", pubsubpb.Schema_PROTOCOL_BUFFER},
+	} {
+		if _, err := reg.AddFile(f.name, f.rev, f.typ, f.path); err != nil {
+			t.Fatalf("AddFile(%q): %v", f.path, err)
+		}
+	}
+	return reg
+}
+
+func reader(t *testing.T, reg Registry, name, rev string) *pubsubpb.Schema {
+	t.Helper()
+	s, err := reg.Schema(context.Background(), name, rev)
+	if err != nil {
+		t.Fatalf("Schema(%q, %q): %v", name, rev, err)
+	}
+	return s
+}
+
+func encoder(t *testing.T, reg Registry, name, rev string, enc pubsubpb.Encoding) *Encoder {
+	t.Helper()
+	e, err := NewEncoder(context.Background(), reg, &pubsubpb.SchemaSettings{Schema: name, Encoding: enc, LastRevisionId: rev})
+	if err != nil {
+		t.Fatalf("NewEncoder: %v", err)
+	}
+	return e
+}
+
+// message returns a message as Pub/Sub delivers it for a topic with the
+// given schema revision.
+func message(name, rev string, enc pubsubpb.Encoding, data []byte) *pubsub.Message {
+	return &pubsub.Message{ID: "m1", Data: data, Attributes: map[string]string{
+		AttrSchemaName: name,
+		AttrRevisionID: rev,
+		AttrEncoding:   enc.String(),
+	}}
+}
+
+var encodings = []pubsubpb.Encoding{pubsubpb.Encoding_JSON, pubsubpb.Encoding_BINARY}
+
+func TestAvroRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry(t)
+	for _, enc := range encodings {
+		t.Run(enc.String(), func(t *testing.T) {
+			e := encoder(t, reg, avroSchema, "r2", enc)
+			d, err := NewDecoder(ctx, reg, reader(t, reg, avroSchema, "r2"))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+			for _, v := range []any{
+				state{Name: "Alaska", PostAbbr: "AK", Population: 733391},
+				map[string]any{"name": "Alaska", "post_abbr": "AK", "population": int64(733391)},
+			} {
+				data, err := e.Encode(v)
+				if err != nil {
+					t.Fatalf("Encode(%v): %v", v, err)
+				}
+				var got state
+				if err := d.Decode(ctx, message(avroSchema, "r2", enc, data), &got); err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				if want := (state{Name: "Alaska", PostAbbr: "AK", Population: 733391}); got != want {
+					t.Errorf("Decode got %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAvroJSONEncoding(t *testing.T) {
+	e := encoder(t, newRegistry(t), avroSchema, "r1", pubsubpb.Encoding_JSON)
+	data, err := e.Encode(state{Name: "Alaska", PostAbbr: "AK"})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Encode got %s, not JSON: %v", data, err)
+	}
+	if diff := cmp.Diff(map[string]any{"name": "Alaska", "post_abbr": "AK"}, got); diff != "" {
+		t.Errorf("Encode mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAvroInvalidMessage(t *testing.T) {
+	e := encoder(t, newRegistry(t), avroSchema, "r2", pubsubpb.Encoding_BINARY)
+	for _, v := range []any{
+		map[string]any{"name": "Alaska"},
+		map[string]any{"name": "Alaska", "post_abbr": 7},
+		struct {
+			Name string `json:"name"`
+		}{"Alaska"},
+	} {
+		if _, err := e.Encode(v); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("Encode(%v) got %v, want ErrInvalidMessage", v, err)
+		}
+	}
+}
+
+func TestAvroRevisions(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry(t)
+	v1, err := encoder(t, reg, avroSchema, "r1", pubsubpb.Encoding_BINARY).Encode(state{Name: "Alaska", PostAbbr: "AK"})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	v2, err := encoder(t, reg, avroSchema, "r2", pubsubpb.Encoding_BINARY).Encode(state{Name: "Alaska", PostAbbr: "AK", Population: 733391})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		reader *pubsubpb.Schema
+		rev    string
+		data   []byte
+		want   map[string]any
+	}{
+		{"old revision gets defaults", reader(t, reg, avroSchema, "r2"), "r1", v1,
+			map[string]any{"name": "Alaska", "post_abbr": "AK", "population": int64(0)}},
+		{"new revision drops fields", reader(t, reg, avroSchema, "r1"), "r2", v2,
+			map[string]any{"name": "Alaska", "post_abbr": "AK"}},
+		{"no reader decodes as written", nil, "r2", v2,
+			map[string]any{"name": "Alaska", "post_abbr": "AK", "population": int64(733391)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDecoder(ctx, reg, tc.reader)
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+			var got map[string]any
+			if err := d.Decode(ctx, message(avroSchema, tc.rev, pubsubpb.Encoding_BINARY, tc.data), &got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Decode mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAvroIncompatibleRevisions(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry(t)
+	reg.Add(&pubsubpb.Schema{Name: avroSchema, RevisionId: "r3", Type: pubsubpb.Schema_AVRO, Definition: `{
+		"type": "record", "name": "State", "namespace": "utilities",
+		"fields": [{"name": "name", "type": "string"}, {"name": "post_abbr", "type": "int"}]
+	}`})
+	reg.Add(&pubsubpb.Schema{Name: avroSchema, RevisionId: "r4", Type: pubsubpb.Schema_AVRO, Definition: `{
+		"type": "record", "name": "State", "namespace": "utilities",
+		"fields": [{"name": "name", "type": "string"}, {"name": "post_abbr", "type": "string"}, {"name": "capital", "type": "string"}]
+	}`})
+	r3, err := encoder(t, reg, avroSchema, "r3", pubsubpb.Encoding_BINARY).Encode(map[string]any{"name": "Alaska", "post_abbr": 2})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	r1, err := encoder(t, reg, avroSchema, "r1", pubsubpb.Encoding_BINARY).Encode(state{Name: "Alaska", PostAbbr: "AK"})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		reader     string
+		rev        string
+		data       []byte
+		wantReason string
+	}{
+		{"changed type", "r1", "r3", r3, `field "post_abbr" is "int", the reader expects "string"`},
+		{"missing field without default", "r4", "r1", r1, `field "capital" is missing and the reader has no default for it`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDecoder(ctx, reg, reader(t, reg, avroSchema, tc.reader))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+			var got map[string]any
+			err = d.Decode(ctx, message(avroSchema, tc.rev, pubsubpb.Encoding_BINARY, tc.data), &got)
+			if !errors.Is(err, ErrIncompatibleRevision) {
+				t.Fatalf("Decode got %v, want ErrIncompatibleRevision", err)
+			}
+			for _, want := range []string{avroSchema + "@" + tc.rev, tc.wantReason} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Decode error %q doesn't mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry(t)
+	want := &statepb.State{Name: "Alaska", PostAbbr: "AK"}
+	d, err := NewDecoder(ctx, reg, nil)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	for _, enc := range encodings {
+		t.Run(enc.String(), func(t *testing.T) {
+			data, err := encoder(t, reg, protoSchema, "r1", enc).Encode(want)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			// The order of the encoded fields isn't deterministic, so the
+			// encoded message is compared rather than its bytes.
+			if enc == pubsubpb.Encoding_BINARY {
+				encoded := &statepb.State{}
+				if err := proto.Unmarshal(data, encoded); err != nil {
+					t.Errorf("Encode got %x, not a State: %v", data, err)
+				} else if !proto.Equal(want, encoded) {
+					t.Errorf("Encode got %v, want %v", encoded, want)
+				}
+			} else if err := protojson.Unmarshal(data, &statepb.State{}); err != nil {
+				t.Errorf("Encode got %s, not protobuf JSON: %v", data, err)
+			}
+
+			got := &statepb.State{}
+			if err := d.Decode(ctx, message(protoSchema, "r1", enc, data), got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("Decode mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProtoInvalidMessage(t *testing.T) {
+	e := encoder(t, newRegistry(t), protoSchema, "r1", pubsubpb.Encoding_BINARY)
+	// Field 1 of a Duration is an int64; the schema's field 1 is a string.
+	if _, err := e.Encode(durationpb.New(5)); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Encode(Duration) got %v, want ErrInvalidMessage", err)
+	}
+	if _, err := e.Encode(state{Name: "Alaska"}); err == nil || !strings.Contains(err.Error(), "needs a proto.Message") {
+		t.Errorf("Encode(struct) got %v, want an error asking for a proto.Message", err)
+	}
+}
+
+func TestProtoRevisions(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry(t)
+	reg.Add(&pubsubpb.Schema{Name: protoSchema, RevisionId: "r3", Type: pubsubpb.Schema_PROTOCOL_BUFFER, Definition: `
+		syntax = "proto3";
+		package utilities;
+		message State {
+		  string name = 1;
+		  int64 post_abbr = 2;
+		}`})
+	d, err := NewDecoder(ctx, reg, nil)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// A newer revision with an extra field decodes into the older type.
+	for _, enc := range encodings {
+		data, err := encoder(t, reg, protoSchema, "r2", enc).Encode(dynamicState(t, reg, "r2"))
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		got := &statepb.State{}
+		if err := d.Decode(ctx, message(protoSchema, "r2", enc, data), got); err != nil {
+			t.Fatalf("Decode(%v): %v", enc, err)
+		}
+		if got.Name != "Alaska" || got.PostAbbr != "AK" {
+			t.Errorf("Decode(%v) got %v, want Alaska AK", enc, got)
+		}
+	}
+
+	// A revision that changed a field's type doesn't.
+	err = d.Decode(ctx, message(protoSchema, "r3", pubsubpb.Encoding_BINARY, nil), &statepb.State{})
+	if !errors.Is(err, ErrIncompatibleRevision) {
+		t.Fatalf("Decode got %v, want ErrIncompatibleRevision", err)
+	}
+	if want := "field 2 (post_abbr) is int64, the reader expects string"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Decode error %q doesn't mention %q", err, want)
+	}
+}
+
+// dynamicState returns a message of revision rev of the proto schema with
+// all of its fields set.
+func dynamicState(t *testing.T, reg Registry, rev string) proto.Message {
+	t.Helper()
+	c, err := compile(context.Background(), reader(t, reg, protoSchema, rev))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	m := dynamicpb.NewMessage(c.desc)
+	if err := protojson.Unmarshal([]byte(`{"name": "Alaska", "postAbbr": "AK", "population": "733391"}`), m); err != nil {
+		t.Fatalf("protojson.Unmarshal: %v", err)
+	}
+	return m
+}
+
+func TestDecodeErrors(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry(t)
+	d, err := NewDecoder(ctx, reg, nil)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	var v map[string]any
+
+	if err := d.Decode(ctx, &pubsub.Message{ID: "m1", Data: []byte("{}")}, &v); !errors.Is(err, ErrNoSchema) {
+		t.Errorf("Decode without attributes got %v, want ErrNoSchema", err)
+	}
+	msg := message(avroSchema, "r1", pubsubpb.Encoding_JSON, []byte("{}"))
+	msg.Attributes[AttrEncoding] = "XML"
+	if err := d.Decode(ctx, msg, &v); err == nil || !strings.Contains(err.Error(), `unknown encoding "XML"`) {
+		t.Errorf("Decode with an unknown encoding got %v", err)
+	}
+	if err := d.Decode(ctx, message(avroSchema, "r9", pubsubpb.Encoding_JSON, []byte("{}")), &v); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Decode of an unknown revision got %v, want not found", err)
+	}
+	if err := d.Decode(ctx, message(avroSchema, "r1", pubsubpb.Encoding_JSON, []byte(`{"name": 1}`)), &v); err == nil || !strings.Contains(err.Error(), "decoding with "+avroSchema+"@r1") {
+		t.Errorf("Decode of bad data got %v", err)
+	}
+}
+
+// countingRegistry counts lookups.
+type countingRegistry struct {
+	Registry
+	lookups int
+}
+
+func (r *countingRegistry) Schema(ctx context.Context, name, revisionID string) (*pubsubpb.Schema, error) {
+	r.lookups++
+	return r.Registry.Schema(ctx, name, revisionID)
+}
+
+func TestDecoderCachesRevisions(t *testing.T) {
+	ctx := context.Background()
+	reg := &countingRegistry{Registry: newRegistry(t)}
+	d, err := NewDecoder(ctx, reg, nil)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	data := map[string][]byte{}
+	for _, rev := range []string{"r1", "r2"} {
+		if data[rev], err = encoder(t, reg, avroSchema, rev, pubsubpb.Encoding_BINARY).Encode(state{Name: "Alaska", PostAbbr: "AK"}); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+	}
+	reg.lookups = 0
+	for i := 0; i < 3; i++ {
+		for _, rev := range []string{"r1", "r2"} {
+			var v map[string]any
+			if err := d.Decode(ctx, message(avroSchema, rev, pubsubpb.Encoding_BINARY, data[rev]), &v); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+		}
+	}
+	if reg.lookups != 2 {
+		t.Errorf("registry got %d lookups, want one per revision", reg.lookups)
+	}
+}
+
+func TestNewEncoderErrors(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry(t)
+	for _, s := range []*pubsubpb.SchemaSettings{
+		nil,
+		{Schema: avroSchema},
+		{Schema: "projects/p/schemas/missing", Encoding: pubsubpb.Encoding_JSON},
+	} {
+		if _, err := NewEncoder(ctx, reg, s); err == nil {
+			t.Errorf("NewEncoder(%v) succeeded, want an error", s)
+		}
+	}
+}
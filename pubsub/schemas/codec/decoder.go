@@ -0,0 +1,231 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Decoder decodes messages written with any revision of a schema. It
+// caches the revisions it looks up and is safe for concurrent use.
+type Decoder struct {
+	reg    Registry
+	reader *compiled
+
+	mu        sync.Mutex
+	revisions map[string]*compiled
+	// compat caches whether revisions can be read as a reader schema or
+	// protocol buffer message type, keyed by revision and reader.
+	compat map[[2]string]error
+}
+
+// NewDecoder returns a Decoder that looks up revisions in reg.
+//
+// For Avro schemas, reader is the schema the subscriber expects, often
+// compiled into it from a local file. Messages written with other
+// revisions are resolved to it: fields it doesn't have are dropped and
+// fields it has but the revision doesn't get their defaults. With a nil
+// reader, messages are decoded as written.
+//
+// For protocol buffer schemas, messages are read as the proto.Message
+// passed to Decode and reader is ignored.
+func NewDecoder(ctx context.Context, reg Registry, reader *pubsubpb.Schema) (*Decoder, error) {
+	d := &Decoder{
+		reg:       reg,
+		revisions: make(map[string]*compiled),
+		compat:    make(map[[2]string]error),
+	}
+	if reader != nil && reader.Type == pubsubpb.Schema_AVRO {
+		c, err := compile(ctx, reader)
+		if err != nil {
+			return nil, fmt.Errorf("codec: %w", err)
+		}
+		d.reader = c
+	}
+	return d, nil
+}
+
+// Decode decodes msg into v. For Avro schemas, v is a *map[string]any or
+// a pointer to a value that the record's JSON encoding can be unmarshaled
+// into; for protocol buffer schemas it is a proto.Message.
+//
+// Decode returns an error wrapping ErrIncompatibleRevision if the message
+// was written with a revision that can't be read as the reader schema or
+// message type, and ErrNoSchema if msg has no schema attributes.
+func (d *Decoder) Decode(ctx context.Context, msg *pubsub.Message, v any) error {
+	name := msg.Attributes[AttrSchemaName]
+	revisionID := msg.Attributes[AttrRevisionID]
+	if name == "" {
+		return fmt.Errorf("codec: message %s: %w", msg.ID, ErrNoSchema)
+	}
+	var encoding pubsubpb.Encoding
+	switch e := msg.Attributes[AttrEncoding]; e {
+	case "JSON":
+		encoding = pubsubpb.Encoding_JSON
+	case "BINARY":
+		encoding = pubsubpb.Encoding_BINARY
+	default:
+		return fmt.Errorf("codec: message %s has unknown encoding %q", msg.ID, e)
+	}
+	w, err := d.revision(ctx, name, revisionID)
+	if err != nil {
+		return fmt.Errorf("codec: message %s: %w", msg.ID, err)
+	}
+	if w.avro != nil {
+		err = d.decodeAvro(w, msg.Data, encoding, v)
+	} else {
+		err = d.decodeProto(w, msg.Data, encoding, v)
+	}
+	if err != nil {
+		return fmt.Errorf("codec: message %s: %w", msg.ID, err)
+	}
+	return nil
+}
+
+// revision returns a compiled revision of a schema.
+func (d *Decoder) revision(ctx context.Context, name, revisionID string) (*compiled, error) {
+	key := name + "@" + revisionID
+	d.mu.Lock()
+	c, ok := d.revisions[key]
+	d.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	s, err := d.reg.Schema(ctx, name, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if c, err = compile(ctx, s); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.revisions[key] = c
+	return c, nil
+}
+
+// compatible returns the cached result of check for reading revision w as
+// reader.
+func (d *Decoder) compatible(w *compiled, reader string, check func() error) error {
+	key := [2]string{w.String(), reader}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err, ok := d.compat[key]
+	if !ok {
+		if err = check(); err != nil {
+			err = fmt.Errorf("%w: %s can't be read as %s: %v", ErrIncompatibleRevision, w, reader, err)
+		}
+		d.compat[key] = err
+	}
+	return err
+}
+
+func (d *Decoder) decodeAvro(w *compiled, data []byte, encoding pubsubpb.Encoding, v any) error {
+	var native any
+	var err error
+	if encoding == pubsubpb.Encoding_JSON {
+		native, _, err = w.avro.NativeFromTextual(data)
+	} else {
+		native, _, err = w.avro.NativeFromBinary(data)
+	}
+	if err != nil {
+		return fmt.Errorf("decoding with %s: %w", w, err)
+	}
+
+	if r := d.reader; r != nil && r.String() != w.String() {
+		if err := d.compatible(w, r.String(), func() error { return avroCompatible(w, r) }); err != nil {
+			return err
+		}
+		if native, err = resolveAvro(native, r); err != nil {
+			return fmt.Errorf("resolving %s to %s: %w", w, r, err)
+		}
+	}
+
+	if m, ok := v.(*map[string]any); ok {
+		record, ok := native.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s isn't a record", w)
+		}
+		*m = record
+		return nil
+	}
+	b, err := json.Marshal(native)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// resolveAvro converts a record read with a writer revision into a record
+// of the reader schema r. Fields r doesn't have are dropped; the reader's
+// codec fills in defaults for fields the writer didn't have.
+func resolveAvro(native any, r *compiled) (any, error) {
+	record, ok := native.(map[string]any)
+	if !ok || r.fields == nil {
+		return native, nil
+	}
+	out := make(map[string]any, len(r.order))
+	for _, name := range r.order {
+		if v, ok := record[name]; ok {
+			out[name] = v
+		}
+	}
+	b, err := r.avro.BinaryFromNative(nil, out)
+	if err != nil {
+		return nil, err
+	}
+	native, _, err = r.avro.NativeFromBinary(b)
+	return native, err
+}
+
+func (d *Decoder) decodeProto(w *compiled, data []byte, encoding pubsubpb.Encoding, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%s needs a proto.Message, got %T", w, v)
+	}
+	reader := m.ProtoReflect().Descriptor()
+	if err := d.compatible(w, string(reader.FullName()), func() error { return protoCompatible(w.desc, reader) }); err != nil {
+		return err
+	}
+
+	// Decode with the writer's revision, then convert to the reader's type
+	// through the binary encoding, which matches fields by number.
+	dyn := dynamicpb.NewMessage(w.desc)
+	var err error
+	if encoding == pubsubpb.Encoding_JSON {
+		err = protojson.Unmarshal(data, dyn)
+	} else {
+		err = proto.Unmarshal(data, dyn)
+	}
+	if err != nil {
+		return fmt.Errorf("decoding with %s: %w", w, err)
+	}
+	b, err := proto.Marshal(dyn)
+	if err != nil {
+		return err
+	}
+	proto.Reset(m)
+	return proto.Unmarshal(b, m)
+}
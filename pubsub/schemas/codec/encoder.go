@@ -0,0 +1,187 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package codec encodes and decodes Pub/Sub messages of topics with Avro or
+// protocol buffer schemas.
+//
+// An Encoder checks outgoing messages against the topic's schema and
+// encodes them with the topic's JSON or binary encoding. A Decoder reads
+// the schema attributes Pub/Sub adds to delivered messages, looks up the
+// revision the message was written with and decodes it, also when that is
+// an older or newer revision than the one the subscriber knows.
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Attributes Pub/Sub adds to messages published to a topic with a schema.
+const (
+	AttrSchemaName = "googclient_schemaname"
+	AttrRevisionID = "googclient_schemarevisionid"
+	AttrEncoding   = "googclient_schemaencoding"
+)
+
+var (
+	// ErrInvalidMessage means a message doesn't match the schema.
+	ErrInvalidMessage = errors.New("message doesn't match the schema")
+	// ErrIncompatibleRevision means a message was written with a schema
+	// revision the reader can't read.
+	ErrIncompatibleRevision = errors.New("incompatible schema revision")
+	// ErrNoSchema means a message has no schema attributes.
+	ErrNoSchema = errors.New("message has no schema attributes")
+)
+
+// Encoder encodes messages for a topic.
+type Encoder struct {
+	schema   *compiled
+	encoding pubsubpb.Encoding
+}
+
+// NewEncoder returns an Encoder for a topic with the given schema settings,
+// as returned in Topic.SchemaSettings. Messages are checked against the
+// newest revision the topic accepts.
+func NewEncoder(ctx context.Context, reg Registry, settings *pubsubpb.SchemaSettings) (*Encoder, error) {
+	if settings.GetSchema() == "" {
+		return nil, errors.New("codec: topic has no schema")
+	}
+	switch settings.Encoding {
+	case pubsubpb.Encoding_JSON, pubsubpb.Encoding_BINARY:
+	default:
+		return nil, fmt.Errorf("codec: unsupported encoding %v", settings.Encoding)
+	}
+	s, err := reg.Schema(ctx, settings.Schema, settings.LastRevisionId)
+	if err != nil {
+		return nil, fmt.Errorf("codec: %w", err)
+	}
+	c, err := compile(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("codec: %w", err)
+	}
+	return &Encoder{schema: c, encoding: settings.Encoding}, nil
+}
+
+// Encoding returns the encoding of the topic.
+func (e *Encoder) Encoding() pubsubpb.Encoding {
+	return e.encoding
+}
+
+// Encode checks v against the schema and returns the encoded message data.
+// Values for Avro schemas are either the map[string]any that goavro uses
+// or values, such as structs, whose JSON encoding is the Avro JSON
+// encoding. Values for protocol buffer schemas are proto.Messages whose
+// fields match the schema by number and type.
+func (e *Encoder) Encode(v any) ([]byte, error) {
+	if e.schema.avro != nil {
+		return e.encodeAvro(v)
+	}
+	return e.encodeProto(v)
+}
+
+func (e *Encoder) encodeAvro(v any) ([]byte, error) {
+	native, ok := v.(map[string]any)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("codec: %w", err)
+		}
+		n, _, err := e.schema.avro.NativeFromTextual(b)
+		if err != nil {
+			return nil, fmt.Errorf("codec: %w for %s: %v", ErrInvalidMessage, e.schema, err)
+		}
+		native = n.(map[string]any)
+	}
+	var b []byte
+	var err error
+	if e.encoding == pubsubpb.Encoding_JSON {
+		b, err = e.schema.avro.TextualFromNative(nil, native)
+	} else {
+		b, err = e.schema.avro.BinaryFromNative(nil, native)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("codec: %w for %s: %v", ErrInvalidMessage, e.schema, err)
+	}
+	return b, nil
+}
+
+func (e *Encoder) encodeProto(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("codec: schema %s needs a proto.Message, got %T", e.schema, v)
+	}
+	// Round trip through a message of the schema, so that fields the schema
+	// doesn't have, or has with other types, show up as unknown fields.
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("codec: %w", err)
+	}
+	dyn := dynamicpb.NewMessage(e.schema.desc)
+	if err := proto.Unmarshal(b, dyn); err != nil {
+		return nil, fmt.Errorf("codec: %w for %s: %v", ErrInvalidMessage, e.schema, err)
+	}
+	if path := unknownField(dyn, ""); path != "" {
+		return nil, fmt.Errorf("codec: %w for %s: %s has fields the schema doesn't define or defines with other types", ErrInvalidMessage, e.schema, path)
+	}
+	if e.encoding == pubsubpb.Encoding_JSON {
+		b, err = protojson.Marshal(dyn)
+	} else {
+		b, err = proto.Marshal(dyn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("codec: %w", err)
+	}
+	return b, nil
+}
+
+// unknownField returns the path of the first message within m that has
+// unknown fields, or "" if there is none.
+func unknownField(m protoreflect.Message, path string) string {
+	if len(m.GetUnknown()) > 0 {
+		if path == "" {
+			return string(m.Descriptor().FullName())
+		}
+		return path
+	}
+	var found string
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		if path != "" {
+			name = path + "." + name
+		}
+		switch {
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				found = unknownField(mv.Message(), fmt.Sprintf("%s[%v]", name, k.Interface()))
+				return found == ""
+			})
+		case fd.IsList() && fd.Message() != nil:
+			for i := 0; i < v.List().Len() && found == ""; i++ {
+				found = unknownField(v.List().Get(i).Message(), fmt.Sprintf("%s[%d]", name, i))
+			}
+		case !fd.IsMap() && !fd.IsList() && fd.Message() != nil:
+			found = unknownField(v.Message(), name)
+		}
+		return found == ""
+	})
+	return found
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	schema "cloud.google.com/go/pubsub/v2/apiv1"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Registry looks up schema revisions.
+type Registry interface {
+	// Schema returns a revision of the schema with the given full name,
+	// such as "projects/my-project/schemas/my-schema". An empty
+	// revisionID means the latest revision.
+	Schema(ctx context.Context, name, revisionID string) (*pubsubpb.Schema, error)
+}
+
+// ServiceRegistry looks up schema revisions with the Pub/Sub schema
+// service.
+type ServiceRegistry struct {
+	Client *schema.SchemaClient
+}
+
+// Schema implements Registry.
+func (r ServiceRegistry) Schema(ctx context.Context, name, revisionID string) (*pubsubpb.Schema, error) {
+	if revisionID != "" {
+		name += "@" + revisionID
+	}
+	s, err := r.Client.GetSchema(ctx, &pubsubpb.GetSchemaRequest{Name: name, View: pubsubpb.SchemaView_FULL})
+	if err != nil {
+		return nil, fmt.Errorf("GetSchema(%q): %w", name, err)
+	}
+	return s, nil
+}
+
+// MemoryRegistry holds schema revisions in memory, for example ones loaded
+// from local schema files. It is safe for concurrent use.
+type MemoryRegistry struct {
+	mu        sync.Mutex
+	revisions map[string][]*pubsubpb.Schema
+}
+
+// Add adds a revision of a schema. Revisions added later are newer.
+func (r *MemoryRegistry) Add(s *pubsubpb.Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.revisions == nil {
+		r.revisions = make(map[string][]*pubsubpb.Schema)
+	}
+	r.revisions[s.Name] = append(r.revisions[s.Name], s)
+}
+
+// AddFile adds a revision of a schema with the definition in the file at
+// path.
+func (r *MemoryRegistry) AddFile(name, revisionID string, typ pubsubpb.Schema_Type, path string) (*pubsubpb.Schema, error) {
+	def, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &pubsubpb.Schema{Name: name, RevisionId: revisionID, Type: typ, Definition: string(def)}
+	r.Add(s)
+	return s, nil
+}
+
+// Schema implements Registry. Unknown schemas and revisions are NotFound
+// errors, like the schema service returns.
+func (r *MemoryRegistry) Schema(_ context.Context, name, revisionID string) (*pubsubpb.Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	revs := r.revisions[name]
+	if len(revs) == 0 {
+		return nil, status.Errorf(codes.NotFound, "schema %q not found", name)
+	}
+	if revisionID == "" {
+		return revs[len(revs)-1], nil
+	}
+	for _, s := range revs {
+		if s.RevisionId == revisionID {
+			return s, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "schema %q not found", name+"@"+revisionID)
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"testing"
+
+	schema "cloud.google.com/go/pubsub/v2/apiv1"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestServiceRegistry(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	defer srv.Close()
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	client, err := schema.NewSchemaClient(ctx, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewSchemaClient: %v", err)
+	}
+	defer client.Close()
+
+	local := newRegistry(t)
+	r1, err := client.CreateSchema(ctx, &pubsubpb.CreateSchemaRequest{
+		Parent:   "projects/p",
+		SchemaId: "us-states-avro",
+		Schema:   reader(t, local, avroSchema, "r1"),
+	})
+	if err != nil {
+		t.Fatalf("CreateSchema: %v", err)
+	}
+	r2, err := client.CommitSchema(ctx, &pubsubpb.CommitSchemaRequest{
+		Name:   avroSchema,
+		Schema: reader(t, local, avroSchema, "r2"),
+	})
+	if err != nil {
+		t.Fatalf("CommitSchema: %v", err)
+	}
+
+	reg := ServiceRegistry{Client: client}
+	for _, tc := range []struct {
+		rev  string
+		want *pubsubpb.Schema
+	}{
+		{r1.RevisionId, r1},
+		{r2.RevisionId, r2},
+		{"", r2},
+	} {
+		got, err := reg.Schema(ctx, avroSchema, tc.rev)
+		if err != nil {
+			t.Fatalf("Schema(%q): %v", tc.rev, err)
+		}
+		if got.RevisionId != tc.want.RevisionId || got.Definition != tc.want.Definition {
+			t.Errorf("Schema(%q) got revision %q, want %q", tc.rev, got.RevisionId, tc.want.RevisionId)
+		}
+	}
+	if _, err := reg.Schema(ctx, avroSchema, "missing"); status.Code(err) != codes.NotFound {
+		t.Errorf("Schema(missing) got %v, want NotFound", err)
+	}
+}
+
+func TestMemoryRegistry(t *testing.T) {
+	ctx := context.Background()
+	reg := newRegistry(t)
+	if got := reader(t, reg, avroSchema, "").RevisionId; got != "r2" {
+		t.Errorf("latest revision = %q, want r2", got)
+	}
+	if got := reader(t, reg, protoSchema, "r1").RevisionId; got != "r1" {
+		t.Errorf("revision = %q, want r1", got)
+	}
+	for _, tc := range [][2]string{{avroSchema, "r9"}, {"projects/p/schemas/missing", ""}} {
+		if _, err := reg.Schema(ctx, tc[0], tc[1]); status.Code(err) != codes.NotFound {
+			t.Errorf("Schema(%q, %q) got %v, want NotFound", tc[0], tc[1], err)
+		}
+	}
+	if _, err := reg.AddFile(avroSchema, "r3", pubsubpb.Schema_AVRO, "testdata/missing.avsc"); err == nil {
+		t.Error("AddFile of a missing file succeeded, want an error")
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/bufbuild/protocompile"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// compiled is a schema revision ready to encode and decode messages.
+type compiled struct {
+	schema *pubsubpb.Schema
+
+	// Avro schemas.
+	avro *goavro.Codec
+	// fields are the fields of an Avro record by name, in order.
+	fields map[string]avroField
+	order  []string
+
+	// Protocol buffer schemas.
+	desc protoreflect.MessageDescriptor
+}
+
+type avroField struct {
+	typ        string // the field's type as canonical JSON
+	hasDefault bool
+}
+
+func (c *compiled) String() string {
+	return c.schema.Name + "@" + c.schema.RevisionId
+}
+
+func compile(ctx context.Context, s *pubsubpb.Schema) (*compiled, error) {
+	c := &compiled{schema: s}
+	var err error
+	switch s.Type {
+	case pubsubpb.Schema_AVRO:
+		err = c.compileAvro()
+	case pubsubpb.Schema_PROTOCOL_BUFFER:
+		c.desc, err = compileProto(ctx, s.Definition)
+	default:
+		err = fmt.Errorf("unsupported schema type %v", s.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("compiling schema %s: %w", c, err)
+	}
+	return c, nil
+}
+
+func (c *compiled) compileAvro() error {
+	codec, err := goavro.NewCodec(c.schema.Definition)
+	if err != nil {
+		return err
+	}
+	c.avro = codec
+
+	var record struct {
+		Type   any `json:"type"`
+		Fields []struct {
+			Name    string          `json:"name"`
+			Type    any             `json:"type"`
+			Default json.RawMessage `json:"default"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(c.schema.Definition), &record); err != nil || record.Type != "record" {
+		// Not a record; revisions are compared as a whole.
+		return nil
+	}
+	c.fields = make(map[string]avroField)
+	for _, f := range record.Fields {
+		c.fields[f.Name] = avroField{typ: canonicalAvroType(f.Type), hasDefault: f.Default != nil}
+		c.order = append(c.order, f.Name)
+	}
+	return nil
+}
+
+// canonicalAvroType returns t as JSON, with {"type": "long"} and "long"
+// alike.
+func canonicalAvroType(t any) string {
+	if m, ok := t.(map[string]any); ok && len(m) == 1 {
+		if name, ok := m["type"].(string); ok {
+			t = name
+		}
+	}
+	b, _ := json.Marshal(t)
+	return string(b)
+}
+
+// compileProto returns the first message defined in def, which is the one
+// Pub/Sub validates messages against.
+func compileProto(ctx context.Context, def string) (protoreflect.MessageDescriptor, error) {
+	c := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{"schema.proto": def}),
+		}),
+	}
+	files, err := c.Compile(ctx, "schema.proto")
+	if err != nil {
+		return nil, err
+	}
+	msgs := files[0].Messages()
+	if msgs.Len() == 0 {
+		return nil, fmt.Errorf("no message defined")
+	}
+	return msgs.Get(0), nil
+}
+
+// avroPromotions lists the writer types that Avro schema resolution lets a
+// reader read as another type.
+var avroPromotions = map[string][]string{
+	`"int"`:    {`"long"`, `"float"`, `"double"`},
+	`"long"`:   {`"float"`, `"double"`},
+	`"float"`:  {`"double"`},
+	`"string"`: {`"bytes"`},
+	`"bytes"`:  {`"string"`},
+}
+
+// avroCompatible reports why data written with w can't be read as r, or
+// returns nil if it can. Readers ignore fields they don't know and fill in
+// missing fields that have a default.
+func avroCompatible(w, r *compiled) error {
+	if w.fields == nil || r.fields == nil {
+		if w.avro.CanonicalSchema() != r.avro.CanonicalSchema() {
+			return fmt.Errorf("schema differs from the reader's")
+		}
+		return nil
+	}
+	var problems []string
+	for _, name := range r.order {
+		rf := r.fields[name]
+		wf, ok := w.fields[name]
+		switch {
+		case !ok && !rf.hasDefault:
+			problems = append(problems, fmt.Sprintf("field %q is missing and the reader has no default for it", name))
+		case ok && wf.typ != rf.typ && !promotable(wf.typ, rf.typ):
+			problems = append(problems, fmt.Sprintf("field %q is %s, the reader expects %s", name, wf.typ, rf.typ))
+		}
+	}
+	if problems != nil {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func promotable(from, to string) bool {
+	for _, t := range avroPromotions[from] {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// protoCompatible reports why data written with w can't be read as r, or
+// returns nil if it can. Fields are matched by number; readers keep fields
+// they don't know as unknown fields.
+func protoCompatible(w, r protoreflect.MessageDescriptor) error {
+	var problems []string
+	protoCompare(w, r, "", map[[2]protoreflect.FullName]bool{}, &problems)
+	if problems != nil {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func protoCompare(w, r protoreflect.MessageDescriptor, prefix string, seen map[[2]protoreflect.FullName]bool, problems *[]string) {
+	key := [2]protoreflect.FullName{w.FullName(), r.FullName()}
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+	fields := w.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		wf := fields.Get(i)
+		rf := r.Fields().ByNumber(wf.Number())
+		if rf == nil {
+			continue
+		}
+		name := prefix + string(rf.Name())
+		switch {
+		case wf.Kind() != rf.Kind():
+			*problems = append(*problems, fmt.Sprintf("field %d (%s) is %v, the reader expects %v", wf.Number(), name, wf.Kind(), rf.Kind()))
+		case wf.IsList() != rf.IsList() || wf.IsMap() != rf.IsMap():
+			*problems = append(*problems, fmt.Sprintf("field %d (%s) is %v, the reader expects %v", wf.Number(), name, cardinality(wf), cardinality(rf)))
+		case wf.Message() != nil:
+			protoCompare(wf.Message(), rf.Message(), name+".", seen, problems)
+		}
+	}
+}
+
+func cardinality(f protoreflect.FieldDescriptor) string {
+	switch {
+	case f.IsMap():
+		return "a map"
+	case f.IsList():
+		return "repeated"
+	}
+	return "singular"
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"go.opentelemetry.io/otel"
+
+	"github.com/GoogleCloudPlatform/golang-samples/pubsub/consumer"
+)
+
+// pullMsgsWithConsumer receives messages with the consumer package, which
+// adds panic recovery, logging, tracing and metrics around the handler and
+// dead-letters messages that keep failing.
+func pullMsgsWithConsumer(w io.Writer, projectID, subID, deadLetterTopicID string) error {
+	// projectID := "my-project-id"
+	// subID := "my-sub"
+	// deadLetterTopicID := "my-dead-letter-topic"
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("pubsub.NewClient: %w", err)
+	}
+	defer client.Close()
+
+	deadLetter := client.Publisher(deadLetterTopicID)
+	defer deadLetter.Stop()
+
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	metrics, err := consumer.Metrics(otel.GetMeterProvider())
+	if err != nil {
+		return err
+	}
+	c, err := consumer.New(consumer.Config{
+		Subscriber: client.Subscriber(subID),
+		Handler: func(_ context.Context, msg *pubsub.Message) error {
+			if len(msg.Data) == 0 {
+				// Redelivering an empty message won't help.
+				return consumer.Permanent(errors.New("empty message"))
+			}
+			fmt.Fprintf(w, "Got message: %q\n", string(msg.Data))
+			return nil
+		},
+		Middleware: []consumer.Middleware{
+			consumer.Recover(),
+			consumer.Logging(logger),
+			consumer.Tracing(otel.GetTracerProvider()),
+			metrics,
+		},
+		DeadLetter:          deadLetter,
+		MaxDeliveryAttempts: 5,
+		DrainTimeout:        10 * time.Second,
+		Logger:              logger,
+	})
+	if err != nil {
+		return fmt.Errorf("consumer.New: %w", err)
+	}
+
+	// Receive messages for 10 seconds, which simplifies testing.
+	// Comment this out in production, since `Run` should
+	// be used as a long running operation.
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := c.Run(ctx); err != nil {
+		return fmt.Errorf("consumer.Run: %w", err)
+	}
+	s := c.Stats()
+	fmt.Fprintf(w, "Acked %d, nacked %d and dead-lettered %d messages\n", s.Acked, s.Nacked, s.DeadLettered)
+	return nil
+}
@@ -634,6 +634,55 @@ func TestSubscribeOpenTelemetryTracing(t *testing.T) {
 	})
 }
 
+func TestPullMsgsWithConsumer(t *testing.T) {
+	ctx := context.Background()
+	buf := new(bytes.Buffer)
+
+	// Use the pstest fake with emulator settings.
+	srv := pstest.NewServer()
+	defer srv.Close()
+	t.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+	const projectID = "consumer-project"
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		t.Fatalf("pubsub.NewClient: %v", err)
+	}
+	defer client.Close()
+
+	topic := "projects/" + projectID + "/topics/consumer"
+	dlTopic := "projects/" + projectID + "/topics/consumer-dead-letter"
+	sub := "projects/" + projectID + "/subscriptions/consumer"
+	for _, name := range []string{topic, dlTopic} {
+		if err := createTopic(ctx, client, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := createSubscription(ctx, client, topic, sub); err != nil {
+		t.Fatal(err)
+	}
+	srv.Publish(topic, []byte("hello"), nil)
+	srv.Publish(topic, nil, nil)
+
+	if err := pullMsgsWithConsumer(buf, projectID, "consumer", "consumer-dead-letter"); err != nil {
+		t.Fatalf("pullMsgsWithConsumer: %v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{`Got message: "hello"`, "Acked 1, nacked 0 and dead-lettered 1 messages"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got %q, want it to contain %q", got, want)
+		}
+	}
+	var deadLettered int
+	for _, m := range srv.Messages() {
+		if m.Topic == dlTopic {
+			deadLettered++
+		}
+	}
+	if deadLettered != 1 {
+		t.Errorf("got %d dead-lettered messages, want 1", deadLettered)
+	}
+}
+
 func publishMsgs(ctx context.Context, p *pubsub.Publisher, numMsgs int) error {
 	var results []*pubsub.PublishResult
 	for i := 0; i < numMsgs; i++ {
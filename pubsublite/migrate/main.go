@@ -0,0 +1,258 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command migrate moves Pub/Sub Lite topics and subscriptions to Pub/Sub.
+//
+// It mirrors every Lite topic as a Pub/Sub topic and every Lite subscription
+// as a Pub/Sub subscription with message ordering enabled, then creates a
+// Lite export subscription per topic that forwards the retained backlog and
+// new messages to Pub/Sub:
+//
+//	migrate -project=my-project -region=us-central1 list
+//	migrate -project=my-project -region=us-central1 plan
+//	migrate -project=my-project -region=us-central1 -dry_run apply
+//	migrate -project=my-project -region=us-central1 apply
+//	migrate -project=my-project -region=us-central1 verify
+//	migrate -project=my-project -region=us-central1 rollback
+//
+// apply records what it created in the -state file. Running apply again
+// resumes from that file, verify compares message counts using it, and
+// rollback deletes exactly the resources it lists.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsublite"
+	vkit "cloud.google.com/go/pubsublite/apiv1"
+	"google.golang.org/api/option"
+)
+
+// errNoParity is returned by verify when a Pub/Sub topic is missing
+// messages retained by its Lite topic.
+var errNoParity = errors.New("Pub/Sub topics are missing exported messages")
+
+type config struct {
+	project       string
+	locations     []string
+	pubsubProject string
+	prefix        string
+	stateFile     string
+	dryRun        bool
+	idle          time.Duration
+}
+
+func main() {
+	project := flag.String("project", "", "Cloud project that owns the Pub/Sub Lite resources")
+	region := flag.String("region", "", "Pub/Sub Lite region, e.g. us-central1")
+	locations := flag.String("locations", "", "Comma-separated Lite locations to migrate (default: the region and its zones a, b and c)")
+	pubsubProject := flag.String("pubsub_project", "", "Cloud project for the Pub/Sub resources (default: -project)")
+	prefix := flag.String("prefix", "", "Prefix for Pub/Sub topic and subscription IDs")
+	stateFile := flag.String("state", "migration.json", "File recording the plan and the resources created by apply")
+	dryRun := flag.Bool("dry_run", false, "Print what apply or rollback would do without changing anything")
+	idle := flag.Duration("idle", 30*time.Second, "How long verify waits for more exported messages")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate [flags] list|plan|apply|verify|rollback")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *project == "" || *region == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *idle <= 0 {
+		fmt.Fprintf(os.Stderr, "-idle must be positive, got %v\n", *idle)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config{
+		project:       *project,
+		pubsubProject: *pubsubProject,
+		prefix:        *prefix,
+		stateFile:     *stateFile,
+		dryRun:        *dryRun,
+		idle:          *idle,
+	}
+	if cfg.pubsubProject == "" {
+		cfg.pubsubProject = cfg.project
+	}
+	if *locations != "" {
+		cfg.locations = strings.Split(*locations, ",")
+	} else {
+		cfg.locations = []string{*region, *region + "-a", *region + "-b", *region + "-c"}
+	}
+
+	ctx := context.Background()
+	c, err := newClients(ctx, *region, cfg.pubsubProject)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.close()
+
+	if err := run(ctx, os.Stdout, c, cfg, flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newClients(ctx context.Context, region, pubsubProject string, opts ...option.ClientOption) (*clients, error) {
+	lite, err := pubsublite.NewAdminClient(ctx, region, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("pubsublite.NewAdminClient: %w", err)
+	}
+	statsOpts := append([]option.ClientOption{option.WithEndpoint(region + "-pubsublite.googleapis.com:443")}, opts...)
+	stats, err := vkit.NewTopicStatsClient(ctx, statsOpts...)
+	if err != nil {
+		lite.Close()
+		return nil, fmt.Errorf("NewTopicStatsClient: %w", err)
+	}
+	ps, err := pubsub.NewClient(ctx, pubsubProject, opts...)
+	if err != nil {
+		lite.Close()
+		stats.Close()
+		return nil, fmt.Errorf("pubsub.NewClient: %w", err)
+	}
+	return &clients{lite: lite, stats: stats, pubsub: ps}, nil
+}
+
+func (c *clients) close() {
+	c.lite.Close()
+	c.stats.Close()
+	c.pubsub.Close()
+}
+
+// run executes command, writing its report to w.
+func run(ctx context.Context, w io.Writer, c *clients, cfg config, command string) error {
+	switch command {
+	case "list":
+		inv, err := list(ctx, c, cfg.project, cfg.locations)
+		if err != nil {
+			return err
+		}
+		for _, t := range inv.Topics {
+			fmt.Fprintf(w, "topic %s (%d partitions, retention %s)\n", t.Name, t.PartitionCount, retentionString(t.RetentionDuration))
+		}
+		for _, s := range inv.Subscriptions {
+			kind := "subscription"
+			if s.ExportConfig != nil {
+				kind = "export subscription"
+			}
+			fmt.Fprintf(w, "%s %s (topic %s)\n", kind, s.Name, s.Topic)
+		}
+		return nil
+
+	case "plan":
+		p, err := newPlan(ctx, c, cfg)
+		if err != nil {
+			return err
+		}
+		printPlan(w, p)
+		return nil
+
+	case "apply":
+		s, err := loadState(cfg.stateFile)
+		if errors.Is(err, os.ErrNotExist) {
+			var p *Plan
+			if p, err = newPlan(ctx, c, cfg); err != nil {
+				return err
+			}
+			s = &State{Plan: p}
+		} else if err != nil {
+			return err
+		}
+		err = apply(ctx, w, c, s, cfg.dryRun)
+		if cfg.dryRun {
+			return err
+		}
+		if serr := saveState(cfg.stateFile, s); serr != nil && err == nil {
+			err = serr
+		}
+		return err
+
+	case "verify":
+		s, err := loadState(cfg.stateFile)
+		if err != nil {
+			return err
+		}
+		ps, err := verify(ctx, c, s, cfg.idle)
+		if err != nil {
+			return err
+		}
+		if err := saveState(cfg.stateFile, s); err != nil {
+			return err
+		}
+		ok := true
+		for _, p := range ps {
+			fmt.Fprintln(w, p)
+			ok = ok && p.OK()
+		}
+		if !ok {
+			return errNoParity
+		}
+		return nil
+
+	case "rollback":
+		s, err := loadState(cfg.stateFile)
+		if err != nil {
+			return err
+		}
+		err = rollback(ctx, w, c, s, cfg.dryRun)
+		if cfg.dryRun {
+			return err
+		}
+		if serr := saveState(cfg.stateFile, s); serr != nil && err == nil {
+			err = serr
+		}
+		return err
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
+
+func newPlan(ctx context.Context, c *clients, cfg config) (*Plan, error) {
+	inv, err := list(ctx, c, cfg.project, cfg.locations)
+	if err != nil {
+		return nil, err
+	}
+	return plan(inv, cfg.pubsubProject, cfg.prefix)
+}
+
+func loadState(name string) (*State, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	s := new(State)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	return s, nil
+}
+
+func saveState(name string, s *State) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,510 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"cloud.google.com/go/pubsublite"
+	vkit "cloud.google.com/go/pubsublite/apiv1"
+	pb "cloud.google.com/go/pubsublite/apiv1/pubsublitepb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeLite is an in-memory Pub/Sub Lite admin and topic stats service.
+type fakeLite struct {
+	pb.UnimplementedAdminServiceServer
+	pb.UnimplementedTopicStatsServiceServer
+
+	mu     sync.Mutex
+	topics map[string]*pb.Topic
+	subs   map[string]*pb.Subscription
+	// created holds the requests that created subscriptions.
+	created []*pb.CreateSubscriptionRequest
+	// counts holds the retained message count of each topic partition.
+	counts map[string][]int64
+	// createErr, if set, fails the next CreateSubscription.
+	createErr error
+}
+
+func (f *fakeLite) addTopic(name string, partitions int64, retention time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &pb.Topic{
+		Name:            name,
+		PartitionConfig: &pb.Topic_PartitionConfig{Count: partitions},
+		RetentionConfig: &pb.Topic_RetentionConfig{PerPartitionBytes: 30 << 30},
+	}
+	if retention != pubsublite.InfiniteRetention {
+		t.RetentionConfig.Period = durationpb.New(retention)
+	}
+	f.topics[name] = t
+}
+
+func (f *fakeLite) addSubscription(name, topic string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs[name] = &pb.Subscription{Name: name, Topic: topic}
+}
+
+func (f *fakeLite) setCounts(topic string, counts ...int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts[topic] = counts
+}
+
+func (f *fakeLite) ListTopics(_ context.Context, req *pb.ListTopicsRequest) (*pb.ListTopicsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := new(pb.ListTopicsResponse)
+	for name, t := range f.topics {
+		if strings.HasPrefix(name, req.Parent+"/topics/") {
+			resp.Topics = append(resp.Topics, t)
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeLite) ListSubscriptions(_ context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resp := new(pb.ListSubscriptionsResponse)
+	for name, s := range f.subs {
+		if strings.HasPrefix(name, req.Parent+"/subscriptions/") {
+			resp.Subscriptions = append(resp.Subscriptions, s)
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeLite) CreateSubscription(_ context.Context, req *pb.CreateSubscriptionRequest) (*pb.Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.createErr; err != nil {
+		f.createErr = nil
+		return nil, err
+	}
+	name := req.Parent + "/subscriptions/" + req.SubscriptionId
+	if _, ok := f.subs[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "%s exists", name)
+	}
+	if _, ok := f.topics[req.Subscription.Topic]; !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Subscription.Topic)
+	}
+	s := &pb.Subscription{
+		Name:           name,
+		Topic:          req.Subscription.Topic,
+		DeliveryConfig: req.Subscription.DeliveryConfig,
+		ExportConfig:   req.Subscription.ExportConfig,
+	}
+	f.subs[name] = s
+	f.created = append(f.created, req)
+	return s, nil
+}
+
+func (f *fakeLite) DeleteSubscription(_ context.Context, req *pb.DeleteSubscriptionRequest) (*emptypb.Empty, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subs[req.Name]; !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
+	delete(f.subs, req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+func (f *fakeLite) ComputeMessageStats(_ context.Context, req *pb.ComputeMessageStatsRequest) (*pb.ComputeMessageStatsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	counts := f.counts[req.Topic]
+	if req.Partition >= int64(len(counts)) {
+		return &pb.ComputeMessageStatsResponse{}, nil
+	}
+	return &pb.ComputeMessageStatsResponse{MessageCount: counts[req.Partition]}, nil
+}
+
+func (f *fakeLite) sub(name string) *pb.Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.subs[name]
+}
+
+type fixture struct {
+	lite   *fakeLite
+	pubsub *pstest.Server
+	c      *clients
+	cfg    config
+}
+
+const (
+	liteProject   = "lite-project"
+	pubsubProject = "pubsub-project"
+	zoneTopic     = "projects/lite-project/locations/us-central1-a/topics/orders"
+	regionTopic   = "projects/lite-project/locations/us-central1/topics/events"
+)
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &fixture{
+		lite: &fakeLite{
+			topics: make(map[string]*pb.Topic),
+			subs:   make(map[string]*pb.Subscription),
+			counts: make(map[string][]int64),
+		},
+		pubsub: pstest.NewServer(),
+		cfg: config{
+			project:       liteProject,
+			locations:     []string{"us-central1", "us-central1-a"},
+			pubsubProject: pubsubProject,
+			stateFile:     filepath.Join(t.TempDir(), "migration.json"),
+			idle:          200 * time.Millisecond,
+		},
+	}
+	t.Cleanup(func() { f.pubsub.Close() })
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterAdminServiceServer(srv, f.lite)
+	pb.RegisterTopicStatsServiceServer(srv, f.lite)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	liteConn := dial(t, lis.Addr().String())
+	pubsubConn := dial(t, f.pubsub.Addr)
+	f.c = new(clients)
+	if f.c.lite, err = pubsublite.NewAdminClient(ctx, "us-central1", option.WithGRPCConn(liteConn)); err != nil {
+		t.Fatalf("pubsublite.NewAdminClient: %v", err)
+	}
+	if f.c.stats, err = vkit.NewTopicStatsClient(ctx, option.WithGRPCConn(liteConn)); err != nil {
+		t.Fatalf("NewTopicStatsClient: %v", err)
+	}
+	if f.c.pubsub, err = pubsub.NewClient(ctx, pubsubProject, option.WithGRPCConn(pubsubConn)); err != nil {
+		t.Fatalf("pubsub.NewClient: %v", err)
+	}
+
+	f.lite.addTopic(zoneTopic, 2, 24*time.Hour)
+	f.lite.addTopic(regionTopic, 1, pubsublite.InfiniteRetention)
+	f.lite.addSubscription("projects/lite-project/locations/us-central1-a/subscriptions/billing", zoneTopic)
+	f.lite.addSubscription("projects/lite-project/locations/us-central1-a/subscriptions/shipping", zoneTopic)
+	f.lite.addSubscription("projects/lite-project/locations/us-central1/subscriptions/audit", regionTopic)
+	return f
+}
+
+func dial(t *testing.T, addr string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func (f *fixture) run(t *testing.T, command string) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var buf bytes.Buffer
+	err := run(ctx, &buf, f.c, f.cfg, command)
+	return buf.String(), err
+}
+
+func (f *fixture) mustRun(t *testing.T, command string) string {
+	t.Helper()
+	out, err := f.run(t, command)
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", command, err, out)
+	}
+	return out
+}
+
+func (f *fixture) state(t *testing.T) *State {
+	t.Helper()
+	s, err := loadState(f.cfg.stateFile)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	return s
+}
+
+// publish simulates the export subscription forwarding n messages.
+func (f *fixture) publish(t *testing.T, topicID string, n int) {
+	t.Helper()
+	topic := f.c.pubsub.Topic(topicID)
+	defer topic.Stop()
+	for i := 0; i < n; i++ {
+		if _, err := topic.Publish(context.Background(), &pubsub.Message{Data: []byte("m")}).Get(context.Background()); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	inv := &Inventory{
+		Topics: []*pubsublite.TopicConfig{
+			{Name: zoneTopic, PartitionCount: 2, RetentionDuration: 24 * time.Hour},
+			{Name: regionTopic, PartitionCount: 1, RetentionDuration: pubsublite.InfiniteRetention},
+		},
+		Subscriptions: []*pubsublite.SubscriptionConfig{
+			{Name: "projects/lite-project/locations/us-central1-a/subscriptions/billing", Topic: zoneTopic},
+			{Name: "projects/lite-project/locations/us-central1/subscriptions/audit", Topic: regionTopic},
+			{
+				Name:         "projects/lite-project/locations/us-central1/subscriptions/to-bigquery",
+				Topic:        regionTopic,
+				ExportConfig: &pubsublite.ExportConfig{},
+			},
+		},
+	}
+	got, err := plan(inv, pubsubProject, "lite-")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	want := &Plan{
+		PubSubProject: pubsubProject,
+		Topics: []*TopicPlan{
+			{
+				LiteTopic:          regionTopic,
+				Partitions:         1,
+				PubSubTopic:        "lite-events",
+				Retention:          31 * 24 * time.Hour,
+				ExportSubscription: "projects/lite-project/locations/us-central1/subscriptions/events-pubsub-export",
+				VerifySubscription: "lite-events-migration-verify",
+				Subscriptions: []*SubscriptionPlan{{
+					LiteSubscription:   "projects/lite-project/locations/us-central1/subscriptions/audit",
+					PubSubSubscription: "lite-audit",
+					Retention:          7 * 24 * time.Hour,
+					Ordering:           true,
+				}},
+				Notes: []string{"retention reduced from infinite to 744h0m0s"},
+			},
+			{
+				LiteTopic:          zoneTopic,
+				Partitions:         2,
+				PubSubTopic:        "lite-orders",
+				Retention:          24 * time.Hour,
+				ExportSubscription: "projects/lite-project/locations/us-central1-a/subscriptions/orders-pubsub-export",
+				VerifySubscription: "lite-orders-migration-verify",
+				Subscriptions: []*SubscriptionPlan{{
+					LiteSubscription:   "projects/lite-project/locations/us-central1-a/subscriptions/billing",
+					PubSubSubscription: "lite-billing",
+					Retention:          24 * time.Hour,
+					Ordering:           true,
+				}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("plan mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPlanConflicts(t *testing.T) {
+	inv := &Inventory{
+		Topics: []*pubsublite.TopicConfig{
+			{Name: "projects/p/locations/us-central1-a/topics/orders", PartitionCount: 1},
+			{Name: "projects/p/locations/us-central1-b/topics/orders", PartitionCount: 1},
+		},
+	}
+	if _, err := plan(inv, pubsubProject, ""); err == nil || !strings.Contains(err.Error(), `Pub/Sub ID "topics/orders"`) {
+		t.Errorf("plan with duplicate topic IDs got %v, want a conflict", err)
+	}
+
+	inv = &Inventory{
+		Subscriptions: []*pubsublite.SubscriptionConfig{
+			{Name: "projects/p/locations/us-central1-a/subscriptions/s", Topic: "projects/p/locations/us-central1-c/topics/t"},
+		},
+	}
+	if _, err := plan(inv, pubsubProject, ""); err == nil || !strings.Contains(err.Error(), "not being migrated") {
+		t.Errorf("plan with an unlisted topic got %v, want an error", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	f := newFixture(t)
+	out := f.mustRun(t, "list")
+	for _, want := range []string{
+		"topic " + regionTopic + " (1 partitions, retention infinite)",
+		"topic " + zoneTopic + " (2 partitions, retention 24h0m0s)",
+		"subscription projects/lite-project/locations/us-central1-a/subscriptions/billing (topic " + zoneTopic + ")",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("list output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestMigration(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	out := f.mustRun(t, "plan")
+	if !strings.Contains(out, "-> projects/pubsub-project/topics/orders (retention 24h0m0s)") {
+		t.Errorf("plan output:\n%s", out)
+	}
+
+	// A dry run changes nothing.
+	f.cfg.dryRun = true
+	out = f.mustRun(t, "apply")
+	if !strings.Contains(out, "Would create lite-subscription "+"projects/lite-project/locations/us-central1-a/subscriptions/orders-pubsub-export") {
+		t.Errorf("dry-run apply output:\n%s", out)
+	}
+	if _, err := os.Stat(f.cfg.stateFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("dry run wrote a state file: %v", err)
+	}
+	if ok, err := f.c.pubsub.Topic("orders").Exists(ctx); ok || err != nil {
+		t.Errorf("Topic(orders).Exists after a dry run = %v, %v, want false", ok, err)
+	}
+	f.cfg.dryRun = false
+
+	f.mustRun(t, "apply")
+	topic, err := f.c.pubsub.Topic("events").Config(ctx)
+	if err != nil {
+		t.Fatalf("Topic(events).Config: %v", err)
+	}
+	if topic.RetentionDuration != 31*24*time.Hour {
+		t.Errorf("events retention = %v, want 31 days", topic.RetentionDuration)
+	}
+	for _, id := range []string{"billing", "shipping", "audit"} {
+		sub, err := f.c.pubsub.Subscription(id).Config(ctx)
+		if err != nil {
+			t.Fatalf("Subscription(%s).Config: %v", id, err)
+		}
+		if !sub.EnableMessageOrdering {
+			t.Errorf("subscription %s does not have ordering enabled", id)
+		}
+	}
+	export := f.lite.sub("projects/lite-project/locations/us-central1-a/subscriptions/orders-pubsub-export")
+	if got := export.GetExportConfig().GetPubsubConfig().GetTopic(); got != "projects/pubsub-project/topics/orders" {
+		t.Errorf("export destination = %q, want projects/pubsub-project/topics/orders", got)
+	}
+	f.lite.mu.Lock()
+	for _, req := range f.lite.created {
+		if req.SkipBacklog {
+			t.Errorf("export subscription %s skips the backlog", req.SubscriptionId)
+		}
+	}
+	f.lite.mu.Unlock()
+	if got := len(f.state(t).Created); got != 9 {
+		t.Errorf("state records %d created resources, want 9", got)
+	}
+
+	// Verify is cumulative across runs.
+	f.lite.setCounts(zoneTopic, 2, 1)
+	f.lite.setCounts(regionTopic, 1)
+	f.publish(t, "orders", 2)
+	f.publish(t, "events", 1)
+	out, err = f.run(t, "verify")
+	if !errors.Is(err, errNoParity) || !strings.Contains(out, "orders: behind (2 of 3 messages)") || !strings.Contains(out, "events: ok (1 messages)") {
+		t.Errorf("verify got %v:\n%s", err, out)
+	}
+	f.publish(t, "orders", 1)
+	out = f.mustRun(t, "verify")
+	if !strings.Contains(out, "orders: ok (3 messages)") {
+		t.Errorf("verify output:\n%s", out)
+	}
+
+	f.cfg.dryRun = true
+	out = f.mustRun(t, "rollback")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 9 || !strings.HasPrefix(lines[0], "Would delete lite-subscription") || !strings.HasPrefix(lines[8], "Would delete pubsub-topic") {
+		t.Errorf("dry-run rollback output:\n%s", out)
+	}
+	if ok, err := f.c.pubsub.Topic("orders").Exists(ctx); !ok || err != nil {
+		t.Errorf("Topic(orders).Exists after a dry run = %v, %v, want true", ok, err)
+	}
+	f.cfg.dryRun = false
+
+	f.mustRun(t, "rollback")
+	for _, id := range []string{"orders", "events"} {
+		if ok, err := f.c.pubsub.Topic(id).Exists(ctx); ok || err != nil {
+			t.Errorf("Topic(%s).Exists after rollback = %v, %v, want false", id, ok, err)
+		}
+	}
+	if ok, err := f.c.pubsub.Subscription("billing").Exists(ctx); ok || err != nil {
+		t.Errorf("Subscription(billing).Exists after rollback = %v, %v, want false", ok, err)
+	}
+	if s := f.lite.sub("projects/lite-project/locations/us-central1-a/subscriptions/orders-pubsub-export"); s != nil {
+		t.Errorf("export subscription still exists after rollback")
+	}
+	if f.lite.sub("projects/lite-project/locations/us-central1-a/subscriptions/billing") == nil {
+		t.Errorf("rollback deleted a subscription it did not create")
+	}
+	if s := f.state(t); len(s.Created) != 0 || s.Counted != nil {
+		t.Errorf("state after rollback = %+v, want nothing created", s)
+	}
+}
+
+func TestApplyResumes(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	// An existing Pub/Sub topic is reused but not recorded, so rollback
+	// keeps it.
+	if _, err := f.c.pubsub.CreateTopic(ctx, "events"); err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+	f.lite.mu.Lock()
+	f.lite.createErr = status.Error(codes.PermissionDenied, "no export permission")
+	f.lite.mu.Unlock()
+	if _, err := f.run(t, "apply"); status.Code(errors.Unwrap(err)) != codes.PermissionDenied {
+		t.Fatalf("apply got %v, want PermissionDenied", err)
+	}
+	partial := len(f.state(t).Created)
+
+	out := f.mustRun(t, "apply")
+	if !strings.Contains(out, "Skipped existing pubsub-topic events") {
+		t.Errorf("second apply output:\n%s", out)
+	}
+	s := f.state(t)
+	seen := make(map[Resource]bool)
+	for _, r := range s.Created {
+		if seen[r] {
+			t.Errorf("%v recorded twice", r)
+		}
+		seen[r] = true
+	}
+	if seen[Resource{Kind: kindPubSubTopic, Name: "events"}] {
+		t.Errorf("pre-existing topic recorded as created")
+	}
+	if len(s.Created) <= partial {
+		t.Errorf("second apply recorded %d resources, want more than %d", len(s.Created), partial)
+	}
+
+	f.mustRun(t, "rollback")
+	if ok, err := f.c.pubsub.Topic("events").Exists(ctx); !ok || err != nil {
+		t.Errorf("Topic(events).Exists after rollback = %v, %v, want true", ok, err)
+	}
+}
@@ -0,0 +1,483 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsublite"
+	vkit "cloud.google.com/go/pubsublite/apiv1"
+	"cloud.google.com/go/pubsublite/apiv1/pubsublitepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Pub/Sub retention limits. Lite topics may retain messages longer, or
+// forever, so their retention is clamped to what Pub/Sub supports.
+const (
+	minRetention             = 10 * time.Minute
+	maxTopicRetention        = 31 * 24 * time.Hour
+	maxSubscriptionRetention = 7 * 24 * time.Hour
+)
+
+// Suffixes of the resources the migration creates alongside the mirrored
+// topics and subscriptions.
+const (
+	exportSuffix = "-pubsub-export"
+	verifySuffix = "-migration-verify"
+)
+
+// Kinds of resources recorded in a State.
+const (
+	kindPubSubTopic        = "pubsub-topic"
+	kindPubSubSubscription = "pubsub-subscription"
+	kindLiteSubscription   = "lite-subscription"
+)
+
+// clients holds the admin clients the migration talks to. The Lite clients
+// must be for the region that contains every migrated location.
+type clients struct {
+	lite   *pubsublite.AdminClient
+	stats  *vkit.TopicStatsClient
+	pubsub *pubsub.Client
+}
+
+// Inventory is the set of Lite resources found in a project.
+type Inventory struct {
+	Topics        []*pubsublite.TopicConfig
+	Subscriptions []*pubsublite.SubscriptionConfig
+}
+
+// list returns the Lite topics and subscriptions in the given locations of
+// project.
+func list(ctx context.Context, c *clients, project string, locations []string) (*Inventory, error) {
+	inv := new(Inventory)
+	for _, loc := range locations {
+		parent := fmt.Sprintf("projects/%s/locations/%s", project, loc)
+		topics := c.lite.Topics(ctx, parent)
+		for {
+			t, err := topics.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("listing topics in %s: %w", parent, err)
+			}
+			inv.Topics = append(inv.Topics, t)
+		}
+		subs := c.lite.Subscriptions(ctx, parent)
+		for {
+			s, err := subs.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("listing subscriptions in %s: %w", parent, err)
+			}
+			inv.Subscriptions = append(inv.Subscriptions, s)
+		}
+	}
+	return inv, nil
+}
+
+// Plan describes the Pub/Sub resources that replace a project's Lite
+// resources.
+type Plan struct {
+	PubSubProject string       `json:"pubsubProject"`
+	Topics        []*TopicPlan `json:"topics"`
+}
+
+// TopicPlan maps one Lite topic to a Pub/Sub topic.
+type TopicPlan struct {
+	LiteTopic  string `json:"liteTopic"`
+	Partitions int    `json:"partitions"`
+	// PubSubTopic is the ID of the Pub/Sub topic to create.
+	PubSubTopic string        `json:"pubsubTopic"`
+	Retention   time.Duration `json:"retention"`
+	// ExportSubscription is the full path of the Lite export subscription
+	// that forwards the topic's backlog and new messages to PubSubTopic.
+	ExportSubscription string `json:"exportSubscription"`
+	// VerifySubscription is the ID of a Pub/Sub subscription used only to
+	// count exported messages.
+	VerifySubscription string              `json:"verifySubscription"`
+	Subscriptions      []*SubscriptionPlan `json:"subscriptions"`
+	Notes              []string            `json:"notes,omitempty"`
+}
+
+// SubscriptionPlan maps one Lite subscription to a Pub/Sub subscription.
+type SubscriptionPlan struct {
+	LiteSubscription string `json:"liteSubscription"`
+	// PubSubSubscription is the ID of the Pub/Sub subscription to create.
+	PubSubSubscription string        `json:"pubsubSubscription"`
+	Retention          time.Duration `json:"retention"`
+	// Ordering is always enabled: Lite delivers each partition in order and
+	// export subscriptions map message keys to ordering keys.
+	Ordering bool `json:"ordering"`
+}
+
+// plan maps the inventory to Pub/Sub resources in pubsubProject. Pub/Sub IDs
+// are the Lite IDs with prefix prepended. Existing export subscriptions are
+// not mirrored.
+func plan(inv *Inventory, pubsubProject, prefix string) (*Plan, error) {
+	p := &Plan{PubSubProject: pubsubProject}
+	byTopic := make(map[string]*TopicPlan)
+	ids := make(map[string]string)
+	claim := func(id, owner string) error {
+		if prev, ok := ids[id]; ok {
+			return fmt.Errorf("%s and %s both map to Pub/Sub ID %q; use a prefix or migrate the locations separately", prev, owner, id)
+		}
+		ids[id] = owner
+		return nil
+	}
+
+	for _, t := range inv.Topics {
+		tp := &TopicPlan{
+			LiteTopic:          t.Name,
+			Partitions:         t.PartitionCount,
+			PubSubTopic:        prefix + path.Base(t.Name),
+			ExportSubscription: t.Name[:strings.LastIndex(t.Name, "/topics/")] + "/subscriptions/" + path.Base(t.Name) + exportSuffix,
+		}
+		tp.VerifySubscription = tp.PubSubTopic + verifySuffix
+		tp.Retention = clampRetention(t.RetentionDuration, maxTopicRetention)
+		if t.RetentionDuration == pubsublite.InfiniteRetention || t.RetentionDuration > maxTopicRetention {
+			tp.Notes = append(tp.Notes, fmt.Sprintf("retention reduced from %s to %s", retentionString(t.RetentionDuration), tp.Retention))
+		}
+		if err := claim("topics/"+tp.PubSubTopic, t.Name); err != nil {
+			return nil, err
+		}
+		if err := claim("subscriptions/"+tp.VerifySubscription, t.Name); err != nil {
+			return nil, err
+		}
+		byTopic[t.Name] = tp
+		p.Topics = append(p.Topics, tp)
+	}
+
+	for _, s := range inv.Subscriptions {
+		if s.ExportConfig != nil {
+			continue
+		}
+		tp, ok := byTopic[s.Topic]
+		if !ok {
+			return nil, fmt.Errorf("subscription %s is attached to topic %s, which is not being migrated", s.Name, s.Topic)
+		}
+		sp := &SubscriptionPlan{
+			LiteSubscription:   s.Name,
+			PubSubSubscription: prefix + path.Base(s.Name),
+			Retention:          clampRetention(tp.Retention, maxSubscriptionRetention),
+			Ordering:           true,
+		}
+		if err := claim("subscriptions/"+sp.PubSubSubscription, s.Name); err != nil {
+			return nil, err
+		}
+		tp.Subscriptions = append(tp.Subscriptions, sp)
+	}
+
+	sort.Slice(p.Topics, func(i, j int) bool { return p.Topics[i].PubSubTopic < p.Topics[j].PubSubTopic })
+	for _, tp := range p.Topics {
+		sort.Slice(tp.Subscriptions, func(i, j int) bool {
+			return tp.Subscriptions[i].PubSubSubscription < tp.Subscriptions[j].PubSubSubscription
+		})
+	}
+	return p, nil
+}
+
+func clampRetention(d, max time.Duration) time.Duration {
+	switch {
+	case d == pubsublite.InfiniteRetention || d > max:
+		return max
+	case d < minRetention:
+		return minRetention
+	}
+	return d
+}
+
+func retentionString(d time.Duration) string {
+	if d == pubsublite.InfiniteRetention {
+		return "infinite"
+	}
+	return d.String()
+}
+
+// printPlan writes a readable summary of p to w.
+func printPlan(w io.Writer, p *Plan) {
+	for _, tp := range p.Topics {
+		fmt.Fprintf(w, "%s (%d partitions)\n", tp.LiteTopic, tp.Partitions)
+		fmt.Fprintf(w, "  -> projects/%s/topics/%s (retention %s)\n", p.PubSubProject, tp.PubSubTopic, tp.Retention)
+		fmt.Fprintf(w, "  export: %s\n", tp.ExportSubscription)
+		for _, sp := range tp.Subscriptions {
+			fmt.Fprintf(w, "  %s\n", sp.LiteSubscription)
+			fmt.Fprintf(w, "    -> projects/%s/subscriptions/%s (ordering, retention %s)\n", p.PubSubProject, sp.PubSubSubscription, sp.Retention)
+		}
+		for _, n := range tp.Notes {
+			fmt.Fprintf(w, "  note: %s\n", n)
+		}
+	}
+}
+
+// State records what apply created, so that verify and rollback can pick up
+// where it left off.
+type State struct {
+	Plan    *Plan      `json:"plan"`
+	Created []Resource `json:"created"`
+	// Counted is the number of messages each verify subscription has
+	// received so far, keyed by Pub/Sub topic ID.
+	Counted map[string]int64 `json:"counted,omitempty"`
+}
+
+// Resource is a resource created by apply.
+type Resource struct {
+	Kind string `json:"kind"`
+	// Name is the ID of a Pub/Sub resource or the full path of a Lite
+	// resource.
+	Name string `json:"name"`
+}
+
+// apply creates the resources in s.Plan that do not exist yet, recording
+// each in s.Created. Pub/Sub subscriptions are created before the export
+// subscription so that they receive the whole exported backlog. On error,
+// s holds everything created so far. With dryRun, apply only reports what
+// it would do.
+func apply(ctx context.Context, w io.Writer, c *clients, s *State, dryRun bool) error {
+	p := s.Plan
+	verb := "Created"
+	if dryRun {
+		verb = "Would create"
+	}
+	create := func(kind, name string, f func() error) error {
+		if !dryRun {
+			err := f()
+			if status.Code(err) == codes.AlreadyExists {
+				fmt.Fprintf(w, "Skipped existing %s %s\n", kind, name)
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("creating %s %s: %w", kind, name, err)
+			}
+			s.Created = append(s.Created, Resource{Kind: kind, Name: name})
+		}
+		fmt.Fprintf(w, "%s %s %s\n", verb, kind, name)
+		return nil
+	}
+
+	for _, tp := range p.Topics {
+		topic := c.pubsub.Topic(tp.PubSubTopic)
+		err := create(kindPubSubTopic, tp.PubSubTopic, func() error {
+			_, err := c.pubsub.CreateTopicWithConfig(ctx, tp.PubSubTopic, &pubsub.TopicConfig{
+				RetentionDuration: tp.Retention,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		err = create(kindPubSubSubscription, tp.VerifySubscription, func() error {
+			_, err := c.pubsub.CreateSubscription(ctx, tp.VerifySubscription, pubsub.SubscriptionConfig{
+				Topic: topic,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, sp := range tp.Subscriptions {
+			err := create(kindPubSubSubscription, sp.PubSubSubscription, func() error {
+				_, err := c.pubsub.CreateSubscription(ctx, sp.PubSubSubscription, pubsub.SubscriptionConfig{
+					Topic:                 topic,
+					EnableMessageOrdering: sp.Ordering,
+					RetentionDuration:     sp.Retention,
+				})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = create(kindLiteSubscription, tp.ExportSubscription, func() error {
+			// Start at the oldest retained message so the Pub/Sub topic gets
+			// the whole backlog.
+			_, err := c.lite.CreateSubscription(ctx, pubsublite.SubscriptionConfig{
+				Name:                tp.ExportSubscription,
+				Topic:               tp.LiteTopic,
+				DeliveryRequirement: pubsublite.DeliverImmediately,
+				ExportConfig: &pubsublite.ExportConfig{
+					DesiredState: pubsublite.ExportActive,
+					Destination: &pubsublite.PubSubDestinationConfig{
+						Topic: fmt.Sprintf("projects/%s/topics/%s", p.PubSubProject, tp.PubSubTopic),
+					},
+				},
+			}, pubsublite.AtTargetLocation(pubsublite.Beginning))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback deletes the resources in s.Created in reverse order, so the
+// export subscriptions stop before their destinations disappear. Deleted
+// resources are removed from s.Created. With dryRun, rollback only reports
+// what it would do.
+func rollback(ctx context.Context, w io.Writer, c *clients, s *State, dryRun bool) error {
+	for i := len(s.Created) - 1; i >= 0; i-- {
+		r := s.Created[i]
+		if dryRun {
+			fmt.Fprintf(w, "Would delete %s %s\n", r.Kind, r.Name)
+			continue
+		}
+		var err error
+		switch r.Kind {
+		case kindLiteSubscription:
+			err = c.lite.DeleteSubscription(ctx, r.Name)
+		case kindPubSubSubscription:
+			err = c.pubsub.Subscription(r.Name).Delete(ctx)
+		case kindPubSubTopic:
+			err = c.pubsub.Topic(r.Name).Delete(ctx)
+		default:
+			err = fmt.Errorf("unknown resource kind %q", r.Kind)
+		}
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("deleting %s %s: %w", r.Kind, r.Name, err)
+		}
+		s.Created = s.Created[:i]
+		fmt.Fprintf(w, "Deleted %s %s\n", r.Kind, r.Name)
+	}
+	s.Counted = nil
+	return nil
+}
+
+// Parity compares the messages retained by a Lite topic with the messages
+// exported to its Pub/Sub topic.
+type Parity struct {
+	PubSubTopic string
+	Lite        int64
+	PubSub      int64
+}
+
+// OK reports whether every retained Lite message reached Pub/Sub. Export is
+// at-least-once, so Pub/Sub may have received more.
+func (p Parity) OK() bool { return p.PubSub >= p.Lite }
+
+func (p Parity) String() string {
+	switch {
+	case p.PubSub == p.Lite:
+		return fmt.Sprintf("%s: ok (%d messages)", p.PubSubTopic, p.Lite)
+	case p.PubSub > p.Lite:
+		return fmt.Sprintf("%s: ok (%d messages, %d redelivered)", p.PubSubTopic, p.Lite, p.PubSub-p.Lite)
+	}
+	return fmt.Sprintf("%s: behind (%d of %d messages)", p.PubSubTopic, p.PubSub, p.Lite)
+}
+
+// verify counts the messages retained by each Lite topic and the messages
+// its verify subscription has received. The verify subscription is drained
+// until it has been idle for idle; counts accumulate in s.Counted across
+// runs. Publishers should be stopped before verifying, or the counts will
+// race.
+func verify(ctx context.Context, c *clients, s *State, idle time.Duration) ([]Parity, error) {
+	if s.Counted == nil {
+		s.Counted = make(map[string]int64)
+	}
+	var ps []Parity
+	for _, tp := range s.Plan.Topics {
+		lite, err := liteCount(ctx, c, tp)
+		if err != nil {
+			return nil, err
+		}
+		n, err := drain(ctx, c.pubsub.Subscription(tp.VerifySubscription), idle)
+		if err != nil {
+			return nil, fmt.Errorf("counting messages in %s: %w", tp.VerifySubscription, err)
+		}
+		s.Counted[tp.PubSubTopic] += n
+		ps = append(ps, Parity{PubSubTopic: tp.PubSubTopic, Lite: lite, PubSub: s.Counted[tp.PubSubTopic]})
+	}
+	return ps, nil
+}
+
+// liteCount returns the number of messages retained by the topic across
+// all partitions.
+func liteCount(ctx context.Context, c *clients, tp *TopicPlan) (int64, error) {
+	var total int64
+	for i := 0; i < tp.Partitions; i++ {
+		resp, err := c.stats.ComputeMessageStats(ctx, &pubsublitepb.ComputeMessageStatsRequest{
+			Topic:       tp.LiteTopic,
+			Partition:   int64(i),
+			StartCursor: &pubsublitepb.Cursor{},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("ComputeMessageStats(%s, partition %d): %w", tp.LiteTopic, i, err)
+		}
+		total += resp.GetMessageCount()
+	}
+	return total, nil
+}
+
+// drain acks and counts the distinct messages received by sub until no
+// message has arrived for idle.
+func drain(parent context.Context, sub *pubsub.Subscription, idle time.Duration) (int64, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	last := time.Now()
+	go func() {
+		// Check a few times per idle period, but not in a busy loop for
+		// tiny periods; NewTicker also panics on a zero interval.
+		t := time.NewTicker(max(idle/4, time.Millisecond))
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-t.C:
+				mu.Lock()
+				quiet := now.Sub(last) >= idle
+				mu.Unlock()
+				if quiet {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err := sub.Receive(ctx, func(_ context.Context, m *pubsub.Message) {
+		mu.Lock()
+		seen[m.ID] = true
+		last = time.Now()
+		mu.Unlock()
+		m.Ack()
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return 0, err
+	}
+	if err := parent.Err(); err != nil {
+		return 0, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return int64(len(seen)), nil
+}
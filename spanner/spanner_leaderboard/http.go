@@ -0,0 +1,192 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/GoogleCloudPlatform/golang-samples/spanner/spanner_leaderboard/leaderboardpb"
+)
+
+// httpHandler serves the Leaderboard service as JSON over HTTP:
+//
+//	POST /v1/players                    CreatePlayer, body CreatePlayerRequest
+//	POST /v1/scores                     SubmitScore, body SubmitScoreRequest
+//	GET  /v1/leaderboard?window=day&page_size=10&page_token=...
+//	GET  /v1/players/{id}/rank?window=week&neighbors=2
+//
+// Windows are day, week or all, the default.
+func httpHandler(s *server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/players", func(w http.ResponseWriter, r *http.Request) {
+		req := &pb.CreatePlayerRequest{}
+		if err := readProto(r, req); err != nil {
+			writeError(w, err)
+			return
+		}
+		resp, err := s.CreatePlayer(r.Context(), req)
+		writeResponse(w, resp, err)
+	})
+	mux.HandleFunc("POST /v1/scores", func(w http.ResponseWriter, r *http.Request) {
+		req := &pb.SubmitScoreRequest{}
+		if err := readProto(r, req); err != nil {
+			writeError(w, err)
+			return
+		}
+		resp, err := s.SubmitScore(r.Context(), req)
+		writeResponse(w, resp, err)
+	})
+	mux.HandleFunc("GET /v1/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		req := &pb.ListTopPlayersRequest{PageToken: r.FormValue("page_token")}
+		var err error
+		if req.Window, err = parseWindow(r.FormValue("window")); err != nil {
+			writeError(w, err)
+			return
+		}
+		if req.PageSize, err = int32Param(r, "page_size"); err != nil {
+			writeError(w, err)
+			return
+		}
+		resp, err := s.ListTopPlayers(r.Context(), req)
+		writeResponse(w, resp, err)
+	})
+	mux.HandleFunc("GET /v1/players/{id}/rank", func(w http.ResponseWriter, r *http.Request) {
+		req := &pb.GetPlayerRankRequest{}
+		var err error
+		if req.PlayerId, err = strconv.ParseInt(r.PathValue("id"), 10, 64); err != nil {
+			writeError(w, status.Error(codes.InvalidArgument, "invalid player ID"))
+			return
+		}
+		if req.Window, err = parseWindow(r.FormValue("window")); err != nil {
+			writeError(w, err)
+			return
+		}
+		if req.Neighbors, err = int32Param(r, "neighbors"); err != nil {
+			writeError(w, err)
+			return
+		}
+		resp, err := s.GetPlayerRank(r.Context(), req)
+		writeResponse(w, resp, err)
+	})
+	return mux
+}
+
+// parseWindow parses day, week or all, or the name of a Window value.
+func parseWindow(s string) (pb.Window, error) {
+	switch strings.ToLower(s) {
+	case "", "all", "all_time":
+		return pb.Window_WINDOW_ALL_TIME, nil
+	case "day":
+		return pb.Window_WINDOW_DAY, nil
+	case "week":
+		return pb.Window_WINDOW_WEEK, nil
+	}
+	if v, ok := pb.Window_value[s]; ok {
+		return pb.Window(v), nil
+	}
+	return 0, status.Errorf(codes.InvalidArgument, "invalid window %q, want day, week or all", s)
+}
+
+func int32Param(r *http.Request, name string) (int32, error) {
+	s := r.FormValue(name)
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid %s %q", name, s)
+	}
+	return int32(v), nil
+}
+
+func readProto(r *http.Request, m proto.Message) error {
+	b, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if err := protojson.Unmarshal(b, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+func writeResponse(w http.ResponseWriter, m proto.Message, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// writeError writes err, usually a gRPC status, as a JSON google.rpc.Status
+// with the matching HTTP status code.
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code := httpStatus(st.Code())
+	if code == http.StatusInternalServerError {
+		log.Printf("internal error: %v", err)
+	}
+	b, _ := protojson.Marshal(st.Proto())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// httpStatus maps gRPC codes to HTTP status codes, as gRPC-to-HTTP
+// gateways do.
+func httpStatus(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
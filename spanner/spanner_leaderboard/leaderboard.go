@@ -12,58 +12,44 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Command spanner_leaderboard contains runnable snippet code for Cloud Spanner.
+// Command spanner_leaderboard is a leaderboard service backed by Cloud Spanner,
+// served over gRPC and HTTP.
 package main
 
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
-
 	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
-	"google.golang.org/api/iterator"
-)
-
-type command func(ctx context.Context, w io.Writer, client *spanner.Client) error
-type uniqueRand struct {
-	used map[int64]bool
-	rand *rand.Rand
-}
-
-func newUniqueRand() uniqueRand {
-	return uniqueRand{
-		used: map[int64]bool{},
-		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
-	}
-}
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 
-func (r *uniqueRand) rnd(min, max int64) int64 {
-	for {
-		rnd := r.rand.Int63n(max) + min
-		if !r.used[rnd] {
-			r.used[rnd] = true
-			return rnd
-		}
-	}
-}
+	pb "github.com/GoogleCloudPlatform/golang-samples/spanner/spanner_leaderboard/leaderboardpb"
+)
 
 var (
-	commands = map[string]command{
-		"insertplayers": insertPlayers,
-		"insertscores":  insertScores,
-		"query":         query,
-	}
+	grpcPort    = flag.Int("grpc_port", 9090, "serve: port of the gRPC API")
+	httpPort    = flag.Int("http_port", 0, "serve: port of the HTTP API; defaults to $PORT, or 8080")
+	target      = flag.String("target", "", "loadtest: address of the gRPC API; empty runs the service in-process")
+	players     = flag.Int("players", 100, "loadtest: players to create")
+	scores      = flag.Int("scores", 4, "loadtest: scores per player")
+	concurrency = flag.Int("concurrency", 8, "loadtest: concurrent requests")
+	duplicates  = flag.Int("duplicate_every", 10, "loadtest: resubmit every Nth score; 0 disables")
 )
 
 func createDatabase(ctx context.Context, w io.Writer, adminClient *database.DatabaseAdminClient, db string) error {
@@ -74,19 +60,6 @@ func createDatabase(ctx context.Context, w io.Writer, adminClient *database.Data
 	op, err := adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
 		Parent:          matches[1],
 		CreateStatement: "CREATE DATABASE `" + matches[2] + "`",
-		ExtraStatements: []string{
-			`CREATE TABLE Players(
-			    PlayerId INT64 NOT NULL,
-			    PlayerName STRING(2048) NOT NULL
-			) PRIMARY KEY(PlayerId)`,
-			`CREATE TABLE Scores(
-			    PlayerId INT64 NOT NULL,
-			    Score INT64 NOT NULL,
-			    Timestamp TIMESTAMP NOT NULL
-			    OPTIONS(allow_commit_timestamp=true)
-			) PRIMARY KEY(PlayerId, Timestamp),
-			INTERLEAVE IN PARENT Players ON DELETE NO ACTION`,
-		},
 	})
 	if err != nil {
 		return err
@@ -98,174 +71,77 @@ func createDatabase(ctx context.Context, w io.Writer, adminClient *database.Data
 	return nil
 }
 
-func insertPlayers(ctx context.Context, w io.Writer, client *spanner.Client) error {
-	// Get number of players to use as an incrementing value for each PlayerName to be inserted
-	stmt := spanner.Statement{
-		SQL: `SELECT Count(PlayerId) as PlayerCount FROM Players`,
-	}
-	iter := client.Single().Query(ctx, stmt)
-	defer iter.Stop()
-	row, err := iter.Next()
+// serve serves the gRPC and HTTP APIs until ctx is done.
+func serve(ctx context.Context, w io.Writer, client *spanner.Client, grpcLis, httpLis net.Listener) error {
+	n, err := pendingMigrations(ctx, client)
 	if err != nil {
 		return err
 	}
-	var numberOfPlayers int64 = 0
-	if err := row.Columns(&numberOfPlayers); err != nil {
-		return err
+	if n > 0 {
+		return fmt.Errorf("%d migrations are pending; run the migrate command first", n)
 	}
-	// Intialize values for random PlayerId
-	min := int64(1000000000)
-	max := int64(9000000000)
-	rnd := newUniqueRand()
-	// Insert 100 player records into the Players table
-	_, err = client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
-		stmts := []spanner.Statement{}
-		for i := 1; i <= 100; i++ {
-			numberOfPlayers++
-			playerID := rnd.rnd(min, max)
 
-			playerName := fmt.Sprintf("Player %d", numberOfPlayers)
-			stmts = append(stmts, spanner.Statement{
-				SQL: `INSERT INTO Players
-						(PlayerId, PlayerName)
-						VALUES (@playerID, @playerName)`,
-				Params: map[string]interface{}{
-					"playerID":   playerID,
-					"playerName": playerName,
-				},
-			})
-		}
-		_, err := txn.BatchUpdate(ctx, stmts)
-		if err != nil {
-			return err
-		}
-		return nil
-	})
-	fmt.Fprintf(w, "Inserted players \n")
-	return nil
-}
+	s := newServer(client)
+	grpcServer := grpc.NewServer()
+	pb.RegisterLeaderboardServer(grpcServer, s)
+	httpServer := &http.Server{Handler: httpHandler(s)}
 
-func insertScores(ctx context.Context, w io.Writer, client *spanner.Client) error {
-	playerRecordsFound := false
-	// Create slice for insert statements
-	stmts := []spanner.Statement{}
-	// Select all player records
-	stmt := spanner.Statement{SQL: `SELECT PlayerId FROM Players`}
-	iter := client.Single().Query(ctx, stmt)
-	defer iter.Stop()
-	// Insert 4 score records into the Scores table for each player in the Players table
-	for {
-		row, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return err
-		}
-		playerRecordsFound = true
-		var playerID int64
-		if err := row.ColumnByName("PlayerId", &playerID); err != nil {
-			return err
-		}
-		// Intialize values for random score and date
-		rand.Seed(time.Now().UnixNano())
-		min := 1000
-		max := 1000000
-		for i := 0; i < 4; i++ {
-			// Generate random score between 1,000 and 1,000,000
-			score := rand.Intn(max-min) + min
-			// Generate random day within the past two years
-			now := time.Now()
-			endDate := now.Unix()
-			past := now.AddDate(0, -24, 0)
-			startDate := past.Unix()
-			randomDateInSeconds := rand.Int63n(endDate-startDate) + startDate
-			randomDate := time.Unix(randomDateInSeconds, 0)
-			// Add insert statement to stmts slice
-			stmts = append(stmts, spanner.Statement{
-				SQL: `INSERT INTO Scores
-						(PlayerId, Score, Timestamp)
-						VALUES (@playerID, @score, @timestamp)`,
-				Params: map[string]interface{}{
-					"playerID":  playerID,
-					"score":     score,
-					"timestamp": randomDate,
-				},
-			})
-		}
+	errc := make(chan error, 2)
+	go func() { errc <- grpcServer.Serve(grpcLis) }()
+	go func() { errc <- httpServer.Serve(httpLis) }()
+	fmt.Fprintf(w, "Serving gRPC on %s and HTTP on %s\n", grpcLis.Addr(), httpLis.Addr())
 
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
 	}
-	if !playerRecordsFound {
-		fmt.Fprintln(w, "No player records currently exist. First insert players then insert scores.")
-	} else {
-		_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
-			// Commit insert statements for all scores to be inserted as a single transaction
-			_, err := txn.BatchUpdate(ctx, stmts)
-			return err
-		})
-		if err != nil {
-			return err
-		}
-		fmt.Fprintln(w, "Inserted scores")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	httpServer.Shutdown(shutdownCtx)
+	grpcServer.GracefulStop()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
 	}
-	return nil
+	return err
 }
 
-func query(ctx context.Context, w io.Writer, client *spanner.Client) error {
-	stmt := spanner.Statement{
-		SQL: `SELECT p.PlayerId, p.PlayerName, s.Score, s.Timestamp
-		        FROM Players p
-		        JOIN Scores s ON p.PlayerId = s.PlayerId
-		        ORDER BY s.Score DESC LIMIT 10`}
-	iter := client.Single().Query(ctx, stmt)
-	defer iter.Stop()
-	for {
-		row, err := iter.Next()
-		if err == iterator.Done {
-			return nil
-		}
+// runLoadTest runs a load test against target, or against an in-process
+// service if target is empty.
+func runLoadTest(ctx context.Context, w io.Writer, client *spanner.Client, target string, cfg loadTestConfig) error {
+	if target == "" {
+		lis, err := net.Listen("tcp", "localhost:0")
 		if err != nil {
 			return err
 		}
-		var playerID, score int64
-		var playerName string
-		var timestamp time.Time
-		if err := row.Columns(&playerID, &playerName, &score, &timestamp); err != nil {
-			return err
-		}
-		fmt.Fprintf(w, "PlayerId: %d  PlayerName: %s  Score: %s  Timestamp: %s\n",
-			playerID, playerName, formatWithCommas(score), timestamp.String()[0:10])
+		grpcServer := grpc.NewServer()
+		pb.RegisterLeaderboardServer(grpcServer, newServer(client))
+		go grpcServer.Serve(lis)
+		defer grpcServer.Stop()
+		target = lis.Addr().String()
+	}
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
+	return loadTest(ctx, w, pb.NewLeaderboardClient(conn), cfg)
 }
 
-func queryWithTimespan(ctx context.Context, w io.Writer, client *spanner.Client, timespan int) error {
-	stmt := spanner.Statement{
-		SQL: `SELECT p.PlayerId, p.PlayerName, s.Score, s.Timestamp
-				FROM Players p
-				JOIN Scores s ON p.PlayerId = s.PlayerId 
-				WHERE s.Timestamp > TIMESTAMP_SUB(CURRENT_TIMESTAMP(), INTERVAL @Timespan HOUR)
-				ORDER BY s.Score DESC LIMIT 10`,
-		Params: map[string]interface{}{"Timespan": timespan},
+// printTop prints the top ten players of a window.
+func printTop(ctx context.Context, w io.Writer, client *spanner.Client, window string) error {
+	win, err := parseWindow(window)
+	if err != nil {
+		return err
 	}
-	iter := client.Single().Query(ctx, stmt)
-	defer iter.Stop()
-	for {
-		row, err := iter.Next()
-		if err == iterator.Done {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-		var playerID, score int64
-		var playerName string
-		var timestamp time.Time
-		if err := row.Columns(&playerID, &playerName, &score, &timestamp); err != nil {
-			return err
-		}
-		fmt.Fprintf(w, "PlayerId: %d  PlayerName: %s  Score: %s  Timestamp: %s\n",
-			playerID, playerName, formatWithCommas(score), timestamp.String()[0:10])
+	resp, err := newServer(client).ListTopPlayers(ctx, &pb.ListTopPlayersRequest{Window: win, PageSize: 10})
+	if err != nil {
+		return err
 	}
+	for _, e := range resp.GetEntries() {
+		fmt.Fprintf(w, "Rank: %d  PlayerId: %d  PlayerName: %s  Score: %s\n",
+			e.GetRank(), e.GetPlayerId(), e.GetPlayerName(), formatWithCommas(e.GetScore()))
+	}
+	return nil
 }
 
 func formatWithCommas(n int64) string {
@@ -306,36 +182,50 @@ func createClients(ctx context.Context, db string) (*database.DatabaseAdminClien
 }
 
 func run(ctx context.Context, adminClient *database.DatabaseAdminClient, dataClient *spanner.Client, w io.Writer,
-	cmd string, db string, timespan int) error {
-	// createdatabase command
-	if cmd == "createdatabase" {
-		err := createDatabase(ctx, w, adminClient, db)
-		if err != nil {
-			fmt.Fprintf(w, "%s failed with %v", cmd, err)
+	cmd string, db string, args []string) error {
+	var err error
+	switch cmd {
+	case "createdatabase":
+		if err = createDatabase(ctx, w, adminClient, db); err == nil {
+			err = migrate(ctx, w, adminClient, dataClient, db)
 		}
-		return err
-	}
-
-	// querywithtimespan command
-	if cmd == "querywithtimespan" {
-		if timespan == 0 {
-			flag.Usage()
-			os.Exit(2)
+	case "migrate":
+		err = migrate(ctx, w, adminClient, dataClient, db)
+	case "serve":
+		port := *httpPort
+		if port == 0 {
+			port = 8080
+			if p, perr := strconv.Atoi(os.Getenv("PORT")); perr == nil {
+				port = p
+			}
 		}
-		err := queryWithTimespan(ctx, w, dataClient, timespan)
-		if err != nil {
-			fmt.Fprintf(w, "%s failed with %v", cmd, err)
+		var grpcLis, httpLis net.Listener
+		if grpcLis, err = net.Listen("tcp", fmt.Sprintf(":%d", *grpcPort)); err != nil {
+			break
 		}
-		return err
-	}
-
-	// insert and query commands
-	cmdFn := commands[cmd]
-	if cmdFn == nil {
+		if httpLis, err = net.Listen("tcp", fmt.Sprintf(":%d", port)); err != nil {
+			grpcLis.Close()
+			break
+		}
+		err = serve(ctx, w, dataClient, grpcLis, httpLis)
+	case "loadtest":
+		err = runLoadTest(ctx, w, dataClient, *target, loadTestConfig{
+			Players:         *players,
+			ScoresPerPlayer: *scores,
+			Concurrency:     *concurrency,
+			DuplicateEvery:  *duplicates,
+			Seed:            time.Now().UnixNano(),
+		})
+	case "top":
+		window := ""
+		if len(args) > 0 {
+			window = args[0]
+		}
+		err = printTop(ctx, w, dataClient, window)
+	default:
 		flag.Usage()
 		os.Exit(2)
 	}
-	err := cmdFn(ctx, w, dataClient)
 	if err != nil {
 		fmt.Fprintf(w, "%s failed with %v", cmd, err)
 	}
@@ -344,47 +234,44 @@ func run(ctx context.Context, adminClient *database.DatabaseAdminClient, dataCli
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: leaderboard <command> <database_name> [command_option]
+		fmt.Fprintf(os.Stderr, `Usage: leaderboard [flags] <command> <database_name> [command_option]
 
-	Command can be one of: createdatabase, insertplayers, insertscores, query, querywithtimespan
+	Command can be one of: createdatabase, migrate, serve, loadtest, top
 
 Examples:
 	leaderboard createdatabase projects/my-project/instances/my-instance/databases/example-db
-		- Create a sample Cloud Spanner database along with sample tables in your project.
-	leaderboard insertplayers projects/my-project/instances/my-instance/databases/example-db
-		- Insert 100 sample Player records into the database.
-	leaderboard insertscores projects/my-project/instances/my-instance/databases/example-db
-		- Insert sample score data into Scores sample Cloud Spanner database table.
-	leaderboard query projects/my-project/instances/my-instance/databases/example-db
-		- Query players with top ten scores of all time.
-	leaderboard querywithtimespan projects/my-project/instances/my-instance/databases/example-db 168
-		- Query players with top ten scores within a timespan specified in hours.
+		- Create a sample Cloud Spanner database and apply the schema migrations.
+	leaderboard migrate projects/my-project/instances/my-instance/databases/example-db
+		- Apply pending schema migrations to an existing database.
+	leaderboard -grpc_port=9090 -http_port=8080 serve projects/my-project/instances/my-instance/databases/example-db
+		- Serve the leaderboard over gRPC and HTTP.
+	leaderboard -players=100 -scores=4 -concurrency=8 loadtest projects/my-project/instances/my-instance/databases/example-db
+		- Create random players and submit random scores from the past two years, and report latencies.
+	leaderboard top projects/my-project/instances/my-instance/databases/example-db week
+		- Print the top ten players of the last day, week or all time.
+
+Flags:
 `)
+		flag.PrintDefaults()
 	}
 
 	flag.Parse()
-	flagCount := len(flag.Args())
-	if flagCount < 2 || flagCount > 3 {
+	if len(flag.Args()) < 2 {
 		flag.Usage()
 		os.Exit(2)
 	}
-
 	cmd, db := flag.Arg(0), flag.Arg(1)
-	// If query timespan flag is specified, parse to int
-	var timespan int = 0
-	if flagCount == 3 {
-		parsedTimespan, err := strconv.Atoi(flag.Arg(2))
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(2)
-		}
-		timespan = parsedTimespan
-	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-	defer cancel()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cmd != "serve" && cmd != "loadtest" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 10*time.Minute)
+		defer cancel()
+	}
 	adminClient, dataClient := createClients(ctx, db)
-	if err := run(ctx, adminClient, dataClient, os.Stdout, cmd, db, timespan); err != nil {
+	defer dataClient.Close()
+	if err := run(ctx, adminClient, dataClient, os.Stdout, cmd, db, flag.Args()[2:]); err != nil {
 		os.Exit(1)
 	}
 }
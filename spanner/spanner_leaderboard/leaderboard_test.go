@@ -71,21 +71,21 @@ func TestSample(t *testing.T) {
 			t.Errorf("%s failed: got output %q; want it to contain %q", name, out, sub)
 		}
 	}
-	runCommand := func(t *testing.T, cmd string, dbName string, timespan int) string {
+	runCommand := func(t *testing.T, cmd string, dbName string, args ...string) string {
 		t.Helper()
 		var b bytes.Buffer
 		// Set timeout to 600 seconds so it should avoid DeadlineExceeded error.
 		cctx, cancel := context.WithTimeout(ctx, 600*time.Second)
 		defer cancel()
-		if err := run(cctx, adminClient, dataClient, &b, cmd, dbName, timespan); err != nil {
+		if err := run(cctx, adminClient, dataClient, &b, cmd, dbName, args); err != nil {
 			t.Errorf("run(%q, %q): %v", cmd, dbName, err)
 		}
 		return b.String()
 	}
-	mustRunCommand := func(t *testing.T, cmd string, dbName string, timespan int) string {
+	mustRunCommand := func(t *testing.T, cmd string, dbName string, args ...string) string {
 		t.Helper()
 		var b bytes.Buffer
-		if err := run(context.Background(), adminClient, dataClient, &b, cmd, dbName, timespan); err != nil {
+		if err := run(context.Background(), adminClient, dataClient, &b, cmd, dbName, args); err != nil {
 			t.Fatalf("run(%q, %q): %v", cmd, dbName, err)
 		}
 		return b.String()
@@ -93,11 +93,10 @@ func TestSample(t *testing.T) {
 
 	// These commands have to be run in a specific order
 	// since earlier commands setup the database for the subsequent commands.
-	mustRunCommand(t, "createdatabase", dbName, 0)
-	assertContains(t, "insertplayers", runCommand(t, "insertplayers", dbName, 0), "Inserted players")
-	assertContains(t, "insertscores", runCommand(t, "insertscores", dbName, 0), "Inserted scores")
-	assertContains(t, "query", runCommand(t, "query", dbName, 0), "PlayerId: ")
-	assertContains(t, "querywithtimespan 18168", runCommand(t, "querywithtimespan", dbName, 18168), "PlayerId: ")
-	assertContains(t, "querywithtimespan 18730", runCommand(t, "querywithtimespan", dbName, 18730), "PlayerId: ")
-	assertContains(t, "querywithtimespan 186870", runCommand(t, "querywithtimespan", dbName, 186870), "PlayerId: ")
+	assertContains(t, "createdatabase", mustRunCommand(t, "createdatabase", dbName), "Applied migration 3")
+	assertContains(t, "loadtest", runCommand(t, "loadtest", dbName), "SubmitScore: ")
+	assertContains(t, "top", runCommand(t, "top", dbName), "PlayerId: ")
+	// Random scores may not fall in the last day or week.
+	runCommand(t, "top", dbName, "week")
+	runCommand(t, "top", dbName, "day")
 }
@@ -0,0 +1,189 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/golang-samples/spanner/spanner_leaderboard/leaderboardpb"
+)
+
+type uniqueRand struct {
+	used map[int64]bool
+	rand *rand.Rand
+}
+
+func newUniqueRand(seed int64) uniqueRand {
+	return uniqueRand{
+		used: map[int64]bool{},
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// rnd returns a random number in [min, max) it hasn't returned before.
+func (r *uniqueRand) rnd(min, max int64) int64 {
+	for {
+		rnd := r.rand.Int63n(max-min) + min
+		if !r.used[rnd] {
+			r.used[rnd] = true
+			return rnd
+		}
+	}
+}
+
+// loadTestConfig configures a load test.
+type loadTestConfig struct {
+	// Players to create.
+	Players int
+	// ScoresPerPlayer to submit.
+	ScoresPerPlayer int
+	// Concurrency is the number of concurrent requests.
+	Concurrency int
+	// DuplicateEvery resubmits every Nth score, to exercise idempotent
+	// submission. Zero disables duplicates.
+	DuplicateEvery int
+	// Seed seeds the generated data.
+	Seed int64
+}
+
+// loadTest creates players and submits random scores from the past two
+// years through the Leaderboard API, then reports throughput and
+// latencies.
+func loadTest(ctx context.Context, w io.Writer, lb pb.LeaderboardClient, cfg loadTestConfig) error {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	rnd := newUniqueRand(cfg.Seed)
+	run := time.Now().UnixNano()
+
+	type request struct {
+		create *pb.CreatePlayerRequest
+		submit *pb.SubmitScoreRequest
+	}
+	var players, scores []request
+	now := time.Now()
+	start := now.AddDate(0, -24, 0).Unix()
+	n := 0
+	for i := 0; i < cfg.Players; i++ {
+		id := rnd.rnd(minPlayerID, maxPlayerID)
+		players = append(players, request{create: &pb.CreatePlayerRequest{
+			PlayerId:   id,
+			PlayerName: fmt.Sprintf("Player %d", id),
+		}})
+		for j := 0; j < cfg.ScoresPerPlayer; j++ {
+			req := &pb.SubmitScoreRequest{
+				PlayerId:     id,
+				Score:        rnd.rand.Int63n(1000000-1000) + 1000,
+				SubmissionId: fmt.Sprintf("load-%d-%d-%d", run, id, j),
+				Timestamp:    timestamppb.New(time.Unix(rnd.rand.Int63n(now.Unix()-start)+start, 0)),
+			}
+			scores = append(scores, request{submit: req})
+			if n++; cfg.DuplicateEvery > 0 && n%cfg.DuplicateEvery == 0 {
+				scores = append(scores, request{submit: req})
+			}
+		}
+	}
+
+	var (
+		mu         sync.Mutex
+		latencies  []time.Duration
+		errs       int
+		duplicates int
+		firstErr   error
+	)
+	phase := func(name string, reqs []request) {
+		latencies = latencies[:0]
+		errs, duplicates, firstErr = 0, 0, nil
+		work := make(chan request)
+		var wg sync.WaitGroup
+		began := time.Now()
+		for i := 0; i < cfg.Concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for r := range work {
+					t := time.Now()
+					var err error
+					dup := false
+					if r.create != nil {
+						_, err = lb.CreatePlayer(ctx, r.create)
+					} else {
+						var resp *pb.SubmitScoreResponse
+						resp, err = lb.SubmitScore(ctx, r.submit)
+						dup = resp.GetDuplicate()
+					}
+					mu.Lock()
+					latencies = append(latencies, time.Since(t))
+					if err != nil {
+						errs++
+						if firstErr == nil {
+							firstErr = err
+						}
+					}
+					if dup {
+						duplicates++
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, r := range reqs {
+			work <- r
+		}
+		close(work)
+		wg.Wait()
+		elapsed := time.Since(began)
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Fprintf(w, "%s: %d requests in %v (%.1f/s), %d errors, %d duplicates, latency p50 %v p95 %v p99 %v\n",
+			name, len(reqs), elapsed.Round(time.Millisecond), float64(len(reqs))/elapsed.Seconds(), errs, duplicates,
+			percentile(latencies, 50), percentile(latencies, 95), percentile(latencies, 99))
+		if firstErr != nil {
+			fmt.Fprintf(w, "%s: first error: %v\n", name, firstErr)
+		}
+	}
+	phase("CreatePlayer", players)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	phase("SubmitScore", scores)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d SubmitScore requests failed: %w", errs, firstErr)
+	}
+	return nil
+}
+
+// percentile returns the pth percentile of sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(time.Microsecond)
+}
@@ -0,0 +1,203 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"google.golang.org/api/iterator"
+)
+
+// A migration is a numbered schema change. Migrations are applied in
+// order, once each; never edit one that has been released, add a new one.
+type migration struct {
+	version     int64
+	description string
+	ddl         []string
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create Players and Scores",
+		ddl: []string{
+			`CREATE TABLE Players(
+			    PlayerId INT64 NOT NULL,
+			    PlayerName STRING(2048) NOT NULL
+			) PRIMARY KEY(PlayerId)`,
+			`CREATE TABLE Scores(
+			    PlayerId INT64 NOT NULL,
+			    Score INT64 NOT NULL,
+			    Timestamp TIMESTAMP NOT NULL
+			    OPTIONS(allow_commit_timestamp=true)
+			) PRIMARY KEY(PlayerId, Timestamp),
+			INTERLEAVE IN PARENT Players ON DELETE NO ACTION`,
+		},
+	},
+	{
+		version:     2,
+		description: "add submission IDs for idempotent score submission",
+		ddl: []string{
+			`ALTER TABLE Scores ADD COLUMN SubmissionId STRING(64)`,
+			// Scores recorded before this migration have no submission ID,
+			// and are left out of the index.
+			`CREATE UNIQUE NULL_FILTERED INDEX ScoresBySubmission
+			    ON Scores(PlayerId, SubmissionId), INTERLEAVE IN Players`,
+		},
+	},
+	{
+		version:     3,
+		description: "index scores by time for windowed leaderboards",
+		ddl: []string{
+			`CREATE INDEX ScoresByTimestamp ON Scores(Timestamp) STORING (Score)`,
+		},
+	},
+}
+
+// migrationsTable records the applied migrations.
+const migrationsTable = `CREATE TABLE SchemaMigrations(
+    Version INT64 NOT NULL,
+    Description STRING(MAX) NOT NULL,
+    AppliedAt TIMESTAMP NOT NULL OPTIONS(allow_commit_timestamp=true)
+) PRIMARY KEY(Version)`
+
+// migrate applies the migrations that haven't been applied to db yet.
+//
+// A database created by the earlier version of this sample has the tables
+// of migration 1 but no SchemaMigrations table, and starts at version 1.
+//
+// Schema changes and the SchemaMigrations row recording them can't be in
+// one transaction: if migrate stops between them, fix the schema by hand
+// before running it again. Run a single migrate at a time.
+func migrate(ctx context.Context, w io.Writer, adminClient *database.DatabaseAdminClient, client *spanner.Client, db string) error {
+	tables, err := tableNames(ctx, client)
+	if err != nil {
+		return err
+	}
+	if !tables["SchemaMigrations"] {
+		if err := updateDDL(ctx, adminClient, db, []string{migrationsTable}); err != nil {
+			return err
+		}
+		if tables["Players"] {
+			if err := recordMigration(ctx, client, migrations[0]); err != nil {
+				return err
+			}
+		}
+	}
+
+	applied, err := appliedVersion(ctx, client)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.version <= applied {
+			continue
+		}
+		if err := updateDDL(ctx, adminClient, db, m.ddl); err != nil {
+			return fmt.Errorf("migration %d: %w", m.version, err)
+		}
+		if err := recordMigration(ctx, client, m); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Applied migration %d: %s\n", m.version, m.description)
+	}
+	return nil
+}
+
+// pendingMigrations returns the number of migrations not applied to the
+// database yet.
+func pendingMigrations(ctx context.Context, client *spanner.Client) (int, error) {
+	tables, err := tableNames(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	if !tables["SchemaMigrations"] {
+		return len(migrations), nil
+	}
+	applied, err := appliedVersion(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, m := range migrations {
+		if m.version > applied {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func tableNames(ctx context.Context, client *spanner.Client) (map[string]bool, error) {
+	iter := client.Single().Query(ctx, spanner.Statement{
+		SQL: `SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ''`,
+	})
+	defer iter.Stop()
+	tables := make(map[string]bool)
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			return tables, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		if err := row.Columns(&name); err != nil {
+			return nil, err
+		}
+		tables[name] = true
+	}
+}
+
+func appliedVersion(ctx context.Context, client *spanner.Client) (int64, error) {
+	iter := client.Single().Query(ctx, spanner.Statement{
+		SQL: `SELECT IFNULL(MAX(Version), 0) FROM SchemaMigrations`,
+	})
+	defer iter.Stop()
+	row, err := iter.Next()
+	if err != nil {
+		return 0, err
+	}
+	var v int64
+	if err := row.Columns(&v); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func updateDDL(ctx context.Context, adminClient *database.DatabaseAdminClient, db string, ddl []string) error {
+	op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   db,
+		Statements: ddl,
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+func recordMigration(ctx context.Context, client *spanner.Client, m migration) error {
+	_, err := client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert("SchemaMigrations",
+			[]string{"Version", "Description", "AppliedAt"},
+			[]interface{}{m.version, m.description, spanner.CommitTimestamp}),
+	})
+	return err
+}
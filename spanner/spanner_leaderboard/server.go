@@ -0,0 +1,433 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/golang-samples/spanner/spanner_leaderboard/leaderboardpb"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+	maxNeighbors    = 50
+
+	// Random player IDs are in [minPlayerID, maxPlayerID).
+	minPlayerID = int64(1000000000)
+	maxPlayerID = int64(9000000000)
+)
+
+// server implements the Leaderboard service. The HTTP API and load test
+// call the same methods.
+type server struct {
+	pb.UnimplementedLeaderboardServer
+	client *spanner.Client
+
+	// now returns the current time. It defaults to time.Now.
+	now func() time.Time
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newServer(client *spanner.Client) *server {
+	return &server{
+		client: client,
+		now:    time.Now,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *server) CreatePlayer(ctx context.Context, req *pb.CreatePlayerRequest) (*pb.Player, error) {
+	if req.GetPlayerName() == "" || len(req.GetPlayerName()) > 2048 {
+		return nil, status.Error(codes.InvalidArgument, "player_name must have 1 to 2048 characters")
+	}
+	id := req.GetPlayerId()
+	if id < 0 {
+		return nil, status.Error(codes.InvalidArgument, "player_id must be positive")
+	}
+	if id == 0 {
+		s.mu.Lock()
+		id = s.rand.Int63n(maxPlayerID-minPlayerID) + minPlayerID
+		s.mu.Unlock()
+	}
+	_, err := s.client.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert("Players", []string{"PlayerId", "PlayerName"}, []interface{}{id, req.GetPlayerName()}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Player{PlayerId: id, PlayerName: req.GetPlayerName()}, nil
+}
+
+func (s *server) SubmitScore(ctx context.Context, req *pb.SubmitScoreRequest) (*pb.SubmitScoreResponse, error) {
+	if req.GetPlayerId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "player_id must be positive")
+	}
+	if id := req.GetSubmissionId(); id == "" || len(id) > 64 {
+		return nil, status.Error(codes.InvalidArgument, "submission_id must have 1 to 64 characters")
+	}
+	var ts interface{} = spanner.CommitTimestamp
+	if req.GetTimestamp() != nil {
+		if err := req.GetTimestamp().CheckValid(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "timestamp: %v", err)
+		}
+		t := req.GetTimestamp().AsTime()
+		if t.After(s.now()) {
+			return nil, status.Error(codes.InvalidArgument, "timestamp is in the future")
+		}
+		ts = t
+	}
+
+	var resp *pb.SubmitScoreResponse
+	commit, err := s.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		resp = nil
+		prev, err := readSubmission(ctx, txn, req)
+		if err != nil || prev != nil {
+			resp = prev
+			return err
+		}
+		if _, err := txn.ReadRow(ctx, "Players", spanner.Key{req.GetPlayerId()}, []string{"PlayerId"}); err != nil {
+			if spanner.ErrCode(err) == codes.NotFound {
+				return status.Errorf(codes.NotFound, "player %d not found", req.GetPlayerId())
+			}
+			return err
+		}
+		return txn.BufferWrite([]*spanner.Mutation{
+			spanner.Insert("Scores",
+				[]string{"PlayerId", "Score", "Timestamp", "SubmissionId"},
+				[]interface{}{req.GetPlayerId(), req.GetScore(), ts, req.GetSubmissionId()}),
+		})
+	}, spanner.TransactionOptions{})
+	if spanner.ErrCode(err) == codes.AlreadyExists {
+		// A concurrent request with the same submission ID won the race, or
+		// the player already has a score at this timestamp.
+		prev, rerr := readSubmission(ctx, s.client.Single(), req)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if prev == nil {
+			return nil, status.Errorf(codes.AlreadyExists, "player %d already has a score at this timestamp", req.GetPlayerId())
+		}
+		return prev, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil {
+		return resp, nil
+	}
+	if req.GetTimestamp() == nil {
+		ts = commit.CommitTs
+	}
+	return &pb.SubmitScoreResponse{
+		PlayerId:  req.GetPlayerId(),
+		Score:     req.GetScore(),
+		Timestamp: timestamppb.New(ts.(time.Time)),
+	}, nil
+}
+
+// rowReader is implemented by both read-only and read-write transactions.
+type rowReader interface {
+	ReadRowUsingIndex(ctx context.Context, table, index string, key spanner.Key, columns []string) (*spanner.Row, error)
+}
+
+// readSubmission returns the score recorded for the submission ID of req,
+// or nil if there isn't one. Reusing a submission ID for a different score
+// is an error.
+func readSubmission(ctx context.Context, txn rowReader, req *pb.SubmitScoreRequest) (*pb.SubmitScoreResponse, error) {
+	row, err := txn.ReadRowUsingIndex(ctx, "Scores", "ScoresBySubmission",
+		spanner.Key{req.GetPlayerId(), req.GetSubmissionId()}, []string{"Score", "Timestamp"})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var score int64
+	var ts time.Time
+	if err := row.Columns(&score, &ts); err != nil {
+		return nil, err
+	}
+	if score != req.GetScore() {
+		return nil, status.Errorf(codes.AlreadyExists, "submission %q was already recorded with score %d", req.GetSubmissionId(), score)
+	}
+	return &pb.SubmitScoreResponse{
+		PlayerId:  req.GetPlayerId(),
+		Score:     score,
+		Timestamp: timestamppb.New(ts),
+		Duplicate: true,
+	}, nil
+}
+
+// windowStart returns the time the scores of window start at. All-time
+// windows start at the zero time.
+func windowStart(w pb.Window, now time.Time) (time.Time, error) {
+	switch w {
+	case pb.Window_WINDOW_UNSPECIFIED, pb.Window_WINDOW_ALL_TIME:
+		return time.Time{}, nil
+	case pb.Window_WINDOW_DAY:
+		return now.Add(-24 * time.Hour), nil
+	case pb.Window_WINDOW_WEEK:
+		return now.Add(-7 * 24 * time.Hour), nil
+	}
+	return time.Time{}, status.Errorf(codes.InvalidArgument, "invalid window %v", w)
+}
+
+// bestScores returns a query of the best score of each player since the
+// start of a window, bound to @since. Windowed queries use the
+// ScoresByTimestamp index to only read scores in the window.
+//
+// This scans every score in the window: with many players, keep per-window
+// rollups of best scores up to date when scores are submitted instead.
+func bestScores(since time.Time) string {
+	if since.IsZero() {
+		return `SELECT PlayerId, MAX(Score) AS Best FROM Scores GROUP BY PlayerId`
+	}
+	return `SELECT PlayerId, MAX(Score) AS Best
+	        FROM Scores@{FORCE_INDEX=ScoresByTimestamp}
+	        WHERE Timestamp >= @since
+	        GROUP BY PlayerId`
+}
+
+// A position is a place in the total order of a leaderboard: highest best
+// score first, then lowest player ID.
+type position struct {
+	Best     int64
+	PlayerID int64
+}
+
+// entriesAfter returns a statement listing up to limit entries ranked
+// after pos, or from the top if pos is nil.
+func entriesAfter(since time.Time, pos *position, limit int) spanner.Statement {
+	sql := `SELECT b.PlayerId, p.PlayerName, b.Best
+	        FROM (` + bestScores(since) + `) AS b
+	        JOIN Players AS p ON p.PlayerId = b.PlayerId`
+	params := map[string]interface{}{"since": since, "limit": int64(limit)}
+	if pos != nil {
+		sql += ` WHERE b.Best < @best OR (b.Best = @best AND b.PlayerId > @player)`
+		params["best"] = pos.Best
+		params["player"] = pos.PlayerID
+	}
+	sql += ` ORDER BY b.Best DESC, b.PlayerId LIMIT @limit`
+	return spanner.Statement{SQL: sql, Params: params}
+}
+
+// entriesBefore returns a statement listing up to limit entries ranked
+// just before pos, lowest rank first.
+func entriesBefore(since time.Time, pos position, limit int) spanner.Statement {
+	return spanner.Statement{
+		SQL: `SELECT b.PlayerId, p.PlayerName, b.Best
+		      FROM (` + bestScores(since) + `) AS b
+		      JOIN Players AS p ON p.PlayerId = b.PlayerId
+		      WHERE b.Best > @best OR (b.Best = @best AND b.PlayerId < @player)
+		      ORDER BY b.Best, b.PlayerId DESC LIMIT @limit`,
+		Params: map[string]interface{}{"since": since, "best": pos.Best, "player": pos.PlayerID, "limit": int64(limit)},
+	}
+}
+
+// queryEntries runs a statement from entriesAfter or entriesBefore, and
+// numbers the entries from firstRank, adding step for each.
+func queryEntries(ctx context.Context, txn *spanner.ReadOnlyTransaction, stmt spanner.Statement, firstRank, step int64) ([]*pb.Entry, error) {
+	iter := txn.Query(ctx, stmt)
+	defer iter.Stop()
+	var entries []*pb.Entry
+	for rank := firstRank; ; rank += step {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := &pb.Entry{Rank: rank}
+		if err := row.Columns(&e.PlayerId, &e.PlayerName, &e.Score); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// pageToken is the state of a ListTopPlayers call between pages. Pages
+// after the first read at the same time as the first, so players moving up
+// or down the leaderboard don't show up twice or not at all.
+type pageToken struct {
+	Window   pb.Window
+	Since    time.Time
+	ReadTime time.Time
+	Last     position
+	LastRank int64
+}
+
+func (t *pageToken) encode() string {
+	b, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePageToken(s string) (*pageToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	var t pageToken
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	return &t, nil
+}
+
+func (s *server) ListTopPlayers(ctx context.Context, req *pb.ListTopPlayersRequest) (*pb.ListTopPlayersResponse, error) {
+	size := int(req.GetPageSize())
+	if size < 0 || size > maxPageSize {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be from 0 to %d", maxPageSize)
+	}
+	if size == 0 {
+		size = defaultPageSize
+	}
+
+	tok := &pageToken{Window: req.GetWindow()}
+	txn := s.client.Single()
+	if req.GetPageToken() != "" {
+		var err error
+		if tok, err = decodePageToken(req.GetPageToken()); err != nil {
+			return nil, err
+		}
+		if tok.Window != req.GetWindow() {
+			return nil, status.Error(codes.InvalidArgument, "page_token is for another window")
+		}
+		txn = s.client.Single().WithTimestampBound(spanner.ReadTimestamp(tok.ReadTime))
+	} else {
+		var err error
+		if tok.Since, err = windowStart(req.GetWindow(), s.now()); err != nil {
+			return nil, err
+		}
+	}
+	defer txn.Close()
+
+	var after *position
+	if tok.LastRank > 0 {
+		after = &tok.Last
+	}
+	// Read one more entry than the page size, to know if there's a next
+	// page.
+	entries, err := queryEntries(ctx, txn, entriesAfter(tok.Since, after, size+1), tok.LastRank+1, 1)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListTopPlayersResponse{Entries: entries}
+	if len(entries) > size {
+		resp.Entries = entries[:size]
+		last := resp.Entries[size-1]
+		if tok.ReadTime.IsZero() {
+			if tok.ReadTime, err = txn.Timestamp(); err != nil {
+				return nil, err
+			}
+		}
+		tok.Last = position{Best: last.GetScore(), PlayerID: last.GetPlayerId()}
+		tok.LastRank = last.GetRank()
+		resp.NextPageToken = tok.encode()
+	}
+	return resp, nil
+}
+
+func (s *server) GetPlayerRank(ctx context.Context, req *pb.GetPlayerRankRequest) (*pb.GetPlayerRankResponse, error) {
+	n := int(req.GetNeighbors())
+	if n < 0 || n > maxNeighbors {
+		return nil, status.Errorf(codes.InvalidArgument, "neighbors must be from 0 to %d", maxNeighbors)
+	}
+	since, err := windowStart(req.GetWindow(), s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Read everything at the same timestamp, for consistent ranks.
+	txn := s.client.ReadOnlyTransaction()
+	defer txn.Close()
+
+	player := &pb.Entry{PlayerId: req.GetPlayerId()}
+	row, err := txn.ReadRow(ctx, "Players", spanner.Key{req.GetPlayerId()}, []string{"PlayerName"})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, status.Errorf(codes.NotFound, "player %d not found", req.GetPlayerId())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := row.Columns(&player.PlayerName); err != nil {
+		return nil, err
+	}
+
+	var best spanner.NullInt64
+	if err := queryRow(ctx, txn, spanner.Statement{
+		SQL:    `SELECT MAX(Score) FROM Scores WHERE PlayerId = @player AND Timestamp >= @since`,
+		Params: map[string]interface{}{"player": req.GetPlayerId(), "since": since},
+	}, &best); err != nil {
+		return nil, err
+	}
+	if !best.Valid {
+		return nil, status.Errorf(codes.NotFound, "player %d has no scores in window %v", req.GetPlayerId(), req.GetWindow())
+	}
+	player.Score = best.Int64
+	pos := position{Best: best.Int64, PlayerID: req.GetPlayerId()}
+
+	var ahead int64
+	if err := queryRow(ctx, txn, spanner.Statement{
+		SQL: `SELECT COUNT(*) FROM (` + bestScores(since) + `) AS b
+		      WHERE b.Best > @best OR (b.Best = @best AND b.PlayerId < @player)`,
+		Params: map[string]interface{}{"since": since, "best": pos.Best, "player": pos.PlayerID},
+	}, &ahead); err != nil {
+		return nil, err
+	}
+	player.Rank = ahead + 1
+
+	resp := &pb.GetPlayerRankResponse{Player: player}
+	if n == 0 {
+		return resp, nil
+	}
+	above, err := queryEntries(ctx, txn, entriesBefore(since, pos, n), player.Rank-1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(above) - 1; i >= 0; i-- {
+		resp.Above = append(resp.Above, above[i])
+	}
+	if resp.Below, err = queryEntries(ctx, txn, entriesAfter(since, &pos, n), player.Rank+1, 1); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// queryRow runs a query returning a single row, and stores its columns in
+// ptrs.
+func queryRow(ctx context.Context, txn *spanner.ReadOnlyTransaction, stmt spanner.Statement, ptrs ...interface{}) error {
+	iter := txn.Query(ctx, stmt)
+	defer iter.Stop()
+	row, err := iter.Next()
+	if err != nil {
+		return err
+	}
+	return row.Columns(ptrs...)
+}
@@ -0,0 +1,413 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	instancepb "cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/golang-samples/spanner/spanner_leaderboard/leaderboardpb"
+)
+
+func TestPageToken(t *testing.T) {
+	tok := &pageToken{
+		Window:   pb.Window_WINDOW_WEEK,
+		Since:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		ReadTime: time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC),
+		Last:     position{Best: 500, PlayerID: 7},
+		LastRank: 10,
+	}
+	got, err := decodePageToken(tok.encode())
+	if err != nil {
+		t.Fatalf("decodePageToken: %v", err)
+	}
+	if *got != *tok {
+		t.Errorf("decodePageToken = %+v, want %+v", got, tok)
+	}
+	for _, bad := range []string{"!", "bm90IGpzb24"} {
+		if _, err := decodePageToken(bad); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("decodePageToken(%q) = %v, want InvalidArgument", bad, err)
+		}
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		w    pb.Window
+		want time.Time
+	}{
+		{pb.Window_WINDOW_UNSPECIFIED, time.Time{}},
+		{pb.Window_WINDOW_ALL_TIME, time.Time{}},
+		{pb.Window_WINDOW_DAY, now.Add(-24 * time.Hour)},
+		{pb.Window_WINDOW_WEEK, now.Add(-7 * 24 * time.Hour)},
+	}
+	for _, tc := range tests {
+		got, err := windowStart(tc.w, now)
+		if err != nil || !got.Equal(tc.want) {
+			t.Errorf("windowStart(%v) = %v, %v, want %v", tc.w, got, err, tc.want)
+		}
+	}
+	if _, err := windowStart(pb.Window(42), now); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("windowStart(42) = %v, want InvalidArgument", err)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	for s, want := range map[string]pb.Window{
+		"":           pb.Window_WINDOW_ALL_TIME,
+		"all":        pb.Window_WINDOW_ALL_TIME,
+		"DAY":        pb.Window_WINDOW_DAY,
+		"week":       pb.Window_WINDOW_WEEK,
+		"WINDOW_DAY": pb.Window_WINDOW_DAY,
+	} {
+		if got, err := parseWindow(s); err != nil || got != want {
+			t.Errorf("parseWindow(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := parseWindow("month"); err == nil {
+		t.Error("parseWindow(month) succeeded, want error")
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, status.Error(codes.NotFound, "player 1 not found"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "player 1 not found") {
+		t.Errorf("body = %q, want the message", body)
+	}
+}
+
+func TestUniqueRand(t *testing.T) {
+	r := newUniqueRand(1)
+	seen := map[int64]bool{}
+	for i := 0; i < 10; i++ {
+		v := r.rnd(0, 10)
+		if v < 0 || v >= 10 || seen[v] {
+			t.Fatalf("rnd = %d, seen %v", v, seen)
+		}
+		seen[v] = true
+	}
+}
+
+func TestFormatWithCommas(t *testing.T) {
+	for n, want := range map[int64]string{7: "7", 1000: "1,000", 123456: "123,456", 1234567: "1,234,567"} {
+		if got := formatWithCommas(n); got != want {
+			t.Errorf("formatWithCommas(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// newEmulatorDB creates a database on the Spanner emulator. Without
+// migrate, it has no tables.
+func newEmulatorDB(t *testing.T, migrated bool) (*database.DatabaseAdminClient, *spanner.Client, string) {
+	t.Helper()
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		t.Skip("Skipping emulator test. Set SPANNER_EMULATOR_HOST.")
+	}
+	ctx := context.Background()
+	const inst = "projects/test-project/instances/leaderboard"
+
+	instanceAdmin, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		t.Fatalf("NewInstanceAdminClient: %v", err)
+	}
+	defer instanceAdmin.Close()
+	op, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/test-project",
+		InstanceId: "leaderboard",
+		Instance: &instancepb.Instance{
+			Config:      "projects/test-project/instanceConfigs/emulator-config",
+			DisplayName: "leaderboard",
+			NodeCount:   1,
+		},
+	})
+	if err == nil {
+		_, err = op.Wait(ctx)
+	}
+	if err != nil && status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("CreateInstance: %v", err)
+	}
+
+	db := fmt.Sprintf("%s/databases/lb-%s", inst, strings.ReplaceAll(randomID(), "-", "")[:20])
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		t.Fatalf("NewDatabaseAdminClient: %v", err)
+	}
+	t.Cleanup(func() { adminClient.Close() })
+	if err := createDatabase(ctx, io.Discard, adminClient, db); err != nil {
+		t.Fatalf("createDatabase: %v", err)
+	}
+	client, err := spanner.NewClient(ctx, db)
+	if err != nil {
+		t.Fatalf("spanner.NewClient: %v", err)
+	}
+	t.Cleanup(client.Close)
+	if migrated {
+		if err := migrate(ctx, io.Discard, adminClient, client, db); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+	}
+	return adminClient, client, db
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	adminClient, client, db := newEmulatorDB(t, false)
+
+	// A database created by the earlier version of the sample.
+	if err := updateDDL(ctx, adminClient, db, migrations[0].ddl); err != nil {
+		t.Fatalf("updateDDL: %v", err)
+	}
+	if n, err := pendingMigrations(ctx, client); err != nil || n != len(migrations) {
+		t.Errorf("pendingMigrations = %d, %v, want %d", n, err, len(migrations))
+	}
+	var b bytes.Buffer
+	if err := migrate(ctx, &b, adminClient, client, db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if out := b.String(); strings.Contains(out, "migration 1:") || !strings.Contains(out, "migration 3:") {
+		t.Errorf("migrate output = %q, want migrations 2 and 3", out)
+	}
+	b.Reset()
+	if err := migrate(ctx, &b, adminClient, client, db); err != nil {
+		t.Fatalf("migrate again: %v", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("migrate again output = %q, want nothing", b.String())
+	}
+	if n, err := pendingMigrations(ctx, client); err != nil || n != 0 {
+		t.Errorf("pendingMigrations = %d, %v, want 0", n, err)
+	}
+}
+
+func TestSubmitScore(t *testing.T) {
+	ctx := context.Background()
+	_, client, _ := newEmulatorDB(t, true)
+	s := newServer(client)
+
+	p, err := s.CreatePlayer(ctx, &pb.CreatePlayerRequest{PlayerName: "Ada"})
+	if err != nil {
+		t.Fatalf("CreatePlayer: %v", err)
+	}
+	req := &pb.SubmitScoreRequest{PlayerId: p.GetPlayerId(), Score: 100, SubmissionId: "game-1"}
+	first, err := s.SubmitScore(ctx, req)
+	if err != nil {
+		t.Fatalf("SubmitScore: %v", err)
+	}
+	if first.GetDuplicate() || first.GetTimestamp() == nil {
+		t.Errorf("SubmitScore = %v, want a new score with a timestamp", first)
+	}
+	again, err := s.SubmitScore(ctx, req)
+	if err != nil {
+		t.Fatalf("SubmitScore again: %v", err)
+	}
+	if !again.GetDuplicate() || !again.GetTimestamp().AsTime().Equal(first.GetTimestamp().AsTime()) {
+		t.Errorf("SubmitScore again = %v, want the first score as a duplicate", again)
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.SubmitScoreRequest
+		want codes.Code
+	}{
+		{"reused ID", &pb.SubmitScoreRequest{PlayerId: p.GetPlayerId(), Score: 5, SubmissionId: "game-1"}, codes.AlreadyExists},
+		{"unknown player", &pb.SubmitScoreRequest{PlayerId: 1, Score: 5, SubmissionId: "x"}, codes.NotFound},
+		{"no ID", &pb.SubmitScoreRequest{PlayerId: p.GetPlayerId(), Score: 5}, codes.InvalidArgument},
+		{"future", &pb.SubmitScoreRequest{PlayerId: p.GetPlayerId(), Score: 5, SubmissionId: "y",
+			Timestamp: timestamppb.New(time.Now().Add(time.Hour))}, codes.InvalidArgument},
+	}
+	for _, tc := range tests {
+		if _, err := s.SubmitScore(ctx, tc.req); status.Code(err) != tc.want {
+			t.Errorf("%s: SubmitScore = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+// submitScores creates players 1 to len(best), each with a score of
+// best[i] two hours ago, and 1000 + i ten days ago.
+func submitScores(t *testing.T, s *server, best []int64) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	for i, score := range best {
+		id := int64(i + 1)
+		if _, err := s.CreatePlayer(ctx, &pb.CreatePlayerRequest{PlayerId: id, PlayerName: fmt.Sprintf("Player %d", id)}); err != nil {
+			t.Fatalf("CreatePlayer: %v", err)
+		}
+		for j, sub := range []*pb.SubmitScoreRequest{
+			{Score: score, Timestamp: timestamppb.New(now.Add(-2 * time.Hour))},
+			{Score: 1000 + id, Timestamp: timestamppb.New(now.Add(-10 * 24 * time.Hour))},
+		} {
+			sub.PlayerId = id
+			sub.SubmissionId = fmt.Sprint(j)
+			if _, err := s.SubmitScore(ctx, sub); err != nil {
+				t.Fatalf("SubmitScore: %v", err)
+			}
+		}
+	}
+}
+
+func entryIDs(entries []*pb.Entry) string {
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, fmt.Sprintf("%d:%d", e.GetRank(), e.GetPlayerId()))
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestListTopPlayers(t *testing.T) {
+	ctx := context.Background()
+	_, client, _ := newEmulatorDB(t, true)
+	s := newServer(client)
+	// Players 2 and 4 tie, and rank by player ID.
+	submitScores(t, s, []int64{10, 50, 30, 50, 20})
+
+	var pages []string
+	req := &pb.ListTopPlayersRequest{Window: pb.Window_WINDOW_DAY, PageSize: 2}
+	for i := 0; ; i++ {
+		resp, err := s.ListTopPlayers(ctx, req)
+		if err != nil {
+			t.Fatalf("ListTopPlayers: %v", err)
+		}
+		pages = append(pages, entryIDs(resp.GetEntries()))
+		if i == 0 {
+			// Pages after the first don't see later scores.
+			if _, err := s.SubmitScore(ctx, &pb.SubmitScoreRequest{PlayerId: 1, Score: 99, SubmissionId: "late"}); err != nil {
+				t.Fatalf("SubmitScore: %v", err)
+			}
+		}
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	want := []string{"1:2 2:4", "3:3 4:5", "5:1"}
+	if strings.Join(pages, "|") != strings.Join(want, "|") {
+		t.Errorf("pages = %q, want %q", pages, want)
+	}
+
+	// All time, the scores from ten days ago count.
+	resp, err := s.ListTopPlayers(ctx, &pb.ListTopPlayersRequest{PageSize: 3})
+	if err != nil {
+		t.Fatalf("ListTopPlayers: %v", err)
+	}
+	if got, want := entryIDs(resp.GetEntries()), "1:5 2:4 3:3"; got != want {
+		t.Errorf("all time = %q, want %q", got, want)
+	}
+
+	if _, err := s.ListTopPlayers(ctx, &pb.ListTopPlayersRequest{Window: pb.Window_WINDOW_WEEK, PageToken: req.PageToken}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListTopPlayers with another window's token = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGetPlayerRank(t *testing.T) {
+	ctx := context.Background()
+	_, client, _ := newEmulatorDB(t, true)
+	s := newServer(client)
+	submitScores(t, s, []int64{10, 50, 30, 50, 20})
+
+	resp, err := s.GetPlayerRank(ctx, &pb.GetPlayerRankRequest{PlayerId: 3, Window: pb.Window_WINDOW_WEEK, Neighbors: 2})
+	if err != nil {
+		t.Fatalf("GetPlayerRank: %v", err)
+	}
+	if got := entryIDs([]*pb.Entry{resp.GetPlayer()}); got != "3:3" {
+		t.Errorf("player = %q, want 3:3", got)
+	}
+	if got, want := entryIDs(resp.GetAbove()), "1:2 2:4"; got != want {
+		t.Errorf("above = %q, want %q", got, want)
+	}
+	if got, want := entryIDs(resp.GetBelow()), "4:5 5:1"; got != want {
+		t.Errorf("below = %q, want %q", got, want)
+	}
+
+	if _, err := s.GetPlayerRank(ctx, &pb.GetPlayerRankRequest{PlayerId: 42}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetPlayerRank(unknown) = %v, want NotFound", err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	_, client, _ := newEmulatorDB(t, true)
+	srv := httptest.NewServer(httpHandler(newServer(client)))
+	defer srv.Close()
+
+	do := func(method, path, body string, wantCode int) string {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != wantCode {
+			t.Fatalf("%s %s = %d %s, want %d", method, path, resp.StatusCode, b, wantCode)
+		}
+		return string(b)
+	}
+	do("POST", "/v1/players", `{"playerId": "7", "playerName": "Grace"}`, http.StatusOK)
+	do("POST", "/v1/scores", `{"playerId": "7", "score": "42", "submissionId": "s1"}`, http.StatusOK)
+	if body := do("POST", "/v1/scores", `{"playerId": "7", "score": "42", "submissionId": "s1"}`, http.StatusOK); !strings.Contains(body, "duplicate") {
+		t.Errorf("resubmission = %s, want duplicate", body)
+	}
+	if body := do("GET", "/v1/players/7/rank?window=day", "", http.StatusOK); !strings.Contains(body, "Grace") {
+		t.Errorf("rank = %s, want Grace", body)
+	}
+	if body := do("GET", "/v1/leaderboard?window=week&page_size=5", "", http.StatusOK); !strings.Contains(strings.ReplaceAll(body, " ", ""), `"score":"42"`) {
+		t.Errorf("leaderboard = %s, want score 42", body)
+	}
+	do("GET", "/v1/leaderboard?window=month", "", http.StatusBadRequest)
+	do("GET", "/v1/players/8/rank", "", http.StatusNotFound)
+}
+
+func TestLoadTest(t *testing.T) {
+	ctx := context.Background()
+	_, client, _ := newEmulatorDB(t, true)
+
+	var b bytes.Buffer
+	err := runLoadTest(ctx, &b, client, "", loadTestConfig{Players: 5, ScoresPerPlayer: 4, Concurrency: 4, DuplicateEvery: 5, Seed: 1})
+	if err != nil {
+		t.Fatalf("runLoadTest: %v\n%s", err, b.String())
+	}
+	if out := b.String(); !strings.Contains(out, "SubmitScore: 24 requests") || !strings.Contains(out, "4 duplicates") {
+		t.Errorf("runLoadTest output = %q, want 24 submissions with 4 duplicates", out)
+	}
+	resp, err := newServer(client).ListTopPlayers(ctx, &pb.ListTopPlayersRequest{PageSize: 10})
+	if err != nil {
+		t.Fatalf("ListTopPlayers: %v", err)
+	}
+	if len(resp.GetEntries()) != 5 {
+		t.Errorf("ListTopPlayers returned %d entries, want 5", len(resp.GetEntries()))
+	}
+}
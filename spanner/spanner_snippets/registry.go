@@ -0,0 +1,285 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+)
+
+type command func(ctx context.Context, w io.Writer, client *spanner.Client) error
+type adminCommand func(ctx context.Context, w io.Writer, adminClient *database.DatabaseAdminClient, database string) error
+
+// env is what a snippet runs with.
+type env struct {
+	w           io.Writer
+	client      *spanner.Client
+	adminClient *database.DatabaseAdminClient
+	database    string
+	// arg is the optional command line argument after the database.
+	arg string
+}
+
+// A snippet is a command of this program.
+type snippet struct {
+	name    string
+	dialect adminpb.DatabaseDialect
+	// createsDatabase snippets are given the name of a database that doesn't
+	// exist yet. Other snippets get a database with schema.
+	createsDatabase bool
+	// schema is the DDL of the tables the snippet uses.
+	schema []string
+	// requires are snippets of the same dialect that must run first on the
+	// same database, e.g. to add a column or write rows.
+	requires []string
+	// seed is DML the runner applies after the required snippets and before
+	// this one.
+	seed []string
+	// databaseRole is the role of the data client, if any.
+	databaseRole string
+	// noEmulator snippets use features the emulator doesn't support, such
+	// as database roles and IAM.
+	noEmulator bool
+	// unordered output is compared to golden files without regard to line
+	// order, for queries without ORDER BY.
+	unordered bool
+	run       func(ctx context.Context, e *env) error
+}
+
+func data(fn command) func(context.Context, *env) error {
+	return func(ctx context.Context, e *env) error { return fn(ctx, e.w, e.client) }
+}
+
+func admin(fn adminCommand) func(context.Context, *env) error {
+	return func(ctx context.Context, e *env) error { return fn(ctx, e.w, e.adminClient, e.database) }
+}
+
+const (
+	googleSQL  = adminpb.DatabaseDialect_GOOGLE_STANDARD_SQL
+	postgreSQL = adminpb.DatabaseDialect_POSTGRESQL
+)
+
+// The schemas created by createdatabase and pgcreatedatabase.
+var (
+	singersSchema = []string{
+		`CREATE TABLE Singers (
+			SingerId   INT64 NOT NULL,
+			FirstName  STRING(1024),
+			LastName   STRING(1024),
+			SingerInfo BYTES(MAX)
+		) PRIMARY KEY (SingerId)`,
+		`CREATE TABLE Albums (
+			SingerId     INT64 NOT NULL,
+			AlbumId      INT64 NOT NULL,
+			AlbumTitle   STRING(MAX)
+		) PRIMARY KEY (SingerId, AlbumId),
+		INTERLEAVE IN PARENT Singers ON DELETE CASCADE`,
+	}
+	pgSingersSchema = []string{
+		`CREATE TABLE Singers (
+			SingerId   bigint NOT NULL PRIMARY KEY,
+			FirstName  varchar(1024),
+			LastName   varchar(1024),
+			SingerInfo bytea
+		)`,
+		`CREATE TABLE Albums (
+			AlbumId      bigint NOT NULL,
+			SingerId     bigint NOT NULL REFERENCES Singers (SingerId),
+			AlbumTitle   text,
+			PRIMARY KEY(SingerId, AlbumId)
+		)`,
+	}
+	// pgAlbumsWithBudgets is the data write, addnewcolumn and update leave
+	// in GoogleSQL databases. Mutations name tables and columns exactly,
+	// and PostgreSQL folds unquoted names to lower case, so write and update
+	// don't work on the PostgreSQL schema.
+	pgAlbumsWithBudgets = []string{
+		`INSERT INTO Singers (SingerId, FirstName, LastName) VALUES
+			(1, 'Marc', 'Richards'), (2, 'Catalina', 'Smith')`,
+		`INSERT INTO Albums (SingerId, AlbumId, AlbumTitle, MarketingBudget) VALUES
+			(1, 1, 'Total Junk', 100000),
+			(1, 2, 'Go, Go, Go', NULL),
+			(2, 1, 'Green', NULL),
+			(2, 2, 'Forever Hold Your Peace', 500000),
+			(2, 3, 'Terrified', NULL)`,
+	}
+)
+
+func withDDL(schema []string, ddl ...string) []string {
+	return append(append([]string(nil), schema...), ddl...)
+}
+
+var snippets = newRegistry([]*snippet{
+	{name: "createdatabase", dialect: googleSQL, createsDatabase: true, run: admin(createDatabase)},
+	{name: "write", dialect: googleSQL, schema: singersSchema, run: data(write)},
+	{name: "read", dialect: googleSQL, schema: singersSchema, requires: []string{"write"}, run: data(read)},
+	{name: "query", dialect: googleSQL, schema: singersSchema, requires: []string{"write"}, unordered: true, run: data(query)},
+	{name: "addnewcolumn", dialect: googleSQL, schema: singersSchema, run: admin(addNewColumn)},
+	{name: "update", dialect: googleSQL, schema: singersSchema, requires: []string{"write", "addnewcolumn"}, run: data(update)},
+	{name: "querynewcolumn", dialect: googleSQL, schema: singersSchema, requires: []string{"update"}, unordered: true, run: data(queryNewColumn)},
+	{name: "dmlwrite", dialect: googleSQL, schema: singersSchema, run: data(writeUsingDML)},
+	{name: "querywithparameter", dialect: googleSQL, schema: singersSchema, requires: []string{"dmlwrite"}, run: data(queryWithParameter)},
+	{name: "dmlwritetxn", dialect: googleSQL, schema: singersSchema, requires: []string{"update"}, run: data(writeWithTransactionUsingDML)},
+	{
+		name:     "readindex",
+		dialect:  googleSQL,
+		schema:   withDDL(singersSchema, "CREATE INDEX AlbumsByAlbumTitle ON Albums(AlbumTitle)"),
+		requires: []string{"write"},
+		run:      data(readUsingIndex),
+	},
+	{name: "addstoringindex", dialect: googleSQL, schema: singersSchema, requires: []string{"addnewcolumn"}, run: admin(addStoringIndex)},
+	{name: "readstoringindex", dialect: googleSQL, schema: singersSchema, requires: []string{"addstoringindex", "update"}, run: data(readStoringIndex)},
+	{name: "readonlytransaction", dialect: googleSQL, schema: singersSchema, requires: []string{"write"}, unordered: true, run: data(readOnlyTransaction)},
+	{name: "addanddropdatabaserole", dialect: googleSQL, schema: singersSchema, noEmulator: true, run: admin(addAndDropDatabaseRole)},
+	{name: "listdatabaseroles", dialect: googleSQL, schema: singersSchema, requires: []string{"addanddropdatabaserole"}, noEmulator: true, run: admin(listDatabaseRoles)},
+	{
+		name:         "readdatawithdatabaserole",
+		dialect:      googleSQL,
+		schema:       singersSchema,
+		requires:     []string{"write", "addanddropdatabaserole"},
+		databaseRole: "parent",
+		noEmulator:   true,
+		run:          data(read),
+	},
+	{
+		name:       "enablefinegrainedaccess",
+		dialect:    googleSQL,
+		schema:     singersSchema,
+		requires:   []string{"addanddropdatabaserole"},
+		noEmulator: true,
+		run: func(ctx context.Context, e *env) error {
+			return enableFineGrainedAccess(ctx, e.w, e.adminClient, e.database, e.arg)
+		},
+	},
+
+	{name: "pgcreatedatabase", dialect: postgreSQL, createsDatabase: true, run: admin(pgCreateDatabase)},
+	{name: "pgdmlwrite", dialect: postgreSQL, schema: pgSingersSchema, run: data(pgWriteUsingDML)},
+	{name: "pgqueryparameter", dialect: postgreSQL, schema: pgSingersSchema, requires: []string{"pgdmlwrite"}, run: data(pgQueryParameter)},
+	{name: "pgaddnewcolumn", dialect: postgreSQL, schema: pgSingersSchema, run: admin(pgAddNewColumn)},
+	{
+		name:      "pgquerynewcolumn",
+		dialect:   postgreSQL,
+		schema:    pgSingersSchema,
+		requires:  []string{"pgaddnewcolumn"},
+		seed:      pgAlbumsWithBudgets,
+		unordered: true,
+		run:       data(pgQueryNewColumn),
+	},
+	{
+		name:     "pgdmlwritetxn",
+		dialect:  postgreSQL,
+		schema:   pgSingersSchema,
+		requires: []string{"pgaddnewcolumn"},
+		seed:     pgAlbumsWithBudgets,
+		run:      data(pgWriteWithTransactionUsingDML),
+	},
+	{name: "pgaddstoringindex", dialect: postgreSQL, schema: pgSingersSchema, requires: []string{"pgaddnewcolumn"}, run: admin(pgAddStoringIndex)},
+})
+
+// A registry is a set of snippets, checked for consistency.
+type registry struct {
+	byName map[string]*snippet
+	// order lists every snippet after the snippets it requires.
+	order []*snippet
+}
+
+// newRegistry returns a registry of list, in order. It panics if names
+// aren't unique, or requirements are missing, cyclic or of another dialect.
+func newRegistry(list []*snippet) *registry {
+	r := &registry{byName: make(map[string]*snippet)}
+	for _, s := range list {
+		if r.byName[s.name] != nil {
+			panic("duplicate snippet " + s.name)
+		}
+		r.byName[s.name] = s
+	}
+	for _, s := range list {
+		for _, req := range s.requires {
+			dep := r.byName[req]
+			if dep == nil {
+				panic(fmt.Sprintf("snippet %s requires unknown snippet %s", s.name, req))
+			}
+			if dep.dialect != s.dialect {
+				panic(fmt.Sprintf("snippet %s requires %s of another dialect", s.name, req))
+			}
+			if dep.createsDatabase {
+				panic(fmt.Sprintf("snippet %s requires %s, which creates its database", s.name, req))
+			}
+		}
+	}
+	done := make(map[string]bool)
+	for _, s := range list {
+		order, err := r.plan(s.name)
+		if err != nil {
+			panic(err)
+		}
+		for _, p := range order {
+			if !done[p.name] {
+				done[p.name] = true
+				r.order = append(r.order, p)
+			}
+		}
+	}
+	return r
+}
+
+// plan returns the snippets to run for name: its requirements, each after
+// the ones it requires, then the snippet itself.
+func (r *registry) plan(name string) ([]*snippet, error) {
+	var order []*snippet
+	state := make(map[string]int) // 1 visiting, 2 done
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		s := r.byName[name]
+		if s == nil {
+			return fmt.Errorf("unknown snippet %s", name)
+		}
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("snippets require each other: %v", append(path, name))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, req := range s.requires {
+			if err := visit(req, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, s)
+		return nil
+	}
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// names returns the names of the snippets, sorted.
+func (r *registry) names() []string {
+	var names []string
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
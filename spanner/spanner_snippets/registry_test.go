@@ -0,0 +1,222 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	instancepb "cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func names(list []*snippet) []string {
+	var names []string
+	for _, s := range list {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"createdatabase", "createdatabase"},
+		{"read", "write read"},
+		{"querynewcolumn", "write addnewcolumn update querynewcolumn"},
+		{"readstoringindex", "addnewcolumn addstoringindex write update readstoringindex"},
+		{"pgdmlwritetxn", "pgaddnewcolumn pgdmlwritetxn"},
+	}
+	for _, tc := range tests {
+		plan, err := snippets.plan(tc.name)
+		if err != nil {
+			t.Fatalf("plan(%q): %v", tc.name, err)
+		}
+		if got := strings.Join(names(plan), " "); got != tc.want {
+			t.Errorf("plan(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if _, err := snippets.plan("nosuchsnippet"); err == nil {
+		t.Error("plan(nosuchsnippet) succeeded, want error")
+	}
+}
+
+func TestOrder(t *testing.T) {
+	if got, want := len(snippets.order), len(snippets.byName); got != want {
+		t.Fatalf("order has %d snippets, want %d", got, want)
+	}
+	seen := make(map[string]bool)
+	for _, s := range snippets.order {
+		for _, req := range s.requires {
+			if !seen[req] {
+				t.Errorf("%s is ordered before %s, which it requires", s.name, req)
+			}
+		}
+		seen[s.name] = true
+	}
+}
+
+func TestNewRegistryPanics(t *testing.T) {
+	noop := func(context.Context, *env) error { return nil }
+	tests := []struct {
+		desc string
+		list []*snippet
+	}{
+		{"duplicate", []*snippet{{name: "a", run: noop}, {name: "a", run: noop}}},
+		{"unknown requirement", []*snippet{{name: "a", requires: []string{"b"}, run: noop}}},
+		{"cycle", []*snippet{
+			{name: "a", requires: []string{"b"}, run: noop},
+			{name: "b", requires: []string{"a"}, run: noop},
+		}},
+		{"dialect", []*snippet{
+			{name: "a", dialect: postgreSQL, run: noop},
+			{name: "b", dialect: googleSQL, requires: []string{"a"}, run: noop},
+		}},
+		{"creates database", []*snippet{
+			{name: "a", createsDatabase: true, run: noop},
+			{name: "b", requires: []string{"a"}, run: noop},
+		}},
+	}
+	for _, tc := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: newRegistry didn't panic", tc.desc)
+				}
+			}()
+			newRegistry(tc.list)
+		}()
+	}
+}
+
+func TestGoldenFilesExist(t *testing.T) {
+	for _, s := range snippets.order {
+		_, err := os.Stat(goldenFile(s))
+		if s.noEmulator {
+			if err == nil {
+				t.Errorf("%s doesn't run on the emulator but has a golden file", s.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s has no golden file: %v", s.name, err)
+		}
+	}
+}
+
+func TestNewDatabaseID(t *testing.T) {
+	id := newDatabaseID()
+	if !regexp.MustCompile(`^[a-z][a-z0-9_-]{1,29}$`).MatchString(id) {
+		t.Errorf("newDatabaseID() = %q, not a valid database ID", id)
+	}
+	if id == newDatabaseID() {
+		t.Errorf("newDatabaseID() returned %q twice", id)
+	}
+}
+
+func goldenFile(s *snippet) string {
+	return filepath.Join("testdata", "golden", s.name+".txt")
+}
+
+var databasePath = regexp.MustCompile(`projects/[^/]+/instances/[^/]+/databases/[a-z0-9_-]+`)
+
+// normalize replaces database names, which differ on each run, and sorts
+// the lines of unordered output.
+func normalize(s *snippet, out string) string {
+	out = databasePath.ReplaceAllString(out, "<database>")
+	if !s.unordered {
+		return out
+	}
+	lines := strings.SplitAfter(out, "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
+func TestGolden(t *testing.T) {
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		t.Skip("Skipping emulator test. Set SPANNER_EMULATOR_HOST.")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	instanceAdmin, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		t.Fatalf("NewInstanceAdminClient: %v", err)
+	}
+	defer instanceAdmin.Close()
+	op, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/test-project",
+		InstanceId: "snippets",
+		Instance: &instancepb.Instance{
+			Config:      "projects/test-project/instanceConfigs/emulator-config",
+			DisplayName: "snippets",
+			NodeCount:   1,
+		},
+	})
+	if err == nil {
+		_, err = op.Wait(ctx)
+	}
+	if err != nil && status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("CreateInstance: %v", err)
+	}
+
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		t.Fatalf("NewDatabaseAdminClient: %v", err)
+	}
+	defer adminClient.Close()
+	r := &runner{adminClient: adminClient, instance: "projects/test-project/instances/snippets"}
+
+	for _, s := range snippets.order {
+		if s.noEmulator {
+			continue
+		}
+		t.Run(s.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := r.run(ctx, &buf, s, ""); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			got := normalize(s, buf.String())
+			if *updateGolden {
+				if err := os.WriteFile(goldenFile(s), []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenFile(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != normalize(s, string(want)) {
+				t.Errorf("output:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+)
+
+// A runner runs snippets in databases of their own, which it creates in an
+// instance and drops afterwards.
+type runner struct {
+	adminClient *database.DatabaseAdminClient
+	instance    string // projects/<project>/instances/<instance>
+}
+
+// run runs s with its requirements in a new database. Only the output of s
+// is written to w.
+func (r *runner) run(ctx context.Context, w io.Writer, s *snippet, arg string) (err error) {
+	plan, err := snippets.plan(s.name)
+	if err != nil {
+		return err
+	}
+	db := r.instance + "/databases/" + newDatabaseID()
+	defer func() {
+		dropErr := r.adminClient.DropDatabase(ctx, &adminpb.DropDatabaseRequest{Database: db})
+		if err == nil && dropErr != nil {
+			err = fmt.Errorf("drop %s: %w", db, dropErr)
+		}
+	}()
+	if s.createsDatabase {
+		return s.run(ctx, &env{w: w, adminClient: r.adminClient, database: db, arg: arg})
+	}
+	if err := r.createDatabase(ctx, db, s.dialect, s.schema); err != nil {
+		return err
+	}
+
+	client, err := spanner.NewClient(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	for _, p := range plan {
+		if err := seed(ctx, client, p.seed); err != nil {
+			return fmt.Errorf("seed %s: %w", p.name, err)
+		}
+		e := &env{w: io.Discard, client: client, adminClient: r.adminClient, database: db, arg: arg}
+		if p != s {
+			if err := p.run(ctx, e); err != nil {
+				return fmt.Errorf("%s, required by %s: %w", p.name, s.name, err)
+			}
+			continue
+		}
+		e.w = w
+		if s.databaseRole != "" {
+			roleClient, err := spanner.NewClientWithConfig(ctx, db, spanner.ClientConfig{DatabaseRole: s.databaseRole})
+			if err != nil {
+				return err
+			}
+			defer roleClient.Close()
+			e.client = roleClient
+		}
+		return s.run(ctx, e)
+	}
+	return nil
+}
+
+// createDatabase creates db with schema. PostgreSQL databases don't accept
+// DDL in the CREATE DATABASE request, so their schema is added after.
+func (r *runner) createDatabase(ctx context.Context, db string, dialect adminpb.DatabaseDialect, schema []string) error {
+	req := &adminpb.CreateDatabaseRequest{
+		Parent:          r.instance,
+		DatabaseDialect: dialect,
+	}
+	id := db[len(r.instance+"/databases/"):]
+	if dialect == postgreSQL {
+		req.CreateStatement = `CREATE DATABASE "` + id + `"`
+	} else {
+		req.CreateStatement = "CREATE DATABASE `" + id + "`"
+		req.ExtraStatements = schema
+	}
+	op, err := r.adminClient.CreateDatabase(ctx, req)
+	if err != nil {
+		return err
+	}
+	if _, err := op.Wait(ctx); err != nil {
+		return err
+	}
+	if dialect != postgreSQL || len(schema) == 0 {
+		return nil
+	}
+	ddl, err := r.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   db,
+		Statements: schema,
+	})
+	if err != nil {
+		return err
+	}
+	return ddl.Wait(ctx)
+}
+
+// seed runs DML statements in a single transaction.
+func seed(ctx context.Context, client *spanner.Client, dml []string) error {
+	if len(dml) == 0 {
+		return nil
+	}
+	stmts := make([]spanner.Statement, len(dml))
+	for i, sql := range dml {
+		stmts[i] = spanner.NewStatement(sql)
+	}
+	_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		_, err := txn.BatchUpdate(ctx, stmts)
+		return err
+	})
+	return err
+}
+
+// newDatabaseID returns a random database ID. IDs are at most 30
+// characters long.
+func newDatabaseID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "snippets-" + hex.EncodeToString(b)
+}
@@ -37,41 +37,6 @@ import (
 	expr "google.golang.org/genproto/googleapis/type/expr"
 )
 
-type command func(ctx context.Context, w io.Writer, client *spanner.Client) error
-type adminCommand func(ctx context.Context, w io.Writer, adminClient *database.DatabaseAdminClient, database string) error
-
-var (
-	commands = map[string]command{
-		"write":                    write,
-		"read":                     read,
-		"readdatawithdatabaserole": read,
-		"query":                    query,
-		"update":                   update,
-		"querynewcolumn":           queryNewColumn,
-		"pgquerynewcolumn":         pgQueryNewColumn,
-		"querywithparameter":       queryWithParameter,
-		"pgqueryparameter":         pgQueryParameter,
-		"dmlwrite":                 writeUsingDML,
-		"pgdmlwrite":               pgWriteUsingDML,
-		"dmlwritetxn":              writeWithTransactionUsingDML,
-		"pgdmlwritetxn":            pgWriteWithTransactionUsingDML,
-		"readindex":                readUsingIndex,
-		"readstoringindex":         readStoringIndex,
-		"readonlytransaction":      readOnlyTransaction,
-	}
-
-	adminCommands = map[string]adminCommand{
-		"createdatabase":         createDatabase,
-		"addnewcolumn":           addNewColumn,
-		"pgaddnewcolumn":         pgAddNewColumn,
-		"addstoringindex":        addStoringIndex,
-		"pgaddstoringindex":      pgAddStoringIndex,
-		"pgcreatedatabase":       pgCreateDatabase,
-		"addanddropdatabaserole": addAndDropDatabaseRole,
-		"listdatabaseroles":      listDatabaseRoles,
-	}
-)
-
 // [START spanner_create_database]
 
 func createDatabase(ctx context.Context, w io.Writer, adminClient *database.DatabaseAdminClient, db string) error {
@@ -816,72 +781,89 @@ func listDatabaseRoles(ctx context.Context, w io.Writer, adminClient *database.D
 }
 
 func run(ctx context.Context, w io.Writer, cmd string, db string, arg string) error {
-	var databaseRole string
-	if cmd == "readdatawithdatabaserole" {
-		databaseRole = "parent"
-	}
-
-	cfg := spanner.ClientConfig{
-		DatabaseRole: databaseRole,
-	}
-
 	adminClient, err := database.NewDatabaseAdminClient(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer adminClient.Close()
 
+	if cmd == "runall" {
+		// db is an instance here.
+		return runAll(ctx, w, &runner{adminClient: adminClient, instance: db}, arg)
+	}
+
+	s := snippets.byName[cmd]
+	if s == nil {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := spanner.ClientConfig{
+		DatabaseRole: s.databaseRole,
+	}
 	dataClient, err := spanner.NewClientWithConfig(ctx, db, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer dataClient.Close()
 
-	if cmd == "enablefinegrainedaccess" {
-		err := enableFineGrainedAccess(ctx, w, adminClient, db, arg)
-		if err != nil {
-			fmt.Fprintf(w, "%s failed with %v", cmd, err)
-		}
-		return err
+	err = s.run(ctx, &env{w: w, client: dataClient, adminClient: adminClient, database: db, arg: arg})
+	if err != nil {
+		fmt.Fprintf(w, "%s failed with %v", cmd, err)
 	}
+	return err
+}
 
-	if adminCmdFn := adminCommands[cmd]; adminCmdFn != nil {
-		err := adminCmdFn(ctx, w, adminClient, db)
-		if err != nil {
-			fmt.Fprintf(w, "%s failed with %v", cmd, err)
+// runAll runs the snippets named in the comma-separated list names, or all
+// of them, each in a new database. On the emulator it skips the snippets
+// the emulator doesn't support.
+func runAll(ctx context.Context, w io.Writer, r *runner, names string) error {
+	list := snippets.order
+	if names != "" {
+		list = nil
+		for _, name := range strings.Split(names, ",") {
+			s := snippets.byName[name]
+			if s == nil {
+				return fmt.Errorf("unknown snippet %s", name)
+			}
+			list = append(list, s)
 		}
-		return err
 	}
-
-	// Normal mode
-	cmdFn := commands[cmd]
-	if cmdFn == nil {
-		flag.Usage()
-		os.Exit(2)
+	emulator := os.Getenv("SPANNER_EMULATOR_HOST") != ""
+	var failed []string
+	for _, s := range list {
+		if s.noEmulator && emulator {
+			fmt.Fprintf(w, "=== %s: skipped on the emulator\n", s.name)
+			continue
+		}
+		fmt.Fprintf(w, "=== %s\n", s.name)
+		if err := r.run(ctx, w, s, ""); err != nil {
+			fmt.Fprintf(w, "%s failed with %v\n", s.name, err)
+			failed = append(failed, s.name)
+		}
 	}
-	err = cmdFn(ctx, w, dataClient)
-	if err != nil {
-		fmt.Fprintf(w, "%s failed with %v", cmd, err)
+	if len(failed) > 0 {
+		return fmt.Errorf("failed snippets: %s", strings.Join(failed, ", "))
 	}
-	return err
+	return nil
 }
 
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: spanner_snippets <command> <database_name> [iam_member]
+       spanner_snippets runall <instance_name> [command,...]
+
+	Command can be one of: %s
 
-	Command can be one of: write, read, readdatawithdatabaserole, query, update,
-		querynewcolumn, querywithparameter, dmlwrite, dmlwritetxn, readindex,
-		readstoringindex, readonlytransaction, createdatabase, addnewcolumn,
-		addstoringindex, addanddropdatabaserole, enablefinegrainedaccess,
-		listdatabaseroles, pgcreatedatabase, pgqueryparameter, pgdmlwrite,
-		pgaddnewcolumn, pgquerynewcolumn, pgdmlwritetxn, pgaddstoringindex
+	runall runs each command, or each listed command, in a new database after
+	the commands it depends on, and drops the database afterwards.
 
 Examples:
 	spanner_snippets createdatabase projects/my-project/instances/my-instance/databases/example-db
 	spanner_snippets write projects/my-project/instances/my-instance/databases/example-db
 	spanner_snippets enablefinegrainedaccess projects/my-project/instances/my-instance/databases/example-db user:alice@example.com
-`)
+	spanner_snippets runall projects/my-project/instances/my-instance read,pgdmlwritetxn
+`, strings.Join(snippets.names(), ", "))
 	}
 
 	flag.Parse()
@@ -891,9 +873,16 @@ Examples:
 	}
 
 	cmd, db, arg := flag.Arg(0), flag.Arg(1), flag.Arg(2)
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	timeout := 1 * time.Minute
+	if cmd == "runall" {
+		timeout = 30 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	if err := run(ctx, os.Stdout, cmd, db, arg); err != nil {
+		if cmd == "runall" {
+			log.Print(err)
+		}
 		os.Exit(1)
 	}
 }
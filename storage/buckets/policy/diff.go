@@ -0,0 +1,276 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Change is a bucket setting that differs from the policy.
+type Change struct {
+	Field string `json:"field"`
+	Have  string `json:"have"`
+	Want  string `json:"want"`
+	// Blocked explains why the change cannot be applied, such as a locked
+	// retention policy. Blocked changes are reported but never applied.
+	Blocked string `json:"blocked,omitempty"`
+
+	update func(*storage.BucketAttrsToUpdate)
+}
+
+// diff compares a bucket with the settings it should have and returns the
+// changes needed, ordered by field.
+func diff(attrs *storage.BucketAttrs, want Settings) []Change {
+	var changes []Change
+	add := func(field string, have, want any, update func(*storage.BucketAttrsToUpdate)) *Change {
+		h, w := fmt.Sprint(have), fmt.Sprint(want)
+		if h == w {
+			return nil
+		}
+		changes = append(changes, Change{Field: field, Have: h, Want: w, update: update})
+		return &changes[len(changes)-1]
+	}
+
+	if w := want.UniformBucketLevelAccess; w != nil {
+		ubla := attrs.UniformBucketLevelAccess
+		c := add("uniformBucketLevelAccess", ubla.Enabled, *w, func(u *storage.BucketAttrsToUpdate) {
+			u.UniformBucketLevelAccess = &storage.UniformBucketLevelAccess{Enabled: *w}
+		})
+		if c != nil && !*w && !ubla.LockedTime.IsZero() && time.Now().After(ubla.LockedTime) {
+			c.Blocked = fmt.Sprintf("uniform bucket-level access was locked on %s", ubla.LockedTime.Format(time.DateOnly))
+		}
+	}
+	if w := want.PublicAccessPrevention; w != nil {
+		pap := storage.PublicAccessPreventionInherited
+		if *w == "enforced" {
+			pap = storage.PublicAccessPreventionEnforced
+		}
+		add("publicAccessPrevention", attrs.PublicAccessPrevention, *w, func(u *storage.BucketAttrsToUpdate) {
+			u.PublicAccessPrevention = pap
+		})
+	}
+	if w := want.Versioning; w != nil {
+		add("versioning", attrs.VersioningEnabled, *w, func(u *storage.BucketAttrsToUpdate) {
+			u.VersioningEnabled = *w
+		})
+	}
+	if w := want.RequesterPays; w != nil {
+		add("requesterPays", attrs.RequesterPays, *w, func(u *storage.BucketAttrsToUpdate) {
+			u.RequesterPays = *w
+		})
+	}
+	if w := want.DefaultEventBasedHold; w != nil {
+		add("defaultEventBasedHold", attrs.DefaultEventBasedHold, *w, func(u *storage.BucketAttrsToUpdate) {
+			u.DefaultEventBasedHold = *w
+		})
+	}
+	if w := want.StorageClass; w != nil {
+		add("storageClass", attrs.StorageClass, strings.ToUpper(*w), func(u *storage.BucketAttrsToUpdate) {
+			u.StorageClass = strings.ToUpper(*w)
+		})
+	}
+	if w := want.RetentionPeriod; w != nil {
+		var have Duration
+		rp := attrs.RetentionPolicy
+		if rp != nil {
+			have = Duration(rp.RetentionPeriod)
+		}
+		c := add("retentionPeriod", have, *w, func(u *storage.BucketAttrsToUpdate) {
+			u.RetentionPolicy = &storage.RetentionPolicy{RetentionPeriod: time.Duration(*w)}
+		})
+		if c != nil && rp != nil && rp.IsLocked && *w < have {
+			c.Blocked = "the retention policy is locked, so its period can only be increased"
+		}
+	}
+	if w := want.SoftDeleteRetention; w != nil {
+		var have Duration
+		if sd := attrs.SoftDeletePolicy; sd != nil {
+			have = Duration(sd.RetentionDuration)
+		}
+		add("softDeleteRetention", have, *w, func(u *storage.BucketAttrsToUpdate) {
+			u.SoftDeletePolicy = &storage.SoftDeletePolicy{RetentionDuration: time.Duration(*w)}
+		})
+	}
+	if w := want.DefaultKMSKey; w != nil {
+		var have string
+		if attrs.Encryption != nil {
+			have = attrs.Encryption.DefaultKMSKeyName
+		}
+		add("defaultKMSKey", quote(have), quote(*w), func(u *storage.BucketAttrsToUpdate) {
+			u.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: *w}
+		})
+	}
+
+	keys := make([]string, 0, len(want.Labels))
+	for k := range want.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		have, ok := attrs.Labels[k]
+		h := "absent"
+		if ok {
+			h = quote(have)
+		}
+		if w := want.Labels[k]; w == nil {
+			add("labels."+k, h, "absent", func(u *storage.BucketAttrsToUpdate) { u.DeleteLabel(k) })
+		} else {
+			add("labels."+k, h, quote(*w), func(u *storage.BucketAttrsToUpdate) { u.SetLabel(k, *w) })
+		}
+	}
+
+	if w := want.Lifecycle; w != nil {
+		have := lifecycleRules(attrs.Lifecycle)
+		if !reflect.DeepEqual(normalize(have), normalize(*w)) {
+			changes = append(changes, Change{
+				Field: "lifecycle",
+				Have:  formatRules(have),
+				Want:  formatRules(*w),
+				update: func(u *storage.BucketAttrsToUpdate) {
+					u.Lifecycle = &storage.Lifecycle{Rules: storageRules(*w)}
+				},
+			})
+		}
+	}
+	return changes
+}
+
+func quote(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// lifecycleRules converts a bucket's lifecycle rules to policy rules.
+func lifecycleRules(l storage.Lifecycle) []LifecycleRule {
+	var rules []LifecycleRule
+	for _, r := range l.Rules {
+		c := r.Condition
+		pr := LifecycleRule{
+			Action:                  r.Action.Type,
+			StorageClass:            r.Action.StorageClass,
+			AgeDays:                 c.AgeInDays,
+			NumNewerVersions:        c.NumNewerVersions,
+			DaysSinceNoncurrentTime: c.DaysSinceNoncurrentTime,
+			MatchesPrefix:           c.MatchesPrefix,
+			MatchesSuffix:           c.MatchesSuffix,
+			MatchesStorageClass:     c.MatchesStorageClasses,
+		}
+		switch c.Liveness {
+		case storage.Live:
+			pr.IsLive = new(bool)
+			*pr.IsLive = true
+		case storage.Archived:
+			pr.IsLive = new(bool)
+		}
+		rules = append(rules, pr)
+	}
+	return rules
+}
+
+// storageRules converts policy lifecycle rules to the client's type.
+func storageRules(rules []LifecycleRule) []storage.LifecycleRule {
+	out := []storage.LifecycleRule{}
+	for _, r := range rules {
+		lr := storage.LifecycleRule{
+			Action: storage.LifecycleAction{Type: r.Action, StorageClass: r.StorageClass},
+			Condition: storage.LifecycleCondition{
+				AgeInDays:               r.AgeDays,
+				NumNewerVersions:        r.NumNewerVersions,
+				DaysSinceNoncurrentTime: r.DaysSinceNoncurrentTime,
+				MatchesPrefix:           r.MatchesPrefix,
+				MatchesSuffix:           r.MatchesSuffix,
+				MatchesStorageClasses:   r.MatchesStorageClass,
+			},
+		}
+		if r.IsLive != nil {
+			lr.Condition.Liveness = storage.Archived
+			if *r.IsLive {
+				lr.Condition.Liveness = storage.Live
+			}
+		}
+		out = append(out, lr)
+	}
+	return out
+}
+
+// normalize makes rules comparable: empty lists become nil and storage
+// classes are upper case.
+func normalize(rules []LifecycleRule) []LifecycleRule {
+	var out []LifecycleRule
+	for _, r := range rules {
+		r.StorageClass = strings.ToUpper(r.StorageClass)
+		r.MatchesStorageClass = slices.Clone(r.MatchesStorageClass)
+		for i, c := range r.MatchesStorageClass {
+			r.MatchesStorageClass[i] = strings.ToUpper(c)
+		}
+		for _, l := range []*[]string{&r.MatchesPrefix, &r.MatchesSuffix, &r.MatchesStorageClass} {
+			if len(*l) == 0 {
+				*l = nil
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// formatRules summarizes lifecycle rules on one line.
+func formatRules(rules []LifecycleRule) string {
+	if len(rules) == 0 {
+		return "none"
+	}
+	var parts []string
+	for _, r := range rules {
+		s := r.Action
+		if r.StorageClass != "" {
+			s += "(" + r.StorageClass + ")"
+		}
+		var conds []string
+		if r.AgeDays > 0 {
+			conds = append(conds, fmt.Sprintf("age>=%dd", r.AgeDays))
+		}
+		if r.NumNewerVersions > 0 {
+			conds = append(conds, fmt.Sprintf("newerVersions>=%d", r.NumNewerVersions))
+		}
+		if r.DaysSinceNoncurrentTime > 0 {
+			conds = append(conds, fmt.Sprintf("noncurrent>=%dd", r.DaysSinceNoncurrentTime))
+		}
+		if r.IsLive != nil {
+			conds = append(conds, fmt.Sprintf("isLive=%v", *r.IsLive))
+		}
+		if len(r.MatchesPrefix) > 0 {
+			conds = append(conds, "prefix="+strings.Join(r.MatchesPrefix, ","))
+		}
+		if len(r.MatchesSuffix) > 0 {
+			conds = append(conds, "suffix="+strings.Join(r.MatchesSuffix, ","))
+		}
+		if len(r.MatchesStorageClass) > 0 {
+			conds = append(conds, "class="+strings.Join(r.MatchesStorageClass, ","))
+		}
+		if len(conds) > 0 {
+			s += " " + strings.Join(conds, " ")
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "; ")
+}
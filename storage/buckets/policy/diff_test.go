@@ -0,0 +1,300 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+const day = 24 * time.Hour
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		attrs storage.BucketAttrs
+		want  Settings
+		diff  []Change
+	}{
+		{
+			name: "unmanaged",
+			attrs: storage.BucketAttrs{
+				VersioningEnabled: true,
+				Labels:            map[string]string{"team": "x"},
+			},
+		},
+		{
+			name: "compliant",
+			attrs: storage.BucketAttrs{
+				UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: true},
+				PublicAccessPrevention:   storage.PublicAccessPreventionEnforced,
+				VersioningEnabled:        true,
+				StorageClass:             "STANDARD",
+				SoftDeletePolicy:         &storage.SoftDeletePolicy{RetentionDuration: 7 * day},
+				Labels:                   map[string]string{"owner": "data-eng", "extra": "kept"},
+			},
+			want: Settings{
+				UniformBucketLevelAccess: ptr(true),
+				PublicAccessPrevention:   ptr("enforced"),
+				Versioning:               ptr(true),
+				StorageClass:             ptr("standard"),
+				SoftDeleteRetention:      ptr(Duration(7 * day)),
+				Labels:                   map[string]*string{"owner": ptr("data-eng"), "temp": nil},
+			},
+		},
+		{
+			name: "booleans",
+			attrs: storage.BucketAttrs{
+				PublicAccessPrevention: storage.PublicAccessPreventionUnspecified,
+				RequesterPays:          true,
+			},
+			want: Settings{
+				UniformBucketLevelAccess: ptr(true),
+				PublicAccessPrevention:   ptr("enforced"),
+				Versioning:               ptr(true),
+				RequesterPays:            ptr(false),
+				DefaultEventBasedHold:    ptr(true),
+			},
+			diff: []Change{
+				{Field: "uniformBucketLevelAccess", Have: "false", Want: "true"},
+				{Field: "publicAccessPrevention", Have: "inherited", Want: "enforced"},
+				{Field: "versioning", Have: "false", Want: "true"},
+				{Field: "requesterPays", Have: "true", Want: "false"},
+				{Field: "defaultEventBasedHold", Have: "false", Want: "true"},
+			},
+		},
+		{
+			name: "locked uniform access",
+			attrs: storage.BucketAttrs{
+				UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: true, LockedTime: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+			},
+			want: Settings{UniformBucketLevelAccess: ptr(false)},
+			diff: []Change{
+				{Field: "uniformBucketLevelAccess", Have: "true", Want: "false", Blocked: "uniform bucket-level access was locked on 2020-01-02"},
+			},
+		},
+		{
+			name: "durations",
+			attrs: storage.BucketAttrs{
+				RetentionPolicy:  &storage.RetentionPolicy{RetentionPeriod: 10 * day},
+				SoftDeletePolicy: &storage.SoftDeletePolicy{RetentionDuration: 7 * day},
+			},
+			want: Settings{
+				RetentionPeriod:     ptr(Duration(30 * day)),
+				SoftDeleteRetention: ptr(Duration(0)),
+			},
+			diff: []Change{
+				{Field: "retentionPeriod", Have: "10d", Want: "30d"},
+				{Field: "softDeleteRetention", Have: "7d", Want: "none"},
+			},
+		},
+		{
+			name:  "missing retention policy",
+			attrs: storage.BucketAttrs{},
+			want:  Settings{RetentionPeriod: ptr(Duration(36 * time.Hour))},
+			diff:  []Change{{Field: "retentionPeriod", Have: "none", Want: "36h0m0s"}},
+		},
+		{
+			name: "locked retention policy",
+			attrs: storage.BucketAttrs{
+				RetentionPolicy: &storage.RetentionPolicy{RetentionPeriod: 30 * day, IsLocked: true},
+			},
+			want: Settings{RetentionPeriod: ptr(Duration(0))},
+			diff: []Change{
+				{Field: "retentionPeriod", Have: "30d", Want: "none", Blocked: "the retention policy is locked, so its period can only be increased"},
+			},
+		},
+		{
+			name: "locked retention policy increase",
+			attrs: storage.BucketAttrs{
+				RetentionPolicy: &storage.RetentionPolicy{RetentionPeriod: 30 * day, IsLocked: true},
+			},
+			want: Settings{RetentionPeriod: ptr(Duration(60 * day))},
+			diff: []Change{{Field: "retentionPeriod", Have: "30d", Want: "60d"}},
+		},
+		{
+			name:  "kms key",
+			attrs: storage.BucketAttrs{Encryption: &storage.BucketEncryption{DefaultKMSKeyName: "old"}},
+			want:  Settings{DefaultKMSKey: ptr("")},
+			diff:  []Change{{Field: "defaultKMSKey", Have: `"old"`, Want: "none"}},
+		},
+		{
+			name:  "labels",
+			attrs: storage.BucketAttrs{Labels: map[string]string{"owner": "someone", "temp": "yes"}},
+			want:  Settings{Labels: map[string]*string{"owner": ptr("data-eng"), "temp": nil, "env": ptr("prod")}},
+			diff: []Change{
+				{Field: "labels.env", Have: "absent", Want: `"prod"`},
+				{Field: "labels.owner", Have: `"someone"`, Want: `"data-eng"`},
+				{Field: "labels.temp", Have: `"yes"`, Want: "absent"},
+			},
+		},
+		{
+			name: "equal lifecycle",
+			attrs: storage.BucketAttrs{Lifecycle: storage.Lifecycle{Rules: []storage.LifecycleRule{{
+				Action:    storage.LifecycleAction{Type: "SetStorageClass", StorageClass: "COLDLINE"},
+				Condition: storage.LifecycleCondition{AgeInDays: 30, MatchesStorageClasses: []string{"STANDARD"}, MatchesPrefix: []string{}},
+			}}}},
+			want: Settings{Lifecycle: &[]LifecycleRule{{Action: "SetStorageClass", StorageClass: "coldline", AgeDays: 30, MatchesStorageClass: []string{"standard"}}}},
+		},
+		{
+			name: "lifecycle",
+			attrs: storage.BucketAttrs{Lifecycle: storage.Lifecycle{Rules: []storage.LifecycleRule{{
+				Action:    storage.LifecycleAction{Type: "Delete"},
+				Condition: storage.LifecycleCondition{AgeInDays: 100},
+			}}}},
+			want: Settings{Lifecycle: &[]LifecycleRule{
+				{Action: "Delete", AgeDays: 365, IsLive: ptr(true)},
+				{Action: "Delete", NumNewerVersions: 3, MatchesPrefix: []string{"tmp/"}},
+			}},
+			diff: []Change{{Field: "lifecycle", Have: "Delete age>=100d", Want: "Delete age>=365d isLive=true; Delete newerVersions>=3 prefix=tmp/"}},
+		},
+		{
+			name: "remove lifecycle",
+			attrs: storage.BucketAttrs{Lifecycle: storage.Lifecycle{Rules: []storage.LifecycleRule{{
+				Action: storage.LifecycleAction{Type: "Delete"},
+			}}}},
+			want: Settings{Lifecycle: &[]LifecycleRule{}},
+			diff: []Change{{Field: "lifecycle", Have: "Delete", Want: "none"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := diff(&tc.attrs, tc.want)
+			if d := cmp.Diff(tc.diff, got, cmpopts.IgnoreUnexported(Change{}), cmpopts.EquateEmpty()); d != "" {
+				t.Errorf("diff mismatch (-want +got):\n%s", d)
+			}
+		})
+	}
+}
+
+func TestDiffUpdate(t *testing.T) {
+	attrs := &storage.BucketAttrs{
+		Labels:          map[string]string{"temp": "yes"},
+		RetentionPolicy: &storage.RetentionPolicy{RetentionPeriod: day, IsLocked: true},
+	}
+	changes := diff(attrs, Settings{
+		Versioning:      ptr(true),
+		RetentionPeriod: ptr(Duration(0)),
+		Labels:          map[string]*string{"temp": nil},
+		Lifecycle:       &[]LifecycleRule{{Action: "Delete", IsLive: ptr(false), NumNewerVersions: 2}},
+	})
+	var u storage.BucketAttrsToUpdate
+	for _, c := range changes {
+		c.update(&u)
+	}
+	if u.VersioningEnabled != true {
+		t.Errorf("VersioningEnabled = %v, want true", u.VersioningEnabled)
+	}
+	if u.RetentionPolicy == nil || u.RetentionPolicy.RetentionPeriod != 0 {
+		t.Errorf("RetentionPolicy = %+v, want a zero period", u.RetentionPolicy)
+	}
+	want := []storage.LifecycleRule{{
+		Action:    storage.LifecycleAction{Type: "Delete"},
+		Condition: storage.LifecycleCondition{Liveness: storage.Archived, NumNewerVersions: 2},
+	}}
+	if u.Lifecycle == nil || !cmp.Equal(u.Lifecycle.Rules, want, cmpopts.EquateEmpty()) {
+		t.Errorf("Lifecycle = %+v, want %+v", u.Lifecycle, want)
+	}
+}
+
+const testPolicy = `
+defaults:
+  uniformBucketLevelAccess: true
+  publicAccessPrevention: enforced
+  softDeleteRetention: 7d
+  labels:
+    owner: data-eng
+    temp: null
+rules:
+  - match: "logs-*"
+    settings:
+      retentionPeriod: 30d
+      labels:
+        owner: sre
+  - match: "logs-audit-*"
+    settings:
+      retentionPeriod: 90d
+exclude:
+  - "legacy-*"
+`
+
+func TestSettingsFor(t *testing.T) {
+	p, err := parsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("parsePolicy: %v", err)
+	}
+	for _, tc := range []struct {
+		bucket    string
+		retention *Duration
+		owner     string
+	}{
+		{bucket: "data"},
+		{bucket: "logs-web", retention: ptr(Duration(30 * day)), owner: "sre"},
+		{bucket: "logs-audit-2025", retention: ptr(Duration(90 * day)), owner: "sre"},
+	} {
+		s, ok := p.settingsFor(tc.bucket)
+		if !ok {
+			t.Fatalf("settingsFor(%q) excluded the bucket", tc.bucket)
+		}
+		if !cmp.Equal(s.RetentionPeriod, tc.retention) {
+			t.Errorf("settingsFor(%q).RetentionPeriod = %v, want %v", tc.bucket, s.RetentionPeriod, tc.retention)
+		}
+		if tc.owner == "" {
+			tc.owner = "data-eng"
+		}
+		if got := s.Labels["owner"]; got == nil || *got != tc.owner {
+			t.Errorf("settingsFor(%q) owner label = %v, want %q", tc.bucket, got, tc.owner)
+		}
+		if v, ok := s.Labels["temp"]; !ok || v != nil {
+			t.Errorf("settingsFor(%q) temp label = %v, %v; want required absent", tc.bucket, v, ok)
+		}
+		if s.UniformBucketLevelAccess == nil || !*s.UniformBucketLevelAccess {
+			t.Errorf("settingsFor(%q) lost the default uniform bucket-level access", tc.bucket)
+		}
+	}
+	if _, ok := p.settingsFor("legacy-photos"); ok {
+		t.Errorf("settingsFor(%q) did not exclude the bucket", "legacy-photos")
+	}
+	// Merging must not modify the defaults.
+	if got := p.Defaults.Labels["owner"]; *got != "data-eng" {
+		t.Errorf("default owner label changed to %q", *got)
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for _, tc := range []struct {
+		policy, want string
+	}{
+		{"defaults:\n  versionning: true\n", "field versionning not found"},
+		{"defaults:\n  publicAccessPrevention: on\n", "want enforced or inherited"},
+		{"defaults:\n  softDeleteRetention: 3d\n", "between 7d and 90d"},
+		{"defaults:\n  retentionPeriod: soon\n", "invalid duration"},
+		{"defaults:\n  lifecycle:\n    - action: Archive\n", "unknown action"},
+		{"defaults:\n  lifecycle:\n    - action: SetStorageClass\n", "needs a storageClass"},
+		{"rules:\n  - match: \"[\"\n", "not a valid glob"},
+		{"exclude: [\"[\"]\n", "exclude"},
+	} {
+		_, err := parsePolicy([]byte(tc.policy))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("parsePolicy(%q) = %v, want error containing %q", tc.policy, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command policy audits the buckets in a project against a declarative
+// YAML policy and fixes the drift with a plan and apply workflow:
+//
+//	policy -project=my-project -policy=policy.yaml audit
+//	policy -project=my-project -policy=policy.yaml -plan=plan.json plan
+//	policy -project=my-project -policy=policy.yaml -plan=plan.json apply
+//
+// audit reports drift and fails if there is any, for use in CI. plan
+// writes the changes to make to the -plan file for review, and apply makes
+// exactly those changes, refusing buckets that changed in the meantime.
+//
+// A policy sets defaults for every bucket, overrides them for buckets
+// matching a glob and excludes others:
+//
+//	defaults:
+//	  uniformBucketLevelAccess: true
+//	  publicAccessPrevention: enforced
+//	  softDeleteRetention: 7d
+//	  labels:
+//	    owner: data-eng
+//	rules:
+//	  - match: "logs-*"
+//	    settings:
+//	      versioning: false
+//	      retentionPeriod: 30d
+//	      lifecycle:
+//	        - action: Delete
+//	          ageDays: 365
+//	exclude:
+//	  - "legacy-*"
+//
+// Settings left out of the policy are not managed. The sample programs in
+// storage/buckets show how to change each setting on its own.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"cloud.google.com/go/storage"
+)
+
+// errDrift is returned by audit when buckets differ from the policy.
+var errDrift = errors.New("buckets differ from the policy")
+
+type config struct {
+	project    string
+	policyFile string
+	planFile   string
+}
+
+func main() {
+	project := flag.String("project", "", "Cloud project whose buckets are managed")
+	policyFile := flag.String("policy", "policy.yaml", "YAML policy file")
+	planFile := flag.String("plan", "plan.json", "File written by plan and read by apply")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: policy [flags] audit|plan|apply")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *project == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		log.Fatalf("storage.NewClient: %v", err)
+	}
+	defer client.Close()
+
+	cfg := config{project: *project, policyFile: *policyFile, planFile: *planFile}
+	if err := run(ctx, os.Stdout, client, cfg, flag.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run executes command, writing its report to w.
+func run(ctx context.Context, w io.Writer, client *storage.Client, cfg config, command string) error {
+	p, err := loadPolicy(cfg.policyFile)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "audit":
+		plan, err := newPlan(ctx, client, cfg.project, p)
+		if err != nil {
+			return err
+		}
+		printPlan(w, plan)
+		if len(plan.Buckets) > 0 {
+			return errDrift
+		}
+		return nil
+
+	case "plan":
+		plan, err := newPlan(ctx, client, cfg.project, p)
+		if err != nil {
+			return err
+		}
+		printPlan(w, plan)
+		if plan.hasBlocked() {
+			fmt.Fprintln(w, "Blocked changes will be skipped by apply.")
+		}
+		if err := savePlan(cfg.planFile, plan); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Plan written to %s. Run apply to make these changes.\n", cfg.planFile)
+		return nil
+
+	case "apply":
+		plan, err := loadPlan(cfg.planFile)
+		if err != nil {
+			return fmt.Errorf("reading plan: %w; run plan first", err)
+		}
+		if plan.Project != cfg.project {
+			return fmt.Errorf("plan is for project %q, not %q", plan.Project, cfg.project)
+		}
+		return apply(ctx, w, client, p, plan)
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
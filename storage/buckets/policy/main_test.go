@@ -0,0 +1,202 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/golang-samples/storage/internal/gcsfake"
+)
+
+// setup starts a fake with some buckets and writes testPolicy to a file.
+func setup(t *testing.T) (*storage.Client, config) {
+	t.Helper()
+	ctx := context.Background()
+	srv := gcsfake.NewServer()
+	t.Cleanup(srv.Close)
+	client, err := srv.Client(ctx)
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	for name, attrs := range map[string]*storage.BucketAttrs{
+		"data": {
+			Labels: map[string]string{"owner": "data-eng", "temp": "1"},
+		},
+		"logs-web": {
+			UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: true},
+			PublicAccessPrevention:   storage.PublicAccessPreventionEnforced,
+			SoftDeletePolicy:         &storage.SoftDeletePolicy{RetentionDuration: 7 * day},
+			RetentionPolicy:          &storage.RetentionPolicy{RetentionPeriod: 30 * day},
+			Labels:                   map[string]string{"owner": "sre"},
+		},
+		"legacy-photos": {},
+	} {
+		if err := client.Bucket(name).Create(ctx, "p", attrs); err != nil {
+			t.Fatalf("Bucket(%q).Create: %v", name, err)
+		}
+	}
+
+	dir := t.TempDir()
+	cfg := config{
+		project:    "p",
+		policyFile: filepath.Join(dir, "policy.yaml"),
+		planFile:   filepath.Join(dir, "plan.json"),
+	}
+	if err := os.WriteFile(cfg.policyFile, []byte(testPolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return client, cfg
+}
+
+func TestAuditPlanApply(t *testing.T) {
+	ctx := context.Background()
+	client, cfg := setup(t)
+
+	var out bytes.Buffer
+	if err := run(ctx, &out, client, cfg, "audit"); !errors.Is(err, errDrift) {
+		t.Fatalf("audit = %v, want errDrift", err)
+	}
+	for _, want := range []string{
+		"gs://data\n",
+		"  labels.temp: \"1\" -> absent\n",
+		"  uniformBucketLevelAccess: false -> true\n",
+		"1 buckets drifted, 1 compliant, 1 excluded\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("audit output does not contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := run(ctx, &out, client, cfg, "plan"); err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	plan, err := loadPlan(cfg.planFile)
+	if err != nil {
+		t.Fatalf("loadPlan: %v", err)
+	}
+	if len(plan.Buckets) != 1 || plan.Buckets[0].Bucket != "data" || len(plan.Buckets[0].Changes) != 4 {
+		t.Fatalf("plan = %+v, want 4 changes to bucket data", plan)
+	}
+
+	out.Reset()
+	if err := run(ctx, &out, client, cfg, "apply"); err != nil {
+		t.Fatalf("apply: %v\n%s", err, out.String())
+	}
+	attrs, err := client.Bucket("data").Attrs(ctx)
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if !attrs.UniformBucketLevelAccess.Enabled || attrs.PublicAccessPrevention != storage.PublicAccessPreventionEnforced {
+		t.Errorf("data access settings = %+v, %v; want uniform access and enforced prevention", attrs.UniformBucketLevelAccess, attrs.PublicAccessPrevention)
+	}
+	if _, ok := attrs.Labels["temp"]; ok || attrs.Labels["owner"] != "data-eng" {
+		t.Errorf("data labels = %v, want owner only", attrs.Labels)
+	}
+	if attrs.SoftDeletePolicy == nil || attrs.SoftDeletePolicy.RetentionDuration != 7*day {
+		t.Errorf("data soft delete = %+v, want 7 days", attrs.SoftDeletePolicy)
+	}
+
+	out.Reset()
+	if err := run(ctx, &out, client, cfg, "audit"); err != nil {
+		t.Errorf("audit after apply = %v, want no drift:\n%s", err, out.String())
+	}
+
+	// The excluded bucket was left alone.
+	legacy, err := client.Bucket("legacy-photos").Attrs(ctx)
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if legacy.UniformBucketLevelAccess.Enabled {
+		t.Errorf("excluded bucket was changed")
+	}
+}
+
+func TestApplyStalePlan(t *testing.T) {
+	ctx := context.Background()
+	client, cfg := setup(t)
+
+	var out bytes.Buffer
+	if err := run(ctx, &out, client, cfg, "plan"); err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	// Someone changes the bucket after the plan was reviewed.
+	if _, err := client.Bucket("data").Update(ctx, storage.BucketAttrsToUpdate{VersioningEnabled: true}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	err := run(ctx, &out, client, cfg, "apply")
+	if err == nil || !strings.Contains(err.Error(), "bucket changed since the plan was made") {
+		t.Fatalf("apply = %v, want a stale plan error", err)
+	}
+	attrs, err := client.Bucket("data").Attrs(ctx)
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if attrs.UniformBucketLevelAccess.Enabled {
+		t.Errorf("apply changed a bucket modified since the plan")
+	}
+
+	// A plan for another project is refused.
+	cfg.project = "other"
+	if err := run(ctx, &out, client, cfg, "apply"); err == nil {
+		t.Errorf("apply of another project's plan succeeded")
+	}
+}
+
+func TestApplyBlocked(t *testing.T) {
+	ctx := context.Background()
+	client, cfg := setup(t)
+
+	// A locked retention policy cannot be shortened, but the bucket's other
+	// changes are still made.
+	policy := strings.Replace(testPolicy, "retentionPeriod: 30d", "retentionPeriod: 10d\n      versioning: true", 1)
+	if err := os.WriteFile(cfg.policyFile, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bkt := client.Bucket("logs-web")
+	attrs, err := bkt.Attrs(ctx)
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if err := bkt.If(storage.BucketConditions{MetagenerationMatch: attrs.MetaGeneration}).LockRetentionPolicy(ctx); err != nil {
+		t.Fatalf("LockRetentionPolicy: %v", err)
+	}
+	var out bytes.Buffer
+	if err := run(ctx, &out, client, cfg, "plan"); err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if err := run(ctx, &out, client, cfg, "apply"); err != nil {
+		t.Fatalf("apply: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "gs://logs-web: skipping retentionPeriod") {
+		t.Errorf("apply output does not report the blocked change:\n%s", out.String())
+	}
+	attrs, err = bkt.Attrs(ctx)
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if !attrs.VersioningEnabled || attrs.RetentionPolicy.RetentionPeriod != 30*day {
+		t.Errorf("logs-web versioning = %v, retention = %v; want true and unchanged", attrs.VersioningEnabled, attrs.RetentionPolicy.RetentionPeriod)
+	}
+}
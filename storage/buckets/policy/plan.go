@@ -0,0 +1,189 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// Plan records the drift of a project's buckets from a policy, and the
+// changes apply will make.
+type Plan struct {
+	Project string    `json:"project"`
+	Created time.Time `json:"created"`
+	// Buckets lists the buckets that drifted from the policy.
+	Buckets []BucketPlan `json:"buckets"`
+	// Compliant and Excluded list the other buckets.
+	Compliant []string `json:"compliant"`
+	Excluded  []string `json:"excluded"`
+}
+
+// BucketPlan is the changes to one bucket. Metageneration is the bucket's
+// metageneration when the plan was made; apply refuses to change a bucket
+// that has been modified since.
+type BucketPlan struct {
+	Bucket         string   `json:"bucket"`
+	Metageneration int64    `json:"metageneration"`
+	Changes        []Change `json:"changes"`
+}
+
+// newPlan compares every bucket in the project with the policy.
+func newPlan(ctx context.Context, client *storage.Client, project string, p *Policy) (*Plan, error) {
+	plan := &Plan{Project: project, Created: time.Now().UTC()}
+	it := client.Buckets(ctx, project)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return plan, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing buckets: %w", err)
+		}
+		settings, ok := p.settingsFor(attrs.Name)
+		if !ok {
+			plan.Excluded = append(plan.Excluded, attrs.Name)
+			continue
+		}
+		changes := diff(attrs, settings)
+		if len(changes) == 0 {
+			plan.Compliant = append(plan.Compliant, attrs.Name)
+			continue
+		}
+		plan.Buckets = append(plan.Buckets, BucketPlan{
+			Bucket:         attrs.Name,
+			Metageneration: attrs.MetaGeneration,
+			Changes:        changes,
+		})
+	}
+}
+
+// hasBlocked reports whether the plan includes a change that cannot be
+// applied.
+func (p *Plan) hasBlocked() bool {
+	for _, b := range p.Buckets {
+		for _, c := range b.Changes {
+			if c.Blocked != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// printPlan writes a drift report.
+func printPlan(w io.Writer, p *Plan) {
+	for _, b := range p.Buckets {
+		fmt.Fprintf(w, "gs://%s\n", b.Bucket)
+		for _, c := range b.Changes {
+			fmt.Fprintf(w, "  %s: %s -> %s", c.Field, c.Have, c.Want)
+			if c.Blocked != "" {
+				fmt.Fprintf(w, " (blocked: %s)", c.Blocked)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintf(w, "%d buckets drifted, %d compliant, %d excluded\n", len(p.Buckets), len(p.Compliant), len(p.Excluded))
+}
+
+func loadPlan(file string) (*Plan, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := new(Plan)
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	return p, nil
+}
+
+func savePlan(file string, p *Plan) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(b, '\n'), 0o644)
+}
+
+// sameChanges reports whether two change lists make the same changes.
+func sameChanges(a, b []Change) bool {
+	return slices.EqualFunc(a, b, func(x, y Change) bool {
+		return x.Field == y.Field && x.Want == y.Want && x.Blocked == y.Blocked
+	})
+}
+
+// apply makes the changes in a plan. The policy must be the one the plan
+// was made from: each bucket is compared with it again, and is updated
+// only if it is unchanged since the plan and still needs the planned
+// changes. Buckets that fail do not stop the others.
+func apply(ctx context.Context, w io.Writer, client *storage.Client, p *Policy, plan *Plan) error {
+	var errs []error
+	for _, bp := range plan.Buckets {
+		if err := applyBucket(ctx, w, client, p, bp); err != nil {
+			errs = append(errs, fmt.Errorf("gs://%s: %w", bp.Bucket, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func applyBucket(ctx context.Context, w io.Writer, client *storage.Client, p *Policy, bp BucketPlan) error {
+	bkt := client.Bucket(bp.Bucket)
+	attrs, err := bkt.Attrs(ctx)
+	if err != nil {
+		return err
+	}
+	if attrs.MetaGeneration != bp.Metageneration {
+		return fmt.Errorf("bucket changed since the plan was made (metageneration %d, planned %d); plan again", attrs.MetaGeneration, bp.Metageneration)
+	}
+	settings, ok := p.settingsFor(bp.Bucket)
+	if !ok {
+		return errors.New("bucket is excluded by the policy")
+	}
+	changes := diff(attrs, settings)
+	if !sameChanges(changes, bp.Changes) {
+		return errors.New("the policy no longer matches the plan; plan again")
+	}
+
+	var u storage.BucketAttrsToUpdate
+	n := 0
+	for _, c := range changes {
+		if c.Blocked != "" {
+			fmt.Fprintf(w, "gs://%s: skipping %s: %s\n", bp.Bucket, c.Field, c.Blocked)
+			continue
+		}
+		c.update(&u)
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	// The precondition fails if the bucket changes between Attrs and Update.
+	_, err = bkt.If(storage.BucketConditions{MetagenerationMatch: bp.Metageneration}).Update(ctx, u)
+	if err != nil {
+		return fmt.Errorf("Update: %w", err)
+	}
+	fmt.Fprintf(w, "gs://%s: applied %d changes\n", bp.Bucket, n)
+	return nil
+}
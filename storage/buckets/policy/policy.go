@@ -0,0 +1,228 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the desired configuration of the buckets in a project.
+type Policy struct {
+	// Defaults apply to every bucket that is not excluded.
+	Defaults Settings `yaml:"defaults"`
+	// Rules override the defaults for buckets whose names match a glob.
+	// Later rules override earlier ones.
+	Rules []Rule `yaml:"rules"`
+	// Exclude lists globs of bucket names that the policy does not manage.
+	Exclude []string `yaml:"exclude"`
+}
+
+// Rule holds settings for the buckets matching a glob, as in path.Match.
+type Rule struct {
+	Match    string   `yaml:"match"`
+	Settings Settings `yaml:"settings"`
+}
+
+// Settings are the managed bucket settings. Unset fields are not managed.
+type Settings struct {
+	UniformBucketLevelAccess *bool `yaml:"uniformBucketLevelAccess"`
+	// PublicAccessPrevention is "enforced" or "inherited".
+	PublicAccessPrevention *string `yaml:"publicAccessPrevention"`
+	Versioning             *bool   `yaml:"versioning"`
+	RequesterPays          *bool   `yaml:"requesterPays"`
+	DefaultEventBasedHold  *bool   `yaml:"defaultEventBasedHold"`
+	StorageClass           *string `yaml:"storageClass"`
+	// RetentionPeriod of zero removes the retention policy.
+	RetentionPeriod *Duration `yaml:"retentionPeriod"`
+	// SoftDeleteRetention of zero disables soft delete.
+	SoftDeleteRetention *Duration `yaml:"softDeleteRetention"`
+	// DefaultKMSKey of "" removes the default key.
+	DefaultKMSKey *string `yaml:"defaultKMSKey"`
+	// Labels maps label keys to their values. A null value requires the
+	// label to be absent. Labels not listed are not managed.
+	Labels map[string]*string `yaml:"labels"`
+	// Lifecycle replaces the bucket's lifecycle rules. An empty list
+	// removes them.
+	Lifecycle *[]LifecycleRule `yaml:"lifecycle"`
+}
+
+// LifecycleRule is a lifecycle rule. A rule matches objects satisfying all
+// of its conditions.
+type LifecycleRule struct {
+	// Action is "Delete", "SetStorageClass" or
+	// "AbortIncompleteMultipartUpload".
+	Action string `yaml:"action"`
+	// StorageClass is the target of a SetStorageClass action.
+	StorageClass string `yaml:"storageClass,omitempty"`
+
+	AgeDays                 int64    `yaml:"ageDays,omitempty"`
+	NumNewerVersions        int64    `yaml:"numNewerVersions,omitempty"`
+	DaysSinceNoncurrentTime int64    `yaml:"daysSinceNoncurrentTime,omitempty"`
+	IsLive                  *bool    `yaml:"isLive,omitempty"`
+	MatchesPrefix           []string `yaml:"matchesPrefix,omitempty"`
+	MatchesSuffix           []string `yaml:"matchesSuffix,omitempty"`
+	MatchesStorageClass     []string `yaml:"matchesStorageClass,omitempty"`
+}
+
+// Duration is a time.Duration written as in time.ParseDuration, or as a
+// whole number of days such as "30d".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(n *yaml.Node) error {
+	s := n.Value
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		v, err := strconv.ParseInt(days, 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid duration %q", n.Line, s)
+		}
+		*d = Duration(time.Duration(v) * 24 * time.Hour)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", n.Line, s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) String() string {
+	switch v := time.Duration(d); {
+	case v == 0:
+		return "none"
+	case v%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", v/(24*time.Hour))
+	default:
+		return v.String()
+	}
+}
+
+// loadPolicy reads and validates a YAML policy. Unknown fields are errors,
+// so that a misspelled setting is not silently unmanaged.
+func loadPolicy(file string) (*Policy, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parsePolicy(b)
+}
+
+func parsePolicy(b []byte) (*Policy, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	p := new(Policy)
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	return p, nil
+}
+
+func (p *Policy) validate() error {
+	for _, g := range p.Exclude {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("exclude %q: %w", g, err)
+		}
+	}
+	if err := p.Defaults.validate(); err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+	for _, r := range p.Rules {
+		if _, err := path.Match(r.Match, ""); err != nil || r.Match == "" {
+			return fmt.Errorf("rule match %q is not a valid glob", r.Match)
+		}
+		if err := r.Settings.validate(); err != nil {
+			return fmt.Errorf("rule %q: %w", r.Match, err)
+		}
+	}
+	return nil
+}
+
+func (s *Settings) validate() error {
+	if v := s.PublicAccessPrevention; v != nil && *v != "enforced" && *v != "inherited" {
+		return fmt.Errorf("publicAccessPrevention is %q, want enforced or inherited", *v)
+	}
+	if v := s.RetentionPeriod; v != nil && *v < 0 {
+		return fmt.Errorf("retentionPeriod %v is negative", *v)
+	}
+	if v := s.SoftDeleteRetention; v != nil && *v != 0 && (*v < Duration(7*24*time.Hour) || *v > Duration(90*24*time.Hour)) {
+		return fmt.Errorf("softDeleteRetention %v must be 0 or between 7d and 90d", *v)
+	}
+	if s.Lifecycle != nil {
+		for i, r := range *s.Lifecycle {
+			switch r.Action {
+			case "Delete", "AbortIncompleteMultipartUpload":
+			case "SetStorageClass":
+				if r.StorageClass == "" {
+					return fmt.Errorf("lifecycle rule %d: SetStorageClass needs a storageClass", i)
+				}
+			default:
+				return fmt.Errorf("lifecycle rule %d: unknown action %q", i, r.Action)
+			}
+		}
+	}
+	return nil
+}
+
+// settingsFor returns the settings for a bucket, or false if the bucket is
+// excluded.
+func (p *Policy) settingsFor(bucket string) (Settings, bool) {
+	for _, g := range p.Exclude {
+		if ok, _ := path.Match(g, bucket); ok {
+			return Settings{}, false
+		}
+	}
+	s := p.Defaults.merge(Settings{})
+	for _, r := range p.Rules {
+		if ok, _ := path.Match(r.Match, bucket); ok {
+			s = s.merge(r.Settings)
+		}
+	}
+	return s, true
+}
+
+// merge returns s overridden by the fields set in o. Labels are merged by
+// key.
+func (s Settings) merge(o Settings) Settings {
+	dst, src := reflect.ValueOf(&s).Elem(), reflect.ValueOf(o)
+	for i := range dst.NumField() {
+		if f := src.Field(i); !f.IsNil() && f.Kind() != reflect.Map {
+			dst.Field(i).Set(f)
+		}
+	}
+	labels := make(map[string]*string, len(s.Labels)+len(o.Labels))
+	for k, v := range s.Labels {
+		labels[k] = v
+	}
+	for k, v := range o.Labels {
+		labels[k] = v
+	}
+	s.Labels = nil
+	if len(labels) > 0 {
+		s.Labels = labels
+	}
+	return s
+}
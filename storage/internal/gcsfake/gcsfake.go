@@ -0,0 +1,830 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcsfake is an in-memory fake of the Cloud Storage JSON and XML
+// APIs, for tests that cannot reach Cloud Storage.
+//
+// It covers the bucket and object calls made by the samples: bucket
+// create, get, list, patch, retention policy lock and delete; object upload (multipart and
+// resumable), get, list, download (including ranges), delete, compose
+// and rewrite. The XML API also serves the Amazon S3 compatible calls
+// made by S3 SDKs in interoperability mode; see xml.go.
+package gcsfake
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+	raw "google.golang.org/api/storage/v1"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Server is a fake Cloud Storage server.
+type Server struct {
+	// URL is the base URL of the server.
+	URL string
+
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	uploads map[string]*upload
+	parts   map[string]*multipartUpload
+	gen     int64
+	hook    func(*http.Request) int
+}
+
+type bucket struct {
+	attrs   *raw.Bucket
+	objects map[string]*object
+}
+
+type object struct {
+	attrs *raw.Object
+	data  []byte
+	// etag is the XML API ETag, if it is not the MD5 of data.
+	etag string
+}
+
+type upload struct {
+	bucket string
+	attrs  *raw.Object
+	data   []byte
+	query  url.Values
+}
+
+// NewServer starts a fake server. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		buckets: make(map[string]*bucket),
+		uploads: make(map[string]*upload),
+		parts:   make(map[string]*multipartUpload),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() { s.srv.Close() }
+
+// Client returns a storage client that talks to the server.
+func (s *Server) Client(ctx context.Context, opts ...option.ClientOption) (*storage.Client, error) {
+	opts = append([]option.ClientOption{
+		option.WithEndpoint(s.URL + "/storage/v1/"),
+		option.WithoutAuthentication(),
+	}, opts...)
+	return storage.NewClient(ctx, opts...)
+}
+
+// SetHook installs f to run before every request. If f returns a non-zero
+// HTTP status, the request fails with that status.
+func (s *Server) SetHook(f func(r *http.Request) int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hook = f
+}
+
+// CreateBucket creates an empty bucket.
+func (s *Server) CreateBucket(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[name] = &bucket{attrs: s.newBucketLocked(&raw.Bucket{Name: name}), objects: make(map[string]*object)}
+}
+
+// PutObject stores an object, creating its bucket if needed.
+func (s *Server) PutObject(bucketName, name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		b = &bucket{attrs: s.newBucketLocked(&raw.Bucket{Name: bucketName}), objects: make(map[string]*object)}
+		s.buckets[bucketName] = b
+	}
+	s.putLocked(b, &raw.Object{Name: name}, data)
+}
+
+// Object returns the contents of an object.
+func (s *Server) Object(bucketName, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		return nil, false
+	}
+	o, ok := b.objects[name]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), o.data...), true
+}
+
+// Objects returns the sorted names of the objects in a bucket.
+func (s *Server) Objects(bucketName string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	if b, ok := s.buckets[bucketName]; ok {
+		for n := range b.objects {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// httpError is an error with an HTTP status, rendered as a JSON API error.
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func errorf(code int, format string, args ...interface{}) error {
+	return &httpError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	hook := s.hook
+	s.mu.Unlock()
+	if hook != nil {
+		if code := hook(r); code != 0 {
+			writeError(w, errorf(code, "injected error"))
+			return
+		}
+	}
+
+	var segs []string
+	for _, seg := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		u, err := url.PathUnescape(seg)
+		if err != nil {
+			writeError(w, errorf(http.StatusBadRequest, "bad path: %v", err))
+			return
+		}
+		segs = append(segs, u)
+	}
+
+	var (
+		resp interface{}
+		err  error
+	)
+	switch {
+	case len(segs) >= 4 && segs[0] == "upload" && segs[1] == "storage" && segs[3] == "b":
+		resp, err = s.upload(r, segs[4:])
+	case len(segs) >= 3 && segs[0] == "storage" && segs[1] == "v1" && segs[2] == "b":
+		resp, err = s.json(w, r, segs[3:])
+	default:
+		// XML API: /BUCKET and /BUCKET/OBJECT.
+		if err := s.xml(w, r, segs); err != nil {
+			writeXMLError(w, err)
+		}
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if resp == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch err := err.(type) {
+	case *httpError:
+		code = err.code
+	case *location:
+		w.Header().Set("Location", err.url)
+		w.WriteHeader(http.StatusOK)
+		return
+	case *incomplete:
+		if err.size > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", err.size-1))
+		}
+		// Clients ask for 200 with an override header instead of 308, which
+		// net/http would treat as a redirect.
+		w.Header().Set("X-Http-Status-Code-Override", "308")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": err.Error()},
+	})
+}
+
+// json serves the JSON API under /storage/v1/b.
+func (s *Server) json(w http.ResponseWriter, r *http.Request, segs []string) (interface{}, error) {
+	q := r.URL.Query()
+	switch {
+	case len(segs) == 0 && r.Method == http.MethodPost:
+		var attrs raw.Bucket
+		if err := json.NewDecoder(r.Body).Decode(&attrs); err != nil {
+			return nil, errorf(http.StatusBadRequest, "decoding bucket: %v", err)
+		}
+		return s.createBucket(&attrs)
+	case len(segs) == 0 && r.Method == http.MethodGet:
+		return s.listBuckets(q)
+	case len(segs) == 1:
+		return s.bucket(r, segs[0])
+	case len(segs) == 2 && segs[1] == "lockRetentionPolicy" && r.Method == http.MethodPost:
+		return s.lockRetentionPolicy(q, segs[0])
+	case len(segs) == 2 && segs[1] == "o" && r.Method == http.MethodGet:
+		return s.list(segs[0], q)
+	case len(segs) == 3 && segs[1] == "o":
+		if r.Method == http.MethodGet && q.Get("alt") == "media" {
+			return nil, s.download(w, r, segs[0], segs[2])
+		}
+		return s.object(r, segs[0], segs[2])
+	case len(segs) == 4 && segs[1] == "o" && segs[3] == "compose" && r.Method == http.MethodPost:
+		return s.compose(r, segs[0], segs[2])
+	case len(segs) == 8 && segs[1] == "o" && segs[3] == "rewriteTo" && r.Method == http.MethodPost:
+		return s.rewrite(r, segs[0], segs[2], segs[5], segs[7])
+	}
+	return nil, errorf(http.StatusNotFound, "no handler for %s %s", r.Method, r.URL.Path)
+}
+
+func (s *Server) newBucketLocked(attrs *raw.Bucket) *raw.Bucket {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	attrs.Kind = "storage#bucket"
+	attrs.Id = attrs.Name
+	attrs.Metageneration = 1
+	attrs.TimeCreated = now
+	attrs.Updated = now
+	if attrs.Location == "" {
+		attrs.Location = "US"
+	}
+	if attrs.StorageClass == "" {
+		attrs.StorageClass = "STANDARD"
+	}
+	defaultBucket(attrs, now)
+	return attrs
+}
+
+// defaultBucket fills in the settings the server sets when a bucket is
+// created or patched.
+func defaultBucket(attrs *raw.Bucket, now string) {
+	if attrs.IamConfiguration == nil {
+		attrs.IamConfiguration = &raw.BucketIamConfiguration{}
+	}
+	if attrs.IamConfiguration.PublicAccessPrevention == "" {
+		attrs.IamConfiguration.PublicAccessPrevention = "inherited"
+	}
+	if rp := attrs.RetentionPolicy; rp != nil && rp.RetentionPeriod == 0 {
+		attrs.RetentionPolicy = nil
+	} else if rp != nil && rp.EffectiveTime == "" {
+		rp.EffectiveTime = now
+	}
+}
+
+func (s *Server) createBucket(attrs *raw.Bucket) (*raw.Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[attrs.Name]; ok {
+		return nil, errorf(http.StatusConflict, "bucket %s already exists", attrs.Name)
+	}
+	b := &bucket{attrs: s.newBucketLocked(attrs), objects: make(map[string]*object)}
+	s.buckets[attrs.Name] = b
+	return b.attrs, nil
+}
+
+func (s *Server) listBuckets(q url.Values) (*raw.Buckets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &raw.Buckets{Kind: "storage#buckets"}
+	for _, b := range s.buckets {
+		if strings.HasPrefix(b.attrs.Name, q.Get("prefix")) {
+			resp.Items = append(resp.Items, b.attrs)
+		}
+	}
+	sort.Slice(resp.Items, func(i, j int) bool { return resp.Items[i].Name < resp.Items[j].Name })
+	return resp, nil
+}
+
+// bucket serves GET, PATCH and DELETE of a bucket.
+func (s *Server) bucket(r *http.Request, name string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "bucket %s not found", name)
+	}
+	if v := r.URL.Query().Get("ifMetagenerationMatch"); v != "" && v != strconv.FormatInt(b.attrs.Metageneration, 10) {
+		return nil, errorf(http.StatusPreconditionFailed, "metageneration %d does not match %s", b.attrs.Metageneration, v)
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return b.attrs, nil
+	case http.MethodDelete:
+		if len(b.objects) > 0 {
+			return nil, errorf(http.StatusConflict, "bucket %s is not empty", name)
+		}
+		delete(s.buckets, name)
+		return nil, nil
+	case http.MethodPatch, http.MethodPut:
+		patched, err := patch(b.attrs, r.Body)
+		if err != nil {
+			return nil, err
+		}
+		patched.Metageneration = b.attrs.Metageneration + 1
+		patched.Updated = time.Now().UTC().Format(time.RFC3339Nano)
+		defaultBucket(patched, patched.Updated)
+		b.attrs = patched
+		return b.attrs, nil
+	}
+	return nil, errorf(http.StatusMethodNotAllowed, "%s not allowed", r.Method)
+}
+
+// lockRetentionPolicy locks a bucket's retention policy. The
+// ifMetagenerationMatch precondition is required.
+func (s *Server) lockRetentionPolicy(q url.Values, name string) (*raw.Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "bucket %s not found", name)
+	}
+	if v := q.Get("ifMetagenerationMatch"); v != strconv.FormatInt(b.attrs.Metageneration, 10) {
+		return nil, errorf(http.StatusPreconditionFailed, "metageneration %d does not match %q", b.attrs.Metageneration, v)
+	}
+	if b.attrs.RetentionPolicy == nil {
+		return nil, errorf(http.StatusBadRequest, "bucket %s has no retention policy", name)
+	}
+	b.attrs.RetentionPolicy.IsLocked = true
+	b.attrs.Metageneration++
+	return b.attrs, nil
+}
+
+// patch applies a JSON merge patch to attrs. Null fields are cleared.
+func patch(attrs *raw.Bucket, body io.Reader) (*raw.Bucket, error) {
+	var cur, p map[string]interface{}
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return nil, err
+	}
+	if err := json.NewDecoder(body).Decode(&p); err != nil {
+		return nil, errorf(http.StatusBadRequest, "decoding patch: %v", err)
+	}
+	merge(cur, p)
+	if b, err = json.Marshal(cur); err != nil {
+		return nil, err
+	}
+	patched := new(raw.Bucket)
+	if err := json.Unmarshal(b, patched); err != nil {
+		return nil, errorf(http.StatusBadRequest, "applying patch: %v", err)
+	}
+	return patched, nil
+}
+
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		switch v := v.(type) {
+		case nil:
+			delete(dst, k)
+		case map[string]interface{}:
+			d, ok := dst[k].(map[string]interface{})
+			if !ok {
+				d = make(map[string]interface{})
+				dst[k] = d
+			}
+			merge(d, v)
+		default:
+			dst[k] = v
+		}
+	}
+}
+
+func (s *Server) lookupLocked(bucketName, name string) (*bucket, *object, error) {
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		return nil, nil, errorf(http.StatusNotFound, "bucket %s not found", bucketName)
+	}
+	o, ok := b.objects[name]
+	if !ok {
+		return b, nil, errorf(http.StatusNotFound, "object %s/%s not found", bucketName, name)
+	}
+	return b, o, nil
+}
+
+// checkGeneration enforces the ifGenerationMatch and
+// ifGenerationNotMatch preconditions against o, which may be nil.
+func checkGeneration(q url.Values, o *object) error {
+	var gen int64
+	if o != nil {
+		gen = o.attrs.Generation
+	}
+	if v := q.Get("ifGenerationMatch"); v != "" && v != strconv.FormatInt(gen, 10) {
+		return errorf(http.StatusPreconditionFailed, "generation %d does not match %s", gen, v)
+	}
+	if v := q.Get("ifGenerationNotMatch"); v != "" && v == strconv.FormatInt(gen, 10) {
+		return errorf(http.StatusNotModified, "generation matches %s", v)
+	}
+	return nil
+}
+
+// object serves GET and DELETE of object metadata.
+func (s *Server) object(r *http.Request, bucketName, name string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, o, err := s.lookupLocked(bucketName, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGeneration(r.URL.Query(), o); err != nil {
+		return nil, err
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return o.attrs, nil
+	case http.MethodDelete:
+		delete(b.objects, name)
+		return nil, nil
+	}
+	return nil, errorf(http.StatusMethodNotAllowed, "%s not allowed", r.Method)
+}
+
+func (s *Server) list(bucketName string, q url.Values) (*raw.Objects, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "bucket %s not found", bucketName)
+	}
+	prefix, delim := q.Get("prefix"), q.Get("delimiter")
+	// The page token is the last object name or prefix returned.
+	tok := q.Get("pageToken")
+	var names []string
+	for n := range b.objects {
+		if !strings.HasPrefix(n, prefix) || n < q.Get("startOffset") {
+			continue
+		}
+		if tok != "" && (n <= tok || delim != "" && strings.HasSuffix(tok, delim) && strings.HasPrefix(n, tok)) {
+			continue
+		}
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	max := 1000
+	if v, err := strconv.Atoi(q.Get("maxResults")); err == nil && v > 0 && v < max {
+		max = v
+	}
+	resp := &raw.Objects{Kind: "storage#objects"}
+	seen := make(map[string]bool)
+	var last string
+	for _, n := range names {
+		entry := n
+		if delim != "" {
+			if i := strings.Index(n[len(prefix):], delim); i >= 0 {
+				entry = n[:len(prefix)+i+len(delim)]
+				if seen[entry] {
+					continue
+				}
+			}
+		}
+		if len(resp.Items)+len(resp.Prefixes) == max {
+			resp.NextPageToken = last
+			break
+		}
+		if entry != n {
+			seen[entry] = true
+			resp.Prefixes = append(resp.Prefixes, entry)
+		} else {
+			resp.Items = append(resp.Items, b.objects[n].attrs)
+		}
+		last = entry
+	}
+	return resp, nil
+}
+
+// putLocked stores data as the object described by attrs and returns the
+// new object's metadata.
+func (s *Server) putLocked(b *bucket, attrs *raw.Object, data []byte) *raw.Object {
+	s.gen++
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.Checksum(data, crc32cTable))
+	sum := md5.Sum(data)
+	o := &raw.Object{
+		Kind:           "storage#object",
+		Bucket:         b.attrs.Name,
+		Name:           attrs.Name,
+		Id:             fmt.Sprintf("%s/%s/%d", b.attrs.Name, attrs.Name, s.gen),
+		Size:           uint64(len(data)),
+		Generation:     s.gen,
+		Metageneration: 1,
+		ContentType:    attrs.ContentType,
+		Metadata:       attrs.Metadata,
+		Crc32c:         base64.StdEncoding.EncodeToString(crc),
+		Md5Hash:        base64.StdEncoding.EncodeToString(sum[:]),
+		StorageClass:   b.attrs.StorageClass,
+		TimeCreated:    now,
+		Updated:        now,
+		// JSON API ETags identify a generation rather than the contents.
+		Etag: base64.StdEncoding.EncodeToString([]byte(strconv.FormatInt(s.gen, 10))),
+	}
+	if o.ContentType == "" {
+		o.ContentType = "application/octet-stream"
+	}
+	b.objects[attrs.Name] = &object{attrs: o, data: data}
+	return o
+}
+
+// store validates an upload against its preconditions and checksums and
+// stores it.
+func (s *Server) store(bucketName string, q url.Values, attrs *raw.Object, data []byte) (*raw.Object, error) {
+	if attrs.Name == "" {
+		attrs.Name = q.Get("name")
+	}
+	if attrs.Crc32c != "" {
+		crc := make([]byte, 4)
+		binary.BigEndian.PutUint32(crc, crc32.Checksum(data, crc32cTable))
+		if got := base64.StdEncoding.EncodeToString(crc); got != attrs.Crc32c {
+			return nil, errorf(http.StatusBadRequest, "crc32c %s does not match the sent value %s", got, attrs.Crc32c)
+		}
+	}
+	if attrs.Md5Hash != "" {
+		sum := md5.Sum(data)
+		if got := base64.StdEncoding.EncodeToString(sum[:]); got != attrs.Md5Hash {
+			return nil, errorf(http.StatusBadRequest, "md5 %s does not match the sent value %s", got, attrs.Md5Hash)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, o, err := s.lookupLocked(bucketName, attrs.Name)
+	if b == nil {
+		return nil, err
+	}
+	if err := checkGeneration(q, o); err != nil {
+		return nil, err
+	}
+	return s.putLocked(b, attrs, data), nil
+}
+
+// upload serves /upload/storage/v1/b/BUCKET/o.
+func (s *Server) upload(r *http.Request, segs []string) (interface{}, error) {
+	if len(segs) != 2 || segs[1] != "o" {
+		return nil, errorf(http.StatusNotFound, "no handler for %s %s", r.Method, r.URL.Path)
+	}
+	bucketName := segs[0]
+	q := r.URL.Query()
+	switch q.Get("uploadType") {
+	case "multipart":
+		attrs, data, err := readMultipart(r)
+		if err != nil {
+			return nil, err
+		}
+		return s.store(bucketName, q, attrs, data)
+	case "media":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		return s.store(bucketName, q, &raw.Object{}, data)
+	case "resumable":
+		if id := q.Get("upload_id"); id != "" {
+			return s.resume(r, id)
+		}
+		attrs := new(raw.Object)
+		if err := json.NewDecoder(r.Body).Decode(attrs); err != nil && err != io.EOF {
+			return nil, errorf(http.StatusBadRequest, "decoding metadata: %v", err)
+		}
+		s.mu.Lock()
+		s.gen++
+		id := strconv.FormatInt(s.gen, 10)
+		s.uploads[id] = &upload{bucket: bucketName, attrs: attrs, query: q}
+		s.mu.Unlock()
+		return nil, &location{url: fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable&upload_id=%s", s.URL, url.PathEscape(bucketName), id)}
+	}
+	return nil, errorf(http.StatusBadRequest, "unsupported uploadType %q", q.Get("uploadType"))
+}
+
+// location is returned as an error to end a request with a redirect-like
+// response that carries a Location header.
+type location struct{ url string }
+
+func (l *location) Error() string { return l.url }
+
+func readMultipart(r *http.Request) (*raw.Object, []byte, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, errorf(http.StatusBadRequest, "parsing content type: %v", err)
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	meta, err := mr.NextPart()
+	if err != nil {
+		return nil, nil, errorf(http.StatusBadRequest, "reading metadata: %v", err)
+	}
+	attrs := new(raw.Object)
+	if err := json.NewDecoder(meta).Decode(attrs); err != nil {
+		return nil, nil, errorf(http.StatusBadRequest, "decoding metadata: %v", err)
+	}
+	media, err := mr.NextPart()
+	if err != nil {
+		return nil, nil, errorf(http.StatusBadRequest, "reading media: %v", err)
+	}
+	data, err := io.ReadAll(media)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attrs.ContentType == "" {
+		attrs.ContentType = media.Header.Get("Content-Type")
+	}
+	return attrs, data, nil
+}
+
+// incomplete ends a resumable upload chunk that did not finish the upload.
+type incomplete struct{ size int }
+
+func (e *incomplete) Error() string { return "upload incomplete" }
+
+// resume appends a chunk to a resumable upload.
+func (s *Server) resume(r *http.Request, id string) (interface{}, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	if !ok {
+		s.mu.Unlock()
+		return nil, errorf(http.StatusNotFound, "upload %s not found", id)
+	}
+	// Content-Range is "bytes FIRST-LAST/TOTAL", "bytes */TOTAL" or
+	// "bytes FIRST-LAST/*".
+	cr := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+	rng, total, _ := strings.Cut(cr, "/")
+	if first, _, ok := strings.Cut(rng, "-"); ok {
+		off, err := strconv.Atoi(first)
+		if err != nil || off > len(u.data) {
+			s.mu.Unlock()
+			return nil, errorf(http.StatusBadRequest, "bad Content-Range %q", cr)
+		}
+		u.data = append(u.data[:off], data...)
+	}
+	if total == "*" || total == "" || total != strconv.Itoa(len(u.data)) {
+		size := len(u.data)
+		s.mu.Unlock()
+		return nil, &incomplete{size: size}
+	}
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	return s.store(u.bucket, u.query, u.attrs, u.data)
+}
+
+func (s *Server) compose(r *http.Request, bucketName, name string) (*raw.Object, error) {
+	var req raw.ComposeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errorf(http.StatusBadRequest, "decoding compose request: %v", err)
+	}
+	if n := len(req.SourceObjects); n == 0 || n > 32 {
+		return nil, errorf(http.StatusBadRequest, "compose needs 1 to 32 sources, got %d", n)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, dst, err := s.lookupLocked(bucketName, name)
+	if b == nil {
+		return nil, err
+	}
+	if err := checkGeneration(r.URL.Query(), dst); err != nil {
+		return nil, err
+	}
+	var data []byte
+	var components int64
+	for _, src := range req.SourceObjects {
+		o, ok := b.objects[src.Name]
+		if !ok || (src.Generation != 0 && src.Generation != o.attrs.Generation) {
+			return nil, errorf(http.StatusNotFound, "source object %s not found", src.Name)
+		}
+		data = append(data, o.data...)
+		components += max(o.attrs.ComponentCount, 1)
+	}
+	if components > 1024 {
+		return nil, errorf(http.StatusBadRequest, "composite object would have %d components", components)
+	}
+	attrs := &raw.Object{Name: name}
+	if d := req.Destination; d != nil {
+		attrs.ContentType = d.ContentType
+		attrs.Metadata = d.Metadata
+		crc := make([]byte, 4)
+		binary.BigEndian.PutUint32(crc, crc32.Checksum(data, crc32cTable))
+		if got := base64.StdEncoding.EncodeToString(crc); d.Crc32c != "" && got != d.Crc32c {
+			return nil, errorf(http.StatusBadRequest, "crc32c %s does not match the sent value %s", got, d.Crc32c)
+		}
+	}
+	o := s.putLocked(b, attrs, data)
+	// Composite objects have no MD5 hash.
+	o.Md5Hash = ""
+	o.ComponentCount = components
+	if req.DeleteSourceObjects {
+		for _, src := range req.SourceObjects {
+			if src.Name != name {
+				delete(b.objects, src.Name)
+			}
+		}
+	}
+	return o, nil
+}
+
+func (s *Server) rewrite(r *http.Request, srcBucket, srcName, dstBucket, dstName string) (*raw.RewriteResponse, error) {
+	var meta raw.Object
+	if err := json.NewDecoder(r.Body).Decode(&meta); err != nil && err != io.EOF {
+		return nil, errorf(http.StatusBadRequest, "decoding metadata: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, src, err := s.lookupLocked(srcBucket, srcName)
+	if err != nil {
+		return nil, err
+	}
+	b, dst, err := s.lookupLocked(dstBucket, dstName)
+	if b == nil {
+		return nil, err
+	}
+	if err := checkGeneration(r.URL.Query(), dst); err != nil {
+		return nil, err
+	}
+	attrs := &raw.Object{Name: dstName, ContentType: src.attrs.ContentType, Metadata: src.attrs.Metadata}
+	if meta.ContentType != "" {
+		attrs.ContentType = meta.ContentType
+	}
+	if meta.Metadata != nil {
+		attrs.Metadata = meta.Metadata
+	}
+	o := s.putLocked(b, attrs, append([]byte(nil), src.data...))
+	return &raw.RewriteResponse{
+		Kind:                "storage#rewriteResponse",
+		Done:                true,
+		ObjectSize:          int64(len(src.data)),
+		TotalBytesRewritten: int64(len(src.data)),
+		Resource:            o,
+	}, nil
+}
+
+// download serves object contents with the headers of the XML API.
+func (s *Server) download(w http.ResponseWriter, r *http.Request, bucketName, name string) error {
+	s.mu.Lock()
+	_, o, err := s.lookupLocked(bucketName, name)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	h := w.Header()
+	h.Set("Content-Type", o.attrs.ContentType)
+	h.Set("X-Goog-Generation", strconv.FormatInt(o.attrs.Generation, 10))
+	h.Set("X-Goog-Metageneration", strconv.FormatInt(o.attrs.Metageneration, 10))
+	h.Set("X-Goog-Stored-Content-Length", strconv.Itoa(len(o.data)))
+	hash := "crc32c=" + o.attrs.Crc32c
+	if o.attrs.Md5Hash != "" {
+		hash += ",md5=" + o.attrs.Md5Hash
+	}
+	h.Set("X-Goog-Hash", hash)
+	h.Set("ETag", strconv.Quote(o.xmlETag()))
+	for k, v := range o.attrs.Metadata {
+		h.Set("X-Goog-Meta-"+k, v)
+	}
+	// http.ServeContent handles Range requests.
+	updated, _ := time.Parse(time.RFC3339Nano, o.attrs.Updated)
+	http.ServeContent(w, r, "", updated, bytes.NewReader(o.data))
+	return nil
+}
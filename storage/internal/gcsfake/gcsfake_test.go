@@ -0,0 +1,156 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsfake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+)
+
+func newClient(t *testing.T) (*Server, *storage.Client) {
+	t.Helper()
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	client, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return srv, client
+}
+
+func write(t *testing.T, o *storage.ObjectHandle, data []byte, chunkSize int) {
+	t.Helper()
+	w := o.NewWriter(context.Background())
+	w.ChunkSize = chunkSize
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func read(t *testing.T, o *storage.ObjectHandle, off, length int64) []byte {
+	t.Helper()
+	r, err := o.NewRangeReader(context.Background(), off, length)
+	if err != nil {
+		t.Fatalf("NewRangeReader: %v", err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	return b
+}
+
+func TestObjects(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newClient(t)
+	srv.CreateBucket("b")
+	bkt := client.Bucket("b")
+
+	small := []byte("hello")
+	large := bytes.Repeat([]byte("0123456789"), 60_000)
+	write(t, bkt.Object("dir/small"), small, 0)
+	write(t, bkt.Object("dir/sub/large"), large, 256*1024)
+	write(t, bkt.Object("top"), small, 0)
+
+	if got := read(t, bkt.Object("dir/sub/large"), 0, -1); !bytes.Equal(got, large) {
+		t.Errorf("resumable upload round trip lost data: got %d bytes", len(got))
+	}
+	if got := read(t, bkt.Object("dir/sub/large"), 10, 5); string(got) != "01234" {
+		t.Errorf("range read = %q, want 01234", got)
+	}
+	attrs, err := bkt.Object("dir/small").Attrs(ctx)
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if attrs.Size != 5 || attrs.CRC32C == 0 || len(attrs.MD5) != 16 {
+		t.Errorf("Attrs = %+v, want size and hashes", attrs)
+	}
+
+	var got []string
+	it := bkt.Objects(ctx, &storage.Query{Prefix: "dir/", Delimiter: "/"})
+	it.PageInfo().MaxSize = 1
+	for {
+		a, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, a.Name+a.Prefix)
+	}
+	if diff := cmp.Diff([]string{"dir/small", "dir/sub/"}, got); diff != "" {
+		t.Errorf("list mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := bkt.Object("joined").ComposerFrom(bkt.Object("dir/small"), bkt.Object("top")).Run(ctx); err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+	if got := read(t, bkt.Object("joined"), 0, -1); string(got) != "hellohello" {
+		t.Errorf("composed object = %q, want hellohello", got)
+	}
+	if _, err := bkt.Object("copy").CopierFrom(bkt.Object("top")).Run(ctx); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if err := bkt.Object("top").Delete(ctx); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := bkt.Object("top").Attrs(ctx); !errors.Is(err, storage.ErrObjectNotExist) {
+		t.Errorf("Attrs after Delete got %v, want ErrObjectNotExist", err)
+	}
+	w := bkt.Object("copy").If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	w.Write(small)
+	if err := w.Close(); err == nil {
+		t.Errorf("overwriting with DoesNotExist succeeded")
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+	bkt := client.Bucket("b")
+	if err := bkt.Create(ctx, "p", &storage.BucketAttrs{Labels: map[string]string{"env": "dev"}}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	attrs, err := bkt.Update(ctx, storage.BucketAttrsToUpdate{VersioningEnabled: true})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !attrs.VersioningEnabled || attrs.Labels["env"] != "dev" {
+		t.Errorf("Update = %+v, want versioning on and labels kept", attrs)
+	}
+	stale := bkt.If(storage.BucketConditions{MetagenerationMatch: attrs.MetaGeneration - 1})
+	if _, err := stale.Update(ctx, storage.BucketAttrsToUpdate{RequesterPays: true}); err == nil {
+		t.Errorf("Update with a stale metageneration succeeded, want error")
+	}
+	if err := bkt.Delete(ctx); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := bkt.Attrs(ctx); !errors.Is(err, storage.ErrBucketNotExist) {
+		t.Errorf("Attrs after Delete got %v, want ErrBucketNotExist", err)
+	}
+}
@@ -0,0 +1,542 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsfake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"cloud.google.com/go/storage/experimental"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// maxReadChunk is the most object data sent in one BidiReadObject
+// response.
+const maxReadChunk = 2 << 20
+
+// GRPCServer is a fake of the Cloud Storage gRPC API for appendable objects
+// in zonal buckets. It implements BidiWriteObject, BidiReadObject,
+// GetObject and DeleteObject. Buckets need not be created first.
+//
+// The generated storage types are internal to the client library, so the
+// server works with the message types the library registers and converts
+// them to the local structs below through their JSON form.
+type GRPCServer struct {
+	// Addr is the address the server listens on.
+	Addr string
+
+	srv *grpc.Server
+
+	mu      sync.Mutex
+	objects map[string]*appendable
+	gen     int64
+}
+
+type appendable struct {
+	obj  grpcObject
+	data []byte
+	// owner identifies the write stream that may append. A takeover
+	// replaces it, failing the stream that held it.
+	owner int64
+}
+
+// NewGRPCServer starts a fake gRPC server. Call Close when done.
+func NewGRPCServer() (*GRPCServer, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &GRPCServer{
+		Addr:    lis.Addr().String(),
+		srv:     grpc.NewServer(),
+		objects: make(map[string]*appendable),
+	}
+	s.srv.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "google.storage.v2.Storage",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "GetObject", Handler: unary("GetObjectRequest", s.getObject)},
+			{MethodName: "DeleteObject", Handler: unary("DeleteObjectRequest", s.deleteObject)},
+		},
+		Streams: []grpc.StreamDesc{
+			{StreamName: "BidiWriteObject", Handler: s.bidiWriteObject, ServerStreams: true, ClientStreams: true},
+			{StreamName: "BidiReadObject", Handler: s.bidiReadObject, ServerStreams: true, ClientStreams: true},
+		},
+	}, s)
+	go s.srv.Serve(lis)
+	return s, nil
+}
+
+// Close stops the server.
+func (s *GRPCServer) Close() { s.srv.Stop() }
+
+// Client returns a gRPC storage client with the zonal bucket APIs enabled
+// that talks to the server.
+func (s *GRPCServer) Client(ctx context.Context, opts ...option.ClientOption) (*storage.Client, error) {
+	opts = append([]option.ClientOption{
+		experimental.WithZonalBucketAPIs(),
+		option.WithEndpoint(s.Addr),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithoutAuthentication(),
+		storage.WithDisabledClientMetrics(),
+	}, opts...)
+	return storage.NewGRPCClient(ctx, opts...)
+}
+
+// Data returns the contents of an object.
+func (s *GRPCServer) Data(bucket, object string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[grpcKey(bucket, object)]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), o.data...), true
+}
+
+// grpcKey identifies an object. Buckets may be given as names or as
+// "projects/_/buckets/NAME".
+func grpcKey(bucket, object string) string {
+	return strings.TrimPrefix(bucket, "projects/_/buckets/") + "/" + object
+}
+
+func (s *GRPCServer) lookupLocked(bucket, object string, gen int64) (*appendable, error) {
+	o, ok := s.objects[grpcKey(bucket, object)]
+	if !ok || gen != 0 && int64(o.obj.Generation) != gen {
+		return nil, status.Errorf(codes.NotFound, "object %s not found", grpcKey(bucket, object))
+	}
+	return o, nil
+}
+
+type objectRequest struct {
+	Bucket     string `json:"bucket"`
+	Object     string `json:"object"`
+	Generation int64s `json:"generation"`
+}
+
+func (s *GRPCServer) getObject(req *objectRequest) (proto.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.lookupLocked(req.Bucket, req.Object, int64(req.Generation))
+	if err != nil {
+		return nil, err
+	}
+	return encode("Object", o.obj)
+}
+
+func (s *GRPCServer) deleteObject(req *objectRequest) (proto.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.lookupLocked(req.Bucket, req.Object, int64(req.Generation)); err != nil {
+		return nil, err
+	}
+	delete(s.objects, grpcKey(req.Bucket, req.Object))
+	return newMessage("google.protobuf.Empty"), nil
+}
+
+type writeRequest struct {
+	WriteObjectSpec *struct {
+		Resource          grpcObject `json:"resource"`
+		IfGenerationMatch *int64s    `json:"ifGenerationMatch"`
+		Appendable        bool       `json:"appendable"`
+	} `json:"writeObjectSpec"`
+	AppendObjectSpec *objectRequest   `json:"appendObjectSpec"`
+	WriteOffset      int64s           `json:"writeOffset"`
+	ChecksummedData  *checksummedData `json:"checksummedData"`
+	ObjectChecksums  *checksums       `json:"objectChecksums"`
+	StateLookup      bool             `json:"stateLookup"`
+	Flush            bool             `json:"flush"`
+	FinishWrite      bool             `json:"finishWrite"`
+}
+
+type writeResponse struct {
+	Resource    *grpcObject `json:"resource,omitempty"`
+	WriteHandle *handle     `json:"writeHandle,omitempty"`
+}
+
+// bidiWriteObject creates or appends to an appendable object. Data is
+// visible to readers as soon as it arrives.
+func (s *GRPCServer) bidiWriteObject(_ any, stream grpc.ServerStream) error {
+	var req writeRequest
+	if err := recv(stream, "BidiWriteObjectRequest", &req); err != nil {
+		return err
+	}
+	o, owner, err := s.open(&req)
+	if err != nil {
+		return err
+	}
+	if req.AppendObjectSpec != nil {
+		// A takeover learns the persisted size before sending data.
+		s.mu.Lock()
+		resp := s.stateLocked(o, owner)
+		s.mu.Unlock()
+		if err := send(stream, "BidiWriteObjectResponse", resp); err != nil {
+			return err
+		}
+	}
+	for {
+		resp, err := s.write(o, owner, &req)
+		if err != nil {
+			return err
+		}
+		if resp != nil {
+			if err := send(stream, "BidiWriteObjectResponse", resp); err != nil {
+				return err
+			}
+		}
+		req = writeRequest{}
+		if err := recv(stream, "BidiWriteObjectRequest", &req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// open handles the first message of a write stream, creating the object
+// or taking it over.
+func (s *GRPCServer) open(req *writeRequest) (*appendable, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	owner := s.gen
+
+	if spec := req.AppendObjectSpec; spec != nil {
+		o, err := s.lookupLocked(spec.Bucket, spec.Object, int64(spec.Generation))
+		if err != nil {
+			return nil, 0, err
+		}
+		if o.obj.FinalizeTime != nil {
+			return nil, 0, status.Errorf(codes.FailedPrecondition, "object %s is finalized", grpcKey(spec.Bucket, spec.Object))
+		}
+		o.owner = owner
+		return o, owner, nil
+	}
+
+	spec := req.WriteObjectSpec
+	if spec == nil {
+		return nil, 0, status.Error(codes.InvalidArgument, "first message has no object spec")
+	}
+	k := grpcKey(spec.Resource.Bucket, spec.Resource.Name)
+	if want := spec.IfGenerationMatch; want != nil {
+		var gen int64s
+		if cur, ok := s.objects[k]; ok {
+			gen = cur.obj.Generation
+		}
+		if gen != *want {
+			return nil, 0, status.Errorf(codes.FailedPrecondition, "generation %d does not match %d", gen, *want)
+		}
+	}
+	now := time.Now().UTC()
+	o := &appendable{
+		obj: grpcObject{
+			Bucket:         spec.Resource.Bucket,
+			Name:           spec.Resource.Name,
+			Generation:     int64s(s.gen),
+			Metageneration: 1,
+			ContentType:    spec.Resource.ContentType,
+			Metadata:       spec.Resource.Metadata,
+			StorageClass:   "RAPID",
+			CreateTime:     &now,
+			UpdateTime:     &now,
+			Checksums:      &checksums{Crc32c: new(uint32)},
+		},
+		owner: owner,
+	}
+	s.objects[k] = o
+	return o, owner, nil
+}
+
+// write applies one request to o and returns the response to send, if
+// any.
+func (s *GRPCServer) write(o *appendable, owner int64, req *writeRequest) (*writeResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o.owner != owner {
+		return nil, status.Error(codes.FailedPrecondition, "the object was taken over by another writer")
+	}
+	if o.obj.FinalizeTime != nil {
+		return nil, status.Error(codes.FailedPrecondition, "the object is finalized")
+	}
+	if d := req.ChecksummedData; d != nil {
+		if d.Crc32c != nil && crc32.Checksum(d.Content, crc32cTable) != *d.Crc32c {
+			return nil, status.Error(codes.InvalidArgument, "crc32c of the data does not match")
+		}
+		off := int64(req.WriteOffset)
+		if off > int64(len(o.data)) {
+			return nil, status.Errorf(codes.OutOfRange, "write offset %d is past the persisted size %d", off, len(o.data))
+		}
+		// Data below the persisted size is a replay after a reconnect.
+		if skip := int64(len(o.data)) - off; skip < int64(len(d.Content)) {
+			o.data = append(o.data, d.Content[skip:]...)
+		}
+		now := time.Now().UTC()
+		o.obj.Size = int64s(len(o.data))
+		o.obj.Checksums = &checksums{Crc32c: ptr(crc32.Checksum(o.data, crc32cTable))}
+		o.obj.UpdateTime = &now
+	}
+	if req.FinishWrite {
+		if c := req.ObjectChecksums; c != nil && c.Crc32c != nil && *c.Crc32c != *o.obj.Checksums.Crc32c {
+			return nil, status.Error(codes.InvalidArgument, "crc32c of the object does not match")
+		}
+		now := time.Now().UTC()
+		o.obj.FinalizeTime = &now
+	}
+	if req.FinishWrite || req.Flush || req.StateLookup {
+		return s.stateLocked(o, owner), nil
+	}
+	return nil, nil
+}
+
+func (s *GRPCServer) stateLocked(o *appendable, owner int64) *writeResponse {
+	obj := o.obj
+	return &writeResponse{
+		Resource:    &obj,
+		WriteHandle: &handle{Handle: []byte(strconv.FormatInt(owner, 10))},
+	}
+}
+
+type readRange struct {
+	ReadOffset int64s `json:"readOffset"`
+	ReadLength int64s `json:"readLength"`
+	ReadID     int64s `json:"readId"`
+}
+
+type readRequest struct {
+	ReadObjectSpec *objectRequest `json:"readObjectSpec"`
+	ReadRanges     []readRange    `json:"readRanges"`
+}
+
+type readResponse struct {
+	Metadata         *grpcObject `json:"metadata,omitempty"`
+	ReadHandle       *handle     `json:"readHandle,omitempty"`
+	ObjectDataRanges []rangeData `json:"objectDataRanges,omitempty"`
+}
+
+type rangeData struct {
+	ChecksummedData checksummedData `json:"checksummedData"`
+	ReadRange       readRange       `json:"readRange"`
+	RangeEnd        bool            `json:"rangeEnd"`
+}
+
+// bidiReadObject serves ranges of an object. A range with length zero
+// reads to the current end of the object, which may grow between
+// requests.
+func (s *GRPCServer) bidiReadObject(_ any, stream grpc.ServerStream) error {
+	var req readRequest
+	if err := recv(stream, "BidiReadObjectRequest", &req); err != nil {
+		return err
+	}
+	spec := req.ReadObjectSpec
+	if spec == nil {
+		return status.Error(codes.InvalidArgument, "first message has no object spec")
+	}
+	s.mu.Lock()
+	o, err := s.lookupLocked(spec.Bucket, spec.Object, int64(spec.Generation))
+	var meta grpcObject
+	if err == nil {
+		meta = o.obj
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	err = send(stream, "BidiReadObjectResponse", &readResponse{
+		Metadata:   &meta,
+		ReadHandle: &handle{Handle: []byte(strconv.FormatInt(int64(meta.Generation), 10))},
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		for _, r := range req.ReadRanges {
+			if err := s.sendRange(stream, o, r); err != nil {
+				return err
+			}
+		}
+		req = readRequest{}
+		if err := recv(stream, "BidiReadObjectRequest", &req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *GRPCServer) sendRange(stream grpc.ServerStream, o *appendable, r readRange) error {
+	s.mu.Lock()
+	data := o.data
+	s.mu.Unlock()
+
+	off, length := int64(r.ReadOffset), int64(r.ReadLength)
+	size := int64(len(data))
+	if off < 0 {
+		off = max(size+off, 0)
+	}
+	if off > size {
+		return status.Errorf(codes.OutOfRange, "read offset %d is past the object size %d", off, size)
+	}
+	if length == 0 || off+length > size {
+		length = size - off
+	}
+	for pos := off; ; {
+		n := min(off+length-pos, maxReadChunk)
+		chunk := data[pos : pos+n]
+		end := pos+n == off+length
+		err := send(stream, "BidiReadObjectResponse", &readResponse{
+			ObjectDataRanges: []rangeData{{
+				ChecksummedData: checksummedData{Content: chunk, Crc32c: ptr(crc32.Checksum(chunk, crc32cTable))},
+				ReadRange:       readRange{ReadOffset: int64s(pos), ReadLength: int64s(n), ReadID: r.ReadID},
+				RangeEnd:        end,
+			}},
+		})
+		if err != nil || end {
+			return err
+		}
+		pos += n
+	}
+}
+
+// grpcObject is the JSON form of google.storage.v2.Object.
+type grpcObject struct {
+	Bucket         string            `json:"bucket,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Generation     int64s            `json:"generation,omitempty"`
+	Metageneration int64s            `json:"metageneration,omitempty"`
+	Size           int64s            `json:"size,omitempty"`
+	ContentType    string            `json:"contentType,omitempty"`
+	StorageClass   string            `json:"storageClass,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Checksums      *checksums        `json:"checksums,omitempty"`
+	CreateTime     *time.Time        `json:"createTime,omitempty"`
+	UpdateTime     *time.Time        `json:"updateTime,omitempty"`
+	FinalizeTime   *time.Time        `json:"finalizeTime,omitempty"`
+}
+
+type checksums struct {
+	Crc32c *uint32 `json:"crc32c,omitempty"`
+}
+
+type checksummedData struct {
+	Content []byte  `json:"content,omitempty"`
+	Crc32c  *uint32 `json:"crc32c,omitempty"`
+}
+
+type handle struct {
+	Handle []byte `json:"handle"`
+}
+
+// int64s is an int64 field, which protojson writes as a string.
+type int64s int64
+
+func (n *int64s) UnmarshalJSON(b []byte) error {
+	v, err := strconv.ParseInt(strings.Trim(string(b), `"`), 10, 64)
+	*n = int64s(v)
+	return err
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// newMessage returns an empty message of the named type.
+func newMessage(name protoreflect.FullName) proto.Message {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(name)
+	if err != nil {
+		panic(fmt.Sprintf("gcsfake: %v", err))
+	}
+	return mt.New().Interface()
+}
+
+// encode converts v to a google.storage.v2 message of the named type.
+func encode(name string, v any) (proto.Message, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := newMessage(protoreflect.FullName("google.storage.v2." + name))
+	if err := protojson.Unmarshal(b, m); err != nil {
+		return nil, status.Errorf(codes.Internal, "encoding %s: %v", name, err)
+	}
+	return m, nil
+}
+
+// decode converts a google.storage.v2 message to v. Field masks are
+// dropped, as the server always returns whole objects and protojson cannot
+// encode the "*" mask the client sends.
+func decode(m proto.Message, v any) error {
+	r := m.ProtoReflect()
+	r.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if md := fd.Message(); md != nil && md.FullName() == "google.protobuf.FieldMask" {
+			r.Clear(fd)
+		}
+		return true
+	})
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return status.Errorf(codes.InvalidArgument, "decoding %s: %v", m.ProtoReflect().Descriptor().Name(), err)
+	}
+	return nil
+}
+
+func recv(stream grpc.ServerStream, name string, v any) error {
+	m := newMessage(protoreflect.FullName("google.storage.v2." + name))
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return decode(m, v)
+}
+
+func send(stream grpc.ServerStream, name string, v any) error {
+	m, err := encode(name, v)
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(m)
+}
+
+// unary adapts a handler of a request decoded from the named message type
+// to a grpc.MethodDesc handler.
+func unary[Req any](name string, h func(*Req) (proto.Message, error)) func(any, context.Context, func(any) error, grpc.UnaryServerInterceptor) (any, error) {
+	return func(_ any, _ context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+		m := newMessage(protoreflect.FullName("google.storage.v2." + name))
+		if err := dec(m); err != nil {
+			return nil, err
+		}
+		req := new(Req)
+		if err := decode(m, req); err != nil {
+			return nil, err
+		}
+		return h(req)
+	}
+}
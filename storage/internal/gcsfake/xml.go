@@ -0,0 +1,445 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsfake
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	raw "google.golang.org/api/storage/v1"
+)
+
+// minPartSize is the smallest part, other than the last, accepted in an
+// XML API multipart upload.
+const minPartSize = 5 << 20
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type multipartUpload struct {
+	bucket      string
+	name        string
+	contentType string
+	parts       map[int][]byte
+}
+
+// xmlETag returns the object's XML API ETag: the hex MD5 of its contents,
+// or an MD5 of the part MD5s suffixed with the part count for multipart
+// uploads.
+func (o *object) xmlETag() string {
+	if o.etag != "" {
+		return o.etag
+	}
+	if sum, err := base64.StdEncoding.DecodeString(o.attrs.Md5Hash); err == nil && len(sum) > 0 {
+		return hex.EncodeToString(sum)
+	}
+	return o.attrs.Etag
+}
+
+// xmlTime formats a JSON API timestamp as the XML API does.
+func xmlTime(t string) string {
+	v, _ := time.Parse(time.RFC3339Nano, t)
+	return v.Format("2006-01-02T15:04:05.000Z")
+}
+
+// writeXMLError writes err as an XML API error document.
+func writeXMLError(w http.ResponseWriter, err error) {
+	code, msg := http.StatusInternalServerError, err.Error()
+	if e, ok := err.(*httpError); ok {
+		code = e.code
+	}
+	s3Code := "InternalError"
+	switch {
+	case code == http.StatusNotFound && strings.HasPrefix(msg, "bucket"):
+		s3Code = "NoSuchBucket"
+	case code == http.StatusNotFound && strings.HasPrefix(msg, "upload"):
+		s3Code = "NoSuchUpload"
+	case code == http.StatusNotFound:
+		s3Code = "NoSuchKey"
+	case code == http.StatusPreconditionFailed:
+		s3Code = "PreconditionFailed"
+	case code == http.StatusBadRequest:
+		s3Code = "InvalidArgument"
+	case code == http.StatusMethodNotAllowed:
+		s3Code = "MethodNotAllowed"
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: s3Code, Message: msg})
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) error {
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// xml serves the XML API with path-style URLs, including the Amazon S3
+// compatible calls: list buckets, list objects (V1 and V2), put, get, head,
+// copy, delete and multipart uploads. Authentication is not checked, so
+// signed URLs and HMAC-signed requests are accepted as is.
+func (s *Server) xml(w http.ResponseWriter, r *http.Request, segs []string) error {
+	if len(segs) == 1 && segs[0] == "" {
+		segs = nil
+	}
+	q := r.URL.Query()
+	switch {
+	case len(segs) == 0 && r.Method == http.MethodGet:
+		return s.xmlListBuckets(w)
+	case len(segs) == 1 && r.Method == http.MethodGet:
+		return s.xmlList(w, segs[0], q)
+	case len(segs) < 2:
+		return errorf(http.StatusMethodNotAllowed, "%s not allowed", r.Method)
+	}
+
+	bucketName, name := segs[0], strings.Join(segs[1:], "/")
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		return s.initiateMultipart(w, r, bucketName, name)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		return s.uploadPart(w, r, q)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		return s.completeMultipart(w, r, q.Get("uploadId"))
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		return s.abortMultipart(w, q.Get("uploadId"))
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		return s.xmlCopy(w, r.Header.Get("X-Amz-Copy-Source"), bucketName, name)
+	case r.Method == http.MethodPut:
+		return s.xmlPut(w, r, bucketName, name)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		return s.download(w, r, bucketName, name)
+	case r.Method == http.MethodDelete:
+		// Unlike Amazon S3, deleting a missing object is an error.
+		if _, err := s.object(r, bucketName, name); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return errorf(http.StatusMethodNotAllowed, "%s not allowed", r.Method)
+}
+
+func (s *Server) xmlListBuckets(w http.ResponseWriter) error {
+	type entry struct {
+		Name         string
+		CreationDate string
+	}
+	var resp struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Buckets []entry  `xml:"Buckets>Bucket"`
+	}
+	resp.Xmlns = s3Namespace
+	s.mu.Lock()
+	for _, b := range s.buckets {
+		resp.Buckets = append(resp.Buckets, entry{Name: b.attrs.Name, CreationDate: xmlTime(b.attrs.TimeCreated)})
+	}
+	s.mu.Unlock()
+	sort.Slice(resp.Buckets, func(i, j int) bool { return resp.Buckets[i].Name < resp.Buckets[j].Name })
+	return writeXML(w, resp)
+}
+
+// xmlList lists objects with ListObjects (V1) or, given list-type=2,
+// ListObjectsV2. Continuation tokens and markers are JSON API page tokens.
+func (s *Server) xmlList(w http.ResponseWriter, bucketName string, q url.Values) error {
+	v2 := q.Get("list-type") == "2"
+	token := q.Get("marker")
+	if v2 {
+		token = q.Get("continuation-token")
+		if token == "" {
+			token = q.Get("start-after")
+		}
+	}
+	res, err := s.list(bucketName, url.Values{
+		"prefix":     {q.Get("prefix")},
+		"delimiter":  {q.Get("delimiter")},
+		"pageToken":  {token},
+		"maxResults": {q.Get("max-keys")},
+	})
+	if err != nil {
+		return err
+	}
+
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         uint64
+		StorageClass string
+	}
+	type commonPrefix struct{ Prefix string }
+	var resp struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Xmlns                 string   `xml:"xmlns,attr"`
+		Name                  string
+		Prefix                string
+		Delimiter             string `xml:",omitempty"`
+		Marker                *string
+		NextMarker            string `xml:",omitempty"`
+		ContinuationToken     string `xml:",omitempty"`
+		NextContinuationToken string `xml:",omitempty"`
+		KeyCount              *int
+		IsTruncated           bool
+		Contents              []content
+		CommonPrefixes        []commonPrefix
+	}
+	resp.Xmlns = s3Namespace
+	resp.Name = bucketName
+	resp.Prefix = q.Get("prefix")
+	resp.Delimiter = q.Get("delimiter")
+	resp.IsTruncated = res.NextPageToken != ""
+
+	s.mu.Lock()
+	for _, item := range res.Items {
+		etag := item.Etag
+		if o, ok := s.buckets[bucketName].objects[item.Name]; ok {
+			etag = o.xmlETag()
+		}
+		resp.Contents = append(resp.Contents, content{
+			Key:          item.Name,
+			LastModified: xmlTime(item.Updated),
+			ETag:         strconv.Quote(etag),
+			Size:         item.Size,
+			StorageClass: item.StorageClass,
+		})
+	}
+	s.mu.Unlock()
+	for _, p := range res.Prefixes {
+		resp.CommonPrefixes = append(resp.CommonPrefixes, commonPrefix{Prefix: p})
+	}
+	if v2 {
+		n := len(resp.Contents) + len(resp.CommonPrefixes)
+		resp.KeyCount = &n
+		resp.ContinuationToken = q.Get("continuation-token")
+		resp.NextContinuationToken = res.NextPageToken
+	} else {
+		marker := q.Get("marker")
+		resp.Marker = &marker
+		resp.NextMarker = res.NextPageToken
+	}
+	return writeXML(w, resp)
+}
+
+// xmlAttrs returns the object attributes sent as XML API headers.
+func xmlAttrs(r *http.Request, name string) *raw.Object {
+	attrs := &raw.Object{Name: name, ContentType: r.Header.Get("Content-Type")}
+	for k, v := range r.Header {
+		k = strings.ToLower(k)
+		for _, p := range []string{"x-goog-meta-", "x-amz-meta-"} {
+			if strings.HasPrefix(k, p) && len(v) > 0 {
+				if attrs.Metadata == nil {
+					attrs.Metadata = make(map[string]string)
+				}
+				attrs.Metadata[k[len(p):]] = v[0]
+			}
+		}
+	}
+	return attrs
+}
+
+func (s *Server) xmlPut(w http.ResponseWriter, r *http.Request, bucketName, name string) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	attrs := xmlAttrs(r, name)
+	attrs.Md5Hash = r.Header.Get("Content-Md5")
+	o, err := s.store(bucketName, url.Values{}, attrs, data)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", strconv.Quote(hex.EncodeToString(md5Sum(data))))
+	w.Header().Set("X-Goog-Generation", strconv.FormatInt(o.Generation, 10))
+	return nil
+}
+
+func md5Sum(data []byte) []byte {
+	sum := md5.Sum(data)
+	return sum[:]
+}
+
+// xmlCopy copies the object named by an X-Amz-Copy-Source header, which
+// is "BUCKET/OBJECT" with an optional leading slash and URL escaping.
+func (s *Server) xmlCopy(w http.ResponseWriter, source, dstBucket, dstName string) error {
+	source, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+	if err != nil {
+		return errorf(http.StatusBadRequest, "bad copy source: %v", err)
+	}
+	srcBucket, srcName, ok := strings.Cut(source, "/")
+	if !ok {
+		return errorf(http.StatusBadRequest, "bad copy source %q", source)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, src, err := s.lookupLocked(srcBucket, srcName)
+	if err != nil {
+		return err
+	}
+	b, _, err := s.lookupLocked(dstBucket, dstName)
+	if b == nil {
+		return err
+	}
+	o := s.putLocked(b, &raw.Object{Name: dstName, ContentType: src.attrs.ContentType, Metadata: src.attrs.Metadata}, src.data)
+	// A copy keeps the source's checksums, so a copied multipart upload
+	// still has no MD5.
+	o.Md5Hash = src.attrs.Md5Hash
+	o.ComponentCount = src.attrs.ComponentCount
+	dst := b.objects[dstName]
+	dst.etag = src.etag
+
+	w.Header().Set("X-Goog-Generation", strconv.FormatInt(o.Generation, 10))
+	return writeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		LastModified string
+		ETag         string
+	}{LastModified: xmlTime(o.Updated), ETag: strconv.Quote(dst.xmlETag())})
+}
+
+func (s *Server) initiateMultipart(w http.ResponseWriter, r *http.Request, bucketName, name string) error {
+	s.mu.Lock()
+	if _, ok := s.buckets[bucketName]; !ok {
+		s.mu.Unlock()
+		return errorf(http.StatusNotFound, "bucket %s not found", bucketName)
+	}
+	s.gen++
+	id := fmt.Sprintf("mpu-%d", s.gen)
+	s.parts[id] = &multipartUpload{
+		bucket:      bucketName,
+		name:        name,
+		contentType: r.Header.Get("Content-Type"),
+		parts:       make(map[int][]byte),
+	}
+	s.mu.Unlock()
+	return writeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Xmlns: s3Namespace, Bucket: bucketName, Key: name, UploadId: id})
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, q url.Values) error {
+	n, err := strconv.Atoi(q.Get("partNumber"))
+	if err != nil || n < 1 || n > 10000 {
+		return errorf(http.StatusBadRequest, "bad part number %q", q.Get("partNumber"))
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if want := r.Header.Get("Content-Md5"); want != "" && want != base64.StdEncoding.EncodeToString(md5Sum(data)) {
+		return errorf(http.StatusBadRequest, "md5 of part %d does not match %s", n, want)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.parts[q.Get("uploadId")]
+	if !ok {
+		return errorf(http.StatusNotFound, "upload %s not found", q.Get("uploadId"))
+	}
+	u.parts[n] = data
+	w.Header().Set("ETag", strconv.Quote(hex.EncodeToString(md5Sum(data))))
+	return nil
+}
+
+func (s *Server) completeMultipart(w http.ResponseWriter, r *http.Request, id string) error {
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		return errorf(http.StatusBadRequest, "bad request body: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.parts[id]
+	if !ok {
+		return errorf(http.StatusNotFound, "upload %s not found", id)
+	}
+	if len(req.Parts) == 0 {
+		return errorf(http.StatusBadRequest, "upload %s has no parts", id)
+	}
+	var data, sums []byte
+	for i, p := range req.Parts {
+		part, ok := u.parts[p.PartNumber]
+		if !ok || i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			return errorf(http.StatusBadRequest, "part %d is missing or out of order", p.PartNumber)
+		}
+		sum := md5Sum(part)
+		if strings.Trim(p.ETag, `"`) != hex.EncodeToString(sum) {
+			return errorf(http.StatusBadRequest, "part %d has ETag %s, not %s", p.PartNumber, hex.EncodeToString(sum), p.ETag)
+		}
+		if i < len(req.Parts)-1 && len(part) < minPartSize {
+			return errorf(http.StatusBadRequest, "part %d is smaller than %d bytes", p.PartNumber, minPartSize)
+		}
+		data = append(data, part...)
+		sums = append(sums, sum...)
+	}
+	b, _, err := s.lookupLocked(u.bucket, u.name)
+	if b == nil {
+		return err
+	}
+	delete(s.parts, id)
+
+	// The assembled object is composite: it has a CRC32C but no MD5.
+	o := s.putLocked(b, &raw.Object{Name: u.name, ContentType: u.contentType}, data)
+	o.Md5Hash = ""
+	o.ComponentCount = int64(len(req.Parts))
+	dst := b.objects[u.name]
+	dst.etag = fmt.Sprintf("%x-%d", md5.Sum(sums), len(req.Parts))
+
+	return writeXML(w, struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string
+		Bucket   string
+		Key      string
+		ETag     string
+	}{
+		Xmlns:    s3Namespace,
+		Location: fmt.Sprintf("%s/%s/%s", s.URL, u.bucket, u.name),
+		Bucket:   u.bucket,
+		Key:      u.name,
+		ETag:     strconv.Quote(dst.etag),
+	})
+}
+
+func (s *Server) abortMultipart(w http.ResponseWriter, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.parts[id]; !ok {
+		return errorf(http.StatusNotFound, "upload %s not found", id)
+	}
+	delete(s.parts, id)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rapid
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/golang-samples/storage/internal/gcsfake"
+)
+
+const fakeBucket = "logs"
+
+func newFakeClient(t *testing.T) (*gcsfake.GRPCServer, *storage.Client) {
+	t.Helper()
+	srv, err := gcsfake.NewGRPCServer()
+	if err != nil {
+		t.Fatalf("gcsfake.NewGRPCServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	c, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return srv, c
+}
+
+// waitForData waits until the fake object holds want.
+func waitForData(t *testing.T, srv *gcsfake.GRPCServer, object, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := srv.Data(fakeBucket, object)
+		if string(got) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("object data = %q, want %q", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	ctx := context.Background()
+	srv, c := newFakeClient(t)
+
+	lw, err := newLogWriter(ctx, c, fakeBucket, "job.log", logWriterOptions{FlushInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("newLogWriter: %v", err)
+	}
+	if lw.Offset != 0 {
+		t.Errorf("Offset = %d, want 0", lw.Offset)
+	}
+	io.WriteString(lw, "one\n")
+	if err := lw.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	waitForData(t, srv, "job.log", "one\n")
+
+	// The background flush picks up later writes.
+	io.WriteString(lw, "two\n")
+	waitForData(t, srv, "job.log", "one\ntwo\n")
+
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	attrs, err := c.Bucket(fakeBucket).Object("job.log").Attrs(ctx)
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if !attrs.Finalized.IsZero() {
+		t.Errorf("object is finalized, want appendable")
+	}
+}
+
+func TestLogWriterTakeover(t *testing.T) {
+	ctx := context.Background()
+	srv, c := newFakeClient(t)
+
+	// The first writer flushes one line, then "crashes" with a second line
+	// still buffered.
+	crashed, err := newLogWriter(ctx, c, fakeBucket, "job.log", logWriterOptions{FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("newLogWriter: %v", err)
+	}
+	io.WriteString(crashed, "before crash\n")
+	if err := crashed.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	io.WriteString(crashed, "lost\n")
+
+	lw, err := newLogWriter(ctx, c, fakeBucket, "job.log", logWriterOptions{FlushInterval: time.Hour, FinalizeOnClose: true})
+	if err != nil {
+		t.Fatalf("newLogWriter after crash: %v", err)
+	}
+	if want := int64(len("before crash\n")); lw.Offset != want {
+		t.Errorf("Offset = %d, want %d", lw.Offset, want)
+	}
+	io.WriteString(lw, "after restart\n")
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := lw.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	waitForData(t, srv, "job.log", "before crash\nafter restart\n")
+
+	// The writer that lost the object can no longer append to it.
+	closeErr := crashed.Close()
+	if closeErr == nil {
+		t.Errorf("Close of taken over writer succeeded, want error")
+	}
+	// Closing again doesn't panic and reports the same error.
+	if err := crashed.Close(); err != closeErr {
+		t.Errorf("second Close = %v, want %v", err, closeErr)
+	}
+	waitForData(t, srv, "job.log", "before crash\nafter restart\n")
+
+	// A finalized object cannot be taken over.
+	if _, err := newLogWriter(ctx, c, fakeBucket, "job.log", logWriterOptions{}); err == nil {
+		t.Errorf("newLogWriter of finalized object succeeded, want error")
+	}
+}
+
+func TestTailReader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, c := newFakeClient(t)
+
+	lw, err := newLogWriter(ctx, c, fakeBucket, "job.log", logWriterOptions{FlushInterval: 10 * time.Millisecond, FinalizeOnClose: true})
+	if err != nil {
+		t.Fatalf("newLogWriter: %v", err)
+	}
+	io.WriteString(lw, "skipped\n")
+	if err := lw.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	tail, err := newTailReader(ctx, c, fakeBucket, "job.log", int64(len("skipped\n")), 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("newTailReader: %v", err)
+	}
+	defer tail.Close()
+
+	var want strings.Builder
+	writeErr := make(chan error, 1)
+	go func() {
+		for i := range 20 {
+			line := strings.Repeat("x", i) + "\n"
+			want.WriteString(line)
+			if _, err := io.WriteString(lw, line); err != nil {
+				writeErr <- err
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		writeErr <- lw.Close()
+	}()
+
+	got, err := io.ReadAll(tail)
+	if err != nil {
+		t.Fatalf("reading tail: %v", err)
+	}
+	if err := <-writeErr; err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	if string(got) != want.String() {
+		t.Errorf("tail read %q, want %q", got, want.String())
+	}
+	if got, want := tail.Offset(), int64(len("skipped\n")+len(got)); got != want {
+		t.Errorf("Offset = %d, want %d", got, want)
+	}
+}
+
+func TestTailReaderContext(t *testing.T) {
+	ctx := context.Background()
+	_, c := newFakeClient(t)
+
+	lw, err := newLogWriter(ctx, c, fakeBucket, "job.log", logWriterOptions{})
+	if err != nil {
+		t.Fatalf("newLogWriter: %v", err)
+	}
+	io.WriteString(lw, "partial\n")
+	if err := lw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// An unfinalized object is followed until the context ends.
+	tctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	tail, err := newTailReader(tctx, c, fakeBucket, "job.log", 0, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("newTailReader: %v", err)
+	}
+	got, err := io.ReadAll(tail)
+	if err != context.DeadlineExceeded {
+		t.Errorf("ReadAll error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if string(got) != "partial\n" {
+		t.Errorf("tail read %q, want %q", got, "partial\n")
+	}
+}
+
+func TestBatchJobLogSink(t *testing.T) {
+	srv, c := newFakeClient(t)
+
+	var b bytes.Buffer
+	if err := batchJobLogSink(&b, c, fakeBucket, "batch.log"); err != nil {
+		t.Fatalf("batchJobLogSink: %v, output: %v", err, b.String())
+	}
+	data, _ := srv.Data(fakeBucket, "batch.log")
+	if !strings.Contains(string(data), `"msg":"job complete"`) {
+		t.Errorf("job log = %q, want it to contain the completion message", data)
+	}
+	if !strings.Contains(b.String(), string(data)) {
+		t.Errorf("output %q does not contain the job log %q", b.String(), data)
+	}
+	attrs, err := c.Bucket(fakeBucket).Object("batch.log").Attrs(context.Background())
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if attrs.Finalized.IsZero() {
+		t.Errorf("job log is not finalized")
+	}
+}
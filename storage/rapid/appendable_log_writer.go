@@ -0,0 +1,158 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rapid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// logWriterOptions configures a logWriter.
+type logWriterOptions struct {
+	// FlushInterval is how often buffered writes are flushed so that
+	// readers can see them. The default is one second.
+	FlushInterval time.Duration
+	// FinalizeOnClose finalizes the object when the writer is closed. An
+	// unfinalized object can be appended to again by a later writer.
+	FinalizeOnClose bool
+}
+
+// logWriter is an io.Writer that appends to an appendable object in a zonal
+// bucket. Writes are buffered by the storage client and flushed
+// periodically, so a crash loses at most one flush interval of data.
+//
+// If the object already exists and is not finalized, the writer takes it
+// over and continues after its persisted data. Any writer that still holds
+// the object, such as one in a process that was presumed dead, fails on its
+// next flush.
+type logWriter struct {
+	// Offset is the size of the object when the writer took it over, or
+	// zero for a new object.
+	Offset int64
+
+	mu    sync.Mutex
+	w     *storage.Writer
+	dirty bool
+	err   error
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newLogWriter opens bucket/object for appending, creating it if it does not
+// exist. The client must be a gRPC client with the zonal bucket APIs
+// enabled.
+func newLogWriter(ctx context.Context, client *storage.Client, bucket, object string, opts logWriterOptions) (*logWriter, error) {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	o := client.Bucket(bucket).Object(object)
+	lw := &logWriter{stop: make(chan struct{}), done: make(chan struct{})}
+
+	attrs, err := o.Attrs(ctx)
+	switch {
+	case errors.Is(err, storage.ErrObjectNotExist):
+		lw.w = o.If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+		lw.w.FinalizeOnClose = opts.FinalizeOnClose
+	case err != nil:
+		return nil, fmt.Errorf("Object(%q).Attrs: %w", object, err)
+	case !attrs.Finalized.IsZero():
+		return nil, fmt.Errorf("object %q is finalized and cannot be appended to", object)
+	default:
+		lw.w, lw.Offset, err = o.Generation(attrs.Generation).NewWriterFromAppendableObject(ctx, &storage.AppendableWriterOpts{
+			FinalizeOnClose: opts.FinalizeOnClose,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("NewWriterFromAppendableObject: %w", err)
+		}
+	}
+
+	go lw.flushLoop(opts.FlushInterval)
+	return lw, nil
+}
+
+// Write appends p to the object. It returns the error from a failed
+// background flush, after which the writer is unusable.
+func (lw *logWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.err != nil {
+		return 0, lw.err
+	}
+	n, err := lw.w.Write(p)
+	if err != nil {
+		lw.err = err
+	}
+	lw.dirty = lw.dirty || n > 0
+	return n, err
+}
+
+// Flush makes all data written so far visible to readers.
+func (lw *logWriter) Flush() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.flushLocked()
+}
+
+func (lw *logWriter) flushLocked() error {
+	if lw.err != nil || !lw.dirty {
+		return lw.err
+	}
+	if _, err := lw.w.Flush(); err != nil {
+		lw.err = fmt.Errorf("Writer.Flush: %w", err)
+		return lw.err
+	}
+	lw.dirty = false
+	return nil
+}
+
+func (lw *logWriter) flushLoop(interval time.Duration) {
+	defer close(lw.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-lw.stop:
+			return
+		case <-t.C:
+			// A failure is reported by the next Write, Flush or Close.
+			lw.Flush()
+		}
+	}
+}
+
+// Close flushes remaining data and closes the writer, finalizing the object
+// if requested. Closing it again returns the same error.
+func (lw *logWriter) Close() error {
+	lw.closeOnce.Do(func() {
+		close(lw.stop)
+		<-lw.done
+
+		lw.mu.Lock()
+		defer lw.mu.Unlock()
+		if err := lw.w.Close(); err != nil && lw.err == nil {
+			lw.err = fmt.Errorf("Writer.Close: %w", err)
+		}
+	})
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.err
+}
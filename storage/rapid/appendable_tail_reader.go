@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rapid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// tailReader is an io.Reader that follows an appendable object like
+// "tail -f". Read blocks until new data is flushed to the object, and
+// returns io.EOF once the object is finalized and fully read.
+type tailReader struct {
+	ctx  context.Context
+	obj  *storage.ObjectHandle
+	mrd  *storage.MultiRangeDownloader
+	poll time.Duration
+
+	off int64
+	buf bytes.Buffer
+}
+
+// newTailReader opens bucket/object for tailing from offset. It checks for
+// new data every poll interval, one second by default. Reads fail with the
+// context's error once ctx is done.
+func newTailReader(ctx context.Context, client *storage.Client, bucket, object string, offset int64, poll time.Duration) (*tailReader, error) {
+	if poll <= 0 {
+		poll = time.Second
+	}
+	obj := client.Bucket(bucket).Object(object)
+	mrd, err := obj.NewMultiRangeDownloader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("NewMultiRangeDownloader: %w", err)
+	}
+	return &tailReader{ctx: ctx, obj: obj, mrd: mrd, poll: poll, off: offset}, nil
+}
+
+// Offset returns the offset of the next byte Read returns.
+func (t *tailReader) Offset() int64 { return t.off - int64(t.buf.Len()) }
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	for t.buf.Len() == 0 {
+		attrs, err := t.obj.Attrs(t.ctx)
+		if err != nil {
+			if t.ctx.Err() != nil {
+				return 0, t.ctx.Err()
+			}
+			return 0, fmt.Errorf("Object.Attrs: %w", err)
+		}
+		if attrs.Size > t.off {
+			if err := t.fetch(attrs.Size - t.off); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if !attrs.Finalized.IsZero() {
+			return 0, io.EOF
+		}
+		select {
+		case <-time.After(t.poll):
+		case <-t.ctx.Done():
+			return 0, t.ctx.Err()
+		}
+	}
+	return t.buf.Read(p)
+}
+
+// fetch reads length bytes at the current offset into the buffer. The
+// downloader caches the object size from when it was opened, so ranges
+// past it are requested with an explicit length.
+func (t *tailReader) fetch(length int64) error {
+	done := make(chan error, 1)
+	t.mrd.Add(&t.buf, t.off, length, func(_, n int64, err error) {
+		t.off += n
+		done <- err
+	})
+	// The downloader shares the reader's context, so cancelling it fails
+	// the range rather than leaving the callback pending.
+	if err := <-done; err != nil {
+		if t.ctx.Err() != nil {
+			return t.ctx.Err()
+		}
+		return fmt.Errorf("MultiRangeDownloader.Add: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying read stream.
+func (t *tailReader) Close() error {
+	return t.mrd.Close()
+}
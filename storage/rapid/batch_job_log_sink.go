@@ -0,0 +1,61 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rapid
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// batchJobLogSink runs a batch job that logs to an appendable object as
+// well as to w. Log lines become readable within a second of being
+// written, so the job can be followed with a tailReader while it runs. If
+// the job is restarted after a crash, its logs continue in the same object.
+func batchJobLogSink(w io.Writer, client *storage.Client, bucket, object string) error {
+	// bucket := "bucket-name"
+	// object := "object-name"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	sink, err := newLogWriter(ctx, client, bucket, object, logWriterOptions{
+		FlushInterval:   time.Second,
+		FinalizeOnClose: true,
+	})
+	if err != nil {
+		return err
+	}
+	logger := slog.New(slog.NewJSONHandler(io.MultiWriter(w, sink), nil))
+	if sink.Offset > 0 {
+		logger.Info("resuming job log", "offset", sink.Offset)
+	}
+
+	for step := range 3 {
+		logger.Info("processing", "step", step)
+	}
+	logger.Info("job complete")
+
+	// Closing the sink finalizes the log. A job that crashes before this
+	// leaves the object appendable, and its restart continues the log.
+	if err := sink.Close(); err != nil {
+		return fmt.Errorf("closing job log: %w", err)
+	}
+	fmt.Fprintf(w, "Job log written to gs://%v/%v\n", bucket, object)
+	return nil
+}
@@ -47,9 +47,10 @@ var (
 func TestMain(m *testing.M) {
 	ctx := context.Background()
 
-	// Skip tests by default for now, until b/452725162 is resolved
+	// Skip system tests by default for now, until b/452725162 is resolved.
+	// Tests against the local fake still run.
 	if os.Getenv("STORAGE_RUN_RAPID_TESTS") == "" {
-		os.Exit(0)
+		os.Exit(m.Run())
 	}
 
 	// Create fixture client & bucket to use across tests.
@@ -98,7 +99,17 @@ func TestMain(m *testing.M) {
 	os.Exit(exit)
 }
 
+// requireZonalBucket skips system tests when the zonal bucket fixture was
+// not created.
+func requireZonalBucket(t *testing.T) {
+	t.Helper()
+	if client == nil {
+		t.Skip("STORAGE_RUN_RAPID_TESTS not set")
+	}
+}
+
 func TestCreateAndWriteAppendableObject(t *testing.T) {
+	requireZonalBucket(t)
 	var b bytes.Buffer
 	object := "obj-appendable"
 	if err := createAndWriteAppendableObject(&b, zonalBucketName, object); err != nil {
@@ -116,6 +127,7 @@ func TestCreateAndWriteAppendableObject(t *testing.T) {
 }
 
 func TestFinalizeAppendableObject(t *testing.T) {
+	requireZonalBucket(t)
 	var b bytes.Buffer
 	object := "obj-finalize"
 	if err := finalizeAppendableObject(&b, zonalBucketName, object); err != nil {
@@ -133,6 +145,7 @@ func TestFinalizeAppendableObject(t *testing.T) {
 }
 
 func TestPauseAndResumeAppendableUpload(t *testing.T) {
+	requireZonalBucket(t)
 	var b bytes.Buffer
 	object := "obj-pause"
 	if err := pauseAndResumeAppendableUpload(&b, zonalBucketName, object); err != nil {
@@ -150,6 +163,7 @@ func TestPauseAndResumeAppendableUpload(t *testing.T) {
 }
 
 func TestOpenObjectSingleRangedRead(t *testing.T) {
+	requireZonalBucket(t)
 	var b bytes.Buffer
 	data, err := openObjectSingleRangedRead(&b, zonalBucketName, downloadObject)
 	if err != nil {
@@ -161,6 +175,7 @@ func TestOpenObjectSingleRangedRead(t *testing.T) {
 }
 
 func TestOpenObjectReadFullObject(t *testing.T) {
+	requireZonalBucket(t)
 	var b bytes.Buffer
 	data, err := openObjectReadFullObject(&b, zonalBucketName, downloadObject)
 	if err != nil {
@@ -172,6 +187,7 @@ func TestOpenObjectReadFullObject(t *testing.T) {
 }
 
 func TestOpenObjectMultipleRangedRead(t *testing.T) {
+	requireZonalBucket(t)
 	var b bytes.Buffer
 	dataSlices, err := openObjectMultipleRangedRead(&b, zonalBucketName, downloadObject)
 	if err != nil {
@@ -184,6 +200,7 @@ func TestOpenObjectMultipleRangedRead(t *testing.T) {
 }
 
 func TestOpenMultipleObjectsRangedRead(t *testing.T) {
+	requireZonalBucket(t)
 	var b bytes.Buffer
 	dataSlices, err := openMultipleObjectsRangedRead(&b, zonalBucketName, []string{downloadObject, downloadObject, downloadObject})
 	if err != nil {
@@ -197,6 +214,7 @@ func TestOpenMultipleObjectsRangedRead(t *testing.T) {
 }
 
 func TestReadAppendableObjectTail(t *testing.T) {
+	requireZonalBucket(t)
 	// Test passes locally but currently takes too long to run. Skipping
 	// on internal issue which will unblock running in CI.
 	t.Skip("b/440374150")
@@ -0,0 +1,150 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// gcsStore is an objectStore over the Cloud Storage client.
+type gcsStore struct {
+	bucket *storage.BucketHandle
+	// sign holds options for signed URLs, such as the signing credentials.
+	// Method, Expires and Scheme are set by PresignGet.
+	sign storage.SignedURLOptions
+}
+
+// newGCSStore returns a store for bucket. If sign is nil, signed URLs use
+// the client's credentials.
+func newGCSStore(client *storage.Client, bucket string, sign *storage.SignedURLOptions) *gcsStore {
+	s := &gcsStore{bucket: client.Bucket(bucket)}
+	if sign != nil {
+		s.sign = *sign
+	}
+	return s
+}
+
+func gcsInfo(attrs *storage.ObjectAttrs) objectInfo {
+	return objectInfo{
+		Key:         attrs.Name,
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		ETag:        attrs.Etag,
+		MD5:         attrs.MD5,
+		Updated:     attrs.Updated,
+	}
+}
+
+func gcsError(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %v", errObjectNotFound, err)
+	}
+	return err
+}
+
+func (s *gcsStore) write(ctx context.Context, key string, r io.Reader, chunkSize int, contentType string) (objectInfo, error) {
+	w := s.bucket.Object(key).NewWriter(ctx)
+	w.ChunkSize = chunkSize
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return objectInfo{}, fmt.Errorf("Writer.Write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return objectInfo{}, fmt.Errorf("Writer.Close: %w", err)
+	}
+	return gcsInfo(w.Attrs()), nil
+}
+
+func (s *gcsStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (objectInfo, error) {
+	// A zero chunk size sends the object in one request.
+	return s.write(ctx, key, r, 0, contentType)
+}
+
+func (s *gcsStore) Upload(ctx context.Context, key string, r io.Reader, partSize int64) (objectInfo, error) {
+	// The client rounds the chunk size up to a multiple of 256 KiB.
+	return s.write(ctx, key, r, int(partSize), "")
+}
+
+func (s *gcsStore) Get(ctx context.Context, key string) (io.ReadCloser, objectInfo, error) {
+	attrs, err := s.bucket.Object(key).Attrs(ctx)
+	if err != nil {
+		return nil, objectInfo{}, gcsError(err)
+	}
+	// Read the generation described by attrs, even if the object has since
+	// been overwritten.
+	rc, err := s.bucket.Object(key).Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return nil, objectInfo{}, gcsError(err)
+	}
+	return rc, gcsInfo(attrs), nil
+}
+
+func (s *gcsStore) Stat(ctx context.Context, key string) (objectInfo, error) {
+	attrs, err := s.bucket.Object(key).Attrs(ctx)
+	if err != nil {
+		return objectInfo{}, gcsError(err)
+	}
+	return gcsInfo(attrs), nil
+}
+
+func (s *gcsStore) List(ctx context.Context, prefix, delimiter string) (listResult, error) {
+	var res listResult
+	it := s.bucket.Objects(ctx, &storage.Query{Prefix: prefix, Delimiter: delimiter})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return res, nil
+		}
+		if err != nil {
+			return listResult{}, fmt.Errorf("Bucket.Objects: %w", err)
+		}
+		if attrs.Prefix != "" {
+			res.Prefixes = append(res.Prefixes, attrs.Prefix)
+			continue
+		}
+		res.Objects = append(res.Objects, gcsInfo(attrs))
+	}
+}
+
+func (s *gcsStore) Copy(ctx context.Context, src, dst string) (objectInfo, error) {
+	attrs, err := s.bucket.Object(dst).CopierFrom(s.bucket.Object(src)).Run(ctx)
+	if err != nil {
+		return objectInfo{}, gcsError(err)
+	}
+	return gcsInfo(attrs), nil
+}
+
+func (s *gcsStore) Delete(ctx context.Context, key string) error {
+	if err := s.bucket.Object(key).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("Object(%q).Delete: %w", key, err)
+	}
+	return nil
+}
+
+func (s *gcsStore) PresignGet(key string, expires time.Duration) (string, error) {
+	opts := s.sign
+	opts.Method = "GET"
+	opts.Expires = time.Now().Add(expires)
+	opts.Scheme = storage.SigningSchemeV4
+	return s.bucket.SignedURL(key, &opts)
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3sdk
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// errObjectNotFound is returned by objectStore methods for missing
+// objects.
+var errObjectNotFound = errors.New("object not found")
+
+// objectStore is a bucket in Cloud Storage or an S3-compatible service.
+// gcsStore uses the Cloud Storage client and s3Store uses the AWS SDK,
+// which also works against Cloud Storage with HMAC keys.
+//
+// The two differ where the services do:
+//   - ETags. Cloud Storage JSON API ETags change with every write, even of
+//     the same contents. S3 ETags are the MD5 of the contents for a single
+//     request upload, and an MD5 of the part MD5s suffixed with "-N" for a
+//     multipart upload of N parts. Compare contents with objectInfo.MD5,
+//     not ETags, across stores.
+//   - MD5 hashes. S3 multipart uploads and Cloud Storage composite objects
+//     have none. A Cloud Storage resumable upload is not composite and does
+//     have one.
+//
+// Both services list and read objects with strong consistency, so an
+// object is visible to List and Get as soon as Put returns.
+type objectStore interface {
+	// Put writes an object in a single request.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (objectInfo, error)
+	// Upload writes an object of any size in parts of partSize bytes,
+	// using an S3 multipart upload or a Cloud Storage resumable upload.
+	Upload(ctx context.Context, key string, r io.Reader, partSize int64) (objectInfo, error)
+	// Get opens an object for reading. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, objectInfo, error)
+	// Stat returns an object's metadata.
+	Stat(ctx context.Context, key string) (objectInfo, error)
+	// List returns the objects under prefix. With a delimiter, keys
+	// containing it after the prefix are rolled up into Prefixes.
+	List(ctx context.Context, prefix, delimiter string) (listResult, error)
+	// Copy copies an object within the bucket.
+	Copy(ctx context.Context, src, dst string) (objectInfo, error)
+	// Delete deletes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL that downloads the object without
+	// credentials until it expires.
+	PresignGet(key string, expires time.Duration) (string, error)
+}
+
+// objectInfo describes an object.
+type objectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	// ETag identifies a version of the object. Its format depends on the
+	// store and the upload method.
+	ETag string
+	// MD5 is the MD5 hash of the contents, or nil if the store does not
+	// know it.
+	MD5     []byte
+	Updated time.Time
+}
+
+// listResult holds the results of objectStore.List.
+type listResult struct {
+	Objects  []objectInfo
+	Prefixes []string
+}
+
+// md5FromETag returns the MD5 hash in an S3 ETag, or nil if the ETag is
+// not a plain MD5, as for multipart uploads.
+func md5FromETag(etag string) []byte {
+	etag = strings.Trim(etag, `"`)
+	if len(etag) != 32 {
+		return nil
+	}
+	sum, err := hex.DecodeString(etag)
+	if err != nil {
+		return nil
+	}
+	return sum
+}
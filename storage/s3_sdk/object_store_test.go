@@ -0,0 +1,340 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/golang-samples/storage/internal/gcsfake"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testBucket = "interop"
+
+// storeTraits records where the stores are expected to differ.
+type storeTraits struct {
+	// contentETag is set if ETags are derived from the contents, so that
+	// rewriting or copying an object keeps its ETag.
+	contentETag bool
+	// multipartETag is set if Upload in parts produces an "-N" suffixed
+	// ETag and no MD5.
+	multipartETag bool
+}
+
+var stores = []struct {
+	name   string
+	traits storeTraits
+	new    func(t *testing.T, srv *gcsfake.Server) objectStore
+}{
+	{
+		name: "gcs",
+		new: func(t *testing.T, srv *gcsfake.Server) objectStore {
+			client, err := srv.Client(context.Background())
+			if err != nil {
+				t.Fatalf("Client: %v", err)
+			}
+			t.Cleanup(func() { client.Close() })
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return newGCSStore(client, testBucket, &storage.SignedURLOptions{
+				GoogleAccessID: "signer@example.iam.gserviceaccount.com",
+				PrivateKey:     pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+				Hostname:       strings.TrimPrefix(srv.URL, "http://"),
+				Insecure:       true,
+			})
+		},
+	},
+	{
+		name:   "s3",
+		traits: storeTraits{contentETag: true, multipartETag: true},
+		new: func(t *testing.T, srv *gcsfake.Server) objectStore {
+			// The same configuration as listGCSObjects, with the fake's
+			// endpoint and path-style URLs.
+			sess := session.Must(session.NewSession(&aws.Config{
+				Region:           aws.String("auto"),
+				Endpoint:         aws.String(srv.URL),
+				S3ForcePathStyle: aws.Bool(true),
+				Credentials:      credentials.NewStaticCredentials("GOOG1EXAMPLE", "secret", ""),
+			}))
+			return newS3Store(s3.New(sess), testBucket)
+		},
+	},
+}
+
+// TestObjectStores runs the same checks against both stores, each talking
+// to a fake Cloud Storage server through its own API.
+func TestObjectStores(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s objectStore, traits storeTraits)
+	}{
+		{"PutGet", testPutGet},
+		{"NotFound", testNotFound},
+		{"List", testList},
+		{"ListConsistency", testListConsistency},
+		{"Upload", testUpload},
+		{"Copy", testCopy},
+		{"Delete", testDelete},
+		{"PresignGet", testPresignGet},
+	}
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					srv := gcsfake.NewServer()
+					t.Cleanup(srv.Close)
+					srv.CreateBucket(testBucket)
+					tt.fn(t, st.new(t, srv), st.traits)
+				})
+			}
+		})
+	}
+}
+
+func put(t *testing.T, s objectStore, key, data string) objectInfo {
+	t.Helper()
+	info, err := s.Put(context.Background(), key, strings.NewReader(data), "text/plain")
+	if err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+	return info
+}
+
+func get(t *testing.T, s objectStore, key string) ([]byte, objectInfo) {
+	t.Helper()
+	rc, info, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading %q: %v", key, err)
+	}
+	return data, info
+}
+
+func md5Of(data string) []byte {
+	sum := md5.Sum([]byte(data))
+	return sum[:]
+}
+
+func testPutGet(t *testing.T, s objectStore, traits storeTraits) {
+	info := put(t, s, "dir/hello.txt", "hello world")
+	if info.Size != 11 || info.ContentType != "text/plain" || !bytes.Equal(info.MD5, md5Of("hello world")) {
+		t.Errorf("Put = %+v, want size 11, text/plain and the MD5 of the contents", info)
+	}
+	if info.ETag == "" || info.Updated.IsZero() {
+		t.Errorf("Put = %+v, want an ETag and update time", info)
+	}
+
+	data, got := get(t, s, "dir/hello.txt")
+	if string(data) != "hello world" {
+		t.Errorf("Get = %q, want %q", data, "hello world")
+	}
+	if got.ETag != info.ETag || !bytes.Equal(got.MD5, info.MD5) {
+		t.Errorf("Get = %+v, want the ETag and MD5 of %+v", got, info)
+	}
+
+	// Rewriting the same contents keeps a content-derived ETag.
+	again := put(t, s, "dir/hello.txt", "hello world")
+	if sameETag := again.ETag == info.ETag; sameETag != traits.contentETag {
+		t.Errorf("ETag after rewrite = %q, was %q; want same = %v", again.ETag, info.ETag, traits.contentETag)
+	}
+}
+
+func testNotFound(t *testing.T, s objectStore, _ storeTraits) {
+	ctx := context.Background()
+	if _, _, err := s.Get(ctx, "missing"); !errors.Is(err, errObjectNotFound) {
+		t.Errorf("Get = %v, want errObjectNotFound", err)
+	}
+	if _, err := s.Stat(ctx, "missing"); !errors.Is(err, errObjectNotFound) {
+		t.Errorf("Stat = %v, want errObjectNotFound", err)
+	}
+	if _, err := s.Copy(ctx, "missing", "copy"); !errors.Is(err, errObjectNotFound) {
+		t.Errorf("Copy = %v, want errObjectNotFound", err)
+	}
+}
+
+func keys(res listResult) []string {
+	var k []string
+	for _, o := range res.Objects {
+		k = append(k, o.Key)
+	}
+	return k
+}
+
+func testList(t *testing.T, s objectStore, _ storeTraits) {
+	ctx := context.Background()
+	for _, k := range []string{"a.txt", "logs/1", "logs/2", "logs/2025/3", "data/x", "data.csv"} {
+		put(t, s, k, k)
+	}
+	for _, tc := range []struct {
+		prefix, delimiter string
+		want              listResult
+	}{
+		{"", "", listResult{Objects: []objectInfo{{Key: "a.txt"}, {Key: "data.csv"}, {Key: "data/x"}, {Key: "logs/1"}, {Key: "logs/2"}, {Key: "logs/2025/3"}}}},
+		{"", "/", listResult{Objects: []objectInfo{{Key: "a.txt"}, {Key: "data.csv"}}, Prefixes: []string{"data/", "logs/"}}},
+		{"logs/", "/", listResult{Objects: []objectInfo{{Key: "logs/1"}, {Key: "logs/2"}}, Prefixes: []string{"logs/2025/"}}},
+		{"data", "/", listResult{Objects: []objectInfo{{Key: "data.csv"}}, Prefixes: []string{"data/"}}},
+		{"none/", "/", listResult{}},
+	} {
+		got, err := s.List(ctx, tc.prefix, tc.delimiter)
+		if err != nil {
+			t.Fatalf("List(%q, %q): %v", tc.prefix, tc.delimiter, err)
+		}
+		if diff := cmp.Diff(keys(tc.want), keys(got)); diff != "" {
+			t.Errorf("List(%q, %q) objects mismatch (-want +got):\n%s", tc.prefix, tc.delimiter, diff)
+		}
+		if diff := cmp.Diff(tc.want.Prefixes, got.Prefixes); diff != "" {
+			t.Errorf("List(%q, %q) prefixes mismatch (-want +got):\n%s", tc.prefix, tc.delimiter, diff)
+		}
+	}
+
+	got, err := s.List(ctx, "a", "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got.Objects) != 1 || got.Objects[0].Size != 5 || !bytes.Equal(got.Objects[0].MD5, md5Of("a.txt")) {
+		t.Errorf("List(%q) = %+v, want a.txt with its size and MD5", "a", got.Objects)
+	}
+}
+
+func testListConsistency(t *testing.T, s objectStore, _ storeTraits) {
+	ctx := context.Background()
+	// Writes and deletes are visible to the next list, with no retries.
+	for i, k := range []string{"q/1", "q/2", "q/3"} {
+		put(t, s, k, k)
+		res, err := s.List(ctx, "q/", "")
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(res.Objects) != i+1 {
+			t.Errorf("List after writing %s = %v, want %d objects", k, keys(res), i+1)
+		}
+	}
+	if err := s.Delete(ctx, "q/2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	res, err := s.List(ctx, "q/", "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if diff := cmp.Diff([]string{"q/1", "q/3"}, keys(res)); diff != "" {
+		t.Errorf("List after delete mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testUpload(t *testing.T, s objectStore, traits storeTraits) {
+	const partSize = 5 << 20
+	data := make([]byte, 2*partSize+1234)
+	rand.Read(data)
+	sum := md5.Sum(data)
+
+	// A reader without Seek, as from a network stream.
+	info, err := s.Upload(context.Background(), "big.bin", io.MultiReader(bytes.NewReader(data)), partSize)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if info.Size != int64(len(data)) {
+		t.Errorf("Upload size = %d, want %d", info.Size, len(data))
+	}
+	if traits.multipartETag {
+		if !strings.HasSuffix(strings.Trim(info.ETag, `"`), "-3") || info.MD5 != nil {
+			t.Errorf("Upload = %+v, want a 3 part ETag and no MD5", info)
+		}
+	} else if !bytes.Equal(info.MD5, sum[:]) {
+		t.Errorf("Upload MD5 = %x, want %x", info.MD5, sum)
+	}
+	if got, _ := get(t, s, "big.bin"); !bytes.Equal(got, data) {
+		t.Errorf("Get after Upload returned %d bytes that do not match", len(got))
+	}
+
+	// Objects smaller than a part are uploaded in one request.
+	small, err := s.Upload(context.Background(), "small.bin", strings.NewReader("small"), partSize)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if !bytes.Equal(small.MD5, md5Of("small")) {
+		t.Errorf("Upload of a small object = %+v, want its MD5", small)
+	}
+}
+
+func testCopy(t *testing.T, s objectStore, traits storeTraits) {
+	src := put(t, s, "src/report 1.txt", "quarterly numbers")
+	dst, err := s.Copy(context.Background(), "src/report 1.txt", "dst/report 1.txt")
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if data, _ := get(t, s, "dst/report 1.txt"); string(data) != "quarterly numbers" {
+		t.Errorf("Get of copy = %q, want %q", data, "quarterly numbers")
+	}
+	if !bytes.Equal(dst.MD5, src.MD5) || dst.Size != src.Size {
+		t.Errorf("Copy = %+v, want the size and MD5 of %+v", dst, src)
+	}
+	if sameETag := dst.ETag == src.ETag; sameETag != traits.contentETag {
+		t.Errorf("Copy ETag = %q, source %q; want same = %v", dst.ETag, src.ETag, traits.contentETag)
+	}
+}
+
+func testDelete(t *testing.T, s objectStore, _ storeTraits) {
+	ctx := context.Background()
+	put(t, s, "doomed", "bye")
+	if err := s.Delete(ctx, "doomed"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Stat(ctx, "doomed"); !errors.Is(err, errObjectNotFound) {
+		t.Errorf("Stat after Delete = %v, want errObjectNotFound", err)
+	}
+	if err := s.Delete(ctx, "doomed"); err != nil {
+		t.Errorf("Delete of a missing object = %v, want nil", err)
+	}
+}
+
+func testPresignGet(t *testing.T, s objectStore, _ storeTraits) {
+	put(t, s, "shared/file.txt", "for anyone with the link")
+	u, err := s.PresignGet("shared/file.txt", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatalf("GET %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "for anyone with the link" {
+		t.Errorf("GET %s = %s %q, want the object", u, resp.Status, data)
+	}
+}
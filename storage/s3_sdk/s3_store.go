@@ -0,0 +1,181 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// s3Store is an objectStore over the AWS SDK. Against Cloud Storage, the
+// client is configured as in listGCSObjects, with path-style URLs.
+type s3Store struct {
+	client *s3.S3
+	bucket string
+}
+
+func newS3Store(client *s3.S3, bucket string) *s3Store {
+	return &s3Store{client: client, bucket: bucket}
+}
+
+// isNotFound reports whether err is a missing object error. HEAD requests
+// have no error body, so their code is "NotFound".
+func isNotFound(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && (aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound")
+}
+
+func s3Error(op string, err error) error {
+	if isNotFound(err) {
+		return fmt.Errorf("%w: %s: %v", errObjectNotFound, op, err)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) (objectInfo, error) {
+	// The SDK signs the request body, so it needs to be seekable.
+	body, ok := r.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return objectInfo{}, err
+		}
+		body = bytes.NewReader(data)
+	}
+	in := &s3.PutObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key), Body: body}
+	if contentType != "" {
+		in.ContentType = aws.String(contentType)
+	}
+	if _, err := s.client.PutObjectWithContext(ctx, in); err != nil {
+		return objectInfo{}, s3Error("PutObject", err)
+	}
+	return s.Stat(ctx, key)
+}
+
+func (s *s3Store) Upload(ctx context.Context, key string, r io.Reader, partSize int64) (objectInfo, error) {
+	// The uploader sends objects smaller than one part with PutObject. S3
+	// parts other than the last must be at least 5 MiB.
+	u := s3manager.NewUploaderWithClient(s.client, func(u *s3manager.Uploader) {
+		u.PartSize = max(partSize, s3manager.MinUploadPartSize)
+	})
+	_, err := u.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	})
+	if err != nil {
+		return objectInfo{}, fmt.Errorf("Upload: %w", err)
+	}
+	return s.Stat(ctx, key)
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, objectInfo, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, objectInfo{}, s3Error("GetObject", err)
+	}
+	etag := aws.StringValue(out.ETag)
+	return out.Body, objectInfo{
+		Key:         key,
+		Size:        aws.Int64Value(out.ContentLength),
+		ContentType: aws.StringValue(out.ContentType),
+		ETag:        etag,
+		MD5:         md5FromETag(etag),
+		Updated:     aws.TimeValue(out.LastModified),
+	}, nil
+}
+
+func (s *s3Store) Stat(ctx context.Context, key string) (objectInfo, error) {
+	out, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err != nil {
+		return objectInfo{}, s3Error("HeadObject", err)
+	}
+	etag := aws.StringValue(out.ETag)
+	return objectInfo{
+		Key:         key,
+		Size:        aws.Int64Value(out.ContentLength),
+		ContentType: aws.StringValue(out.ContentType),
+		ETag:        etag,
+		MD5:         md5FromETag(etag),
+		Updated:     aws.TimeValue(out.LastModified),
+	}, nil
+}
+
+func (s *s3Store) List(ctx context.Context, prefix, delimiter string) (listResult, error) {
+	var res listResult
+	in := &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(prefix)}
+	if delimiter != "" {
+		in.Delimiter = aws.String(delimiter)
+	}
+	err := s.client.ListObjectsV2PagesWithContext(ctx, in, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, o := range page.Contents {
+			etag := aws.StringValue(o.ETag)
+			res.Objects = append(res.Objects, objectInfo{
+				Key:     aws.StringValue(o.Key),
+				Size:    aws.Int64Value(o.Size),
+				ETag:    etag,
+				MD5:     md5FromETag(etag),
+				Updated: aws.TimeValue(o.LastModified),
+			})
+		}
+		for _, p := range page.CommonPrefixes {
+			res.Prefixes = append(res.Prefixes, aws.StringValue(p.Prefix))
+		}
+		return true
+	})
+	if err != nil {
+		return listResult{}, fmt.Errorf("ListObjectsV2: %w", err)
+	}
+	return res, nil
+}
+
+func (s *s3Store) Copy(ctx context.Context, src, dst string) (objectInfo, error) {
+	// The copy source is "BUCKET/KEY", URL-escaped.
+	source := (&url.URL{Path: s.bucket + "/" + src}).EscapedPath()
+	_, err := s.client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dst),
+		CopySource: aws.String(source),
+	})
+	if err != nil {
+		return objectInfo{}, s3Error("CopyObject", err)
+	}
+	return s.Stat(ctx, dst)
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	// Amazon S3 succeeds for missing objects, but Cloud Storage returns
+	// NoSuchKey.
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("DeleteObject: %w", err)
+	}
+	return nil
+}
+
+func (s *s3Store) PresignGet(key string, expires time.Duration) (string, error) {
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	return req.Presign(expires)
+}
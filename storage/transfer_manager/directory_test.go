@@ -0,0 +1,334 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/golang-samples/storage/internal/gcsfake"
+	"github.com/google/go-cmp/cmp"
+)
+
+const fakeBucket = "tm-fake-bucket"
+
+func newFake(t *testing.T) (*gcsfake.Server, *storage.Client) {
+	t.Helper()
+	srv := gcsfake.NewServer()
+	t.Cleanup(srv.Close)
+	srv.CreateBucket(fakeBucket)
+	client, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return srv, client
+}
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files, err := walkFiles(dir)
+	if err != nil {
+		t.Fatalf("walkFiles: %v", err)
+	}
+	got := make(map[string]string)
+	for rel, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[rel] = string(b)
+	}
+	return got
+}
+
+// progressLog records the final progress report of each object.
+type progressLog struct {
+	mu    sync.Mutex
+	final map[string]Progress
+	calls int
+	// regressed is set if an object's byte count went backwards.
+	regressed bool
+	last      map[string]int64
+}
+
+func (l *progressLog) record(p Progress) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.final == nil {
+		l.final = make(map[string]Progress)
+		l.last = make(map[string]int64)
+	}
+	l.calls++
+	if p.Bytes < l.last[p.Object] || p.Bytes > p.Total {
+		l.regressed = true
+	}
+	l.last[p.Object] = p.Bytes
+	if p.Done {
+		l.final[p.Object] = p
+	}
+}
+
+func TestUploadDirectory(t *testing.T) {
+	srv, client := newFake(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt":          "alpha",
+		"sub/b.txt":      "bravo",
+		"sub/deep/c.txt": "charlie",
+	}
+	writeTree(t, dir, files)
+
+	var log progressLog
+	got, err := uploadDirectory(context.Background(), client, fakeBucket, dir, "up/", TransferOptions{Workers: 2, Progress: log.record})
+	if err != nil {
+		t.Fatalf("uploadDirectory: %v", err)
+	}
+	want := []string{"up/a.txt", "up/sub/b.txt", "up/sub/deep/c.txt"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("uploaded objects mismatch (-want +got):\n%s", diff)
+	}
+	for rel, data := range files {
+		if b, _ := srv.Object(fakeBucket, "up/"+rel); string(b) != data {
+			t.Errorf("object up/%s = %q, want %q", rel, b, data)
+		}
+	}
+	if p := log.final["up/sub/deep/c.txt"]; p.Bytes != 7 || p.Total != 7 || p.Err != nil {
+		t.Errorf("final progress = %+v, want 7 of 7 bytes", p)
+	}
+}
+
+func TestParallelCompositeUpload(t *testing.T) {
+	srv, client := newFake(t)
+	dir := t.TempDir()
+	// 100 parts need two rounds of composition.
+	large := bytes.Repeat([]byte("0123456789"), 10_000)
+	writeTree(t, dir, map[string]string{"large.bin": string(large), "small.txt": "tiny"})
+
+	var log progressLog
+	opts := TransferOptions{CompositeThreshold: 10_000, PartSize: 1_000, Progress: log.record}
+	if _, err := uploadDirectory(context.Background(), client, fakeBucket, dir, "", opts); err != nil {
+		t.Fatalf("uploadDirectory: %v", err)
+	}
+	if b, _ := srv.Object(fakeBucket, "large.bin"); !bytes.Equal(b, large) {
+		t.Errorf("composed object has %d bytes, want the %d byte file", len(b), len(large))
+	}
+	if diff := cmp.Diff([]string{"large.bin", "small.txt"}, srv.Objects(fakeBucket)); diff != "" {
+		t.Errorf("temporary objects left behind (-want +got):\n%s", diff)
+	}
+	attrs, err := client.Bucket(fakeBucket).Object("large.bin").Attrs(context.Background())
+	if err != nil {
+		t.Fatalf("Attrs: %v", err)
+	}
+	if attrs.ComponentCount != 100 {
+		t.Errorf("ComponentCount = %d, want 100", attrs.ComponentCount)
+	}
+	if p := log.final["large.bin"]; p.Bytes != int64(len(large)) || p.Err != nil {
+		t.Errorf("final progress = %+v, want all bytes", p)
+	}
+	if log.regressed {
+		t.Errorf("progress went backwards or past the total")
+	}
+}
+
+func TestParallelCompositeUploadFailure(t *testing.T) {
+	srv, client := newFake(t)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"large.bin": strings.Repeat("x", 5_000)})
+	srv.SetHook(func(r *http.Request) int {
+		if strings.HasSuffix(r.URL.Path, "/compose") {
+			return http.StatusForbidden
+		}
+		return 0
+	})
+
+	opts := TransferOptions{CompositeThreshold: 1, PartSize: 1_000}
+	if _, err := uploadDirectory(context.Background(), client, fakeBucket, dir, "", opts); err == nil {
+		t.Fatalf("uploadDirectory succeeded, want the compose error")
+	}
+	if got := srv.Objects(fakeBucket); len(got) != 0 {
+		t.Errorf("objects after a failed upload = %v, want none", got)
+	}
+
+	opts.PartSize = 1
+	if _, err := parallelCompositeUpload(context.Background(), client.Bucket(fakeBucket), "large.bin", filepath.Join(dir, "large.bin"), opts); err == nil || !strings.Contains(err.Error(), "5000 parts") {
+		t.Errorf("upload of 5000 parts got %v, want an error", err)
+	}
+}
+
+func TestDownloadDirectory(t *testing.T) {
+	srv, client := newFake(t)
+	srv.PutObject(fakeBucket, "data/x.txt", []byte("x"))
+	srv.PutObject(fakeBucket, "data/y/z.txt", []byte("z"))
+	srv.PutObject(fakeBucket, "data/folder/", nil)
+	srv.PutObject(fakeBucket, "other/q.txt", []byte("q"))
+
+	dir := t.TempDir()
+	var log progressLog
+	got, err := downloadDirectory(context.Background(), client, fakeBucket, "data/", dir, TransferOptions{Progress: log.record})
+	if err != nil {
+		t.Fatalf("downloadDirectory: %v", err)
+	}
+	if diff := cmp.Diff([]string{"data/x.txt", "data/y/z.txt"}, got); diff != "" {
+		t.Errorf("downloaded objects mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"x.txt": "x", "y/z.txt": "z"}, readTree(t, dir)); diff != "" {
+		t.Errorf("local files mismatch (-want +got):\n%s", diff)
+	}
+	if p := log.final["data/y/z.txt"]; !p.Done || p.Bytes != 1 || p.File != filepath.Join(dir, "y", "z.txt") {
+		t.Errorf("final progress = %+v", p)
+	}
+
+	// A prefix without a trailing slash names the same folder, and doesn't
+	// match objects whose name merely starts with it.
+	srv.PutObject(fakeBucket, "dataX/w.txt", []byte("w"))
+	dir2 := t.TempDir()
+	got, err = downloadDirectory(context.Background(), client, fakeBucket, "data", dir2, TransferOptions{})
+	if err != nil {
+		t.Fatalf("downloadDirectory without a trailing slash: %v", err)
+	}
+	if diff := cmp.Diff([]string{"data/x.txt", "data/y/z.txt"}, got); diff != "" {
+		t.Errorf("downloaded objects without a trailing slash mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"x.txt": "x", "y/z.txt": "z"}, readTree(t, dir2)); diff != "" {
+		t.Errorf("local files without a trailing slash mismatch (-want +got):\n%s", diff)
+	}
+
+	// Names that escape the directory fail without writing anything.
+	srv.PutObject(fakeBucket, "data/../escape.txt", []byte("!"))
+	if _, err := downloadDirectory(context.Background(), client, fakeBucket, "data/", dir, TransferOptions{}); err == nil {
+		t.Errorf("downloadDirectory with an escaping name succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); err == nil {
+		t.Errorf("escaping object was written outside the directory")
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	dir := filepath.FromSlash("/tmp/out")
+	tests := []struct {
+		prefix, object string
+		want           string
+		ok             bool
+	}{
+		{"reports/", "reports/a.txt", "a.txt", true},
+		{"reports", "reports/a/b.txt", "a/b.txt", true},
+		{"", "a.txt", "a.txt", true},
+		{"reports/", "reports/", "", false},
+		{"reports/", "reports/sub/", "", false},
+		{"reports/", "reports/../x.txt", "", false},
+	}
+	for _, tc := range tests {
+		path, ok := localPath(dir, dirPrefix(tc.prefix), tc.object)
+		want := ""
+		if tc.ok {
+			want = filepath.Join(dir, filepath.FromSlash(tc.want))
+		}
+		if path != want || ok != tc.ok {
+			t.Errorf("localPath(%q, %q) = %q, %v, want %q, %v", tc.prefix, tc.object, path, ok, want, tc.ok)
+		}
+	}
+}
+
+func TestSyncDirectory(t *testing.T) {
+	ctx := context.Background()
+	srv, client := newFake(t)
+	srv.PutObject(fakeBucket, "r/same.txt", []byte("same"))
+	srv.PutObject(fakeBucket, "r/changed.txt", []byte("new!"))
+	srv.PutObject(fakeBucket, "r/new/file.txt", []byte("new"))
+	srv.PutObject(fakeBucket, "r/part1", []byte("comp"))
+	srv.PutObject(fakeBucket, "r/part2", []byte("osed"))
+	bkt := client.Bucket(fakeBucket)
+	if _, err := bkt.Object("r/composite").ComposerFrom(bkt.Object("r/part1"), bkt.Object("r/part2")).Run(ctx); err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"same.txt":    "same",
+		"changed.txt": "old!",
+		"composite":   "composed",
+		"part1":       "comp",
+		"part2":       "osed",
+		"extra.txt":   "extra",
+	})
+
+	var downloads atomic.Int32
+	srv.SetHook(func(r *http.Request) int {
+		if r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/storage/") {
+			downloads.Add(1)
+		}
+		return 0
+	})
+
+	got, err := syncDirectory(ctx, client, fakeBucket, "r/", dir, SyncOptions{})
+	if err != nil {
+		t.Fatalf("syncDirectory: %v", err)
+	}
+	want := &SyncResult{
+		Downloaded: []string{"r/changed.txt", "r/new/file.txt"},
+		Skipped:    []string{"r/composite", "r/part1", "r/part2", "r/same.txt"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("first sync mismatch (-want +got):\n%s", diff)
+	}
+	if n := downloads.Load(); n != 2 {
+		t.Errorf("first sync downloaded %d objects, want 2", n)
+	}
+
+	downloads.Store(0)
+	got, err = syncDirectory(ctx, client, fakeBucket, "r/", dir, SyncOptions{DeleteExtras: true})
+	if err != nil {
+		t.Fatalf("syncDirectory: %v", err)
+	}
+	if len(got.Downloaded) != 0 || len(got.Skipped) != 6 || downloads.Load() != 0 {
+		t.Errorf("second sync = %+v with %d downloads, want everything skipped", got, downloads.Load())
+	}
+	if diff := cmp.Diff([]string{filepath.Join(dir, "extra.txt")}, got.Deleted); diff != "" {
+		t.Errorf("deleted files mismatch (-want +got):\n%s", diff)
+	}
+	wantFiles := map[string]string{
+		"same.txt":     "same",
+		"changed.txt":  "new!",
+		"new/file.txt": "new",
+		"composite":    "composed",
+		"part1":        "comp",
+		"part2":        "osed",
+	}
+	if diff := cmp.Diff(wantFiles, readTree(t, dir)); diff != "" {
+		t.Errorf("local files mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+)
+
+// downloadDirectory downloads every object in bucket under the folder prefix
+// into dir, recreating the rest of each name as a relative path. A "/" is
+// appended to prefix if it doesn't end in one. It returns the names of the downloaded objects. Names ending in "/" are
+// treated as folder placeholders and skipped. A failed object does not stop
+// the others; all failures are returned together.
+func downloadDirectory(ctx context.Context, client *storage.Client, bucket, prefix, dir string, opts TransferOptions) ([]string, error) {
+	// bucket := "bucket-name"
+	// prefix := "backups/2024-01-01/"
+	// dir := "path/to/local/dir"
+	prefix = dirPrefix(prefix)
+	objs, err := listObjects(ctx, client.Bucket(bucket), prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]error, len(objs))
+	g := new(errgroup.Group)
+	g.SetLimit(opts.workers())
+	for i, attrs := range objs {
+		if strings.HasSuffix(attrs.Name, "/") {
+			continue
+		}
+		path, ok := localPath(dir, prefix, attrs.Name)
+		if !ok {
+			results[i] = fmt.Errorf("object %s would be written outside %s", attrs.Name, dir)
+			continue
+		}
+		g.Go(func() error {
+			results[i] = downloadFile(ctx, client.Bucket(bucket).Object(attrs.Name), attrs.Size, path, opts)
+			return nil
+		})
+	}
+	g.Wait()
+
+	var downloaded []string
+	var errs []error
+	for i, err := range results {
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case !strings.HasSuffix(objs[i].Name, "/"):
+			downloaded = append(downloaded, objs[i].Name)
+		}
+	}
+	return downloaded, errors.Join(errs...)
+}
+
+// listObjects returns the attributes of the objects whose names start with
+// prefix, in name order.
+func listObjects(ctx context.Context, bkt *storage.BucketHandle, prefix string) ([]*storage.ObjectAttrs, error) {
+	var objs []*storage.ObjectAttrs
+	it := bkt.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing objects with prefix %q: %w", prefix, err)
+		}
+		objs = append(objs, attrs)
+	}
+}
+
+// downloadFile downloads an object to a temporary file next to path and
+// renames it into place, so that path never holds a partial download.
+func downloadFile(ctx context.Context, o *storage.ObjectHandle, size int64, path string, opts TransferOptions) (err error) {
+	p := Progress{Object: o.ObjectName(), File: path, Total: size}
+	done := new(atomic.Int64)
+	defer func() {
+		p.Bytes, p.Done, p.Err = done.Load(), true, err
+		opts.report(p)
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".download-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	r, err := o.NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", o.ObjectName(), err)
+	}
+	defer r.Close()
+	// The reader checks the CRC32C of the whole object at EOF.
+	if _, err := io.Copy(f, &progressReader{r: r, done: done, p: p, opts: opts}); err != nil {
+		return fmt.Errorf("downloading %s: %w", o.ObjectName(), err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// defaultPartSize is the part size of parallel composite uploads when
+	// TransferOptions.PartSize is unset.
+	defaultPartSize = 32 << 20
+	// maxComposeSources is the number of objects one compose request
+	// accepts.
+	maxComposeSources = 32
+	// maxComponents is the number of components a composite object may
+	// have.
+	maxComponents = 1024
+)
+
+// parallelCompositeUpload uploads a file as parts of opts.PartSize bytes in
+// parallel, then composes them into object and deletes the parts. Parts are
+// composed in groups of 32, so files of up to 1024 parts are supported.
+// The result is checked against the file's CRC32C; composite objects have
+// no MD5 hash.
+func parallelCompositeUpload(ctx context.Context, bkt *storage.BucketHandle, object, name string, opts TransferOptions) (attrs *storage.ObjectAttrs, err error) {
+	o := bkt.Object(object)
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	}
+	n := int((size + partSize - 1) / partSize)
+	if n == 0 {
+		n = 1
+	}
+	if n > maxComponents {
+		return nil, fmt.Errorf("%s would be split into %d parts, more than the %d components a composite object can have; use a larger part size", name, n, maxComponents)
+	}
+	crc, _, err := fileHashes(name)
+	if err != nil {
+		return nil, fmt.Errorf("hashing %s: %w", name, err)
+	}
+
+	p := Progress{Object: o.ObjectName(), File: name, Total: size}
+	done := new(atomic.Int64)
+	defer func() {
+		p.Bytes, p.Done, p.Err = done.Load(), true, err
+		opts.report(p)
+	}()
+
+	// Temporary objects live next to the destination, under a unique
+	// prefix, and are deleted however the upload ends.
+	tmp := fmt.Sprintf("%s.parts-%s/", o.ObjectName(), uuid.NewString())
+	var temps []*storage.ObjectHandle
+	defer func() {
+		cleanup := context.WithoutCancel(ctx)
+		for _, t := range temps {
+			if derr := t.Delete(cleanup); derr != nil && !errors.Is(derr, storage.ErrObjectNotExist) && err == nil {
+				err = fmt.Errorf("deleting temporary object %s: %w", t.ObjectName(), derr)
+			}
+		}
+	}()
+
+	parts := make([]*storage.ObjectHandle, n)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.workers())
+	for i := range parts {
+		parts[i] = bkt.Object(fmt.Sprintf("%s%05d", tmp, i))
+		temps = append(temps, parts[i])
+		off := int64(i) * partSize
+		g.Go(func() error {
+			_, err := uploadSection(gctx, parts[i], name, off, min(partSize, size-off), done, p, opts)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for level := 0; len(parts) > maxComposeSources; level++ {
+		var next []*storage.ObjectHandle
+		for i := 0; i < len(parts); i += maxComposeSources {
+			dst := bkt.Object(fmt.Sprintf("%scompose-%d-%05d", tmp, level, i/maxComposeSources))
+			temps = append(temps, dst)
+			if _, err := dst.ComposerFrom(parts[i:min(i+maxComposeSources, len(parts))]...).Run(ctx); err != nil {
+				return nil, fmt.Errorf("composing %s: %w", dst.ObjectName(), err)
+			}
+			next = append(next, dst)
+		}
+		parts = next
+	}
+
+	c := o.ComposerFrom(parts...)
+	c.CRC32C = crc
+	c.SendCRC32C = true
+	if attrs, err = c.Run(ctx); err != nil {
+		return nil, fmt.Errorf("composing %s: %w", o.ObjectName(), err)
+	}
+	if attrs.CRC32C != crc {
+		return nil, fmt.Errorf("composed %s has CRC32C %08x, want %08x", o.ObjectName(), attrs.CRC32C, crc)
+	}
+	return attrs, nil
+}
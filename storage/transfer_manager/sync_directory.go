@@ -0,0 +1,145 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// SyncOptions configures syncDirectory.
+type SyncOptions struct {
+	TransferOptions
+	// DeleteExtras removes local files that have no matching object.
+	DeleteExtras bool
+}
+
+// SyncResult lists what syncDirectory did.
+type SyncResult struct {
+	// Downloaded and Skipped hold object names.
+	Downloaded []string
+	Skipped    []string
+	// Deleted holds local paths.
+	Deleted []string
+}
+
+// syncDirectory makes dir mirror the objects in bucket under the folder
+// prefix, to which a "/" is appended if it doesn't end in one. Objects whose local copy has the same size and checksums
+// are skipped: the CRC32C must match, and so must the MD5 if the object has
+// one (composite objects do not). Extra local files are deleted only if
+// opts.DeleteExtras is set and every download succeeded.
+func syncDirectory(ctx context.Context, client *storage.Client, bucket, prefix, dir string, opts SyncOptions) (*SyncResult, error) {
+	// bucket := "bucket-name"
+	// prefix := "reports/"
+	// dir := "path/to/local/dir"
+	prefix = dirPrefix(prefix)
+	objs, err := listObjects(ctx, client.Bucket(bucket), prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	files, err := walkFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("walking %s: %w", dir, err)
+	}
+
+	var (
+		mu     sync.Mutex
+		res    = new(SyncResult)
+		errs   []error
+		wanted = make(map[string]bool)
+	)
+	g := new(errgroup.Group)
+	g.SetLimit(opts.workers())
+	for _, attrs := range objs {
+		if strings.HasSuffix(attrs.Name, "/") {
+			continue
+		}
+		path, ok := localPath(dir, prefix, attrs.Name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("object %s would be written outside %s", attrs.Name, dir))
+			continue
+		}
+		wanted[strings.TrimPrefix(attrs.Name, prefix)] = true
+		g.Go(func() error {
+			same, err := unchanged(path, attrs)
+			if err == nil && !same {
+				err = downloadFile(ctx, client.Bucket(bucket).Object(attrs.Name), attrs.Size, path, opts.TransferOptions)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				errs = append(errs, err)
+			case same:
+				res.Skipped = append(res.Skipped, attrs.Name)
+			default:
+				res.Downloaded = append(res.Downloaded, attrs.Name)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	if opts.DeleteExtras && len(errs) == 0 {
+		for rel, path := range files {
+			if wanted[rel] {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			res.Deleted = append(res.Deleted, path)
+		}
+	}
+	sort.Strings(res.Downloaded)
+	sort.Strings(res.Skipped)
+	sort.Strings(res.Deleted)
+	return res, errors.Join(errs...)
+}
+
+// unchanged reports whether the file at path has the object's contents.
+func unchanged(path string, attrs *storage.ObjectAttrs) (bool, error) {
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !fi.Mode().IsRegular() || fi.Size() != attrs.Size {
+		return false, nil
+	}
+	crc, sum, err := fileHashes(path)
+	if err != nil {
+		return false, err
+	}
+	if crc != attrs.CRC32C {
+		return false, nil
+	}
+	return len(attrs.MD5) == 0 || bytes.Equal(sum, attrs.MD5), nil
+}
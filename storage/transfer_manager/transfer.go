@@ -0,0 +1,152 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"crypto/md5"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultWorkers is the number of files transferred at once when
+// TransferOptions.Workers is unset.
+const defaultWorkers = 8
+
+// Progress reports how far a single file transfer has got.
+type Progress struct {
+	Object string
+	File   string
+	// Bytes is the number of bytes transferred so far, out of Total.
+	Bytes int64
+	Total int64
+	// Done is set on the last report for a file, with Err set if the
+	// transfer failed.
+	Done bool
+	Err  error
+}
+
+// TransferOptions configures directory transfers.
+type TransferOptions struct {
+	// Workers is the number of files transferred concurrently.
+	Workers int
+	// Progress, if set, is called as files are transferred. It may be called
+	// from several goroutines at once.
+	Progress func(Progress)
+	// CompositeThreshold is the file size from which uploads are split into
+	// parts that are uploaded in parallel and composed. Zero disables
+	// parallel composite uploads.
+	CompositeThreshold int64
+	// PartSize is the size of each part of a parallel composite upload.
+	// The default is 32 MiB.
+	PartSize int64
+}
+
+func (o TransferOptions) workers() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return defaultWorkers
+}
+
+func (o TransferOptions) report(p Progress) {
+	if o.Progress != nil {
+		o.Progress(p)
+	}
+}
+
+// progressReader reports the bytes read through it. done is shared by
+// readers that transfer parts of the same file.
+type progressReader struct {
+	r    io.Reader
+	done *atomic.Int64
+	p    Progress
+	opts TransferOptions
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	if n > 0 && pr.opts.Progress != nil {
+		p := pr.p
+		p.Bytes = pr.done.Add(int64(n))
+		pr.opts.Progress(p)
+	} else if n > 0 {
+		pr.done.Add(int64(n))
+	}
+	return n, err
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// fileHashes returns the CRC32C and MD5 of a file's contents, as Cloud
+// Storage computes them.
+func fileHashes(name string) (uint32, []byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	crc := crc32.New(crc32cTable)
+	sum := md5.New()
+	if _, err := io.Copy(io.MultiWriter(crc, sum), f); err != nil {
+		return 0, nil, err
+	}
+	return crc.Sum32(), sum.Sum(nil), nil
+}
+
+// dirPrefix returns prefix as the name of a folder, ending in "/", so that
+// "reports" names the objects under "reports/" and not "reportsX/...".
+func dirPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// localPath maps an object name under prefix, a folder name as returned by
+// dirPrefix, to a path under dir. It reports false for names that are not
+// files or would escape dir.
+func localPath(dir, prefix, object string) (string, bool) {
+	rel := strings.TrimPrefix(object, prefix)
+	if rel == "" || strings.HasSuffix(rel, "/") {
+		return "", false
+	}
+	rel = filepath.FromSlash(rel)
+	if !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return filepath.Join(dir, rel), true
+}
+
+// walkFiles returns the regular files under dir, keyed by their slash
+// separated path relative to dir.
+func walkFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = path
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfermanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// uploadDirectory uploads every file under dir to bucket, naming each
+// object prefix followed by the file's slash-separated path relative to dir.
+// A "/" is appended to prefix if it doesn't end in one. It returns the names of the uploaded objects. A failed file does not stop
+// the others; all failures are returned together.
+func uploadDirectory(ctx context.Context, client *storage.Client, bucket, dir, prefix string, opts TransferOptions) ([]string, error) {
+	// bucket := "bucket-name"
+	// dir := "path/to/local/dir"
+	// prefix := "backups/2024-01-01/"
+	prefix = dirPrefix(prefix)
+	files, err := walkFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("walking %s: %w", dir, err)
+	}
+	rels := make([]string, 0, len(files))
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	results := make([]error, len(rels))
+	g := new(errgroup.Group)
+	g.SetLimit(opts.workers())
+	for i, rel := range rels {
+		g.Go(func() error {
+			_, results[i] = uploadFile(ctx, client.Bucket(bucket), prefix+rel, files[rel], opts)
+			return nil
+		})
+	}
+	g.Wait()
+
+	var uploaded []string
+	var errs []error
+	for i, err := range results {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		uploaded = append(uploaded, prefix+rels[i])
+	}
+	return uploaded, errors.Join(errs...)
+}
+
+// uploadFile uploads a single file, using a parallel composite upload if
+// the file is large enough.
+func uploadFile(ctx context.Context, bkt *storage.BucketHandle, object, name string, opts TransferOptions) (*storage.ObjectAttrs, error) {
+	o := bkt.Object(object)
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CompositeThreshold > 0 && fi.Size() >= opts.CompositeThreshold {
+		return parallelCompositeUpload(ctx, bkt, object, name, opts)
+	}
+
+	p := Progress{Object: o.ObjectName(), File: name, Total: fi.Size()}
+	done := new(atomic.Int64)
+	attrs, err := uploadSection(ctx, o, name, 0, fi.Size(), done, p, opts)
+	p.Bytes, p.Done, p.Err = done.Load(), true, err
+	opts.report(p)
+	return attrs, err
+}
+
+// uploadSection uploads length bytes of a file starting at off, sending
+// their CRC32C so that Cloud Storage rejects corrupted uploads.
+func uploadSection(ctx context.Context, o *storage.ObjectHandle, name string, off, length int64, done *atomic.Int64, p Progress, opts TransferOptions) (*storage.ObjectAttrs, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	crc, err := sectionCRC32C(f, off, length)
+	if err != nil {
+		return nil, fmt.Errorf("hashing %s: %w", name, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := o.NewWriter(ctx)
+	w.CRC32C = crc
+	w.SendCRC32C = true
+	r := &progressReader{r: io.NewSectionReader(f, off, length), done: done, p: p, opts: opts}
+	if _, err := io.Copy(w, r); err != nil {
+		// Canceling the context aborts the upload.
+		cancel()
+		w.Close()
+		return nil, fmt.Errorf("uploading %s to %s: %w", name, o.ObjectName(), err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("uploading %s to %s: %w", name, o.ObjectName(), err)
+	}
+	return w.Attrs(), nil
+}
+
+func sectionCRC32C(f *os.File, off, length int64) (uint32, error) {
+	h := crc32.New(crc32cTable)
+	if _, err := io.Copy(h, io.NewSectionReader(f, off, length)); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}